@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// 內部錯誤的回應
+//
+// 資料庫與檔案系統的錯誤訊息可能含有 SQL 片段、表格名稱或伺服器上的路徑，不直接回傳給用戶端。
+// writeDBError 依錯誤類別回傳 409（違反唯一或外鍵約束）、503（資料庫忙碌，附 Retry-After）、
+// 404（查無資料）或 500；writeInternalError 用於檔案等其他內部錯誤，一律 500。
+// 回應只含錯誤代碼與請求編號，完整的錯誤連同請求編號記錄在伺服器日誌中。
+//
+// 每個請求都有請求編號：沿用用戶端送來的 X-Request-ID（格式合理時），否則隨機產生，
+// 並以 X-Request-ID 標頭回傳。
+
+type requestIDKey struct{}
+
+// 為每個請求指定請求編號
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// 1 到 64 個英數字、- 或 _，避免將任意內容寫進日誌
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// 目前請求的編號；不是經由 withRequestID 的請求為空字串
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// 資料庫錯誤的類別，回傳 HTTP 狀態碼與錯誤代碼
+func classifyDBError(err error) (int, string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, "record_not_found"
+	}
+	var se *sqlite.Error
+	if errors.As(err, &se) {
+		switch se.Code() & 0xff { // 延伸錯誤碼的低 8 位元為主要錯誤碼
+		case 19: // SQLITE_CONSTRAINT
+			return http.StatusConflict, "constraint_violation"
+		case 5, 6: // SQLITE_BUSY、SQLITE_LOCKED
+			return http.StatusServiceUnavailable, "database_busy"
+		}
+	}
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		switch {
+		case pe.Code.Class() == "23": // integrity_constraint_violation
+			return http.StatusConflict, "constraint_violation"
+		case pe.Code == "40001" || pe.Code == "40P01" || pe.Code == "55P03": // 序列化失敗、死結、無法取得鎖
+			return http.StatusServiceUnavailable, "database_busy"
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable, "database_busy"
+	}
+	return http.StatusInternalServerError, "database_error"
+}
+
+// 輸出資料庫錯誤，完整錯誤只寫入日誌
+func writeDBError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := classifyDBError(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	writeLoggedError(w, r, status, code, err)
+}
+
+// 輸出檔案等其他內部錯誤（500），完整錯誤只寫入日誌
+func writeInternalError(w http.ResponseWriter, r *http.Request, code string, err error) {
+	writeLoggedError(w, r, http.StatusInternalServerError, code, err)
+}
+
+func writeLoggedError(w http.ResponseWriter, r *http.Request, status int, code string, err error) {
+	id := requestID(r)
+	log.Printf("[%s] %s %s: %s: %v", id, r.Method, r.URL.Path, code, err)
+	writeJSON(w, status, map[string]interface{}{
+		"success":    false,
+		"error":      code,
+		"message":    localize(requestLanguage(r), code, id),
+		"request_id": id,
+	})
+}
+
+// 逐筆處理的結果（例如批次下載）中可顯示的錯誤訊息：內部錯誤記錄後以請求編號取代
+func safeErrorMessage(r *http.Request, code string, err error) string {
+	id := requestID(r)
+	log.Printf("[%s] %s %s: %s: %v", id, r.Method, r.URL.Path, code, err)
+	return localize(requestLanguage(r), code, id)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 違反約束時回應 409，回應中沒有 SQL 文字，完整錯誤連同請求編號寫入日誌
+func TestConstraintViolationResponse(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	// 以唯一索引製造真正的約束錯誤
+	if _, err := s.db.Exec("CREATE UNIQUE INDEX test_unique_title ON bookmarks(title)"); err != nil {
+		t.Fatal(err)
+	}
+	addTestBookmark(t, h, `{"job_number":"A001","title":"同名"}`)
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	req := httptest.NewRequest("POST", "/api/bookmarks", strings.NewReader(`{"job_number":"A002","title":"同名"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", "req-472")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	for _, leak := range []string{"UNIQUE", "constraint failed", "bookmarks.title", "INSERT", "SQL"} {
+		if strings.Contains(body, leak) {
+			t.Errorf("回應含有 %q: %s", leak, body)
+		}
+	}
+	resp := decodeBody(t, w)
+	if resp["error"] != "constraint_violation" || resp["request_id"] != "req-472" || w.Header().Get("X-Request-ID") != "req-472" {
+		t.Errorf("response = %v, X-Request-ID = %q", resp, w.Header().Get("X-Request-ID"))
+	}
+	if !strings.Contains(logs.String(), "[req-472]") || !strings.Contains(logs.String(), "UNIQUE constraint failed") {
+		t.Errorf("日誌沒有完整錯誤: %s", logs.String())
+	}
+}
+
+func TestClassifyDBError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{sql.ErrNoRows, http.StatusNotFound, "record_not_found"},
+		{fmt.Errorf("查詢: %w", sql.ErrNoRows), http.StatusNotFound, "record_not_found"},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable, "database_busy"},
+		{errors.New(`near "SELEC": syntax error`), http.StatusInternalServerError, "database_error"},
+	}
+	for _, tt := range tests {
+		status, code := classifyDBError(tt.err)
+		if status != tt.status || code != tt.code {
+			t.Errorf("classifyDBError(%v) = %d %s, want %d %s", tt.err, status, code, tt.status, tt.code)
+		}
+	}
+
+	// 503 附 Retry-After
+	req := httptest.NewRequest("GET", "/api/bookmarks", nil)
+	w := httptest.NewRecorder()
+	writeDBError(w, req, context.DeadlineExceeded)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("busy = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
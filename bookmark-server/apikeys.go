@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// API 金鑰驗證
+//
+// 設定 api_keys（或環境變數 API_KEYS）後，/api/ 之下的請求都必須以 X-API-Key 標頭帶其中一組金鑰，
+// 沒有帶或不符時回傳 401；未設定時維持開放。靜態檔案、分享頁 /share/{token} 與行事曆 /calendar/ 不在 /api/ 之下，不受影響；
+// 分享連結的 JSON（GET /api/shares/{token}.json）本身以權杖保護，同樣不需要金鑰。
+// 瀏覽器的 EventSource 與 WebSocket 無法自訂標頭，事件串流與 /api/ws 也接受 ?api_key=。
+// WebSocket 與 Telegram 的指令經由內部請求執行（見 internalapi.go），入口已經驗證過，不再檢查。
+// 預檢請求（OPTIONS）不帶自訂標頭，一律放行，由 allowMethods 回應。
+
+// 金鑰最短長度，避免設定成容易猜到的短字串
+const minAPIKeyLength = 16
+
+// 可以用 ?api_key= 帶金鑰的路徑
+var apiKeyQueryPaths = map[string]bool{
+	"/api/events/stream": true,
+	"/api/ws":            true,
+}
+
+// 去除空白與空項目並檢查長度
+func normalizeAPIKeys(keys []string) ([]string, error) {
+	var out []string
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if len(key) < minAPIKeyLength {
+			return nil, fmt.Errorf("API 金鑰至少需要 %d 個字元", minAPIKeyLength)
+		}
+		if !containsString(out, key) {
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// 來源須為 scheme://host[:port]，不可有路徑；結尾的 / 去除
+func normalizeOrigins(origins []string) ([]string, error) {
+	var out []string
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("allowed_origins 格式錯誤（須為 scheme://host[:port]）: %q", origin)
+		}
+		out = append(out, origin)
+	}
+	return out, nil
+}
+
+// 金鑰是否為設定中的其中一組；比較雜湊值，耗時與金鑰內容和長度無關
+func (s *Server) validAPIKey(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	match := 0
+	for _, k := range s.cfg.APIKeys {
+		expected := sha256.Sum256([]byte(k))
+		match |= subtle.ConstantTimeCompare(sum[:], expected[:])
+	}
+	return match == 1
+}
+
+// 不需要金鑰的 API：分享連結的 JSON
+func isPublicAPIRequest(r *http.Request) bool {
+	return (r.Method == "GET" || r.Method == "HEAD") &&
+		strings.HasPrefix(r.URL.Path, "/api/shares/") && strings.HasSuffix(r.URL.Path, ".json")
+}
+
+// 檢查 API 金鑰，不通過時回應 401 並回傳 false
+func (s *Server) checkAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.cfg.APIKeys) == 0 || !strings.HasPrefix(r.URL.Path, "/api/") || isInternalRequest(r) || isPublicAPIRequest(r) {
+		return true
+	}
+	key := r.Header.Get("X-API-Key")
+	if key == "" && apiKeyQueryPaths[r.URL.Path] {
+		key = r.URL.Query().Get("api_key")
+	}
+	if key == "" {
+		writeError(w, r, http.StatusUnauthorized, "api_key_required")
+		return false
+	}
+	if !s.validAPIKey(key) {
+		writeError(w, r, http.StatusUnauthorized, "api_key_invalid")
+		return false
+	}
+	return true
+}
+
+type internalRequestKey struct{}
+
+// 標記為伺服器內部請求
+func withInternalRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalRequestKey{}, true)
+}
+
+func isInternalRequest(r *http.Request) bool {
+	internal, _ := r.Context().Value(internalRequestKey{}).(bool)
+	return internal
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testAPIKey = "office-key-0123456789"
+
+func TestAPIKeyAndCORS(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		origins    []string
+		method     string
+		target     string
+		origin     string
+		key        string
+		status     int
+		code       string // 401 時的錯誤代碼
+		allowOrig  string // Access-Control-Allow-Origin
+		varyOrigin bool
+	}{
+		{"open mode", nil, nil, "GET", "/api/bookmarks", "", "", http.StatusOK, "", "*", false},
+		{"open mode ignores key", nil, nil, "GET", "/api/bookmarks", "", "anything", http.StatusOK, "", "*", false},
+		{"open mode any origin", nil, nil, "GET", "/api/bookmarks", "https://evil.example", "", http.StatusOK, "", "*", false},
+		{"missing key", []string{testAPIKey}, nil, "GET", "/api/bookmarks", "", "", http.StatusUnauthorized, "api_key_required", "*", false},
+		{"invalid key", []string{testAPIKey}, nil, "GET", "/api/bookmarks", "", "wrong-key-0123456789", http.StatusUnauthorized, "api_key_invalid", "*", false},
+		{"valid key", []string{testAPIKey}, nil, "GET", "/api/bookmarks", "", testAPIKey, http.StatusOK, "", "*", false},
+		{"query key only on stream", []string{testAPIKey}, nil, "GET", "/api/bookmarks?api_key=" + testAPIKey, "", "", http.StatusUnauthorized, "api_key_required", "*", false},
+		{"stream query key invalid", []string{testAPIKey}, nil, "GET", "/api/events/stream?api_key=wrong", "", "", http.StatusUnauthorized, "api_key_invalid", "*", false},
+		{"missing key on write", []string{testAPIKey}, nil, "DELETE", "/api/bookmarks?job_number=A001", "", "", http.StatusUnauthorized, "api_key_required", "*", false},
+		{"unknown route with key", []string{testAPIKey}, nil, "GET", "/api/unknown-route", "", testAPIKey, http.StatusNotFound, "", "*", false},
+		{"allowed origin", nil, []string{"https://office.example"}, "GET", "/api/bookmarks", "https://office.example", "", http.StatusOK, "", "https://office.example", true},
+		{"same origin", nil, []string{"https://office.example"}, "GET", "/api/bookmarks", "http://example.com", "", http.StatusOK, "", "http://example.com", true},
+		{"disallowed origin", nil, []string{"https://office.example"}, "GET", "/api/bookmarks", "https://evil.example", "", http.StatusOK, "", "", true},
+		{"no origin header", nil, []string{"https://office.example"}, "GET", "/api/bookmarks", "", "", http.StatusOK, "", "", true},
+		{"allowed origin missing key", []string{testAPIKey}, []string{"https://office.example"}, "GET", "/api/bookmarks", "https://office.example", "", http.StatusUnauthorized, "api_key_required", "https://office.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.cfg.APIKeys = tt.keys
+			s.cfg.AllowedOrigins = tt.origins
+			h := s.routes()
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.code != "" {
+				if resp := decodeBody(t, w); resp["error"] != tt.code {
+					t.Errorf("error = %v, want %s", resp["error"], tt.code)
+				}
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrig {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowOrig)
+			}
+			if got := w.Header().Get("Vary") == "Origin"; got != tt.varyOrigin {
+				t.Errorf("Vary = %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+// 預檢請求不帶金鑰也放行，並允許 X-API-Key 標頭
+func TestPreflightWithoutAPIKey(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.APIKeys = []string{testAPIKey}
+	s.cfg.AllowedOrigins = []string{"https://office.example"}
+	h := s.routes()
+
+	req := httptest.NewRequest("OPTIONS", "/api/bookmarks", nil)
+	req.Header.Set("Origin", "https://office.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-API-Key, Content-Type")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://office.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Accept-Language, X-API-Key, X-Requested-With" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+}
+
+func TestNormalizeAPIKeysAndOrigins(t *testing.T) {
+	keyTests := []struct {
+		in      []string
+		want    int
+		wantErr bool
+	}{
+		{nil, 0, false},
+		{[]string{" ", ""}, 0, false},
+		{[]string{testAPIKey, " " + testAPIKey + " "}, 1, false},
+		{[]string{"short"}, 0, true},
+	}
+	for _, tt := range keyTests {
+		got, err := normalizeAPIKeys(tt.in)
+		if (err != nil) != tt.wantErr || len(got) != tt.want {
+			t.Errorf("normalizeAPIKeys(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+
+	originTests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"https://office.example/", "https://office.example", false},
+		{"http://localhost:3000", "http://localhost:3000", false},
+		{"office.example", "", true},
+		{"https://office.example/app", "", true},
+		{"https://office.example?x=1", "", true},
+	}
+	for _, tt := range originTests {
+		got, err := normalizeOrigins([]string{tt.in})
+		if (err != nil) != tt.wantErr || (!tt.wantErr && (len(got) != 1 || got[0] != tt.want)) {
+			t.Errorf("normalizeOrigins(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 每批封存的書籤數，每批一個交易；中斷後重新執行會從剩下的書籤繼續
+const archiveBatchSize = 200
+
+// 封存表與 bookmarks 欄位相同，另記錄封存時間；id 沿用原本的值
+// bookmarks 新增欄位時封存表也必須一起新增
+// 事件保留在 events 表，以 entity_key 仍可查到封存書籤的完整歷程
+// 附件資料列以外鍵隨書籤刪除，封存前先移到 attachments_archive，檔案留在附件目錄，清理時不會刪除
+const archiveColumns = bookmarkColumns
+
+// 年底封存：將標案日期早於 before 的書籤移到 bookmarks_archive
+// POST /api/admin/archive-year?before=2026-01-01，未指定時為設定年度的 1 月 1 日
+// 封存不看狀態（見 status.go），仍在投標中的書籤也會封存；封存的書籤保留封存當時的狀態，狀態歷程不封存
+func (s *Server) archiveYear(w http.ResponseWriter, r *http.Request) {
+	cutoff := TenderDate(s.cfg.Year*10000 + 101)
+	if v := r.URL.Query().Get("before"); v != "" {
+		d, err := parseTenderDate(v)
+		if err != nil || d == 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "before")
+			return
+		}
+		cutoff = d
+	}
+
+	moved := make([]string, 0)
+	batches := 0
+	for {
+		var batch []string
+		err := s.db.withTx(r.Context(), func(tx *Tx) error {
+			// 沒有日期（0）的書籤與垃圾桶中的書籤不封存
+			rows, err := tx.Query("SELECT job_number FROM bookmarks WHERE date > 0 AND date < ? AND deleted_at IS NULL ORDER BY id LIMIT ?", cutoff, archiveBatchSize)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var jn string
+				if err := rows.Scan(&jn); err != nil {
+					rows.Close()
+					return err
+				}
+				batch = append(batch, jn)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil || len(batch) == 0 {
+				return err
+			}
+
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+			args := make([]interface{}, len(batch))
+			for i, jn := range batch {
+				args[i] = jn
+			}
+			// 封存表中已有同一 job_number（先前封存後又重新加入）時以新的資料取代
+			if _, err := tx.Exec("DELETE FROM bookmarks_archive WHERE job_number IN ("+placeholders+")", args...); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO bookmarks_archive (`+archiveColumns+`, archived_at)
+				SELECT `+archiveColumns+`, ? FROM bookmarks WHERE job_number IN (`+placeholders+`)
+			`, append([]interface{}{dbNow()}, args...)...); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM attachments_archive WHERE job_number IN ("+placeholders+")", args...); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO attachments_archive (`+attachmentColumns+`, archived_at)
+				SELECT `+attachmentColumns+`, ? FROM attachments WHERE job_number IN (`+placeholders+`)
+			`, append([]interface{}{dbNow()}, args...)...); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM bookmarks WHERE job_number IN ("+placeholders+")", args...); err != nil {
+				return err
+			}
+			// 類別與關鍵字不封存，讀取封存書籤時由 data 重新解析；標籤、狀態歷程與異動紀錄不封存
+			for _, table := range []string{"bookmark_categories", "bookmark_keywords", "bookmark_tags", "bookmark_status_history", "bookmark_changes"} {
+				if _, err := tx.Exec("DELETE FROM "+table+" WHERE job_number IN ("+placeholders+")", args...); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM bookmark_tags)"); err != nil {
+				return err
+			}
+			for _, jn := range batch {
+				if err := writeEvent(tx, requestActor(r), entityBookmark, jn, actionArchive, map[string]interface{}{"before": cutoff}); err != nil {
+					return err
+				}
+			}
+			return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("封存 %d 筆書籤（標案日期早於 %s）", len(batch), cutoff), batch...))
+		})
+		if err != nil {
+			log.Printf("封存中斷（已完成 %d 批）: %v", batches, err)
+			writeDBError(w, r, err)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+		batches++
+		moved = append(moved, batch...)
+		s.markChanged()
+	}
+
+	log.Printf("封存完成: %d 筆書籤（標案日期早於 %s）", len(moved), cutoff)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"before":      cutoff,
+		"moved":       len(moved),
+		"batches":     batches,
+		"job_numbers": moved,
+	})
+}
+
+// 讀取封存的書籤
+func (s *Server) archivedBookmarks(ctx context.Context) ([]Bookmark, int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+archiveColumns+" FROM bookmarks_archive ORDER BY priority DESC, created_at DESC")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	bookmarks := make([]Bookmark, 0)
+	warnings := 0
+	for rows.Next() {
+		b, err := scanBookmark(rows, s.db.cipher)
+		if err != nil {
+			log.Printf("讀取封存書籤失敗（id=%d）: %v", b.ID, err)
+			warnings++
+			continue
+		}
+		b.Archived = true
+		b.MatchedCategories, b.MatchedKeywords = bookmarkTerms(b.Data)
+		b.Tags = []string{}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, warnings, rows.Err()
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 附件
+//
+// 書籤的附件（下載的標案詳細資料、招標文件、使用者上傳的檔案）記錄在 attachments 表，
+// 下載的內容依 SHA-256 存於附件目錄的 ab/cd/abcd... 路徑，相同內容只存一份。
+// 使用者以 POST /api/bookmarks/attachments 上傳的檔案（報價單、規格書、內部筆記等）為 upload 種類，
+// 存於附件目錄的 <案號>/<檔名>（見 uploadPath），可直接在資料目錄中依書籤找到。
+// 每筆資料列的 stored_path 記錄檔案的相對路徑，兩種配置由同一個完整性檢查與清理比對。
+// attachments 以外鍵關聯 bookmarks，刪除書籤時資料列一併刪除；雜湊路徑的檔案可能被其他附件共用，
+// 不會立即刪除，由清理（prune）與完整性檢查找出沒有任何資料列參照的檔案後移除。
+// 所有寫入、讀取附件檔案的處理都必須經過這裡，不要直接 os.WriteFile。
+
+// 附件種類
+const (
+	attachmentTenderDetail = "tender_detail" // 下載的標案詳細資料（PCC API 回應）
+	attachmentDocument     = "document"      // 招標文件
+	attachmentUpload       = "upload"        // 使用者上傳
+)
+
+// 寫入中的暫存檔目錄，位於附件目錄內，完成後以 rename 移到雜湊路徑或上傳的路徑
+const attachmentTempDir = "tmp"
+
+// 剛寫入、尚未建立資料列的檔案在這段時間內不視為孤兒
+const attachmentGracePeriod = time.Hour
+
+// Attachment 附件資料列
+type Attachment struct {
+	ID          int64     `json:"id"`
+	JobNumber   string    `json:"job_number"`
+	Kind        string    `json:"kind"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	StoredPath  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const attachmentColumns = "id, job_number, kind, filename, content_type, size, sha256, stored_path, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.JobNumber, &a.Kind, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.StoredPath, scanTime(&a.CreatedAt))
+	a.CreatedAt = localTime(a.CreatedAt)
+	return a, err
+}
+
+// 附件目錄：設定 attachments_dir 時為 <attachments_dir>/<年度>，否則為年度資料目錄下的 attachments/
+// 每個年度資料庫各自一個目錄，孤兒檔案檢查才不會誤判其他年度的檔案
+func (s *Server) attachmentsDir() string {
+	if s.cfg.AttachmentsDir != "" {
+		return filepath.Join(s.cfg.AttachmentsDir, strconv.Itoa(s.cfg.Year))
+	}
+	return filepath.Join(yearDir(s.cfg.Year), "attachments")
+}
+
+// 依雜湊值產生相對路徑
+func attachmentPath(sum string) string {
+	return filepath.Join(sum[:2], sum[2:4], sum)
+}
+
+// 上傳附件的相對路徑 <案號>/<檔名>；案號以 escapeFileName 編碼，檔名須已經過 sanitizeFilename。
+// 案號編碼後與雜湊路徑的第一層（兩個十六進位字元）或暫存檔目錄同名時，第一個字元也編碼，
+// 大小寫不分的檔案系統上也不會與其他配置共用目錄
+func uploadPath(jobNumber, filename string) string {
+	dir := escapeFileName(jobNumber)
+	if isHashPrefix(dir) || strings.EqualFold(dir, attachmentTempDir) {
+		dir = fmt.Sprintf("%%%02X", dir[0]) + dir[1:]
+	}
+	return filepath.Join(dir, filename)
+}
+
+func isHashPrefix(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(name[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// 將內容寫入暫存檔目錄並計算雜湊值，呼叫端負責移走或刪除暫存檔
+func (s *Server) writeAttachmentTemp(r io.Reader) (tmpPath, sum string, size int64, err error) {
+	tmpDir := filepath.Join(s.attachmentsDir(), attachmentTempDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", "", 0, err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "upload-*")
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, err
+	}
+	return tmp.Name(), hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// 將暫存檔移到附件目錄中的相對路徑 rel，已有檔案時取代
+func (s *Server) placeAttachment(tmpPath, rel string) error {
+	target := filepath.Join(s.attachmentsDir(), rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, target)
+}
+
+// 寫入內容到雜湊路徑；已有相同內容的檔案時直接沿用
+func (s *Server) storeAttachmentContent(r io.Reader) (sum string, size int64, rel string, err error) {
+	tmpPath, sum, size, err := s.writeAttachmentTemp(r)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(tmpPath)
+
+	rel = attachmentPath(sum)
+	target := filepath.Join(s.attachmentsDir(), rel)
+	if info, err := os.Stat(target); err == nil && info.Size() == size {
+		// 更新修改時間，避免同時進行的孤兒清理刪掉即將被參照的檔案
+		now := time.Now()
+		os.Chtimes(target, now, now)
+		return sum, size, rel, nil
+	}
+	if err := s.placeAttachment(tmpPath, rel); err != nil {
+		return "", 0, "", err
+	}
+	return sum, size, rel, nil
+}
+
+// 寫入檔案到建立（或刪除）參照它的資料列之間持有讀鎖；刪除附件後檢查檔案是否仍被參照並刪除、
+// 以及清理刪除孤兒檔案時持有寫鎖，避免刪除的檔案剛好被另一個上傳寫入而尚未建立資料列
+var attachmentFiles sync.RWMutex
+
+// 儲存附件：同一書籤、種類與檔名已存在時以新內容取代
+func (s *Server) saveAttachment(ctx context.Context, jobNumber, kind, filename, contentType string, r io.Reader) (Attachment, error) {
+	attachmentFiles.RLock()
+	defer attachmentFiles.RUnlock()
+	sum, size, rel, err := s.storeAttachmentContent(r)
+	if err != nil {
+		return Attachment{}, err
+	}
+	var a Attachment
+	err = s.db.withTx(ctx, func(tx *Tx) error {
+		var err error
+		a, err = upsertAttachment(tx, jobNumber, kind, filename, contentType, sum, size, rel)
+		return err
+	})
+	return a, err
+}
+
+// 在交易中建立附件資料列，內容須已由 storeAttachmentContent 寫入
+func upsertAttachment(tx *Tx, jobNumber, kind, filename, contentType, sum string, size int64, rel string) (Attachment, error) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	return scanAttachment(tx.QueryRow(`
+		INSERT INTO attachments (job_number, kind, filename, content_type, size, sha256, stored_path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_number, kind, filename) DO UPDATE SET
+			content_type = excluded.content_type, size = excluded.size, sha256 = excluded.sha256,
+			stored_path = excluded.stored_path, created_at = excluded.created_at
+		RETURNING `+attachmentColumns,
+		jobNumber, kind, filename, contentType, size, sum, rel, dbNow()))
+}
+
+// 開啟附件內容
+func (s *Server) openAttachment(a Attachment) (*os.File, error) {
+	return os.Open(filepath.Join(s.attachmentsDir(), a.StoredPath))
+}
+
+// 將附件內容複製到附件目錄以外的位置（例如供其他工具讀取的 bookmarked_tenders/），
+// 先寫入暫存檔再 rename，讀取端不會看到寫到一半的檔案
+func (s *Server) publishAttachment(a Attachment, dest string) error {
+	src, err := s.openAttachment(a)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".publish-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// attachmentFileReport 資料列與磁碟檔案的比對結果
+type attachmentFileReport struct {
+	MissingIDs  []int64  // 檔案不存在或大小不符的附件
+	OrphanFiles []string // 沒有任何附件參照的檔案（相對路徑），不含寬限期內的檔案
+	OrphanBytes int64
+}
+
+// 比對 attachments（含 attachments_archive）與附件目錄中的檔案；雜湊路徑與上傳的 <案號>/<檔名> 都依 stored_path 比對，
+// 只略過最上層的暫存檔目錄（uploadPath 不會產生同名的案號目錄）
+func (s *Server) scanAttachmentFiles(ctx context.Context) (*attachmentFileReport, error) {
+	root := s.attachmentsDir()
+	referenced := map[string]bool{}
+	report := &attachmentFileReport{}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, stored_path, size FROM attachments ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, size int64
+		var rel string
+		if err := rows.Scan(&id, &rel, &size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		referenced[filepath.Clean(rel)] = true
+		if info, err := os.Stat(filepath.Join(root, rel)); err != nil || info.Size() != size {
+			report.MissingIDs = append(report.MissingIDs, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 封存書籤的附件仍算有參照（見 archive.go）
+	rows, err = s.db.QueryContext(ctx, "SELECT stored_path FROM attachments_archive")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var rel string
+		if err := rows.Scan(&rel); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		referenced[filepath.Clean(rel)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-attachmentGracePeriod)
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			if rel == attachmentTempDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if referenced[rel] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		report.OrphanFiles = append(report.OrphanFiles, rel)
+		report.OrphanBytes += info.Size()
+		return nil
+	})
+	return report, err
+}
+
+// 刪除孤兒檔案，回傳刪除的數量；比對後才寫入的檔案（例如重新加入書籤後上傳到同一路徑）不刪除。
+// 刪除後留下的空目錄（上傳的案號目錄、雜湊路徑的兩層目錄）一併移除
+func (s *Server) removeAttachmentFiles(rels []string) int {
+	attachmentFiles.Lock()
+	defer attachmentFiles.Unlock()
+	root := s.attachmentsDir()
+	cutoff := time.Now().Add(-attachmentGracePeriod)
+	removed := 0
+	for _, rel := range rels {
+		path := filepath.Join(root, rel)
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := s.removeAttachmentFile(rel); err != nil {
+			log.Printf("刪除附件檔案 %s 失敗: %v", rel, err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// 刪除附件目錄中的檔案，以及因此變成空的上層目錄；呼叫端須持有 attachmentFiles 的寫鎖
+func (s *Server) removeAttachmentFile(rel string) error {
+	root := s.attachmentsDir()
+	path := filepath.Join(root, rel)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	// 目錄不是空的時 Remove 失敗，停在該層
+	for dir := filepath.Dir(path); dir != root && os.Remove(dir) == nil; dir = filepath.Dir(dir) {
+	}
+	return nil
+}
+
+// GET /api/attachments?job_number= 列出書籤的附件
+// GET /api/attachments?id= 下載附件內容
+func (s *Server) attachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if v := r.URL.Query().Get("id"); v != "" {
+		s.downloadAttachment(w, r)
+		return
+	}
+	s.listAttachments(w, r)
+}
+
+// 依 ?id= 取得附件，參數錯誤或不存在時已回應
+func (s *Server) attachmentParam(w http.ResponseWriter, r *http.Request) (Attachment, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "id")
+		return Attachment{}, false
+	}
+	a, err := scanAttachment(s.db.QueryRowContext(r.Context(), "SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "attachment_not_found", id)
+		return Attachment{}, false
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return Attachment{}, false
+	}
+	return a, true
+}
+
+// GET /api/bookmarks/attachments/download?id= 下載附件內容，以附件的 Content-Type 回應
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.attachmentParam(w, r)
+	if !ok {
+		return
+	}
+	f, err := s.openAttachment(a)
+	if err != nil {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+	defer f.Close()
+	if a.ContentType != "" {
+		w.Header().Set("Content-Type", a.ContentType)
+	}
+	// 上傳的內容由使用者提供，一律以下載處理，不讓瀏覽器猜測類型
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", contentDisposition(a.Filename))
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, "", a.CreatedAt, f)
+}
+
+// GET /api/bookmarks/attachments?job_number= 列出書籤的附件；書籤不存在時回傳 404
+func (s *Server) listAttachments(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
+		return
+	}
+	var exists int
+	if err := s.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL", jobNumber).Scan(&exists); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if exists == 0 {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+attachmentColumns+" FROM attachments WHERE job_number = ? ORDER BY id", jobNumber)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]Attachment, 0)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		items = append(items, a)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// 上傳檔名最長的位元組數
+const maxAttachmentFilename = 200
+
+// 只保留上傳檔名的最後一段（同時處理 / 與 \），去除控制字元；
+// 結果為空或為 . 與 .. 時回傳空字串。結果用於 uploadPath 組成路徑，不會離開案號目錄
+func sanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) || c == utf8.RuneError {
+			return -1
+		}
+		return c
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > maxAttachmentFilename {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// 檔案以外，multipart 的邊界、標頭與 job_number 欄位另外允許的位元組數
+const uploadOverhead = 64 << 10
+
+// POST /api/bookmarks/attachments 上傳附件（multipart/form-data，欄位 job_number 與 file），存於附件目錄的 <案號>/<檔名>
+// 同一書籤已有相同檔名的上傳附件時以新內容取代；檔案超過 upload_max_bytes 時回傳 413
+func (s *Server) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.UploadMaxBytes+uploadOverhead)
+	// 超過 1 MiB 的部分由 net/http 寫入暫存檔，處理結束後刪除
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, "upload_too_large", s.cfg.UploadMaxBytes)
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "invalid_upload", err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	jobNumber, ok := jobNumberParam(w, r, r.FormValue("job_number"))
+	if !ok {
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_upload", "file")
+		return
+	}
+	defer file.Close()
+	if header.Size > s.cfg.UploadMaxBytes {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "upload_too_large", s.cfg.UploadMaxBytes)
+		return
+	}
+	filename := sanitizeFilename(header.Filename)
+	if filename == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "filename")
+		return
+	}
+	contentType := header.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType == "application/octet-stream" {
+		// 未提供或無法辨識時依副檔名判斷
+		contentType = ""
+	}
+
+	attachmentFiles.RLock()
+	defer attachmentFiles.RUnlock()
+	tmpPath, sum, size, err := s.writeAttachmentTemp(file)
+	if err != nil {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+	defer os.Remove(tmpPath)
+	rel := uploadPath(jobNumber, filename)
+	var a Attachment
+	var placeErr error
+	err = s.db.withTx(r.Context(), func(tx *Tx) error {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL", jobNumber).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errBookmarkNotFound
+		}
+		if a, err = upsertAttachment(tx, jobNumber, attachmentUpload, filename, contentType, sum, size, rel); err != nil {
+			return err
+		}
+		if err := writeAudit(tx, newAuditEntry(r, "上傳附件 "+filename, jobNumber)); err != nil {
+			return err
+		}
+		// 書籤確定存在才移到案號目錄，取代同名的舊檔案；移動失敗時資料列一併復原
+		placeErr = s.placeAttachment(tmpPath, rel)
+		return placeErr
+	})
+	if errors.Is(err, errBookmarkNotFound) {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
+	if placeErr != nil {
+		writeInternalError(w, r, "file_error", placeErr)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeSuccess(w, r, http.StatusCreated, "attachment_uploaded", map[string]interface{}{"attachment": a})
+}
+
+// DELETE /api/bookmarks/attachments?id= 刪除附件；檔案沒有其他附件共用時一併刪除（上傳的檔案只屬於這個附件）
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.attachmentParam(w, r)
+	if !ok {
+		return
+	}
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		result, err := tx.Exec("DELETE FROM attachments WHERE id = ?", a.ID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return writeAudit(tx, newAuditEntry(r, "刪除附件 "+a.Filename, a.JobNumber))
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "attachment_not_found", a.ID)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	attachmentFiles.Lock()
+	defer attachmentFiles.Unlock()
+	var refs int
+	if err := s.db.QueryRowContext(r.Context(), "SELECT (SELECT COUNT(*) FROM attachments WHERE stored_path = ?) + (SELECT COUNT(*) FROM attachments_archive WHERE stored_path = ?)", a.StoredPath, a.StoredPath).Scan(&refs); err != nil {
+		// 資料列已刪除，檔案留給孤兒清理
+		log.Printf("檢查附件檔案 %s 的參照失敗: %v", a.StoredPath, err)
+	} else if refs == 0 {
+		if err := s.removeAttachmentFile(a.StoredPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("刪除附件檔案 %s 失敗: %v", a.StoredPath, err)
+		}
+	}
+	writeSuccess(w, r, http.StatusOK, "attachment_deleted", map[string]interface{}{"deleted": 1})
+}
+
+// /api/bookmarks/attachments：GET 列出、POST 上傳、DELETE 刪除
+func (s *Server) bookmarkAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		s.uploadAttachment(w, r)
+	case "DELETE":
+		s.deleteAttachment(w, r)
+	default:
+		s.listAttachments(w, r)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 以 multipart/form-data 上傳附件；contentType 為空時不帶檔案的 Content-Type
+func uploadTestAttachment(t *testing.T, h http.Handler, jobNumber, filename, contentType string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("job_number", jobNumber)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/bookmarks/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// 上傳成功時回傳的附件
+func uploadedAttachment(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("上傳 status = %d: %s", w.Code, w.Body.String())
+	}
+	a, _ := decodeBody(t, w)["attachment"].(map[string]interface{})
+	if a == nil {
+		t.Fatalf("回應缺少 attachment: %s", w.Body.String())
+	}
+	return a
+}
+
+func TestUploadAttachmentValidation(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程"}`)
+
+	tests := []struct {
+		name      string
+		jobNumber string
+		filename  string
+		content   []byte
+		status    int
+		code      string
+	}{
+		{"ok", "A001", "報價單.xlsx", []byte("報價"), http.StatusCreated, "attachment_uploaded"},
+		{"at limit", "A001", "max.bin", bytes.Repeat([]byte("x"), int(s.cfg.UploadMaxBytes)), http.StatusCreated, "attachment_uploaded"},
+		{"too large", "A001", "big.bin", bytes.Repeat([]byte("x"), int(s.cfg.UploadMaxBytes)+1), http.StatusRequestEntityTooLarge, "upload_too_large"},
+		{"far too large", "A001", "huge.bin", bytes.Repeat([]byte("x"), int(s.cfg.UploadMaxBytes)+2*uploadOverhead), http.StatusRequestEntityTooLarge, "upload_too_large"},
+		{"missing bookmark", "Z999", "a.pdf", []byte("x"), http.StatusNotFound, "not_found"},
+		{"invalid job number", "A<1>", "a.pdf", []byte("x"), http.StatusBadRequest, "invalid_job_number"},
+		{"empty filename", "A001", "../", []byte("x"), http.StatusBadRequest, "invalid_parameter"},
+		{"dot dot filename", "A001", "..", []byte("x"), http.StatusBadRequest, "invalid_parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := uploadTestAttachment(t, h, tt.jobNumber, tt.filename, "", tt.content)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			resp := decodeBody(t, w)
+			got := resp["error"]
+			if w.Code == http.StatusCreated {
+				got = resp["code"]
+			}
+			if got != tt.code {
+				t.Errorf("code = %v, want %s: %v", got, tt.code, resp)
+			}
+		})
+	}
+}
+
+func TestUploadAttachmentContentType(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程"}`)
+
+	tests := []struct {
+		filename    string
+		contentType string // 上傳時帶的 Content-Type
+		want        string // 記錄的 Content-Type 前綴
+	}{
+		{"規格書.pdf", "application/pdf", "application/pdf"},
+		{"規格書2.pdf", "application/octet-stream", "application/pdf"},
+		{"筆記.txt", "", "text/plain"},
+		{"圖面.png", "not a type", "image/png"},
+		{"未知", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			a := uploadedAttachment(t, uploadTestAttachment(t, h, "A001", tt.filename, tt.contentType, []byte(tt.filename)))
+			ct, _ := a["content_type"].(string)
+			if !strings.HasPrefix(ct, tt.want) || (tt.want == "" && ct != "") {
+				t.Errorf("content_type = %q, want %q", ct, tt.want)
+			}
+
+			// 下載一律以附件處理，不讓瀏覽器猜測類型
+			w := serve(t, h, "GET", fmt.Sprintf("/api/bookmarks/attachments/download?id=%v", a["id"]), "")
+			if w.Code != http.StatusOK || w.Body.String() != tt.filename {
+				t.Fatalf("下載 status = %d body = %q", w.Code, w.Body.String())
+			}
+			if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+				t.Error("缺少 X-Content-Type-Options: nosniff")
+			}
+			if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+				t.Errorf("Content-Disposition = %q", cd)
+			}
+			if tt.want != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), tt.want) {
+				t.Errorf("下載 Content-Type = %q, want %q", w.Header().Get("Content-Type"), tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"報價單.xlsx", "報價單.xlsx"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\windows\win.ini`, "win.ini"},
+		{"/abs/path/spec.pdf", "spec.pdf"},
+		{"a\x00b\nc.txt", "abc.txt"},
+		{"  空白.txt  ", "空白.txt"},
+		{"..", ""},
+		{".", ""},
+		{"dir/", ""},
+		{strings.Repeat("長", 100) + ".pdf", strings.Repeat("長", 66)},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.in); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 檔名含路徑時只記錄最後一段，檔案存於附件目錄的 <案號>/<檔名>，不會寫到目錄之外
+func TestUploadAttachmentPathTraversal(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程"}`)
+
+	a := uploadedAttachment(t, uploadTestAttachment(t, h, "A001", "../../../escape.txt", "text/plain", []byte("內容")))
+	if a["filename"] != "escape.txt" {
+		t.Errorf("filename = %v, want escape.txt", a["filename"])
+	}
+	if _, err := os.Stat(filepath.Join(dataRoot, "escape.txt")); err == nil {
+		t.Error("檔案寫到了附件目錄之外")
+	}
+	if content, err := os.ReadFile(filepath.Join(s.attachmentsDir(), "A001", "escape.txt")); err != nil || string(content) != "內容" {
+		t.Errorf("案號目錄中的檔案 = %q, %v", content, err)
+	}
+}
+
+// 案號編碼後不會與雜湊路徑的第一層或暫存檔目錄同名
+func TestUploadPath(t *testing.T) {
+	tests := []struct {
+		jobNumber string
+		want      string
+	}{
+		{"A001", "A001/規格書.pdf"},
+		{"A/01", "A%2F01/規格書.pdf"},
+		{".A01", "%2EA01/規格書.pdf"},
+		{"ab", "%61b/規格書.pdf"},
+		{"1F", "%31F/規格書.pdf"},
+		{"abc", "abc/規格書.pdf"},
+		{"tmp", "%74mp/規格書.pdf"},
+		{"TMP", "%54MP/規格書.pdf"},
+	}
+	for _, tt := range tests {
+		if got := filepath.ToSlash(uploadPath(tt.jobNumber, "規格書.pdf")); got != tt.want {
+			t.Errorf("uploadPath(%q) = %q, want %q", tt.jobNumber, got, tt.want)
+		}
+	}
+}
+
+// 上傳的檔案存於各書籤的案號目錄，內容相同也各存一份；刪除附件時檔案與空的案號目錄一併刪除
+func TestAttachmentUploadAndDelete(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"橋梁維修工程"}`)
+
+	content := []byte("相同的規格書內容")
+	sum := sha256.Sum256(content)
+	firstPath := filepath.Join(s.attachmentsDir(), "A001", "規格書.pdf")
+	secondPath := filepath.Join(s.attachmentsDir(), "A002", "spec.pdf")
+
+	first := uploadedAttachment(t, uploadTestAttachment(t, h, "A001", "規格書.pdf", "", content))
+	second := uploadedAttachment(t, uploadTestAttachment(t, h, "A002", "spec.pdf", "", content))
+	if first["sha256"] != hex.EncodeToString(sum[:]) || first["sha256"] != second["sha256"] {
+		t.Fatalf("sha256 = %v / %v", first["sha256"], second["sha256"])
+	}
+	if first["size"] != float64(len(content)) {
+		t.Errorf("size = %v, want %d", first["size"], len(content))
+	}
+	for _, p := range []string{firstPath, secondPath} {
+		if got, err := os.ReadFile(p); err != nil || !bytes.Equal(got, content) {
+			t.Errorf("%s = %q, %v", p, got, err)
+		}
+	}
+
+	// 同一書籤上傳相同檔名時取代，不新增資料列
+	replaced := uploadedAttachment(t, uploadTestAttachment(t, h, "A001", "規格書.pdf", "", []byte("新版")))
+	if replaced["id"] != first["id"] {
+		t.Errorf("取代後 id = %v, want %v", replaced["id"], first["id"])
+	}
+	list := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/attachments?job_number=A001", ""))
+	if items, _ := list["items"].([]interface{}); len(items) != 1 {
+		t.Fatalf("A001 附件 = %v", list)
+	}
+	if got, _ := os.ReadFile(firstPath); string(got) != "新版" {
+		t.Errorf("取代後的檔案 = %q", got)
+	}
+
+	if w := serve(t, h, "DELETE", fmt.Sprintf("/api/bookmarks/attachments?id=%v", second["id"]), ""); w.Code != http.StatusOK {
+		t.Fatalf("刪除 status = %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Dir(secondPath)); !os.IsNotExist(err) {
+		t.Errorf("刪除後檔案或案號目錄仍存在: %v", err)
+	}
+	if _, err := os.Stat(firstPath); err != nil {
+		t.Errorf("其他書籤的檔案被刪除: %v", err)
+	}
+	if w := serve(t, h, "DELETE", fmt.Sprintf("/api/bookmarks/attachments?id=%v", second["id"]), ""); w.Code != http.StatusNotFound {
+		t.Errorf("重複刪除 status = %d, want 404", w.Code)
+	}
+	if w := serve(t, h, "GET", fmt.Sprintf("/api/bookmarks/attachments/download?id=%v", second["id"]), ""); w.Code != http.StatusNotFound {
+		t.Errorf("下載已刪除的附件 status = %d, want 404", w.Code)
+	}
+}
+
+func TestListAttachmentsMissingBookmark(t *testing.T) {
+	h := newTestServer(t).routes()
+	tests := []struct {
+		target string
+		status int
+		code   string
+	}{
+		{"/api/bookmarks/attachments?job_number=Z999", http.StatusNotFound, "not_found"},
+		{"/api/bookmarks/attachments?job_number=", http.StatusBadRequest, "missing_job_number"},
+		{"/api/bookmarks/attachments/download?id=abc", http.StatusBadRequest, "invalid_parameter"},
+		{"/api/bookmarks/attachments/download?id=42", http.StatusNotFound, "attachment_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if resp := decodeBody(t, w); resp["error"] != tt.code {
+				t.Errorf("error = %v, want %s", resp["error"], tt.code)
+			}
+		})
+	}
+}
+
+// 封存的書籤仍算存在：附件資料列移到 attachments_archive，清理與完整性修復都不會刪除資料列或檔案
+func TestPruneKeepsArchivedAttachments(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"去年的標案","date":"2025-01-15"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"今年的標案","date":"2026-03-01"}`)
+	a := uploadedAttachment(t, uploadTestAttachment(t, h, "A001", "規格書.pdf", "", []byte("封存的附件")))
+
+	// 檔案超過寬限期，沒有參照時清理會刪除；雜湊路徑與已刪除書籤的案號目錄都比對
+	old := time.Now().Add(-2 * attachmentGracePeriod)
+	rel := uploadPath("A001", "規格書.pdf")
+	if a["filename"] != "規格書.pdf" {
+		t.Fatalf("attachment = %v", a)
+	}
+	path := filepath.Join(s.attachmentsDir(), rel)
+	orphans := []string{
+		filepath.Join(s.attachmentsDir(), attachmentPath(strings.Repeat("0", 64))),
+		filepath.Join(s.attachmentsDir(), uploadPath("Z999", "舊報價單.xlsx")),
+	}
+	for _, p := range orphans {
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("沒有參照"), 0644)
+	}
+	for _, p := range append([]string{path}, orphans...) {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := serve(t, h, "POST", "/api/admin/archive-year?before=2026-01-01", ""); w.Code != http.StatusOK {
+		t.Fatalf("封存 status = %d: %s", w.Code, w.Body.String())
+	}
+	// 子表中封存書籤的資料列同樣保留，對應不到任何書籤的才刪除
+	for _, jn := range []string{"A001", "Z999"} {
+		if _, err := s.db.ExecContext(context.Background(), "INSERT INTO bookmark_changes (job_number, field, detected_at) VALUES (?, 'title', ?)", jn, dbNow()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"prune", func(t *testing.T) {
+			if _, err := s.prune(context.Background(), false); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"integrity fix", func(t *testing.T) {
+			if w := serve(t, h, "POST", "/api/admin/integrity?fix=safe", ""); w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t)
+			var rows, changes int
+			s.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM attachments_archive WHERE job_number = 'A001' AND stored_path = ?", rel).Scan(&rows)
+			if rows != 1 {
+				t.Errorf("封存書籤的附件資料列 %d 筆, want 1", rows)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("封存書籤的附件檔案被刪除: %v", err)
+			}
+			for _, p := range orphans {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("沒有參照的檔案 %s 未刪除: %v", p, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(s.attachmentsDir(), "Z999")); !os.IsNotExist(err) {
+				t.Errorf("空的案號目錄未刪除: %v", err)
+			}
+			s.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM bookmark_changes WHERE job_number = 'A001'").Scan(&changes)
+			if changes != 1 {
+				t.Errorf("封存書籤的異動紀錄 %d 筆, want 1", changes)
+			}
+			s.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM bookmark_changes WHERE job_number = 'Z999'").Scan(&changes)
+			if changes != 0 {
+				t.Errorf("孤兒異動紀錄 %d 筆, want 0", changes)
+			}
+		})
+	}
+}
+
+// 比對後才重新寫入的檔案（例如重新加入書籤後上傳到同一路徑）不會被當成孤兒刪除
+func TestRemoveAttachmentFilesSkipsRewritten(t *testing.T) {
+	s := newTestServer(t)
+	old := time.Now().Add(-2 * attachmentGracePeriod)
+	var rels []string
+	for _, jn := range []string{"A001", "A002"} {
+		rel := uploadPath(jn, "報價單.xlsx")
+		path := filepath.Join(s.attachmentsDir(), rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("舊內容"), 0644)
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		rels = append(rels, rel)
+	}
+	files, err := s.scanAttachmentFiles(context.Background())
+	if err != nil || len(files.OrphanFiles) != 2 {
+		t.Fatalf("orphans = %v, %v", files, err)
+	}
+
+	// 比對後 A002 的檔案被新的上傳取代
+	os.WriteFile(filepath.Join(s.attachmentsDir(), rels[1]), []byte("新內容"), 0644)
+	if n := s.removeAttachmentFiles(files.OrphanFiles); n != 1 {
+		t.Errorf("刪除 %d 個檔案, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(s.attachmentsDir(), rels[0])); !os.IsNotExist(err) {
+		t.Errorf("孤兒檔案未刪除: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(s.attachmentsDir(), rels[1])); string(got) != "新內容" {
+		t.Errorf("重新寫入的檔案 = %q", got)
+	}
+}
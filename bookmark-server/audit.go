@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookmark-server/store"
+)
+
+// AuditEntry 稽核紀錄
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Actor      string    `json:"actor"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	JobNumbers []string  `json:"job_numbers"`
+	Summary    string    `json:"summary"`
+}
+
+// 稽核紀錄表（只新增、不修改）
+const auditSchemaSQL = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		actor TEXT NOT NULL DEFAULT '',
+		remote_addr TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		job_numbers TEXT NOT NULL DEFAULT '',
+		summary TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_created_at ON audit_log(created_at);
+`
+
+// 背景工作使用的操作者名稱
+const systemActor = "system"
+
+// 取得請求的操作者（已驗證的使用者或 API 金鑰 ID），未驗證時為 anonymous
+func requestActor(r *http.Request) string {
+	return "anonymous"
+}
+
+// 由請求建立稽核紀錄
+func newAuditEntry(r *http.Request, summary string, jobNumbers ...string) AuditEntry {
+	return AuditEntry{
+		Actor:      requestActor(r),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		JobNumbers: jobNumbers,
+		Summary:    summary,
+	}
+}
+
+// 寫入稽核紀錄，必須與資料異動在同一個交易中
+func writeAudit(tx *Tx, e AuditEntry) error {
+	_, err := tx.Exec(`
+		INSERT INTO audit_log (created_at, actor, remote_addr, method, path, job_numbers, summary)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, dbNow(), e.Actor, e.RemoteAddr, e.Method, e.Path, strings.Join(e.JobNumbers, ","), e.Summary)
+	return err
+}
+
+// 解析時間參數，接受 RFC3339 或 YYYY-MM-DD（顯示時區當天 00:00），轉成資料庫格式
+func parseAuditTime(value string) (string, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return dbTime(t), true
+	}
+	if t, err := startOfDay(value); err == nil {
+		return dbTime(t), true
+	}
+	return "", false
+}
+
+// 查詢稽核紀錄，支援 from/to 時間範圍、job_number 篩選與分頁
+func (s *Server) getAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var where []string
+	var args []interface{}
+	for _, p := range []struct{ name, op string }{{"from", ">="}, {"to", "<"}} {
+		value := q.Get(p.name)
+		if value == "" {
+			continue
+		}
+		ts, ok := parseAuditTime(value)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", p.name)
+			return
+		}
+		where = append(where, "created_at "+p.op+" ?")
+		args = append(args, ts)
+	}
+	if jobNumber := canonicalJobNumber(q.Get("job_number")); jobNumber != "" {
+		// 案號可含 _，以跳脫後的樣式照字面比對整個案號
+		where = append(where, `(',' || job_numbers || ',') LIKE ? ESCAPE '\'`)
+		args = append(args, "%,"+store.LikeEscaper.Replace(jobNumber)+",%")
+	}
+
+	page, pageSize := 1, 50
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page")
+			return
+		}
+		page = n
+	}
+	if v := q.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page_size")
+			return
+		}
+		pageSize = n
+	}
+
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM audit_log"+whereSQL, args...).Scan(&total); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, created_at, actor, remote_addr, method, path, job_numbers, summary
+		FROM audit_log`+whereSQL+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var e AuditEntry
+		var jobNumbers string
+		if err := rows.Scan(&e.ID, scanTime(&e.CreatedAt), &e.Actor, &e.RemoteAddr, &e.Method, &e.Path, &jobNumbers, &e.Summary); err != nil {
+			continue
+		}
+		e.CreatedAt = localTime(e.CreatedAt)
+		e.JobNumbers = []string{}
+		if jobNumbers != "" {
+			e.JobNumbers = strings.Split(jobNumbers, ",")
+		}
+		entries = append(entries, e)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"items":     entries,
+	})
+}
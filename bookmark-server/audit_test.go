@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+// 以案號篩選稽核紀錄時 _ 照字面比對，全形案號轉成標準形式
+func TestAuditJobNumberFilter(t *testing.T) {
+	h := newTestServer(t).routes()
+	for _, jn := range []string{"A_01", "AX01", "B_01"} {
+		addTestBookmark(t, h, `{"job_number":"`+jn+`"}`)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"A_01", []string{"A_01"}},
+		{"Ａ＿01", []string{"A_01"}},
+		{"AX01", []string{"AX01"}},
+		{"%", nil},
+	} {
+		resp := decodeBody(t, serve(t, h, "GET", "/api/admin/audit?job_number="+url.QueryEscape(tt.query), ""))
+		items, _ := resp["items"].([]interface{})
+		var got []string
+		for _, item := range items {
+			for _, jn := range item.(map[string]interface{})["job_numbers"].([]interface{}) {
+				got = append(got, jn.(string))
+			}
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("job_number=%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
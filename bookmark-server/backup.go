@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 備份檔名格式
+const (
+	backupPrefix     = "bookmarks_"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102_150405"
+)
+
+// BackupFile 備份檔資訊
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 最近一次備份的結果，供健康檢查回報
+type backupState struct {
+	sync.Mutex
+	LastSuccess time.Time
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// 備份目錄，未設定時為資料庫旁的 backups/
+func (s *Server) backupDir() string {
+	if s.cfg.BackupDir != "" {
+		return s.cfg.BackupDir
+	}
+	return filepath.Join(filepath.Dir(s.dbPath), "backups")
+}
+
+// 列出備份檔，新的在前
+func (s *Server) listBackups() ([]BackupFile, error) {
+	entries, err := os.ReadDir(s.backupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []BackupFile{}, nil
+		}
+		return nil, err
+	}
+	backups := make([]BackupFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		ts, err := time.ParseInLocation(backupTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix), time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{Name: name, Size: info.Size(), CreatedAt: ts})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// 以 VACUUM INTO 建立備份並依數量與總大小輪替
+func (s *Server) runBackup(ctx context.Context) (BackupFile, error) {
+	backup, err := s.createBackup(ctx)
+
+	s.backup.Lock()
+	if err != nil {
+		s.backup.LastError = err.Error()
+		s.backup.LastErrorAt = time.Now()
+	} else {
+		s.backup.LastSuccess = backup.CreatedAt
+		s.backup.LastError = ""
+	}
+	s.backup.Unlock()
+
+	if err != nil {
+		log.Printf("!!! 資料庫備份失敗: %v", err)
+	}
+	return backup, err
+}
+
+func (s *Server) createBackup(ctx context.Context) (BackupFile, error) {
+	if s.db.dialect != dialectSQLite {
+		return BackupFile{}, fmt.Errorf("僅支援 SQLite 備份")
+	}
+	dir := s.backupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return BackupFile{}, err
+	}
+
+	now := time.Now()
+	name := backupPrefix + now.Format(backupTimeLayout) + backupSuffix
+	target := filepath.Join(dir, name)
+	// 同一秒內重複備份時不可覆寫或刪除既有的備份檔
+	if _, err := os.Stat(target); err == nil {
+		return BackupFile{}, fmt.Errorf("備份檔已存在: %s", name)
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", target); err != nil {
+		os.Remove(target)
+		return BackupFile{}, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	log.Printf("已建立資料庫備份: %s（%d bytes）", target, info.Size())
+
+	if err := s.rotateBackups(); err != nil {
+		log.Println("備份輪替失敗:", err)
+	}
+	return BackupFile{Name: name, Size: info.Size(), CreatedAt: now.Truncate(time.Second)}, nil
+}
+
+// 保留最新的 BackupKeep 份，且總大小不超過 BackupMaxBytes（至少保留最新一份）
+func (s *Server) rotateBackups() error {
+	backups, err := s.listBackups()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for i, b := range backups {
+		total += b.Size
+		overCount := s.cfg.BackupKeep > 0 && i >= s.cfg.BackupKeep
+		overSize := s.cfg.BackupMaxBytes > 0 && total > s.cfg.BackupMaxBytes && i > 0
+		if overCount || overSize {
+			if err := os.Remove(filepath.Join(s.backupDir(), b.Name)); err != nil {
+				return err
+			}
+			log.Println("已刪除舊備份:", b.Name)
+		}
+	}
+	return nil
+}
+
+// 啟動時檢查最新備份是否過舊
+func (s *Server) checkBackupFreshness() {
+	if s.cfg.BackupInterval.Duration <= 0 || s.db.dialect != dialectSQLite {
+		return
+	}
+	backups, err := s.listBackups()
+	if err != nil {
+		log.Println("警告: 無法讀取備份目錄:", err)
+		return
+	}
+	if len(backups) == 0 {
+		log.Println("警告: 尚未有任何資料庫備份")
+		return
+	}
+	if age := time.Since(backups[0].CreatedAt); age > s.cfg.BackupInterval.Duration {
+		log.Printf("警告: 最新備份 %s 已超過 %s（設定間隔 %s）", backups[0].Name, age.Round(time.Minute), s.cfg.BackupInterval.Duration)
+	}
+}
+
+// 備份狀態，供健康檢查使用
+func (s *Server) backupHealth() map[string]interface{} {
+	s.backup.Lock()
+	defer s.backup.Unlock()
+	status := map[string]interface{}{
+		"ok":           s.backup.LastError == "",
+		"last_success": nil,
+		"last_error":   nil,
+	}
+	if !s.backup.LastSuccess.IsZero() {
+		status["last_success"] = s.backup.LastSuccess
+	}
+	if s.backup.LastError != "" {
+		status["last_error"] = s.backup.LastError
+		status["last_error_at"] = s.backup.LastErrorAt
+	}
+	return status
+}
+
+// GET 列出備份，GET ?name= 下載指定備份，POST 立即建立備份
+func (s *Server) backupsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		backup, err := s.runBackup(r.Context())
+		if err != nil {
+			writeInternalError(w, r, "backup_failed", err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, backup)
+	case "GET", "HEAD":
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			backups, err := s.listBackups()
+			if err != nil {
+				writeInternalError(w, r, "file_error", err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"dir": s.backupDir(), "backups": backups})
+			return
+		}
+		// 只接受列表中的檔名，避免路徑穿越
+		if filepath.Base(name) != name || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "name")
+			return
+		}
+		path := filepath.Join(s.backupDir(), name)
+		if _, err := os.Stat(path); err != nil {
+			writeError(w, r, http.StatusNotFound, "backup_not_found", name)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", contentDisposition(name))
+		http.ServeFile(w, r, path)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 批次新增與刪除書籤
+//
+// POST /api/bookmarks/bulk 接受與 POST /api/bookmarks 相同內容的 JSON 陣列，
+// DELETE /api/bookmarks/bulk 接受 {"job_numbers": [...]}。每批最多 maxBulkItems 筆，超過時回傳 413。
+// 所有寫入在同一筆交易中完成；格式錯誤的項目列在結果中並略過，其餘照常寫入，
+// 加上 ?atomic=true 時只要有一筆格式錯誤就全部不寫入並回傳 422。資料庫錯誤時一律全部不寫入。
+
+const (
+	maxBulkItems         = 500
+	bookmarkBulkMaxBytes = 32 << 20
+)
+
+// BulkResult 批次新增中一筆的結果；批次刪除時只列出格式錯誤的案號
+type BulkResult struct {
+	Index     int    `json:"index"` // 在陣列中的位置（從 0 開始）
+	JobNumber string `json:"job_number,omitempty"`
+	Success   bool   `json:"success"`
+	Created   bool   `json:"created,omitempty"` // 新增為 true，已存在而更新為 false
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// 讀取 ?atomic=
+func atomicParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	switch r.URL.Query().Get("atomic") {
+	case "", "false", "0":
+		return false, true
+	case "true", "1":
+		return true, true
+	}
+	writeError(w, r, http.StatusBadRequest, "invalid_parameter", "atomic")
+	return false, false
+}
+
+// 解析一筆批次新增的項目，格式與 POST /api/bookmarks 的請求內容相同
+func decodeBulkItem(raw json.RawMessage) (*bookmarkInput, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return &bookmarkInput{}, &bodyError{Reason: "必須是 JSON 物件"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var input bookmarkInput
+	if err := dec.Decode(&input); err != nil {
+		return &input, translateDecodeError(err, dec.InputOffset())
+	}
+	return &input, nil
+}
+
+// POST /api/bookmarks/bulk[?atomic=true]
+func (s *Server) bulkAddBookmarks(w http.ResponseWriter, r *http.Request) {
+	atomic, ok := atomicParam(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, bookmarkBulkMaxBytes)
+	var raw json.RawMessage
+	if err := decodeJSONBody(r, &raw); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		writeDecodeError(w, r, &bodyError{Reason: "必須是書籤陣列"})
+		return
+	}
+	if len(raws) > maxBulkItems {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "bulk_too_large", len(raws), maxBulkItems)
+		return
+	}
+
+	// 先驗證所有的項目，同一個案號出現多次時只寫入第一筆
+	lang := requestLanguage(r)
+	results := make([]BulkResult, len(raws))
+	inputs := make([]*bookmarkInput, len(raws))
+	failed := 0
+	seen := map[string]int{}
+	for i, raw := range raws {
+		results[i].Index = i
+		input, err := decodeBulkItem(raw)
+		code, args := "", []interface{}(nil)
+		if err != nil {
+			code, args = "invalid_json", []interface{}{err.Error()}
+		} else if _, code, args = s.prepareBookmarkInput(r.Context(), input); code == "" {
+			if first, dup := seen[input.JobNumber]; dup {
+				code, args = "bulk_duplicate_job_number", []interface{}{first}
+			}
+		}
+		results[i].JobNumber = input.JobNumber
+		if code != "" {
+			results[i].Error, results[i].Message = code, localize(lang, code, args...)
+			failed++
+			continue
+		}
+		seen[input.JobNumber] = i
+		inputs[i] = input
+	}
+	if atomic && failed > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"success": false,
+			"code":    "bulk_rejected",
+			"message": localize(lang, "bulk_rejected", failed),
+			"total":   len(raws),
+			"failed":  failed,
+			"results": results,
+		})
+		return
+	}
+
+	created, updated := 0, 0
+	var changed []string
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		for i, input := range inputs {
+			if input == nil {
+				continue
+			}
+			_, isNew, _, err := s.saveBookmarkInput(tx, r, input)
+			if err != nil {
+				return err
+			}
+			results[i].Success, results[i].Created = true, isNew
+			if isNew {
+				created++
+			} else {
+				updated++
+			}
+			changed = append(changed, input.JobNumber)
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("批次新增書籤：新增 %d、更新 %d、格式錯誤 %d", created, updated, failed), changed...))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(changed) > 0 {
+		s.markChanged()
+	}
+
+	writeSuccess(w, r, http.StatusOK, "bookmarks_bulk_added", map[string]interface{}{
+		"total":   len(raws),
+		"created": created,
+		"updated": updated,
+		"failed":  failed,
+		"results": results,
+	})
+}
+
+// DELETE /api/bookmarks/bulk[?atomic=true]，內容為 {"job_numbers": [...]}
+func (s *Server) bulkDeleteBookmarks(w http.ResponseWriter, r *http.Request) {
+	atomic, ok := atomicParam(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, bookmarkBulkMaxBytes)
+	var input struct {
+		JobNumbers []string `json:"job_numbers"`
+	}
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if len(input.JobNumbers) > maxBulkItems {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "bulk_too_large", len(input.JobNumbers), maxBulkItems)
+		return
+	}
+
+	// 格式錯誤的案號列在 errors；重複的案號只刪除一次
+	lang := requestLanguage(r)
+	bulkErrors := make([]BulkResult, 0)
+	var jobNumbers []string
+	seen := map[string]bool{}
+	for i, raw := range input.JobNumbers {
+		jn, code := checkJobNumber(raw)
+		if code != "" {
+			var args []interface{}
+			if code == "invalid_job_number" {
+				args = []interface{}{maxJobNumberLength, jobNumberPunct}
+			}
+			bulkErrors = append(bulkErrors, BulkResult{Index: i, JobNumber: raw, Error: code, Message: localize(lang, code, args...)})
+			continue
+		}
+		if !seen[jn] {
+			seen[jn] = true
+			jobNumbers = append(jobNumbers, jn)
+		}
+	}
+	if atomic && len(bulkErrors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"success": false,
+			"code":    "bulk_rejected",
+			"message": localize(lang, "bulk_rejected", len(bulkErrors)),
+			"total":   len(input.JobNumbers),
+			"failed":  len(bulkErrors),
+			"errors":  bulkErrors,
+		})
+		return
+	}
+
+	var deleted, notFound []string
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		for _, jn := range jobNumbers {
+			err := s.removeBookmark(tx, r, jn)
+			if errors.Is(err, errBookmarkNotFound) {
+				notFound = append(notFound, jn)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, jn)
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("批次刪除書籤 %d 筆（不存在 %d 筆）", len(deleted), len(notFound)), deleted...))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(deleted) > 0 {
+		s.markChanged()
+	}
+
+	if notFound == nil {
+		notFound = []string{}
+	}
+	writeSuccess(w, r, http.StatusOK, "bookmarks_bulk_deleted", map[string]interface{}{
+		"total":     len(input.JobNumbers),
+		"deleted":   len(deleted),
+		"not_found": len(notFound),
+		"missing":   notFound,
+		"failed":    len(bulkErrors),
+		"errors":    bulkErrors,
+	})
+}
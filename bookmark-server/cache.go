@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 標記資料已異動（遞增 Server.changes），須在交易提交成功後呼叫
+func (s *Server) markChanged() {
+	s.changes.Add(1)
+	s.stats.notify()
+	s.broker.notify()
+}
+
+// 回應快取上限
+const (
+	responseCacheMaxEntries = 256
+	responseCacheMaxBytes   = 8 << 20
+	responseCacheTTL        = 30 * time.Second
+)
+
+// 快取的回應
+type cachedResponse struct {
+	key      string
+	version  uint64
+	storedAt time.Time
+	status   int
+	header   http.Header // 處理函式設定的標頭（Content-Type、X-Scan-Warnings 等），命中時一併送出
+	body     []byte
+
+	// 回應中 generated_at 值的位置，命中時換成目前時間；沒有時 generatedEnd 為 0
+	generatedStart, generatedEnd int
+}
+
+// JSON 回應中表示產生時間的鍵（例如 GET /api/bookmarks/list?format_version=2）
+var generatedAtKey = []byte(`"generated_at":`)
+
+// 找出 body 中第一個 generated_at 字串值的位置
+func generatedAtSpan(body []byte) (start, end int) {
+	i := bytes.Index(body, generatedAtKey)
+	if i < 0 {
+		return 0, 0
+	}
+	start = i + len(generatedAtKey)
+	if start >= len(body) || body[start] != '"' {
+		return 0, 0
+	}
+	n := bytes.IndexByte(body[start+1:], '"')
+	if n < 0 {
+		return 0, 0
+	}
+	return start, start + n + 2
+}
+
+// 寫出快取的回應，generated_at 換成目前時間
+func (entry *cachedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range entry.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(entry.status)
+	if entry.generatedEnd == 0 {
+		w.Write(entry.body)
+		return
+	}
+	now, _ := json.Marshal(localTime(time.Now().Truncate(time.Millisecond)))
+	w.Write(entry.body[:entry.generatedStart])
+	w.Write(now)
+	w.Write(entry.body[entry.generatedEnd:])
+}
+
+// responseCache 以端點與正規化查詢字串為鍵的 LRU 回應快取
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // 最近使用的在前
+	bytes   int
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// 產生快取鍵：操作者 + 路徑 + 排序後的查詢參數
+// 以操作者區隔，避免多使用者時互相看到對方的資料
+func cacheKey(r *http.Request) string {
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(requestActor(r))
+	b.WriteString("|")
+	b.WriteString(r.URL.Path)
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			b.WriteString("|" + k + "=" + v)
+		}
+	}
+	return b.String()
+}
+
+// version 為目前的異動計數，快取項目的版本不同時視為失效
+func (c *responseCache) get(key string, version uint64) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cachedResponse)
+	if entry.version != version || time.Since(entry.storedAt) > responseCacheTTL {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+func (c *responseCache) put(entry *cachedResponse) {
+	if len(entry.body) > responseCacheMaxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[entry.key]; ok {
+		c.remove(el)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	c.bytes += len(entry.body)
+
+	for len(c.entries) > responseCacheMaxEntries || c.bytes > responseCacheMaxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// 移除項目，呼叫端須持有鎖
+func (c *responseCache) remove(el *list.Element) {
+	entry := el.Value.(*cachedResponse)
+	c.order.Remove(el)
+	delete(c.entries, entry.key)
+	c.bytes -= len(entry.body)
+}
+
+// 快取統計
+func (c *responseCache) stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"hits":    c.hits.Load(),
+		"misses":  c.misses.Load(),
+		"entries": len(c.entries),
+		"bytes":   c.bytes,
+	}
+}
+
+// 記錄回應內容以便寫入快取
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// 回應快取中介軟體，只快取 GET 的 200 回應
+// ?refresh=true 的請求要求重新計算，不讀取也不寫入快取；處理函式以 Cache-Control: no-store 表示該回應不可快取
+func (s *Server) cacheMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Query().Get("refresh") == "true" {
+			next(w, r)
+			return
+		}
+
+		// conditionalBookmarks 已讀取資料庫中的版本時以此為準，與回應的 ETag 一致
+		version, ok := requestBookmarksVersion(r)
+		if !ok {
+			version = s.changes.Load()
+		}
+		key := cacheKey(r)
+		if entry, ok := s.cache.get(key, version); ok {
+			s.cache.hits.Add(1)
+			entry.writeTo(w)
+			return
+		}
+		s.cache.misses.Add(1)
+
+		// 版本在處理前取得，避免處理期間發生的異動被誤認為已包含在回應中
+		// 外層（CORS、ETag 等）已設定的標頭每次都會重新設定，只保存處理函式新增或修改的標頭
+		before := w.Header().Clone()
+		w.Header().Set("X-Cache", "MISS")
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status != http.StatusOK || strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+			return
+		}
+		header := http.Header{}
+		for k, v := range w.Header() {
+			if k != "X-Cache" && !slices.Equal(before[k], v) {
+				header[k] = slices.Clone(v)
+			}
+		}
+		entry := &cachedResponse{
+			key:      key,
+			version:  version,
+			storedAt: time.Now(),
+			status:   rec.status,
+			header:   header,
+			body:     rec.body.Bytes(),
+		}
+		entry.generatedStart, entry.generatedEnd = generatedAtSpan(entry.body)
+		s.cache.put(entry)
+	}
+}
+
+// If-None-Match 是否包含 etag（弱比較，接受 * 與以逗號分隔的多個值）
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 取得執行期統計
+func (s *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"response_cache": s.cache.stats(),
+		"change_counter": s.changes.Load(),
+		"writer":         s.db.writer.stats(),
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 快取命中時送出與未命中時相同的標頭與內容，generated_at 重新計算；資料異動後重新計算
+func TestResponseCacheHitMiss(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001"}`)
+
+	const target = "/api/bookmarks/list?format_version=2"
+	miss := serve(t, h, "GET", target, "")
+	time.Sleep(5 * time.Millisecond)
+	hit := serve(t, h, "GET", target, "")
+	if miss.Header().Get("X-Cache") != "MISS" || hit.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("X-Cache = %q, %q; want MISS, HIT", miss.Header().Get("X-Cache"), hit.Header().Get("X-Cache"))
+	}
+	for _, k := range []string{"Content-Type", "X-Scan-Warnings", "ETag", "Access-Control-Allow-Origin"} {
+		if got, want := hit.Header().Get(k), miss.Header().Get(k); got != want || want == "" {
+			t.Errorf("HIT %s = %q, MISS %q", k, got, want)
+		}
+	}
+
+	var missBody, hitBody map[string]json.RawMessage
+	json.Unmarshal(miss.Body.Bytes(), &missBody)
+	if err := json.Unmarshal(hit.Body.Bytes(), &hitBody); err != nil {
+		t.Fatalf("HIT 的回應不是 JSON: %v %s", err, hit.Body.String())
+	}
+	var missAt, hitAt time.Time
+	json.Unmarshal(missBody["generated_at"], &missAt)
+	json.Unmarshal(hitBody["generated_at"], &hitAt)
+	if !hitAt.After(missAt) {
+		t.Errorf("HIT 的 generated_at = %s，MISS 為 %s", hitAt, missAt)
+	}
+	delete(missBody, "generated_at")
+	delete(hitBody, "generated_at")
+	a, _ := json.Marshal(missBody)
+	b, _ := json.Marshal(hitBody)
+	if !bytes.Equal(a, b) {
+		t.Errorf("HIT = %s, MISS = %s", b, a)
+	}
+
+	// 不同格式是不同的快取項目
+	if w := serve(t, h, "GET", "/api/bookmarks/list", ""); w.Header().Get("X-Cache") != "MISS" || w.Body.String() != `["A001"]`+"\n" {
+		t.Errorf("舊格式 = %s %s", w.Header().Get("X-Cache"), w.Body.String())
+	}
+
+	addTestBookmark(t, h, `{"job_number":"A002"}`)
+	w := serve(t, h, "GET", target, "")
+	if w.Header().Get("X-Cache") != "MISS" || decodeBody(t, w)["count"] != float64(2) {
+		t.Errorf("異動後 X-Cache = %q: %s", w.Header().Get("X-Cache"), w.Body.String())
+	}
+
+	metrics := decodeBody(t, serve(t, h, "GET", "/api/admin/metrics", ""))["response_cache"].(map[string]interface{})
+	if metrics["hits"] != float64(1) || metrics["misses"] != float64(3) {
+		t.Errorf("response_cache = %v", metrics)
+	}
+}
+
+// 書籤統計經過回應快取；?refresh=true 與略舊的統計不使用快取
+func TestStatsResponseCache(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001"}`)
+
+	// 等背景更新統計完成
+	deadline := time.Now().Add(5 * time.Second)
+	for decodeBody(t, serve(t, h, "GET", "/api/bookmarks/stats?refresh=true", ""))["stale"] != false {
+		if time.Now().After(deadline) {
+			t.Fatal("統計一直是 stale")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i, want := range []string{"MISS", "HIT"} {
+		w := serve(t, h, "GET", "/api/bookmarks/stats", "")
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != want {
+			t.Errorf("第 %d 次 = %d, X-Cache %q, want %s", i+1, w.Code, w.Header().Get("X-Cache"), want)
+		}
+		if decodeBody(t, w)["total"] != float64(1) {
+			t.Errorf("total = %s", w.Body.String())
+		}
+	}
+	if w := serve(t, h, "GET", "/api/bookmarks/stats?refresh=true", ""); w.Header().Get("X-Cache") != "" {
+		t.Errorf("refresh=true X-Cache = %q", w.Header().Get("X-Cache"))
+	}
+
+	addTestBookmark(t, h, `{"job_number":"A002"}`)
+	w := serve(t, h, "GET", "/api/bookmarks/stats", "")
+	if w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("異動後 X-Cache = %q", w.Header().Get("X-Cache"))
+	}
+}
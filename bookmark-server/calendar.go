@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 截止投標行事曆（iCalendar）
+//
+// GET /api/bookmarks/calendar 下載一次性的 .ics；GET /calendar/{token}.ics 為可訂閱的網址（例如加入 Google 日曆），
+// token 為設定檔 calendar.tokens 中的任一個，每次請求重新產生，內容與下載的檔案相同。
+// 每個有截止投標時間（取自 tender_cache，見 tenderDeadline）的書籤是一個 VEVENT，UID 以案號產生，不會變動；
+// 截止時間改變時 SEQUENCE 加一、DTSTAMP 更新，行事曆軟體會更新既有的事件而不是新增一筆。
+// 各書籤目前的截止時間與 SEQUENCE 記錄於 calendar_events；唯讀模式不更新紀錄，沿用上次的 SEQUENCE。
+// token 錯誤或網址格式不符時一律回應相同的 404。
+
+// 回應的快取時間；行事曆軟體另依 REFRESH-INTERVAL（1 小時）重新下載
+const calendarMaxAge = 15 * time.Minute
+
+// UID 的網域部分
+const calendarUIDDomain = "gov-procurement-bookmarks"
+
+// CalendarConfig 行事曆訂閱設定
+type CalendarConfig struct {
+	Tokens []string `json:"tokens"` // 訂閱網址中的密鑰，未設定時停用 /calendar/
+}
+
+// token 至少的長度，避免被猜到
+const minCalendarTokenLength = 16
+
+// 檢查設定
+func (c *CalendarConfig) validate() error {
+	for _, t := range c.Tokens {
+		if len(t) < minCalendarTokenLength {
+			return fmt.Errorf("calendar.tokens 每個至少要 %d 個字元", minCalendarTokenLength)
+		}
+		if url.PathEscape(t) != t || strings.Contains(t, ".") {
+			return fmt.Errorf("calendar.tokens 只能使用英數字與 -、_、~")
+		}
+	}
+	return nil
+}
+
+// 行事曆中的一個事件
+type calendarEvent struct {
+	JobNumber string
+	Title     string
+	UnitName  string
+	URL       string
+	Priority  int
+	Deadline  time.Time
+	Sequence  int
+	Stamp     time.Time // 截止時間最後一次改變（或第一次出現）的時間
+}
+
+// 有截止投標時間的書籤，依截止時間排列，並更新 calendar_events 中的 SEQUENCE
+func (s *Server) calendarEvents(r *http.Request) ([]*calendarEvent, error) {
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT b.job_number, b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0), c.body,
+			e.deadline, COALESCE(e.sequence, 0), e.updated_at
+		FROM bookmarks b
+		JOIN tender_cache c ON c.job_number = b.job_number
+		LEFT JOIN calendar_events e ON e.job_number = b.job_number
+		WHERE b.deleted_at IS NULL
+		ORDER BY b.job_number`)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var events, changed []*calendarEvent
+	for rows.Next() {
+		e := &calendarEvent{}
+		var body string
+		var recorded time.Time
+		if err := rows.Scan(&e.JobNumber, &e.Title, &e.UnitName, &e.URL, &e.Priority, &body,
+			scanTime(&recorded), &e.Sequence, scanTime(&e.Stamp)); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if e.Deadline = tenderDeadline([]byte(body)); e.Deadline.IsZero() {
+			continue
+		}
+		switch {
+		case recorded.IsZero() || e.Stamp.IsZero():
+			e.Sequence, e.Stamp = 0, now
+			changed = append(changed, e)
+		case !recorded.Equal(e.Deadline):
+			e.Sequence, e.Stamp = e.Sequence+1, now
+			changed = append(changed, e)
+		}
+		events = append(events, e)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changed) > 0 && !s.cfg.ReadOnly {
+		err := s.db.withTx(r.Context(), func(tx *Tx) error {
+			for _, e := range changed {
+				_, err := tx.Exec(`
+					INSERT INTO calendar_events (job_number, deadline, sequence, updated_at)
+					VALUES (?, ?, ?, ?)
+					ON CONFLICT(job_number) DO UPDATE SET
+						deadline = excluded.deadline, sequence = excluded.sequence, updated_at = excluded.updated_at`,
+					e.JobNumber, dbTime(e.Deadline), e.Sequence, dbTime(e.Stamp))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Deadline.Before(events[j].Deadline) })
+	return events, nil
+}
+
+// 產生 iCalendar 內容（RFC 5545）
+func buildCalendar(events []*calendarEvent, lang string) []byte {
+	var buf bytes.Buffer
+	line := func(s string) {
+		// 每行最多 75 個位元組，超過時折行（續行以空白開頭），不切斷 UTF-8 字元
+		for len(s) > 75 {
+			cut := 75
+			for cut > 0 && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			buf.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		buf.WriteString(s + "\r\n")
+	}
+	stamp := func(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//gov-procurement-analytics//bookmark-server//ZH")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + icsText(localize(lang, "calendar_name")))
+	line("X-WR-TIMEZONE:" + displayLocation.String())
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	line("X-PUBLISHED-TTL:PT1H")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:deadline-" + url.PathEscape(e.JobNumber) + "@" + calendarUIDDomain)
+		line("DTSTAMP:" + stamp(e.Stamp))
+		line("LAST-MODIFIED:" + stamp(e.Stamp))
+		line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+		line("DTSTART:" + stamp(e.Deadline))
+		line("DTEND:" + stamp(e.Deadline))
+		line("SUMMARY:" + icsText(localize(lang, "calendar_summary", e.Title)))
+		desc := []string{localize(lang, "calendar_job_number", e.JobNumber)}
+		if e.UnitName != "" {
+			desc = append(desc, localize(lang, "calendar_unit", e.UnitName))
+		}
+		if e.Priority != 0 {
+			desc = append(desc, localize(lang, "calendar_priority", e.Priority))
+		}
+		line("DESCRIPTION:" + icsText(strings.Join(desc, "\n")))
+		if e.URL != "" {
+			line("URL:" + e.URL)
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// TEXT 值的跳脫
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// 回應行事曆；ETag 為內容的雜湊，Last-Modified 為最後一次截止時間改變的時間，支援條件式請求
+func (s *Server) serveCalendar(w http.ResponseWriter, r *http.Request, filename string) {
+	events, err := s.calendarEvents(r)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	body := buildCalendar(events, requestLanguage(r))
+	var modified time.Time
+	for _, e := range events {
+		if e.Stamp.After(modified) {
+			modified = e.Stamp
+		}
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(calendarMaxAge/time.Second)))
+	if filename != "" {
+		w.Header().Set("Content-Disposition", contentDisposition(filename))
+	}
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// GET /api/bookmarks/calendar：下載截止投標行事曆
+func (s *Server) exportCalendar(w http.ResponseWriter, r *http.Request) {
+	s.serveCalendar(w, r, "deadlines_"+time.Now().In(displayLocation).Format("20060102")+".ics")
+}
+
+// GET /calendar/{file}：訂閱用的行事曆，file 為 <token>.ics
+func (s *Server) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || !s.validCalendarToken(token) {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveCalendar(w, r, "")
+}
+
+// 與每個 token 比對（固定時間比較）
+func (s *Server) validCalendarToken(token string) bool {
+	valid := false
+	for _, t := range s.cfg.Calendar.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
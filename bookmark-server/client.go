@@ -0,0 +1,624 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 遠端用戶端模式：經由 HTTP API 操作另一台伺服器，不開啟本機的資料庫
+//
+//	bookmark-server --server http://host:8080 [--api-key KEY] [--year 2026] [--lang en] <子命令> [參數]
+//
+// 子命令：
+//
+//	list [--category 類別] [--keyword 關鍵字] [--date-from 日期] [--date-to 日期] [--json]
+//	add <案號> --title 標案名稱 [--unit 機關] [--url 網址] [--api-url 網址] [--priority N] [--note 備註]
+//	note <案號> <備註>（備註為 - 時從標準輸入讀取）
+//	download
+//	export [-o 檔案] [--format json|csv|xlsx]（未指定檔案時輸出到標準輸出）
+//	import <檔案> [--mode skip|overwrite|merge]（檔案為 export 的輸出，- 時從標準輸入讀取）
+//	sync --peer 名稱或網址（--server 的伺服器與對象雙向同步一輪，見 sync.go）
+//
+// API 金鑰也可以用環境變數 BOOKMARK_API_KEY 指定，以 X-API-Key 標頭送出。
+// 伺服器回應錯誤時顯示其錯誤代碼與訊息，並以下列結束代碼結束，腳本可據此判斷。
+const (
+	exitUsage       = 2 // 參數錯誤
+	exitUnreachable = 3 // 無法連線到伺服器
+	exitAuth        = 4 // 401、403
+	exitNotFound    = 5 // 404
+	exitRejected    = 6 // 其他 4xx：參數、衝突、唯讀模式等
+	exitServer      = 7 // 5xx 或無法解析的回應
+	exitPartial     = 8 // 下載或匯入時部分書籤失敗
+)
+
+// 用戶端子命令的錯誤，exit 為結束代碼
+type clientError struct {
+	exit    int
+	status  int    // HTTP 狀態碼，沒有回應時為 0
+	code    string // 伺服器的錯誤代碼
+	message string
+}
+
+func (e *clientError) Error() string {
+	if e.code != "" {
+		return fmt.Sprintf("%s（%s，HTTP %d）", e.message, e.code, e.status)
+	}
+	return e.message
+}
+
+func usageError(format string, args ...interface{}) error {
+	return &clientError{exit: exitUsage, message: fmt.Sprintf(format, args...)}
+}
+
+// 命令列中有 --server 時以用戶端模式執行
+func isClientMode(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return false
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == "server" || strings.HasPrefix(name, "server=") {
+			return true
+		}
+	}
+	return false
+}
+
+// 結束代碼：用戶端錯誤依種類而定，其他錯誤為 1
+func exitCode(err error) int {
+	var ce *clientError
+	if errors.As(err, &ce) {
+		return ce.exit
+	}
+	return 1
+}
+
+type apiClient struct {
+	base   *url.URL
+	apiKey string
+	year   string
+	lang   string
+	http   *http.Client
+}
+
+// 送出請求；無法連線或回應 4xx、5xx 時回傳 *clientError，成功時由呼叫端關閉 resp.Body
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	u := *c.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.year != "" {
+		query.Set("year", c.year)
+	}
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.lang != "" {
+		req.Header.Set("Accept-Language", c.lang)
+	}
+	req.Header.Set("User-Agent", "bookmark-server-cli/"+version)
+	req.Header.Set(csrfHeader, "bookmark-server-cli")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &clientError{exit: exitUnreachable, message: fmt.Sprintf("無法連線到 %s: %v", c.base, err)}
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, responseError(resp)
+}
+
+// 由錯誤回應 {"success": false, "error": 代碼, "message": 訊息} 產生 *clientError
+func responseError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	e := &clientError{status: resp.StatusCode}
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(raw, &payload) == nil && payload.Error != "" {
+		e.code, e.message = payload.Error, payload.Message
+	} else {
+		e.message = fmt.Sprintf("伺服器回應 %s: %s", resp.Status, truncateMessage(strings.TrimSpace(string(raw))))
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		e.exit = exitAuth
+	case resp.StatusCode == http.StatusNotFound:
+		e.exit = exitNotFound
+	case resp.StatusCode < 500:
+		e.exit = exitRejected
+	default:
+		e.exit = exitServer
+	}
+	return e
+}
+
+// 送出請求並解析 JSON 回應
+func (c *apiClient) getJSON(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &clientError{exit: exitServer, status: resp.StatusCode, message: fmt.Sprintf("無法解析伺服器的回應: %v", err)}
+	}
+	return nil
+}
+
+// 允許旗標與位置參數交錯，例如 add <案號> --title ...
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError("%v", err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// 執行用戶端模式
+func runClient(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("bookmark-server", flag.ContinueOnError)
+	server := fs.String("server", "", "遠端伺服器網址，例如 http://host:8080")
+	apiKey := fs.String("api-key", os.Getenv("BOOKMARK_API_KEY"), "API 金鑰（X-API-Key），預設為環境變數 BOOKMARK_API_KEY")
+	year := fs.String("year", "", "年度（西元或民國年），預設為伺服器的年度")
+	lang := fs.String("lang", "", "伺服器訊息的語系（zh-TW 或 en）")
+	timeout := fs.Duration("timeout", 0, "整個請求的逾時，預設不限（下載可能需要數分鐘）")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	base, err := url.Parse(*server)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return usageError("--server 必須是 http 或 https 網址: %q", *server)
+	}
+	if fs.NArg() == 0 {
+		return usageError("請指定子命令：list、add、note、download、export、import、sync")
+	}
+	c := &apiClient{base: base, apiKey: *apiKey, year: *year, lang: *lang, http: &http.Client{}}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "list":
+		return c.list(ctx, rest, stdout)
+	case "add":
+		return c.add(ctx, rest, stdout)
+	case "note":
+		return c.note(ctx, rest, stdout)
+	case "download":
+		return c.download(ctx, rest, stdout)
+	case "export":
+		return c.export(ctx, rest, stdout)
+	case "import":
+		return c.importBookmarks(ctx, rest, stdout)
+	case "sync":
+		return c.sync(ctx, rest, stdout)
+	}
+	return usageError("不支援的子命令: %s（可用 list、add、note、download、export、import、sync）", cmd)
+}
+
+// list：以表格列出書籤
+func (c *apiClient) list(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	category := fs.String("category", "", "只列出此類別")
+	keyword := fs.String("keyword", "", "只列出符合此關鍵字")
+	dateFrom := fs.String("date-from", "", "公告日期起（YYYY-MM-DD 或民國日期）")
+	dateTo := fs.String("date-to", "", "公告日期迄")
+	asJSON := fs.Bool("json", false, "輸出 JSON 而非表格")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError("list 不接受參數: %s", strings.Join(positional, " "))
+	}
+
+	query := url.Values{}
+	for name, v := range map[string]string{"category": *category, "keyword": *keyword, "date_from": *dateFrom, "date_to": *dateTo} {
+		if v != "" {
+			query.Set(name, v)
+		}
+	}
+	// 只取表格需要的欄位；date 照原樣保留，舊資料中無效的日期也能列出
+	var bookmarks []struct {
+		JobNumber string          `json:"job_number"`
+		Title     string          `json:"title"`
+		UnitName  string          `json:"unit_name"`
+		Date      json.RawMessage `json:"date"`
+		Note      string          `json:"note"`
+		Priority  int             `json:"priority"`
+	}
+	var raw json.RawMessage
+	if err := c.getJSON(ctx, "GET", "/api/bookmarks", query, nil, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &bookmarks); err != nil {
+		return &clientError{exit: exitServer, status: http.StatusOK, message: fmt.Sprintf("無法解析伺服器的回應: %v", err)}
+	}
+	if *asJSON {
+		var out bytes.Buffer
+		if err := json.Indent(&out, raw, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err := out.WriteTo(stdout)
+		return err
+	}
+
+	rows := make([][]string, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		rows = append(rows, []string{b.JobNumber, strconv.Itoa(b.Priority), displayDate(b.Date), b.UnitName, b.Title, firstLine(b.Note)})
+	}
+	printTable(stdout, []string{"案號", "優先", "公告日期", "機關", "標案名稱", "備註"}, rows)
+	fmt.Fprintf(stdout, "共 %d 筆\n", len(bookmarks))
+	return nil
+}
+
+// add：新增書籤
+func (c *apiClient) add(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	input := struct {
+		JobNumber string `json:"job_number"`
+		Title     string `json:"title"`
+		UnitName  string `json:"unit_name,omitempty"`
+		URL       string `json:"url,omitempty"`
+		APIURL    string `json:"api_url,omitempty"`
+		Note      string `json:"note,omitempty"`
+		Priority  int    `json:"priority,omitempty"`
+	}{}
+	fs.StringVar(&input.Title, "title", "", "標案名稱（必填）")
+	fs.StringVar(&input.UnitName, "unit", "", "機關名稱")
+	fs.StringVar(&input.URL, "url", "", "標案頁面網址")
+	fs.StringVar(&input.APIURL, "api-url", "", "標案詳細資料 API 網址")
+	fs.StringVar(&input.Note, "note", "", "備註")
+	fs.IntVar(&input.Priority, "priority", 0, "優先順序")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("用法: add <案號> --title 標案名稱 [--unit 機關] [--url 網址] [--api-url 網址] [--priority N] [--note 備註]")
+	}
+	if input.Title == "" {
+		return usageError("add 需要 --title")
+	}
+	input.JobNumber = positional[0]
+
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if err := c.getJSON(ctx, "POST", "/api/bookmarks", nil, input, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", input.JobNumber, resp.Message)
+	return nil
+}
+
+// note：更新備註
+func (c *apiClient) note(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) != 2 {
+		return usageError("用法: note <案號> <備註>（備註為 - 時從標準輸入讀取）")
+	}
+	text := args[1]
+	if text == "-" {
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimRight(string(raw), "\r\n")
+	}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	body := map[string]interface{}{"job_number": args[0], "note": text}
+	if err := c.getJSON(ctx, "PUT", "/api/bookmarks", nil, body, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", args[0], resp.Message)
+	return nil
+}
+
+// download：請伺服器下載所有書籤的標案資料，逐筆顯示結果；有失敗時以 exitPartial 結束
+func (c *apiClient) download(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return usageError("download 不接受參數: %s", strings.Join(args, " "))
+	}
+	resp, err := c.do(ctx, "GET", "/api/bookmarks/download", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// NDJSON：每筆結果一行，最後一行為摘要
+	failed := 0
+	var summary struct {
+		Total     int    `json:"total"`
+		Success   int    `json:"success"`
+		OutputDir string `json:"output_dir"`
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var line struct {
+			Type      string `json:"type"`
+			JobNumber string `json:"job_number"`
+			Title     string `json:"title"`
+			Status    string `json:"status"`
+			Error     string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return &clientError{exit: exitServer, status: resp.StatusCode, message: fmt.Sprintf("無法解析伺服器的回應: %v", err)}
+		}
+		switch line.Type {
+		case "result":
+			status := "完成"
+			if line.Status == "error" {
+				status = "失敗"
+				failed++
+			}
+			fmt.Fprintf(stdout, "%s  %s  %s", status, line.JobNumber, truncateWidth(line.Title, 40))
+			if line.Error != "" {
+				fmt.Fprintf(stdout, "  %s", line.Error)
+			}
+			fmt.Fprintln(stdout)
+		case "summary":
+			json.Unmarshal(scanner.Bytes(), &summary)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &clientError{exit: exitUnreachable, status: resp.StatusCode, message: fmt.Sprintf("下載途中連線中斷: %v", err)}
+	}
+	fmt.Fprintf(stdout, "共 %d 筆，成功 %d 筆，失敗 %d 筆；檔案位於伺服器的 %s\n", summary.Total, summary.Success, failed, summary.OutputDir)
+	if failed > 0 {
+		return &clientError{exit: exitPartial, message: fmt.Sprintf("%d 筆書籤下載失敗", failed)}
+	}
+	return nil
+}
+
+// export：匯出書籤 JSON、CSV 或 XLSX
+func (c *apiClient) export(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "輸出檔案，未指定或為 - 時輸出到標準輸出")
+	format := fs.String("format", "json", "格式：json、csv 或 xlsx")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError("export 不接受參數: %s", strings.Join(positional, " "))
+	}
+	resp, err := c.do(ctx, "GET", "/api/bookmarks/export", url.Values{"format": {*format}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if *output == "" || *output == "-" {
+		_, err = io.Copy(stdout, resp.Body)
+		return err
+	}
+	// 先寫到暫存檔，下載不完整時不覆蓋既有的檔案
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &clientError{exit: exitUnreachable, status: resp.StatusCode, message: fmt.Sprintf("匯出途中連線中斷: %v", err)}
+	}
+	if err := writeFileAtomic(*output, raw); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "已匯出到 %s（%d 位元組）\n", *output, len(raw))
+	return nil
+}
+
+// import：匯入 export 產生的檔案，顯示各種處理的筆數與格式錯誤的書籤
+func (c *apiClient) importBookmarks(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	mode := fs.String("mode", importSkip, "案號已存在時的處理：skip、overwrite 或 merge")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError("用法: import <檔案> [--mode skip|overwrite|merge]")
+	}
+	var raw []byte
+	if positional[0] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return usageError("讀取匯入檔案失敗: %v", err)
+	}
+	if !json.Valid(raw) {
+		return usageError("%s 不是有效的 JSON", positional[0])
+	}
+
+	var resp struct {
+		Imported    int           `json:"imported"`
+		Overwritten int           `json:"overwritten"`
+		Merged      int           `json:"merged"`
+		Skipped     int           `json:"skipped"`
+		Errors      []ImportError `json:"errors"`
+	}
+	if err := c.getJSON(ctx, "POST", "/api/bookmarks/import", url.Values{"mode": {*mode}}, json.RawMessage(raw), &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "新增 %d 筆，取代 %d 筆，合併 %d 筆，略過 %d 筆\n", resp.Imported, resp.Overwritten, resp.Merged, resp.Skipped)
+	for _, e := range resp.Errors {
+		fmt.Fprintf(stdout, "第 %d 筆  %s  %s\n", e.Index, e.JobNumber, e.Message)
+	}
+	if len(resp.Errors) > 0 {
+		return &clientError{exit: exitPartial, message: fmt.Sprintf("%d 筆書籤格式錯誤，未匯入", len(resp.Errors))}
+	}
+	return nil
+}
+
+// sync：請伺服器與對象雙向同步一輪，顯示兩個方向套用、略過與衝突的筆數，以及衝突的書籤
+func (c *apiClient) sync(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	peer := fs.String("peer", "", "同步對象：sync.peers 中的名稱或伺服器網址")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 || *peer == "" {
+		return usageError("用法: sync --peer 名稱或網址")
+	}
+	var resp struct {
+		Summary SyncSummary `json:"summary"`
+	}
+	if err := c.getJSON(ctx, "POST", "/api/sync/run", nil, map[string]string{"peer": *peer}, &resp); err != nil {
+		return err
+	}
+	sum := resp.Summary
+	fmt.Fprintf(stdout, "與 %s 同步完成", sum.Peer)
+	if sum.Snapshot {
+		fmt.Fprint(stdout, "（完整快照）")
+	}
+	fmt.Fprintln(stdout)
+	printTable(stdout, []string{"方向", "套用", "略過", "衝突"}, [][]string{
+		{"拉取", strconv.Itoa(sum.Pulled.Applied), strconv.Itoa(sum.Pulled.Skipped), strconv.Itoa(len(sum.Pulled.Conflicts))},
+		{"推送", strconv.Itoa(sum.Pushed.Applied), strconv.Itoa(sum.Pushed.Skipped), strconv.Itoa(len(sum.Pushed.Conflicts))},
+	})
+	var rows [][]string
+	for _, dir := range []struct {
+		name      string
+		conflicts []SyncConflict
+	}{{"拉取", sum.Pulled.Conflicts}, {"推送", sum.Pushed.Conflicts}} {
+		for _, cf := range dir.conflicts {
+			rows = append(rows, []string{dir.name, cf.JobNumber, cf.Op, cf.Reason})
+		}
+	}
+	if len(rows) > 0 {
+		fmt.Fprintln(stdout)
+		printTable(stdout, []string{"方向", "案號", "異動", "原因"}, rows)
+	}
+	return nil
+}
+
+// 以空白對齊的表格輸出；中文等全形字元以兩格計算寬度
+func printTable(w io.Writer, header []string, rows [][]string) {
+	const maxCellWidth = 40
+	widths := make([]int, len(header))
+	cells := append([][]string{header}, rows...)
+	for i, row := range cells {
+		for j, cell := range row {
+			cell = truncateWidth(cell, maxCellWidth)
+			cells[i][j] = cell
+			if n := displayWidth(cell); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+	for _, row := range cells {
+		var line strings.Builder
+		for j, cell := range row {
+			line.WriteString(cell)
+			if j < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[j]-displayWidth(cell)+2))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(line.String(), " "))
+	}
+}
+
+// 終端機中的顯示寬度
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// 東亞全形字元（CJK、諺文、全形標點）佔兩格
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115F, r >= 0x2E80 && r <= 0xA4CF, r >= 0xAC00 && r <= 0xD7A3, r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F, r >= 0xFF00 && r <= 0xFF60, r >= 0xFFE0 && r <= 0xFFE6, r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
+
+// 截斷到最多 max 格寬，截斷時以 … 結尾
+func truncateWidth(s string, max int) string {
+	if displayWidth(s) <= max {
+		return s
+	}
+	n := 0
+	for i, r := range s {
+		if n+runeWidth(r) > max-1 {
+			return s[:i] + "…"
+		}
+		n += runeWidth(r)
+	}
+	return s
+}
+
+// 公告日期 YYYYMMDD 顯示為 YYYY-MM-DD，其他值照原樣顯示
+func displayDate(raw json.RawMessage) string {
+	s := strings.Trim(string(raw), `"`)
+	if s == "0" || s == "null" {
+		return ""
+	}
+	if len(s) == 8 {
+		if _, err := strconv.Atoi(s); err == nil {
+			return s[:4] + "-" + s[4:6] + "-" + s[6:]
+		}
+	}
+	return s
+}
+
+// 第一行文字，多行時加上 …
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i] + "…"
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 以 httptest 啟動伺服器，回傳 Server 與網址
+func newClientTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	t.Setenv("BOOKMARK_API_KEY", "")
+	s := newTestServer(t)
+	ts := httptest.NewServer(s.routes())
+	t.Cleanup(ts.Close)
+	return s, ts.URL
+}
+
+// 執行用戶端命令，回傳輸出與結束代碼（成功為 0）
+func runTestClient(args ...string) (string, int, error) {
+	var out bytes.Buffer
+	err := runClient(args, &out)
+	if err == nil {
+		return out.String(), 0, nil
+	}
+	return out.String(), exitCode(err), err
+}
+
+func TestClientCommands(t *testing.T) {
+	_, url := newClientTestServer(t)
+
+	out, code, err := runTestClient("--server", url, "add", "A001", "--title", "道路養護工程", "--unit", "臺北市政府", "--priority", "3")
+	if code != 0 || !strings.Contains(out, "A001") {
+		t.Fatalf("add: code=%d err=%v out=%q", code, err, out)
+	}
+	if out, code, err = runTestClient("--server", url, "note", "A001", "週五前報價"); code != 0 {
+		t.Fatalf("note: code=%d err=%v out=%q", code, err, out)
+	}
+
+	out, code, err = runTestClient("--server", url, "list")
+	if code != 0 {
+		t.Fatalf("list: code=%d err=%v", code, err)
+	}
+	for _, want := range []string{"案號", "A001", "道路養護工程", "臺北市政府", "週五前報價", "共 1 筆"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list 輸出缺少 %q:\n%s", want, out)
+		}
+	}
+
+	out, code, _ = runTestClient("--server", url, "list", "--json")
+	var listed []Bookmark
+	if code != 0 || json.Unmarshal([]byte(out), &listed) != nil || len(listed) != 1 || listed[0].Note != "週五前報價" {
+		t.Errorf("list --json: code=%d out=%q", code, out)
+	}
+
+	path := filepath.Join(t.TempDir(), "bookmarks.json")
+	if out, code, err = runTestClient("--server", url, "export", "-o", path); code != 0 {
+		t.Fatalf("export: code=%d err=%v out=%q", code, err, out)
+	}
+	raw, _ := os.ReadFile(path)
+	var exported []Bookmark
+	if json.Unmarshal(raw, &exported) != nil || len(exported) != 1 || exported[0].JobNumber != "A001" {
+		t.Errorf("匯出檔案內容錯誤: %s", raw)
+	}
+
+	// 沒有 api_url 的書籤下載失敗，部分失敗以 exitPartial 結束
+	out, code, _ = runTestClient("--server", url, "download")
+	if code != exitPartial || !strings.Contains(out, "失敗  A001") || !strings.Contains(out, "共 1 筆，成功 0 筆，失敗 1 筆") {
+		t.Errorf("download: code=%d out=%q", code, out)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	s, url := newClientTestServer(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		exit     int
+		code     string // 伺服器的錯誤代碼
+		contains string // 錯誤訊息應包含的文字
+	}{
+		{"no subcommand", []string{"--server", url}, exitUsage, "", "子命令"},
+		{"unknown subcommand", []string{"--server", url, "fly"}, exitUsage, "", "fly"},
+		{"bad server url", []string{"--server", "ftp://host", "list"}, exitUsage, "", "--server"},
+		{"add without title", []string{"--server", url, "add", "A001"}, exitUsage, "", "--title"},
+		{"note missing", []string{"--server", url, "note", "Z999", "備註"}, exitNotFound, "not_found", "Z999"},
+		{"invalid job number", []string{"--server", url, "add", "A<1>", "--title", "x"}, exitRejected, "invalid_job_number", ""},
+		{"bad export format", []string{"--server", url, "export", "--format", "pdf"}, exitRejected, "invalid_parameter", "format"},
+		{"unknown year", []string{"--server", url, "--year", "1999", "list"}, exitNotFound, "unknown_year", "1999"},
+		{"english messages", []string{"--server", url, "--lang", "en", "note", "Z999", "x"}, exitNotFound, "not_found", "not found"},
+		{"unreachable", []string{"--server", "http://127.0.0.1:1", "list"}, exitUnreachable, "", "無法連線"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, exit, err := runTestClient(tt.args...)
+			if exit != tt.exit {
+				t.Fatalf("exit = %d, want %d (err=%v)", exit, tt.exit, err)
+			}
+			var ce *clientError
+			if !errors.As(err, &ce) {
+				t.Fatalf("err = %T %v, want *clientError", err, err)
+			}
+			if ce.code != tt.code {
+				t.Errorf("code = %q, want %q", ce.code, tt.code)
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("錯誤訊息 %q 不含 %q", err.Error(), tt.contains)
+			}
+			// 顯示伺服器的訊息而非原始 JSON
+			if strings.Contains(err.Error(), `"success"`) {
+				t.Errorf("錯誤訊息含有原始 JSON: %s", err)
+			}
+		})
+	}
+
+	t.Run("api key", func(t *testing.T) {
+		s.cfg.APIKeys = []string{"office-key-0123456789"}
+		defer func() { s.cfg.APIKeys = nil }()
+		if _, exit, err := runTestClient("--server", url, "list"); exit != exitAuth {
+			t.Errorf("沒有金鑰: exit = %d, want %d (err=%v)", exit, exitAuth, err)
+		}
+		if _, exit, err := runTestClient("--server", url, "--api-key", "wrong", "list"); exit != exitAuth {
+			t.Errorf("錯誤的金鑰: exit = %d, want %d (err=%v)", exit, exitAuth, err)
+		}
+		if _, exit, err := runTestClient("--server", url, "--api-key", "office-key-0123456789", "list"); exit != 0 {
+			t.Errorf("正確的金鑰: exit = %d (err=%v)", exit, err)
+		}
+	})
+}
+
+func TestIsClientMode(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"--server", "http://h", "list"}, true},
+		{[]string{"--server=http://h", "list"}, true},
+		{[]string{"-server", "http://h"}, true},
+		{[]string{"--port", "8080"}, false},
+		{[]string{"--", "--server"}, false},
+		{[]string{"list", "--server", "http://h"}, false},
+	}
+	for _, tt := range tests {
+		if got := isClientMode(tt.args); got != tt.want {
+			t.Errorf("isClientMode(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestPrintTableWidths(t *testing.T) {
+	var out bytes.Buffer
+	printTable(&out, []string{"案號", "名稱"}, [][]string{{"A001", "道路"}, {"B2", "x"}})
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("表格行數不足:\n%s", out.String())
+	}
+	// 全形字元以兩格計算，第二欄在每一行的起始位置相同
+	col := -1
+	for _, line := range lines {
+		if strings.Trim(line, "-— ") == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		pos := displayWidth(line[:strings.LastIndex(line, fields[len(fields)-1])])
+		if col >= 0 && pos != col {
+			t.Errorf("欄位未對齊:\n%s", out.String())
+			break
+		}
+		col = pos
+	}
+}
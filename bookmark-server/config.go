@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 伺服器設定
+type Config struct {
+	ReadOnly bool   `json:"read_only"` // 唯讀模式：以唯讀方式開啟資料庫並拒絕所有修改請求
+	Language string `json:"language"`  // 預設語系（zh-TW 或 en），請求未指定 Accept-Language 時使用
+
+	// 監聽的連接埠，預設 8080；也可用環境變數 PORT 或 --port 指定
+	Port string `json:"port"`
+
+	// 資料根目錄，各年度的資料放在其下的 <西元年>/，預設為 ../pcc_data；也可用環境變數 DATA_DIR 或 --data-dir 指定
+	DataDir string `json:"data_dir"`
+
+	// 主要年度的 SQLite 資料庫檔案，預設為 <DataDir>/<年度>/bookmarks.db；其他年度仍使用資料根目錄下的資料庫
+	// 也可用環境變數 DB_PATH 或 --db 指定
+	DBPath string `json:"db_path"`
+
+	// 根路徑 / 提供的靜態網頁目錄，預設為 <DataDir>/<年度>/filtered_for_company；
+	// 也可用環境變數 STATIC_DIR 或 --static-dir 指定
+	StaticDir string `json:"static_dir"`
+
+	// 年度：決定資料庫與下載目錄（pcc_data/<西元年>/），預設為台灣時間的今年
+	// 可填西元年（2026）或民國年（115）；其他年度可在請求中以 ?year= 指定
+	Year int `json:"year"`
+
+	// 跨站請求防護：瀏覽器發出的修改請求必須來自同源或 AllowedOrigins
+	// 純 API 金鑰部署（沒有瀏覽器介面）可關閉
+	CSRF bool `json:"csrf"`
+
+	// 允許的來源，例如 "https://intranet.example.com"；也決定 CORS 回應給哪些來源，未設定時允許任何來源讀取
+	// 也可用環境變數 ALLOWED_ORIGINS（以逗號分隔）指定
+	AllowedOrigins []string `json:"allowed_origins"`
+
+	// API 金鑰：設定後 /api/ 之下的請求都必須以 X-API-Key 標頭帶其中一組，否則回傳 401（見 apikeys.go）
+	// 也可用環境變數 API_KEYS（以逗號分隔）指定；未設定時不需要金鑰
+	APIKeys []string `json:"api_keys"`
+
+	// 資料庫連線字串；設定為 postgres:// 時改用 PostgreSQL，未設定時使用 SQLite 檔案
+	// 也可用環境變數 DATABASE_URL 指定
+	DatabaseURL string `json:"database_url"`
+
+	// 欄位加密金鑰（base64 編碼的 32 位元組），設定後 note、data 與事件內容以 AES-GCM 加密儲存
+	// 也可用環境變數 BOOKMARK_ENCRYPTION_KEY 指定；既有資料以 --encrypt-existing 加密
+	EncryptionKey string `json:"encryption_key"`
+
+	// 自動執行資料庫維護（ANALYZE/VACUUM）的間隔，例如 "168h"；未設定則停用
+	MaintenanceInterval Duration `json:"maintenance_interval"`
+
+	// 自動備份（VACUUM INTO）的間隔，預設 "24h"，設為 "0s" 停用
+	// 備份存放於 BackupDir（預設為資料庫旁的 backups/），保留最新 BackupKeep 份，
+	// BackupMaxBytes > 0 時另限制備份總大小
+	BackupInterval Duration `json:"backup_interval"`
+	BackupDir      string   `json:"backup_dir"`
+	BackupKeep     int      `json:"backup_keep"`
+	BackupMaxBytes int64    `json:"backup_max_bytes"`
+
+	// 事件保留期限，例如 "2160h"（90 天）；未設定則永久保留
+	EventRetention Duration `json:"event_retention"`
+
+	// 清理已刪除書籤留下的下載檔案、快取與子表資料的間隔，預設 "24h"，設為 "0s" 停用
+	PruneInterval Duration `json:"prune_interval"`
+
+	// 垃圾桶中的書籤保留期限，預設 "720h"（30 天）；啟動時與之後每天永久刪除超過期限的書籤，設為 "0s" 停用
+	TrashRetention Duration `json:"trash_retention"`
+
+	// 標案詳細資料快取：TenderCacheTTL 內直接使用快取，
+	// 過期後 TenderCacheMaxStale 內先回傳舊資料並於背景更新
+	TenderCacheTTL      Duration `json:"tender_cache_ttl"`
+	TenderCacheMaxStale Duration `json:"tender_cache_max_stale"`
+
+	// 下載標書時向 PCC API 發出請求的最短間隔（速率上限），預設 "500ms"
+	// 上游回應 429/503 或連續失敗時自動拉長間隔（並遵守 Retry-After），最長 DownloadMaxInterval，預設 "2m"
+	DownloadInterval    Duration `json:"download_interval"`
+	DownloadMaxInterval Duration `json:"download_max_interval"`
+
+	// 下載標書時同時進行的筆數，預設 3；請求的間隔仍受 DownloadInterval 限制
+	DownloadConcurrency int `json:"download_concurrency"`
+
+	// 附件檔案根目錄，各年度使用其下的 <年度>/ 子目錄；未設定時為年度資料目錄下的 attachments/
+	AttachmentsDir string `json:"attachments_dir"`
+
+	// 上傳附件（POST /api/bookmarks/attachments）的檔案大小上限（位元組），預設 25 MiB
+	UploadMaxBytes int64 `json:"upload_max_bytes"`
+
+	// 顯示時區（IANA 名稱），JSON 中的時間與「今天」等日期計算使用此時區，預設 "Asia/Taipei"
+	Timezone string `json:"timezone"`
+
+	// 靜態檔案掛載，例如同時提供 bookmarked_tenders 與 reports 目錄
+	StaticMounts []StaticMount `json:"static_mounts"`
+
+	// 通知（LINE 等），見 notify.go；未設定提供者時停用
+	Notifications NotificationConfig `json:"notifications"`
+
+	// Telegram bot，見 telegram.go；未設定 allowed_chats 時停用
+	Telegram TelegramConfig `json:"telegram"`
+
+	// 每日電子郵件摘要，見 emaildigest.go；未設定 smtp.host 時停用
+	EmailDigest EmailDigestConfig `json:"email_digest"`
+
+	// 每日下載標案清單並篩選，見 ingest.go；enabled 為 true 時啟用
+	Ingest IngestConfig `json:"ingest"`
+
+	// pcc.g0v.tw API 與各功能的資料來源，見 pccg0v.go
+	PCCG0v PCCG0vConfig `json:"pcc_g0v"`
+
+	// 標案異動偵測比對的欄位與排程，見 tenderchanges.go
+	TenderChanges TenderChangesConfig `json:"tender_changes"`
+
+	// 截止投標行事曆的訂閱網址 /calendar/{token}.ics，見 calendar.go
+	Calendar CalendarConfig `json:"calendar"`
+
+	// 每週報告，見 weeklyreport.go；enabled 為 true 時啟用，報告目錄自動加入靜態掛載
+	WeeklyReport WeeklyReportConfig `json:"weekly_report"`
+
+	// 與其他伺服器同步書籤的對象，見 sync.go
+	Sync SyncConfig `json:"sync"`
+}
+
+// Duration 設定檔中以字串表示的時間間隔，例如 "24h"、"30m"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		d.Duration = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// 載入設定：先讀設定檔，再以環境變數覆寫，最後以命令列參數覆寫
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Port:                "8080",
+		Language:            langZhTW,
+		Timezone:            "Asia/Taipei",
+		CSRF:                true,
+		BackupInterval:      Duration{24 * time.Hour},
+		BackupKeep:          7,
+		PruneInterval:       Duration{24 * time.Hour},
+		TrashRetention:      Duration{30 * 24 * time.Hour},
+		TenderCacheTTL:      Duration{6 * time.Hour},
+		TenderCacheMaxStale: Duration{7 * 24 * time.Hour},
+		DownloadInterval:    Duration{500 * time.Millisecond},
+		DownloadMaxInterval: Duration{2 * time.Minute},
+		DownloadConcurrency: 3,
+		UploadMaxBytes:      25 << 20,
+	}
+
+	configPath := flag.String("config", "", "設定檔路徑（JSON）")
+	readOnly := flag.Bool("read-only", false, "以唯讀模式開啟資料庫")
+	language := flag.String("lang", langZhTW, "預設語系（zh-TW 或 en）")
+	csrf := flag.Bool("csrf", true, "啟用跨站請求防護")
+	year := flag.String("year", "", "年度（西元或民國年），預設為今年")
+	port := flag.String("port", "", "監聽的連接埠，預設為環境變數 PORT 或 8080")
+	dataDir := flag.String("data-dir", "", "資料根目錄，預設為環境變數 DATA_DIR 或 ../pcc_data")
+	dbPath := flag.String("db", "", "主要年度的資料庫檔案，預設為環境變數 DB_PATH 或 <資料根目錄>/<年度>/bookmarks.db")
+	staticDir := flag.String("static-dir", "", "根路徑的靜態網頁目錄，預設為環境變數 STATIC_DIR")
+	flag.Parse()
+
+	if *configPath != "" {
+		raw, err := os.ReadFile(*configPath)
+		if err != nil {
+			return cfg, fmt.Errorf("讀取設定檔失敗: %w", err)
+		}
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("解析設定檔失敗: %w", err)
+		}
+	}
+
+	envOverrides := []struct {
+		name  string
+		field *string
+	}{
+		{"PORT", &cfg.Port},
+		{"DATA_DIR", &cfg.DataDir},
+		{"DB_PATH", &cfg.DBPath},
+		{"STATIC_DIR", &cfg.StaticDir},
+	}
+	for _, env := range envOverrides {
+		if v := os.Getenv(env.name); v != "" {
+			*env.field = v
+		}
+	}
+
+	// 只有明確指定的參數才覆寫設定檔與環境變數
+	yearSet := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "read-only":
+			cfg.ReadOnly = *readOnly
+		case "lang":
+			cfg.Language = *language
+		case "csrf":
+			cfg.CSRF = *csrf
+		case "year":
+			yearSet = true
+		case "port":
+			cfg.Port = *port
+		case "data-dir":
+			cfg.DataDir = *dataDir
+		case "db":
+			cfg.DBPath = *dbPath
+		case "static-dir":
+			cfg.StaticDir = *staticDir
+		}
+	})
+
+	if n, err := strconv.Atoi(cfg.Port); err != nil || n < 1 || n > 65535 {
+		return cfg, fmt.Errorf("連接埠格式錯誤: %q", cfg.Port)
+	}
+	// 年度目錄、預設的靜態掛載與報告目錄都以資料根目錄為基準，必須最先設定
+	if cfg.DataDir != "" {
+		dataRoot = cfg.DataDir
+	}
+
+	loc, err := loadDisplayLocation(cfg.Timezone)
+	if err != nil {
+		return cfg, err
+	}
+	displayLocation = loc
+
+	if yearSet {
+		y, err := parseYear(*year)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Year = y
+	}
+	switch {
+	case cfg.Year == 0:
+		cfg.Year = currentYear()
+	case cfg.Year < 0:
+		return cfg, fmt.Errorf("年度格式錯誤: %d", cfg.Year)
+	case cfg.Year < rocYearOffset:
+		cfg.Year += rocYearOffset
+	}
+	if cfg.StaticMounts == nil {
+		cfg.StaticMounts = defaultStaticMounts(cfg.Year)
+	}
+	if cfg.StaticDir != "" {
+		cfg.StaticMounts = withRootMount(cfg.StaticMounts, cfg.StaticDir)
+	}
+
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		cfg.DatabaseURL = url
+	}
+	if key := os.Getenv("BOOKMARK_ENCRYPTION_KEY"); key != "" {
+		cfg.EncryptionKey = key
+	}
+	if keys := os.Getenv("API_KEYS"); keys != "" {
+		cfg.APIKeys = strings.Split(keys, ",")
+	}
+	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
+		cfg.AllowedOrigins = strings.Split(origins, ",")
+	}
+	if cfg.APIKeys, err = normalizeAPIKeys(cfg.APIKeys); err != nil {
+		return cfg, err
+	}
+	if cfg.AllowedOrigins, err = normalizeOrigins(cfg.AllowedOrigins); err != nil {
+		return cfg, err
+	}
+
+	lang := matchLanguage(cfg.Language)
+	if lang == "" {
+		return cfg, fmt.Errorf("不支援的語系: %s", cfg.Language)
+	}
+	cfg.Language = lang
+
+	if cfg.BackupKeep < 0 || cfg.BackupMaxBytes < 0 {
+		return cfg, fmt.Errorf("backup_keep 與 backup_max_bytes 不可為負數")
+	}
+
+	if cfg.DownloadInterval.Duration <= 0 || cfg.DownloadMaxInterval.Duration < cfg.DownloadInterval.Duration {
+		return cfg, fmt.Errorf("download_interval 必須大於 0，且不可大於 download_max_interval")
+	}
+	if cfg.DownloadConcurrency < 1 || cfg.DownloadConcurrency > 16 {
+		return cfg, fmt.Errorf("download_concurrency 必須介於 1 到 16")
+	}
+	if cfg.TrashRetention.Duration < 0 {
+		return cfg, fmt.Errorf("trash_retention 不可為負數")
+	}
+	if cfg.UploadMaxBytes <= 0 {
+		return cfg, fmt.Errorf("upload_max_bytes 必須大於 0")
+	}
+
+	if err := cfg.WeeklyReport.validate(); err != nil {
+		return cfg, err
+	}
+	cfg.StaticMounts = cfg.WeeklyReport.mount(cfg.StaticMounts)
+	if err := validateStaticMounts(cfg.StaticMounts); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Notifications.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Telegram.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.EmailDigest.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Ingest.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.PCCG0v.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.TenderChanges.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Calendar.validate(); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Sync.validate(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
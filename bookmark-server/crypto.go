@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 加密欄位的前綴，沒有前綴的值視為尚未加密的明文，讀取時原樣回傳
+const encryptedPrefix = "enc:v1:"
+
+// 用來確認金鑰正確的固定明文，加密後存於 app_meta
+const keyCheckPlaintext = "bookmark-server key check"
+
+var encryptExistingFlag = flag.Bool("encrypt-existing", false, "以設定的金鑰加密資料庫中尚未加密的備註與資料後結束")
+
+var (
+	errWrongKey     = errors.New("加密金鑰錯誤：無法解開資料庫中的金鑰檢查值")
+	errKeyRequired  = errors.New("資料庫已加密，請設定 encryption_key 或環境變數 BOOKMARK_ENCRYPTION_KEY")
+	errDecryptField = errors.New("無法解密欄位")
+)
+
+// fieldCipher 以 AES-256-GCM 加密 note、data 欄位與事件內容
+// 欄位名稱作為附加驗證資料，避免密文被搬到其他欄位後仍能解開
+// nil 表示未啟用加密，所有方法都原樣回傳
+type fieldCipher struct {
+	aead cipher.AEAD
+}
+
+// 由 base64 編碼的 32 位元組金鑰建立，可用 openssl rand -base64 32 產生
+func newFieldCipher(encodedKey string) (*fieldCipher, error) {
+	if encodedKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("encryption_key 必須是 base64 編碼的 32 位元組金鑰")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{aead: aead}, nil
+}
+
+// 加密欄位值；空字串不加密，讓「未填寫」的判斷維持不變
+func (c *fieldCipher) seal(column, plaintext string) (string, error) {
+	if c == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(column))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// 解密欄位值；沒有加密前綴的值原樣回傳
+func (c *fieldCipher) open(column, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", errKeyRequired
+	}
+	raw, err := base64.StdEncoding.DecodeString(value[len(encryptedPrefix):])
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return "", fmt.Errorf("%w: %s", errDecryptField, column)
+	}
+	nonce, sealed := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errDecryptField, column)
+	}
+	return string(plain), nil
+}
+
+// 解密書籤的 note 與 data
+func (c *fieldCipher) openBookmark(b *Bookmark) error {
+	var err error
+	if b.Note, err = c.open("note", b.Note); err != nil {
+		return err
+	}
+	b.Data, err = c.open("data", b.Data)
+	return err
+}
+
+// 啟動時確認金鑰：金鑰錯誤或資料庫已加密卻沒有金鑰時立即失敗，不回傳無法解讀的資料
+// 第一次以金鑰啟動時寫入檢查值
+func verifyEncryptionKey(d *DB, c *fieldCipher, readOnly bool) error {
+	var check string
+	err := d.QueryRow("SELECT value FROM app_meta WHERE key = 'encryption_check'").Scan(&check)
+	switch {
+	case err == sql.ErrNoRows || (err != nil && readOnly):
+		// 唯讀開啟舊資料庫時可能還沒有 app_meta
+		check = ""
+	case err != nil:
+		return err
+	}
+
+	if check == "" {
+		if c == nil || readOnly {
+			return nil
+		}
+		sealed, err := c.seal("key_check", keyCheckPlaintext)
+		if err != nil {
+			return err
+		}
+		if _, err := d.Exec("INSERT INTO app_meta (key, value) VALUES ('encryption_check', ?)", sealed); err != nil {
+			return err
+		}
+		log.Println("已啟用欄位加密")
+	} else {
+		if c == nil {
+			return errKeyRequired
+		}
+		if plain, err := c.open("key_check", check); err != nil || plain != keyCheckPlaintext {
+			return errWrongKey
+		}
+	}
+
+	var plaintext int
+	err = d.QueryRow(`SELECT COUNT(*) FROM bookmarks
+		WHERE (note <> '' AND note NOT LIKE 'enc:v1:%') OR (data <> '' AND data NOT LIKE 'enc:v1:%')`).Scan(&plaintext)
+	if err == nil && plaintext > 0 {
+		log.Printf("警告: 有 %d 筆書籤的備註或資料尚未加密，請執行 --encrypt-existing", plaintext)
+	}
+	return nil
+}
+
+// 加密既有的明文備註、資料與事件內容，回傳處理的書籤數
+func (s *Server) encryptExisting() (int, error) {
+	c := s.db.cipher
+	if c == nil {
+		return 0, errors.New("未設定 encryption_key")
+	}
+	count := 0
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		type row struct {
+			id         int64
+			note, data string
+		}
+		var pending []row
+		rows, err := tx.Query(`SELECT id, COALESCE(note, ''), COALESCE(data, '') FROM bookmarks
+			WHERE (note <> '' AND note NOT LIKE 'enc:v1:%') OR (data <> '' AND data NOT LIKE 'enc:v1:%')`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.note, &r.data); err != nil {
+				rows.Close()
+				return err
+			}
+			pending = append(pending, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range pending {
+			note, err := c.sealIfPlain("note", r.note)
+			if err != nil {
+				return err
+			}
+			data, err := c.sealIfPlain("data", r.data)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE bookmarks SET note = ?, data = ? WHERE id = ?", note, data, r.id); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// 事件內容含有備註，一併加密
+		type event struct {
+			id      int64
+			payload string
+		}
+		var events []event
+		rows, err = tx.Query("SELECT id, payload FROM events WHERE payload IS NOT NULL AND payload NOT LIKE 'enc:v1:%'")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var e event
+			if err := rows.Scan(&e.id, &e.payload); err != nil {
+				rows.Close()
+				return err
+			}
+			events = append(events, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, e := range events {
+			payload, err := c.seal("payload", e.payload)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE events SET payload = ? WHERE id = ?", payload, e.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// 只加密尚未加密的值
+func (c *fieldCipher) sealIfPlain(column, value string) (string, error) {
+	if strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	return c.seal(column, value)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSRFGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		csrf    bool
+		allowed []string
+		method  string
+		origin  string
+		referer string
+		status  int
+	}{
+		{"forged cross-origin post", true, nil, "POST", "https://evil.example", "", http.StatusForbidden},
+		{"forged cross-origin delete", true, nil, "DELETE", "https://evil.example", "", http.StatusForbidden},
+		{"forged referer", true, nil, "POST", "", "https://evil.example/page", http.StatusForbidden},
+		{"bundled ui same origin", true, nil, "POST", "http://example.com", "", http.StatusCreated},
+		{"bundled ui referer", true, nil, "POST", "", "http://example.com/index.html", http.StatusCreated},
+		{"allowed origin", true, []string{"https://intranet.example"}, "POST", "https://intranet.example", "", http.StatusCreated},
+		{"script without origin", true, nil, "POST", "", "", http.StatusCreated},
+		{"cross-origin read", true, nil, "GET", "https://evil.example", "", http.StatusOK},
+		{"csrf disabled", false, nil, "POST", "https://evil.example", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.cfg.CSRF = tt.csrf
+			s.cfg.AllowedOrigins = tt.allowed
+			h := s.routes()
+			if tt.method == "DELETE" {
+				addTestBookmark(t, h, `{"job_number":"A001"}`)
+			}
+
+			target, body := "/api/bookmarks", ""
+			switch tt.method {
+			case "POST":
+				body = `{"job_number":"A001","title":"測試"}`
+			case "DELETE":
+				target += "?job_number=A001"
+			}
+			req := httptest.NewRequest(tt.method, target, strings.NewReader(body))
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusForbidden {
+				if resp := decodeBody(t, w); resp["error"] != "csrf_rejected" {
+					t.Errorf("error = %v, want csrf_rejected", resp["error"])
+				}
+				// 被拒絕的請求不得修改資料
+				if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")); resp["bookmarked"] != (tt.method == "DELETE") {
+					t.Errorf("被拒絕的 %s 仍修改了書籤: %v", tt.method, resp)
+				}
+			}
+		})
+	}
+}
+
+// GET 也會寫入的端點：沒有 Origin 與 Referer 時須帶 X-Requested-With 或有效的 API 金鑰
+func TestCSRFGuardAlwaysWrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		allowed bool
+	}{
+		{"download without headers", "/api/bookmarks/download?stream=false", nil, false},
+		{"websocket without headers", "/api/ws", nil, false},
+		{"download with X-Requested-With", "/api/bookmarks/download?stream=false", map[string]string{"X-Requested-With": "curl"}, true},
+		{"websocket with X-Requested-With", "/api/ws", map[string]string{"X-Requested-With": "script"}, true},
+		{"download with api key", "/api/bookmarks/download?stream=false", map[string]string{"X-API-Key": testAPIKey}, true},
+		{"download same-origin referer", "/api/bookmarks/download?stream=false", map[string]string{"Referer": "http://example.com/index.html"}, true},
+		{"websocket cross-origin", "/api/ws", map[string]string{"Origin": "https://evil.example", "X-Requested-With": "x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if tt.headers["X-API-Key"] != "" {
+				s.cfg.APIKeys = []string{testAPIKey}
+			}
+			h := s.routes()
+			req := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if !tt.allowed {
+				if w.Code != http.StatusForbidden {
+					t.Fatalf("status = %d, want 403: %s", w.Code, w.Body.String())
+				}
+				return
+			}
+			// 通過檢查後由端點處理；/api/ws 不是升級請求會回應 400，但不應是 403
+			if w.Code == http.StatusForbidden {
+				t.Fatalf("status = 403: %s", w.Body.String())
+			}
+		})
+	}
+
+	// 未帶標頭時回應 csrf_header_required，且不開始下載
+	s := newTestServer(t)
+	h := s.routes()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/bookmarks/download?stream=false", nil))
+	if resp := decodeBody(t, w); resp["error"] != "csrf_header_required" {
+		t.Fatalf("error = %v, want csrf_header_required", resp["error"])
+	}
+}
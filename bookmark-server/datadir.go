@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 啟動前檢查資料目錄
+//
+// 在錯誤的目錄下執行時，../pcc_data（或 DATA_DIR 指定的目錄）不存在，過去會直接建立空的目錄與資料庫，
+// 看起來像是書籤全部消失。現在資料目錄必須已存在，且至少有一個年度的 bookmarks.db，
+// 否則列出檢查過的路徑後結束；第一次使用時以 --init 建立。
+// 已有其他年度的資料庫時，新年度的資料庫照常自動建立（跨年時不需要 --init）。
+
+var initFlag = flag.Bool("init", false, "建立新的資料目錄與資料庫（第一次使用時）")
+
+// 檢查資料目錄；init 為 true 時建立缺少的目錄
+func checkDataDir(cfg Config, init bool) error {
+	if init {
+		for _, dir := range []string{yearDir(cfg.Year), filepath.Dir(cfg.primaryDBPath())} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		log.Println("已建立資料目錄:", absPath(yearDir(cfg.Year)))
+		return nil
+	}
+
+	var checked []string
+	check := func(path string) bool {
+		checked = append(checked, absPath(path))
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	if !check(dataRoot) {
+		return dataDirError(checked)
+	}
+	// PostgreSQL 的資料不在資料目錄中，目錄存在即可
+	if cfg.DatabaseURL != "" {
+		return nil
+	}
+	if check(cfg.primaryDBPath()) {
+		return nil
+	}
+	// 指定的資料庫檔案不存在時不改用其他年度的資料庫判斷，以免打錯路徑時建立新的空資料庫
+	if cfg.DBPath != "" {
+		return dataDirError(checked)
+	}
+	others, _ := filepath.Glob(filepath.Join(dataRoot, "[0-9][0-9][0-9][0-9]", "bookmarks.db"))
+	checked = append(checked, absPath(filepath.Join(dataRoot, "<年度>", "bookmarks.db")))
+	if len(others) > 0 {
+		log.Printf("%d 年度的資料庫不存在，將建立新的資料庫（既有年度: %s）", cfg.Year, strings.Join(others, ", "))
+		return nil
+	}
+	return dataDirError(checked)
+}
+
+func dataDirError(checked []string) error {
+	wd, _ := os.Getwd()
+	return fmt.Errorf(`找不到書籤資料（目前目錄: %s）
+檢查過的路徑:
+  %s
+請在 bookmark-server 目錄下執行（資料目錄為 ../pcc_data），或以 DATA_DIR/--data-dir 指定資料目錄、--year 指定已有資料的年度；
+第一次使用時加上 --init 建立新的資料目錄與資料庫`, wd, strings.Join(checked, "\n  "))
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var errDataNotObject = errors.New("不是 JSON 物件")
+
+// 檢查 data 欄位：空字串表示沒有資料，否則必須是 JSON 物件
+func validateData(data string) error {
+	if data == "" {
+		return nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &obj); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return errDataNotObject
+		}
+		return err
+	}
+	if obj == nil {
+		return errDataNotObject
+	}
+	return nil
+}
+
+// InvalidDataRow data 欄位無法解析的書籤
+type InvalidDataRow struct {
+	ID         int64  `json:"id"`
+	JobNumber  string `json:"job_number"`
+	Title      string `json:"title"`
+	APIURL     string `json:"api_url"`
+	DataLength int    `json:"data_length"`
+	Error      string `json:"error"`
+	Refetch    bool   `json:"refetchable"` // 有 api_url，可重新下載取代
+}
+
+// 列出 data 欄位不是合法 JSON 物件的書籤，供從 api_url 重新取得資料
+func (s *Server) invalidDataReport(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT id, job_number, title, COALESCE(api_url, ''), data FROM bookmarks WHERE data IS NOT NULL AND data <> '' ORDER BY id")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]InvalidDataRow, 0)
+	for rows.Next() {
+		var row InvalidDataRow
+		var data string
+		if err := rows.Scan(&row.ID, &row.JobNumber, &row.Title, &row.APIURL, &data); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		plain, err := s.db.cipher.open("data", data)
+		if err == nil {
+			err = validateData(plain)
+		}
+		if err != nil {
+			row.DataLength = len(data)
+			row.Error = err.Error()
+			row.Refetch = row.APIURL != ""
+			items = append(items, row)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(items),
+		"items": items,
+	})
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// data 與書籤欄位的同步
+//
+// data 保存前端加入書籤時的整筆標案，其中 title、unit_name、type、date、url、api_url 與書籤的欄位重複。
+// 以 data 為準：新增或覆寫書籤時若帶了 data，重複的欄位一律取自 data，data 缺少的鍵再以請求中的欄位補上；
+// 其他地方修改這些欄位時（例如 reconcile）以 patchBookmarkData 同時修改 data 中對應的鍵。
+// 備註與優先級不在 data 中，更新時不需要同步。job_number 是書籤的識別，不由 data 覆寫。
+// 此規則之前寫入的資料可能已經不一致，GET /api/admin/data-drift 與完整性檢查會列出。
+
+// data 中與書籤欄位重複的鍵（鍵名與欄位名稱相同）
+var dataMirroredFields = []string{"title", "unit_name", "type", "date", "url", "api_url"}
+
+// 書籤中與 data 重複的欄位
+type mirroredColumns struct {
+	Title    string
+	UnitName string
+	Type     string
+	Date     TenderDate
+	URL      string
+	APIURL   string
+}
+
+// 欄位的文字值，沒有日期時為空字串
+func (c *mirroredColumns) get(field string) string {
+	switch field {
+	case "title":
+		return c.Title
+	case "unit_name":
+		return c.UnitName
+	case "type":
+		return c.Type
+	case "date":
+		if c.Date == 0 {
+			return ""
+		}
+		return strconv.Itoa(int(c.Date))
+	case "url":
+		return c.URL
+	case "api_url":
+		return c.APIURL
+	}
+	return ""
+}
+
+// 以 data 中的值設定欄位；日期無法解析時回傳錯誤且不修改
+func (c *mirroredColumns) set(field, value string) error {
+	switch field {
+	case "title":
+		c.Title = value
+	case "unit_name":
+		c.UnitName = value
+	case "type":
+		c.Type = value
+	case "date":
+		d, err := parseTenderDate(value)
+		if err != nil {
+			return err
+		}
+		c.Date = d
+	case "url":
+		c.URL = value
+	case "api_url":
+		c.APIURL = value
+	}
+	return nil
+}
+
+// 欄位寫入 data 時的 JSON 值：日期為 YYYYMMDD 數字，其他為字串
+func (c *mirroredColumns) raw(field string) json.RawMessage {
+	if field == "date" {
+		return json.RawMessage(strconv.Itoa(int(c.Date)))
+	}
+	b, _ := json.Marshal(c.get(field))
+	return b
+}
+
+// data 中的純量值轉為文字；null、物件與陣列視為沒有值
+func dataScalar(raw json.RawMessage) (string, bool) {
+	var v interface{}
+	if len(raw) == 0 || json.Unmarshal(raw, &v) != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// 以 data（明文）為準同步重複的欄位，回傳同步後的 data
+// data 有值的鍵覆寫欄位；data 缺少或無法解析的鍵以欄位的值補上，此時 data 重新編碼，否則維持原文
+func syncDataColumns(data string, c *mirroredColumns) (string, error) {
+	if data == "" {
+		return data, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &obj); err != nil {
+		return data, err
+	}
+	patched := false
+	for _, field := range dataMirroredFields {
+		if v, ok := dataScalar(obj[field]); ok && v != "" && c.set(field, v) == nil {
+			continue
+		}
+		if c.get(field) != "" {
+			obj[field] = c.raw(field)
+			patched = true
+		}
+	}
+	if !patched {
+		return data, nil
+	}
+	b, err := json.Marshal(obj)
+	return string(b), err
+}
+
+// 修改書籤欄位後同步 data 中對應的鍵；data 為空時不處理
+func patchBookmarkData(tx *Tx, jobNumber string, c *mirroredColumns, fields ...string) error {
+	var stored string
+	if err := tx.QueryRow("SELECT COALESCE(data, '') FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&stored); err != nil {
+		return err
+	}
+	if stored == "" {
+		return nil
+	}
+	plain, err := tx.cipher.open("data", stored)
+	if err != nil {
+		return err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(plain), &obj); err != nil || obj == nil {
+		// 損毀的 data 由 invalid-data 報表處理，不在這裡覆寫
+		return nil
+	}
+	for _, field := range fields {
+		obj[field] = c.raw(field)
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	sealed, err := tx.cipher.seal("data", string(b))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE bookmarks SET data = ? WHERE job_number = ?", sealed, jobNumber)
+	return err
+}
+
+// DataFieldDrift data 與欄位不一致的鍵
+type DataFieldDrift struct {
+	Field  string `json:"field"`
+	Column string `json:"column"`
+	Data   string `json:"data"`
+}
+
+// 比對 data（明文）與欄位；data 沒有的鍵不比對，文字忽略空白差異，日期依解析後的值比對
+func dataDrift(data string, c *mirroredColumns) []DataFieldDrift {
+	var obj map[string]json.RawMessage
+	if data == "" || json.Unmarshal([]byte(data), &obj) != nil {
+		return nil
+	}
+	var drift []DataFieldDrift
+	for _, field := range dataMirroredFields {
+		v, ok := dataScalar(obj[field])
+		if !ok {
+			continue
+		}
+		column := c.get(field)
+		same := strings.Join(strings.Fields(v), " ") == strings.Join(strings.Fields(column), " ")
+		if field == "date" {
+			d, err := parseTenderDate(v)
+			same = err == nil && d == c.Date
+		}
+		if !same {
+			drift = append(drift, DataFieldDrift{Field: field, Column: column, Data: v})
+		}
+	}
+	return drift
+}
+
+// DataDriftRow data 與欄位不一致的書籤
+type DataDriftRow struct {
+	ID        int64            `json:"id"`
+	JobNumber string           `json:"job_number"`
+	Fields    []DataFieldDrift `json:"fields"`
+}
+
+// 列出 data 與欄位不一致的書籤；data 無法解密或解析的書籤不列入（見 invalid-data）
+func (s *Server) dataDriftRows(ctx context.Context) ([]DataDriftRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, job_number, title, COALESCE(unit_name, ''), COALESCE(type, ''), date,
+			COALESCE(url, ''), COALESCE(api_url, ''), data
+		FROM bookmarks WHERE data IS NOT NULL AND data <> '' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]DataDriftRow, 0)
+	for rows.Next() {
+		var row DataDriftRow
+		var c mirroredColumns
+		var data string
+		if err := rows.Scan(&row.ID, &row.JobNumber, &c.Title, &c.UnitName, &c.Type, &c.Date, &c.URL, &c.APIURL, &data); err != nil {
+			return nil, err
+		}
+		plain, err := s.db.cipher.open("data", data)
+		if err != nil {
+			continue
+		}
+		if row.Fields = dataDrift(plain, &c); len(row.Fields) > 0 {
+			items = append(items, row)
+		}
+	}
+	return items, rows.Err()
+}
+
+// GET /api/admin/data-drift：列出 data 與書籤欄位不一致的書籤
+func (s *Server) dataDriftReport(w http.ResponseWriter, r *http.Request) {
+	items, err := s.dataDriftRows(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"fields": dataMirroredFields,
+		"total":  len(items),
+		"items":  items,
+	})
+}
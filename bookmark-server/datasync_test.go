@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// 書籤欄位與 data 中對應的鍵
+func bookmarkDataFields(t *testing.T, s *Server, jobNumber string) (title string, data map[string]interface{}) {
+	t.Helper()
+	var stored string
+	if err := s.db.QueryRow("SELECT title, COALESCE(data, '') FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&title, &stored); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := s.db.cipher.open("data", stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(plain), &data); err != nil {
+		t.Fatalf("data = %s: %v", plain, err)
+	}
+	return title, data
+}
+
+func dataDriftTotal(t *testing.T, h http.Handler) float64 {
+	t.Helper()
+	return decodeBody(t, serve(t, h, "GET", "/api/admin/data-drift", ""))["total"].(float64)
+}
+
+// 新增時以 data 為準，data 缺少的鍵以請求的欄位補上
+func TestAddSyncsDataColumns(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"請求的名稱","unit_name":"臺北市政府","date":"115/01/15",
+		"data":"{\"title\":\"data 的名稱\",\"date\":\"2026-01-20\",\"budget\":100}"}`)
+
+	b := getTestBookmark(t, h, "A001")
+	if b.Title != "data 的名稱" || b.UnitName != "臺北市政府" || b.Date != 20260120 {
+		t.Errorf("欄位 = %q %q %d", b.Title, b.UnitName, b.Date)
+	}
+	_, data := bookmarkDataFields(t, s, "A001")
+	if data["unit_name"] != "臺北市政府" || data["title"] != "data 的名稱" || data["budget"] != float64(100) {
+		t.Errorf("data = %v", data)
+	}
+	if n := dataDriftTotal(t, h); n != 0 {
+		t.Errorf("data-drift total = %v", n)
+	}
+}
+
+// 更新備註不影響 data；以詳細資料更新標案名稱時 data 一併更新
+func TestUpdateNoteVersusTitle(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"舊名稱","unit_name":"臺北市政府","api_url":"https://example.invalid/tender",
+		"data":"{\"title\":\"舊名稱\",\"unit_name\":\"臺北市政府\"}"}`)
+	var before string
+	s.db.QueryRow("SELECT data FROM bookmarks WHERE job_number = 'A001'").Scan(&before)
+
+	if w := serve(t, h, "PUT", "/api/bookmarks", `{"job_number":"A001","note":"只改備註"}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", w.Code, w.Body.String())
+	}
+	var after string
+	s.db.QueryRow("SELECT data FROM bookmarks WHERE job_number = 'A001'").Scan(&after)
+	if after != before {
+		t.Errorf("更新備註改變了 data:\nbefore %s\nafter  %s", before, after)
+	}
+	if n := dataDriftTotal(t, h); n != 0 {
+		t.Errorf("更新備註後 data-drift total = %v", n)
+	}
+
+	detail := `{"records":[{"job_number":"A001","unit_name":"新北市政府","brief":{"title":"新名稱"}}]}`
+	if err := s.storeTenderCache("A001", "https://example.invalid/tender", []byte(detail), ""); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(t, h, "POST", "/api/bookmarks/A001/reconcile", ""); w.Code != http.StatusOK {
+		t.Fatalf("reconcile = %d: %s", w.Code, w.Body.String())
+	}
+	title, data := bookmarkDataFields(t, s, "A001")
+	if title != "新名稱" || data["title"] != "新名稱" || data["unit_name"] != "新北市政府" {
+		t.Errorf("title = %q, data = %v", title, data)
+	}
+	if n := dataDriftTotal(t, h); n != 0 {
+		t.Errorf("更新名稱後 data-drift total = %v", n)
+	}
+}
+
+// 直接修改欄位造成的不一致列在報表與完整性檢查中
+func TestDataDriftReport(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","data":"{\"title\":\"道路養護\",\"date\":20260115}"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","data":"{\"title\":\"資訊系統\"}"}`)
+	if _, err := s.db.Exec("UPDATE bookmarks SET title = ' 道路養護  ', date = 20260116 WHERE job_number = 'A001'"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, "GET", "/api/admin/data-drift", "")
+	var resp struct {
+		Total int            `json:"total"`
+		Items []DataDriftRow `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	// 只差在空白的名稱不算不一致
+	if resp.Total != 1 || resp.Items[0].JobNumber != "A001" || len(resp.Items[0].Fields) != 1 || resp.Items[0].Fields[0].Field != "date" {
+		t.Errorf("data-drift = %s", w.Body.String())
+	}
+
+	integrity := decodeBody(t, serve(t, h, "GET", "/api/admin/integrity", ""))
+	found := false
+	for _, p := range integrity["problems"].([]interface{}) {
+		found = found || p.(map[string]interface{})["check"] == "data_drift"
+	}
+	if !found {
+		t.Errorf("完整性檢查沒有列出 data_drift: %v", integrity)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 書籤清單的版本與條件式請求
+//
+// app_meta 的 bookmarks_version 為書籤清單的版本，bookmarks_modified_at 為最後異動的時間（Unix 毫秒）。
+// 兩者由觸發程序（遷移 29）在 bookmarksVersionTables 任何一筆新增、修改或刪除時更新，
+// 與異動在同一筆交易中提交：讀到新版本號時一定也讀得到新的資料，不會有寫入與輪詢交錯而回傳過時的 304。
+// GET /api/bookmarks 與 /api/bookmarks/list 以版本號產生強 ETag 並附上 Last-Modified，
+// If-None-Match 相符（或沒有 If-None-Match 而 If-Modified-Since 不早於最後異動）時回傳 304。
+// 版本在查詢資料之前讀取，查詢期間的異動只會讓回應比 ETag 新，下一次輪詢會重新取得。
+
+const (
+	bookmarksVersionKey  = "bookmarks_version"
+	bookmarksModifiedKey = "bookmarks_modified_at"
+)
+
+// 內容會出現在書籤清單中的資料表
+var bookmarksVersionTables = []string{"bookmarks", "bookmark_tags", "tags", "bookmark_categories", "bookmark_keywords"}
+
+// 遷移 29：初始版本與 SQLite 的觸發程序（每個資料表的新增、修改、刪除各一個）
+func bookmarksVersionSQL() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `INSERT INTO app_meta (key, value) VALUES ('%s', '1'), ('%s', CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT));`,
+		bookmarksVersionKey, bookmarksModifiedKey)
+	for _, table := range bookmarksVersionTables {
+		for _, op := range []string{"INSERT", "UPDATE", "DELETE"} {
+			fmt.Fprintf(&b, `
+			CREATE TRIGGER %s_version_%s AFTER %s ON %s BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = '%s';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = '%s';
+			END;`, table, strings.ToLower(op), op, table, bookmarksVersionKey, bookmarksModifiedKey)
+		}
+	}
+	return b.String()
+}
+
+// 遷移 29 的 PostgreSQL 版本：每個陳述式更新一次
+func bookmarksVersionPostgresSQL() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `
+		INSERT INTO app_meta (key, value) VALUES ('%[1]s', '1'), ('%[2]s', (extract(epoch FROM clock_timestamp()) * 1000)::bigint::text);
+		CREATE FUNCTION bump_bookmarks_version() RETURNS trigger AS $$
+		BEGIN
+			UPDATE app_meta SET value = (value::bigint + 1)::text WHERE key = '%[1]s';
+			UPDATE app_meta SET value = (extract(epoch FROM clock_timestamp()) * 1000)::bigint::text WHERE key = '%[2]s';
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`, bookmarksVersionKey, bookmarksModifiedKey)
+	for _, table := range bookmarksVersionTables {
+		fmt.Fprintf(&b, `
+		CREATE TRIGGER %[1]s_version AFTER INSERT OR UPDATE OR DELETE ON %[1]s
+		FOR EACH STATEMENT EXECUTE FUNCTION bump_bookmarks_version();`, table)
+	}
+	return b.String()
+}
+
+// 書籤清單目前的版本與最後異動時間；遷移 29 之前的資料庫（唯讀開啟）沒有版本，回傳 0
+func (s *Server) bookmarksVersion(ctx context.Context) (uint64, time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM app_meta WHERE key IN (?, ?)", bookmarksVersionKey, bookmarksModifiedKey)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer rows.Close()
+	var version uint64
+	var modified time.Time
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return 0, time.Time{}, err
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("app_meta.%s 格式錯誤: %q", key, value)
+		}
+		if key == bookmarksVersionKey {
+			version = uint64(n)
+		} else {
+			modified = time.UnixMilli(n)
+		}
+	}
+	return version, modified, rows.Err()
+}
+
+type bookmarksVersionContextKey struct{}
+
+// 條件式請求時讀到的版本，供 cacheMiddleware 使用；沒有時回傳 false
+func requestBookmarksVersion(r *http.Request) (uint64, bool) {
+	v, ok := r.Context().Value(bookmarksVersionContextKey{}).(uint64)
+	return v, ok
+}
+
+// 以書籤清單的版本產生 ETag 與 Last-Modified，資料未變動時回傳 304，不執行 next
+// 前端輪詢時資料沒有變動就不必重新傳送與解析整份內容
+func (s *Server) conditionalBookmarks(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			next(w, r)
+			return
+		}
+		version, modified, err := s.bookmarksVersion(r.Context())
+		if err != nil || version == 0 {
+			if err != nil {
+				log.Println("讀取書籤清單版本失敗:", err)
+			}
+			next(w, r)
+			return
+		}
+		etag := fmt.Sprintf(`"%d-%d"`, s.cfg.Year, version)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		// Last-Modified 只到秒；最後異動在一秒內時不提供，否則同一秒內的下一次異動會被 If-Modified-Since 誤判為未變動
+		if time.Since(modified) >= time.Second {
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		}
+		if notModified(r, etag, modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), bookmarksVersionContextKey{}, version)))
+	}
+}
+
+// 有 If-None-Match 時只依 ETag 判斷；否則 If-Modified-Since 不早於最後異動（以秒比較）時視為未變動
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() || time.Since(modified) < time.Second {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenderDate 標案日期，資料庫與 JSON 都以西元 YYYYMMDD 整數表示（例如 20260115），0 表示沒有日期
+// 寫入時接受整數、"2026-01-15"、"20260115" 以及 PCC 資料中偶爾出現的民國年（1150115、"115/01/15"），
+// 一律轉成西元 YYYYMMDD
+type TenderDate int
+
+// 可接受的年份範圍（民國元年起）
+const (
+	minTenderYear = rocYearOffset + 1
+	maxTenderYear = 2200
+)
+
+// 解析日期字串或數字字串
+func parseTenderDate(value string) (TenderDate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	// 有分隔符號：年-月-日，年可為西元或民國
+	if i := strings.IndexAny(value, "-/."); i >= 0 {
+		sep := value[i : i+1]
+		parts := strings.Split(value, sep)
+		if len(parts) != 3 {
+			return 0, fmt.Errorf("日期格式錯誤: %q", value)
+		}
+		nums := make([]int, 3)
+		for j, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return 0, fmt.Errorf("日期格式錯誤: %q", value)
+			}
+			nums[j] = n
+		}
+		year := nums[0]
+		if year < rocYearOffset {
+			year += rocYearOffset
+		}
+		return makeTenderDate(year, nums[1], nums[2])
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("日期格式錯誤: %q", value)
+	}
+	return tenderDateFromInt(n)
+}
+
+// 由整數轉換：8 位數為西元 YYYYMMDD，6、7 位數為民國 YYYMMDD
+func tenderDateFromInt(n int) (TenderDate, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("日期格式錯誤: %d", n)
+	}
+	year, month, day := n/10000, n/100%100, n%100
+	if n < 10000000 {
+		year += rocYearOffset
+	}
+	return makeTenderDate(year, month, day)
+}
+
+// 檢查日期是否存在（例如拒絕 20269999、20260230）
+func makeTenderDate(year, month, day int) (TenderDate, error) {
+	if year < minTenderYear || year > maxTenderYear {
+		return 0, fmt.Errorf("日期年份超出範圍: %d", year)
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return 0, fmt.Errorf("日期不存在: %04d-%02d-%02d", year, month, day)
+	}
+	return TenderDate(year*10000 + month*100 + day), nil
+}
+
+// 轉成台灣時間當天 00:00，沒有日期時回傳零值
+func (d TenderDate) Time() time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Date(int(d)/10000, time.Month(int(d)/100%100), int(d)%100, 0, 0, 0, 0, taipei)
+}
+
+// ISO 8601 日期字串，沒有日期時為空字串
+func (d TenderDate) String() string {
+	if d == 0 {
+		return ""
+	}
+	return d.Time().Format("2006-01-02")
+}
+
+// 解析 JSON：接受數字或字串
+func (d *TenderDate) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = 0
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var err error
+	switch v := v.(type) {
+	case float64:
+		if v != float64(int(v)) {
+			return fmt.Errorf("日期格式錯誤: %v", v)
+		}
+		*d, err = tenderDateFromInt(int(v))
+	case string:
+		*d, err = parseTenderDate(v)
+	default:
+		err = fmt.Errorf("日期格式錯誤: %s", b)
+	}
+	return err
+}
+
+// 讀取資料庫欄位，NULL 視為沒有日期；既有的舊資料不做驗證，原樣讀出
+func (d *TenderDate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+	case int64:
+		*d = TenderDate(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("日期欄位格式錯誤: %q", v)
+		}
+		*d = TenderDate(n)
+	default:
+		return fmt.Errorf("日期欄位型別錯誤: %T", src)
+	}
+	return nil
+}
+
+func (d TenderDate) Value() (driver.Value, error) {
+	return int64(d), nil
+}
+
+// InvalidDateRow date 欄位不是有效 YYYYMMDD 的書籤
+type InvalidDateRow struct {
+	ID        int64      `json:"id"`
+	JobNumber string     `json:"job_number"`
+	Title     string     `json:"title"`
+	Date      string     `json:"date"`      // 資料庫中的原始值
+	Suggested TenderDate `json:"suggested"` // 建議的日期，0 表示無法判斷
+	Source    string     `json:"source"`    // 建議的來源：date（民國年等可轉換的格式）、data.date 或 data.raw_data.date
+}
+
+// GET /api/admin/invalid-dates：列出 date 欄位不是有效日期（例如 2026115、20261399）的書籤，
+// 並由原值或 data 中的日期推測正確的值。沒有日期（0 或 NULL）不算錯誤
+func (s *Server) invalidDatesReport(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT id, job_number, title, CAST(date AS TEXT), COALESCE(data, '') FROM bookmarks WHERE date IS NOT NULL AND date <> 0 ORDER BY id")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]InvalidDateRow, 0)
+	for rows.Next() {
+		var row InvalidDateRow
+		var data string
+		if err := rows.Scan(&row.ID, &row.JobNumber, &row.Title, &row.Date, &data); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if n, err := strconv.Atoi(row.Date); err == nil && n >= 10000000 {
+			if d, err := tenderDateFromInt(n); err == nil && int(d) == n {
+				continue
+			}
+		}
+		if d, err := parseTenderDate(row.Date); err == nil && d != 0 {
+			row.Suggested, row.Source = d, "date"
+		} else if plain, err := s.db.cipher.open("data", data); err == nil {
+			row.Suggested, row.Source = suggestTenderDate(plain)
+		}
+		items = append(items, row)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(items),
+		"items": items,
+	})
+}
+
+// 由 data 中的日期推測標案日期（filter_tenders.py 寫入的 date，或 PCC 原始資料 raw_data.date）
+func suggestTenderDate(data string) (TenderDate, string) {
+	var blob struct {
+		Date    json.RawMessage `json:"date"`
+		RawData struct {
+			Date json.RawMessage `json:"date"`
+		} `json:"raw_data"`
+	}
+	if data == "" || json.Unmarshal([]byte(data), &blob) != nil {
+		return 0, ""
+	}
+	for _, c := range []struct {
+		raw    json.RawMessage
+		source string
+	}{{blob.Date, "data.date"}, {blob.RawData.Date, "data.raw_data.date"}} {
+		var d TenderDate
+		if len(c.raw) > 0 && d.UnmarshalJSON(c.raw) == nil && d != 0 {
+			return d, c.source
+		}
+	}
+	return 0, ""
+}
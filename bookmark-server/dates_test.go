@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestParseTenderDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  TenderDate
+		ok    bool
+	}{
+		{"", 0, true},
+		{"0", 0, true},
+		{"2026-01-15", 20260115, true},
+		{"2026/1/5", 20260105, true},
+		{"20260115", 20260115, true},
+		// 民國年
+		{"115/01/15", 20260115, true},
+		{"115.1.15", 20260115, true},
+		{"115-01-15", 20260115, true},
+		{"1150115", 20260115, true},
+		{"990115", 20100115, true},
+		{" 1150229 ", 0, false}, // 民國 115 年（2026）不是閏年
+		{"1130229", 20240229, true},
+		{"20269999", 0, false},
+		{"2026-02-30", 0, false},
+		{"2026-13-01", 0, false},
+		{"3000-01-01", 0, false},
+		{"2026-01", 0, false},
+		{"2026/01-15", 0, false},
+		{"一一五/01/15", 0, false},
+		{"-20260115", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTenderDate(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseTenderDate(%q) = %d, %v; want %d, ok=%v", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTenderDateJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want TenderDate
+		ok   bool
+	}{
+		{`20260115`, 20260115, true},
+		{`1150115`, 20260115, true},
+		{`"115/01/15"`, 20260115, true},
+		{`"2026-01-15"`, 20260115, true},
+		{`null`, 0, true},
+		{`0`, 0, true},
+		{`20260115.5`, 0, false},
+		{`true`, 0, false},
+		{`20269999`, 0, false},
+	}
+	for _, tt := range tests {
+		var d TenderDate
+		err := json.Unmarshal([]byte(tt.json), &d)
+		if (err == nil) != tt.ok || d != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v; want %d, ok=%v", tt.json, d, err, tt.want, tt.ok)
+		}
+	}
+
+	// 輸出一律為西元 YYYYMMDD 整數
+	b, _ := json.Marshal(struct {
+		Date TenderDate `json:"date"`
+	}{20260115})
+	if string(b) != `{"date":20260115}` {
+		t.Errorf("Marshal = %s", b)
+	}
+	if got := TenderDate(20260115).String(); got != "2026-01-15" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := TenderDate(0).String(); got != "" {
+		t.Errorf("String() of zero = %q", got)
+	}
+}
+
+// 新增書籤時民國年轉為西元，不存在的日期以 422 拒絕且不寫入
+func TestTenderDateOnWrite(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	for i, date := range []string{`"115/01/15"`, `1150115`, `"2026-01-15"`, `20260115`} {
+		jn := fmt.Sprintf("D%03d", i)
+		addTestBookmark(t, h, fmt.Sprintf(`{"job_number":"%s","date":%s}`, jn, date))
+		var stored int
+		if err := s.db.QueryRow("SELECT date FROM bookmarks WHERE job_number = ?", jn).Scan(&stored); err != nil || stored != 20260115 {
+			t.Errorf("date %s 存為 %d (%v)", date, stored, err)
+		}
+	}
+	for _, date := range []string{`20269999`, `"2026-02-30"`, `"115/13/01"`} {
+		w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"BAD","date":`+date+`}`)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("date %s = %d: %s", date, w.Code, w.Body.String())
+		}
+	}
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=BAD", "")); resp["bookmarked"] != false {
+		t.Errorf("無效日期的書籤被寫入: %v", resp)
+	}
+
+	// 以民國年篩選
+	w := serve(t, h, "GET", "/api/bookmarks?date_from=115-01-15&date_to=115-01-15", "")
+	var list []Bookmark
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 4 {
+		t.Errorf("民國年篩選回傳 %d 筆 (%v): %s", len(list), err, w.Body.String())
+	}
+}
+
+// 遷移將既有的民國年轉為西元，NULL 改為 0
+func TestTenderDateMigration(t *testing.T) {
+	s := newTestServer(t)
+	var sql string
+	for _, m := range migrations {
+		if m.name == "bookmarks.date roc to gregorian" {
+			sql = m.sql
+		}
+	}
+	if sql == "" {
+		t.Fatal("找不到日期遷移")
+	}
+	for jn, date := range map[string]interface{}{"R1": 1150115, "R2": 990101, "R3": nil, "R4": 20260115} {
+		if _, err := s.db.Exec("INSERT INTO bookmarks (job_number, title, date, created_at, updated_at) VALUES (?, '', ?, ?, ?)", jn, date, dbNow(), dbNow()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.db.Exec(sql); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+	for jn, want := range map[string]int{"R1": 20260115, "R2": 20100101, "R3": 0, "R4": 20260115} {
+		var got int
+		if err := s.db.QueryRow("SELECT date FROM bookmarks WHERE job_number = ?", jn).Scan(&got); err != nil || got != want {
+			t.Errorf("%s date = %d (%v), want %d", jn, got, err, want)
+		}
+	}
+}
+
+// 無效日期報表：由原值或 data 中的日期推測正確的值，有效日期與沒有日期不列出
+func TestInvalidDatesReport(t *testing.T) {
+	s := newTestServer(t)
+	rows := []struct {
+		jn   string
+		date interface{}
+		data string
+	}{
+		{"V1", 20260115, ""},
+		{"V2", 0, ""},
+		{"I1", 1150115, ""},
+		{"I2", 20261399, `{"date":"2026-01-20"}`},
+		{"I3", 20261399, `{"raw_data":{"date":1150121}}`},
+		{"I4", 2026115, ""},
+	}
+	for _, r := range rows {
+		if _, err := s.db.Exec("INSERT INTO bookmarks (job_number, title, date, data, created_at, updated_at) VALUES (?, '', ?, ?, ?, ?)", r.jn, r.date, r.data, dbNow(), dbNow()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := serve(t, s.routes(), "GET", "/api/admin/invalid-dates", "")
+	var resp struct {
+		Total int              `json:"total"`
+		Items []InvalidDateRow `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	want := map[string]struct {
+		suggested TenderDate
+		source    string
+	}{
+		"I1": {20260115, "date"},
+		"I2": {20260120, "data.date"},
+		"I3": {20260121, "data.raw_data.date"},
+		"I4": {0, ""},
+	}
+	if resp.Total != len(want) {
+		t.Errorf("total = %d, want %d: %s", resp.Total, len(want), w.Body.String())
+	}
+	for _, item := range resp.Items {
+		w, ok := want[item.JobNumber]
+		if !ok {
+			t.Errorf("%s 不應列出", item.JobNumber)
+			continue
+		}
+		if item.Suggested != w.suggested || item.Source != w.source {
+			t.Errorf("%s suggested = %d (%s), want %d (%s)", item.JobNumber, item.Suggested, item.Source, w.suggested, w.source)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bookmark-server/store"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// 資料庫種類
+type dialect string
+
+const (
+	dialectSQLite   dialect = "sqlite"
+	dialectPostgres dialect = "postgres"
+)
+
+// 將 ? 佔位符轉成 PostgreSQL 的 $1, $2...（略過字串常值中的 ?）
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	inString := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			inString = !inString
+			b.WriteRune(c)
+		case c == '?' && !inString:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// 將以 SQLite 語法撰寫的結構定義轉成目前資料庫的語法
+func (d dialect) ddl(schema string) string {
+	if d != dialectPostgres {
+		return schema
+	}
+	return strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+		"DATETIME", "TIMESTAMPTZ",
+	).Replace(schema)
+}
+
+// DB 包裝 *sql.DB，依資料庫種類自動轉換佔位符
+type DB struct {
+	*sql.DB
+	dialect dialect
+
+	// 寫入閘門：一般寫入取得讀鎖可互相並行，維護作業取得寫鎖以暫停所有寫入
+	gate *sync.RWMutex
+
+	// 欄位加密，nil 表示未啟用
+	cipher *fieldCipher
+
+	// SQLite 的單一寫入者，PostgreSQL 為 nil
+	writer *writer
+}
+
+func newDB(conn *sql.DB, d dialect) *DB {
+	db := &DB{DB: conn, dialect: d, gate: &sync.RWMutex{}}
+	if d == dialectSQLite {
+		db.startWriter()
+	}
+	return db
+}
+
+// 停止寫入者後關閉連線
+func (d *DB) Close() error {
+	if d.writer != nil {
+		d.writer.stop()
+	}
+	return d.DB.Close()
+}
+
+func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(d.dialect.rebind(query), args...)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.QueryContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRowContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.ExecContext(ctx, d.dialect.rebind(query), args...)
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return d.DB.PrepareContext(ctx, d.dialect.rebind(query))
+}
+
+func (d *DB) Begin() (*Tx, error) {
+	return d.BeginTx(context.Background(), nil)
+}
+
+func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := d.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: d.dialect, cipher: d.cipher}, nil
+}
+
+// 在交易中執行 fn：fn 回傳錯誤或 panic 時回滾，否則提交
+// 所有會寫入多個資料列或多張表的操作都應透過此函式，避免中途失敗留下不一致的資料
+// 交易期間持有寫入閘門的讀鎖，維護作業可藉此暫停所有寫入
+// SQLite 交由單一寫入者執行（見 writer.go），fn 可能與其他寫入合併在同一個交易中，
+// 因此 fn 內不可再呼叫 withTx，也不應有交易以外的副作用
+func (d *DB) withTx(ctx context.Context, fn func(tx *Tx) error) error {
+	if d.writer != nil {
+		return d.writer.submit(ctx, fn)
+	}
+	d.gate.RLock()
+	defer d.gate.RUnlock()
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Tx 包裝 *sql.Tx，依資料庫種類自動轉換佔位符
+type Tx struct {
+	*sql.Tx
+	dialect dialect
+	cipher  *fieldCipher
+}
+
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, t.dialect.rebind(query), args...)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, t.dialect.rebind(query), args...)
+}
+
+// 開啟資料庫：設定 DATABASE_URL 為 postgres:// 時使用 PostgreSQL，否則使用 SQLite 檔案
+// 回傳值 location 為紀錄用的位置描述（不含密碼）
+// dbPath 為 ":memory:" 時開啟記憶體資料庫（測試用）
+func openDB(cfg Config, dbPath string) (d *DB, location string, err error) {
+	if strings.HasPrefix(cfg.DatabaseURL, "postgres://") || strings.HasPrefix(cfg.DatabaseURL, "postgresql://") {
+		return openPostgres(cfg.DatabaseURL, cfg.ReadOnly)
+	}
+	if cfg.DatabaseURL != "" {
+		return nil, "", fmt.Errorf("不支援的 DATABASE_URL: 僅支援 postgres://")
+	}
+	if dbPath == ":memory:" {
+		return openMemory()
+	}
+	return openSQLite(dbPath, cfg.ReadOnly)
+}
+
+func openPostgres(url string, readOnly bool) (*DB, string, error) {
+	dsn := url
+	if readOnly {
+		// 以連線參數讓所有交易預設為唯讀
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "default_transaction_read_only=on"
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	location := url
+	if i := strings.LastIndex(location, "@"); i >= 0 {
+		location = "postgres://***" + location[i:]
+	}
+	return newDB(conn, dialectPostgres), location, nil
+}
+
+// 使用純 Go 的 modernc.org/sqlite，不需要 CGO，可直接交叉編譯到 ARM NAS
+// 與 mattn/go-sqlite3 的差異：
+//   - pragma 以 _pragma=name(value) 指定，由驅動在每條新連線上執行
+//   - 寫入 time.Time 時預設格式為 "2006-01-02 15:04:05.999999999 -0700 MST"，
+//     以 _time_format=sqlite 改為與 mattn 相同的 "2006-01-02 15:04:05.999999999-07:00"
+//   - 宣告為 DATETIME 的欄位兩者都會解析成 time.Time；CURRENT_TIMESTAMP 產生的
+//     "2006-01-02 15:04:05" 解析結果為 UTC，行為一致
+//   - 結尾為 Z 的 RFC 3339 文字（本專案的寫入格式，見 timestamps.go）兩者都解析為 UTC
+//   - MAX() 等聚合結果沒有宣告型別，兩者都回傳字串
+func openSQLite(dbPath string, readOnly bool) (*DB, string, error) {
+	// 唯讀模式：不建立目錄，以 mode=ro 開啟
+	dsn := "file:" + dbPath + "?mode=ro&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	if !readOnly {
+		// 確保目錄存在
+		os.MkdirAll(filepath.Dir(dbPath), 0755)
+		// WAL 讓長時間的讀取（例如匯出）不會擋住寫入
+		dsn = "file:" + dbPath + "?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_time_format=sqlite"
+	}
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	d := newDB(conn, dialectSQLite)
+	if !readOnly {
+		if err := d.verifyPragmas(); err != nil {
+			d.Close()
+			return nil, "", err
+		}
+	}
+	d.logPragmas()
+	return d, dbPath, nil
+}
+
+// 記憶體資料庫：每條連線各自獨立，因此只使用單一連線
+// 沒有 WAL，也不需要 busy_timeout
+func openMemory() (*DB, string, error) {
+	conn, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)&_time_format=sqlite")
+	if err != nil {
+		return nil, "", err
+	}
+	conn.SetMaxOpenConns(1)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	return newDB(conn, dialectSQLite), ":memory:", nil
+}
+
+// 確認連線 pragma 已生效
+func (d *DB) verifyPragmas() error {
+	var journalMode string
+	if err := d.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		return err
+	}
+	if !strings.EqualFold(journalMode, "wal") {
+		return fmt.Errorf("無法啟用 WAL 模式，目前為 %s", journalMode)
+	}
+	var foreignKeys int
+	if err := d.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if foreignKeys != 1 {
+		return fmt.Errorf("無法啟用外鍵檢查")
+	}
+	return nil
+}
+
+// 記錄目前的資料庫設定
+func (d *DB) logPragmas() {
+	var journalMode string
+	var synchronous, busyTimeout, foreignKeys int
+	d.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	d.QueryRow("PRAGMA synchronous").Scan(&synchronous)
+	d.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout)
+	d.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys)
+	log.Printf("資料庫設定: journal_mode=%s synchronous=%d busy_timeout=%dms foreign_keys=%d",
+		journalMode, synchronous, busyTimeout, foreignKeys)
+}
+
+// 建立書籤表的存取（見 store 套件），啟動時準備常用查詢，重複使用避免每次重新解析 SQL
+func (s *Server) openBookmarkStore() error {
+	bookmarks, err := store.New(context.Background(), s.db, func(rows *sql.Rows) (Bookmark, error) {
+		b, err := scanBookmark(rows, s.db.cipher)
+		if err != nil {
+			log.Printf("讀取書籤失敗（id=%d）: %v", b.ID, err)
+		}
+		return b, err
+	})
+	if err != nil {
+		return err
+	}
+	s.bookmarks = bookmarks
+	return nil
+}
+
+// 關閉資料庫；SQLite 非唯讀時先做 WAL checkpoint，避免 -wal 檔無限成長
+// 其他年度的資料庫也一併關閉
+func (s *Server) close() {
+	s.years.mu.Lock()
+	for year, ys := range s.years.servers {
+		ys.closeDB()
+		delete(s.years.servers, year)
+	}
+	s.years.mu.Unlock()
+	s.closeDB()
+}
+
+func (s *Server) closeDB() {
+	s.stats.stop()
+	s.bookmarks.Close()
+	if s.db.dialect == dialectSQLite && !s.cfg.ReadOnly {
+		if _, err := s.db.ExecContext(context.Background(), "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			log.Println("WAL checkpoint 失敗:", err)
+		}
+	}
+	if err := s.db.Close(); err != nil {
+		log.Println("關閉資料庫失敗:", err)
+	}
+	log.Println("資料庫已關閉:", s.dbPath)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenSQLitePragmas(t *testing.T) {
+	d, _, err := openSQLite(filepath.Join(t.TempDir(), "bookmarks.db"), false)
+	if err != nil {
+		t.Fatalf("openSQLite: %v", err)
+	}
+	defer d.Close()
+
+	tests := []struct {
+		pragma string
+		want   string
+	}{
+		{"journal_mode", "wal"},
+		{"synchronous", "1"}, // NORMAL
+		{"busy_timeout", "5000"},
+		{"foreign_keys", "1"},
+	}
+	for _, tt := range tests {
+		var got string
+		if err := d.QueryRow("PRAGMA " + tt.pragma).Scan(&got); err != nil {
+			t.Fatalf("PRAGMA %s: %v", tt.pragma, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.pragma, got, tt.want)
+		}
+	}
+}
+
+func TestReadDuringLongWrite(t *testing.T) {
+	s := newFileTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"既有"}`)
+
+	// 寫入交易新增一筆書籤後停住，直到讀取完成
+	inTx := make(chan struct{})
+	release := make(chan struct{})
+	writeDone := make(chan error, 1)
+	go func() {
+		writeDone <- s.db.withTx(context.Background(), func(tx *Tx) error {
+			if _, err := tx.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, ?, ?, ?)", "A002", "寫入中", dbNow(), dbNow()); err != nil {
+				return err
+			}
+			close(inTx)
+			<-release
+			return nil
+		})
+	}()
+	<-inTx
+
+	start := time.Now()
+	resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("寫入交易進行中讀取花了 %s", elapsed)
+	}
+	if resp["count"] != float64(1) {
+		t.Errorf("讀到尚未提交的資料: count = %v", resp["count"])
+	}
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")); resp["bookmarked"] != true {
+		t.Errorf("寫入交易進行中無法讀取既有書籤: %v", resp)
+	}
+
+	close(release)
+	if err := <-writeDone; err != nil {
+		t.Fatalf("寫入交易失敗: %v", err)
+	}
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", "")); resp["count"] != float64(2) {
+		t.Errorf("提交後 count = %v, want 2", resp["count"])
+	}
+}
+
+func TestCloseCheckpointsWAL(t *testing.T) {
+	oldRoot := dataRoot
+	dataRoot = t.TempDir()
+	defer func() { dataRoot = oldRoot }()
+	path := filepath.Join(dataRoot, "bookmarks.db")
+
+	s, err := newServer(testConfig(), path)
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	h := s.routes()
+	for _, jn := range []string{"A001", "A002", "A003"} {
+		if w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"`+jn+`","title":"測試"}`); w.Code != http.StatusCreated {
+			t.Fatalf("新增 %s 回應 %d", jn, w.Code)
+		}
+	}
+	if fi, err := os.Stat(path + "-wal"); err != nil || fi.Size() == 0 {
+		t.Fatalf("寫入後應有 -wal 檔: %v", err)
+	}
+
+	s.close()
+	if fi, err := os.Stat(path + "-wal"); err == nil && fi.Size() > 0 {
+		t.Errorf("關閉後 -wal 檔仍有 %d bytes", fi.Size())
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	tests := []struct {
+		d     dialect
+		query string
+		want  string
+	}{
+		{dialectSQLite, "SELECT * FROM bookmarks WHERE job_number = ? AND date > ?", "SELECT * FROM bookmarks WHERE job_number = ? AND date > ?"},
+		{dialectPostgres, "SELECT * FROM bookmarks WHERE job_number = ? AND date > ?", "SELECT * FROM bookmarks WHERE job_number = $1 AND date > $2"},
+		{dialectPostgres, "SELECT '?' || note FROM bookmarks WHERE id = ?", "SELECT '?' || note FROM bookmarks WHERE id = $1"},
+		{dialectPostgres, "SELECT 1", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.rebind(tt.query); got != tt.want {
+			t.Errorf("%s rebind(%q) = %q, want %q", tt.d, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDialectDDL(t *testing.T) {
+	schema := "CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, created_at DATETIME NOT NULL)"
+	if got := dialectSQLite.ddl(schema); got != schema {
+		t.Errorf("sqlite ddl changed the schema: %s", got)
+	}
+	if got, want := dialectPostgres.ddl(schema), "CREATE TABLE t (id BIGSERIAL PRIMARY KEY, created_at TIMESTAMPTZ NOT NULL)"; got != want {
+		t.Errorf("postgres ddl = %s, want %s", got, want)
+	}
+}
+
+func TestOpenDBRejectsUnknownURL(t *testing.T) {
+	cfg := testConfig()
+	cfg.DatabaseURL = "mysql://user@localhost/bookmarks"
+	if d, _, err := openDB(cfg, ":memory:"); err == nil {
+		d.Close()
+		t.Fatal("openDB 接受了 mysql:// 的 DATABASE_URL")
+	}
+}
+
+// 兩種資料庫的新增／更新語意與分頁必須一致
+// PostgreSQL 只在設定 TEST_DATABASE_URL 時測試，每次使用新的 schema
+func TestBackendParity(t *testing.T) {
+	engines := map[string]func(t *testing.T) *Server{
+		"sqlite": newTestServer,
+		"postgres": func(t *testing.T) *Server {
+			url := os.Getenv("TEST_DATABASE_URL")
+			if url == "" {
+				t.Skip("未設定 TEST_DATABASE_URL")
+			}
+			conn, err := sql.Open("postgres", url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			schema := fmt.Sprintf("bookmark_test_%d", time.Now().UnixNano())
+			if _, err := conn.Exec("CREATE SCHEMA " + schema); err != nil {
+				t.Fatalf("建立 schema: %v", err)
+			}
+			t.Cleanup(func() {
+				if conn, err := sql.Open("postgres", url); err == nil {
+					conn.Exec("DROP SCHEMA " + schema + " CASCADE")
+					conn.Close()
+				}
+			})
+			sep := "?"
+			if strings.Contains(url, "?") {
+				sep = "&"
+			}
+			oldRoot := dataRoot
+			dataRoot = t.TempDir()
+			t.Cleanup(func() { dataRoot = oldRoot })
+			cfg := testConfig()
+			cfg.DatabaseURL = url + sep + "search_path=" + schema
+			s, err := newServer(cfg, "")
+			if err != nil {
+				t.Fatalf("newServer: %v", err)
+			}
+			t.Cleanup(s.close)
+			return s
+		},
+	}
+	for name, newEngine := range engines {
+		t.Run(name, func(t *testing.T) {
+			h := newEngine(t).routes()
+
+			// 重複新增視為更新：保留未提供的使用者欄位，version 遞增
+			if w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"A001","title":"舊","note":"保留","priority":3}`); w.Code != http.StatusCreated {
+				t.Fatalf("add = %d: %s", w.Code, w.Body.String())
+			}
+			if w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"A001","title":"新"}`); w.Code != http.StatusOK {
+				t.Fatalf("re-add = %d: %s", w.Code, w.Body.String())
+			}
+			b := getTestBookmark(t, h, "A001")
+			if b.Title != "新" || b.Note != "保留" || b.Priority != 3 || b.Version != 2 {
+				t.Fatalf("upsert = %+v", b)
+			}
+
+			for i := 2; i <= 5; i++ {
+				addTestBookmark(t, h, fmt.Sprintf(`{"job_number":"A%03d","priority":%d}`, i, i))
+			}
+			resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks?page=2&page_size=2", ""))
+			items, _ := resp["items"].([]interface{})
+			var got []string
+			for _, item := range items {
+				got = append(got, item.(map[string]interface{})["job_number"].(string))
+			}
+			if resp["total"] != float64(5) || !equalStrings(got, []string{"A003", "A001"}) {
+				t.Fatalf("page 2 = total %v, %v", resp["total"], got)
+			}
+		})
+	}
+}
+
+// 交易中的函式回傳錯誤或 panic 時，已執行的陳述都回滾
+func TestWithTxRollback(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	insert := func(tx *Tx, jn string) {
+		if _, err := tx.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, ?, ?, ?)", jn, "交易測試", dbNow(), dbNow()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	injected := fmt.Errorf("injected")
+	if err := s.db.withTx(ctx, func(tx *Tx) error {
+		insert(tx, "T001")
+		return injected
+	}); err != injected {
+		t.Errorf("withTx = %v, want injected error", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "injected panic" {
+				t.Errorf("recover() = %v", p)
+			}
+		}()
+		s.db.withTx(ctx, func(tx *Tx) error {
+			insert(tx, "T002")
+			panic("injected panic")
+		})
+	}()
+
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks").Scan(&n); err != nil || n != 0 {
+		t.Errorf("回滾後仍有 %d 筆書籤 (%v)", n, err)
+	}
+	// 回滾後連線可繼續使用
+	if err := s.db.withTx(ctx, func(tx *Tx) error { insert(tx, "T003"); return nil }); err != nil {
+		t.Errorf("withTx after rollback: %v", err)
+	}
+}
+
+// 多步驟的寫入在最後一步（稽核紀錄）失敗時，書籤、事件與稽核都維持原狀
+func TestMultiStatementWritesAreAtomic(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{"add", "POST", "/api/bookmarks", `{"job_number":"N001"}`},
+		{"update via add", "POST", "/api/bookmarks", `{"job_number":"A001","note":"改過"}`},
+		{"bulk add", "POST", "/api/bookmarks/bulk", `[{"job_number":"N001"},{"job_number":"A002","priority":5}]`},
+		{"import overwrite", "POST", "/api/bookmarks/import?mode=overwrite", `[{"job_number":"N001","title":"新"},{"job_number":"A001","title":"舊","note":"匯入"}]`},
+		{"import merge", "POST", "/api/bookmarks/import?mode=merge", `[{"job_number":"A001","title":"舊","note":"合併","created_at":"2099-01-01T00:00:00Z"}]`},
+		{"delete", "DELETE", "/api/bookmarks?job_number=A001", ""},
+		{"bulk delete", "DELETE", "/api/bookmarks/bulk", `{"job_numbers":["A001","A002"]}`},
+		{"restore", "POST", "/api/bookmarks/restore?job_number=T001", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			h := s.routes()
+			addTestBookmark(t, h, `{"job_number":"A001","note":"原本"}`)
+			addTestBookmark(t, h, `{"job_number":"A002"}`)
+			addTestBookmark(t, h, `{"job_number":"T001"}`)
+			serve(t, h, "DELETE", "/api/bookmarks?job_number=T001", "")
+
+			before := dbFingerprint(t, s)
+			if _, err := s.db.Exec("CREATE TRIGGER fail_audit BEFORE INSERT ON audit_log BEGIN SELECT RAISE(ABORT, 'injected'); END"); err != nil {
+				t.Fatal(err)
+			}
+			w := serve(t, h, tt.method, tt.target, tt.body)
+			if w.Code < 400 {
+				t.Errorf("%s %s = %d，預期失敗: %s", tt.method, tt.target, w.Code, w.Body.String())
+			}
+			if after := dbFingerprint(t, s); after != before {
+				t.Errorf("失敗的請求留下了部分寫入:\nbefore: %s\nafter:  %s", before, after)
+			}
+		})
+	}
+}
+
+// 書籤、事件與稽核紀錄的內容摘要
+func dbFingerprint(t *testing.T, s *Server) string {
+	t.Helper()
+	var b strings.Builder
+	rows, err := s.db.Query("SELECT job_number, note, priority, version, deleted_at IS NULL FROM bookmarks ORDER BY job_number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var jn, note string
+		var priority, version int
+		var active bool
+		if err := rows.Scan(&jn, &note, &priority, &version, &active); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&b, "%s/%s/%d/v%d/%v ", jn, note, priority, version, active)
+	}
+	var events, audits int
+	s.db.QueryRow("SELECT COUNT(*) FROM events").Scan(&events)
+	s.db.QueryRow("SELECT COUNT(*) FROM audit_log").Scan(&audits)
+	fmt.Fprintf(&b, "events=%d audit=%d", events, audits)
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discord 通知服務
+// 以 webhook 送出 embed：標題連到標案，欄位為機關、截止投標、優先順序與符合的關鍵字，書籤備註為說明文字；url 為 webhook 網址。
+// Discord 限制訊息內文 2000 字、每則最多 10 個 embed 且 embed 合計 6000 字，超過時依字元（rune）截斷或分成多則送出。
+// 除了 429 的 Retry-After 之外，回應的 X-RateLimit-Remaining 為 0 時，下一則等到 X-RateLimit-Reset-After 之後才送出。
+
+const (
+	discordMaxContent     = 2000
+	discordMaxEmbeds      = 10
+	discordMaxEmbedsTotal = 6000
+	discordMaxTitle       = 256
+	discordMaxDescription = 2000 // Discord 上限為 4096，備註只顯示前 2000 字
+	discordMaxFieldValue  = 1024
+)
+
+// 各觸發條件的 embed 顏色
+var discordColors = map[string]int{
+	triggerWatchlistBookmark: 0xF1C40F,
+	triggerTenderChanged:     0x3498DB,
+	triggerDeadline:          0xE74C3C,
+	triggerTest:              0x2ECC71,
+}
+
+type discordNotifier struct {
+	url          string
+	retryBackoff time.Duration
+
+	mu      sync.Mutex
+	resetAt time.Time // 額度用完時，下一則最早可送出的時間
+}
+
+func newDiscordNotifier(cfg ProviderConfig) (*discordNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("未設定 Discord webhook 網址（url）")
+	}
+	return &discordNotifier{url: cfg.URL, retryBackoff: notifyRetryBackoff}, nil
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	URL         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      *discordEmbedFooter `json:"footer,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+type discordMessage struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds"`
+}
+
+// embed 計入 6000 字上限的長度
+func (e *discordEmbed) size() int {
+	n := len([]rune(e.Title)) + len([]rune(e.Description))
+	for _, f := range e.Fields {
+		n += len([]rune(f.Name)) + len([]rune(f.Value))
+	}
+	if e.Footer != nil {
+		n += len([]rune(e.Footer.Text))
+	}
+	return n
+}
+
+// 依上限分成多則送出；任一則失敗時回傳最後的錯誤
+func (dc *discordNotifier) send(ctx context.Context, d *delivery) (int, error) {
+	var status int
+	var lastErr error
+	msgs := discordMessagesFor(d)
+	for i, msg := range msgs {
+		code, err := dc.post(ctx, msg)
+		status = code
+		if err != nil && len(msgs) > 1 {
+			err = fmt.Errorf("第 %d/%d 則: %w", i+1, len(msgs), err)
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return status, lastErr
+}
+
+func (dc *discordNotifier) post(ctx context.Context, msg *discordMessage) (int, error) {
+	if err := dc.waitForQuota(ctx); err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := postNotification(ctx, dc.retryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", dc.url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if resp.Header != nil {
+		dc.updateQuota(resp.Header)
+	}
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status < 200 || resp.Status > 299 {
+		// 錯誤回應為 {"message": "Invalid Webhook Token", "code": 50027}
+		var payload struct {
+			Message string `json:"message"`
+		}
+		text := strings.TrimSpace(string(resp.Body))
+		if json.Unmarshal(resp.Body, &payload) == nil && payload.Message != "" {
+			text = payload.Message
+		}
+		return resp.Status, fmt.Errorf("Discord 回應 %d: %s", resp.Status, truncateMessage(text))
+	}
+	return resp.Status, nil
+}
+
+// 額度用完時等到重置
+func (dc *discordNotifier) waitForQuota(ctx context.Context) error {
+	dc.mu.Lock()
+	wait := time.Until(dc.resetAt)
+	dc.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	if wait > notifyMaxRetryWait {
+		wait = notifyMaxRetryWait
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 依回應標頭記錄額度：X-RateLimit-Remaining 為 0 時，X-RateLimit-Reset-After（秒，可有小數）後才能再送
+func (dc *discordNotifier) updateQuota(h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	secs, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil || secs <= 0 {
+		return
+	}
+	dc.mu.Lock()
+	dc.resetAt = time.Now().Add(time.Duration(secs * float64(time.Second)))
+	dc.mu.Unlock()
+}
+
+// 產生訊息：每則通知一個 embed，超過 embed 數量或合計字數上限時分成多則；內文為標題列，略過的則數附註在第一則
+func discordMessagesFor(d *delivery) []*discordMessage {
+	content := notificationHeadline(d.Items[0])
+	if len(d.Items) > 1 {
+		content = fmt.Sprintf("政府採購書籤通知：%d 則", len(d.Items))
+	}
+	if note := d.skippedNote(); note != "" {
+		content += "\n" + note
+	}
+
+	var msgs []*discordMessage
+	var cur *discordMessage
+	total := 0
+	for _, n := range d.Items {
+		e := discordEmbedFor(n, len(d.Items) > 1)
+		if cur == nil || len(cur.Embeds) == discordMaxEmbeds || total+e.size() > discordMaxEmbedsTotal {
+			cur = &discordMessage{}
+			msgs = append(msgs, cur)
+			total = 0
+		}
+		cur.Embeds = append(cur.Embeds, e)
+		total += e.size()
+	}
+	msgs[0].Content = truncateRunes(content, discordMaxContent)
+	return msgs
+}
+
+func discordEmbedFor(n *Notification, withHeadline bool) discordEmbed {
+	e := discordEmbed{
+		Title:       truncateRunes(n.Title, discordMaxTitle),
+		URL:         n.URL,
+		Description: truncateRunes(n.Note, discordMaxDescription),
+		Color:       discordColors[n.Trigger],
+	}
+	if withHeadline {
+		// 摘要中各則的觸發原因
+		e.Description = truncateRunes(strings.TrimSpace(notificationHeadline(n)+"\n"+n.Note), discordMaxDescription)
+	}
+	field := func(name, value string, inline bool) {
+		if value != "" {
+			e.Fields = append(e.Fields, discordEmbedField{Name: name, Value: truncateRunes(value, discordMaxFieldValue), Inline: inline})
+		}
+	}
+	field("機關", n.UnitName, true)
+	field("截止投標", n.DeadlineText, true)
+	if n.Priority != 0 {
+		field("優先順序", strconv.Itoa(n.Priority), true)
+	}
+	field("符合的關鍵字", strings.Join(n.Keywords, "、"), false)
+	if n.JobNumber != "" {
+		e.Footer = &discordEmbedFooter{Text: "標案案號 " + n.JobNumber}
+	}
+	return e
+}
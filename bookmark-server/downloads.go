@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 下載書籤的標書
+//
+// POST /api/bookmarks/download 在背景下載所有書籤的標案詳細資料，立即回傳 job_id；
+// GET /api/bookmarks/download/status?job_id= 回傳待處理、成功、失敗的筆數與目前為止的逐筆結果；
+// DELETE /api/bookmarks/download?job_id= 取消，正在下載的項目完成後停止，其餘維持待處理。
+// 同一年度同時只執行一個下載工作，重複開始時回傳 409 與執行中的 job_id。
+// 工作狀態只存於記憶體，結束後保留 downloadJobRetention，重新啟動伺服器後不再能查詢（稽核紀錄與事件仍在）。
+//
+// 每個工作以 DownloadConcurrency 個 goroutine 同時下載，請求間隔仍由 downloadPacer 控制。
+// 空白或不是標案資料的回應視為失敗（見 fetchTender），檔案先寫入暫存檔再 rename，取消或失敗時不會留下寫到一半或空白的 JSON；
+// 已取得內容的項目在取消後仍會寫完，不會只有附件沒有檔案。
+// GET /api/bookmarks/download 保留原本的同步模式（NDJSON 逐筆輸出，見 downloadBookmarkedTenders）。
+
+const (
+	downloadJobRetention = time.Hour
+	downloadJobMaxKept   = 20
+)
+
+// 下載狀態
+const (
+	downloadPending = "pending"
+	downloadSuccess = "success"
+	downloadError   = "error"
+)
+
+type downloadTask struct {
+	JobNumber string
+	Title     string
+	UnitName  string
+	APIURL    string
+}
+
+// 一筆書籤的下載結果
+type downloadResult struct {
+	JobNumber string      `json:"job_number"`
+	Title     string      `json:"title"`
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	File      string      `json:"file,omitempty"`
+	Source    string      `json:"source,omitempty"`
+	Mismatch  []FieldDiff `json:"mismatch,omitempty"` // 詳細資料屬於其他標案時的差異
+}
+
+// 下載的摘要
+type downloadSummary struct {
+	results    []downloadResult
+	mismatches int
+	canceled   bool
+	throttled  time.Duration
+}
+
+// 讀取要下載的書籤，依優先順序；回傳無法讀取而略過的筆數
+func (s *Server) loadDownloadTasks(ctx context.Context) ([]downloadTask, int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT job_number, title, COALESCE(unit_name, ''), COALESCE(api_url, '')
+		FROM bookmarks
+		WHERE deleted_at IS NULL
+		ORDER BY priority DESC, created_at DESC
+	`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var tasks []downloadTask
+	warnings := 0
+	for rows.Next() {
+		var t downloadTask
+		if err := rows.Scan(&t.JobNumber, &t.Title, &t.UnitName, &t.APIURL); err != nil {
+			log.Printf("讀取下載任務失敗（job_number=%s）: %v", t.JobNumber, err)
+			warnings++
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, warnings, rows.Err()
+}
+
+// 以 DownloadConcurrency 個 goroutine 下載，每完成一筆呼叫 emit（可能同時呼叫，由呼叫端自行同步）
+// ctx 取消時不再開始新的項目，回傳的 results 只含已處理的項目
+func (s *Server) runDownloads(ctx context.Context, r *http.Request, tasks []downloadTask, emit func(downloadResult)) downloadSummary {
+	downloadDir := s.tendersDir()
+	os.MkdirAll(downloadDir, 0755)
+
+	var mu sync.Mutex
+	var sum downloadSummary
+	pacer := newDownloadPacer(s.cfg)
+	runPaced(ctx, s.cfg.DownloadConcurrency, tasks, func(task downloadTask) {
+		result, ok := s.downloadTender(ctx, r, pacer, downloadDir, task)
+		if !ok {
+			return // 等待途中取消，維持待處理
+		}
+		mu.Lock()
+		sum.results = append(sum.results, result)
+		if result.Mismatch != nil {
+			sum.mismatches++
+		}
+		mu.Unlock()
+		emit(result)
+	})
+
+	sum.canceled = ctx.Err() != nil && len(sum.results) < len(tasks)
+	sum.throttled = pacer.throttled.Round(time.Millisecond)
+	if sum.throttled > 0 {
+		log.Printf("下載標書因上游限流或錯誤共多等待 %s", sum.throttled)
+	}
+	return sum
+}
+
+// 以 concurrency 個 goroutine 處理 tasks（下載標書、更新標案資料等會向 PCC API 發出請求的工作），
+// 請求的間隔由 work 共用的 downloadPacer 控制；ctx 取消時不再開始新的項目，work 可能同時呼叫
+func runPaced[T any](ctx context.Context, concurrency int, tasks []T, work func(T)) {
+	queue := make(chan T)
+	var wg sync.WaitGroup
+	for range min(concurrency, max(len(tasks), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				work(task)
+			}
+		}()
+	}
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case queue <- task:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// 下載一筆書籤的標書；ctx 在發出請求之前取消時回傳 false
+func (s *Server) downloadTender(ctx context.Context, r *http.Request, pacer *downloadPacer, downloadDir string, task downloadTask) (downloadResult, bool) {
+	result := downloadResult{JobNumber: task.JobNumber, Title: task.Title, Status: downloadPending}
+	if !s.canFetchDetail(task.APIURL) {
+		result.Status, result.Error = downloadError, localize(requestLanguage(r), "missing_api_url")
+		return result, true
+	}
+
+	// 下載標案詳細資料（快取未過期時不發出請求），依上游的回應調整請求節奏
+	if err := pacer.wait(ctx); err != nil {
+		return result, false
+	}
+	start := time.Now()
+	body, source, err := s.fetchTender(ctx, task.JobNumber, task.APIURL, false)
+	switch {
+	case source == tenderFromCache:
+		pacer.release()
+	case ctx.Err() == nil: // 取消造成的失敗不算上游錯誤
+		pacer.record(start, err)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, false
+		}
+		result.Status, result.Error = downloadError, err.Error()
+		return result, true
+	}
+
+	// 已取得內容時即使工作被取消也寫完，附件與檔案才會一致
+	ctx = context.WithoutCancel(ctx)
+
+	// 存為附件，並複製一份到 bookmarked_tenders/ 供其他工具讀取
+	// 檔名與其他書籤只差在大小寫時不寫入，以免在不分大小寫的檔案系統上互相覆蓋
+	filename := filepath.Join(downloadDir, tenderFileName(task.JobNumber))
+	// 上游的錯誤訊息照原樣顯示，資料庫與檔案的錯誤只顯示請求編號
+	owner, err := s.tenderFileOwner(ctx, task.JobNumber)
+	if err != nil || owner != "" {
+		result.Status = downloadError
+		if err != nil {
+			result.Error = safeErrorMessage(r, "database_error", err)
+		} else {
+			result.Error = localize(requestLanguage(r), "filename_collision", tenderFileName(task.JobNumber), owner)
+		}
+		return result, true
+	}
+	a, err := s.saveAttachment(ctx, task.JobNumber, attachmentTenderDetail, tenderFileName(task.JobNumber), "application/json", bytes.NewReader(body))
+	if err == nil {
+		err = s.publishAttachment(a, filename)
+	}
+	if err != nil {
+		result.Status, result.Error = downloadError, safeErrorMessage(r, "file_error", err)
+		return result, true
+	}
+
+	result.Status, result.File, result.Source = downloadSuccess, filename, source
+	// 詳細資料屬於其他標案時仍算下載成功，附上差異供確認（可用 reconcile 更新書籤）
+	result.Mismatch = s.tenderMismatches(r, task.JobNumber, task.Title, task.UnitName, task.APIURL, body)
+	return result, true
+}
+
+// 記錄本次下載（僅寫入檔案，稽核紀錄與下載完成事件獨立成一筆交易）
+func (s *Server) recordDownload(r *http.Request, total int, sum downloadSummary) {
+	var downloaded []string
+	for _, result := range sum.results {
+		if result.Status == downloadSuccess {
+			downloaded = append(downloaded, result.JobNumber)
+		}
+	}
+	summary := fmt.Sprintf("下載標書 %d 筆（成功 %d 筆）", total, len(downloaded))
+	if sum.canceled {
+		summary += fmt.Sprintf("，中斷於第 %d 筆", len(sum.results))
+	}
+	entry := newAuditEntry(r, summary, downloaded...)
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		err := writeEvent(tx, requestActor(r), entityDownload, "", actionComplete, map[string]interface{}{
+			"total":       total,
+			"success":     len(downloaded),
+			"mismatches":  sum.mismatches,
+			"canceled":    sum.canceled,
+			"job_numbers": append(make([]string, 0), downloaded...),
+		})
+		if err != nil {
+			return err
+		}
+		return writeAudit(tx, entry)
+	})
+	if err != nil {
+		log.Println("寫入稽核紀錄失敗:", err)
+	} else {
+		s.broker.notify()
+	}
+}
+
+// 背景下載工作
+type downloadJob struct {
+	ID   string
+	Year int
+
+	mu         sync.Mutex
+	state      string // running、completed、canceled
+	total      int
+	warnings   int
+	results    []downloadResult
+	mismatches int
+	throttled  time.Duration
+	outputDir  string
+	startedAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+}
+
+// 執行中與最近結束的下載工作
+var downloadJobs = struct {
+	sync.Mutex
+	jobs map[string]*downloadJob
+}{jobs: map[string]*downloadJob{}}
+
+// 工作狀態；results 為目前為止的逐筆結果
+func (j *downloadJob) status() map[string]interface{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	success, failed := 0, 0
+	for _, res := range j.results {
+		if res.Status == downloadSuccess {
+			success++
+		} else {
+			failed++
+		}
+	}
+	resp := map[string]interface{}{
+		"job_id":            j.ID,
+		"year":              j.Year,
+		"state":             j.state,
+		"total":             j.total,
+		"pending":           j.total - len(j.results),
+		"success":           success,
+		"error":             failed,
+		"warnings":          j.warnings,
+		"mismatches":        j.mismatches,
+		"throttled_seconds": j.throttled.Seconds(),
+		"results":           append(make([]downloadResult, 0, len(j.results)), j.results...),
+		"output_dir":        j.outputDir,
+		"started_at":        localTime(j.startedAt),
+		"finished_at":       nil,
+	}
+	if !j.finishedAt.IsZero() {
+		resp["finished_at"] = localTime(j.finishedAt)
+	}
+	return resp
+}
+
+// 登記新的工作；同一年度已有執行中的工作時回傳該工作
+func registerDownloadJob(job *downloadJob) *downloadJob {
+	downloadJobs.Lock()
+	defer downloadJobs.Unlock()
+	var finished []*downloadJob
+	for id, j := range downloadJobs.jobs {
+		j.mu.Lock()
+		state, finishedAt := j.state, j.finishedAt
+		j.mu.Unlock()
+		switch {
+		case state == "running" && j.Year == job.Year:
+			return j
+		case state != "running" && time.Since(finishedAt) > downloadJobRetention:
+			delete(downloadJobs.jobs, id)
+		case state != "running":
+			finished = append(finished, j)
+		}
+	}
+	// 保留最近結束的 downloadJobMaxKept 個
+	for len(finished) >= downloadJobMaxKept {
+		oldest := 0
+		for i, j := range finished {
+			if j.finishedAt.Before(finished[oldest].finishedAt) {
+				oldest = i
+			}
+		}
+		delete(downloadJobs.jobs, finished[oldest].ID)
+		finished = append(finished[:oldest], finished[oldest+1:]...)
+	}
+	downloadJobs.jobs[job.ID] = job
+	return job
+}
+
+func findDownloadJob(id string) *downloadJob {
+	downloadJobs.Lock()
+	defer downloadJobs.Unlock()
+	return downloadJobs.jobs[id]
+}
+
+// POST /api/bookmarks/download：開始背景下載，回傳 202 與 job_id（job 為工作狀態，與 status 相同）
+func (s *Server) startDownloadJob(w http.ResponseWriter, r *http.Request) {
+	tasks, warnings, err := s.loadDownloadTasks(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &downloadJob{
+		ID:        hex.EncodeToString(b),
+		Year:      s.cfg.Year,
+		state:     "running",
+		total:     len(tasks),
+		warnings:  warnings,
+		outputDir: s.tendersDir(),
+		startedAt: time.Now(),
+		cancel:    cancel,
+	}
+	if running := registerDownloadJob(job); running != job {
+		cancel()
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"error":   "download_in_progress",
+			"message": localize(requestLanguage(r), "download_in_progress", running.ID),
+			"job_id":  running.ID,
+		})
+		return
+	}
+
+	// 請求結束後仍使用其語系、請求編號與操作者，但不隨請求取消
+	detached := r.WithContext(context.WithoutCancel(r.Context()))
+	done := beginJob("download")
+	go func() {
+		defer done()
+		defer cancel()
+		sum := s.runDownloads(ctx, detached, tasks, func(res downloadResult) {
+			job.mu.Lock()
+			defer job.mu.Unlock()
+			job.results = append(job.results, res)
+			if res.Mismatch != nil {
+				job.mismatches++
+			}
+		})
+		s.recordDownload(detached, len(tasks), sum)
+		job.mu.Lock()
+		job.state, job.throttled, job.finishedAt = "completed", sum.throttled, time.Now()
+		if sum.canceled {
+			job.state = "canceled"
+		}
+		job.mu.Unlock()
+		log.Printf("下載工作 %s 結束：%d 筆中處理 %d 筆", job.ID, len(tasks), len(sum.results))
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"success": true, "job_id": job.ID, "job": job.status()})
+}
+
+// GET /api/bookmarks/download/status?job_id=
+func (s *Server) downloadJobStatus(w http.ResponseWriter, r *http.Request) {
+	job := findDownloadJob(r.URL.Query().Get("job_id"))
+	if job == nil {
+		writeError(w, r, http.StatusNotFound, "download_job_not_found", r.URL.Query().Get("job_id"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.status())
+}
+
+// DELETE /api/bookmarks/download?job_id=：取消下載工作；已結束的工作不受影響
+func (s *Server) cancelDownloadJob(w http.ResponseWriter, r *http.Request) {
+	job := findDownloadJob(r.URL.Query().Get("job_id"))
+	if job == nil {
+		writeError(w, r, http.StatusNotFound, "download_job_not_found", r.URL.Query().Get("job_id"))
+		return
+	}
+	job.cancel()
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"success": true, "job_id": job.ID, "job": job.status()})
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// SQL 文字傾印（僅 SQLite）
+//
+// 格式與 sqlite3 的 .dump 相近：先建立資料表，再依主鍵順序插入資料，最後建立索引與觸發器
+// （觸發器在資料之後建立，匯入時不會再次驗證既有資料）。整份傾印包在一個交易中。
+// 值以 SQLite 的 quote() 產生字面值，時間欄位保留資料庫中的原始文字；
+// 加密欄位維持密文，匯入的資料庫需使用相同的金鑰。
+// 傾印中不含時間戳記，相同資料的兩份傾印內容完全一致，可直接 diff。
+
+var errDumpUnsupported = errors.New("SQL 傾印僅支援 SQLite")
+
+// 資料庫物件的建立順序
+var dumpObjectOrder = map[string]int{"table": 0, "index": 1, "trigger": 2, "view": 3}
+
+type dumpObject struct {
+	kind, name, table, sql string
+}
+
+// 將資料庫傾印為 SQL 文字；在唯讀交易中讀取，確保結構與資料來自同一個快照
+func (s *Server) dumpSQL(ctx context.Context, out io.Writer) error {
+	if s.db.dialect != dialectSQLite {
+		return errDumpUnsupported
+	}
+	tx, err := s.db.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	objects, virtual, err := sqliteSchema(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(out)
+	fmt.Fprintln(bw, "PRAGMA foreign_keys=OFF;")
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+	for _, o := range objects {
+		if o.kind != "table" {
+			continue
+		}
+		fmt.Fprintf(bw, "%s;\n", o.sql)
+		// 虛擬資料表只輸出 CREATE，內容在最後由來源資料表重建
+		if virtual[o.name] {
+			continue
+		}
+		if err := dumpTableRows(ctx, tx, bw, o.name); err != nil {
+			return fmt.Errorf("傾印 %s 失敗: %w", o.name, err)
+		}
+	}
+
+	// AUTOINCREMENT 的目前值，匯入後新增的 id 才會接續原本的編號
+	var hasSequence int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_sequence'").Scan(&hasSequence); err != nil {
+		return err
+	}
+	if hasSequence > 0 {
+		fmt.Fprintln(bw, "DELETE FROM sqlite_sequence;")
+		if err := dumpTableRows(ctx, tx, bw, "sqlite_sequence"); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range objects {
+		if o.kind != "table" {
+			fmt.Fprintf(bw, "%s;\n", o.sql)
+		}
+	}
+	// 全文檢索索引由書籤重建，rowid 才會與 bookmarks.id 一致
+	if virtual["bookmarks_fts"] {
+		fmt.Fprintf(bw, "%s;\n", bookmarksFTSPopulate)
+	}
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+// 資料庫中的資料表、索引、觸發器與檢視，依類型排序，同類型依名稱排序
+// FTS5 等虛擬資料表的影子資料表由 CREATE VIRTUAL TABLE 自動建立，不列入；virtual 為虛擬資料表的名稱
+func sqliteSchema(ctx context.Context, tx *sql.Tx) ([]dumpObject, map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, nil, err
+	}
+	var objects []dumpObject
+	for rows.Next() {
+		var o dumpObject
+		if err := rows.Scan(&o.kind, &o.name, &o.table, &o.sql); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	// 依類型排序，同類型維持名稱順序
+	for i := 1; i < len(objects); i++ {
+		for j := i; j > 0 && dumpObjectOrder[objects[j].kind] < dumpObjectOrder[objects[j-1].kind]; j-- {
+			objects[j], objects[j-1] = objects[j-1], objects[j]
+		}
+	}
+
+	virtual := map[string]bool{}
+	for _, o := range objects {
+		if o.kind == "table" && strings.HasPrefix(strings.ToUpper(o.sql), "CREATE VIRTUAL TABLE") {
+			virtual[o.name] = true
+		}
+	}
+	isShadow := func(name string) bool {
+		for _, suffix := range []string{"_data", "_idx", "_content", "_docsize", "_config"} {
+			if virtual[strings.TrimSuffix(name, suffix)] && strings.HasSuffix(name, suffix) {
+				return true
+			}
+		}
+		return false
+	}
+	kept := objects[:0]
+	for _, o := range objects {
+		if !isShadow(o.name) {
+			kept = append(kept, o)
+		}
+	}
+	return kept, virtual, nil
+}
+
+// 依主鍵順序輸出資料表的 INSERT；沒有宣告主鍵的表依 rowid 排序
+func dumpTableRows(ctx context.Context, tx *sql.Tx, w io.Writer, table string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name, pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	pk := map[int]string{}
+	for rows.Next() {
+		var name string
+		var pos int
+		if err := rows.Scan(&name, &pos); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, quoteIdent(name))
+		if pos > 0 {
+			pk[pos] = quoteIdent(name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	orderBy := "rowid"
+	if len(pk) > 0 {
+		keys := make([]string, len(pk))
+		for pos, name := range pk {
+			keys[pos-1] = name
+		}
+		orderBy = strings.Join(keys, ", ")
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = "quote(" + c + ")"
+	}
+
+	rows, err = tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s ORDER BY %s",
+		strings.Join(quoted, " || ',' || "), quoteIdent(table), orderBy))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", quoteIdent(table), strings.Join(columns, ", "))
+	for rows.Next() {
+		var values string
+		if err := rows.Scan(&values); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s%s);\n", prefix, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SQLite 識別字加上雙引號
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// GET /api/admin/dump：下載 SQL 傾印
+func (s *Server) dumpHandler(w http.ResponseWriter, r *http.Request) {
+	if s.db.dialect != dialectSQLite {
+		writeError(w, r, http.StatusNotImplemented, "dump_unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	w.Header().Set("Content-Disposition", contentDisposition(fmt.Sprintf("bookmarks_%d.sql", s.cfg.Year)))
+	if err := s.dumpSQL(r.Context(), w); err != nil {
+		// 標頭已送出，只能中斷輸出；傾印缺少結尾的 COMMIT，匯入時不會留下不完整的資料
+		log.Println("SQL 傾印失敗:", err)
+	}
+}
+
+// 匯入 SQL 傾印到空的資料庫：
+//
+//	bookmark-server import --format sql [--year 2026 | --db path] dump.sql
+//
+// 未指定檔案或檔案為 - 時從標準輸入讀取。匯入後套用尚未執行的資料庫遷移，
+// 較舊版本的傾印也能直接使用。
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "sql", "匯入格式（目前僅支援 sql）")
+	year := fs.String("year", "", "年度（西元或民國年），預設為今年")
+	dbPath := fs.String("db", "", "資料庫檔案路徑，指定時忽略 --year")
+	fs.Parse(args)
+
+	if *format != "sql" {
+		return fmt.Errorf("不支援的匯入格式: %s", *format)
+	}
+	// 與伺服器相同，資料根目錄可用環境變數 DATA_DIR 指定
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		dataRoot = dir
+	}
+	path := *dbPath
+	if path == "" {
+		y := currentYear()
+		if *year != "" {
+			var err error
+			if y, err = parseYear(*year); err != nil {
+				return err
+			}
+		}
+		path = yearDBPath(y)
+	}
+
+	var in io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	script, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	d, _, err := openSQLite(path, false)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	var tables int
+	if err := d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables > 0 {
+		return fmt.Errorf("資料庫 %s 不是空的，請匯入到新的資料庫", path)
+	}
+
+	// 傾印自帶 BEGIN/COMMIT，需在同一條連線上執行；失敗時回滾，不留下部分資料
+	conn, err := d.DB.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), string(script)); err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		return fmt.Errorf("匯入失敗: %w", err)
+	}
+	conn.ExecContext(context.Background(), "PRAGMA foreign_keys=ON")
+	conn.Close()
+
+	if err := initSchema(d); err != nil {
+		return err
+	}
+	var bookmarks int
+	d.QueryRow("SELECT COUNT(*) FROM bookmarks").Scan(&bookmarks)
+	log.Printf("已匯入 %s，共 %d 筆書籤", path, bookmarks)
+	return nil
+}
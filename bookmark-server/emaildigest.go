@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 每日電子郵件摘要
+//
+// 每天 email_digest.time（顯示時區）之後寄出一封摘要給 smtp.to，內容：
+//   - 上次摘要之後自動加入的書籤（操作者為 system 的新增事件，例如依關注清單加入）
+//   - deadline_days 天內截止投標的書籤（見 upcomingDeadlines）
+//   - 上次摘要之後標案詳細資料有變的書籤（tender.update 事件，見 fetchTenderRemote）
+// 「上次摘要之後」以事件 id 計算：每次寄出記錄當時最新的事件 id，下次從其後開始。
+// 每次寄出（含略過與失敗）記錄於 email_digests，排程只在當天沒有成功紀錄時寄出，重新啟動不會重複寄送；
+// 錯過時間（例如當時伺服器未執行）時於啟動後補寄，失敗時下次檢查再試。
+// skip_empty 為 true 時三項都沒有內容的摘要不寄出（仍記錄為 skipped）。
+// POST /api/admin/email-digest 立即寄出一封（手動寄出不影響每日排程與統計區間），GET 列出寄送紀錄。
+
+// EmailDigestConfig 每日摘要設定，未設定 smtp.host 時停用
+type EmailDigestConfig struct {
+	SMTP         SMTPConfig `json:"smtp"`
+	Time         string     `json:"time"`          // 每天寄出的時間（HH:MM），預設 "08:00"
+	DeadlineDays int        `json:"deadline_days"` // 列出幾天內截止投標的書籤，預設 7
+	SkipEmpty    bool       `json:"skip_empty"`    // 沒有內容時不寄出
+}
+
+// 摘要紀錄的狀態
+const (
+	digestSent    = "sent"
+	digestSkipped = "skipped"
+	digestFailed  = "failed"
+)
+
+// 排程檢查是否該寄出的間隔
+const digestCheckInterval = 5 * time.Minute
+
+func (c *EmailDigestConfig) enabled() bool {
+	return c.SMTP.Host != ""
+}
+
+// 補上預設值並檢查設定
+func (c *EmailDigestConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if c.Time == "" {
+		c.Time = "08:00"
+	}
+	if _, err := time.Parse("15:04", c.Time); err != nil {
+		return fmt.Errorf("email_digest.time 格式錯誤（HH:MM）: %q", c.Time)
+	}
+	if c.DeadlineDays == 0 {
+		c.DeadlineDays = 7
+	}
+	if c.DeadlineDays < 0 {
+		return fmt.Errorf("email_digest.deadline_days 不可為負數")
+	}
+	if err := c.SMTP.validate(); err != nil {
+		return fmt.Errorf("email_digest: %w", err)
+	}
+	return nil
+}
+
+// 摘要中的一筆書籤
+type digestItem struct {
+	JobNumber    string
+	Title        string
+	UnitName     string
+	URL          string
+	Priority     int
+	DeadlineText string
+	DaysLeft     int
+}
+
+// 摘要內容，範本的資料
+type digestContent struct {
+	Date         string
+	Since        string // 上次摘要的時間，第一次時為空字串
+	DeadlineDays int
+	New          []digestItem
+	Deadlines    []digestItem
+	Changed      []digestItem
+
+	lastEventID int64 // 本次涵蓋到的事件 id
+}
+
+func (d *digestContent) empty() bool {
+	return len(d.New) == 0 && len(d.Deadlines) == 0 && len(d.Changed) == 0
+}
+
+// 上次每日摘要（寄出或略過）涵蓋到的事件 id 與時間
+func (s *Server) lastDigest(ctx context.Context) (int64, time.Time, error) {
+	var id int64
+	var at time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT last_event_id, created_at FROM email_digests
+		WHERE manual = 0 AND status IN (?, ?) ORDER BY id DESC LIMIT 1`, digestSent, digestSkipped).
+		Scan(&id, scanTime(&at))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, err
+	}
+	return id, at, nil
+}
+
+// 事件之後有新增或更新事件的書籤（依優先順序），actor 不為空時只列出該操作者的事件
+func (s *Server) digestBookmarks(ctx context.Context, entityType, action, actor string, afterID, upToID int64) ([]digestItem, error) {
+	query := `
+		SELECT b.job_number, b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0)
+		FROM bookmarks b
+		WHERE b.deleted_at IS NULL AND b.job_number IN (
+			SELECT entity_key FROM events
+			WHERE entity_type = ? AND action = ? AND id > ? AND id <= ?`
+	args := []interface{}{entityType, action, afterID, upToID}
+	if actor != "" {
+		query += " AND actor = ?"
+		args = append(args, actor)
+	}
+	query += `)
+		ORDER BY b.priority DESC, b.id`
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []digestItem
+	for rows.Next() {
+		var it digestItem
+		if err := rows.Scan(&it.JobNumber, &it.Title, &it.UnitName, &it.URL, &it.Priority); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// 產生摘要內容
+func (s *Server) buildDigest(ctx context.Context, now time.Time) (*digestContent, error) {
+	cfg := s.cfg.EmailDigest
+	afterID, since, err := s.lastDigest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d := &digestContent{Date: localTime(now).Format("2006-01-02"), DeadlineDays: cfg.DeadlineDays}
+	if !since.IsZero() {
+		d.Since = localTime(since).Format("2006-01-02 15:04")
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM events").Scan(&d.lastEventID); err != nil {
+		return nil, err
+	}
+	if d.New, err = s.digestBookmarks(ctx, entityBookmark, actionCreate, systemActor, afterID, d.lastEventID); err != nil {
+		return nil, err
+	}
+	if d.Changed, err = s.digestBookmarks(ctx, entityTender, actionUpdate, "", afterID, d.lastEventID); err != nil {
+		return nil, err
+	}
+	due, err := s.upcomingDeadlines(ctx, now, cfg.DeadlineDays)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range due {
+		d.Deadlines = append(d.Deadlines, digestItem{
+			JobNumber: n.JobNumber, Title: n.Title, UnitName: n.UnitName, URL: n.URL, Priority: n.Priority,
+			DeadlineText: localTime(n.Deadline).Format("2006-01-02 15:04"), DaysLeft: n.DaysLeft,
+		})
+	}
+	return d, nil
+}
+
+var digestTextTemplate = template.Must(template.New("digest").Parse(`政府採購書籤每日摘要 {{.Date}}
+{{- if .Since}}（{{.Since}} 之後）{{end}}
+
+■ 自動加入的書籤（{{len .New}}）
+{{- range .New}}
+・{{.Title}}
+  {{.UnitName}}｜{{.JobNumber}}{{if .URL}}
+  {{.URL}}{{end}}
+{{- else}}
+（無）
+{{- end}}
+
+■ {{.DeadlineDays}} 天內截止投標（{{len .Deadlines}}）
+{{- range .Deadlines}}
+・{{.DeadlineText}}（剩 {{.DaysLeft}} 天）{{.Title}}
+  {{.UnitName}}｜{{.JobNumber}}{{if .URL}}
+  {{.URL}}{{end}}
+{{- else}}
+（無）
+{{- end}}
+
+■ 標案資料有更新（{{len .Changed}}）
+{{- range .Changed}}
+・{{.Title}}
+  {{.UnitName}}｜{{.JobNumber}}{{if .URL}}
+  {{.URL}}{{end}}
+{{- else}}
+（無）
+{{- end}}
+`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; color: #222;">
+<h2>政府採購書籤每日摘要 {{.Date}}</h2>
+{{if .Since}}<p style="color: #666;">{{.Since}} 之後</p>{{end}}
+{{define "items"}}
+{{if .}}<ul>
+{{range .}}<li style="margin-bottom: 8px;">
+{{if .DeadlineText}}<strong>{{.DeadlineText}}</strong>（剩 {{.DaysLeft}} 天）<br>{{end}}
+{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}<br>
+<span style="color: #666;">{{.UnitName}}｜{{.JobNumber}}{{if .Priority}}｜優先順序 {{.Priority}}{{end}}</span>
+</li>
+{{end}}</ul>{{else}}<p style="color: #999;">（無）</p>{{end}}
+{{end}}
+<h3>自動加入的書籤（{{len .New}}）</h3>
+{{template "items" .New}}
+<h3>{{.DeadlineDays}} 天內截止投標（{{len .Deadlines}}）</h3>
+{{template "items" .Deadlines}}
+<h3>標案資料有更新（{{len .Changed}}）</h3>
+{{template "items" .Changed}}
+</body></html>
+`))
+
+// 產生郵件
+func (d *digestContent) message() (mailMessage, error) {
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, d); err != nil {
+		return mailMessage{}, err
+	}
+	if err := digestHTMLTemplate.Execute(&html, d); err != nil {
+		return mailMessage{}, err
+	}
+	return mailMessage{
+		Subject: fmt.Sprintf("政府採購書籤每日摘要 %s（新書籤 %d、即將截止 %d、資料更新 %d）", d.Date, len(d.New), len(d.Deadlines), len(d.Changed)),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// DigestRecord 摘要寄送紀錄
+type DigestRecord struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	DigestDate  string    `json:"digest_date"`
+	Manual      bool      `json:"manual"`
+	Status      string    `json:"status"`
+	Recipients  string    `json:"recipients"`
+	NewCount    int       `json:"new"`
+	Deadlines   int       `json:"deadlines"`
+	Changed     int       `json:"changed"`
+	Error       string    `json:"error"`
+	LastEventID int64     `json:"last_event_id"`
+}
+
+// 產生並寄出摘要，寫入紀錄；skipEmpty 時沒有內容不寄出
+func (s *Server) sendDigest(ctx context.Context, now time.Time, manual, skipEmpty bool) (*DigestRecord, error) {
+	cfg := s.cfg.EmailDigest
+	d, err := s.buildDigest(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	rec := &DigestRecord{
+		DigestDate:  d.Date,
+		Manual:      manual,
+		Status:      digestSent,
+		Recipients:  strings.Join(cfg.SMTP.To, ", "),
+		NewCount:    len(d.New),
+		Deadlines:   len(d.Deadlines),
+		Changed:     len(d.Changed),
+		LastEventID: d.lastEventID,
+	}
+	var sendErr error
+	if skipEmpty && d.empty() {
+		rec.Status = digestSkipped
+	} else {
+		msg, err := d.message()
+		if err != nil {
+			return nil, err
+		}
+		if sendErr = cfg.SMTP.send(ctx, msg); sendErr != nil {
+			rec.Status = digestFailed
+			rec.Error = truncateMessage(sendErr.Error())
+		}
+	}
+	manualFlag := 0
+	if manual {
+		manualFlag = 1
+	}
+	err = s.db.withTx(context.Background(), func(tx *Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO email_digests (created_at, digest_date, manual, status, recipients, new_count, deadline_count, changed_count, error, last_event_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			dbNow(), rec.DigestDate, manualFlag, rec.Status, rec.Recipients, rec.NewCount, rec.Deadlines, rec.Changed, rec.Error, rec.LastEventID).Scan(&rec.ID)
+	})
+	if err != nil {
+		// 已寄出卻沒有紀錄時下次會重複寄送，記錄下來以便追查
+		log.Printf("寫入摘要紀錄失敗（狀態 %s）: %v", rec.Status, err)
+		return rec, err
+	}
+	rec.CreatedAt = localTime(now)
+	return rec, sendErr
+}
+
+// 排程：到了寄送時間且今天還沒有寄出（或略過）時寄出
+func (s *Server) checkEmailDigest(ctx context.Context) error {
+	cfg := s.cfg.EmailDigest
+	now := time.Now()
+	local := localTime(now)
+	at, _ := time.Parse("15:04", cfg.Time)
+	if local.Hour()*60+local.Minute() < at.Hour()*60+at.Minute() {
+		return nil
+	}
+	var done bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM email_digests WHERE manual = 0 AND digest_date = ? AND status IN (?, ?))`,
+		local.Format("2006-01-02"), digestSent, digestSkipped).Scan(&done)
+	if err != nil || done {
+		return err
+	}
+	rec, err := s.sendDigest(ctx, now, false, cfg.SkipEmpty)
+	if err != nil {
+		return err
+	}
+	log.Printf("每日摘要 %s: %s（新書籤 %d、即將截止 %d、資料更新 %d）", rec.DigestDate, rec.Status, rec.NewCount, rec.Deadlines, rec.Changed)
+	return nil
+}
+
+// GET /api/admin/email-digest：最近 50 筆寄送紀錄
+// POST /api/admin/email-digest：立即寄出一封摘要（不略過空白摘要），失敗時回傳 502
+func (s *Server) emailDigestHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.EmailDigest.enabled() {
+		writeError(w, r, http.StatusConflict, "email_digest_disabled")
+		return
+	}
+	if r.Method == "POST" {
+		rec, err := s.sendDigest(r.Context(), time.Now(), true, false)
+		if rec == nil {
+			writeInternalError(w, r, "email_digest_failed", err)
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+				"success": false,
+				"error":   "email_send_failed",
+				"message": localize(requestLanguage(r), "email_send_failed", truncateMessage(err.Error())),
+				"digest":  rec,
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "digest": rec})
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, created_at, digest_date, manual, status, recipients, new_count, deadline_count, changed_count, error, last_event_id
+		FROM email_digests ORDER BY id DESC LIMIT 50`)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]DigestRecord, 0)
+	for rows.Next() {
+		var rec DigestRecord
+		var manual int
+		if err := rows.Scan(&rec.ID, scanTime(&rec.CreatedAt), &rec.DigestDate, &manual, &rec.Status, &rec.Recipients,
+			&rec.NewCount, &rec.Deadlines, &rec.Changed, &rec.Error, &rec.LastEventID); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		rec.Manual = manual != 0
+		rec.CreatedAt = localTime(rec.CreatedAt)
+		items = append(items, rec)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(items), "items": items})
+}
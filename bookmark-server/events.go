@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event 領域事件：所有資料異動的共用變更紀錄
+// 活動紀錄、復原、同步與統計都應讀取此表，不另建重疊的紀錄表
+type Event struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      string          `json:"actor"`
+	EntityType string          `json:"entity_type"`
+	EntityKey  string          `json:"entity_key"`
+	Action     string          `json:"action"`
+	Type       string          `json:"type"` // 「種類.動作」，與 SSE 的事件名稱相同
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// 事件的實體種類與動作
+const (
+	entityBookmark  = "bookmark"
+	entityIntegrity = "integrity"
+	entityDownload  = "download"
+	entityTender    = "tender" // 標案詳細資料（tender_cache）
+
+	actionCreate   = "create"
+	actionUpdate   = "update"
+	actionDelete   = "delete"
+	actionRepair   = "repair"
+	actionArchive  = "archive"
+	actionRestore  = "restore" // 從垃圾桶還原，內容為還原後的書籤
+	actionComplete = "complete"
+)
+
+// 寫入事件，必須與資料異動在同一個交易中
+// payload 為異動後的資料（刪除時為刪除前的資料），供復原與同步使用
+// PostgreSQL 的序號在插入時取得、提交時才看得到，並行的交易可能讓較大的 id 先出現；
+// 因此鎖住 events 表到交易結束，讓 id 的順序與提交順序一致（SQLite 只有單一寫入者，本來就一致）
+func writeEvent(tx *Tx, actor, entityType, entityKey, action string, payload interface{}) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if tx.dialect == dialectPostgres {
+		if _, err := tx.Exec("LOCK TABLE events IN EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+	}
+	sealed, err := tx.cipher.seal("payload", string(raw))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO events (created_at, actor, entity_type, entity_key, action, payload)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, dbNow(), actor, entityType, entityKey, action, sealed)
+	return err
+}
+
+// 在交易中讀取一筆書籤（含標籤），作為事件內容；不存在或在垃圾桶中時回傳 nil
+func bookmarkSnapshot(tx *Tx, jobNumber string) (*Bookmark, error) {
+	rows, err := tx.Query("SELECT "+bookmarkColumns+" FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL", jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	b, err := scanBookmark(rows, tx.cipher)
+	if err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if b.Tags, err = bookmarkTags(tx, jobNumber); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// 已刪除的事件中最大的 id，存於 app_meta；since_id 小於此值時中間可能有事件已被刪除
+const eventsPrunedKey = "events_pruned_through"
+
+func (s *Server) eventsPrunedThrough(ctx context.Context) (int64, error) {
+	var raw string
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(value), '') FROM app_meta WHERE key = ?", eventsPrunedKey).Scan(&raw); err != nil {
+		return 0, err
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+// 刪除超過保留期限的事件，並記錄刪除到哪個 id，讓 /api/events 能分辨游標是否已失效
+func (s *Server) pruneEvents(ctx context.Context) error {
+	if s.cfg.EventRetention.Duration <= 0 {
+		return nil
+	}
+	cutoff := dbTime(time.Now().Add(-s.cfg.EventRetention.Duration))
+	var n int64
+	err := s.db.withTx(ctx, func(tx *Tx) error {
+		n = 0
+		var through int64
+		if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM events WHERE created_at < ?", cutoff).Scan(&through); err != nil {
+			return err
+		}
+		if through == 0 {
+			return nil
+		}
+		// 以 id 而非時間刪除，確保刪除的一定是連續的最舊事件
+		result, err := tx.Exec("DELETE FROM events WHERE id <= ?", through)
+		if err != nil {
+			return err
+		}
+		n, _ = result.RowsAffected()
+		_, err = tx.Exec(`INSERT INTO app_meta (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, eventsPrunedKey, strconv.FormatInt(through, 10))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("已刪除 %d 筆過期事件", n)
+	}
+	return nil
+}
+
+// 事件 id 的有效範圍：prunedThrough 以前的事件已刪除，latest 為目前最新的 id
+type eventRange struct {
+	prunedThrough, latest int64
+}
+
+func (s *Server) currentEventRange(ctx context.Context) (eventRange, error) {
+	var er eventRange
+	var err error
+	if er.prunedThrough, err = s.eventsPrunedThrough(ctx); err != nil {
+		return er, err
+	}
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM events").Scan(&er.latest); err != nil {
+		return er, err
+	}
+	if er.latest < er.prunedThrough {
+		er.latest = er.prunedThrough
+	}
+	return er, nil
+}
+
+// 檢查游標；已失效時回應 410 events_cursor_expired 或 409 events_cursor_ahead 並回傳 false
+// hasSince 為 false（未指定游標）時不會回應 410
+func (er eventRange) check(w http.ResponseWriter, r *http.Request, sinceID int64, hasSince bool) bool {
+	status, code, arg := 0, "", int64(0)
+	switch {
+	case hasSince && sinceID < er.prunedThrough:
+		status, code, arg = http.StatusGone, "events_cursor_expired", er.prunedThrough
+	case sinceID > er.latest:
+		status, code, arg = http.StatusConflict, "events_cursor_ahead", er.latest
+	default:
+		return true
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success":         false,
+		"error":           code,
+		"message":         localize(requestLanguage(r), code, sinceID, arg),
+		"oldest_since_id": er.prunedThrough,
+		"latest_id":       er.latest,
+	})
+	return false
+}
+
+// 增量讀取事件，供無法維持 SSE 連線的外部自動化（排程腳本、serverless 函式）輪詢
+//
+// GET /api/events?since_id=N&limit=M 依 id 由小到大回傳 N 之後的事件，消費端處理完後以回應中的
+// next_since_id 作為下一次的 since_id；has_more 為 true 時應立即再取一次。
+// id 的順序與提交順序一致（見 writeEvent），以 next_since_id 續讀不會漏掉任何事件；
+// 處理後才更新游標即可達成至少一次（at-least-once）的處理。id 可能不連號（回滾或篩選），不代表遺漏。
+//
+// 篩選：type= 為逗號分隔的「種類」或「種類.動作」（例如 bookmark、download.complete），
+// job_number= 只回傳該案號的事件；舊的 entity_type=、entity_key= 仍可使用。
+// 有篩選時 next_since_id 仍會越過不符合的事件，下一次不必重新掃描。
+//
+// 游標失效：
+//   - since_id 小於已依 event_retention 刪除的最大 id 時回應 410 events_cursor_expired，
+//     中間的事件已無法取得，消費端應重新同步全部資料後從 oldest_since_id 繼續
+//   - since_id 大於目前最新的 id 時回應 409 events_cursor_ahead（通常是資料庫還原或更換），
+//     消費端應重新同步後從 latest_id 繼續
+//   - 未指定 since_id 時從保留中最舊的事件開始，不會回應 410
+//
+// 每個事件的欄位固定為 id、created_at、actor、entity_type、entity_key、action、type、payload，
+// payload 依 type 而定：
+//   - bookmark.create、bookmark.update：異動後的書籤（與 GET /api/bookmarks 的項目相同）
+//   - bookmark.delete：刪除前的書籤
+//   - bookmark.archive：{"before": 封存的日期界限}
+//   - bookmark.tender_mismatch：書籤與標案資料不一致的欄位
+//   - download.complete：下載完成的檔案資訊，entity_key 為空字串
+//   - tender.update：{"api_url": 重新抓取的網址}
+//   - tender.award：決標資料
+//   - integrity.repair：修復的孤兒資料，entity_key 為 "orphans"
+func (s *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var sinceID int64
+	hasSince := q.Get("since_id") != ""
+	if hasSince {
+		n, err := strconv.ParseInt(q.Get("since_id"), 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "since_id")
+			return
+		}
+		sinceID = n
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+			return
+		}
+		limit = n
+	}
+
+	// 先取得最新的 id 作為本次讀取的上限：之後才提交的事件留待下一次，next_since_id 才能越過不符合篩選的事件
+	er, err := s.currentEventRange(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if !er.check(w, r, sinceID, hasSince) {
+		return
+	}
+	latestID := er.latest
+	if sinceID < er.prunedThrough {
+		sinceID = er.prunedThrough
+	}
+
+	query := "SELECT id, created_at, actor, entity_type, entity_key, action, payload FROM events WHERE id > ? AND id <= ?"
+	args := []interface{}{sinceID, latestID}
+	if v := q.Get("type"); v != "" {
+		var conds []string
+		for _, t := range strings.Split(v, ",") {
+			entityType, action, hasAction := strings.Cut(strings.TrimSpace(t), ".")
+			switch {
+			case entityType == "" || (hasAction && action == ""):
+				writeError(w, r, http.StatusBadRequest, "invalid_parameter", "type")
+				return
+			case hasAction:
+				conds = append(conds, "(entity_type = ? AND action = ?)")
+				args = append(args, entityType, action)
+			default:
+				conds = append(conds, "entity_type = ?")
+				args = append(args, entityType)
+			}
+		}
+		query += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+	if v := q.Get("entity_type"); v != "" {
+		query += " AND entity_type = ?"
+		args = append(args, v)
+	}
+	if v := q.Get("entity_key"); v != "" {
+		query += " AND entity_key = ?"
+		args = append(args, v)
+	}
+	if v := q.Get("job_number"); v != "" {
+		jobNumber, ok := jobNumberParam(w, r, v)
+		if !ok {
+			return
+		}
+		query += " AND entity_key = ?"
+		args = append(args, jobNumber)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	events, err := s.scanEvents(rows)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	// 還有下一頁時停在本頁最後一個事件；否則上限之前的事件都已讀過（或不符合篩選），直接跳到上限
+	hasMore := len(events) > limit
+	nextID := latestID
+	if hasMore {
+		events = events[:limit]
+		nextID = events[len(events)-1].ID
+	}
+	lastID := sinceID
+	if len(events) > 0 {
+		lastID = events[len(events)-1].ID
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":         events,
+		"next_since_id": nextID,
+		"last_id":       lastID, // 本頁最後一個事件的 id，為相容舊版保留；新的消費端請用 next_since_id
+		"has_more":      hasMore,
+		"latest_id":     latestID,
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 取一頁事件，回傳事件 id、實體鍵與回應
+func pollTestEvents(t *testing.T, h http.Handler, query string) ([]int64, []string, map[string]interface{}) {
+	t.Helper()
+	w := serve(t, h, "GET", "/api/events"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/events%s = %d: %s", query, w.Code, w.Body.String())
+	}
+	resp := decodeBody(t, w)
+	var ids []int64
+	var keys []string
+	for _, item := range resp["items"].([]interface{}) {
+		e := item.(map[string]interface{})
+		ids = append(ids, int64(e["id"].(float64)))
+		keys = append(keys, e["entity_key"].(string))
+	}
+	return ids, keys, resp
+}
+
+// 以 next_since_id 分頁讀完所有事件：依 id 遞增、不重複、不遺漏
+func TestEventsPollingCursor(t *testing.T) {
+	h := newTestServer(t).routes()
+	for i := 1; i <= 5; i++ {
+		addTestBookmark(t, h, fmt.Sprintf(`{"job_number":"A%03d"}`, i))
+	}
+	all, _, resp := pollTestEvents(t, h, "?limit=1000")
+	if len(all) < 5 || resp["has_more"] != false {
+		t.Fatalf("all events = %v, has_more = %v", all, resp["has_more"])
+	}
+	latest := int64(resp["latest_id"].(float64))
+
+	var got []int64
+	since := int64(0)
+	for page := 0; ; page++ {
+		if page > len(all) {
+			t.Fatal("分頁沒有結束")
+		}
+		ids, _, resp := pollTestEvents(t, h, fmt.Sprintf("?since_id=%d&limit=2", since))
+		got = append(got, ids...)
+		next := int64(resp["next_since_id"].(float64))
+		if next < since {
+			t.Fatalf("next_since_id 倒退: %d -> %d", since, next)
+		}
+		since = next
+		if resp["has_more"] != true {
+			break
+		}
+	}
+	if fmt.Sprint(got) != fmt.Sprint(all) {
+		t.Fatalf("分頁結果 %v, want %v", got, all)
+	}
+	if since != latest {
+		t.Fatalf("最後的 next_since_id = %d, want %d", since, latest)
+	}
+
+	// 篩選後只回傳符合的事件，但游標仍前進到上限
+	_, keys, resp := pollTestEvents(t, h, "?job_number=A002&type=bookmark.create")
+	if !equalStrings(keys, []string{"A002"}) || int64(resp["next_since_id"].(float64)) != latest {
+		t.Fatalf("job_number filter = %v, next_since_id %v", keys, resp["next_since_id"])
+	}
+	if ids, _, _ := pollTestEvents(t, h, "?type=download"); len(ids) != 0 {
+		t.Fatalf("type=download = %v", ids)
+	}
+
+	// 游標超過最新的 id
+	w := serve(t, h, "GET", fmt.Sprintf("/api/events?since_id=%d", latest+10), "")
+	if resp := decodeBody(t, w); w.Code != http.StatusConflict || resp["error"] != "events_cursor_ahead" || resp["latest_id"] != float64(latest) {
+		t.Fatalf("cursor ahead = %d: %v", w.Code, resp)
+	}
+}
+
+// 依保留期限刪除後，較舊的游標回應 410，從 oldest_since_id 可繼續讀取
+func TestEventsCursorExpired(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.EventRetention.Duration = time.Hour
+	h := s.routes()
+	for i := 1; i <= 4; i++ {
+		addTestBookmark(t, h, fmt.Sprintf(`{"job_number":"A%03d"}`, i))
+	}
+	all, _, _ := pollTestEvents(t, h, "")
+	pruned := all[1]
+	if _, err := s.db.Exec("UPDATE events SET created_at = ? WHERE id <= ?", dbTime(time.Now().Add(-2*time.Hour)), pruned); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.pruneEvents(context.Background()); err != nil {
+		t.Fatalf("pruneEvents: %v", err)
+	}
+
+	w := serve(t, h, "GET", "/api/events?since_id=0", "")
+	resp := decodeBody(t, w)
+	if w.Code != http.StatusGone || resp["error"] != "events_cursor_expired" || resp["oldest_since_id"] != float64(pruned) {
+		t.Fatalf("expired cursor = %d: %v", w.Code, resp)
+	}
+
+	ids, _, _ := pollTestEvents(t, h, fmt.Sprintf("?since_id=%d", pruned))
+	if fmt.Sprint(ids) != fmt.Sprint(all[2:]) {
+		t.Fatalf("since oldest_since_id = %v, want %v", ids, all[2:])
+	}
+	// 未指定 since_id 時從保留中最舊的事件開始
+	if ids, _, _ := pollTestEvents(t, h, ""); fmt.Sprint(ids) != fmt.Sprint(all[2:]) {
+		t.Fatalf("without since_id = %v, want %v", ids, all[2:])
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 以 Server-Sent Events 推送事件
+//
+// GET /api/events/stream 推送書籤的新增、更新、刪除與下載完成事件，內容與 /api/events 的項目相同，
+// 來源一律是 events 表：寫入事件的交易提交後以 eventBroker 喚醒所有串流，串流再讀取新的事件。
+// 每個事件以 id 欄位帶上事件 id，斷線後 EventSource 會以 Last-Event-ID 重新連線並補送遺漏的事件；
+// 第一次連線時可用 ?last_event_id= 指定起點，未指定時只推送連線之後的事件。
+// 每 25 秒送出註解行作為心跳，避免代理伺服器關閉閒置的連線，同時重新檢查事件（其他程序寫入的事件也會送出）。
+// 伺服器關閉時所有串流立即結束，不會拖延關閉。
+
+// 推送的事件種類
+var streamEntityTypes = []string{entityBookmark, entityDownload}
+
+const (
+	streamHeartbeat = 25 * time.Second
+	streamBatchSize = 100
+	streamRetry     = 5 * time.Second // 建議 EventSource 重新連線的間隔
+)
+
+// eventBroker 通知串流有新的事件；所有年度共用同一個
+type eventBroker struct {
+	mu     sync.Mutex
+	subs   map[chan struct{}]struct{}
+	closed chan struct{}
+	once   sync.Once
+
+	// 進行中的 WebSocket 連線；連線被接管後 http.Server.Shutdown 不會等待，關閉時另外等候
+	sockets sync.WaitGroup
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[chan struct{}]struct{}), closed: make(chan struct{})}
+}
+
+// 訂閱通知，回傳的函式取消訂閱
+func (b *eventBroker) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// 喚醒所有串流；串流忙碌時合併為一次
+func (b *eventBroker) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 伺服器關閉時結束所有串流
+func (b *eventBroker) shutdown() {
+	b.once.Do(func() { close(b.closed) })
+}
+
+// 等待 WebSocket 連線送出關閉訊息後結束，逾時回傳 false
+func (b *eventBroker) waitSockets(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		b.sockets.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// 讀取事件列，解密 payload
+func (s *Server) scanEvents(rows *sql.Rows) ([]Event, error) {
+	events := make([]Event, 0)
+	for rows.Next() {
+		var e Event
+		var payload sql.NullString
+		if err := rows.Scan(&e.ID, scanTime(&e.CreatedAt), &e.Actor, &e.EntityType, &e.EntityKey, &e.Action, &payload); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = localTime(e.CreatedAt)
+		e.Type = e.EntityType + "." + e.Action
+		e.Payload = json.RawMessage("null")
+		if payload.Valid && payload.String != "" {
+			plain, err := s.db.cipher.open("payload", payload.String)
+			if err != nil {
+				return nil, err
+			}
+			e.Payload = json.RawMessage(plain)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// 讀取 afterID 之後要推送的事件
+func (s *Server) streamEvents(ctx context.Context, afterID int64) ([]Event, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(streamEntityTypes)), ", ")
+	args := []interface{}{afterID}
+	for _, t := range streamEntityTypes {
+		args = append(args, t)
+	}
+	args = append(args, streamBatchSize)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, created_at, actor, entity_type, entity_key, action, payload
+		FROM events WHERE id > ? AND entity_type IN (`+placeholders+`)
+		ORDER BY id LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return s.scanEvents(rows)
+}
+
+// GET /api/events/stream：以 SSE 推送事件，事件名稱為「種類.動作」（例如 bookmark.update、download.complete）
+func (s *Server) streamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, "streaming_unsupported")
+		return
+	}
+
+	// 起點：重新連線時的 Last-Event-ID 優先，其次是 ?last_event_id=，都沒有時從目前最新的事件之後開始
+	var lastID int64
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "last_event_id")
+			return
+		}
+		lastID = n
+	} else if err := s.db.QueryRowContext(r.Context(), "SELECT COALESCE(MAX(id), 0) FROM events").Scan(&lastID); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	// 先訂閱再讀取，避免讀取與訂閱之間寫入的事件沒有通知
+	wake, unsubscribe := s.broker.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx 不要緩衝回應
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	flusher.Flush()
+
+	// 送出 lastID 之後的所有事件；寫入失敗表示用戶端已離線
+	send := func() error {
+		for {
+			events, err := s.streamEvents(r.Context(), lastID)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				data, err := json.Marshal(e)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s.%s\ndata: %s\n\n", e.ID, e.EntityType, e.Action, data); err != nil {
+					return err
+				}
+				lastID = e.ID
+			}
+			flusher.Flush()
+			if len(events) < streamBatchSize {
+				return nil
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		if err := send(); err != nil {
+			if r.Context().Err() == nil {
+				log.Printf("[%s] 推送事件失敗: %v", requestID(r), err)
+			}
+			return
+		}
+		select {
+		case <-wake:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.broker.closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSON Feed（https://www.jsonfeed.org/version/1.1/）
+//
+// GET /api/bookmarks/feed.json 列出最近新增的書籤與偵測到的標案異動（tender 事件：詳細資料更新、決標），
+// 兩者各自是一個項目，依發布時間由新到舊排列，總數為 ?limit=（預設 50，最多 500）。
+// 項目的 id 不會變動：書籤為 bookmark/<書籤 id>，標案異動為 event/<事件 id>；
+// 書籤的 date_published、date_modified 取自 created_at、updated_at，標案異動取事件的時間。
+// external_url 為 PCC 的標案頁面，content_html 由 html/template 產生，備註等內容一律跳脫。
+// ?min_priority= 只列出優先順序不低於此值的書籤（標案異動以所屬書籤的優先順序為準）。
+
+const (
+	jsonFeedVersion      = "https://jsonfeed.org/version/1.1"
+	jsonFeedDefaultLimit = 50
+	jsonFeedMaxLimit     = 500
+)
+
+type jsonFeed struct {
+	Version     string          `json:"version"`
+	Title       string          `json:"title"`
+	HomePageURL string          `json:"home_page_url,omitempty"`
+	FeedURL     string          `json:"feed_url,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Language    string          `json:"language,omitempty"`
+	Items       []*jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string     `json:"id"`
+	ExternalURL   string     `json:"external_url,omitempty"`
+	Title         string     `json:"title"`
+	ContentHTML   string     `json:"content_html"`
+	Summary       string     `json:"summary,omitempty"`
+	DatePublished time.Time  `json:"date_published"`
+	DateModified  *time.Time `json:"date_modified,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+}
+
+// 項目內容；note 可能含有 HTML，交由 html/template 跳脫
+var jsonFeedContentTemplate = htmltemplate.Must(htmltemplate.New("feed").Parse(
+	`<p>{{.Heading}}</p><ul><li>案號：{{.JobNumber}}</li>` +
+		`{{if .UnitName}}<li>機關：{{.UnitName}}</li>{{end}}` +
+		`{{if .Priority}}<li>優先順序：{{.Priority}}</li>{{end}}` +
+		`{{range .Details}}<li>{{.}}</li>{{end}}</ul>` +
+		`{{if .Note}}<p>{{range $i, $line := .Note}}{{if $i}}<br>{{end}}{{$line}}{{end}}</p>{{end}}`))
+
+type jsonFeedContent struct {
+	Heading   string
+	JobNumber string
+	UnitName  string
+	Priority  int
+	Details   []string
+	Note      []string // 依換行分割
+}
+
+func (c *jsonFeedContent) render() (string, error) {
+	var buf bytes.Buffer
+	if err := jsonFeedContentTemplate.Execute(&buf, c); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// 書籤的項目
+func bookmarkFeedItem(b *Bookmark) (*jsonFeedItem, error) {
+	content := &jsonFeedContent{Heading: "新增書籤：" + b.Title, JobNumber: b.JobNumber, UnitName: b.UnitName, Priority: b.Priority}
+	if b.Type != "" {
+		content.Details = append(content.Details, "公告類型："+b.Type)
+	}
+	if note := strings.TrimSpace(b.Note); note != "" {
+		content.Note = strings.Split(strings.ReplaceAll(note, "\r\n", "\n"), "\n")
+	}
+	html, err := content.render()
+	if err != nil {
+		return nil, err
+	}
+	item := &jsonFeedItem{
+		ID:            "bookmark/" + strconv.Itoa(b.ID),
+		ExternalURL:   b.URL,
+		Title:         firstNonEmpty(b.Title, b.JobNumber),
+		ContentHTML:   html,
+		Summary:       truncateRunes(b.Note, 200),
+		DatePublished: localTime(b.CreatedAt),
+		Tags:          b.MatchedCategories,
+	}
+	if !b.UpdatedAt.IsZero() {
+		modified := localTime(b.UpdatedAt)
+		item.DateModified = &modified
+	}
+	return item, nil
+}
+
+// 標案異動的項目；b 為事件所屬的書籤
+func tenderChangeFeedItem(e *Event, b *Bookmark) (*jsonFeedItem, error) {
+	content := &jsonFeedContent{JobNumber: b.JobNumber, UnitName: b.UnitName, Priority: b.Priority}
+	title := firstNonEmpty(b.Title, b.JobNumber)
+	switch e.Action {
+	case actionAward:
+		var award TenderAward
+		json.Unmarshal(e.Payload, &award)
+		content.Heading = "已決標：" + title
+		if len(award.Companies) > 0 {
+			content.Details = append(content.Details, "得標廠商："+strings.Join(award.Companies, "、"))
+		}
+		if award.Amount != "" {
+			content.Details = append(content.Details, "決標金額："+award.Amount)
+		}
+	default:
+		content.Heading = "標案資料有更新：" + title
+	}
+	html, err := content.render()
+	if err != nil {
+		return nil, err
+	}
+	return &jsonFeedItem{
+		ID:            "event/" + strconv.FormatInt(e.ID, 10),
+		ExternalURL:   b.URL,
+		Title:         content.Heading,
+		ContentHTML:   html,
+		DatePublished: e.CreatedAt,
+		Tags:          []string{e.Type},
+	}, nil
+}
+
+// 整數查詢參數，未指定時為 def
+func intParam(r *http.Request, name string, def, min, max int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+// GET /api/bookmarks/feed.json：最近新增的書籤與標案異動
+func (s *Server) bookmarkFeed(w http.ResponseWriter, r *http.Request) {
+	limit, ok := intParam(r, "limit", jsonFeedDefaultLimit, 1, jsonFeedMaxLimit)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+		return
+	}
+	minPriority, ok := intParam(r, "min_priority", -1<<31, -1<<31, 1<<31-1)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "min_priority")
+		return
+	}
+
+	// 最近新增的書籤
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+bookmarkColumns+" FROM bookmarks WHERE deleted_at IS NULL AND priority >= ? ORDER BY created_at DESC, id DESC LIMIT ?",
+		minPriority, limit)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	var items []*jsonFeedItem
+	for rows.Next() {
+		b, err := scanBookmark(rows, s.db.cipher)
+		if err != nil {
+			rows.Close()
+			writeDBError(w, r, err)
+			return
+		}
+		item, err := bookmarkFeedItem(&b)
+		if err != nil {
+			rows.Close()
+			writeInternalError(w, r, "database_error", err)
+			return
+		}
+		items = append(items, item)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	// 書籤的標案異動
+	rows, err = s.db.QueryContext(r.Context(), `
+		SELECT id, created_at, actor, entity_type, entity_key, action, payload FROM events
+		WHERE entity_type = ? AND action IN (?, ?)
+			AND entity_key IN (SELECT job_number FROM bookmarks WHERE deleted_at IS NULL AND priority >= ?)
+		ORDER BY id DESC LIMIT ?`, entityTender, actionUpdate, actionAward, minPriority, limit)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	events, err := s.scanEvents(rows)
+	rows.Close()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(events) > 0 {
+		bookmarks := map[string]*Bookmark{}
+		var jobNumbers []interface{}
+		for _, e := range events {
+			if _, ok := bookmarks[e.EntityKey]; !ok {
+				bookmarks[e.EntityKey] = nil
+				jobNumbers = append(jobNumbers, e.EntityKey)
+			}
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(jobNumbers)), ", ")
+		rows, err := s.db.QueryContext(r.Context(), "SELECT "+bookmarkColumns+" FROM bookmarks WHERE job_number IN ("+placeholders+")", jobNumbers...)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		for rows.Next() {
+			b, err := scanBookmark(rows, s.db.cipher)
+			if err != nil {
+				rows.Close()
+				writeDBError(w, r, err)
+				return
+			}
+			bookmarks[b.JobNumber] = &b
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		for i := range events {
+			b := bookmarks[events[i].EntityKey]
+			if b == nil {
+				continue
+			}
+			item, err := tenderChangeFeedItem(&events[i], b)
+			if err != nil {
+				writeInternalError(w, r, "database_error", err)
+				return
+			}
+			items = append(items, item)
+		}
+	}
+
+	// 依發布時間由新到舊，同時間時維持書籤在前
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DatePublished.After(items[j].DatePublished) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	if items == nil {
+		items = []*jsonFeedItem{}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	feed := &jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       "政府採購書籤",
+		FeedURL:     fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.RequestURI()),
+		Description: "最近新增的書籤與偵測到的標案異動",
+		Language:    "zh-TW",
+		Items:       items,
+	}
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(feed)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 依 JSON Feed 1.1 規格檢查 feed，回傳項目
+func validateJSONFeed(t *testing.T, body []byte) []map[string]interface{} {
+	t.Helper()
+	var feed map[string]interface{}
+	if err := json.Unmarshal(body, &feed); err != nil {
+		t.Fatalf("feed 不是 JSON 物件: %v", err)
+	}
+	if feed["version"] != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("version = %v", feed["version"])
+	}
+	if title, _ := feed["title"].(string); title == "" {
+		t.Error("缺少 title")
+	}
+	for _, key := range []string{"home_page_url", "feed_url"} {
+		if v, ok := feed[key]; ok {
+			if u, err := url.Parse(v.(string)); err != nil || !u.IsAbs() {
+				t.Errorf("%s 不是絕對網址: %v", key, v)
+			}
+		}
+	}
+	rawItems, ok := feed["items"].([]interface{})
+	if !ok {
+		t.Fatalf("items 必須是陣列: %v", feed["items"])
+	}
+
+	ids := map[string]bool{}
+	items := make([]map[string]interface{}, len(rawItems))
+	for i, raw := range rawItems {
+		item := raw.(map[string]interface{})
+		items[i] = item
+		id, _ := item["id"].(string)
+		if id == "" {
+			t.Errorf("項目 %d 缺少 id", i)
+		}
+		if ids[id] {
+			t.Errorf("項目 id 重複: %s", id)
+		}
+		ids[id] = true
+		_, hasHTML := item["content_html"].(string)
+		_, hasText := item["content_text"].(string)
+		if !hasHTML && !hasText {
+			t.Errorf("項目 %s 缺少 content_html 或 content_text", id)
+		}
+		for _, key := range []string{"date_published", "date_modified"} {
+			if v, ok := item[key]; ok {
+				if _, err := time.Parse(time.RFC3339, v.(string)); err != nil {
+					t.Errorf("項目 %s 的 %s 不是 RFC 3339: %v", id, key, v)
+				}
+			}
+		}
+		if v, ok := item["external_url"]; ok {
+			if u, err := url.Parse(v.(string)); err != nil || !u.IsAbs() {
+				t.Errorf("項目 %s 的 external_url 不是絕對網址: %v", id, v)
+			}
+		}
+	}
+	return items
+}
+
+func TestBookmarkFeed(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"低優先","priority":1,"url":"https://web.pcc.gov.tw/tps/a"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"高優先","priority":5,"note":"<script>alert(1)</script>\n第二行","url":"https://web.pcc.gov.tw/tps/b"}`)
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		return writeEvent(tx, systemActor, entityTender, "A002", actionAward, TenderAward{Companies: []string{"大道營造"}, Amount: "100元"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, "GET", "/api/bookmarks/feed.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/feed+json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	items := validateJSONFeed(t, w.Body.Bytes())
+	if len(items) != 3 {
+		t.Fatalf("項目數 = %d, want 3", len(items))
+	}
+
+	byID := map[string]map[string]interface{}{}
+	for _, item := range items {
+		byID[item["id"].(string)] = item
+	}
+	var note map[string]interface{}
+	for id, item := range byID {
+		if strings.HasPrefix(id, "bookmark/") && item["title"] == "高優先" {
+			note = item
+		}
+	}
+	if note == nil {
+		t.Fatalf("找不到書籤項目: %v", byID)
+	}
+	html := note["content_html"].(string)
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") || !strings.Contains(html, "<br>第二行") {
+		t.Errorf("備註未正確跳脫: %s", html)
+	}
+	if note["external_url"] != "https://web.pcc.gov.tw/tps/b" || note["date_modified"] == nil {
+		t.Errorf("書籤項目 = %v", note)
+	}
+	var award map[string]interface{}
+	for id, item := range byID {
+		if strings.HasPrefix(id, "event/") {
+			award = item
+		}
+	}
+	if award == nil || !strings.Contains(award["content_html"].(string), "大道營造") {
+		t.Errorf("決標項目 = %v", award)
+	}
+
+	// id 不隨請求改變
+	again := validateJSONFeed(t, serve(t, h, "GET", "/api/bookmarks/feed.json", "").Body.Bytes())
+	for _, item := range again {
+		if byID[item["id"].(string)] == nil {
+			t.Errorf("第二次請求出現新的 id: %v", item["id"])
+		}
+	}
+}
+
+func TestBookmarkFeedParams(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"低","priority":1}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"中","priority":3}`)
+	addTestBookmark(t, h, `{"job_number":"A003","title":"高","priority":5}`)
+
+	tests := []struct {
+		target string
+		status int
+		titles []string
+	}{
+		{"/api/bookmarks/feed.json", http.StatusOK, []string{"高", "中", "低"}},
+		{"/api/bookmarks/feed.json?min_priority=3", http.StatusOK, []string{"高", "中"}},
+		{"/api/bookmarks/feed.json?limit=1", http.StatusOK, []string{"高"}},
+		{"/api/bookmarks/feed.json?min_priority=9", http.StatusOK, []string{}},
+		{"/api/bookmarks/feed.json?limit=0", http.StatusBadRequest, nil},
+		{"/api/bookmarks/feed.json?limit=501", http.StatusBadRequest, nil},
+		{"/api/bookmarks/feed.json?min_priority=x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			items := validateJSONFeed(t, w.Body.Bytes())
+			titles := make([]string, len(items))
+			for i, item := range items {
+				titles[i] = item["title"].(string)
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.titles, ",") {
+				t.Errorf("titles = %v, want %v", titles, tt.titles)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DataFile 資料目錄中的檔案
+type DataFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// 可列出的資料目錄：名稱 → 本機路徑
+// 只允許靜態掛載的目錄與標書下載目錄，避免被用來瀏覽整個檔案系統
+func (s *Server) dataRoots() map[string]string {
+	roots := map[string]string{
+		filepath.Base(s.tendersDir()): s.tendersDir(),
+	}
+	for _, m := range s.cfg.StaticMounts {
+		roots[filepath.Base(m.Directory)] = m.Directory
+	}
+	return roots
+}
+
+// 列出資料目錄中的檔案（不含子目錄），依名稱排序，可用 glob 篩選檔名
+func (s *Server) listDataFiles(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("dir")
+	dir, ok := s.dataRoots()[name]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "unknown_data_dir", name)
+		return
+	}
+
+	pattern := r.URL.Query().Get("glob")
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "glob")
+			return
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+
+	files := make([]DataFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if pattern != "" {
+			if matched, _ := path.Match(pattern, entry.Name()); !matched {
+				continue
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, DataFile{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dir":   name,
+		"files": files,
+	})
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 篩選結果查詢
+//
+// GET /api/tenders 讀取 <年度資料目錄>/filtered_for_company 下（含子目錄）所有的 .jsonl（每行一筆）與 .json（陣列）檔案，
+// 解析為 Tender 後依 ?date_from= ?date_to= ?category= ?keyword= ?unit_name= 篩選（語意與 GET /api/bookmarks 相同），
+// GET /api/tenders/stats 回傳每天與每個類別的筆數。all_matched.jsonl 與各類別的檔案內容重複，依 job_number 合併。
+// 解析結果依檔案路徑快取在記憶體，每次請求只比對檔案的修改時間與大小，有變動的檔案才重新讀取，刪除的檔案移出快取。
+// 無法解析的檔案略過並寫入日誌，列在回應的 skipped_files，不影響其他檔案。
+
+// 一個檔案的解析結果；無法解析時 err 不為空，同樣快取到檔案變動為止，不會每次請求都重新讀取
+type parsedTenderFile struct {
+	modTime time.Time
+	size    int64
+	tenders []Tender
+	err     error
+}
+
+var tenderFileCache = struct {
+	sync.Mutex
+	files map[string]*parsedTenderFile
+}{files: map[string]*parsedTenderFile{}}
+
+// SkippedTenderFile 略過的檔案，路徑相對於篩選結果目錄
+type SkippedTenderFile struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// 篩選結果目錄
+func filteredTenderDir(year int) string {
+	return filepath.Join(yearDir(year), "filtered_for_company")
+}
+
+// 解析一個檔案；.jsonl 任一行錯誤即整個檔案略過，避免只列出部分內容而不自知
+func parseTenderFile(path string) ([]Tender, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".json" {
+		var tenders []Tender
+		if err := json.Unmarshal(content, &tenders); err != nil {
+			return nil, err
+		}
+		for i := range tenders {
+			normalizeTenderTerms(&tenders[i])
+		}
+		return tenders, nil
+	}
+	tenders := make([]Tender, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var t Tender
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		normalizeTenderTerms(&t)
+		tenders = append(tenders, t)
+	}
+	return tenders, scanner.Err()
+}
+
+// 沒有類別或關鍵字時回傳 []，不是 null
+func normalizeTenderTerms(t *Tender) {
+	if t.MatchedCategories == nil {
+		t.MatchedCategories = []string{}
+	}
+	if t.MatchedKeywords == nil {
+		t.MatchedKeywords = []string{}
+	}
+}
+
+// 讀取目錄下所有的篩選結果，依 job_number 合併（類別與關鍵字取聯集）；目錄不存在時回傳空的結果
+// 回傳的 Tender 不可修改，快取中的資料與其共用
+func loadFilteredTenders(dir string) ([]Tender, []SkippedTenderFile, int, error) {
+	type fileInfo struct {
+		path    string
+		modTime time.Time
+		size    int64
+	}
+	var files []fileInfo
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); d.IsDir() || (ext != ".json" && ext != ".jsonl") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, fileInfo{path, info.ModTime(), info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	// all_matched.jsonl 排在各類別的檔案之前，合併時以它為主
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	// 先找出需要重新讀取的檔案，讀取時不持有鎖
+	parsed := make([]*parsedTenderFile, len(files))
+	tenderFileCache.Lock()
+	for i, f := range files {
+		if c, ok := tenderFileCache.files[f.path]; ok && c.modTime.Equal(f.modTime) && c.size == f.size {
+			parsed[i] = c
+		}
+	}
+	tenderFileCache.Unlock()
+	for i, f := range files {
+		if parsed[i] != nil {
+			continue
+		}
+		tenders, err := parseTenderFile(f.path)
+		if err != nil {
+			log.Printf("篩選結果 %s 無法解析，已略過: %v", f.path, err)
+		}
+		parsed[i] = &parsedTenderFile{modTime: f.modTime, size: f.size, tenders: tenders, err: err}
+	}
+	tenderFileCache.Lock()
+	seen := make(map[string]bool, len(files))
+	for i, f := range files {
+		tenderFileCache.files[f.path] = parsed[i]
+		seen[f.path] = true
+	}
+	prefix := dir + string(filepath.Separator)
+	for path := range tenderFileCache.files {
+		if strings.HasPrefix(path, prefix) && !seen[path] {
+			delete(tenderFileCache.files, path)
+		}
+	}
+	tenderFileCache.Unlock()
+
+	skipped := make([]SkippedTenderFile, 0)
+	var tenders []Tender
+	index := map[string]int{}
+	for i, p := range parsed {
+		if p.err != nil {
+			rel, _ := filepath.Rel(dir, files[i].path)
+			skipped = append(skipped, SkippedTenderFile{File: filepath.ToSlash(rel), Error: p.err.Error()})
+			continue
+		}
+		for _, t := range p.tenders {
+			j, ok := index[t.JobNumber]
+			if !ok || t.JobNumber == "" {
+				index[t.JobNumber] = len(tenders)
+				tenders = append(tenders, t)
+				continue
+			}
+			merged := &tenders[j]
+			merged.MatchedCategories = mergeTerms(merged.MatchedCategories, t.MatchedCategories)
+			merged.MatchedKeywords = mergeTerms(merged.MatchedKeywords, t.MatchedKeywords)
+		}
+	}
+	return tenders, skipped, len(files) - len(skipped), nil
+}
+
+// a 加上 b 中 a 沒有的項目；沒有新增時直接回傳 a，有新增時回傳新的切片，不修改快取中的資料
+func mergeTerms(a, b []string) []string {
+	var extra []string
+	for _, term := range b {
+		if !containsTerm(a, term) && !containsTerm(extra, term) {
+			extra = append(extra, term)
+		}
+	}
+	if len(extra) == 0 {
+		return a
+	}
+	return append(append(make([]string, 0, len(a)+len(extra)), a...), extra...)
+}
+
+// 標案的篩選條件，參數格式錯誤時回傳該參數的名稱
+type tenderFilter struct {
+	dateFrom, dateTo            TenderDate
+	category, keyword, unitName string
+}
+
+func parseTenderFilter(r *http.Request) (tenderFilter, string) {
+	query := r.URL.Query()
+	f := tenderFilter{category: query.Get("category"), keyword: query.Get("keyword"), unitName: query.Get("unit_name")}
+	for _, p := range []struct {
+		param string
+		value *TenderDate
+	}{{"date_from", &f.dateFrom}, {"date_to", &f.dateTo}} {
+		v := query.Get(p.param)
+		if v == "" {
+			continue
+		}
+		d, err := parseTenderDate(v)
+		if err != nil || d == 0 {
+			return f, p.param
+		}
+		*p.value = d
+	}
+	return f, ""
+}
+
+// 指定日期範圍時沒有日期的標案不列出
+func (f *tenderFilter) match(t *Tender) bool {
+	if (f.dateFrom != 0 || f.dateTo != 0) && t.Date == 0 {
+		return false
+	}
+	if (f.dateFrom != 0 && t.Date < f.dateFrom) || (f.dateTo != 0 && t.Date > f.dateTo) {
+		return false
+	}
+	if (f.category != "" && !containsTerm(t.MatchedCategories, f.category)) ||
+		(f.keyword != "" && !containsTerm(t.MatchedKeywords, f.keyword)) {
+		return false
+	}
+	return f.unitName == "" || strings.Contains(t.UnitName, f.unitName)
+}
+
+// 讀取並篩選標案；發生錯誤時已回應，回傳 ok 為 false
+func (s *Server) filteredTenders(w http.ResponseWriter, r *http.Request) ([]Tender, []SkippedTenderFile, int, bool) {
+	filter, badParam := parseTenderFilter(r)
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return nil, nil, 0, false
+	}
+	tenders, skipped, files, err := loadFilteredTenders(filteredTenderDir(s.cfg.Year))
+	if err != nil {
+		writeInternalError(w, r, "file_error", err)
+		return nil, nil, 0, false
+	}
+	matched := make([]Tender, 0)
+	for i := range tenders {
+		if filter.match(&tenders[i]) {
+			matched = append(matched, tenders[i])
+		}
+	}
+	return matched, skipped, files, true
+}
+
+// GET /api/tenders：篩選結果中的標案，依日期由新到舊
+// ?page= 與 ?page_size= 分頁（page_size 預設 50，0 表示全部）
+func (s *Server) getTenders(w http.ResponseWriter, r *http.Request) {
+	page, ok := intParam(r, "page", 1, 1, 1<<31-1)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page")
+		return
+	}
+	pageSize, ok := intParam(r, "page_size", bookmarkDefaultPageSize, 0, bookmarkMaxPageSize)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page_size")
+		return
+	}
+	tenders, skipped, files, ok := s.filteredTenders(w, r)
+	if !ok {
+		return
+	}
+	sort.SliceStable(tenders, func(i, j int) bool {
+		if tenders[i].Date != tenders[j].Date {
+			return tenders[i].Date > tenders[j].Date
+		}
+		return tenders[i].JobNumber < tenders[j].JobNumber
+	})
+
+	total := len(tenders)
+	if pageSize > 0 {
+		start := min(int64(page-1)*int64(pageSize), int64(total))
+		end := min(start+int64(pageSize), int64(total))
+		tenders = tenders[start:end]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":         total,
+		"page":          page,
+		"page_size":     pageSize,
+		"items":         tenders,
+		"files":         files,
+		"skipped_files": skipped,
+	})
+}
+
+// GET /api/tenders/stats：符合篩選條件的標案每天（YYYY-MM-DD）與每個類別的筆數；沒有日期的標案列在 undated
+func (s *Server) getTenderStats(w http.ResponseWriter, r *http.Request) {
+	tenders, skipped, files, ok := s.filteredTenders(w, r)
+	if !ok {
+		return
+	}
+	byDate, byCategory := map[string]int{}, map[string]int{}
+	undated := 0
+	for _, t := range tenders {
+		if t.Date == 0 {
+			undated++
+		} else {
+			byDate[t.Date.String()]++
+		}
+		for _, c := range t.MatchedCategories {
+			byCategory[c]++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":         len(tenders),
+		"undated":       undated,
+		"by_date":       byDate,
+		"by_category":   byCategory,
+		"files":         files,
+		"skipped_files": skipped,
+	})
+}
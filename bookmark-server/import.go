@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 匯入書籤
+//
+// POST /api/bookmarks/import?mode=skip|overwrite|merge 接受 GET /api/bookmarks/export 匯出的 JSON 陣列，
+// 可直接作為請求內容，或以 multipart/form-data 的 file 欄位上傳。
+// 每一筆必須有 job_number 與 title，data 必須是 JSON 物件；不符合的列在 errors（索引與原因），其餘照常匯入。
+// 所有寫入在同一筆交易中完成，資料庫錯誤時全部不寫入。
+//
+// 案號已存在時依 mode 處理：
+//   - skip（預設）：保留既有書籤
+//   - overwrite：以匯入的內容取代（保留既有的 id 與 created_at），狀態不同時記錄狀態歷程
+//   - merge：只比較備註與優先級，匯入的 created_at 較新時採用匯入的值，其他欄位（含標籤）保留既有的
+//
+// id、version、archived、matched_categories、matched_keywords 由本機產生，匯入時忽略；沒有 tags 欄位時匯入的書籤沒有標籤，
+// 沒有 status 欄位時新增的書籤為 watching、取代時保留既有的狀態。
+
+const bookmarkImportMaxBytes = 64 << 20
+
+// 匯入模式
+const (
+	importSkip      = "skip"
+	importOverwrite = "overwrite"
+	importMerge     = "merge"
+)
+
+// ImportError 驗證失敗而未匯入的一筆
+type ImportError struct {
+	Index     int    `json:"index"` // 在陣列中的位置（從 0 開始）
+	JobNumber string `json:"job_number,omitempty"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+}
+
+// 讀取要匯入的 JSON：multipart 時取 file 欄位，否則為請求內容
+func importBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("缺少 file 欄位")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// 驗證一筆匯入的書籤，回傳標準化後的內容；失敗時回傳錯誤代碼與訊息參數
+func checkImportRecord(raw json.RawMessage) (*Bookmark, string, []interface{}) {
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return &Bookmark{}, "import_invalid_record", []interface{}{"必須是 JSON 物件"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var b Bookmark
+	if err := dec.Decode(&b); err != nil {
+		return &b, "import_invalid_record", []interface{}{translateDecodeError(err, dec.InputOffset()).Error()}
+	}
+
+	jn, code := checkJobNumber(b.JobNumber)
+	switch code {
+	case "missing_job_number":
+		return &b, code, nil
+	case "invalid_job_number":
+		return &b, code, []interface{}{maxJobNumberLength, jobNumberPunct}
+	}
+	b.JobNumber = jn
+	if strings.TrimSpace(b.Title) == "" {
+		return &b, "import_missing_title", nil
+	}
+	if b.Tags != nil {
+		tags, err := normalizeTags(b.Tags)
+		if err != nil {
+			return &b, "invalid_tags", []interface{}{err.Error()}
+		}
+		b.Tags = tags
+	}
+	if b.Status != "" && !validStatus(b.Status) {
+		return &b, "invalid_status", []interface{}{b.Status, strings.Join(bookmarkStatuses, ", ")}
+	}
+	if err := validateData(b.Data); err != nil {
+		return &b, "invalid_data", []interface{}{err.Error()}
+	}
+	// 與新增書籤相同，標案名稱等欄位以 data 為準
+	cols := mirroredColumns{Title: b.Title, UnitName: b.UnitName, Type: b.Type, Date: b.Date, URL: b.URL, APIURL: b.APIURL}
+	synced, err := syncDataColumns(b.Data, &cols)
+	if err != nil {
+		return &b, "invalid_data", []interface{}{err.Error()}
+	}
+	b.Title, b.UnitName, b.Type, b.Date, b.URL, b.APIURL = cols.Title, cols.UnitName, cols.Type, cols.Date, cols.URL, cols.APIURL
+	b.Data = synced
+	if strings.TrimSpace(b.Title) == "" {
+		return &b, "import_missing_title", nil
+	}
+	return &b, "", nil
+}
+
+// POST /api/bookmarks/import?mode=skip|overwrite|merge
+func (s *Server) importBookmarks(w http.ResponseWriter, r *http.Request) {
+	mode := firstNonEmpty(r.URL.Query().Get("mode"), importSkip)
+	if mode != importSkip && mode != importOverwrite && mode != importMerge {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "mode")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, bookmarkImportMaxBytes)
+	body, err := importBody(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "file: "+err.Error())
+		return
+	}
+	r.Body = io.NopCloser(body)
+	var raw json.RawMessage
+	if err := decodeJSONBody(r, &raw); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		writeDecodeError(w, r, &bodyError{Reason: "必須是匯出的書籤陣列"})
+		return
+	}
+
+	// 先驗證所有的列，同一個案號出現多次時只匯入第一筆
+	lang := requestLanguage(r)
+	var records []*Bookmark
+	importErrors := make([]ImportError, 0)
+	seen := map[string]int{}
+	for i, raw := range raws {
+		b, code, args := checkImportRecord(raw)
+		if code == "" {
+			if first, ok := seen[b.JobNumber]; ok {
+				code, args = "import_duplicate_job_number", []interface{}{first}
+			}
+		}
+		if code != "" {
+			importErrors = append(importErrors, ImportError{Index: i, JobNumber: b.JobNumber, Error: code, Message: localize(lang, code, args...)})
+			continue
+		}
+		seen[b.JobNumber] = i
+		records = append(records, b)
+	}
+
+	counts := map[string]int{"imported": 0, "overwritten": 0, "merged": 0, "skipped": 0}
+	var changed []string
+	actor := requestActor(r)
+	now := time.Now()
+	err = s.db.withTx(r.Context(), func(tx *Tx) error {
+		for _, b := range records {
+			existing, err := bookmarkSnapshot(tx, b.JobNumber)
+			if err != nil {
+				return err
+			}
+			switch {
+			case existing == nil:
+				sb := syncBookmarkOf(b)
+				if sb.CreatedAt.IsZero() {
+					sb.CreatedAt = now
+				}
+				sb.UpdatedAt = now
+				if err := applySyncUpsert(tx, actor, b.JobNumber, sb, true); err != nil {
+					return err
+				}
+				counts["imported"]++
+			case mode == importOverwrite:
+				sb := syncBookmarkOf(b)
+				sb.UpdatedAt = now
+				if err := applySyncUpsert(tx, actor, b.JobNumber, sb, false); err != nil {
+					return err
+				}
+				counts["overwritten"]++
+			case mode == importMerge && b.CreatedAt.After(existing.CreatedAt) && (b.Note != existing.Note || b.Priority != existing.Priority):
+				sb := syncBookmarkOf(existing)
+				sb.Note, sb.Priority, sb.UpdatedAt = b.Note, b.Priority, now
+				if err := applySyncUpsert(tx, actor, b.JobNumber, sb, false); err != nil {
+					return err
+				}
+				counts["merged"]++
+			default:
+				counts["skipped"]++
+				continue
+			}
+			changed = append(changed, b.JobNumber)
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("匯入書籤（%s）：新增 %d、取代 %d、合併 %d、略過 %d、格式錯誤 %d",
+			mode, counts["imported"], counts["overwritten"], counts["merged"], counts["skipped"], len(importErrors)), changed...))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(changed) > 0 {
+		s.markChanged()
+	}
+
+	writeSuccess(w, r, http.StatusOK, "bookmarks_imported", map[string]interface{}{
+		"mode":        mode,
+		"total":       len(raws),
+		"imported":    counts["imported"],
+		"overwritten": counts["overwritten"],
+		"merged":      counts["merged"],
+		"skipped":     counts["skipped"],
+		"errors":      importErrors,
+	})
+}
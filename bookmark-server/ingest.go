@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每日標案清單下載與篩選
+//
+// 取代 download_2026.py 與 filter_tenders.py：每天 ingest.time（顯示時區）之後，
+// 從 ingest.url（預設為 pcc.g0v.tw 的 API）下載當天的標案清單（listbydate），
+// 原始回應存於 <年度資料目錄>/raw/YYYYMMDD.json，再以類別關鍵字篩選，
+// 結果依 job_number 合併寫入 filtered_for_company/all_matched.jsonl 與各類別的 <類別>.jsonl（格式與 filter_tenders.py 相同）。
+// 符合關注清單（watchlist，類別或關鍵字）的標案自動加入書籤（操作者為 system），並送出 watchlist_bookmark 通知；
+// 每個標案只自動加入一次，使用者刪除後重新執行不會再加回來。
+// 每天的執行結果記錄於 ingest_runs，以 GET /api/ingest/status 查詢；失敗時依 ingestRetryDelay 重試，
+// 錯過的日子（例如伺服器未執行）在 days 天內補抓。同一天重新執行（POST /api/ingest/run?date=）結果相同。
+
+// 執行狀態
+const (
+	ingestRunning = "running"
+	ingestSuccess = "success"
+	ingestFailed  = "failed"
+)
+
+// 排程檢查是否該下載的間隔
+const ingestCheckInterval = 10 * time.Minute
+
+// IngestCategory 篩選類別與其關鍵字
+type IngestCategory struct {
+	Name             string   `json:"name"`
+	Keywords         []string `json:"keywords"`
+	NegativeKeywords []string `json:"negative_keywords,omitempty"` // 標題含有這些字時不算符合此類別
+	Priority         int      `json:"priority"`                    // 依此類別自動加入書籤時的優先順序
+}
+
+// IngestConfig 每日下載設定
+type IngestConfig struct {
+	Enabled    bool             `json:"enabled"`
+	URL        string           `json:"url"`        // API 位址，預設 https://pcc-api.openfun.app/api
+	Time       string           `json:"time"`       // 每天下載的時間（HH:MM），預設 "07:00"
+	Days       int              `json:"days"`       // 補抓最近幾天（含今天）未成功的日子，預設 3
+	Categories []IngestCategory `json:"categories"` // 類別與關鍵字，未設定時與 filter_tenders.py 相同；另加上匯入的能力清單（見 watchlist.go）
+	Exclude    []string         `json:"exclude"`    // 標題含有這些字時排除
+	BidTypes   []string         `json:"bid_types"`  // 可投標的公告類型
+	Watchlist  []string         `json:"watchlist"`  // 符合這些類別或關鍵字的標案自動加入書籤；匯入的能力一律在關注清單中
+}
+
+// filter_tenders.py 的預設值
+var (
+	defaultIngestCategories = []IngestCategory{
+		{Name: "廣告行銷", Keywords: []string{"廣告", "行銷", "宣傳", "推廣", "公關", "媒體", "社群", "SEO", "品牌", "形象",
+			"企劃", "活動", "展覽", "文宣", "海報", "傳播", "DM", "EDM", "曝光"}},
+		{Name: "軟體開發", Keywords: []string{"軟體", "程式", "APP", "應用程式", "系統開發", "資訊系統", "開發案", "客製化",
+			"模組", "功能開發", "API", "後台", "前端", "後端", "程式設計"}},
+		{Name: "網站設計", Keywords: []string{"網站", "網頁", "官網", "入口網", "平台", "網路平台", "Web", "RWD",
+			"響應式", "電子商務", "購物網站", "形象網站", "入口網站"}},
+		{Name: "AI部署", Keywords: []string{"AI", "人工智慧", "機器學習", "深度學習", "ChatGPT", "GPT", "LLM",
+			"大語言模型", "智慧", "演算法", "模型", "自動化", "智能"}},
+		{Name: "視覺設計", Keywords: []string{"視覺", "設計", "美編", "美術", "LOGO", "CIS", "VI", "識別系統",
+			"平面設計", "圖像", "插畫", "排版", "版面", "UI", "UX", "介面設計",
+			"多媒體", "影片", "動畫", "剪輯", "後製"}},
+	}
+	defaultIngestExclude = []string{"工程", "營造", "建築", "土木", "機電", "水電", "消防", "空調",
+		"監造", "結構", "鋼構", "裝修", "裝潢", "木作", "油漆",
+		"醫療", "藥品", "器材", "儀器", "設備採購", "硬體",
+		"清潔", "保全", "警衛", "餐飲", "伙食", "便當",
+		"印刷", "油墨", "紙張"}
+	defaultIngestBidTypes = []string{
+		"公開招標公告",
+		"公開取得報價單或企劃書公告",
+		"經公開評選或公開徵求之限制性招標公告",
+		"選擇性招標(個案)公告",
+	}
+)
+
+// 補上預設值並檢查設定
+func (c *IngestConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.URL == "" {
+		c.URL = "https://pcc-api.openfun.app/api"
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("ingest.url 必須是 http 或 https 網址: %q", c.URL)
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.Time == "" {
+		c.Time = "07:00"
+	}
+	if _, err := time.Parse("15:04", c.Time); err != nil {
+		return fmt.Errorf("ingest.time 格式錯誤（HH:MM）: %q", c.Time)
+	}
+	if c.Days == 0 {
+		c.Days = 3
+	}
+	if c.Days < 1 {
+		return fmt.Errorf("ingest.days 必須大於 0")
+	}
+	if c.Categories == nil {
+		c.Categories = defaultIngestCategories
+	}
+	for _, cat := range c.Categories {
+		if cat.Name == "" || strings.ContainsAny(cat.Name, `/\`) || len(cat.Keywords) == 0 {
+			return fmt.Errorf("ingest.categories 的類別名稱不可為空或含有斜線，且至少要有一個關鍵字: %q", cat.Name)
+		}
+	}
+	if c.Exclude == nil {
+		c.Exclude = defaultIngestExclude
+	}
+	if c.BidTypes == nil {
+		c.BidTypes = defaultIngestBidTypes
+	}
+	return nil
+}
+
+// 失敗後等待的時間：15 分鐘起每次加倍，最長 6 小時
+func ingestRetryDelay(attempts int) time.Duration {
+	delay := 15 * time.Minute
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+// listbydate 回應中的一筆標案
+type pccListRecord struct {
+	Date         int    `json:"date"`
+	JobNumber    string `json:"job_number"`
+	UnitName     string `json:"unit_name"`
+	URL          string `json:"url"`
+	TenderAPIURL string `json:"tender_api_url"`
+	Brief        struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Category string `json:"category"`
+	} `json:"brief"`
+}
+
+// 篩選結果的一行，欄位與 filter_tenders.py 輸出的 all_matched.jsonl 相同
+type filteredTender struct {
+	Date              int      `json:"date"`
+	Title             string   `json:"title"`
+	Type              string   `json:"type"`
+	UnitName          string   `json:"unit_name"`
+	JobNumber         string   `json:"job_number"`
+	URL               string   `json:"url"`
+	APIURL            string   `json:"api_url"`
+	MatchedCategories []string `json:"matched_categories"`
+	MatchedKeywords   []string `json:"matched_keywords"`
+
+	priority int // 自動加入書籤時的優先順序（見 watched）
+}
+
+// 篩選一筆標案：公告類型須可投標、標題不含排除字，且至少符合一個類別（每個類別取第一個符合的關鍵字）
+// 標題含有類別的排除關鍵字時不算符合該類別
+func (c *IngestConfig) match(rec *pccListRecord, categories []IngestCategory) (*filteredTender, bool) {
+	title := rec.Brief.Title
+	if !containsString(c.BidTypes, rec.Brief.Type) {
+		return nil, false
+	}
+	for _, kw := range c.Exclude {
+		if strings.Contains(title, kw) {
+			return nil, false
+		}
+	}
+	lower := strings.ToLower(title)
+	t := &filteredTender{
+		Date:      rec.Date,
+		Title:     title,
+		Type:      rec.Brief.Type,
+		UnitName:  firstNonEmpty(rec.UnitName, "未知機關"),
+		JobNumber: rec.JobNumber,
+		URL:       "https://web.pcc.gov.tw" + rec.URL,
+		APIURL:    rec.TenderAPIURL,
+	}
+categories:
+	for _, cat := range categories {
+		for _, kw := range cat.NegativeKeywords {
+			if strings.Contains(lower, strings.ToLower(kw)) {
+				continue categories
+			}
+		}
+		for _, kw := range cat.Keywords {
+			if strings.Contains(lower, strings.ToLower(kw)) {
+				t.MatchedCategories = append(t.MatchedCategories, cat.Name)
+				t.MatchedKeywords = append(t.MatchedKeywords, kw)
+				break
+			}
+		}
+	}
+	return t, len(t.MatchedCategories) > 0
+}
+
+// 是否符合關注清單（類別名稱或符合的關鍵字），並設定自動加入書籤的優先順序為符合的類別中最高的
+func watched(t *filteredTender, categories []IngestCategory, watchlist []string) bool {
+	found := false
+	for i, name := range t.MatchedCategories {
+		if !containsString(watchlist, name) && !containsString(watchlist, t.MatchedKeywords[i]) {
+			continue
+		}
+		for _, cat := range categories {
+			if cat.Name == name && (!found || cat.Priority > t.priority) {
+				t.priority = cat.Priority
+			}
+		}
+		found = true
+	}
+	return found
+}
+
+// IngestRun 一天的執行紀錄
+type IngestRun struct {
+	Date          string     `json:"date"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	Fetched       int        `json:"fetched"`
+	Matched       int        `json:"matched"`
+	Bookmarked    int        `json:"bookmarked"`
+	Error         string     `json:"error"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // 失敗時下次重試的時間
+}
+
+// 可為空的時間，零值為 nil
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = localTime(t)
+	return &t
+}
+
+// 同一時間只執行一次（排程與手動執行共用）
+var ingestMu sync.Mutex
+
+var ingestClient = &http.Client{Timeout: 2 * time.Minute}
+
+// 下載、篩選並寫入一天的標案，回傳執行紀錄
+func (s *Server) runIngest(ctx context.Context, day time.Time) (*IngestRun, error) {
+	ingestMu.Lock()
+	defer ingestMu.Unlock()
+
+	run := &IngestRun{Date: day.Format("2006-01-02"), Status: ingestRunning, StartedAt: time.Now()}
+	err := s.db.QueryRowContext(ctx, "SELECT attempts FROM ingest_runs WHERE date = ?", run.Date).Scan(&run.Attempts)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	run.Attempts++
+	_, err = s.db.execWrite(ctx, `
+		INSERT INTO ingest_runs (date, status, attempts, started_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(date) DO UPDATE SET status = excluded.status, attempts = excluded.attempts, started_at = excluded.started_at
+	`, run.Date, run.Status, run.Attempts, dbTime(run.StartedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	runErr := s.ingestDay(ctx, day, run)
+	finished := time.Now()
+	var next interface{}
+	run.Status = ingestSuccess
+	run.FinishedAt = optionalTime(finished)
+	if runErr != nil {
+		run.Status = ingestFailed
+		run.Error = truncateMessage(runErr.Error())
+		run.NextAttemptAt = optionalTime(finished.Add(ingestRetryDelay(run.Attempts)))
+		next = dbTime(*run.NextAttemptAt)
+	}
+	_, err = s.db.execWrite(context.Background(), `
+		UPDATE ingest_runs SET status = ?, fetched = ?, matched = ?, bookmarked = ?, error = ?, finished_at = ?, next_attempt_at = ?
+		WHERE date = ?
+	`, run.Status, run.Fetched, run.Matched, run.Bookmarked, run.Error, dbTime(finished), next, run.Date)
+	if err != nil {
+		log.Println("寫入下載紀錄失敗:", err)
+	}
+	run.StartedAt = localTime(run.StartedAt)
+	return run, runErr
+}
+
+func (s *Server) ingestDay(ctx context.Context, day time.Time, run *IngestRun) error {
+	cfg := s.cfg.Ingest
+	date := day.Format("20060102")
+	body, err := fetchTenderList(ctx, cfg.URL, date)
+	if err != nil {
+		return err
+	}
+	var list struct {
+		Records []pccListRecord `json:"records"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return fmt.Errorf("解析標案清單失敗: %w", err)
+	}
+	run.Fetched = len(list.Records)
+
+	dir := yearDir(day.Year())
+	if err := writeFileAtomic(filepath.Join(dir, "raw", date+".json"), body); err != nil {
+		return err
+	}
+
+	categories, watchlist, err := s.watchCategories()
+	if err != nil {
+		return err
+	}
+	var matches []*filteredTender
+	for i := range list.Records {
+		if list.Records[i].JobNumber == "" {
+			continue
+		}
+		if t, ok := cfg.match(&list.Records[i], categories); ok {
+			matches = append(matches, t)
+		}
+	}
+	run.Matched = len(matches)
+	if err := upsertFilteredTenders(filepath.Join(dir, "filtered_for_company"), matches); err != nil {
+		return err
+	}
+
+	var watchedTenders []*filteredTender
+	for _, t := range matches {
+		if watched(t, categories, watchlist) {
+			watchedTenders = append(watchedTenders, t)
+		}
+	}
+	added, err := s.autoBookmark(ctx, watchedTenders)
+	run.Bookmarked = len(added)
+	for _, jobNumber := range added {
+		s.notifyBookmark(triggerWatchlistBookmark, jobNumber)
+	}
+	return err
+}
+
+// 下載一天的標案清單
+func fetchTenderList(ctx context.Context, baseURL, date string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/listbydate?date="+date, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "bookmark-server")
+	resp, err := ingestClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &upstreamStatusError{status: resp.Status, code: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// 寫入暫存檔後改名，讀取端不會看到寫到一半的檔案
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ingest-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 依 job_number 合併寫入 all_matched.jsonl 與各類別的檔案：已有的行原地取代，新的附加在最後
+func upsertFilteredTenders(dir string, matches []*filteredTender) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	files := map[string][]*filteredTender{"all_matched": matches}
+	for _, t := range matches {
+		for _, c := range t.MatchedCategories {
+			files[c] = append(files[c], t)
+		}
+	}
+	for name, items := range files {
+		if err := upsertJSONL(filepath.Join(dir, name+".jsonl"), items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertJSONL(path string, items []*filteredTender) error {
+	var lines [][]byte
+	index := map[string]int{}
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64<<10), 4<<20)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			var t struct {
+				JobNumber string `json:"job_number"`
+			}
+			if json.Unmarshal(line, &t) == nil && t.JobNumber != "" {
+				index[t.JobNumber] = len(lines)
+			}
+			lines = append(lines, line)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	for _, t := range items {
+		line, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[t.JobNumber]; ok {
+			lines[i] = line
+			continue
+		}
+		index[t.JobNumber] = len(lines)
+		lines = append(lines, line)
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// 將關注清單的標案加入書籤，回傳新加入的案號；已有書籤或曾經自動加入過的略過
+func (s *Server) autoBookmark(ctx context.Context, tenders []*filteredTender) ([]string, error) {
+	var added []string
+	for _, t := range tenders {
+		raw, err := json.Marshal(t)
+		if err != nil {
+			return added, err
+		}
+		date, _ := tenderDateFromInt(t.Date)
+		var created bool
+		err = s.db.withTx(ctx, func(tx *Tx) error {
+			// 每個標案只自動加入一次
+			result, err := tx.Exec("INSERT INTO ingest_bookmarks (job_number, created_at) VALUES (?, ?) ON CONFLICT(job_number) DO NOTHING", t.JobNumber, dbNow())
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return nil
+			}
+			data, err := tx.cipher.seal("data", string(raw))
+			if err != nil {
+				return err
+			}
+			result, err = tx.Exec(`
+				INSERT INTO bookmarks (job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, '', ?, ?, ?, ?)
+				ON CONFLICT(job_number) DO NOTHING
+			`, t.JobNumber, t.Title, t.UnitName, t.URL, t.APIURL, t.Type, date, t.priority, data, dbNow(), dbNow())
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return nil
+			}
+			created = true
+			if err := syncBookmarkTerms(tx, t.JobNumber, string(raw)); err != nil {
+				return err
+			}
+			saved, err := bookmarkSnapshot(tx, t.JobNumber)
+			if err != nil {
+				return err
+			}
+			if err := writeEvent(tx, systemActor, entityBookmark, t.JobNumber, actionCreate, saved); err != nil {
+				return err
+			}
+			return writeAudit(tx, AuditEntry{
+				Actor:      systemActor,
+				Method:     "INGEST",
+				Path:       "watchlist",
+				JobNumbers: []string{t.JobNumber},
+				Summary:    fmt.Sprintf("依關注清單加入書籤「%s」", t.Title),
+			})
+		})
+		if err != nil {
+			return added, err
+		}
+		if created {
+			added = append(added, t.JobNumber)
+		}
+	}
+	if len(added) > 0 {
+		s.markChanged()
+	}
+	return added, nil
+}
+
+// 排程：到了下載時間後執行今天，並補抓最近 days 天內未成功且已到重試時間的日子
+func (s *Server) checkIngest(ctx context.Context) error {
+	cfg := s.cfg.Ingest
+	now := time.Now()
+	local := localTime(now)
+	at, _ := time.Parse("15:04", cfg.Time)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	for i := cfg.Days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		if i == 0 && local.Hour()*60+local.Minute() < at.Hour()*60+at.Minute() {
+			continue
+		}
+		var status string
+		var next time.Time
+		err := s.db.QueryRowContext(ctx, "SELECT status, next_attempt_at FROM ingest_runs WHERE date = ?", day.Format("2006-01-02")).
+			Scan(&status, scanTime(&next))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if status == ingestSuccess || (status == ingestFailed && now.Before(next)) {
+			continue
+		}
+		run, err := s.runIngest(ctx, day)
+		if run == nil {
+			return err
+		}
+		if err != nil {
+			log.Printf("下載 %s 的標案清單失敗（第 %d 次，%s 後重試）: %v", run.Date, run.Attempts, ingestRetryDelay(run.Attempts), err)
+			continue
+		}
+		log.Printf("已下載 %s 的標案清單: %d 筆，符合 %d 筆，自動加入書籤 %d 筆", run.Date, run.Fetched, run.Matched, run.Bookmarked)
+	}
+	return nil
+}
+
+// GET /api/ingest/status：最近 30 天的執行紀錄
+func (s *Server) getIngestStatus(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT date, status, attempts, fetched, matched, bookmarked, error, started_at, finished_at, next_attempt_at
+		FROM ingest_runs ORDER BY date DESC LIMIT 30`)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]IngestRun, 0)
+	for rows.Next() {
+		var run IngestRun
+		var finished, next time.Time
+		if err := rows.Scan(&run.Date, &run.Status, &run.Attempts, &run.Fetched, &run.Matched, &run.Bookmarked, &run.Error,
+			scanTime(&run.StartedAt), scanTime(&finished), scanTime(&next)); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		run.StartedAt = localTime(run.StartedAt)
+		run.FinishedAt = optionalTime(finished)
+		if run.Status == ingestFailed {
+			run.NextAttemptAt = optionalTime(next)
+		}
+		items = append(items, run)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	_, watchlist, err := s.watchCategories()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	cfg := s.cfg.Ingest
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":   cfg.Enabled && !s.cfg.ReadOnly,
+		"url":       cfg.URL,
+		"time":      cfg.Time,
+		"watchlist": watchlist,
+		"items":     items,
+	})
+}
+
+// POST /api/ingest/run：立即下載並篩選 ?date=（預設今天），同一天可重複執行
+func (s *Server) runIngestHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Ingest.Enabled {
+		writeError(w, r, http.StatusConflict, "ingest_disabled")
+		return
+	}
+	day := localTime(time.Now())
+	if v := r.URL.Query().Get("date"); v != "" {
+		d, err := parseTenderDate(v)
+		if err != nil || d == 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "date")
+			return
+		}
+		day = time.Date(int(d)/10000, time.Month(int(d)/100%100), int(d)%100, 0, 0, 0, 0, displayLocation)
+	}
+	run, err := s.runIngest(r.Context(), time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location()))
+	if run == nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"success": false,
+			"error":   "ingest_failed",
+			"message": localize(requestLanguage(r), "ingest_failed", run.Error),
+			"run":     run,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "run": run})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// IntegrityProblem 完整性檢查發現的問題
+type IntegrityProblem struct {
+	Check   string  `json:"check"`
+	Message string  `json:"message"`
+	IDs     []int64 `json:"ids,omitempty"`
+	Fixable bool    `json:"fixable"`
+}
+
+// 孤兒資料檢查：子表中 job_number 已不存在於 bookmarks 與 bookmarks_archive 的資料列
+// 新增關聯到書籤的資料表時在此登記，完整性檢查、fix=safe 與清理（見 prune.go）會一併處理
+type orphanCheck struct {
+	table  string
+	column string
+}
+
+// 孤兒資料列的條件；封存的書籤仍算存在，其資料不會被刪除
+func (oc orphanCheck) where() string {
+	return fmt.Sprintf("%[1]s NOT IN (SELECT job_number FROM bookmarks) AND %[1]s NOT IN (SELECT job_number FROM bookmarks_archive)", oc.column)
+}
+
+var orphanChecks = []orphanCheck{
+	{table: "bookmark_categories", column: "job_number"},
+	{table: "bookmark_keywords", column: "job_number"},
+	{table: "bookmark_tags", column: "job_number"},
+	{table: "bookmark_status_history", column: "job_number"},
+	{table: "bookmark_changes", column: "job_number"},
+	{table: "attachments", column: "job_number"},
+}
+
+// 執行 SQLite 內建檢查，回傳非 ok 的訊息
+func (s *Server) sqliteCheck(ctx context.Context, pragma string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "PRAGMA "+pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, rows.Err()
+}
+
+// 查詢符合條件的 id
+func (s *Server) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// 檢查資料庫完整性
+// GET 只產生報告；POST ?fix=safe 會在交易中修復可安全修復的問題（清除孤兒資料）並回報修改內容
+func (s *Server) checkIntegrity(w http.ResponseWriter, r *http.Request) {
+	fix := r.URL.Query().Get("fix")
+	if fix != "" && (fix != "safe" || r.Method != "POST") {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "fix")
+		return
+	}
+
+	problems := make([]IntegrityProblem, 0)
+	engine := map[string]interface{}{}
+
+	if s.db.dialect == dialectSQLite {
+		for _, pragma := range []string{"integrity_check", "quick_check"} {
+			messages, err := s.sqliteCheck(r.Context(), pragma)
+			if err != nil {
+				writeDBError(w, r, err)
+				return
+			}
+			engine[pragma] = len(messages) == 0
+			for _, msg := range messages {
+				problems = append(problems, IntegrityProblem{Check: pragma, Message: msg})
+			}
+		}
+	}
+
+	// 應用層規則：job_number 不可為空
+	ids, err := s.queryIDs(r.Context(), "SELECT id FROM bookmarks WHERE TRIM(COALESCE(job_number, '')) = ''")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(ids) > 0 {
+		problems = append(problems, IntegrityProblem{Check: "empty_job_number", Message: fmt.Sprintf("%d 筆書籤沒有 job_number", len(ids)), IDs: ids})
+	}
+
+	// 應用層規則：data 欄位有值時必須是 JSON 物件
+	rows, err := s.db.QueryContext(r.Context(), "SELECT id, data FROM bookmarks WHERE data IS NOT NULL AND data <> ''")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	var invalid []int64
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		if plain, err := s.db.cipher.open("data", data); err != nil || validateData(plain) != nil {
+			invalid = append(invalid, id)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, IntegrityProblem{Check: "invalid_data_json", Message: fmt.Sprintf("%d 筆書籤的 data 不是合法 JSON 物件", len(invalid)), IDs: invalid})
+	}
+
+	// 快取的標案詳細資料與書籤的案號、名稱或機關不一致（可用 reconcile 更新）
+	ids, err = s.tenderMismatchIDs(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(ids) > 0 {
+		problems = append(problems, IntegrityProblem{Check: "tender_mismatch", Message: fmt.Sprintf("%d 筆書籤與標案詳細資料不一致", len(ids)), IDs: ids})
+	}
+
+	// data 與重複的欄位不一致（見 datasync.go）
+	drift, err := s.dataDriftRows(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(drift) > 0 {
+		ids := make([]int64, len(drift))
+		for i, row := range drift {
+			ids[i] = row.ID
+		}
+		problems = append(problems, IntegrityProblem{Check: "data_drift", Message: fmt.Sprintf("%d 筆書籤的 data 與欄位不一致", len(ids)), IDs: ids})
+	}
+
+	// 孤兒資料
+	for _, oc := range orphanChecks {
+		ids, err := s.queryIDs(r.Context(), "SELECT id FROM "+oc.table+" WHERE "+oc.where())
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if len(ids) > 0 {
+			problems = append(problems, IntegrityProblem{Check: "orphan_" + oc.table, Message: fmt.Sprintf("%s 有 %d 筆資料對應不到書籤", oc.table, len(ids)), IDs: ids, Fixable: true})
+		}
+	}
+
+	// 附件：資料列對應的檔案必須存在，附件目錄中不應有沒被參照的檔案
+	files, err := s.scanAttachmentFiles(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if len(files.MissingIDs) > 0 {
+		problems = append(problems, IntegrityProblem{Check: "attachment_missing_file", Message: fmt.Sprintf("%d 筆附件的檔案不存在或大小不符", len(files.MissingIDs)), IDs: files.MissingIDs})
+	}
+	if len(files.OrphanFiles) > 0 {
+		problems = append(problems, IntegrityProblem{Check: "attachment_orphan_file", Message: fmt.Sprintf("附件目錄有 %d 個檔案（%d bytes）沒有對應的附件", len(files.OrphanFiles), files.OrphanBytes), Fixable: true})
+	}
+
+	fixed := map[string]int64{}
+	if fix == "safe" {
+		err := s.db.withTx(r.Context(), func(tx *Tx) error {
+			for _, oc := range orphanChecks {
+				result, err := tx.Exec("DELETE FROM " + oc.table + " WHERE " + oc.where())
+				if err != nil {
+					return err
+				}
+				if n, _ := result.RowsAffected(); n > 0 {
+					fixed["orphan_"+oc.table] = n
+				}
+			}
+			if len(fixed) == 0 {
+				return nil
+			}
+			if err := writeEvent(tx, requestActor(r), entityIntegrity, "orphans", actionRepair, fixed); err != nil {
+				return err
+			}
+			return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("完整性修復: %v", fixed)))
+		})
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if len(fixed) > 0 {
+			s.markChanged()
+		}
+		// 清除孤兒資料列後可能多出沒被參照的檔案，重新比對一次
+		files, err := s.scanAttachmentFiles(r.Context())
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if n := s.removeAttachmentFiles(files.OrphanFiles); n > 0 {
+			fixed["attachment_orphan_file"] = int64(n)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":       len(problems) == 0,
+		"engine":   engine,
+		"problems": problems,
+		"fixed":    fixed,
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// 伺服器內部請求
+//
+// WebSocket 指令與 Telegram bot 的指令都轉為對應的 REST 請求，經由完整的路由執行，
+// 與外部請求套用相同的防護（唯讀模式、跨站檢查、?year=）、驗證、事件與稽核紀錄。
+
+// 記錄內部請求的回應
+type internalRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *internalRecorder) Header() http.Header { return rec.header }
+
+func (rec *internalRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *internalRecorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+// 以 api 處理請求，回傳狀態碼與回應內容
+// 呼叫端已驗證過來源（WebSocket 連線、Telegram 允許的聊天室），內部請求不再檢查 API 金鑰
+func serveInternal(api http.Handler, req *http.Request) (int, []byte) {
+	rec := &internalRecorder{header: http.Header{}}
+	api.ServeHTTP(rec, req.WithContext(withInternalRequest(req.Context())))
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.status, bytes.TrimSpace(rec.body.Bytes())
+}
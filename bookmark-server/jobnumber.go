@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 標案案號（job_number）的檢查
+//
+// 所有接受 job_number 的端點都經過 jobNumberParam，寫入與查詢都使用 canonicalJobNumber 的結果：
+// 全形英數字與符號轉為半形、去除前後空白、內部連續空白合併為一個半形空白。
+// 結果不可為空、不可超過 maxJobNumberLength 個字元，且只能由文字、數字、空白與 jobNumberPunct
+// 中的符號組成，不合格時回傳 400。
+// 大小寫維持原樣（既有資料以原本的大小寫儲存），只差在大小寫的案號列在 /api/admin/job-number-collisions。
+
+// 案號最大長度（字元數）；PCC 的案號通常在 30 字以內
+const maxJobNumberLength = 100
+
+// 案號中允許的符號
+const jobNumberPunct = "-_./()#+&:"
+
+// 案號的標準形式
+func canonicalJobNumber(raw string) string {
+	var b strings.Builder
+	for _, c := range raw {
+		switch {
+		case c == '\u3000': // 全形空白
+			c = ' '
+		case c >= '\uFF01' && c <= '\uFF5E': // 全形 ASCII
+			c -= 0xFEE0
+		}
+		b.WriteRune(c)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// 檢查案號，回傳標準形式與錯誤代碼；合格時錯誤代碼為空字串
+func checkJobNumber(raw string) (string, string) {
+	if !utf8.ValidString(raw) {
+		return "", "invalid_job_number"
+	}
+	jn := canonicalJobNumber(raw)
+	if jn == "" {
+		return "", "missing_job_number"
+	}
+	if utf8.RuneCountInString(jn) > maxJobNumberLength {
+		return "", "invalid_job_number"
+	}
+	for _, c := range jn {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != ' ' && !strings.ContainsRune(jobNumberPunct, c) {
+			return "", "invalid_job_number"
+		}
+	}
+	return jn, ""
+}
+
+// 檢查請求中的案號，不合格時輸出 400 並回傳 false
+func jobNumberParam(w http.ResponseWriter, r *http.Request, raw string) (string, bool) {
+	jn, code := checkJobNumber(raw)
+	switch code {
+	case "missing_job_number":
+		writeError(w, r, http.StatusBadRequest, code)
+		return "", false
+	case "invalid_job_number":
+		writeError(w, r, http.StatusBadRequest, code, maxJobNumberLength, jobNumberPunct)
+		return "", false
+	}
+	return jn, true
+}
+
+// JobNumberCollision 標準化後會對應到同一個案號（不分大小寫）的既有書籤
+type JobNumberCollision struct {
+	Canonical  string   `json:"canonical"`
+	JobNumbers []string `json:"job_numbers"`
+}
+
+// GET /api/admin/job-number-collisions：列出標準化後會互相衝突的書籤，以及儲存值不是標準形式的書籤
+// 後者在查詢時找不到（查詢一律使用標準形式），需要合併或改名
+func (s *Server) jobNumberCollisionsReport(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT job_number FROM bookmarks ORDER BY job_number")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	groups := map[string][]string{}
+	nonCanonical := make([]string, 0)
+	for rows.Next() {
+		var jn string
+		if err := rows.Scan(&jn); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		canonical, code := checkJobNumber(jn)
+		if code != "" || canonical != jn {
+			nonCanonical = append(nonCanonical, jn)
+		}
+		if code == "" {
+			key := strings.ToUpper(canonical)
+			groups[key] = append(groups[key], jn)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	collisions := make([]JobNumberCollision, 0)
+	for key, jns := range groups {
+		if len(jns) > 1 {
+			collisions = append(collisions, JobNumberCollision{Canonical: key, JobNumbers: jns})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Canonical < collisions[j].Canonical })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":         len(collisions),
+		"collisions":    collisions,
+		"non_canonical": nonCanonical,
+	})
+}
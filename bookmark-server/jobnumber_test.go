@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckJobNumber(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		code string
+	}{
+		{"A001", "A001", ""},
+		{"  A001\t", "A001", ""},
+		{"Ａ００１", "A001", ""},
+		{"ＬＰ５－１１５０１", "LP5-11501", ""},
+		{"A　001", "A 001", ""},
+		{"A  \t 001", "A 001", ""},
+		{"a001", "a001", ""}, // 大小寫維持原樣
+		{"1150115-(2)#3", "1150115-(2)#3", ""},
+		{"臺北市115年度", "臺北市115年度", ""},
+		{"", "", "missing_job_number"},
+		{" 　 ", "", "missing_job_number"},
+		{"A001;DROP", "", "invalid_job_number"},
+		{"A001\n", "A001", ""},
+		{"A<001>", "", "invalid_job_number"},
+		{"A001\x00", "", "invalid_job_number"},
+		{"\xff\xfe", "", "invalid_job_number"},
+		{strings.Repeat("A", maxJobNumberLength), strings.Repeat("A", maxJobNumberLength), ""},
+		{strings.Repeat("A", maxJobNumberLength+1), "", "invalid_job_number"},
+	}
+	for _, tt := range tests {
+		got, code := checkJobNumber(tt.raw)
+		if got != tt.want || code != tt.code {
+			t.Errorf("checkJobNumber(%q) = %q, %q; want %q, %q", tt.raw, got, code, tt.want, tt.code)
+		}
+	}
+}
+
+// 新增、檢查、刪除都使用標準形式：全形或帶空白的案號對應到同一筆書籤
+func TestJobNumberCanonicalOnEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	_, resp := postBookmark(t, h, `{"job_number":" Ａ００１ ","title":"第一次"}`)
+	if resp.Bookmark.JobNumber != "A001" || !resp.Created {
+		t.Fatalf("add = %+v", resp)
+	}
+	if _, resp := postBookmark(t, h, `{"job_number":"A001","title":"第二次"}`); resp.Created {
+		t.Errorf("半形案號建立了新的書籤: %+v", resp)
+	}
+
+	for _, raw := range []string{"A001", "Ａ００１", " A001 "} {
+		got := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number="+url.QueryEscape(raw), ""))
+		if got["bookmarked"] != true || got["job_number"] != "A001" {
+			t.Errorf("check %q = %v", raw, got)
+		}
+	}
+	if w := serve(t, h, "GET", "/api/bookmarks/check?job_number="+url.QueryEscape("A<1>"), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("check invalid = %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"A<1>"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("add invalid = %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := serve(t, h, "DELETE", "/api/bookmarks?job_number="+url.QueryEscape("Ａ００１"), ""); w.Code != http.StatusOK {
+		t.Fatalf("delete = %d: %s", w.Code, w.Body.String())
+	}
+	if got := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")); got["bookmarked"] != false {
+		t.Errorf("刪除後 check = %v", got)
+	}
+}
+
+// 既有資料中標準化後會衝突或不是標準形式的案號
+func TestJobNumberCollisionsReport(t *testing.T) {
+	s := newTestServer(t)
+	for _, jn := range []string{"A001", "a001", "Ａ001", "B001", " C001", "D<1>"} {
+		if _, err := s.db.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, '', ?, ?)", jn, dbNow(), dbNow()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := serve(t, s.routes(), "GET", "/api/admin/job-number-collisions", "")
+	var resp struct {
+		Total        int                  `json:"total"`
+		Collisions   []JobNumberCollision `json:"collisions"`
+		NonCanonical []string             `json:"non_canonical"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if resp.Total != 1 || resp.Collisions[0].Canonical != "A001" || !equalStrings(resp.Collisions[0].JobNumbers, []string{"A001", "a001", "Ａ001"}) {
+		t.Errorf("collisions = %+v", resp.Collisions)
+	}
+	if !equalStrings(resp.NonCanonical, []string{" C001", "D<1>", "Ａ001"}) {
+		t.Errorf("non_canonical = %v", resp.NonCanonical)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// LINE Notify 通知服務
+// 權杖由 LINE Notify 個人頁面發行，發送到權杖綁定的聊天室；訊息上限 1000 字
+
+const (
+	lineNotifyURL      = "https://notify-api.line.me/api/notify"
+	lineNotifyMaxRunes = 1000
+)
+
+type lineNotifier struct {
+	token        string
+	url          string
+	retryBackoff time.Duration
+}
+
+func newLineNotifier(cfg ProviderConfig) (*lineNotifier, error) {
+	l := &lineNotifier{token: cfg.Token, url: cfg.URL, retryBackoff: notifyRetryBackoff}
+	if l.token == "" {
+		l.token = os.Getenv("LINE_NOTIFY_TOKEN")
+	}
+	if l.token == "" {
+		return nil, fmt.Errorf("未設定 LINE Notify 權杖（token 或環境變數 LINE_NOTIFY_TOKEN）")
+	}
+	if l.url == "" {
+		l.url = lineNotifyURL
+	}
+	return l, nil
+}
+
+func (l *lineNotifier) send(ctx context.Context, d *delivery) (int, error) {
+	// LINE 會在訊息前加上權杖名稱，換行讓內容從新的一行開始；摘要的各則之間空一行
+	texts := make([]string, 0, len(d.Items)+1)
+	for _, n := range d.Items {
+		texts = append(texts, n.Text)
+	}
+	if note := d.skippedNote(); note != "" {
+		texts = append(texts, note)
+	}
+	text := truncateRunes("\n"+strings.Join(texts, "\n\n"), lineNotifyMaxRunes)
+	form := url.Values{"message": {text}}.Encode()
+	resp, err := postNotification(ctx, l.retryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", l.url, strings.NewReader(form))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+l.token)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status != http.StatusOK {
+		// 錯誤回應為 {"status": 401, "message": "Invalid access token"}
+		var payload struct {
+			Message string `json:"message"`
+		}
+		msg := strings.TrimSpace(string(resp.Body))
+		if json.Unmarshal(resp.Body, &payload) == nil && payload.Message != "" {
+			msg = payload.Message
+		}
+		return resp.Status, fmt.Errorf("LINE Notify 回應 %d: %s", resp.Status, truncateMessage(msg))
+	}
+	return resp.Status, nil
+}
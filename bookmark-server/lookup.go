@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bookmark-server/store"
+)
+
+// 以 PCC 網頁網址查詢書籤
+//
+// GET /api/lookup?url=<網址> 供瀏覽器擴充功能在政府電子採購網的頁面上顯示星號：
+// 由網址取出案號（tenderCaseNo 等參數）或公告識別碼（pkPmsMain、pkAtmMain、primaryKey、fn 等），
+// 回傳是否已加入書籤、書籤的備註與優先級，以及可直接 POST /api/bookmarks 的 prefill。
+// 網址只有識別碼時，以書籤的 url 與 filtered_for_company/all_matched.jsonl 比對找出案號。
+// 不認得的網址回應 200 與 status "unrecognized"，擴充功能在其他網站上不必處理錯誤。
+
+// 查詢結果
+const (
+	lookupUnrecognized  = "unrecognized"   // 不是 PCC 標案頁面的網址
+	lookupUnknown       = "unknown"        // 認得網址格式，但找不到對應的案號
+	lookupBookmarked    = "bookmarked"     // 已加入書籤
+	lookupNotBookmarked = "not_bookmarked" // 找到案號，尚未加入書籤
+)
+
+// 可辨識的網站
+var lookupHosts = []string{"web.pcc.gov.tw", "pcc.g0v.ronny.tw", "pcc-api.openfun.app"}
+
+// 帶有案號的查詢參數
+var lookupJobNumberParams = []string{"tenderCaseNo", "caseNo", "job_number"}
+
+// 帶有公告識別碼的查詢參數（fn 為公告檔名，例如 TIQ-1-70584524.xml）
+var lookupIdentifierParams = []string{"pkPmsMain", "pkAtmMain", "primaryKey", "tenderId", "fn"}
+
+// 由網址取出的資訊
+type pccPageRef struct {
+	JobNumber   string            `json:"job_number,omitempty"`
+	UnitID      string            `json:"unit_id,omitempty"`
+	Identifiers map[string]string `json:"identifiers,omitempty"`
+}
+
+// 解析 PCC 頁面網址；不是可辨識的網站或網址中沒有案號與識別碼時回傳 false
+func parsePCCPageURL(raw string) (*pccPageRef, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, false
+	}
+	if !containsString(lookupHosts, strings.ToLower(u.Hostname())) {
+		return nil, false
+	}
+	query := u.Query()
+	ref := &pccPageRef{UnitID: query.Get("unit_id")}
+	for _, name := range lookupJobNumberParams {
+		if jn, code := checkJobNumber(query.Get(name)); code == "" {
+			ref.JobNumber = jn
+			break
+		}
+	}
+	for _, name := range lookupIdentifierParams {
+		if v := strings.TrimSpace(query.Get(name)); v != "" {
+			if ref.Identifiers == nil {
+				ref.Identifiers = make(map[string]string)
+			}
+			ref.Identifiers[name] = strings.TrimSuffix(v, ".xml")
+		}
+	}
+	if ref.JobNumber == "" && ref.Identifiers == nil {
+		return nil, false
+	}
+	return ref, true
+}
+
+// 另一個網址是否帶有相同的識別碼
+func (ref *pccPageRef) matchesURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	query := u.Query()
+	for name, value := range ref.Identifiers {
+		if strings.TrimSuffix(strings.TrimSpace(query.Get(name)), ".xml") == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 以識別碼比對書籤的 url，回傳案號
+func (s *Server) lookupBookmarkByIdentifier(ctx context.Context, ref *pccPageRef) (string, error) {
+	for _, value := range ref.Identifiers {
+		pattern := "%" + store.LikeEscaper.Replace(value) + "%"
+		rows, err := s.db.QueryContext(ctx, `SELECT job_number, url FROM bookmarks WHERE deleted_at IS NULL AND url LIKE ? ESCAPE '\'`, pattern)
+		if err != nil {
+			return "", err
+		}
+		for rows.Next() {
+			var jn, pageURL string
+			if err := rows.Scan(&jn, &pageURL); err != nil {
+				rows.Close()
+				return "", err
+			}
+			if ref.matchesURL(pageURL) {
+				rows.Close()
+				return jn, nil
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// 在篩選結果（all_matched.jsonl）中找標案；案號或識別碼相同即可
+func (s *Server) lookupFilteredTender(ref *pccPageRef) (*filteredTender, error) {
+	f, err := os.Open(filepath.Join(yearDir(s.cfg.Year), "filtered_for_company", "all_matched.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var t filteredTender
+		if json.Unmarshal(scanner.Bytes(), &t) != nil {
+			continue
+		}
+		if (ref.JobNumber != "" && t.JobNumber == ref.JobNumber) || (ref.JobNumber == "" && ref.matchesURL(t.URL)) {
+			return &t, nil
+		}
+	}
+	return nil, scanner.Err()
+}
+
+// GET /api/lookup?url=
+func (s *Server) lookupPageURL(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("url")
+	if strings.TrimSpace(raw) == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "url")
+		return
+	}
+	ref, ok := parsePCCPageURL(raw)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": lookupUnrecognized, "recognized": false})
+		return
+	}
+
+	jobNumber := ref.JobNumber
+	if jobNumber == "" {
+		jn, err := s.lookupBookmarkByIdentifier(r.Context(), ref)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		jobNumber = jn
+	}
+
+	resp := map[string]interface{}{
+		"recognized": true,
+		"ref":        ref,
+		"status":     lookupUnknown,
+	}
+	if jobNumber != "" {
+		b, err := s.bookmarks.Get(r.Context(), jobNumber)
+		if err != nil && !errors.Is(err, errBookmarkNotFound) {
+			writeDBError(w, r, err)
+			return
+		}
+		if err == nil {
+			resp["status"] = lookupBookmarked
+			resp["job_number"] = b.JobNumber
+			resp["bookmark"] = map[string]interface{}{
+				"id":       b.ID,
+				"title":    b.Title,
+				"note":     b.Note,
+				"priority": b.Priority,
+				"version":  b.Version,
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+
+	// 尚未加入書籤：以篩選結果產生 prefill，找不到時只帶案號與網址
+	t, err := s.lookupFilteredTender(&pccPageRef{JobNumber: jobNumber, Identifiers: ref.Identifiers})
+	if err != nil {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+	switch {
+	case t != nil:
+		data, _ := json.Marshal(t)
+		resp["status"] = lookupNotBookmarked
+		resp["job_number"] = t.JobNumber
+		resp["prefill"] = map[string]interface{}{
+			"job_number": t.JobNumber,
+			"title":      t.Title,
+			"unit_name":  t.UnitName,
+			"url":        t.URL,
+			"api_url":    t.APIURL,
+			"type":       t.Type,
+			"date":       t.Date,
+			"data":       string(data),
+		}
+	case jobNumber != "":
+		resp["status"] = lookupNotBookmarked
+		resp["job_number"] = jobNumber
+		resp["prefill"] = map[string]interface{}{"job_number": jobNumber, "url": raw}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
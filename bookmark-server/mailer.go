@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 以 SMTP 寄送郵件
+
+// SMTP 連線的加密方式
+const (
+	smtpTLSStartTLS = "starttls" // 以明文連線後升級為 TLS（通常為 587 埠），預設
+	smtpTLSImplicit = "tls"      // 直接以 TLS 連線（通常為 465 埠）
+	smtpTLSNone     = "none"     // 不加密，只適用於本機的郵件伺服器
+)
+
+// SMTPConfig SMTP 設定
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`     // 預設 starttls 為 587、tls 為 465、none 為 25
+	Username string   `json:"username"` // 未設定時不驗證
+	Password string   `json:"password"` // 未設定時使用環境變數 SMTP_PASSWORD
+	TLS      string   `json:"tls"`      // starttls、tls 或 none
+	From     string   `json:"from"`     // 寄件者，例如 "標案書籤 <bot@example.com>"
+	To       []string `json:"to"`       // 收件者
+}
+
+// 補上預設值並檢查設定
+func (c *SMTPConfig) validate() error {
+	switch c.TLS {
+	case "":
+		c.TLS = smtpTLSStartTLS
+	case smtpTLSStartTLS, smtpTLSImplicit, smtpTLSNone:
+	default:
+		return fmt.Errorf("smtp.tls 必須是 starttls、tls 或 none: %q", c.TLS)
+	}
+	if c.Port == 0 {
+		c.Port = map[string]int{smtpTLSStartTLS: 587, smtpTLSImplicit: 465, smtpTLSNone: 25}[c.TLS]
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv("SMTP_PASSWORD")
+	}
+	if c.From == "" {
+		return fmt.Errorf("未設定 smtp.from")
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("smtp.from 格式錯誤: %w", err)
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("未設定 smtp.to")
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("smtp.to 格式錯誤（%s）: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// 郵件內容：純文字與 HTML 兩種版本
+type mailMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// 產生 multipart/alternative 郵件（純文字在前，支援 HTML 的郵件軟體顯示 HTML）
+func (c *SMTPConfig) buildMessage(m mailMessage, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	from, _ := mail.ParseAddress(c.From)
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	var msg bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&msg, "%s: %s\r\n", name, value) }
+	header("From", from.String())
+	header("To", strings.Join(c.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// 寄出郵件給所有收件者
+func (c *SMTPConfig) send(ctx context.Context, m mailMessage) error {
+	msg, err := c.buildMessage(m, time.Now())
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	tlsConfig := &tls.Config{ServerName: c.Host}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if c.TLS == smtpTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// 整個對話最多 2 分鐘，伺服器沒有回應時不會卡住排程
+	deadline := time.Now().Add(2 * time.Minute)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if c.TLS == smtpTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP 伺服器不支援 STARTTLS（可設定 smtp.tls 為 tls 或 none）")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.Username != "" {
+		// PlainAuth 只在加密連線（或本機）上送出密碼
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return err
+		}
+	}
+	from, _ := mail.ParseAddress(c.From)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range c.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("收件者 %s: %w", addr.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -1,64 +1,73 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"bookmark-server/store"
 )
 
 // Tender 標案結構
 type Tender struct {
-	Date              int      `json:"date"`
-	Title             string   `json:"title"`
-	Type              string   `json:"type"`
-	UnitName          string   `json:"unit_name"`
-	JobNumber         string   `json:"job_number"`
-	URL               string   `json:"url"`
-	APIURL            string   `json:"api_url"`
-	MatchedCategories []string `json:"matched_categories"`
-	MatchedKeywords   []string `json:"matched_keywords"`
+	Date              TenderDate `json:"date"`
+	Title             string     `json:"title"`
+	Type              string     `json:"type"`
+	UnitName          string     `json:"unit_name"`
+	JobNumber         string     `json:"job_number"`
+	URL               string     `json:"url"`
+	APIURL            string     `json:"api_url"`
+	MatchedCategories []string   `json:"matched_categories"`
+	MatchedKeywords   []string   `json:"matched_keywords"`
 }
 
 // Bookmark 書籤結構
 type Bookmark struct {
-	ID        int       `json:"id"`
-	JobNumber string    `json:"job_number"`
-	Title     string    `json:"title"`
-	UnitName  string    `json:"unit_name"`
-	URL       string    `json:"url"`
-	APIURL    string    `json:"api_url"`
-	Type      string    `json:"type"`
-	Date      int       `json:"date"`
-	Note      string    `json:"note"`
-	Priority  int       `json:"priority"`
-	CreatedAt time.Time `json:"created_at"`
-	Data      string    `json:"data"` // 完整 JSON 資料
-}
-
-var db *sql.DB
-
-func initDB() {
-	var err error
-	dbPath := filepath.Join("..", "pcc_data", "2026", "bookmarks.db")
-	
-	// 確保目錄存在
-	os.MkdirAll(filepath.Dir(dbPath), 0755)
-	
-	db, err = sql.Open("sqlite3", dbPath)
-	if err != nil {
-		log.Fatal(err)
-	}
+	ID        int        `json:"id"`
+	JobNumber string     `json:"job_number"`
+	Title     string     `json:"title"`
+	UnitName  string     `json:"unit_name"`
+	URL       string     `json:"url"`
+	APIURL    string     `json:"api_url"`
+	Type      string     `json:"type"`
+	Date      TenderDate `json:"date"`
+	Note      string     `json:"note"`
+	Priority  int        `json:"priority"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"` // 每次更新遞增，供樂觀鎖使用
+	Data      string     `json:"data"`    // 完整 JSON 資料
+	Status    string     `json:"status"`  // 標案進度（見 status.go）
+	Archived  bool       `json:"archived,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // 只在垃圾桶中（見 trash.go）有值
+
+	// 由 data 中的同名欄位產生，存於 bookmark_categories 與 bookmark_keywords
+	MatchedCategories []string `json:"matched_categories"`
+	MatchedKeywords   []string `json:"matched_keywords"`
+
+	// 使用者指定的標籤，存於 bookmark_tags（見 tags.go）
+	Tags []string `json:"tags"`
+}
+
+// 版本號，建置時可用 -ldflags "-X main.version=..." 覆寫
+var version = "dev"
 
-	// 建立書籤表
+// 建立書籤表與稽核紀錄表，並執行遷移
+func initSchema(d *DB) error {
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS bookmarks (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -74,366 +83,1191 @@ func initDB() {
 		data TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
-	
-	CREATE INDEX IF NOT EXISTS idx_job_number ON bookmarks(job_number);
-	CREATE INDEX IF NOT EXISTS idx_priority ON bookmarks(priority DESC);
 	`
 
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
+	if _, err := d.Exec(d.dialect.ddl(createTableSQL + auditSchemaSQL)); err != nil {
+		return err
 	}
-
-	log.Println("資料庫初始化完成:", dbPath)
+	return runMigrations(d)
 }
 
-// CORS 中介軟體
-func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// CORS 中介軟體：未設定 allowed_origins 時允許任何來源讀取回應；
+// 設定後只回應同源與清單中的來源（Access-Control-Allow-Origin 為請求的 Origin），其他來源的瀏覽器無法讀取回應
+// 預檢請求（OPTIONS）由內層的 allowMethods 依路由實際允許的方法回應，其他請求接著檢查 API 金鑰（見 apikeys.go）
+func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if len(s.cfg.AllowedOrigins) == 0 {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" && s.isAllowedOrigin(r, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		if r.Method != "OPTIONS" && !s.checkAPIKey(w, r) {
+			return
+		}
+		next(w, r)
+	}
+}
 
+// 限制允許的請求方法，其他方法回傳 405 與 Allow 標頭；允許 GET 時也接受 HEAD
+// OPTIONS 一律由此回應：預檢請求的 Access-Control-Request-Method 不在允許之列時回傳 405，
+// 否則回傳 204 並列出允許的方法與標頭
+func allowMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	if allowed["GET"] {
+		allowed["HEAD"] = true
+		methods = append(methods, "HEAD")
+	}
+	allow := strings.Join(append(methods, "OPTIONS"), ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Allow", allow)
+			if requested := r.Header.Get("Access-Control-Request-Method"); requested != "" {
+				if !allowed[requested] {
+					writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Methods", allow)
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept-Language, X-API-Key, X-Requested-With")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !allowed[r.Method] {
+			w.Header().Set("Allow", allow)
+			writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
 			return
 		}
+		next(w, r)
+	}
+}
+
+// /api/ 之下不存在的路由：任何方法（包括預檢請求）都回傳 404，不交給根路徑的靜態掛載
+func unknownRoute(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, "unknown_route", r.URL.Path)
+}
 
+// 唯讀模式守衛：拒絕會修改資料的請求
+// alwaysWrites 表示該端點即使是 GET 也會寫入（例如下載標書）
+func (s *Server) readOnlyGuard(alwaysWrites bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.ReadOnly && (alwaysWrites || !isSafeMethod(r.Method)) {
+			writeError(w, r, http.StatusForbidden, "read_only")
+			return
+		}
 		next(w, r)
 	}
 }
 
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// 輸出 JSON 回應
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// 輸出錯誤回應，code 為不隨語系變動的錯誤代碼，訊息依請求語系翻譯
+func writeError(w http.ResponseWriter, r *http.Request, status int, code string, args ...interface{}) {
+	writeJSON(w, status, map[string]interface{}{
+		"success": false,
+		"error":   code,
+		"message": localize(requestLanguage(r), code, args...),
+	})
+}
+
+// 輸出成功回應，extra 為額外欄位
+func writeSuccess(w http.ResponseWriter, r *http.Request, status int, code string, extra map[string]interface{}) {
+	resp := map[string]interface{}{
+		"success": true,
+		"code":    code,
+		"message": localize(requestLanguage(r), code),
+	}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	writeJSON(w, status, resp)
+}
+
+// 下載檔名的 Content-Disposition 標頭值
+// filename= 為 ASCII 備用名稱（非 ASCII 與特殊字元換成 _），filename*= 為 RFC 5987 的 UTF-8 編碼，
+// 支援的瀏覽器以後者為準，中文檔名才不會變成亂碼
+func contentDisposition(name string) string {
+	var fallback, encoded strings.Builder
+	for _, c := range name {
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(c)
+		}
+	}
+	for _, b := range []byte(name) {
+		if b < 0x80 && (b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback.String(), encoded.String())
+}
+
+// 取得伺服器版本與模式（前端據此隱藏編輯功能）
+// last_updated_at 與 bookmark_count 讓外部工具不必下載整份清單就能判斷資料是否變動
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request) {
+	var count int
+	var lastUpdated sql.NullString
+	err := s.db.QueryRowContext(r.Context(), "SELECT COUNT(*), MAX(updated_at) FROM bookmarks WHERE "+notDeleted).Scan(&count, &lastUpdated)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"version":         version,
+		"read_only":       s.cfg.ReadOnly,
+		"year":            s.cfg.Year,
+		"bookmark_count":  count,
+		"last_updated_at": nil,
+		"backup":          s.backupHealth(),
+	}
+	if lastUpdated.Valid {
+		if t, err := parseDBTime(lastUpdated.String); err == nil {
+			resp["last_updated_at"] = localTime(t)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /api/health：確認資料庫可以連線，供負載平衡器或監控使用
+// 資料庫無回應時回傳 503，不經過 ?year=，只檢查主要年度的資料庫
+func (s *Server) getHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := s.db.PingContext(ctx); err != nil {
+		log.Println("健康檢查失敗:", err)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":   "error",
+			"database": "unavailable",
+			"year":     s.cfg.Year,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"database":   "ok",
+		"year":       s.cfg.Year,
+		"latency_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// 書籤查詢欄位，順序與 scanBookmark 一致
+const bookmarkColumns = store.Columns
+
+// 讀取一筆書籤並解密 note 與 data；失敗時回傳的 Bookmark 可能只有部分欄位（例如 ID）有值，供紀錄使用
+// 舊版用戶端寫入的資料可能有 NULL 欄位（遷移 14 已補為空字串），一律讀成空字串或 0，不讓整筆資料被略過
+// 查詢在 bookmarkColumns 之後還有其他欄位時，由 extra 接收
+func scanBookmark(rows *sql.Rows, c *fieldCipher, extra ...interface{}) (Bookmark, error) {
+	var b Bookmark
+	var unitName, url, apiURL, typ, note, dataStr sql.NullString
+	var priority sql.NullInt64
+	dest := []interface{}{&b.ID, &b.JobNumber, &b.Title, &unitName, &url, &apiURL, &typ, &b.Date, &note, &priority, &dataStr, scanTime(&b.CreatedAt), scanTime(&b.UpdatedAt), &b.Version, &b.Status}
+	err := rows.Scan(append(dest, extra...)...)
+	b.UnitName, b.URL, b.APIURL, b.Type, b.Note, b.Data = unitName.String, url.String, apiURL.String, typ.String, note.String, dataStr.String
+	b.Priority = int(priority.Int64)
+	b.CreatedAt, b.UpdatedAt = localTime(b.CreatedAt), localTime(b.UpdatedAt)
+	if err != nil {
+		return b, err
+	}
+	return b, c.openBookmark(&b)
+}
+
 // 取得所有書籤
-func getBookmarks(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(`
-		SELECT id, job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at 
-		FROM bookmarks 
-		ORDER BY priority DESC, created_at DESC
-	`)
+// ?category= 與 ?keyword= 只回傳符合該類別或關鍵字的書籤，兩者可同時使用
+// ?date_from= 與 ?date_to= 依標案日期篩選（含兩端，格式同 date 欄位，例如 2026-01-15）；
+// 指定任一端時沒有日期（0）的書籤不會列出
+// ?type= 為公告類型，?unit_name= 比對機關名稱的一部分，?min_priority= 只列出優先順序不低於此值的書籤，
+// ?tag= 可重複，只列出有所有指定標籤的書籤；?status= 可重複，列出狀態為其中之一的書籤
+// ?sort=priority|date|created_at 與 ?order=asc|desc（預設 desc）指定排序，未指定時依優先順序、新增時間由高到新
+// 指定 ?page= 或 ?page_size= 時分頁，回應改為 {"total", "page", "page_size", "items"}；
+// page_size 預設 50，0 表示不分頁（回傳所有符合的書籤）；沒有這兩個參數時維持陣列格式
+func (s *Server) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, args, badParam := bookmarkFilter(query)
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	orderBy, badParam := bookmarkOrder(query)
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	paged := query.Has("page") || query.Has("page_size")
+	page, ok := intParam(r, "page", 1, 1, 1<<31-1)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page")
+		return
+	}
+	pageSize, ok := intParam(r, "page_size", bookmarkDefaultPageSize, 0, bookmarkMaxPageSize)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "page_size")
+		return
+	}
+	if paged && query.Get("include_archived_db") == "true" {
+		// 已封存的書籤在另一個資料庫，無法與現有書籤一起分頁
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "include_archived_db")
+		return
+	}
+
+	q := store.Query{Where: filter, Args: args, OrderBy: orderBy}
+	total := 0
+	if paged {
+		var err error
+		if total, err = s.bookmarks.Count(r.Context(), q); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		q.Limit, q.Offset = pageSize, int64(page-1)*int64(pageSize)
+	}
+
+	// 沒有書籤時回傳 []，不是 null
+	bookmarks, warnings, err := s.bookmarks.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
-	defer rows.Close()
 
-	var bookmarks []Bookmark
-	for rows.Next() {
-		var b Bookmark
-		var dataStr sql.NullString
-		err := rows.Scan(&b.ID, &b.JobNumber, &b.Title, &b.UnitName, &b.URL, &b.APIURL, &b.Type, &b.Date, &b.Note, &b.Priority, &dataStr, &b.CreatedAt)
+	if err := s.attachTerms(bookmarks); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	// ?include_archived_db=true 時附上已封存的書籤（archived 為 true），排在現有書籤之後
+	if query.Get("include_archived_db") == "true" {
+		archived, archiveWarnings, err := s.archivedBookmarks(r.Context())
 		if err != nil {
-			continue
+			writeDBError(w, r, err)
+			return
 		}
-		if dataStr.Valid {
-			b.Data = dataStr.String
+		for _, b := range archived {
+			if archivedBookmarkMatches(&b, query) {
+				bookmarks = append(bookmarks, b)
+			}
 		}
-		bookmarks = append(bookmarks, b)
+		warnings += archiveWarnings
 	}
 
+	// 略過的資料列數以標頭告知
+	w.Header().Set("X-Scan-Warnings", strconv.Itoa(warnings))
+	if paged {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"items":     bookmarks,
+		})
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(bookmarks)
 }
 
-// 新增書籤
-func addBookmark(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		JobNumber string `json:"job_number"`
-		Title     string `json:"title"`
-		UnitName  string `json:"unit_name"`
-		URL       string `json:"url"`
-		APIURL    string `json:"api_url"`
-		Type      string `json:"type"`
-		Date      int    `json:"date"`
-		Note      string `json:"note"`
-		Priority  int    `json:"priority"`
-		Data      string `json:"data"`
+const (
+	bookmarkDefaultPageSize = 50
+	bookmarkMaxPageSize     = 1000
+)
+
+// 書籤的篩選條件（?category= ?keyword= ?date_from= ?date_to= ?type= ?unit_name= ?min_priority= ?tag=），回傳 WHERE 子句與參數
+// ?tag= 可重複，書籤必須有所有指定的標籤
+// 一律排除垃圾桶中的書籤，沒有篩選參數時 WHERE 子句只有這個條件（args 為空）
+// 參數格式錯誤時回傳該參數的名稱
+func bookmarkFilter(query url.Values) (string, []interface{}, string) {
+	where := []string{notDeleted}
+	var args []interface{}
+	for _, f := range []struct{ param, cond string }{{"date_from", "date >= ?"}, {"date_to", "date <= ?"}} {
+		v := query.Get(f.param)
+		if v == "" {
+			continue
+		}
+		d, err := parseTenderDate(v)
+		if err != nil || d == 0 {
+			return "", nil, f.param
+		}
+		where = append(where, "date > 0 AND "+f.cond)
+		args = append(args, d)
+	}
+	if category := query.Get("category"); category != "" {
+		where = append(where, "job_number IN (SELECT job_number FROM bookmark_categories WHERE category = ?)")
+		args = append(args, category)
+	}
+	if keyword := query.Get("keyword"); keyword != "" {
+		where = append(where, "job_number IN (SELECT job_number FROM bookmark_keywords WHERE keyword = ?)")
+		args = append(args, keyword)
+	}
+	if typ := query.Get("type"); typ != "" {
+		where = append(where, "type = ?")
+		args = append(args, typ)
+	}
+	if unitName := query.Get("unit_name"); unitName != "" {
+		where = append(where, `unit_name LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+store.LikeEscaper.Replace(unitName)+"%")
+	}
+	if v := query.Get("min_priority"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return "", nil, "min_priority"
+		}
+		where = append(where, "priority >= ?")
+		args = append(args, n)
+	}
+	if statuses := query["status"]; len(statuses) > 0 {
+		for _, status := range statuses {
+			if !validStatus(status) {
+				return "", nil, "status"
+			}
+			args = append(args, status)
+		}
+		where = append(where, "status IN ("+strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",")+")")
 	}
+	for _, tag := range query["tag"] {
+		if tagKey(tag) == "" {
+			return "", nil, "tag"
+		}
+		where = append(where, "job_number IN (SELECT bt.job_number FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id WHERE t.name_key = ?)")
+		args = append(args, tagKey(tag))
+	}
+	return "WHERE " + strings.Join(where, " AND "), args, ""
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// 書籤的排序（?sort= ?order=），回傳 ORDER BY 子句；欄位只能從固定清單選擇，不直接放入 SQL
+// 之後的欄位讓同值的書籤順序固定，分頁時不會重複或遺漏
+func bookmarkOrder(query url.Values) (string, string) {
+	dir := "DESC"
+	switch strings.ToLower(query.Get("order")) {
+	case "", "desc":
+	case "asc":
+		dir = "ASC"
+	default:
+		return "", "order"
+	}
+	switch query.Get("sort") {
+	case "":
+		return "priority " + dir + ", created_at " + dir + ", id " + dir, ""
+	case "priority":
+		return "priority " + dir + ", created_at DESC, id DESC", ""
+	case "date":
+		return "date " + dir + ", id " + dir, ""
+	case "created_at":
+		return "created_at " + dir + ", id " + dir, ""
+	}
+	return "", "sort"
+}
+
+// 已封存的書籤是否符合篩選條件（參數已由 bookmarkFilter 檢查過）
+func archivedBookmarkMatches(b *Bookmark, query url.Values) bool {
+	category, keyword := query.Get("category"), query.Get("keyword")
+	if (category != "" && !containsTerm(b.MatchedCategories, category)) ||
+		(keyword != "" && !containsTerm(b.MatchedKeywords, keyword)) {
+		return false
+	}
+	if typ := query.Get("type"); typ != "" && b.Type != typ {
+		return false
 	}
+	if unitName := query.Get("unit_name"); unitName != "" && !strings.Contains(b.UnitName, unitName) {
+		return false
+	}
+	if n, err := strconv.Atoi(query.Get("min_priority")); err == nil && b.Priority < n {
+		return false
+	}
+	if statuses := query["status"]; len(statuses) > 0 && !containsTerm(statuses, b.Status) {
+		return false
+	}
+	for _, tag := range query["tag"] {
+		if !hasTag(b.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
 
-	result, err := db.Exec(`
-		INSERT OR REPLACE INTO bookmarks (job_number, title, unit_name, url, api_url, type, date, note, priority, data)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, input.JobNumber, input.Title, input.UnitName, input.URL, input.APIURL, input.Type, input.Date, input.Note, input.Priority, input.Data)
+// 新增書籤的內容，POST /api/bookmarks 與 POST /api/bookmarks/bulk 共用
+type bookmarkInput struct {
+	JobNumber string     `json:"job_number"`
+	Title     string     `json:"title"`
+	UnitName  string     `json:"unit_name"`
+	URL       string     `json:"url"`
+	APIURL    string     `json:"api_url"`
+	Type      string     `json:"type"`
+	Date      TenderDate `json:"date"`
+	Note      *string    `json:"note"`     // 未提供時不變更既有書籤的備註
+	Priority  *int       `json:"priority"` // 未提供時不變更既有書籤的優先級
+	Data      string     `json:"data"`
+	Tags      *[]string  `json:"tags"` // 未提供時不變更既有書籤的標籤
+}
 
+// 驗證並標準化新增的書籤：案號、標籤與 data，標案名稱等欄位以 data 為準，必要時自動補齊
+// 失敗時回傳 HTTP 狀態碼、錯誤代碼與訊息參數，code 為空字串表示成功
+func (s *Server) prepareBookmarkInput(ctx context.Context, input *bookmarkInput) (int, string, []interface{}) {
+	jn, code := checkJobNumber(input.JobNumber)
+	switch code {
+	case "missing_job_number":
+		return http.StatusBadRequest, code, nil
+	case "invalid_job_number":
+		return http.StatusBadRequest, code, []interface{}{maxJobNumberLength, jobNumberPunct}
+	}
+	input.JobNumber = jn
+	if input.Tags != nil {
+		tags, err := normalizeTags(*input.Tags)
+		if err != nil {
+			return http.StatusBadRequest, "invalid_tags", []interface{}{err.Error()}
+		}
+		input.Tags = &tags
+	}
+	if err := validateData(input.Data); err != nil {
+		return http.StatusUnprocessableEntity, "invalid_data", []interface{}{err.Error()}
+	}
+	// 標案名稱、機關、日期等欄位以 data 為準（見 datasync.go）
+	cols := mirroredColumns{Title: input.Title, UnitName: input.UnitName, Type: input.Type, Date: input.Date, URL: input.URL, APIURL: input.APIURL}
+	synced, err := syncDataColumns(input.Data, &cols)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusUnprocessableEntity, "invalid_data", []interface{}{err.Error()}
+	}
+	// 只帶案號時依 pcc_g0v.autofill 查詢補齊（見 pccg0v.go）
+	s.autofillBookmark(ctx, input.JobNumber, &cols)
+	input.Title, input.UnitName, input.Type, input.Date, input.URL, input.APIURL = cols.Title, cols.UnitName, cols.Type, cols.Date, cols.URL, cols.APIURL
+	input.Data = synced
+	return 0, "", nil
+}
+
+// 在交易中新增或更新一筆書籤並記錄事件（稽核由呼叫端記錄），input 須已經過 prepareBookmarkInput
+// 已存在時只更新標案資料（標案名稱、機關、網址、類型、日期與 data），保留 id、created_at 與使用者填寫的欄位；
+// 備註與優先級只有在請求帶了該欄位時才覆寫（前端重複點星號或重新同步時不會帶這兩個欄位，帶空字串或 0 則會清除），
+// 標籤只有在帶了 tags 時才取代
+// 新增的書籤 version 為 1，更新時至少為 2，以此區分新增或更新；書籤在垃圾桶中時移出垃圾桶，restored 為 true
+func (s *Server) saveBookmarkInput(tx *Tx, r *http.Request, input *bookmarkInput) (saved *Bookmark, created, restored bool, err error) {
+	in := store.Input{
+		JobNumber: input.JobNumber, Title: input.Title, UnitName: input.UnitName, URL: input.URL, APIURL: input.APIURL,
+		Type: input.Type, Date: int(input.Date), Priority: input.Priority, Now: dbNow(),
+	}
+	if input.Note != nil {
+		note, err := s.db.cipher.seal("note", *input.Note)
+		if err != nil {
+			return nil, false, false, err
+		}
+		in.Note = &note
+	}
+	if in.Data, err = s.db.cipher.seal("data", input.Data); err != nil {
+		return nil, false, false, err
+	}
+	if created, restored, err = s.bookmarks.Upsert(r.Context(), tx, in); err != nil {
+		return nil, false, false, err
+	}
+	if err := syncBookmarkTerms(tx, input.JobNumber, input.Data); err != nil {
+		return nil, false, false, err
+	}
+	if input.Tags != nil {
+		if err := setBookmarkTags(tx, input.JobNumber, *input.Tags); err != nil {
+			return nil, false, false, err
+		}
+	}
+	saved, err = bookmarkSnapshot(tx, input.JobNumber)
+	if err != nil {
+		return nil, false, false, err
+	}
+	action := actionCreate
+	if restored {
+		action = actionRestore
+	} else if !created {
+		action = actionUpdate
+	}
+	if err := writeEvent(tx, requestActor(r), entityBookmark, input.JobNumber, action, saved); err != nil {
+		return nil, false, false, err
+	}
+	saved.MatchedCategories, saved.MatchedKeywords = bookmarkTerms(input.Data)
+	return saved, created, restored, nil
+}
+
+// 新增書籤：新增回傳 201，已存在而更新時回傳 200
+func (s *Server) addBookmark(w http.ResponseWriter, r *http.Request) {
+	var input bookmarkInput
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if status, code, args := s.prepareBookmarkInput(r.Context(), &input); code != "" {
+		writeError(w, r, status, code, args...)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"id":      id,
-		"message": "書籤已新增",
+	var saved *Bookmark
+	var created, restored bool
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		var err error
+		if saved, created, restored, err = s.saveBookmarkInput(tx, r, &input); err != nil {
+			return err
+		}
+		summary := "新增書籤"
+		if restored {
+			summary = "從垃圾桶還原並更新書籤"
+		} else if !created {
+			summary = "更新書籤"
+		}
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("%s「%s」（優先級 %d）", summary, input.Title, saved.Priority), input.JobNumber))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	s.markChanged()
+
+	status, code := http.StatusCreated, "bookmark_added"
+	if !created {
+		status, code = http.StatusOK, "bookmark_updated"
+	}
+	writeSuccess(w, r, status, code, map[string]interface{}{
+		"id":       saved.ID,
+		"version":  saved.Version,
+		"created":  created,
+		"restored": restored,
+		"bookmark": saved,
 	})
 }
 
-// 刪除書籤
-func deleteBookmark(w http.ResponseWriter, r *http.Request) {
-	jobNumber := r.URL.Query().Get("job_number")
-	if jobNumber == "" {
-		http.Error(w, "缺少 job_number 參數", http.StatusBadRequest)
+// 在交易中將一筆書籤移到垃圾桶（見 trash.go）並記錄刪除事件（稽核由呼叫端記錄）；
+// 書籤不存在或已在垃圾桶中時回傳 errBookmarkNotFound
+func (s *Server) removeBookmark(tx *Tx, r *http.Request, jobNumber string) error {
+	// 事件內容為刪除前的書籤
+	before, err := bookmarkSnapshot(tx, jobNumber)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return errBookmarkNotFound
+	}
+	if err := s.bookmarks.Delete(r.Context(), tx, jobNumber, dbNow()); err != nil {
+		return err
+	}
+	return writeEvent(tx, requestActor(r), entityBookmark, jobNumber, actionDelete, before)
+}
+
+// 刪除書籤：移到垃圾桶，可由 POST /api/bookmarks/restore 還原
+func (s *Server) deleteBookmark(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
 		return
 	}
 
-	_, err := db.Exec("DELETE FROM bookmarks WHERE job_number = ?", jobNumber)
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		if err := s.removeBookmark(tx, r, jobNumber); err != nil {
+			return err
+		}
+		return writeAudit(tx, newAuditEntry(r, "刪除書籤", jobNumber))
+	})
+	if errors.Is(err, errBookmarkNotFound) {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "書籤已刪除",
-	})
+	s.markChanged()
+	writeSuccess(w, r, http.StatusOK, "bookmark_deleted", map[string]interface{}{"deleted": 1})
 }
 
-// 更新書籤備註和優先級
-func updateBookmark(w http.ResponseWriter, r *http.Request) {
+// 更新時版本號不符
+var errVersionConflict = errors.New("版本衝突")
+
+// 書籤不存在，交易中回傳此錯誤以回滾並回應 404
+var errBookmarkNotFound = store.ErrNotFound
+
+// 更新書籤備註、優先級、標籤和狀態（都不在 data 中，不需要同步 data）
+// 只更新請求中有提供的欄位，都沒有時回傳 400；成功時回傳更新後的書籤
+// 狀態變更時在同一筆交易中記錄歷程，status_comment 為變更的說明（見 status.go）
+// 帶 version 時只有版本相符才更新，否則回傳 409 與目前的書籤；未帶 version 時維持最後寫入者勝出
+func (s *Server) updateBookmark(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		JobNumber string `json:"job_number"`
-		Note      string `json:"note"`
-		Priority  int    `json:"priority"`
+		JobNumber string    `json:"job_number"`
+		Note      *string   `json:"note"`
+		Priority  *int      `json:"priority"`
+		Tags      *[]string `json:"tags"`
+		Status    *string   `json:"status"`
+		Comment   string    `json:"status_comment"`
+		Version   *int      `json:"version"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	var ok bool
+	if input.JobNumber, ok = jobNumberParam(w, r, input.JobNumber); !ok {
+		return
+	}
+	if input.Note == nil && input.Priority == nil && input.Tags == nil && input.Status == nil {
+		writeError(w, r, http.StatusBadRequest, "nothing_to_update")
+		return
+	}
+	if input.Status != nil && !validStatus(*input.Status) {
+		writeStatusError(w, r, *input.Status)
+		return
+	}
+	if err := checkStatusComment(input.Comment); err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "status_comment: "+err.Error())
 		return
 	}
 
-	_, err := db.Exec(`
-		UPDATE bookmarks SET note = ?, priority = ? WHERE job_number = ?
-	`, input.Note, input.Priority, input.JobNumber)
+	var sets, changed []string
+	var args []interface{}
+	if input.Note != nil {
+		note, err := s.db.cipher.seal("note", *input.Note)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		sets = append(sets, "note = ?")
+		args = append(args, note)
+		changed = append(changed, "備註")
+	}
+	if input.Priority != nil {
+		sets = append(sets, "priority = ?")
+		args = append(args, *input.Priority)
+		changed = append(changed, fmt.Sprintf("優先級 %d", *input.Priority))
+	}
+	var tags []string
+	if input.Tags != nil {
+		var err error
+		if tags, err = normalizeTags(*input.Tags); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_tags", err.Error())
+			return
+		}
+		changed = append(changed, "標籤")
+	}
+	if input.Status != nil {
+		sets = append(sets, "status = ?")
+		args = append(args, *input.Status)
+		changed = append(changed, "狀態 "+*input.Status)
+	}
 
+	var updated, current *Bookmark
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		oldStatus, err := bookmarkStatus(tx, input.JobNumber)
+		if err != nil {
+			return err
+		}
+		query := "UPDATE bookmarks SET " + strings.Join(append(sets, "version = version + 1", "updated_at = ?"), ", ") + " WHERE job_number = ? AND deleted_at IS NULL"
+		args := append(args, dbNow(), input.JobNumber)
+		if input.Version != nil {
+			query += " AND version = ?"
+			args = append(args, *input.Version)
+		}
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			if current, err = bookmarkSnapshot(tx, input.JobNumber); err != nil {
+				return err
+			}
+			if current == nil {
+				return errBookmarkNotFound
+			}
+			return errVersionConflict
+		}
+		if input.Tags != nil {
+			if err := setBookmarkTags(tx, input.JobNumber, tags); err != nil {
+				return err
+			}
+		}
+		if input.Status != nil && *input.Status != oldStatus {
+			if err := recordStatusChange(tx, requestActor(r), input.JobNumber, oldStatus, *input.Status, input.Comment); err != nil {
+				return err
+			}
+		}
+		if updated, err = bookmarkSnapshot(tx, input.JobNumber); err != nil {
+			return err
+		}
+		if err := writeEvent(tx, requestActor(r), entityBookmark, input.JobNumber, actionUpdate, updated); err != nil {
+			return err
+		}
+		return writeAudit(tx, newAuditEntry(r, "更新書籤（"+strings.Join(changed, "、")+"）", input.JobNumber))
+	})
+	if errors.Is(err, errBookmarkNotFound) {
+		writeError(w, r, http.StatusNotFound, "not_found", input.JobNumber)
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"success": false,
+			"error":   "version_conflict",
+			"message": localize(requestLanguage(r), "version_conflict", *input.Version, current.Version),
+			"current": current,
+		})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "書籤已更新",
+	s.markChanged()
+	updated.MatchedCategories, updated.MatchedKeywords = bookmarkTerms(updated.Data)
+	writeSuccess(w, r, http.StatusOK, "bookmark_updated", map[string]interface{}{
+		"version":  updated.Version,
+		"bookmark": updated,
 	})
 }
 
 // 檢查是否已加入書籤
-func checkBookmark(w http.ResponseWriter, r *http.Request) {
-	jobNumber := r.URL.Query().Get("job_number")
-	if jobNumber == "" {
-		http.Error(w, "缺少 job_number 參數", http.StatusBadRequest)
+func (s *Server) checkBookmark(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
 		return
 	}
 
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&count)
+	exists, err := s.bookmarks.Exists(r.Context(), jobNumber)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"bookmarked": count > 0,
+	// 回傳查詢的案號，批次查詢的用戶端可據此對應結果
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"job_number": jobNumber,
+		"bookmarked": exists,
 	})
 }
 
+// GET /api/bookmarks/list 的回應格式（?format_version=）
+// 1 為案號陣列，先前產生的網頁依此讀取；2 為含 job_numbers、count 的物件。
+// 未指定時這一版仍回傳 1，讓已部署的舊網頁繼續運作，下一版改為 2
+const (
+	listFormatLegacy  = 1
+	listFormatCurrent = 2
+)
+
 // 取得所有書籤的 job_number 列表（用於前端快速判斷）
-func getBookmarkList(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query("SELECT job_number FROM bookmarks")
+func (s *Server) getBookmarkList(w http.ResponseWriter, r *http.Request) {
+	format, ok := intParam(r, "format_version", listFormatLegacy, listFormatLegacy, listFormatCurrent)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "format_version")
+		return
+	}
+	jobNumbers, warnings, err := s.bookmarks.JobNumbers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
-	defer rows.Close()
 
-	var jobNumbers []string
-	for rows.Next() {
-		var jn string
-		if err := rows.Scan(&jn); err == nil {
-			jobNumbers = append(jobNumbers, jn)
-		}
+	w.Header().Set("X-Scan-Warnings", strconv.Itoa(warnings))
+	if format == listFormatLegacy {
+		writeJSON(w, http.StatusOK, jobNumbers)
+		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jobNumbers)
+	// count 供前端判斷是否顯示「沒有書籤」，不必計算陣列長度；沒有書籤時 job_numbers 為 []
+	// 前端輪詢時帶 If-None-Match，資料未變動時由 conditionalBookmarks 回傳 304
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"format_version": listFormatCurrent,
+		"job_numbers":    jobNumbers,
+		"count":          len(jobNumbers),
+		"generated_at":   localTime(time.Now().Truncate(time.Millisecond)),
+	})
 }
 
-// 下載書籤的標書資料
-func downloadBookmarkedTenders(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(`
-		SELECT job_number, title, api_url 
-		FROM bookmarks 
-		ORDER BY priority DESC, created_at DESC
-	`)
+// 下載書籤的標書資料（同步模式，整批完成後才結束請求；背景下載見 downloads.go）
+func (s *Server) downloadBookmarkedTenders(w http.ResponseWriter, r *http.Request) {
+	defer beginJob("download")()
+
+	tasks, warnings, err := s.loadDownloadTasks(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, r, err)
 		return
 	}
-	defer rows.Close()
 
-	type DownloadTask struct {
-		JobNumber string `json:"job_number"`
-		Title     string `json:"title"`
-		APIURL    string `json:"api_url"`
+	// 預設以 NDJSON 逐筆輸出（每筆結果一行，最後一行為摘要），瀏覽器與代理伺服器不必等整批完成；
+	// ?stream=false 維持舊的格式，整批完成後輸出一個 JSON 物件
+	stream := r.URL.Query().Get("stream") != "false"
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	if stream {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx 不要緩衝回應
+		w.WriteHeader(http.StatusOK)
+		if flusher != nil {
+			flusher.Flush()
+		}
 	}
 
-	var tasks []DownloadTask
-	for rows.Next() {
-		var t DownloadTask
-		if err := rows.Scan(&t.JobNumber, &t.Title, &t.APIURL); err == nil {
-			tasks = append(tasks, t)
+	// 用戶端中斷連線時停止，已下載的結果仍寫入稽核紀錄
+	var mu sync.Mutex
+	sum := s.runDownloads(r.Context(), r, tasks, func(result downloadResult) {
+		if !stream {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(struct {
+			Type string `json:"type"`
+			downloadResult
+		}{"result", result})
+		if flusher != nil {
+			flusher.Flush()
 		}
+	})
+	if sum.canceled {
+		log.Printf("用戶端中斷下載，已處理 %d/%d 筆", len(sum.results), len(tasks))
+	}
+	s.recordDownload(r, len(tasks), sum)
+	if sum.canceled {
+		return
 	}
 
-	// 建立下載目錄
-	downloadDir := filepath.Join("..", "pcc_data", "2026", "bookmarked_tenders")
-	os.MkdirAll(downloadDir, 0755)
+	downloaded := 0
+	for _, result := range sum.results {
+		if result.Status == downloadSuccess {
+			downloaded++
+		}
+	}
+	if stream {
+		enc.Encode(map[string]interface{}{
+			"type":              "summary",
+			"total":             len(tasks),
+			"success":           downloaded,
+			"warnings":          warnings,
+			"mismatches":        sum.mismatches,
+			"throttled_seconds": sum.throttled.Seconds(),
+			"output_dir":        s.tendersDir(),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":             len(tasks),
+		"warnings":          warnings,
+		"mismatches":        sum.mismatches,
+		"throttled_seconds": sum.throttled.Seconds(),
+		"results":           append(make([]downloadResult, 0), sum.results...),
+		"output_dir":        s.tendersDir(),
+	})
+}
+
+// 匯出書籤；?format=json（預設）|csv|xlsx，篩選與排序參數同 GET /api/bookmarks
+// JSON 逐筆讀取並直接寫出，記憶體用量不隨書籤數量增加；CSV 與 XLSX 見 spreadsheet.go
+func (s *Server) exportBookmarks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := firstNonEmpty(query.Get("format"), "json")
+	if format != "json" && format != "csv" && format != "xlsx" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "format")
+		return
+	}
+	filter, args, badParam := bookmarkFilter(query)
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	orderBy, badParam := bookmarkOrder(query)
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	if format != "json" {
+		s.exportSpreadsheet(w, r, format, filter, args, orderBy)
+		return
+	}
 
-	results := make([]map[string]interface{}, 0)
-	client := &http.Client{Timeout: 30 * time.Second}
+	fill, err := s.termFiller()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+bookmarkColumns+" FROM bookmarks "+filter+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
 
-	for _, task := range tasks {
-		result := map[string]interface{}{
-			"job_number": task.JobNumber,
-			"title":      task.Title,
-			"status":     "pending",
+	// 第一筆讀取成功後才送出標頭；之前失敗時仍可回傳錯誤
+	enc := json.NewEncoder(w)
+	started := false
+	begin := func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", contentDisposition(fmt.Sprintf("bookmarks_%s.json", time.Now().In(displayLocation).Format("20060102_150405"))))
+		io.WriteString(w, "[")
+		started = true
+	}
+	// 匯出必須完整，任何一筆讀取失敗都視為錯誤。已開始輸出時無法改回傳錯誤，
+	// 中斷連線讓用戶端收到不完整的回應，而不是一個少了資料卻仍能解析的陣列
+	abort := func(err error) {
+		if !started {
+			writeDBError(w, r, err)
+			return
 		}
+		log.Printf("[%s] 匯出中斷: %v", requestID(r), err)
+		panic(http.ErrAbortHandler)
+	}
 
-		if task.APIURL == "" {
-			result["status"] = "error"
-			result["error"] = "無 API URL"
-			results = append(results, result)
-			continue
+	var b Bookmark
+	for rows.Next() {
+		if b, err = scanBookmark(rows, s.db.cipher); err != nil {
+			abort(fmt.Errorf("匯出時讀取書籤失敗（id=%d）: %w", b.ID, err))
+			return
+		}
+		fill(&b)
+		if !started {
+			begin()
+		} else {
+			io.WriteString(w, ",")
 		}
+		if err := enc.Encode(b); err != nil {
+			log.Println("匯出寫入失敗:", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		abort(err)
+		return
+	}
+	if !started {
+		begin()
+	}
+	io.WriteString(w, "]\n")
+}
 
-		// 下載標案詳細資料
-		resp, err := client.Get(task.APIURL)
-		if err != nil {
-			result["status"] = "error"
-			result["error"] = err.Error()
-			results = append(results, result)
-			continue
+func main() {
+	if isClientMode(os.Args[1:]) {
+		if err := runClient(os.Args[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCode(err))
 		}
+		return
+	}
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
 
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+// 所有致命錯誤都回傳到 main 統一結束，不使用 log.Fatal，以免略過 defer 的清理：
+// 依序停止接受請求、中斷逾時的請求並等待工作結束、停止排程，最後 WAL checkpoint 並關閉資料庫
+func run() error {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		return runImport(os.Args[2:])
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := checkDataDir(cfg, *initFlag); err != nil {
+		return err
+	}
+
+	if *encryptExistingFlag {
+		s, err := newServer(cfg, cfg.primaryDBPath())
 		if err != nil {
-			result["status"] = "error"
-			result["error"] = err.Error()
-			results = append(results, result)
-			continue
+			return err
 		}
-
-		// 儲存檔案
-		filename := filepath.Join(downloadDir, fmt.Sprintf("%s.json", strings.ReplaceAll(task.JobNumber, "/", "_")))
-		err = os.WriteFile(filename, body, 0644)
+		defer s.close()
+		n, err := s.encryptExisting()
 		if err != nil {
-			result["status"] = "error"
-			result["error"] = err.Error()
-			results = append(results, result)
-			continue
+			return err
 		}
-
-		result["status"] = "success"
-		result["file"] = filename
-		results = append(results, result)
-
-		// 避免請求過快
-		time.Sleep(500 * time.Millisecond)
+		log.Printf("已加密 %d 筆書籤", n)
+		return nil
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total":      len(tasks),
-		"results":    results,
-		"output_dir": downloadDir,
-	})
+	// 例如連接埠已被佔用
+	ln, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		return err
+	}
+	// 收到 SIGINT/SIGTERM 時停止接受新請求，等待處理中的請求完成後關閉資料庫
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	return serveUntilSignal(cfg, ln, sig)
 }
 
-// 匯出書籤為 JSON
-func exportBookmarks(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(`
-		SELECT id, job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at 
-		FROM bookmarks 
-		ORDER BY priority DESC, created_at DESC
-	`)
+// 開啟資料庫並在 ln 上提供服務，直到 sig 收到訊號或伺服器發生錯誤；回傳前完成所有清理並關閉 ln
+func serveUntilSignal(cfg Config, ln net.Listener, sig <-chan os.Signal) error {
+	s, err := newServer(cfg, cfg.primaryDBPath())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		ln.Close()
+		return err
 	}
-	defer rows.Close()
+	defer s.close()
 
-	var bookmarks []Bookmark
-	for rows.Next() {
-		var b Bookmark
-		var dataStr sql.NullString
-		err := rows.Scan(&b.ID, &b.JobNumber, &b.Title, &b.UnitName, &b.URL, &b.APIURL, &b.Type, &b.Date, &b.Note, &b.Priority, &dataStr, &b.CreatedAt)
-		if err != nil {
-			continue
+	// 通知在排程之後停止、資料庫關閉之前送完佇列中的訊息
+	if !cfg.ReadOnly {
+		if err := s.startNotifications(); err != nil {
+			ln.Close()
+			return err
 		}
-		if dataStr.Valid {
-			b.Data = dataStr.String
+		defer s.notifications.stop(10 * time.Second)
+		s.startWebhooks()
+		defer s.webhooks.stop(10 * time.Second)
+	}
+
+	s.printBanner(cfg.Port)
+
+	// 背景排程
+	sched := newScheduler()
+	s.schedule(sched)
+	defer sched.stop()
+
+	// 所有請求的 context 都衍生自 baseCtx，關閉逾時時取消，讓下載等長時間的請求停止
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	handler := s.routes()
+	srv := &http.Server{
+		Handler:     logRequests(handler),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	// 事件串流不會自行結束，關閉開始時通知它們結束，否則 Shutdown 會等到逾時
+	srv.RegisterOnShutdown(s.broker.shutdown)
+
+	// Telegram bot 的指令經由同一組路由執行；在 HTTP 伺服器之後、資料庫關閉之前停止
+	bot := s.startTelegram(handler)
+	defer bot.stop(10 * time.Second)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-sig:
+	}
+
+	log.Println("正在關閉伺服器...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("等待處理中的請求逾時，中斷剩餘的請求:", err)
+		cancelRequests()
+		if remaining := waitJobs(10 * time.Second); len(remaining) > 0 {
+			log.Println("仍有工作未結束:", strings.Join(remaining, ", "))
 		}
-		bookmarks = append(bookmarks, b)
 	}
+	if !s.broker.waitSockets(5 * time.Second) {
+		log.Println("等待 WebSocket 連線關閉逾時")
+	}
+	return nil
+}
 
-	// 設定下載標頭
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=bookmarks_%s.json", time.Now().Format("20060102_150405")))
-	json.NewEncoder(w).Encode(bookmarks)
+// 啟動畫面中列出的 API 端點
+var bannerRoutes = []struct {
+	method, path, code string
+}{
+	{"GET", "/api/bookmarks", "route_list_all"},
+	{"POST", "/api/bookmarks", "route_add"},
+	{"PUT", "/api/bookmarks", "route_update"},
+	{"DELETE", "/api/bookmarks", "route_delete"},
+	{"POST", "/api/bookmarks/bulk", "route_bulk_add"},
+	{"DELETE", "/api/bookmarks/bulk", "route_bulk_delete"},
+	{"GET", "/api/bookmarks/list", "route_job_numbers"},
+	{"GET", "/api/bookmarks/history", "route_status_history"},
+	{"POST", "/api/bookmarks/refresh", "route_refresh"},
+	{"GET", "/api/bookmarks/changes", "route_changes"},
+	{"PUT", "/api/bookmarks/changes", "route_changes_seen"},
+	{"GET", "/api/bookmarks/search", "route_search"},
+	{"GET", "/api/bookmarks/download", "route_download"},
+	{"POST", "/api/bookmarks/download", "route_download_start"},
+	{"GET", "/api/bookmarks/download/status", "route_download_status"},
+	{"DELETE", "/api/bookmarks/download", "route_download_cancel"},
+	{"GET", "/api/bookmarks/download/archive", "route_download_archive"},
+	{"GET", "/api/bookmarks/download/file", "route_download_file"},
+	{"GET", "/api/bookmarks/export", "route_export"},
+	{"POST", "/api/bookmarks/import", "route_import"},
+	{"GET", "/api/tags", "route_tags"},
+	{"DELETE", "/api/tags", "route_tag_delete"},
+	{"GET", "/api/bookmarks/stats", "route_stats"},
+	{"POST", "/api/bookmarks/{job_number}/reconcile", "route_reconcile"},
+	{"GET", "/api/bookmarks/{job_number}/detail", "route_bookmark_detail"},
+	{"GET", "/api/awards", "route_awards"},
+	{"GET", "/api/bookmarks/calendar", "route_calendar_export"},
+	{"GET", "/api/tenders", "route_tenders"},
+	{"GET", "/api/tenders/stats", "route_tender_stats"},
+	{"GET", "/api/lookup", "route_lookup"},
+	{"GET", "/calendar/{token}.ics", "route_calendar_feed"},
+	{"GET", "/api/bookmarks/feed.json", "route_bookmark_feed"},
+	{"GET", "/api/shares", "route_shares"},
+	{"GET", "/share/{token}", "route_share_page"},
+	{"GET", "/api/version", "route_version"},
+	{"GET", "/api/health", "route_health"},
+	{"GET", "/api/files", "route_files"},
+	{"GET", "/api/events", "route_events"},
+	{"GET", "/api/events/stream", "route_events_stream"},
+	{"GET", "/api/ws", "route_websocket"},
+	{"GET", "/api/notifications", "route_notifications"},
+	{"GET", "/api/notifications/log", "route_notification_log"},
+	{"POST", "/api/notifications/test", "route_notification_test"},
+	{"GET", "/api/attachments", "route_attachments"},
+	{"POST", "/api/bookmarks/attachments", "route_bookmark_attachments"},
+	{"GET", "/api/bookmarks/attachments/download", "route_attachment_download"},
+	{"GET", "/api/bookmarks/trash", "route_trash"},
+	{"POST", "/api/bookmarks/restore", "route_restore"},
+	{"DELETE", "/api/bookmarks/purge", "route_purge"},
+	{"GET", "/api/admin/audit", "route_audit"},
+	{"GET", "/api/admin/metrics", "route_metrics"},
+	{"GET", "/api/admin/integrity", "route_integrity"},
+	{"POST", "/api/admin/maintenance", "route_maintenance"},
+	{"GET", "/api/admin/backups", "route_backups"},
+	{"POST", "/api/admin/email-digest", "route_email_digest"},
+	{"GET", "/api/reports/weekly", "route_weekly_reports"},
+	{"POST", "/api/reports/weekly/run", "route_weekly_report_run"},
+	{"GET", "/api/webhooks", "route_webhooks"},
+	{"GET", "/api/ingest/status", "route_ingest_status"},
+	{"POST", "/api/ingest/run", "route_ingest_run"},
+	{"GET", "/api/watchlist", "route_watchlist"},
+	{"POST", "/api/watchlist/import", "route_watchlist_import"},
+	{"GET", "/api/sync", "route_sync"},
+	{"GET", "/api/sync/pull", "route_sync_pull"},
+	{"POST", "/api/sync/push", "route_sync_push"},
+	{"POST", "/api/sync/run", "route_sync_run"},
+	{"GET", "/api/admin/invalid-data", "route_invalid_data"},
+	{"GET", "/api/admin/data-drift", "route_data_drift"},
+	{"GET", "/api/admin/invalid-dates", "route_invalid_dates"},
+	{"GET", "/api/admin/invalid-tenders", "route_invalid_tenders"},
+	{"GET", "/api/admin/job-number-collisions", "route_job_number_collisions"},
+	{"POST", "/api/admin/archive-year", "route_archive_year"},
+	{"POST", "/api/admin/years", "route_years"},
+	{"POST", "/api/admin/prune", "route_prune"},
+	{"GET", "/api/admin/dump", "route_dump"},
+	{"GET", "/api/admin/tender-cache", "route_tender_cache"},
 }
 
-func main() {
-	initDB()
-	defer db.Close()
-
-	// API 路由
-	http.HandleFunc("/api/bookmarks", corsMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			getBookmarks(w, r)
-		case "POST":
-			addBookmark(w, r)
-		case "PUT":
-			updateBookmark(w, r)
-		case "DELETE":
-			deleteBookmark(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	}))
-
-	http.HandleFunc("/api/bookmarks/list", corsMiddleware(getBookmarkList))
-	http.HandleFunc("/api/bookmarks/check", corsMiddleware(checkBookmark))
-	http.HandleFunc("/api/bookmarks/download", corsMiddleware(downloadBookmarkedTenders))
-	http.HandleFunc("/api/bookmarks/export", corsMiddleware(exportBookmarks))
-
-	// 靜態檔案服務
-	staticDir := filepath.Join("..", "pcc_data", "2026", "filtered_for_company")
-	fs := http.FileServer(http.Dir(staticDir))
-	http.Handle("/", fs)
-
-	port := "8080"
+// 以預設語系輸出啟動畫面
+func (s *Server) printBanner(port string) {
+	lang := s.cfg.Language
 	fmt.Println("========================================")
-	fmt.Println("  標案書籤管理系統")
+	fmt.Println("  " + localize(lang, "banner_title"))
 	fmt.Println("========================================")
-	fmt.Printf("  伺服器啟動於: http://localhost:%s\n", port)
-	fmt.Println("  API 端點:")
-	fmt.Println("    GET    /api/bookmarks       - 取得所有書籤")
-	fmt.Println("    POST   /api/bookmarks       - 新增書籤")
-	fmt.Println("    PUT    /api/bookmarks       - 更新書籤")
-	fmt.Println("    DELETE /api/bookmarks       - 刪除書籤")
-	fmt.Println("    GET    /api/bookmarks/list  - 取得書籤列表")
-	fmt.Println("    GET    /api/bookmarks/download - 下載標書")
-	fmt.Println("    GET    /api/bookmarks/export   - 匯出書籤")
+	fmt.Println("  " + localize(lang, "banner_listening", "http://localhost:"+port))
+	if s.cfg.ReadOnly {
+		fmt.Println("  " + localize(lang, "banner_read_only"))
+	}
+	if len(s.cfg.APIKeys) > 0 {
+		fmt.Println("  " + localize(lang, "banner_api_keys", len(s.cfg.APIKeys)))
+	}
+	fmt.Println("  " + localize(lang, "banner_endpoints"))
+	for _, route := range bannerRoutes {
+		fmt.Printf("    %-6s %-24s - %s\n", route.method, route.path, localize(lang, route.code))
+	}
 	fmt.Println("========================================")
-
-	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
@@ -0,0 +1,759 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// POST /api/bookmarks 的回應
+type addBookmarkResponse struct {
+	Code     string   `json:"code"`
+	Created  bool     `json:"created"`
+	Restored bool     `json:"restored"`
+	Bookmark Bookmark `json:"bookmark"`
+}
+
+func postBookmark(t *testing.T, h http.Handler, body string) (int, addBookmarkResponse) {
+	t.Helper()
+	w := serve(t, h, "POST", "/api/bookmarks", body)
+	var resp addBookmarkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("回應無法解析: %v: %s", err, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func TestAddBookmarkUpsert(t *testing.T) {
+	tests := []struct {
+		name         string
+		readd        string
+		wantNote     string
+		wantPriority int
+		wantTitle    string
+	}{
+		{
+			name:         "re-add without user fields keeps them",
+			readd:        `{"job_number":"A001","title":"新名稱"}`,
+			wantNote:     "已聯絡廠商",
+			wantPriority: 3,
+			wantTitle:    "新名稱",
+		},
+		{
+			name:         "explicit note and priority overwrite",
+			readd:        `{"job_number":"A001","title":"新名稱","note":"改過","priority":1}`,
+			wantNote:     "改過",
+			wantPriority: 1,
+			wantTitle:    "新名稱",
+		},
+		{
+			name:         "explicit empty note and zero priority clear",
+			readd:        `{"job_number":"A001","title":"新名稱","note":"","priority":0}`,
+			wantNote:     "",
+			wantPriority: 0,
+			wantTitle:    "新名稱",
+		},
+		{
+			name:         "only note given keeps priority",
+			readd:        `{"job_number":"A001","title":"原名稱","note":""}`,
+			wantNote:     "",
+			wantPriority: 3,
+			wantTitle:    "原名稱",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(t).routes()
+			status, first := postBookmark(t, h, `{"job_number":"A001","title":"原名稱","note":"已聯絡廠商","priority":3}`)
+			if status != http.StatusCreated || !first.Created || first.Code != "bookmark_added" {
+				t.Fatalf("第一次新增: status=%d created=%v code=%s", status, first.Created, first.Code)
+			}
+
+			status, second := postBookmark(t, h, tt.readd)
+			if status != http.StatusOK || second.Created || second.Code != "bookmark_updated" {
+				t.Fatalf("重新新增: status=%d created=%v code=%s", status, second.Created, second.Code)
+			}
+			b := second.Bookmark
+			if b.Note != tt.wantNote || b.Priority != tt.wantPriority || b.Title != tt.wantTitle {
+				t.Errorf("note=%q priority=%d title=%q, want %q %d %q", b.Note, b.Priority, b.Title, tt.wantNote, tt.wantPriority, tt.wantTitle)
+			}
+			if b.ID != first.Bookmark.ID {
+				t.Errorf("id 改變: %d -> %d", first.Bookmark.ID, b.ID)
+			}
+			if !b.CreatedAt.Equal(first.Bookmark.CreatedAt) {
+				t.Errorf("created_at 改變: %v -> %v", first.Bookmark.CreatedAt, b.CreatedAt)
+			}
+			if b.Version <= first.Bookmark.Version {
+				t.Errorf("version 未遞增: %d -> %d", first.Bookmark.Version, b.Version)
+			}
+		})
+	}
+}
+
+func TestAddBookmarkRestoresFromTrash(t *testing.T) {
+	h := newTestServer(t).routes()
+	postBookmark(t, h, `{"job_number":"A001","title":"測試","note":"保留"}`)
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A001", "")
+
+	status, resp := postBookmark(t, h, `{"job_number":"A001","title":"測試"}`)
+	if status != http.StatusOK || resp.Created || !resp.Restored {
+		t.Fatalf("status=%d created=%v restored=%v", status, resp.Created, resp.Restored)
+	}
+	if resp.Bookmark.Note != "保留" {
+		t.Errorf("還原後備註 = %q", resp.Bookmark.Note)
+	}
+}
+
+func TestAddBookmarkConcurrent(t *testing.T) {
+	h := newFileTestServer(t).routes()
+
+	const n = 20
+	var wg sync.WaitGroup
+	statuses := make([]int, n)
+	created := make([]bool, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := serve(t, h, "POST", "/api/bookmarks", `{"job_number":"A001","title":"同時新增","priority":2}`)
+			statuses[i] = w.Code
+			var resp addBookmarkResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			created[i] = resp.Created
+		}(i)
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i := range statuses {
+		if statuses[i] != http.StatusCreated && statuses[i] != http.StatusOK {
+			t.Errorf("請求 %d 回應 %d", i, statuses[i])
+		}
+		if created[i] {
+			createdCount++
+		}
+	}
+	if createdCount != 1 {
+		t.Errorf("created 為 true 的回應有 %d 個，want 1", createdCount)
+	}
+
+	resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if resp["count"] != float64(1) {
+		t.Errorf("書籤數量 = %v, want 1", resp["count"])
+	}
+}
+
+// 讀取單一書籤
+func getTestBookmark(t *testing.T, h http.Handler, jobNumber string) Bookmark {
+	t.Helper()
+	w := serve(t, h, "GET", "/api/bookmarks", "")
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(w.Body.Bytes(), &bookmarks); err != nil {
+		t.Fatalf("解析書籤失敗: %v: %s", err, w.Body.String())
+	}
+	for _, b := range bookmarks {
+		if b.JobNumber == jobNumber {
+			return b
+		}
+	}
+	t.Fatalf("找不到書籤 %s", jobNumber)
+	return Bookmark{}
+}
+
+func TestUpdatedAtBumpedByWrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		bumps  bool
+	}{
+		{"note edit", "PUT", "/api/bookmarks", `{"job_number":"A001","note":"新備註"}`, true},
+		{"priority change", "PUT", "/api/bookmarks", `{"job_number":"A001","priority":4}`, true},
+		{"re-add", "POST", "/api/bookmarks", `{"job_number":"A001","title":"新名稱"}`, true},
+		{"bulk add", "POST", "/api/bookmarks/bulk", `[{"job_number":"A001","title":"批次"},{"job_number":"A002","title":"其他"}]`, true},
+		{"import overwrite", "POST", "/api/bookmarks/import?mode=overwrite", `[{"job_number":"A001","title":"匯入","note":"匯入備註"}]`, true},
+		{"list read", "GET", "/api/bookmarks", "", false},
+		{"check read", "GET", "/api/bookmarks/check?job_number=A001", "", false},
+		{"export read", "GET", "/api/bookmarks/export", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(t).routes()
+			addTestBookmark(t, h, `{"job_number":"A001","title":"原名稱"}`)
+			before := getTestBookmark(t, h, "A001")
+			meta := decodeBody(t, serve(t, h, "GET", "/api/version", ""))
+			time.Sleep(5 * time.Millisecond)
+
+			if w := serve(t, h, tt.method, tt.target, tt.body); w.Code >= 300 {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			after := getTestBookmark(t, h, "A001")
+			if bumped := after.UpdatedAt.After(before.UpdatedAt); bumped != tt.bumps {
+				t.Errorf("updated_at %v -> %v, bumped = %v, want %v", before.UpdatedAt, after.UpdatedAt, bumped, tt.bumps)
+			}
+			if got := decodeBody(t, serve(t, h, "GET", "/api/version", ""))["last_updated_at"]; (got != meta["last_updated_at"]) != tt.bumps {
+				t.Errorf("last_updated_at %v -> %v", meta["last_updated_at"], got)
+			}
+		})
+	}
+}
+
+// 前端點兩次星號時重送的內容只有標案資料，不得清掉使用者之後填寫的備註
+func TestReAddKeepsNote(t *testing.T) {
+	h := newTestServer(t).routes()
+	star := `{"job_number":"A001","title":"道路養護工程","unit_name":"臺北市政府","data":"{\"job_number\":\"A001\"}"}`
+	_, first := postBookmark(t, h, star)
+	if w := serve(t, h, "PUT", "/api/bookmarks", `{"job_number":"A001","note":"週五前報價","priority":2}`); w.Code != http.StatusOK {
+		t.Fatalf("更新備註回應 %d: %s", w.Code, w.Body.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		postBookmark(t, h, star)
+	}
+	b := getTestBookmark(t, h, "A001")
+	if b.Note != "週五前報價" || b.Priority != 2 {
+		t.Errorf("重新加入後 note=%q priority=%d", b.Note, b.Priority)
+	}
+	if b.ID != first.Bookmark.ID || !b.CreatedAt.Equal(first.Bookmark.CreatedAt) {
+		t.Errorf("重新加入後 id 或 created_at 改變: %d %v -> %d %v", first.Bookmark.ID, first.Bookmark.CreatedAt, b.ID, b.CreatedAt)
+	}
+}
+
+func TestEmptyDatabaseBodies(t *testing.T) {
+	h := newTestServer(t).routes()
+
+	// 沒有時間等變動欄位的回應，比對完整內容
+	exact := []struct {
+		target string
+		body   string
+	}{
+		{"/api/bookmarks", "[]\n"},
+		{"/api/bookmarks?page=1", `{"items":[],"page":1,"page_size":50,"total":0}` + "\n"},
+		{"/api/bookmarks/export", "[]\n"},
+		{"/api/bookmarks/list", "[]\n"},
+		{"/api/bookmarks/trash", `{"items":[]}` + "\n"},
+		{"/api/tags", `{"items":[],"total":0}` + "\n"},
+		{"/api/bookmarks/changes", `{"items":[],"total":0,"unseen":0}` + "\n"},
+		{"/api/shares", `{"items":[],"total":0}` + "\n"},
+		{"/api/webhooks", `{"items":[],"total":0}` + "\n"},
+		{"/api/admin/audit", `{"items":[],"page":1,"page_size":50,"total":0}` + "\n"},
+		{"/api/admin/invalid-data", `{"items":[],"total":0}` + "\n"},
+		{"/api/admin/job-number-collisions", `{"collisions":[],"non_canonical":[],"total":0}` + "\n"},
+	}
+	for _, tt := range exact {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != http.StatusOK || w.Body.String() != tt.body {
+				t.Errorf("status=%d body=%q, want %q", w.Code, w.Body.String(), tt.body)
+			}
+		})
+	}
+
+	t.Run("/api/bookmarks/list", func(t *testing.T) {
+		w := serve(t, h, "GET", "/api/bookmarks/list?format_version=2", "")
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if string(resp["job_numbers"]) != "[]" || string(resp["count"]) != "0" {
+			t.Errorf("job_numbers=%s count=%s, want [] 0", resp["job_numbers"], resp["count"])
+		}
+	})
+}
+
+func TestExportAbortsOnScanFailure(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"正常","priority":9}`)
+	// created_at 無法解析的資料列排在後面，讀到時已開始輸出
+	if _, err := s.db.Exec("INSERT INTO bookmarks (job_number, title, priority, created_at, updated_at) VALUES ('A002', '損壞', 1, 'garbage', 'garbage')"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("recover() = %v, want http.ErrAbortHandler", p)
+			}
+		}()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/api/bookmarks/export", nil))
+	}()
+	if !strings.HasPrefix(w.Body.String(), "[") {
+		t.Fatalf("中斷前應已開始輸出: %q", w.Body.String())
+	}
+	var v []Bookmark
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err == nil {
+		t.Errorf("中斷的匯出仍可解析為完整的陣列: %s", w.Body.String())
+	}
+}
+
+// 記錄輸出期間的最大 heap 用量
+type heapSampler struct {
+	writes int
+	peak   uint64
+}
+
+func (h *heapSampler) Header() http.Header { return http.Header{} }
+func (h *heapSampler) WriteHeader(int)     {}
+func (h *heapSampler) Write(p []byte) (int, error) {
+	if h.writes++; h.writes%16 == 0 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > h.peak {
+			h.peak = m.HeapAlloc
+		}
+	}
+	return len(p), nil
+}
+
+// 匯出 rows 筆、每筆 data 約 8 KB 的書籤，回傳輸出期間 heap 高於開始前的最大值
+func exportPeakHeap(t *testing.T, rows int) uint64 {
+	s := newTestServer(t)
+	blob := `{"description":"` + strings.Repeat("標", 8<<10/3) + `"}`
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		for i := 0; i < rows; i++ {
+			if _, err := tx.Exec("INSERT INTO bookmarks (job_number, title, data, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+				fmt.Sprintf("E%05d", i), "匯出測試", blob, dbNow(), dbNow()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.routes()
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	w := &heapSampler{}
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/bookmarks/export", nil))
+	if w.peak < before.HeapAlloc {
+		return 0
+	}
+	return w.peak - before.HeapAlloc
+}
+
+// 逐筆輸出時 heap 用量不隨筆數增加：10 倍的資料量（約 16 MB）不應讓最大用量接近資料總量
+func TestExportPeakHeapDoesNotScale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("記憶體量測")
+	}
+	small := exportPeakHeap(t, 200)
+	large := exportPeakHeap(t, 2000)
+	t.Logf("200 筆 peak=%d KB，2000 筆 peak=%d KB", small>>10, large>>10)
+	if total := uint64(2000 * 8 << 10); large > total/2 {
+		t.Errorf("匯出 2000 筆時 heap 增加 %d KB，接近資料總量 %d KB", large>>10, total>>10)
+	}
+}
+
+func BenchmarkExportBookmarks(b *testing.B) {
+	for _, rows := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			oldRoot := dataRoot
+			dataRoot = b.TempDir()
+			defer func() { dataRoot = oldRoot }()
+			s, err := newServer(testConfig(), ":memory:")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.close()
+			err = s.db.withTx(context.Background(), func(tx *Tx) error {
+				for i := 0; i < rows; i++ {
+					if _, err := tx.Exec("INSERT INTO bookmarks (job_number, title, data, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+						fmt.Sprintf("E%05d", i), "匯出測試", `{"description":"`+strings.Repeat("x", 4096)+`"}`, dbNow(), dbNow()); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			h := s.routes()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/bookmarks/export", nil))
+			}
+			b.ReportMetric(float64(testing.AllocsPerRun(1, func() {
+				h.ServeHTTP(&heapSampler{}, httptest.NewRequest("GET", "/api/bookmarks/export", nil))
+			}))/float64(rows), "allocs/row")
+		})
+	}
+}
+
+// 寫入進行中收到 SIGTERM：已回應的寫入都保留，結束後 WAL 已 checkpoint，資料庫沒有被鎖住
+func TestShutdownOnSignalDuringWrites(t *testing.T) {
+	oldRoot := dataRoot
+	dataRoot = t.TempDir()
+	defer func() { dataRoot = oldRoot }()
+	cfg := testConfig()
+	cfg.DBPath = filepath.Join(dataRoot, "bookmarks.db")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+	sig := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serveUntilSignal(cfg, ln, sig) }()
+
+	var created atomic.Int64
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for n := 0; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				body := fmt.Sprintf(`{"job_number":"W%d-%04d","title":"關閉測試"}`, worker, n)
+				resp, err := http.Post(base+"/api/bookmarks", "application/json", strings.NewReader(body))
+				if err != nil {
+					continue // 關閉後無法連線
+				}
+				if resp.StatusCode == http.StatusCreated {
+					created.Add(1)
+				}
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	for created.Load() < 20 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	sig <- syscall.SIGTERM
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serveUntilSignal: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("收到 SIGTERM 後沒有結束")
+	}
+	close(stop)
+	wg.Wait()
+
+	if fi, err := os.Stat(cfg.DBPath + "-wal"); err == nil && fi.Size() > 0 {
+		t.Errorf("結束後 -wal 檔仍有 %d bytes", fi.Size())
+	}
+	d, _, err := openSQLite(cfg.DBPath, false)
+	if err != nil {
+		t.Fatalf("重新開啟資料庫: %v", err)
+	}
+	defer d.Close()
+	var busy, logFrames, checkpointed int
+	if err := d.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logFrames, &checkpointed); err != nil || busy != 0 {
+		t.Fatalf("資料庫仍被鎖住: busy=%d err=%v", busy, err)
+	}
+	var n int64
+	if err := d.QueryRow("SELECT COUNT(*) FROM bookmarks").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != created.Load() {
+		t.Errorf("資料庫有 %d 筆書籤，已回應 201 的有 %d 筆", n, created.Load())
+	}
+}
+
+// 預先準備的查詢在 WAL 檔案資料庫與多條連線下與寫入並行：寫入回應後立即查得到，刪除後立即查不到
+func TestPreparedLookupsWithConcurrentWrites(t *testing.T) {
+	s := newFileTestServer(t)
+	h := s.routes()
+	for i := 0; i < 20; i++ {
+		addTestBookmark(t, h, fmt.Sprintf(`{"job_number":"P%03d"}`, i))
+	}
+
+	check := func(jn string) interface{} {
+		w := serve(t, h, "GET", "/api/bookmarks/check?job_number="+jn, "")
+		if w.Code != http.StatusOK {
+			t.Errorf("check %s = %d: %s", jn, w.Code, w.Body.String())
+			return nil
+		}
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp["bookmarked"]
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if got := check(fmt.Sprintf("P%03d", (g+i)%20)); got != true {
+					t.Errorf("P%03d bookmarked = %v", (g+i)%20, got)
+					return
+				}
+				if got := check("NONE"); got != false {
+					t.Errorf("NONE bookmarked = %v", got)
+					return
+				}
+			}
+		}(g)
+	}
+
+	for i := 0; i < 50; i++ {
+		jn := fmt.Sprintf("W%03d", i)
+		addTestBookmark(t, h, `{"job_number":"`+jn+`"}`)
+		if got := check(jn); got != true {
+			t.Errorf("新增後 %s bookmarked = %v", jn, got)
+		}
+		if w := serve(t, h, "DELETE", "/api/bookmarks?job_number="+jn, ""); w.Code != http.StatusOK {
+			t.Fatalf("DELETE %s = %d: %s", jn, w.Code, w.Body.String())
+		}
+		if got := check(jn); got != false {
+			t.Errorf("刪除後 %s bookmarked = %v", jn, got)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	jobNumbers, _, err := s.bookmarks.JobNumbers(context.Background())
+	if err != nil || len(jobNumbers) != 20 {
+		t.Errorf("JobNumbers = %d 筆, %v; want 20", len(jobNumbers), err)
+	}
+	t.Logf("連線池: %d 條連線", s.db.Stats().OpenConnections)
+}
+
+// 比較預先準備與每次重新解析的檢查查詢（WAL 檔案資料庫、並行讀取）
+func BenchmarkCheckBookmark(b *testing.B) {
+	oldRoot := dataRoot
+	dataRoot = b.TempDir()
+	defer func() { dataRoot = oldRoot }()
+	s, err := newServer(testConfig(), filepath.Join(dataRoot, "bookmarks.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.close()
+	err = s.db.withTx(context.Background(), func(tx *Tx) error {
+		for i := 0; i < 1000; i++ {
+			if _, err := tx.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, ?, ?, ?)",
+				fmt.Sprintf("C%05d", i), "檢查測試", dbNow(), dbNow()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	const unprepared = "SELECT EXISTS(SELECT 1 FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL)"
+	lookups := map[string]func(ctx context.Context, jn string) (bool, error){
+		"prepared": s.bookmarks.Exists,
+		"unprepared": func(ctx context.Context, jn string) (bool, error) {
+			var exists bool
+			err := s.db.QueryRowContext(ctx, unprepared, jn).Scan(&exists)
+			return exists, err
+		},
+	}
+	for _, name := range []string{"prepared", "unprepared"} {
+		lookup := lookups[name]
+		b.Run(name, func(b *testing.B) {
+			var n atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					if _, err := lookup(ctx, fmt.Sprintf("C%05d", n.Add(1)%1000)); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+	}
+
+	// 檢查端點在背景持續寫入時的表現
+	b.Run("prepared-under-writes", func(b *testing.B) {
+		h := s.routes()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.db.withTx(context.Background(), func(tx *Tx) error {
+					_, err := tx.Exec("UPDATE bookmarks SET note = ? WHERE job_number = ?", fmt.Sprint(i), fmt.Sprintf("C%05d", i%1000))
+					return err
+				})
+			}
+		}()
+		var n atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				req := httptest.NewRequest("GET", fmt.Sprintf("/api/bookmarks/check?job_number=C%05d", n.Add(1)%1000), nil)
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	})
+}
+
+// 舊版用戶端寫入的 NULL 欄位：書籤仍出現在清單、查詢與匯出中，JSON 為空字串與 0；遷移將 NULL 補為空值
+func TestNullColumnsNotDropped(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"一般書籤"}`)
+	if _, err := s.db.Exec(`INSERT INTO bookmarks (job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at, updated_at)
+		VALUES ('N001', '舊資料', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, ?, ?)`, dbNow(), dbNow()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(t, h, "GET", "/api/bookmarks", "")
+	var raw []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil || len(raw) != 2 {
+		t.Fatalf("清單有 %d 筆 (%v): %s", len(raw), err, w.Body.String())
+	}
+	for _, b := range raw {
+		if b["job_number"] != "N001" {
+			continue
+		}
+		for _, field := range []string{"unit_name", "url", "api_url", "type", "note", "data"} {
+			if b[field] != "" {
+				t.Errorf("%s = %#v, want \"\"", field, b[field])
+			}
+		}
+		if b["priority"] != float64(0) || b["date"] != float64(0) {
+			t.Errorf("priority = %v, date = %v", b["priority"], b["date"])
+		}
+	}
+	if b := getTestBookmark(t, h, "N001"); b.Title != "舊資料" {
+		t.Errorf("GET N001 title = %q", b.Title)
+	}
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=N001", "")); resp["bookmarked"] != true {
+		t.Errorf("check N001 = %v", resp)
+	}
+	resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if jns, _ := resp["job_numbers"].([]interface{}); len(jns) != 2 {
+		t.Errorf("list = %v", resp)
+	}
+	if w := serve(t, h, "GET", "/api/bookmarks/export", ""); !strings.Contains(w.Body.String(), `"N001"`) {
+		t.Errorf("匯出缺少 N001: %s", w.Body.String())
+	}
+
+	var sql string
+	for _, m := range migrations {
+		if m.name == "backfill null bookmark columns" {
+			sql = m.sql
+		}
+	}
+	if sql == "" {
+		t.Fatal("找不到 NULL 回填遷移")
+	}
+	if _, err := s.db.Exec(sql); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+	var nulls int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE unit_name IS NULL OR url IS NULL OR api_url IS NULL OR type IS NULL OR note IS NULL OR priority IS NULL").Scan(&nulls); err != nil || nulls != 0 {
+		t.Errorf("遷移後仍有 %d 筆 NULL (%v)", nulls, err)
+	}
+}
+
+// 先前產生的網頁以 new Set(await response.json()) 讀取案號清單；未指定 format_version 時維持案號陣列
+func TestBookmarkListLegacyFormat(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001"}`)
+	addTestBookmark(t, h, `{"job_number":"A002"}`)
+	for _, target := range []string{"/api/bookmarks/list", "/api/bookmarks/list?format_version=1"} {
+		w := serve(t, h, "GET", target, "")
+		var jobNumbers []string
+		if err := json.Unmarshal(w.Body.Bytes(), &jobNumbers); err != nil {
+			t.Fatalf("%s 不是案號陣列: %v %s", target, err, w.Body.String())
+		}
+		sort.Strings(jobNumbers)
+		if !equalStrings(jobNumbers, []string{"A001", "A002"}) {
+			t.Errorf("%s = %v", target, jobNumbers)
+		}
+	}
+	resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if resp["format_version"] != float64(2) || resp["count"] != float64(2) {
+		t.Errorf("format_version=2 = %v", resp)
+	}
+}
+
+// 書籤案號清單：沒有書籤時為 []，有書籤時帶 ETag，資料未變動時以 304 回應輪詢
+func TestBookmarkListEmptyAndPopulated(t *testing.T) {
+	h := newTestServer(t).routes()
+	list := func(header map[string]string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/bookmarks/list?format_version=2", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		var resp map[string]json.RawMessage
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("%v: %s", err, w.Body.String())
+			}
+			var generated time.Time
+			if err := json.Unmarshal(resp["generated_at"], &generated); err != nil || time.Since(generated) > time.Minute {
+				t.Errorf("generated_at = %s (%v)", resp["generated_at"], err)
+			}
+		}
+		return w, resp
+	}
+
+	w, resp := list(nil)
+	if w.Code != http.StatusOK || string(resp["job_numbers"]) != "[]" || string(resp["count"]) != "0" {
+		t.Fatalf("empty list = %d: %s", w.Code, w.Body.String())
+	}
+
+	addTestBookmark(t, h, `{"job_number":"A001"}`)
+	addTestBookmark(t, h, `{"job_number":"A002"}`)
+	w, resp = list(nil)
+	var jobNumbers []string
+	json.Unmarshal(resp["job_numbers"], &jobNumbers)
+	sort.Strings(jobNumbers)
+	if !equalStrings(jobNumbers, []string{"A001", "A002"}) || string(resp["count"]) != "2" {
+		t.Errorf("list = %s", w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("清單沒有 ETag")
+	}
+
+	if w, _ := list(map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("未變動時 = %d: %q", w.Code, w.Body.String())
+	}
+
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A001", "")
+	w, resp = list(map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusOK || string(resp["count"]) != "1" {
+		t.Fatalf("刪除後 = %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Errorf("刪除後 ETag 未變動: %s", etag)
+	}
+}
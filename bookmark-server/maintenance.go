@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	errMaintenanceBusy        = errors.New("有工作執行中")
+	errIncrementalUnavailable = errors.New("資料庫未啟用 auto_vacuum=INCREMENTAL")
+)
+
+// 資料庫檔案大小（含 -wal），PostgreSQL 回傳 -1
+func (s *Server) databaseFileSize() int64 {
+	if s.db.dialect != dialectSQLite {
+		return -1
+	}
+	var total int64
+	for _, suffix := range []string{"", "-wal"} {
+		if info, err := os.Stat(s.dbPath + suffix); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// 執行 ANALYZE 與 VACUUM
+// 執行期間暫停所有寫入（讀取在 WAL 模式下不受影響），有下載或匯入工作時拒絕執行
+func (s *Server) runMaintenance(ctx context.Context, incremental bool) (map[string]interface{}, error) {
+	if jobs := runningJobs(); len(jobs) > 0 {
+		return map[string]interface{}{"running_jobs": jobs}, errMaintenanceBusy
+	}
+
+	s.db.gate.Lock()
+	defer s.db.gate.Unlock()
+
+	start := time.Now()
+	sizeBefore := s.databaseFileSize()
+
+	vacuum := "VACUUM"
+	if incremental {
+		if s.db.dialect != dialectSQLite {
+			return nil, errIncrementalUnavailable
+		}
+		var autoVacuum int
+		if err := s.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+			return nil, err
+		}
+		if autoVacuum != 2 {
+			return nil, errIncrementalUnavailable
+		}
+		vacuum = "PRAGMA incremental_vacuum"
+	}
+
+	steps := []string{"ANALYZE", vacuum}
+	if s.db.dialect == dialectSQLite {
+		steps = append(steps, "PRAGMA wal_checkpoint(TRUNCATE)")
+	}
+	for _, step := range steps {
+		if _, err := s.db.ExecContext(ctx, step); err != nil {
+			return nil, err
+		}
+	}
+
+	result := map[string]interface{}{
+		"steps":       steps,
+		"size_before": sizeBefore,
+		"size_after":  s.databaseFileSize(),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	log.Printf("資料庫維護完成: %s，%d → %d bytes，耗時 %s",
+		strings.Join(steps, ", "), sizeBefore, result["size_after"], time.Since(start).Round(time.Millisecond))
+	return result, nil
+}
+
+// 手動執行資料庫維護，?incremental=true 使用增量 VACUUM
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := s.runMaintenance(r.Context(), r.URL.Query().Get("incremental") == "true")
+	switch {
+	case errors.Is(err, errMaintenanceBusy):
+		writeError(w, r, http.StatusConflict, "maintenance_busy", strings.Join(result["running_jobs"].([]string), ", "))
+	case errors.Is(err, errIncrementalUnavailable):
+		writeError(w, r, http.StatusBadRequest, "incremental_vacuum_unavailable")
+	case err != nil:
+		writeDBError(w, r, err)
+	default:
+		writeJSON(w, http.StatusOK, result)
+	}
+}
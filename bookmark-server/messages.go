@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支援的語系
+const (
+	langZhTW = "zh-TW"
+	langEn   = "en"
+)
+
+// 訊息目錄：代碼 → 語系 → 文字
+// 代碼不隨語系變動，用戶端應以代碼判斷結果，不要解析文字
+var messages = map[string]map[string]string{
+	// 成功訊息
+	"bookmark_added":         {langZhTW: "書籤已新增", langEn: "Bookmark added"},
+	"bookmark_deleted":       {langZhTW: "書籤已刪除", langEn: "Bookmark deleted"},
+	"bookmark_updated":       {langZhTW: "書籤已更新", langEn: "Bookmark updated"},
+	"bookmarks_imported":     {langZhTW: "書籤已匯入", langEn: "Bookmarks imported"},
+	"bookmarks_bulk_added":   {langZhTW: "批次新增完成", langEn: "Bulk add finished"},
+	"bookmarks_bulk_deleted": {langZhTW: "批次刪除完成", langEn: "Bulk delete finished"},
+	"tag_deleted":            {langZhTW: "標籤已刪除", langEn: "Tag deleted"},
+	"bookmarks_refreshed":    {langZhTW: "標案資料已更新", langEn: "Tender data refreshed"},
+	"changes_marked_seen":    {langZhTW: "異動已標示為已讀", langEn: "Changes marked as seen"},
+	"attachment_uploaded":    {langZhTW: "附件已上傳", langEn: "Attachment uploaded"},
+	"attachment_deleted":     {langZhTW: "附件已刪除", langEn: "Attachment deleted"},
+	"bookmark_restored":      {langZhTW: "書籤已從垃圾桶還原", langEn: "Bookmark restored from trash"},
+	"trash_purged":           {langZhTW: "垃圾桶已清理", langEn: "Trash purged"},
+	"year_created":           {langZhTW: "年度資料庫已建立", langEn: "Year database created"},
+	"year_opened":            {langZhTW: "年度資料庫已存在", langEn: "Year database already exists"},
+
+	// 錯誤訊息
+	"read_only":                      {langZhTW: "伺服器為唯讀模式，無法修改資料", langEn: "Server is in read-only mode; changes are not allowed"},
+	"missing_job_number":             {langZhTW: "缺少 job_number 參數", langEn: "Missing job_number parameter"},
+	"invalid_job_number":             {langZhTW: "job_number 格式錯誤（最多 %d 字，只能包含文字、數字、空白與 %s）", langEn: "Invalid job_number (at most %d characters; letters, digits, spaces and %s only)"},
+	"invalid_json":                   {langZhTW: "請求內容格式錯誤: %s", langEn: "Invalid request body: %s"},
+	"unknown_route":                  {langZhTW: "找不到 API 路徑: %s", langEn: "Unknown API route: %s"},
+	"method_not_allowed":             {langZhTW: "不支援的請求方法", langEn: "Method not allowed"},
+	"database_error":                 {langZhTW: "資料庫錯誤（請求編號 %s）", langEn: "Database error (request ID %s)"},
+	"database_busy":                  {langZhTW: "資料庫忙碌中，請稍後再試（請求編號 %s）", langEn: "Database is busy, please retry (request ID %s)"},
+	"constraint_violation":           {langZhTW: "與既有資料衝突（請求編號 %s）", langEn: "Conflicts with existing data (request ID %s)"},
+	"record_not_found":               {langZhTW: "找不到資料（請求編號 %s）", langEn: "Record not found (request ID %s)"},
+	"invalid_parameter":              {langZhTW: "參數格式錯誤: %s", langEn: "Invalid parameter: %s"},
+	"csrf_header_required":           {langZhTW: "沒有 Origin 與 Referer 的請求須帶 %s 標頭或 API 金鑰", langEn: "Requests without Origin or Referer must send the %s header or an API key"},
+	"csrf_rejected":                  {langZhTW: "拒絕來自 %s 的跨站請求", langEn: "Cross-site request from %s rejected"},
+	"unknown_data_dir":               {langZhTW: "不存在或不允許的資料目錄: %s", langEn: "Unknown or disallowed data directory: %s"},
+	"file_error":                     {langZhTW: "檔案讀寫錯誤（請求編號 %s）", langEn: "File error (request ID %s)"},
+	"maintenance_busy":               {langZhTW: "有工作執行中，無法進行維護: %s", langEn: "Cannot run maintenance while jobs are running: %s"},
+	"incremental_vacuum_unavailable": {langZhTW: "資料庫未啟用 auto_vacuum=INCREMENTAL，無法增量整理", langEn: "Incremental vacuum requires auto_vacuum=INCREMENTAL"},
+	"unknown_year":                   {langZhTW: "找不到 %d 年度的資料庫", langEn: "No database for year %d"},
+	"year_not_found":                 {langZhTW: "%d 年度的資料庫尚未建立，請由 POST /api/admin/years 建立", langEn: "Database for year %d has not been created; create it with POST /api/admin/years"},
+	"invalid_data":                   {langZhTW: "data 欄位必須是 JSON 物件: %s", langEn: "data must be a JSON object: %s"},
+	"version_conflict":               {langZhTW: "書籤已被其他人修改（你的版本 %d，目前版本 %d），請重新載入", langEn: "Bookmark was modified elsewhere (your version %d, current %d); reload and retry"},
+	"backup_failed":                  {langZhTW: "資料庫備份失敗（請求編號 %s）", langEn: "Database backup failed (request ID %s)"},
+	"nothing_to_update":              {langZhTW: "請提供 note、priority、tags 或 status", langEn: "Provide note, priority, tags or status to update"},
+	"not_found":                      {langZhTW: "找不到書籤: %s", langEn: "Bookmark not found: %s"},
+	"attachment_not_found":           {langZhTW: "找不到附件: %d", langEn: "Attachment not found: %d"},
+	"tender_not_downloaded":          {langZhTW: "尚未下載此書籤的標書: %s", langEn: "Tender detail has not been downloaded for: %s"},
+	"not_in_trash":                   {langZhTW: "垃圾桶中沒有此書籤: %s", langEn: "Bookmark is not in the trash: %s"},
+	"upload_too_large":               {langZhTW: "上傳的檔案超過上限 %d 位元組", langEn: "Uploaded file exceeds the limit of %d bytes"},
+	"invalid_upload":                 {langZhTW: "上傳內容格式錯誤（須為 multipart/form-data，含 job_number 與 file 欄位）: %s", langEn: "Invalid upload (expected multipart/form-data with job_number and file fields): %s"},
+	"dump_unsupported":               {langZhTW: "SQL 傾印僅支援 SQLite", langEn: "SQL dump is only supported on SQLite"},
+	"backup_not_found":               {langZhTW: "找不到備份: %s", langEn: "Backup not found: %s"},
+
+	// 下載結果
+	"tender_fetch_failed":          {langZhTW: "無法取得標案詳細資料: %s", langEn: "Failed to fetch tender detail: %s"},
+	"streaming_unsupported":        {langZhTW: "此連線不支援串流回應", langEn: "Streaming is not supported on this connection"},
+	"notifications_disabled":       {langZhTW: "未設定通知提供者", langEn: "No notification providers are configured"},
+	"unknown_provider":             {langZhTW: "找不到通知提供者: %s", langEn: "Unknown notification provider: %s"},
+	"notification_failed":          {langZhTW: "產生通知失敗（請求編號 %s）", langEn: "Failed to build the notification (request ID %s)"},
+	"email_digest_disabled":        {langZhTW: "未設定每日摘要（email_digest.smtp）", langEn: "The email digest is not configured (email_digest.smtp)"},
+	"email_digest_failed":          {langZhTW: "產生每日摘要失敗（請求編號 %s）", langEn: "Failed to build the email digest (request ID %s)"},
+	"weekly_report_disabled":       {langZhTW: "未啟用每週報告（weekly_report.enabled）", langEn: "The weekly report is not enabled (weekly_report.enabled)"},
+	"weekly_report_failed":         {langZhTW: "產生每週報告失敗（請求編號 %s）", langEn: "Failed to generate the weekly report (request ID %s)"},
+	"weekly_report_webhook_failed": {langZhTW: "每週報告已產生，但送出 webhook 失敗: %s", langEn: "The weekly report was generated, but posting it to the webhook failed: %s"},
+	"webhook_invalid_url":          {langZhTW: "webhook 網址錯誤: %s", langEn: "Invalid webhook URL: %s"},
+	"webhook_private_address":      {langZhTW: "webhook 網址指向%s，須設定 allow_private 才能使用", langEn: "The webhook URL resolves to a private address (%s); set allow_private to use it"},
+	"webhook_invalid_event":        {langZhTW: "不支援的事件篩選: %s", langEn: "Unsupported event filter: %s"},
+	"webhook_inactive":             {langZhTW: "webhook %d 已停用", langEn: "Webhook %d is inactive"},
+	"share_invalid":                {langZhTW: "分享設定錯誤: %s", langEn: "Invalid share: %s"},
+	"share_not_found":              {langZhTW: "找不到分享連結", langEn: "Share link not found"},
+	"share_gone":                   {langZhTW: "分享連結已撤銷或過期", langEn: "The share link has been revoked or has expired"},
+	"ingest_disabled":              {langZhTW: "未啟用每日下載（ingest.enabled）", langEn: "Daily ingest is not enabled (ingest.enabled)"},
+	"ingest_failed":                {langZhTW: "下載標案清單失敗: %s", langEn: "Failed to ingest the tender list: %s"},
+	"events_cursor_expired":        {langZhTW: "since_id %d 之後的部分事件已依保留期限刪除，請重新同步後從 %d 繼續", langEn: "Events after since_id %d have been pruned by retention; resync and continue from %d"},
+	"events_cursor_ahead":          {langZhTW: "since_id %d 大於目前最新的事件 id %d，資料庫可能已還原，請重新同步", langEn: "since_id %d is beyond the latest event id %d; the database may have been restored, resync"},
+	"download_in_progress":         {langZhTW: "已有下載工作 %s 執行中", langEn: "Download job %s is already running"},
+	"download_job_not_found":       {langZhTW: "找不到下載工作 %q（已結束超過一小時或伺服器已重新啟動）", langEn: "Download job %q not found (finished over an hour ago, or the server restarted)"},
+	"watchlist_import_invalid":     {langZhTW: "能力清單格式錯誤: %s", langEn: "Invalid capability profile: %s"},
+	"invalid_tags":                 {langZhTW: "標籤格式錯誤: %s", langEn: "Invalid tags: %s"},
+	"invalid_status":               {langZhTW: "不支援的狀態 %q，可用的狀態: %s", langEn: "Unknown status %q; allowed: %s"},
+	"tag_not_found":                {langZhTW: "找不到標籤 %q", langEn: "Tag %q not found"},
+	"import_invalid_record":        {langZhTW: "書籤格式錯誤: %s", langEn: "Invalid bookmark record: %s"},
+	"import_missing_title":         {langZhTW: "缺少 title", langEn: "Missing title"},
+	"import_duplicate_job_number":  {langZhTW: "案號與第 %d 筆重複", langEn: "Duplicate job_number (same as record %d)"},
+	"bulk_too_large":               {langZhTW: "批次共 %d 筆，超過上限 %d 筆，請分批送出", langEn: "Batch of %d items exceeds the limit of %d; split it up"},
+	"bulk_rejected":                {langZhTW: "%d 筆格式錯誤（atomic=true），未寫入任何資料", langEn: "%d invalid items with atomic=true; nothing was written"},
+	"bulk_duplicate_job_number":    {langZhTW: "案號與第 %d 筆重複", langEn: "Duplicate job_number (same as item %d)"},
+	"sync_invalid":                 {langZhTW: "同步資料錯誤: %s", langEn: "Invalid sync batch: %s"},
+	"sync_peer_not_found":          {langZhTW: "sync.peers 中沒有同步對象 %q", langEn: "No sync peer named %q in sync.peers"},
+	"sync_peer_failed":             {langZhTW: "與 %s 同步失敗: %s", langEn: "Sync with %s failed: %s"},
+	"sync_in_progress":             {langZhTW: "正在同步中，請稍後再試", langEn: "A sync round is already running; try again later"},
+	"api_key_required":             {langZhTW: "需要 API 金鑰（X-API-Key 標頭）", langEn: "An API key is required (X-API-Key header)"},
+	"api_key_invalid":              {langZhTW: "API 金鑰錯誤", langEn: "Invalid API key"},
+	"refresh_in_progress":          {langZhTW: "正在更新標案資料，請稍後再試", langEn: "A tender refresh is already running; try again later"},
+	"email_send_failed":            {langZhTW: "寄送郵件失敗: %s", langEn: "Failed to send the email: %s"},
+	"missing_api_url":              {langZhTW: "無 API URL", langEn: "No API URL"},
+	"filename_collision":           {langZhTW: "檔名 %s 與書籤 %s 衝突，未寫入", langEn: "File name %s collides with bookmark %s; not written"},
+
+	// 啟動畫面
+	"banner_title":                {langZhTW: "標案書籤管理系統", langEn: "Tender Bookmark Server"},
+	"banner_listening":            {langZhTW: "伺服器啟動於: %s", langEn: "Listening on: %s"},
+	"banner_read_only":            {langZhTW: "模式: 唯讀（所有修改操作將被拒絕）", langEn: "Mode: read-only (all changes will be rejected)"},
+	"banner_api_keys":             {langZhTW: "API 金鑰驗證: 已啟用（%d 組金鑰）", langEn: "API key authentication: enabled (%d keys)"},
+	"banner_endpoints":            {langZhTW: "API 端點:", langEn: "API endpoints:"},
+	"route_list_all":              {langZhTW: "取得書籤（?category= ?keyword= ?type= ?unit_name= ?min_priority= ?date_from= ?date_to= ?tag= ?status= 篩選，?sort= ?order= 排序，?page= ?page_size= 分頁）", langEn: "List bookmarks (filter with ?category= ?keyword= ?type= ?unit_name= ?min_priority= ?date_from= ?date_to= ?tag= ?status=, sort with ?sort= ?order=, paginate with ?page= ?page_size=)"},
+	"route_add":                   {langZhTW: "新增書籤", langEn: "Add a bookmark"},
+	"route_bulk_add":              {langZhTW: "批次新增書籤（陣列，最多 500 筆，?atomic=true 時有錯誤即全部不寫入）", langEn: "Bulk add bookmarks (array of up to 500; ?atomic=true rejects the batch on any error)"},
+	"route_bulk_delete":           {langZhTW: "批次刪除書籤（{\"job_numbers\": [...]}，最多 500 筆）", langEn: "Bulk delete bookmarks ({\"job_numbers\": [...]}, up to 500)"},
+	"route_status_history":        {langZhTW: "書籤的狀態變更歷程（?job_number=）", langEn: "Status change history of a bookmark (?job_number=)"},
+	"route_refresh":               {langZhTW: "重新取得詳細資料並記錄欄位異動（?job_number= 只更新一筆）", langEn: "Re-fetch tender details and record field changes (?job_number= for one bookmark)"},
+	"route_changes":               {langZhTW: "未讀的標案異動（?job_number= ?all=true 包含已讀）", langEn: "Unseen tender changes (?job_number=, ?all=true includes seen)"},
+	"route_changes_seen":          {langZhTW: "標示異動為已讀（{\"ids\": [...]}、{\"job_number\": ...} 或 {\"all\": true}）", langEn: "Mark changes as seen ({\"ids\": [...]}, {\"job_number\": ...} or {\"all\": true})"},
+	"route_update":                {langZhTW: "更新書籤（備註、優先級、標籤或狀態）", langEn: "Update a bookmark (note, priority, tags or status)"},
+	"route_delete":                {langZhTW: "刪除書籤", langEn: "Delete a bookmark"},
+	"route_job_numbers":           {langZhTW: "取得書籤列表", langEn: "List bookmarked job numbers"},
+	"route_download":              {langZhTW: "下載標書（同步，NDJSON 逐筆輸出）", langEn: "Download tender details (synchronous, streamed as NDJSON)"},
+	"route_download_start":        {langZhTW: "在背景下載標書，回傳 job_id", langEn: "Start a background download and return its job_id"},
+	"route_download_status":       {langZhTW: "下載工作的進度與逐筆結果（?job_id=）", langEn: "Progress and per-item results of a download job (?job_id=)"},
+	"route_download_cancel":       {langZhTW: "取消下載工作（?job_id=）", langEn: "Cancel a download job (?job_id=)"},
+	"route_download_archive":      {langZhTW: "已下載的標書打包成 ZIP（篩選參數同書籤列表）", langEn: "ZIP of downloaded tender details (same filters as the list)"},
+	"route_download_file":         {langZhTW: "單一書籤已下載的標書（?job_number=）", langEn: "Downloaded tender detail of one bookmark (?job_number=)"},
+	"route_export":                {langZhTW: "匯出書籤（?format=json|csv|xlsx，篩選參數同 GET /api/bookmarks）", langEn: "Export bookmarks (?format=json|csv|xlsx; same filters as GET /api/bookmarks)"},
+	"route_tags":                  {langZhTW: "列出標籤與使用的書籤數", langEn: "List tags with bookmark counts"},
+	"route_tag_delete":            {langZhTW: "從所有書籤移除標籤（?name=）", langEn: "Remove a tag from all bookmarks (?name=)"},
+	"route_import":                {langZhTW: "匯入匯出的書籤 JSON（?mode=skip|overwrite|merge，可用 multipart 的 file 欄位上傳）", langEn: "Import exported bookmark JSON (?mode=skip|overwrite|merge; raw body or multipart file field)"},
+	"route_version":               {langZhTW: "版本與模式", langEn: "Version and mode"},
+	"route_health":                {langZhTW: "健康檢查（確認資料庫連線）", langEn: "Health check (pings the database)"},
+	"route_files":                 {langZhTW: "列出資料檔案", langEn: "List data files"},
+	"route_events":                {langZhTW: "增量讀取異動事件（?since_id=&type=&job_number=）", langEn: "Incremental change events (?since_id=&type=&job_number=)"},
+	"route_events_stream":         {langZhTW: "以 SSE 即時推送書籤與下載事件", langEn: "Stream bookmark and download events (SSE)"},
+	"route_websocket":             {langZhTW: "WebSocket：推送事件並接受 check/toggle/update 指令", langEn: "WebSocket: event push plus check/toggle/update commands"},
+	"route_notifications":         {langZhTW: "通知送出紀錄（可依 provider、status、trigger、job_number 篩選）", langEn: "Notification delivery log (filter by provider, status, trigger, job_number)"},
+	"route_notification_log":      {langZhTW: "通知送出紀錄", langEn: "Notification delivery log"},
+	"route_notification_test":     {langZhTW: "送出測試通知（?provider= 指定提供者）", langEn: "Send a test notification (?provider= to pick one)"},
+	"route_audit":                 {langZhTW: "查詢稽核紀錄", langEn: "Query the audit log"},
+	"route_metrics":               {langZhTW: "執行期統計", langEn: "Runtime metrics"},
+	"route_integrity":             {langZhTW: "資料庫完整性檢查", langEn: "Database integrity check"},
+	"route_maintenance":           {langZhTW: "資料庫維護（ANALYZE/VACUUM）", langEn: "Database maintenance (ANALYZE/VACUUM)"},
+	"route_invalid_dates":         {langZhTW: "列出日期欄位不是有效日期的書籤", langEn: "List bookmarks with invalid dates"},
+	"route_data_drift":            {langZhTW: "列出 data 與書籤欄位不一致的書籤", langEn: "List bookmarks whose data blob disagrees with their columns"},
+	"route_invalid_data":          {langZhTW: "列出 data 欄位損毀的書籤", langEn: "List bookmarks with invalid data JSON"},
+	"route_job_number_collisions": {langZhTW: "列出標準化後會衝突的案號", langEn: "List bookmarks whose job numbers collide after canonicalization"},
+	"route_invalid_tenders":       {langZhTW: "列出內容為錯誤訊息的標書檔", langEn: "List downloaded tender files that contain error payloads"},
+	"route_bookmark_detail":       {langZhTW: "書籤的標案詳細資料（來源依 pcc_g0v.detail）", langEn: "Tender detail for a bookmark (source per pcc_g0v.detail)"},
+	"route_awards":                {langZhTW: "已決標的書籤與得標廠商", langEn: "Awarded bookmarks and winning vendors"},
+	"route_reconcile":             {langZhTW: "依標案詳細資料更新書籤（?dry_run=true 只列出差異）", langEn: "Update a bookmark from its tender detail (?dry_run=true to preview)"},
+	"route_stats":                 {langZhTW: "書籤統計（依機關、類型、月份、優先級與近期新增，篩選參數同書籤列表）", langEn: "Bookmark statistics by unit, type, month, priority and recent additions (same filters as the list)"},
+	"route_attachments":           {langZhTW: "列出或下載書籤附件", langEn: "List or download bookmark attachments"},
+	"route_bookmark_attachments":  {langZhTW: "列出、上傳（multipart 欄位 job_number、file）或刪除（?id=）書籤附件", langEn: "List, upload (multipart fields job_number, file) or delete (?id=) bookmark attachments"},
+	"route_attachment_download":   {langZhTW: "下載書籤附件（?id=）", langEn: "Download a bookmark attachment (?id=)"},
+	"route_trash":                 {langZhTW: "列出垃圾桶中的書籤", langEn: "List bookmarks in the trash"},
+	"route_restore":               {langZhTW: "從垃圾桶還原書籤（?job_number=）", langEn: "Restore a bookmark from the trash (?job_number=)"},
+	"route_purge":                 {langZhTW: "永久刪除移到垃圾桶超過 ?days= 天的書籤（預設依 trash_retention，0 清空）", langEn: "Permanently delete bookmarks trashed more than ?days= ago (default trash_retention, 0 empties the trash)"},
+	"route_dump":                  {langZhTW: "下載 SQL 傾印（結構與資料）", langEn: "Download SQL dump (schema and data)"},
+	"route_years":                 {langZhTW: "建立年度資料庫", langEn: "Create a year database"},
+	"route_prune":                 {langZhTW: "清理已刪除書籤的下載檔案與資料", langEn: "Prune files and rows of deleted bookmarks"},
+	"route_archive_year":          {langZhTW: "封存舊年度的書籤", langEn: "Archive bookmarks older than a cutoff"},
+	"route_tender_cache":          {langZhTW: "標案快取統計與清除", langEn: "Tender cache stats and purge"},
+	"route_backups":               {langZhTW: "資料庫備份列表與下載", langEn: "List and download database backups"},
+	"route_webhooks":              {langZhTW: "列出或建立 webhook 訂閱（/api/webhooks/{id}/deliveries 為送出紀錄）", langEn: "List or create webhook subscriptions (/api/webhooks/{id}/deliveries for the delivery log)"},
+	"route_ingest_status":         {langZhTW: "每日標案清單下載的執行紀錄", langEn: "Daily tender list ingest status"},
+	"route_ingest_run":            {langZhTW: "立即下載並篩選某天的標案清單（?date=）", langEn: "Ingest the tender list for a day now (?date=)"},
+	"route_watchlist":             {langZhTW: "列出匯入的能力清單與關注清單", langEn: "List imported capabilities and the effective watchlist"},
+	"route_watchlist_import":      {langZhTW: "匯入能力清單（JSON 或 CSV，?dry_run=true 只回傳差異）", langEn: "Import a capability profile (JSON or CSV; ?dry_run=true only reports the diff)"},
+	"route_sync":                  {langZhTW: "本機的 instance_id 與各同步對象的游標", langEn: "Show this instance's ID and the cursor for each sync peer"},
+	"route_sync_pull":             {langZhTW: "取得 ?since= 之後的書籤異動（含刪除）", langEn: "Fetch bookmark upserts and deletions after ?since="},
+	"route_sync_push":             {langZhTW: "套用另一台伺服器的書籤異動（較新者勝出）", langEn: "Apply bookmark changes from another instance (last writer wins)"},
+	"route_sync_run":              {langZhTW: "與 sync.peers 中的對象雙向同步一輪", langEn: "Run one bidirectional sync round with a configured peer"},
+	"route_email_digest":          {langZhTW: "立即寄出每日摘要（GET 列出寄送紀錄）", langEn: "Send the email digest now (GET lists sent digests)"},
+	"route_weekly_reports":        {langZhTW: "列出每週報告（檔案見 weekly_report.url_prefix）", langEn: "List weekly reports (files under weekly_report.url_prefix)"},
+	"route_weekly_report_run":     {langZhTW: "立即產生最近 7 天的每週報告", langEn: "Generate a weekly report for the last 7 days now"},
+	"route_calendar_export":       {langZhTW: "下載截止投標行事曆（.ics）", langEn: "Download the bid deadline calendar (.ics)"},
+	"route_tenders":               {langZhTW: "查詢篩選結果的標案（?date_from= ?date_to= ?category= ?keyword= ?unit_name= 篩選，?page= ?page_size= 分頁）", langEn: "Query filtered tenders (filter with ?date_from= ?date_to= ?category= ?keyword= ?unit_name=, paginate with ?page= ?page_size=)"},
+	"route_tender_stats":          {langZhTW: "篩選結果每天與每個類別的標案數", langEn: "Filtered tender counts per day and per category"},
+	"route_lookup":                {langZhTW: "以 PCC 網頁網址查詢書籤（?url=，供瀏覽器擴充功能使用）", langEn: "Look up a bookmark by PCC page URL (?url=, for browser extensions)"},
+	"route_calendar_feed":         {langZhTW: "訂閱截止投標行事曆（token 見 calendar.tokens）", langEn: "Subscribe to the bid deadline calendar (token from calendar.tokens)"},
+	"route_search":                {langZhTW: "全文檢索標題、機關、備註與標案資料（?q= 以空白分隔的詞須全部符合）", langEn: "Full-text search over titles, agencies, notes and tender data (?q= space-separated terms, all must match)"},
+	"route_bookmark_feed":         {langZhTW: "JSON Feed：新增的書籤與標案異動（?min_priority=&limit=）", langEn: "JSON Feed of new bookmarks and tender changes (?min_priority=&limit=)"},
+	"route_shares":                {langZhTW: "列出或建立唯讀分享連結（DELETE /api/shares/{id} 撤銷，/api/shares/{token}.json 為分享的資料）", langEn: "List or create read-only share links (DELETE /api/shares/{id} revokes; /api/shares/{token}.json serves the shared data)"},
+	"route_share_page":            {langZhTW: "唯讀分享頁面（不需其他驗證，只包含分享範圍內的書籤）", langEn: "Read-only share page (no other authentication; only the shared bookmarks)"},
+
+	// 行事曆
+	"calendar_name":       {langZhTW: "標案截止投標", langEn: "Tender bid deadlines"},
+	"calendar_summary":    {langZhTW: "截止投標：%s", langEn: "Bid deadline: %s"},
+	"calendar_job_number": {langZhTW: "案號：%s", langEn: "Job number: %s"},
+	"calendar_unit":       {langZhTW: "機關：%s", langEn: "Agency: %s"},
+	"calendar_priority":   {langZhTW: "優先級：%d", langEn: "Priority: %d"},
+}
+
+// 依語系取得訊息，找不到語系時退回預設語系，找不到代碼時回傳代碼本身
+func localize(lang, code string, args ...interface{}) string {
+	texts, ok := messages[code]
+	if !ok {
+		return code
+	}
+	text, ok := texts[lang]
+	if !ok {
+		text = texts[langZhTW]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// 將語系標籤對應到支援的語系，不支援時回傳空字串
+func matchLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case strings.HasPrefix(tag, "zh"):
+		return langZhTW
+	case strings.HasPrefix(tag, "en"):
+		return langEn
+	}
+	return ""
+}
+
+// 由 Accept-Language 選擇語系，沒有可用語系時使用設定的預設語系
+func requestLanguage(r *http.Request) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang := matchLanguage(tag)
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		if lang, ok := r.Context().Value(defaultLanguageKey{}).(string); ok {
+			return lang
+		}
+		return langZhTW
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// 請求 context 中預設語系的鍵
+type defaultLanguageKey struct{}
+
+// 將設定的預設語系放入請求 context，供 requestLanguage 在沒有 Accept-Language 時使用
+func withDefaultLanguage(lang string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), defaultLanguageKey{}, lang)))
+	})
+}
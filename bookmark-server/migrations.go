@@ -0,0 +1,640 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// 資料庫遷移，依版本號順序執行，每個遷移只會執行一次
+type migration struct {
+	version int
+	name    string
+	sql     string
+
+	// PostgreSQL 語法差異太大、無法以 ddl() 轉換時另外提供
+	postgresSQL string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "bookmarks.updated_at",
+		// ADD COLUMN 不能使用 CURRENT_TIMESTAMP 預設值，由寫入路徑明確設定
+		sql: `
+			ALTER TABLE bookmarks ADD COLUMN updated_at DATETIME;
+			UPDATE bookmarks SET updated_at = created_at;
+		`,
+	},
+	{
+		version: 2,
+		name:    "tender_cache",
+		// PCC API 回應快取，job_number 不一定是書籤（查詢、自動填入也會使用）
+		sql: `
+			CREATE TABLE tender_cache (
+				job_number TEXT PRIMARY KEY,
+				api_url TEXT NOT NULL,
+				body TEXT NOT NULL,
+				etag TEXT,
+				fetched_at DATETIME NOT NULL
+			);
+			CREATE INDEX idx_tender_cache_fetched_at ON tender_cache(fetched_at);
+		`,
+	},
+	{
+		version: 3,
+		name:    "events",
+		sql: `
+			CREATE TABLE events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				entity_type TEXT NOT NULL,
+				entity_key TEXT NOT NULL,
+				action TEXT NOT NULL,
+				payload TEXT
+			);
+			CREATE INDEX idx_events_entity ON events(entity_type, entity_key);
+			CREATE INDEX idx_events_created_at ON events(created_at);
+		`,
+	},
+	{
+		version: 4,
+		name:    "bookmarks filter indexes",
+		// job_number 的 UNIQUE 限制已有索引，idx_job_number 多餘；
+		// idx_priority 是預設排序複合索引的前綴，一併移除
+		// status、deadline 欄位的索引隨新增欄位的遷移一起建立
+		sql: `
+			DROP INDEX IF EXISTS idx_job_number;
+			DROP INDEX IF EXISTS idx_priority;
+			CREATE INDEX idx_bookmarks_type ON bookmarks(type);
+			CREATE INDEX idx_bookmarks_unit_name ON bookmarks(unit_name);
+			CREATE INDEX idx_bookmarks_date ON bookmarks(date);
+			CREATE INDEX idx_bookmarks_priority_created ON bookmarks(priority DESC, created_at DESC);
+		`,
+	},
+	{
+		version: 5,
+		name:    "bookmarks.data json check",
+		// SQLite 無法對既有資料表加上 CHECK，改以觸發器限制新的寫入；
+		// 既有的錯誤資料保留，由 /api/admin/invalid-data 列出後重新下載
+		sql: `
+			CREATE TRIGGER bookmarks_data_json_insert BEFORE INSERT ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+			CREATE TRIGGER bookmarks_data_json_update BEFORE UPDATE OF data ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+		`,
+		// NOT VALID 只檢查新的寫入；IS JSON 需要 PostgreSQL 16 以上
+		postgresSQL: `
+			ALTER TABLE bookmarks ADD CONSTRAINT bookmarks_data_json
+			CHECK (data IS NULL OR data = '' OR data IS JSON) NOT VALID;
+		`,
+	},
+	{
+		version: 6,
+		name:    "bookmarks.date roc to gregorian",
+		// date 一律為西元 YYYYMMDD；將舊資料中的民國年（YYMMDD、YYYMMDD）轉成西元，NULL 改為 0
+		sql: `
+			UPDATE bookmarks SET date = date + 19110000 WHERE date BETWEEN 10101 AND 9991231;
+			UPDATE bookmarks SET date = 0 WHERE date IS NULL;
+		`,
+	},
+	{
+		version: 7,
+		name:    "app_meta and encrypted data",
+		// app_meta 存放金鑰檢查值等伺服器設定；加密後的 data 不是 JSON，JSON 檢查需略過
+		sql: `
+			CREATE TABLE app_meta (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			);
+			DROP TRIGGER bookmarks_data_json_insert;
+			DROP TRIGGER bookmarks_data_json_update;
+			CREATE TRIGGER bookmarks_data_json_insert BEFORE INSERT ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NEW.data NOT LIKE 'enc:v1:%' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+			CREATE TRIGGER bookmarks_data_json_update BEFORE UPDATE OF data ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NEW.data NOT LIKE 'enc:v1:%' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+		`,
+		postgresSQL: `
+			CREATE TABLE app_meta (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			);
+			ALTER TABLE bookmarks DROP CONSTRAINT bookmarks_data_json;
+			ALTER TABLE bookmarks ADD CONSTRAINT bookmarks_data_json
+			CHECK (data IS NULL OR data = '' OR data LIKE 'enc:v1:%' OR data IS JSON) NOT VALID;
+		`,
+	},
+	{
+		version: 8,
+		name:    "bookmarks.version",
+		// 樂觀鎖版本號，每次更新遞增
+		sql: `
+			ALTER TABLE bookmarks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+		`,
+	},
+	{
+		version: 9,
+		name:    "bookmarks_archive",
+		// 年底封存的書籤，欄位與 bookmarks 相同
+		sql: `
+			CREATE TABLE bookmarks_archive (
+				id INTEGER PRIMARY KEY,
+				job_number TEXT UNIQUE NOT NULL,
+				title TEXT NOT NULL,
+				unit_name TEXT,
+				url TEXT,
+				api_url TEXT,
+				type TEXT,
+				date INTEGER,
+				note TEXT DEFAULT '',
+				priority INTEGER DEFAULT 0,
+				data TEXT,
+				created_at DATETIME,
+				updated_at DATETIME,
+				version INTEGER NOT NULL DEFAULT 1,
+				archived_at DATETIME NOT NULL
+			);
+		`,
+	},
+	{
+		version: 10,
+		name:    "bookmark_categories and bookmark_keywords",
+		// 由 data 中的 matched_categories、matched_keywords 補齊既有書籤；
+		// 加密的 data 無法在 SQL 中解析，啟動時另由 backfillEncryptedTerms 處理
+		sql: `
+			CREATE TABLE bookmark_categories (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				category TEXT NOT NULL,
+				UNIQUE (job_number, category)
+			);
+			CREATE INDEX idx_bookmark_categories_category ON bookmark_categories(category, job_number);
+			CREATE TABLE bookmark_keywords (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				keyword TEXT NOT NULL,
+				UNIQUE (job_number, keyword)
+			);
+			CREATE INDEX idx_bookmark_keywords_keyword ON bookmark_keywords(keyword, job_number);
+			INSERT OR IGNORE INTO bookmark_categories (job_number, category)
+			SELECT b.job_number, TRIM(j.value) FROM bookmarks b, json_each(b.data, '$.matched_categories') j
+			WHERE b.data <> '' AND b.data NOT LIKE 'enc:v1:%' AND json_valid(b.data)
+				AND json_type(b.data, '$.matched_categories') = 'array' AND j.type = 'text' AND TRIM(j.value) <> '';
+			INSERT OR IGNORE INTO bookmark_keywords (job_number, keyword)
+			SELECT b.job_number, TRIM(j.value) FROM bookmarks b, json_each(b.data, '$.matched_keywords') j
+			WHERE b.data <> '' AND b.data NOT LIKE 'enc:v1:%' AND json_valid(b.data)
+				AND json_type(b.data, '$.matched_keywords') = 'array' AND j.type = 'text' AND TRIM(j.value) <> '';
+		`,
+		postgresSQL: `
+			CREATE TABLE bookmark_categories (
+				id BIGSERIAL PRIMARY KEY,
+				job_number TEXT NOT NULL,
+				category TEXT NOT NULL,
+				UNIQUE (job_number, category)
+			);
+			CREATE INDEX idx_bookmark_categories_category ON bookmark_categories(category, job_number);
+			CREATE TABLE bookmark_keywords (
+				id BIGSERIAL PRIMARY KEY,
+				job_number TEXT NOT NULL,
+				keyword TEXT NOT NULL,
+				UNIQUE (job_number, keyword)
+			);
+			CREATE INDEX idx_bookmark_keywords_keyword ON bookmark_keywords(keyword, job_number);
+			INSERT INTO bookmark_categories (job_number, category)
+			SELECT b.job_number, TRIM(c) FROM bookmarks b,
+				jsonb_array_elements_text(CASE WHEN jsonb_typeof(b.data::jsonb -> 'matched_categories') = 'array'
+					THEN b.data::jsonb -> 'matched_categories' ELSE '[]'::jsonb END) c
+			WHERE b.data IS JSON OBJECT AND TRIM(c) <> ''
+			ON CONFLICT DO NOTHING;
+			INSERT INTO bookmark_keywords (job_number, keyword)
+			SELECT b.job_number, TRIM(k) FROM bookmarks b,
+				jsonb_array_elements_text(CASE WHEN jsonb_typeof(b.data::jsonb -> 'matched_keywords') = 'array'
+					THEN b.data::jsonb -> 'matched_keywords' ELSE '[]'::jsonb END) k
+			WHERE b.data IS JSON OBJECT AND TRIM(k) <> ''
+			ON CONFLICT DO NOTHING;
+		`,
+	}, {
+		version: 11,
+		name:    "utc rfc3339 timestamps",
+		// 將 CURRENT_TIMESTAMP（"2006-01-02 15:04:05"）與 time.Time（"...+00:00"）兩種舊格式
+		// 統一改寫為固定寬度的 UTC RFC 3339 文字，字串比較才會與時間先後一致；
+		// 無法解析的值保留原樣。PostgreSQL 的 TIMESTAMPTZ 本身帶時區，不需要改寫
+		sql: `
+			UPDATE bookmarks SET created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at) WHERE created_at IS NOT NULL AND created_at NOT LIKE '%Z';
+			UPDATE bookmarks SET updated_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', updated_at), updated_at) WHERE updated_at IS NOT NULL AND updated_at NOT LIKE '%Z';
+			UPDATE bookmarks_archive SET created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at) WHERE created_at IS NOT NULL AND created_at NOT LIKE '%Z';
+			UPDATE bookmarks_archive SET updated_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', updated_at), updated_at) WHERE updated_at IS NOT NULL AND updated_at NOT LIKE '%Z';
+			UPDATE bookmarks_archive SET archived_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', archived_at), archived_at) WHERE archived_at IS NOT NULL AND archived_at NOT LIKE '%Z';
+			UPDATE audit_log SET created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at) WHERE created_at IS NOT NULL AND created_at NOT LIKE '%Z';
+			UPDATE events SET created_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', created_at), created_at) WHERE created_at IS NOT NULL AND created_at NOT LIKE '%Z';
+			UPDATE tender_cache SET fetched_at = COALESCE(strftime('%Y-%m-%dT%H:%M:%fZ', fetched_at), fetched_at) WHERE fetched_at IS NOT NULL AND fetched_at NOT LIKE '%Z';
+		`,
+		postgresSQL: `SELECT 1;`,
+	},
+	{
+		version: 12,
+		name:    "attachments",
+		// 附件內容依 SHA-256 存於附件目錄（見 attachments.go），資料列記錄相對路徑；
+		// 刪除書籤時資料列一併刪除，job_number 變更時跟著更新
+		sql: `
+			CREATE TABLE attachments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL REFERENCES bookmarks(job_number) ON DELETE CASCADE ON UPDATE CASCADE,
+				kind TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				content_type TEXT NOT NULL DEFAULT '',
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				stored_path TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				UNIQUE (job_number, kind, filename)
+			);
+			CREATE INDEX idx_attachments_sha256 ON attachments(sha256);
+		`,
+	}, {
+		version: 13,
+		name:    "stats",
+		// 書籤統計，內容由 refreshStats 整批重新計算
+		sql: `
+			CREATE TABLE stats (
+				dimension TEXT NOT NULL,
+				value TEXT NOT NULL,
+				count INTEGER NOT NULL,
+				PRIMARY KEY (dimension, value)
+			);
+		`,
+	}, {
+		version: 14,
+		name:    "backfill null bookmark columns",
+		// 舊版用戶端寫入的 NULL 改為空字串與 0，之後可再為這些欄位加上 NOT NULL 預設值
+		sql: `
+			UPDATE bookmarks SET unit_name = COALESCE(unit_name, ''), url = COALESCE(url, ''), api_url = COALESCE(api_url, ''),
+				type = COALESCE(type, ''), note = COALESCE(note, ''), priority = COALESCE(priority, 0)
+			WHERE unit_name IS NULL OR url IS NULL OR api_url IS NULL OR type IS NULL OR note IS NULL OR priority IS NULL;
+			UPDATE bookmarks_archive SET unit_name = COALESCE(unit_name, ''), url = COALESCE(url, ''), api_url = COALESCE(api_url, ''),
+				type = COALESCE(type, ''), note = COALESCE(note, ''), priority = COALESCE(priority, 0)
+			WHERE unit_name IS NULL OR url IS NULL OR api_url IS NULL OR type IS NULL OR note IS NULL OR priority IS NULL;
+		`,
+	}, {
+		version: 15,
+		name:    "notification log",
+		// 每次送出通知（含失敗與因頻率限制略過）一筆；dedup_key 用來避免重複通知同一個截止時間
+		sql: `
+			CREATE TABLE notification_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				provider TEXT NOT NULL,
+				trigger_name TEXT NOT NULL,
+				job_number TEXT NOT NULL DEFAULT '',
+				dedup_key TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				http_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				message TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX idx_notification_log_dedup ON notification_log(trigger_name, job_number, dedup_key);
+		`,
+	}, {
+		version: 16,
+		name:    "email digests",
+		// 每日摘要的寄送紀錄；last_event_id 為該次涵蓋到的事件，下次摘要從其後開始
+		sql: `
+			CREATE TABLE email_digests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				digest_date TEXT NOT NULL,
+				manual INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				recipients TEXT NOT NULL DEFAULT '',
+				new_count INTEGER NOT NULL DEFAULT 0,
+				deadline_count INTEGER NOT NULL DEFAULT 0,
+				changed_count INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				last_event_id INTEGER NOT NULL DEFAULT 0
+			);
+			CREATE INDEX idx_email_digests_date ON email_digests(digest_date);
+		`,
+	}, {
+		version: 17,
+		name:    "webhooks",
+		// 訂閱記錄已處理到的事件 id；送出紀錄的 payload 為送出的內容（加密），重新送出時沿用
+		sql: `
+			CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				events TEXT NOT NULL DEFAULT '',
+				allow_private INTEGER NOT NULL DEFAULT 0,
+				active INTEGER NOT NULL DEFAULT 1,
+				last_event_id INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			);
+			CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event_id INTEGER NOT NULL,
+				event_type TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at DATETIME,
+				response_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				redelivery_of INTEGER,
+				created_at DATETIME NOT NULL,
+				delivered_at DATETIME
+			);
+			CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+			CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+		`,
+	}, {
+		version: 18,
+		name:    "ingest runs",
+		// 每日下載的執行紀錄（每天一筆）；ingest_bookmarks 記錄曾自動加入書籤的標案，避免刪除後又被加回來
+		sql: `
+			CREATE TABLE ingest_runs (
+				date TEXT PRIMARY KEY,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				fetched INTEGER NOT NULL DEFAULT 0,
+				matched INTEGER NOT NULL DEFAULT 0,
+				bookmarked INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				started_at DATETIME,
+				finished_at DATETIME,
+				next_attempt_at DATETIME
+			);
+			CREATE TABLE ingest_bookmarks (
+				job_number TEXT PRIMARY KEY,
+				created_at DATETIME NOT NULL
+			);
+		`,
+	}, {
+		version: 19,
+		name:    "tender awards",
+		// 書籤標案的決標公告（見 pccg0v.go），每個標案只記錄一次；書籤刪除後保留，重新加入時不會再次通知
+		sql: `
+			CREATE TABLE tender_awards (
+				job_number TEXT PRIMARY KEY,
+				date INTEGER NOT NULL DEFAULT 0,
+				companies TEXT NOT NULL DEFAULT '[]',
+				amount TEXT NOT NULL DEFAULT '',
+				source TEXT NOT NULL,
+				detected_at DATETIME NOT NULL
+			);
+		`,
+	}, {
+		version: 20,
+		name:    "calendar events",
+		// 行事曆中各書籤目前的截止時間與 SEQUENCE（見 calendar.go）；書籤刪除後保留，重新加入時 SEQUENCE 接續
+		sql: `
+			CREATE TABLE calendar_events (
+				job_number TEXT PRIMARY KEY,
+				deadline DATETIME NOT NULL,
+				sequence INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME NOT NULL
+			);
+		`,
+	}, {
+		version: 21,
+		name:    "shares",
+		// 唯讀分享連結（見 shares.go）；只存 token 的 SHA-256，撤銷後保留紀錄
+		sql: `
+			CREATE TABLE shares (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				token_hash TEXT NOT NULL UNIQUE,
+				title TEXT NOT NULL DEFAULT '',
+				job_numbers TEXT NOT NULL DEFAULT '[]',
+				search TEXT NOT NULL DEFAULT '',
+				include_notes INTEGER NOT NULL DEFAULT 0,
+				views INTEGER NOT NULL DEFAULT 0,
+				expires_at DATETIME,
+				revoked_at DATETIME,
+				created_at DATETIME NOT NULL
+			);
+		`,
+	}, {
+		version: 22,
+		name:    "weekly reports",
+		// 每週報告的產生紀錄（見 weeklyreport.go），檔案本身存於 weekly_report.dir
+		sql: `
+			CREATE TABLE weekly_reports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				report_date TEXT NOT NULL,
+				period_start DATETIME NOT NULL,
+				period_end DATETIME NOT NULL,
+				manual INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				items INTEGER NOT NULL DEFAULT 0,
+				agencies INTEGER NOT NULL DEFAULT 0,
+				markdown_file TEXT NOT NULL,
+				html_file TEXT NOT NULL,
+				webhook_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX idx_weekly_reports_period_end ON weekly_reports(period_end);
+		`,
+	}, {
+		version: 23,
+		name:    "watchlist",
+		// 匯入的能力清單（見 watchlist.go），每項是每日下載的一個類別並在關注清單中
+		sql: `
+			CREATE TABLE watchlist (
+				name TEXT PRIMARY KEY,
+				keywords TEXT NOT NULL DEFAULT '[]',
+				negative_keywords TEXT NOT NULL DEFAULT '[]',
+				priority INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			);
+		`,
+	}, {
+		version: 24,
+		name:    "sync_state",
+		// 與各同步對象的游標（見 sync.go）；pulled_through 為對方的事件 id，pushed_through 為本機的事件 id，NULL 表示下一次以快照同步
+		sql: `
+			CREATE TABLE sync_state (
+				peer TEXT PRIMARY KEY,
+				peer_instance TEXT NOT NULL DEFAULT '',
+				pulled_through INTEGER,
+				pushed_through INTEGER,
+				last_synced_at DATETIME
+			);
+		`,
+	}, {
+		version: 25,
+		name:    "bookmarks_fts",
+		// 全文檢索（見 search.go），rowid 為 bookmarks.id，由觸發程序維護；trigram 斷詞才能搜尋沒有空白分隔的中文。
+		// data 只索引 JSON 中的值（不含鍵名）；加密的 note、data 與無效的 JSON 以空字串索引，改由伺服器逐筆比對。
+		// PostgreSQL 不建立，搜尋時逐筆比對
+		sql: `
+			CREATE VIRTUAL TABLE bookmarks_fts USING fts5(title, unit_name, note, data, tokenize = 'trigram');
+			CREATE TRIGGER bookmarks_fts_insert AFTER INSERT ON bookmarks BEGIN
+				INSERT INTO bookmarks_fts (rowid, title, unit_name, note, data) VALUES (
+					NEW.id, COALESCE(NEW.title, ''), COALESCE(NEW.unit_name, ''),
+					CASE WHEN NEW.note LIKE 'enc:v1:%' THEN '' ELSE COALESCE(NEW.note, '') END,
+					CASE WHEN json_valid(NEW.data) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_tree(NEW.data) WHERE atom IS NOT NULL) ELSE '' END);
+			END;
+			CREATE TRIGGER bookmarks_fts_delete AFTER DELETE ON bookmarks BEGIN
+				DELETE FROM bookmarks_fts WHERE rowid = OLD.id;
+			END;
+			CREATE TRIGGER bookmarks_fts_update AFTER UPDATE ON bookmarks BEGIN
+				DELETE FROM bookmarks_fts WHERE rowid = OLD.id;
+				INSERT INTO bookmarks_fts (rowid, title, unit_name, note, data) VALUES (
+					NEW.id, COALESCE(NEW.title, ''), COALESCE(NEW.unit_name, ''),
+					CASE WHEN NEW.note LIKE 'enc:v1:%' THEN '' ELSE COALESCE(NEW.note, '') END,
+					CASE WHEN json_valid(NEW.data) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_tree(NEW.data) WHERE atom IS NOT NULL) ELSE '' END);
+			END;
+		` + bookmarksFTSPopulate + ";",
+		postgresSQL: `SELECT 1;`,
+	}, {
+		version: 26,
+		name:    "tags and bookmark_tags",
+		// 使用者指定的標籤（見 tags.go）；name_key 為小寫的名稱，不分大小寫視為同一個標籤
+		sql: `
+			CREATE TABLE tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				name_key TEXT NOT NULL UNIQUE
+			);
+			CREATE TABLE bookmark_tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				tag_id INTEGER NOT NULL,
+				UNIQUE (job_number, tag_id)
+			);
+			CREATE INDEX idx_bookmark_tags_tag ON bookmark_tags(tag_id, job_number);
+		`,
+	}, {
+		version: 27,
+		name:    "bookmark status and status history",
+		// 標案進度（見 status.go）；封存表與 bookmarks 欄位相同，一起新增
+		sql: `
+			ALTER TABLE bookmarks ADD COLUMN status TEXT NOT NULL DEFAULT 'watching';
+			ALTER TABLE bookmarks_archive ADD COLUMN status TEXT NOT NULL DEFAULT 'watching';
+			CREATE INDEX idx_bookmarks_status ON bookmarks(status);
+			CREATE TABLE bookmark_status_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				old_status TEXT NOT NULL,
+				new_status TEXT NOT NULL,
+				comment TEXT NOT NULL DEFAULT '',
+				actor TEXT NOT NULL DEFAULT '',
+				changed_at DATETIME NOT NULL
+			);
+			CREATE INDEX idx_bookmark_status_history_job ON bookmark_status_history(job_number, id);
+		`,
+	}, {
+		version: 28,
+		name:    "bookmark tender changes",
+		// 重新取得詳細資料時偵測到的欄位異動（見 tenderchanges.go）
+		sql: `
+			CREATE TABLE bookmark_changes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				field TEXT NOT NULL,
+				old_value TEXT NOT NULL DEFAULT '',
+				new_value TEXT NOT NULL DEFAULT '',
+				detected_at DATETIME NOT NULL,
+				seen INTEGER NOT NULL DEFAULT 0,
+				seen_at DATETIME
+			);
+			CREATE INDEX idx_bookmark_changes_seen ON bookmark_changes(seen, id);
+			CREATE INDEX idx_bookmark_changes_job ON bookmark_changes(job_number, id);
+		`,
+	}, {
+		version: 29,
+		name:    "bookmarks version triggers",
+		// 書籤清單的版本與最後異動時間，由觸發程序在同一筆交易中更新（見 dataversion.go）
+		sql:         bookmarksVersionSQL(),
+		postgresSQL: bookmarksVersionPostgresSQL(),
+	}, {
+		version: 30,
+		name:    "bookmarks trash",
+		// 刪除的書籤只記錄刪除時間，移到垃圾桶（見 trash.go）；不在 bookmarkColumns 中，封存表不需要此欄位
+		sql: `
+			ALTER TABLE bookmarks ADD COLUMN deleted_at DATETIME;
+			CREATE INDEX idx_bookmarks_deleted_at ON bookmarks(deleted_at);
+		`,
+	}, {
+		version: 31,
+		name:    "attachments archive",
+		// 封存書籤的附件資料列（見 archive.go）；attachments 以外鍵隨書籤刪除，封存時先移到此表，
+		// 檔案留在附件目錄，清理與完整性檢查視為有參照
+		sql: `
+			CREATE TABLE attachments_archive (
+				id INTEGER PRIMARY KEY,
+				job_number TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				content_type TEXT NOT NULL DEFAULT '',
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				stored_path TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				archived_at DATETIME NOT NULL
+			);
+			CREATE INDEX idx_attachments_archive_job ON attachments_archive(job_number);
+		`,
+	}, {
+		version: 32,
+		name:    "bookmarks trash partial index",
+		// 遷移 30 的 deleted_at 索引會被所有清單查詢的 deleted_at IS NULL 選用，
+		// 日期、優先順序等篩選與預設排序因而改用暫存排序；改為只含垃圾桶中書籤的部分索引。
+		// 預設排序最後以 id 決定同值的順序，複合索引一併加上 id
+		sql: `
+			DROP INDEX IF EXISTS idx_bookmarks_deleted_at;
+			CREATE INDEX idx_bookmarks_trashed ON bookmarks(deleted_at) WHERE deleted_at IS NOT NULL;
+			DROP INDEX IF EXISTS idx_bookmarks_priority_created;
+			CREATE INDEX idx_bookmarks_priority_created ON bookmarks(priority DESC, created_at DESC, id DESC);
+		`,
+	},
+}
+
+// 執行尚未套用的遷移
+func runMigrations(db *DB) error {
+	_, err := db.ExecContext(context.Background(), db.dialect.ddl(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`))
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRowContext(context.Background(), "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := db.withTx(context.Background(), func(tx *Tx) error {
+			query := db.dialect.ddl(m.sql)
+			if db.dialect == dialectPostgres && m.postgresSQL != "" {
+				query = m.postgresSQL
+			}
+			if _, err := tx.Exec(query); err != nil {
+				return fmt.Errorf("遷移 %d（%s）失敗: %w", m.version, m.name, err)
+			}
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("已套用資料庫遷移 %d: %s", m.version, m.name)
+	}
+	return nil
+}
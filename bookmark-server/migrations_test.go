@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bookmark-server/store"
+)
+
+// 書籤清單的各種篩選與排序都使用索引；want 為預期的索引，空字串表示任一索引皆可
+func TestBookmarkListQueryPlans(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "idx_bookmarks_priority_created"},
+		{"type=決標公告", "idx_bookmarks_type"},
+		{"status=watching", "idx_bookmarks_status"},
+		{"status=watching&status=submitted", "idx_bookmarks_status"},
+		{"date_from=2026-01-01&date_to=2026-01-31", "idx_bookmarks_date"},
+		{"date_from=2026-01-01", ""},
+		{"min_priority=3", "idx_bookmarks_priority_created"},
+		{"sort=date", "idx_bookmarks_date"},
+		// 機關名稱是部分比對（LIKE '%...%'），無法以索引篩選，但排序仍使用索引
+		{"unit_name=臺北", ""},
+	}
+	s := newTestServer(t)
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		where, args, bad := bookmarkFilter(q)
+		orderBy, badOrder := bookmarkOrder(q)
+		if bad != "" || badOrder != "" {
+			t.Fatalf("%s: invalid parameter %s%s", tt.query, bad, badOrder)
+		}
+		rows, err := s.db.Query("EXPLAIN QUERY PLAN SELECT "+store.Columns+" FROM bookmarks "+where+" ORDER BY "+orderBy, args...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		var plan []string
+		for rows.Next() {
+			var id, parent, notUsed int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+				t.Fatal(err)
+			}
+			plan = append(plan, detail)
+		}
+		rows.Close()
+
+		joined := strings.Join(plan, "; ")
+		var main string
+		for _, step := range plan {
+			if strings.Contains(step, " bookmarks") {
+				main = step
+				break
+			}
+		}
+		if !strings.Contains(main, "USING INDEX") && !strings.Contains(main, "USING COVERING INDEX") {
+			t.Errorf("%q 沒有使用索引: %s", tt.query, joined)
+			continue
+		}
+		if tt.want != "" && !strings.Contains(main, tt.want+" ") && !strings.HasSuffix(main, tt.want) {
+			t.Errorf("%q 使用 %s，want %s", tt.query, main, tt.want)
+		}
+		if strings.Contains(main, "idx_bookmarks_trashed") {
+			t.Errorf("%q 使用了垃圾桶的索引: %s", tt.query, joined)
+		}
+	}
+
+	// 預設排序完全由索引提供，不需要暫存排序
+	rows, err := s.db.Query("EXPLAIN QUERY PLAN SELECT " + store.Columns + " FROM bookmarks WHERE deleted_at IS NULL ORDER BY priority DESC, created_at DESC, id DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		rows.Scan(&id, &parent, &notUsed, &detail)
+		if strings.Contains(detail, "TEMP B-TREE") {
+			t.Errorf("預設排序使用暫存排序: %s", detail)
+		}
+	}
+}
+
+// job_number 的 UNIQUE 限制已提供索引，不應另有重複的索引
+func TestNoRedundantJobNumberIndex(t *testing.T) {
+	s := newTestServer(t)
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name IN ('idx_job_number', 'idx_priority', 'idx_bookmarks_deleted_at')").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("仍有 %d 個多餘的索引", n)
+	}
+}
+
+var updateStoreSchema = flag.Bool("update-store-schema", false, "以遷移後的結構重新產生 store/testdata/schema.sql")
+
+// store 套件的測試以 store/testdata/schema.sql 建立資料庫，內容須與執行所有遷移後的結構相同；
+// 新增遷移後以 go test -run TestStoreTestSchema -update-store-schema 重新產生
+func TestStoreTestSchema(t *testing.T) {
+	s := newTestServer(t)
+	tx, err := s.db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	objects, _, err := sqliteSchema(context.Background(), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	b.WriteString("-- 執行 migrations.go 所有遷移後的 SQLite 結構，由 TestStoreTestSchema 產生，請勿手動修改\n")
+	for _, o := range objects {
+		b.WriteString(o.sql + ";\n")
+	}
+
+	path := filepath.Join("store", "testdata", "schema.sql")
+	if *updateStoreSchema {
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil || string(want) != b.String() {
+		t.Errorf("%s 與遷移後的結構不一致（%v），請以 -update-store-schema 重新產生", path, err)
+	}
+}
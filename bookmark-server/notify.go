@@ -0,0 +1,691 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// 通知
+//
+// 發生指定的事件（觸發條件）時以範本產生訊息，送到設定的通知服務（提供者）：
+//   - watchlist_bookmark：依關注清單自動加入書籤時，由加入書籤的一方呼叫 notifyBookmark
+//   - tender_changed：書籤的標案詳細資料重新下載後內容有變（見 fetchTenderRemote）
+//   - deadline：截止投標前 deadline_days 天內，每 deadline_interval（預設一小時）檢查一次，
+//     只通知優先順序不低於 deadline_min_priority 的書籤，同一個截止時間只通知一次（見 notifytriggers.go）
+// 訊息放入佇列由背景依序送出，不會拖慢請求。提供者設定 digest 時，該時間內的通知合併成一則摘要送出。
+// 每個提供者在 rate_window 內最多送出 rate_limit 則（摘要算一則），
+// 超過的不送出並記錄為 rate_limited，下一則送出的訊息附上略過的則數，大量更新時不會洗版。
+// 服務回應 429 時依 Retry-After 等待後重試，連線失敗或回應 5xx 時依序等待 1、2、4 秒重試（見 postNotification，等待的基數為各通知服務的 retryBackoff）。
+// 每次送出（含失敗與略過）都記錄於 notification_log，以 GET /api/notifications（或 /api/notifications/log）查詢；
+// POST /api/notifications/test 立即送出範例訊息，用來確認權杖。
+// 新增通知服務時實作 notifier 並在 newNotifier 登記，設定沿用 ProviderConfig；
+// 目前有 LINE Notify（linenotify.go）、Slack（slacknotify.go）、Discord（discordnotify.go）、ntfy（ntfynotify.go）
+// 與通用的 JSON webhook（webhooknotify.go）。
+
+// 觸發條件
+const (
+	triggerWatchlistBookmark = "watchlist_bookmark"
+	triggerTenderChanged     = "tender_changed"
+	triggerDeadline          = "deadline"
+	triggerTest              = "test" // 測試訊息，不可設定
+)
+
+var notificationTriggers = []string{triggerWatchlistBookmark, triggerTenderChanged, triggerDeadline}
+
+// 通知紀錄的狀態
+const (
+	notifySent        = "sent"
+	notifyFailed      = "failed"
+	notifyRateLimited = "rate_limited"
+	notifyDropped     = "dropped" // 佇列已滿
+)
+
+// NotificationConfig 通知設定，沒有提供者時停用
+type NotificationConfig struct {
+	Triggers     []string          `json:"triggers"`      // 啟用的觸發條件，未設定時全部啟用
+	DeadlineDays int               `json:"deadline_days"` // 截止前幾天內通知，預設 3
+	RateLimit    int               `json:"rate_limit"`    // 每個提供者在 RateWindow 內最多送出的則數，預設 10
+	RateWindow   Duration          `json:"rate_window"`   // 預設 "10m"
+	Templates    map[string]string `json:"templates"`     // 依觸發條件覆寫訊息範本（text/template，欄位見 Notification）
+	Providers    []ProviderConfig  `json:"providers"`
+
+	DeadlineInterval    Duration `json:"deadline_interval"`     // 檢查截止投標的間隔，預設 "1h"
+	DeadlineMinPriority *int     `json:"deadline_min_priority"` // 只通知優先順序不低於此值的書籤，未設定時全部通知
+}
+
+// ProviderConfig 通知服務設定
+type ProviderConfig struct {
+	Type     string   `json:"type"`     // line、slack、discord、ntfy、webhook
+	Name     string   `json:"name"`     // 紀錄與測試時使用的名稱，預設與 type 相同，不可重複
+	Token    string   `json:"token"`    // 權杖；LINE 未設定時使用環境變數 LINE_NOTIFY_TOKEN，ntfy 為自架伺服器的存取權杖，webhook 為簽署用的密鑰
+	URL      string   `json:"url"`      // API 位址（Slack、Discord、webhook 為 webhook 網址，ntfy 為伺服器網址），LINE、ntfy 未設定時使用正式位址
+	Topic    string   `json:"topic"`    // ntfy 的主題
+	Triggers []string `json:"triggers"` // 只送出這些觸發條件，未設定時送出所有啟用的觸發條件
+
+	// 依觸發條件指定頻道（Slack），未列出的觸發條件送到 webhook 預設的頻道
+	Channels map[string]string `json:"channels"`
+
+	// 摘要模式：第一則通知後等待 Digest，期間的通知合併成一則送出；未設定時逐則送出
+	Digest Duration `json:"digest"`
+}
+
+// 預設訊息範本
+var defaultNotificationTemplates = map[string]string{
+	triggerWatchlistBookmark: "⭐ 已依關注清單加入書籤\n{{.Title}}\n{{.UnitName}}\n{{.URL}}",
+	triggerTenderChanged:     "📝 書籤的標案資料有更新\n{{.Title}}\n{{.UnitName}}\n{{.URL}}",
+	triggerDeadline:          "⏰ {{.DaysLeft}} 天後截止投標（{{.DeadlineText}}）\n{{.Title}}\n{{.UnitName}}\n{{.URL}}",
+	triggerTest:              "✅ 通知測試\n{{.Title}}\n{{.UnitName}}\n{{.URL}}",
+}
+
+// 補上預設值並檢查設定
+func (c *NotificationConfig) validate() error {
+	if c.DeadlineDays == 0 {
+		c.DeadlineDays = 3
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = 10
+	}
+	if c.RateWindow.Duration == 0 {
+		c.RateWindow = Duration{10 * time.Minute}
+	}
+	if c.DeadlineInterval.Duration == 0 {
+		c.DeadlineInterval = Duration{time.Hour}
+	}
+	if c.DeadlineDays < 0 || c.RateLimit < 0 || c.RateWindow.Duration < 0 {
+		return fmt.Errorf("notifications 的 deadline_days、rate_limit 與 rate_window 不可為負數")
+	}
+	if c.DeadlineInterval.Duration < time.Minute {
+		return fmt.Errorf("notifications.deadline_interval 至少為 1m")
+	}
+	if err := checkTriggers(c.Triggers); err != nil {
+		return err
+	}
+	for trigger, text := range c.Templates {
+		if err := checkTriggers([]string{trigger}); err != nil {
+			return err
+		}
+		if _, err := template.New(trigger).Parse(text); err != nil {
+			return fmt.Errorf("通知範本 %s 格式錯誤: %w", trigger, err)
+		}
+	}
+	names := map[string]bool{}
+	for i := range c.Providers {
+		p := &c.Providers[i]
+		if p.Name == "" {
+			p.Name = p.Type
+		}
+		if names[p.Name] {
+			return fmt.Errorf("通知提供者名稱重複: %s", p.Name)
+		}
+		names[p.Name] = true
+		if err := checkTriggers(p.Triggers); err != nil {
+			return err
+		}
+		for trigger := range p.Channels {
+			if err := checkTriggers([]string{trigger}); err != nil {
+				return err
+			}
+		}
+		if p.Digest.Duration < 0 {
+			return fmt.Errorf("通知提供者 %s: digest 不可為負數", p.Name)
+		}
+		if _, err := newNotifier(*p); err != nil {
+			return fmt.Errorf("通知提供者 %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func checkTriggers(triggers []string) error {
+	for _, t := range triggers {
+		if !containsString(notificationTriggers, t) {
+			return fmt.Errorf("不支援的通知觸發條件: %s（可用: %s）", t, strings.Join(notificationTriggers, ", "))
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification 一則通知；範本可使用所有欄位
+type Notification struct {
+	Trigger      string
+	JobNumber    string
+	Title        string
+	UnitName     string
+	URL          string
+	Priority     int
+	Note         string
+	Keywords     []string  // 書籤符合的關鍵字
+	Deadline     time.Time // 截止投標時間，尚未下載標案詳細資料時為零值
+	DeadlineText string
+	DaysLeft     int // 只有 deadline 有值
+
+	// 重複通知的判斷依據（deadline 為截止時間），寫入紀錄
+	DedupKey string
+
+	// 由範本產生的訊息
+	Text string
+}
+
+// 一次送出的內容：一則通知，或摘要模式下合併的多則通知
+type delivery struct {
+	Items []*Notification
+
+	// 先前因頻率限制略過的則數，提供者應以 skippedNote 附註於訊息中
+	Skipped int
+}
+
+// 略過則數的附註，沒有略過時為空字串
+func (d *delivery) skippedNote() string {
+	if d.Skipped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("（另有 %d 則通知因頻率限制未送出，詳見通知紀錄）", d.Skipped)
+}
+
+// notifier 通知服務
+type notifier interface {
+	// 送出通知，回傳 HTTP 狀態碼（沒有收到回應時為 0）
+	send(ctx context.Context, d *delivery) (int, error)
+}
+
+// 依設定建立通知服務
+func newNotifier(cfg ProviderConfig) (notifier, error) {
+	switch cfg.Type {
+	case "line":
+		return newLineNotifier(cfg)
+	case "slack":
+		return newSlackNotifier(cfg)
+	case "discord":
+		return newDiscordNotifier(cfg)
+	case "ntfy":
+		return newNtfyNotifier(cfg)
+	case "webhook":
+		return newWebhookNotifier(cfg)
+	}
+	return nil, fmt.Errorf("不支援的通知服務: %q", cfg.Type)
+}
+
+// 對通知服務發出請求共用的 HTTP client
+var notifyClient = &http.Client{Timeout: 15 * time.Second}
+
+// 429、5xx 與連線失敗時最多重試的次數與每次最多等待的時間；Retry-After 超過上限時不再重試
+const (
+	notifyMaxRetries   = 3
+	notifyMaxRetryWait = time.Minute
+)
+
+// 重試等待時間的預設基數；各通知服務的 retryBackoff 由此設定，第 n 次重試等待 retryBackoff << (n-1)
+const notifyRetryBackoff = time.Second
+
+// 通知服務的回應
+type notifyResponse struct {
+	Status int // 沒有收到回應時為 0
+	Header http.Header
+	Body   []byte // 最多 64 KiB
+}
+
+// 發出請求並讀取回應；回應 429 時依 Retry-After 等待後重試，連線失敗或回應 5xx 時依序等待 backoff、2×backoff、4×backoff 重試
+// 重試後仍連線失敗時，錯誤附上嘗試的次數；newRequest 每次重試都會呼叫，以取得新的請求內容
+func postNotification(ctx context.Context, backoff time.Duration, newRequest func() (*http.Request, error)) (notifyResponse, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return notifyResponse{}, err
+		}
+		var result notifyResponse
+		resp, err := notifyClient.Do(req.WithContext(ctx))
+		if err == nil {
+			var body []byte
+			body, err = io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			result = notifyResponse{Status: resp.StatusCode, Header: resp.Header, Body: body}
+		}
+		retryable := err != nil || result.Status == http.StatusTooManyRequests || result.Status >= 500
+		if !retryable || attempt >= notifyMaxRetries || ctx.Err() != nil {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("嘗試 %d 次後仍失敗: %w", attempt+1, err)
+			}
+			return result, err
+		}
+		var wait time.Duration
+		if result.Status == http.StatusTooManyRequests {
+			wait = parseRetryAfter(result.Header.Get("Retry-After"), time.Now())
+		}
+		if wait == 0 {
+			wait = backoff << attempt
+		}
+		if wait > notifyMaxRetryWait {
+			return result, err
+		}
+		if err != nil {
+			log.Printf("送出通知失敗，%s 後重試: %v", wait, err)
+		} else {
+			log.Printf("通知服務回應 %d，%s 後重試", result.Status, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+}
+
+// 通知的標題列，各提供者共用
+func notificationHeadline(n *Notification) string {
+	switch n.Trigger {
+	case triggerWatchlistBookmark:
+		return "已依關注清單加入書籤"
+	case triggerTenderChanged:
+		return "書籤的標案資料有更新"
+	case triggerDeadline:
+		return fmt.Sprintf("%d 天後截止投標（%s）", n.DaysLeft, n.DeadlineText)
+	case triggerTest:
+		return "通知測試"
+	}
+	return n.Trigger
+}
+
+// 超過 max 個字時截斷並加上省略號，各提供者依服務的訊息上限使用
+func truncateRunes(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return s
+}
+
+// 單一提供者與其頻率限制
+type provider struct {
+	cfg ProviderConfig
+	notifier
+
+	mu      sync.Mutex
+	sent    []time.Time // RateWindow 內送出的時間
+	skipped int         // 上次送出後因頻率限制略過的則數
+
+	// 摘要模式：等待合併的通知與送出的計時器
+	pending []*Notification
+	timer   *time.Timer
+}
+
+// 頻率限制：count 則通知合併為一次送出，允許時回傳 true 與先前略過的則數
+func (p *provider) allow(now time.Time, limit int, window time.Duration, count int) (bool, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.sent[:0]
+	for _, t := range p.sent {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	p.sent = kept
+	if len(p.sent) >= limit {
+		p.skipped += count
+		return false, 0
+	}
+	p.sent = append(p.sent, now)
+	skipped := p.skipped
+	p.skipped = 0
+	return true, skipped
+}
+
+func (p *provider) accepts(trigger string) bool {
+	return trigger == triggerTest || len(p.cfg.Triggers) == 0 || containsString(p.cfg.Triggers, trigger)
+}
+
+// 通知佇列長度，超過時丟棄並記錄
+const notificationQueueSize = 256
+
+// notificationHub 產生並送出通知；所有年度共用，紀錄寫入設定年度的資料庫
+type notificationHub struct {
+	s         *Server
+	cfg       NotificationConfig
+	templates map[string]*template.Template
+	providers []*provider
+
+	queue chan *Notification
+	done  chan struct{}
+}
+
+// 建立並啟動通知；沒有設定提供者時回傳 nil（所有通知都不處理）
+func (s *Server) startNotifications() error {
+	cfg := s.cfg.Notifications
+	if len(cfg.Providers) == 0 {
+		return nil
+	}
+	h := &notificationHub{
+		s:         s,
+		cfg:       cfg,
+		templates: make(map[string]*template.Template),
+		queue:     make(chan *Notification, notificationQueueSize),
+		done:      make(chan struct{}),
+	}
+	for trigger, text := range defaultNotificationTemplates {
+		if custom, ok := cfg.Templates[trigger]; ok {
+			text = custom
+		}
+		h.templates[trigger] = template.Must(template.New(trigger).Parse(text))
+	}
+	for _, pc := range cfg.Providers {
+		n, err := newNotifier(pc)
+		if err != nil {
+			return err
+		}
+		h.providers = append(h.providers, &provider{cfg: pc, notifier: n})
+	}
+	s.notifications = h
+	go h.run()
+	log.Printf("通知已啟用: %d 個提供者", len(h.providers))
+	return nil
+}
+
+// 停止通知，等待佇列中的訊息送完（最多 timeout）
+func (h *notificationHub) stop(timeout time.Duration) {
+	if h == nil {
+		return
+	}
+	close(h.queue)
+	select {
+	case <-h.done:
+	case <-time.After(timeout):
+		log.Println("等待通知送出逾時，剩餘的通知未送出")
+	}
+}
+
+// 觸發條件是否啟用
+func (h *notificationHub) enabled(trigger string) bool {
+	return h != nil && (len(h.cfg.Triggers) == 0 || containsString(h.cfg.Triggers, trigger))
+}
+
+// 以範本產生訊息
+func (h *notificationHub) render(n *Notification) error {
+	if !n.Deadline.IsZero() {
+		n.DeadlineText = localTime(n.Deadline).Format("2006-01-02 15:04")
+	}
+	var b bytes.Buffer
+	if err := h.templates[n.Trigger].Execute(&b, n); err != nil {
+		return err
+	}
+	n.Text = strings.TrimSpace(b.String())
+	return nil
+}
+
+// 將通知放入佇列；觸發條件未啟用時忽略
+func (h *notificationHub) enqueue(n *Notification) {
+	if !h.enabled(n.Trigger) {
+		return
+	}
+	if err := h.render(n); err != nil {
+		log.Printf("產生通知訊息失敗（%s %s）: %v", n.Trigger, n.JobNumber, err)
+		return
+	}
+	select {
+	case h.queue <- n:
+	default:
+		for _, p := range h.providers {
+			h.record(p, n, notifyDropped, 0, fmt.Errorf("通知佇列已滿"))
+		}
+	}
+}
+
+// 依序送出佇列中的通知；佇列關閉後送出所有等待中的摘要
+func (h *notificationHub) run() {
+	defer close(h.done)
+	for n := range h.queue {
+		for _, p := range h.providers {
+			if !p.accepts(n.Trigger) {
+				continue
+			}
+			if p.cfg.Digest.Duration > 0 {
+				h.addToDigest(p, n)
+				continue
+			}
+			h.deliver(context.Background(), p, []*Notification{n}, true)
+		}
+	}
+	for _, p := range h.providers {
+		h.flushDigest(p)
+	}
+}
+
+// 摘要模式：第一則通知啟動計時器，時間到時一起送出
+func (h *notificationHub) addToDigest(p *provider, n *Notification) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, n)
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.cfg.Digest.Duration, func() { h.flushDigest(p) })
+	}
+}
+
+func (h *notificationHub) flushDigest(p *provider) {
+	p.mu.Lock()
+	items := p.pending
+	p.pending = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+	if len(items) > 0 {
+		h.deliver(context.Background(), p, items, true)
+	}
+}
+
+// 送出並記錄結果（每則通知一筆紀錄），回傳狀態；limited 為 false 時不受頻率限制（測試訊息）
+func (h *notificationHub) deliver(ctx context.Context, p *provider, items []*Notification, limited bool) (string, int, error) {
+	d := &delivery{Items: items}
+	if limited {
+		ok, skipped := p.allow(time.Now(), h.cfg.RateLimit, h.cfg.RateWindow.Duration, len(items))
+		if !ok {
+			for _, n := range items {
+				h.record(p, n, notifyRateLimited, 0, nil)
+			}
+			return notifyRateLimited, 0, nil
+		}
+		d.Skipped = skipped
+	}
+	status, err := p.send(ctx, d)
+	result := notifySent
+	if err != nil {
+		result = notifyFailed
+		log.Printf("送出通知失敗（%s，%d 則）: %v", p.cfg.Name, len(items), err)
+	}
+	for _, n := range items {
+		h.record(p, n, result, status, err)
+	}
+	return result, status, err
+}
+
+// 寫入通知紀錄
+func (h *notificationHub) record(p *provider, n *Notification, status string, httpStatus int, sendErr error) {
+	errText := ""
+	if sendErr != nil {
+		errText = truncateMessage(sendErr.Error())
+	}
+	_, err := h.s.db.execWrite(context.Background(), `
+		INSERT INTO notification_log (created_at, provider, trigger_name, job_number, dedup_key, status, http_status, error, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, dbNow(), p.cfg.Name, n.Trigger, n.JobNumber, n.DedupKey, status, httpStatus, errText, n.Text)
+	if err != nil {
+		log.Println("寫入通知紀錄失敗:", err)
+	}
+}
+
+// 通知某筆書籤（由書籤資料填入標案名稱、機關與網址）；書籤不存在或在垃圾桶中時不通知
+func (s *Server) notifyBookmark(trigger, jobNumber string) {
+	if !s.notifications.enabled(trigger) {
+		return
+	}
+	n := &Notification{Trigger: trigger, JobNumber: jobNumber}
+	var body sql.NullString
+	err := s.db.QueryRowContext(context.Background(), `
+		SELECT b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0), COALESCE(b.note, ''), c.body
+		FROM bookmarks b LEFT JOIN tender_cache c ON c.job_number = b.job_number
+		WHERE b.job_number = ? AND b.deleted_at IS NULL`, jobNumber).
+		Scan(&n.Title, &n.UnitName, &n.URL, &n.Priority, &n.Note, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return // 書籤已刪除或在垃圾桶中
+	}
+	if err == nil {
+		n.Note, err = s.db.cipher.open("note", n.Note)
+	}
+	if err == nil {
+		n.Deadline = tenderDeadline([]byte(body.String))
+		n.Keywords, err = s.notificationKeywords(context.Background(), jobNumber)
+	}
+	if err != nil {
+		log.Printf("讀取通知的書籤 %s 失敗: %v", jobNumber, err)
+		return
+	}
+	s.notifications.enqueue(n)
+}
+
+// 書籤符合的關鍵字
+func (s *Server) notificationKeywords(ctx context.Context, jobNumber string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT keyword FROM bookmark_keywords WHERE job_number = ? ORDER BY keyword", jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keywords []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, k)
+	}
+	return keywords, rows.Err()
+}
+
+// NotificationLogEntry 通知紀錄
+type NotificationLogEntry struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Provider   string    `json:"provider"`
+	Trigger    string    `json:"trigger"`
+	JobNumber  string    `json:"job_number"`
+	Status     string    `json:"status"`
+	HTTPStatus int       `json:"http_status"`
+	Error      string    `json:"error"`
+	Message    string    `json:"message"`
+}
+
+// GET /api/notifications（或 /api/notifications/log）：最新的通知紀錄在前，可依 provider、status、trigger、job_number 篩選，?limit= 預設 100
+// 送出失敗的紀錄 status 為 failed，error 為最後一次嘗試的錯誤
+func (s *Server) getNotificationLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+			return
+		}
+		limit = n
+	}
+	query := "SELECT id, created_at, provider, trigger_name, job_number, status, http_status, error, message FROM notification_log WHERE 1 = 1"
+	var args []interface{}
+	for param, column := range map[string]string{"provider": "provider", "status": "status", "trigger": "trigger_name", "job_number": "job_number"} {
+		if v := q.Get(param); v != "" {
+			query += " AND " + column + " = ?"
+			args = append(args, v)
+		}
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]NotificationLogEntry, 0)
+	for rows.Next() {
+		var e NotificationLogEntry
+		if err := rows.Scan(&e.ID, scanTime(&e.CreatedAt), &e.Provider, &e.Trigger, &e.JobNumber, &e.Status, &e.HTTPStatus, &e.Error, &e.Message); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		e.CreatedAt = localTime(e.CreatedAt)
+		items = append(items, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": s.notifications != nil,
+		"total":   len(items),
+		"items":   items,
+	})
+}
+
+// POST /api/notifications/test：立即送出範例訊息（不受頻率限制），?provider= 指定提供者，未指定時送到全部
+// 全部送出成功回傳 200，否則 502，各提供者的結果列於 results
+func (s *Server) testNotification(w http.ResponseWriter, r *http.Request) {
+	h := s.notifications
+	if h == nil {
+		writeError(w, r, http.StatusConflict, "notifications_disabled")
+		return
+	}
+	name := r.URL.Query().Get("provider")
+	var targets []*provider
+	for _, p := range h.providers {
+		if name == "" || p.cfg.Name == name {
+			targets = append(targets, p)
+		}
+	}
+	if len(targets) == 0 {
+		writeError(w, r, http.StatusNotFound, "unknown_provider", name)
+		return
+	}
+
+	n := &Notification{
+		Trigger:  triggerTest,
+		Title:    "政府採購書籤通知測試",
+		UnitName: "bookmark-server",
+		URL:      "https://web.pcc.gov.tw/",
+	}
+	if err := h.render(n); err != nil {
+		writeInternalError(w, r, "notification_failed", err)
+		return
+	}
+	status := http.StatusOK
+	results := make([]map[string]interface{}, 0, len(targets))
+	for _, p := range targets {
+		result, httpStatus, err := h.deliver(r.Context(), p, []*Notification{n}, false)
+		item := map[string]interface{}{"provider": p.cfg.Name, "status": result, "http_status": httpStatus}
+		if err != nil {
+			item["error"] = truncateMessage(err.Error())
+			status = http.StatusBadGateway
+		}
+		results = append(results, item)
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success": status == http.StatusOK,
+		"message": n.Text,
+		"results": results,
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 依序以 statuses 回應的 webhook 接收端，用完後回應 200；記錄每次請求的時間與內容
+type fakeWebhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header // 回應附加的標頭
+	times    []time.Time
+	bodies   [][]byte
+	sigs     []string
+}
+
+func newFakeWebhook(t *testing.T, statuses ...int) *fakeWebhook {
+	t.Helper()
+	f := &fakeWebhook{statuses: statuses, header: http.Header{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.times = append(f.times, time.Now())
+		f.bodies = append(f.bodies, body)
+		f.sigs = append(f.sigs, r.Header.Get("X-Signature"))
+		status := http.StatusOK
+		if len(f.statuses) > 0 {
+			status, f.statuses = f.statuses[0], f.statuses[1:]
+		}
+		for k, v := range f.header {
+			w.Header()[k] = v
+		}
+		f.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeWebhook) attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.times)
+}
+
+// 收到的通知中的案號，依收到的順序
+func (f *fakeWebhook) jobNumbers(t *testing.T) []string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var jns []string
+	for _, body := range f.bodies {
+		var p webhookNotificationPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Fatalf("通知內容不是 JSON: %s", body)
+		}
+		for _, item := range p.Items {
+			jns = append(jns, item.JobNumber)
+		}
+	}
+	return jns
+}
+
+// 重試等待時間縮短的 webhook 通知服務
+func newTestWebhookNotifier(t *testing.T, url string) *webhookNotifier {
+	t.Helper()
+	wn, err := newWebhookNotifier(ProviderConfig{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wn.retryBackoff = 10 * time.Millisecond
+	return wn
+}
+
+func TestWebhookNotifierRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		attempts   int
+		status     int
+		wantErr    bool
+	}{
+		{"ok", nil, "", 1, http.StatusOK, false},
+		{"5xx then ok", []int{500, 502}, "", 3, http.StatusOK, false},
+		{"429 then ok", []int{429}, "", 2, http.StatusOK, false},
+		{"gives up after 3 retries", []int{503, 503, 503, 503, 503}, "", 4, http.StatusServiceUnavailable, true},
+		{"4xx not retried", []int{400}, "", 1, http.StatusBadRequest, true},
+		{"retry-after too long", []int{429}, "3600", 1, http.StatusTooManyRequests, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeWebhook(t, tt.statuses...)
+			if tt.retryAfter != "" {
+				f.header.Set("Retry-After", tt.retryAfter)
+			}
+			wn := newTestWebhookNotifier(t, f.URL)
+			status, err := wn.send(context.Background(), &delivery{Items: []*Notification{{Trigger: triggerTest, Title: "測試"}}})
+			if status != tt.status || (err != nil) != tt.wantErr {
+				t.Errorf("send = %d, %v; want %d, err %v", status, err, tt.status, tt.wantErr)
+			}
+			if n := f.attempts(); n != tt.attempts {
+				t.Errorf("嘗試 %d 次, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+// 每次重試的等待時間加倍
+func TestWebhookNotifierBackoff(t *testing.T) {
+	f := newFakeWebhook(t, 500, 500, 500)
+	wn := newTestWebhookNotifier(t, f.URL)
+	if _, err := wn.send(context.Background(), &delivery{Items: []*Notification{{Trigger: triggerTest}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.times) != 4 {
+		t.Fatalf("嘗試 %d 次, want 4", len(f.times))
+	}
+	for i := 1; i < len(f.times); i++ {
+		gap := f.times[i].Sub(f.times[i-1])
+		if want := wn.retryBackoff << (i - 1); gap < want {
+			t.Errorf("第 %d 次重試前等待 %s, want >= %s", i, gap, want)
+		}
+	}
+}
+
+func TestPostNotificationConnectionFailure(t *testing.T) {
+	f := newFakeWebhook(t)
+	url := f.URL
+	f.Close()
+
+	wn := newTestWebhookNotifier(t, url)
+	status, err := wn.send(context.Background(), &delivery{Items: []*Notification{{Trigger: triggerTest}}})
+	if status != 0 || err == nil || !strings.Contains(err.Error(), fmt.Sprintf("嘗試 %d 次", notifyMaxRetries+1)) {
+		t.Errorf("send = %d, %v", status, err)
+	}
+
+	// 取消時不再重試
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := wn.send(ctx, &delivery{Items: []*Notification{{Trigger: triggerTest}}}); err == nil {
+		t.Error("已取消的送出沒有回傳錯誤")
+	}
+}
+
+func TestWebhookNotifierSignature(t *testing.T) {
+	f := newFakeWebhook(t)
+	wn, _ := newWebhookNotifier(ProviderConfig{URL: f.URL, Token: "secret"})
+	if _, err := wn.send(context.Background(), &delivery{Items: []*Notification{{Trigger: triggerTest, Title: "測試"}}}); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(f.bodies[0])
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); f.sigs[0] != want {
+		t.Errorf("X-Signature = %q, want %q", f.sigs[0], want)
+	}
+}
+
+// 以民國年表示 now 之後 days 天的截止投標時間
+func rocDeadline(now time.Time, days int) string {
+	d := localTime(now).AddDate(0, 0, days)
+	return fmt.Sprintf("%d/%02d/%02d 17:00", d.Year()-1911, d.Month(), d.Day())
+}
+
+func TestCheckDeadlinesMinPriority(t *testing.T) {
+	minPriority := 3
+	tests := []struct {
+		name        string
+		minPriority *int
+		want        []string
+	}{
+		{"all priorities", nil, []string{"HIGH", "MID", "LOW"}},
+		{"threshold", &minPriority, []string{"HIGH", "MID"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeWebhook(t)
+			s := newTestServer(t)
+			h := s.routes()
+			s.cfg.Notifications = NotificationConfig{
+				Triggers:            []string{triggerDeadline},
+				DeadlineMinPriority: tt.minPriority,
+				Providers:           []ProviderConfig{{Type: "webhook", URL: f.URL}},
+			}
+			if err := s.cfg.Notifications.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			bookmarks := []struct {
+				jn       string
+				priority int
+				days     int
+			}{
+				{"LOW", 1, 1},
+				{"MID", 3, 2},
+				{"HIGH", 5, 1},
+				{"LATER", 5, 10}, // 超過 deadline_days
+				{"PAST", 5, -1},  // 已截止
+			}
+			for _, b := range bookmarks {
+				addTestBookmark(t, h, fmt.Sprintf(`{"job_number":%q,"title":"標案 %s","priority":%d}`, b.jn, b.jn, b.priority))
+				body := fmt.Sprintf(`{"records":[{"detail":{"領投開標:截止投標":%q}}]}`, rocDeadline(now, b.days))
+				if err := s.storeTenderCache(b.jn, "https://example.com/"+b.jn, []byte(body), ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := s.startNotifications(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.checkDeadlines(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			s.notifications.stop(5 * time.Second)
+			if got := f.jobNumbers(t); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("通知 = %v, want %v", got, tt.want)
+			}
+
+			// 送出的通知寫入紀錄，下次檢查時據此略過；門檻只影響通知，不影響截止清單
+			var logged int
+			s.db.QueryRow("SELECT COUNT(*) FROM notification_log WHERE trigger_name = ? AND status = ?", triggerDeadline, notifySent).Scan(&logged)
+			if logged != len(tt.want) {
+				t.Errorf("通知紀錄 %d 筆, want %d", logged, len(tt.want))
+			}
+			due, err := s.upcomingDeadlines(context.Background(), now, s.cfg.Notifications.DeadlineDays)
+			if err != nil || len(due) != 3 {
+				t.Fatalf("upcomingDeadlines = %d, %v", len(due), err)
+			}
+		})
+	}
+}
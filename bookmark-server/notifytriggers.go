@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// 通知的觸發條件：截止投標
+
+// 截止投標時間：詳細資料中鍵名以「截止投標」結尾的欄位，例如 "領投開標:截止投標": "115/03/26 17:00"
+// 取最後一筆紀錄（最新的公告）；沒有時間時視為當天 23:59；沒有或無法解析時回傳零值
+func tenderDeadline(body []byte) time.Time {
+	var payload struct {
+		Records []struct {
+			Detail map[string]interface{} `json:"detail"`
+		} `json:"records"`
+	}
+	if json.Unmarshal(body, &payload) != nil || len(payload.Records) == 0 {
+		return time.Time{}
+	}
+	for key, v := range payload.Records[len(payload.Records)-1].Detail {
+		value, ok := v.(string)
+		if !ok || !strings.HasSuffix(key, "截止投標") {
+			continue
+		}
+		parts := strings.Fields(value)
+		if len(parts) == 0 {
+			continue
+		}
+		d, err := parseTenderDate(parts[0])
+		if err != nil || d == 0 {
+			continue
+		}
+		clock := 23*time.Hour + 59*time.Minute
+		if len(parts) > 1 {
+			if t, err := time.Parse("15:04", parts[1]); err == nil {
+				clock = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+			}
+		}
+		return d.Time().Add(clock)
+	}
+	return time.Time{}
+}
+
+// 兩個時間在顯示時區相差的日曆天數
+func calendarDaysBetween(from, to time.Time) int {
+	day := func(t time.Time) time.Time {
+		y, m, d := localTime(t).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return int(day(to).Sub(day(from)).Hours() / 24)
+}
+
+// 尚未截止且在 days 天內截止投標的書籤，依優先順序排列；DaysLeft 為剩餘的日曆天數
+// 截止時間取自 tender_cache，尚未下載詳細資料的書籤不列出
+func (s *Server) upcomingDeadlines(ctx context.Context, now time.Time, days int) ([]*Notification, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.job_number, b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0), COALESCE(b.note, ''), c.body
+		FROM bookmarks b JOIN tender_cache c ON c.job_number = b.job_number
+		WHERE b.deleted_at IS NULL
+		ORDER BY b.priority DESC, b.id`)
+	if err != nil {
+		return nil, err
+	}
+	var due []*Notification
+	for rows.Next() {
+		n := &Notification{Trigger: triggerDeadline}
+		var body string
+		if err := rows.Scan(&n.JobNumber, &n.Title, &n.UnitName, &n.URL, &n.Priority, &n.Note, &body); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.Deadline = tenderDeadline([]byte(body))
+		if n.Deadline.IsZero() || !n.Deadline.After(now) {
+			continue
+		}
+		if n.DaysLeft = calendarDaysBetween(now, n.Deadline); n.DaysLeft > days {
+			continue
+		}
+		if n.Note, err = s.db.cipher.open("note", n.Note); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		n.DedupKey = n.Deadline.UTC().Format(time.RFC3339)
+		due = append(due, n)
+	}
+	err = rows.Err()
+	rows.Close()
+	return due, err
+}
+
+// 檢查截止投標：尚未截止且在 DeadlineDays 天內、優先順序不低於 DeadlineMinPriority、同一個截止時間還沒有通知過的書籤
+// 因頻率限制略過的下次檢查時再送
+func (s *Server) checkDeadlines(ctx context.Context) error {
+	h := s.notifications
+	if !h.enabled(triggerDeadline) {
+		return nil
+	}
+	due, err := s.upcomingDeadlines(ctx, time.Now(), h.cfg.DeadlineDays)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if min := h.cfg.DeadlineMinPriority; min != nil && n.Priority < *min {
+			continue
+		}
+		var notified bool
+		err := s.db.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM notification_log
+			WHERE trigger_name = ? AND job_number = ? AND dedup_key = ? AND status NOT IN (?, ?))`,
+			triggerDeadline, n.JobNumber, n.DedupKey, notifyRateLimited, notifyDropped).Scan(&notified)
+		if err != nil {
+			return err
+		}
+		if notified {
+			continue
+		}
+		if n.Keywords, err = s.notificationKeywords(ctx, n.JobNumber); err != nil {
+			return err
+		}
+		h.enqueue(n)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ntfy 通知服務
+// 以 JSON 發布到 ntfy 伺服器（url，預設 https://ntfy.sh，也可以是自架的伺服器）的 topic，手機上的 ntfy App 訂閱同一個主題即可收到推播。
+// 自架伺服器需要驗證時 token 為存取權杖。優先順序由書籤的優先順序對應（見 ntfyPriority），
+// 點選通知開啟標案頁面，tags 為觸發條件（第一個是顯示為表情符號的 shortcode）。
+// ntfy 的訊息上限為 4096 位元組，超過時會變成附件，因此內文以 ntfyMaxRunes 字截斷。
+
+const (
+	ntfyDefaultURL = "https://ntfy.sh"
+	ntfyMaxRunes   = 1300 // 中文一個字 3 個位元組，約 3900 位元組
+	ntfyMaxTitle   = 250
+)
+
+// ntfy 的主題名稱規則
+var ntfyTopicPattern = regexp.MustCompile(`^[-_A-Za-z0-9]{1,64}$`)
+
+// 各觸發條件的 tags；ntfy 將 emoji shortcode 顯示為標題前的表情符號
+var ntfyTags = map[string][]string{
+	triggerWatchlistBookmark: {"star", triggerWatchlistBookmark},
+	triggerTenderChanged:     {"memo", triggerTenderChanged},
+	triggerDeadline:          {"alarm_clock", triggerDeadline},
+	triggerTest:              {"white_check_mark", triggerTest},
+}
+
+type ntfyNotifier struct {
+	url          string
+	topic        string
+	token        string
+	retryBackoff time.Duration
+}
+
+func newNtfyNotifier(cfg ProviderConfig) (*ntfyNotifier, error) {
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("未設定 ntfy 主題（topic）")
+	}
+	if !ntfyTopicPattern.MatchString(cfg.Topic) {
+		return nil, fmt.Errorf("ntfy 主題只能使用英數字、- 與 _，最多 64 個字元: %q", cfg.Topic)
+	}
+	n := &ntfyNotifier{url: cfg.URL, topic: cfg.Topic, token: cfg.Token, retryBackoff: notifyRetryBackoff}
+	if n.url == "" {
+		n.url = ntfyDefaultURL
+	}
+	if u, err := url.Parse(n.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("ntfy 伺服器網址必須是 http 或 https 網址: %q", n.url)
+	}
+	n.url = strings.TrimSuffix(n.url, "/")
+	return n, nil
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Tags     []string `json:"tags,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Click    string   `json:"click,omitempty"`
+}
+
+// 書籤優先順序對應 ntfy 的優先順序（1 最低、3 預設、5 最高）；截止投標的通知提高一級
+func ntfyPriority(n *Notification) int {
+	p := 3
+	switch {
+	case n.Priority < 0:
+		p = 2
+	case n.Priority >= 3:
+		p = 5
+	case n.Priority > 0:
+		p = 4
+	}
+	if n.Trigger == triggerDeadline && p < 5 {
+		p++
+	}
+	return p
+}
+
+// 一則通知以標題列為標題；摘要時列出各則的標題列與內容，優先順序取最高的一則
+func ntfyMessageFor(topic string, d *delivery) *ntfyMessage {
+	msg := &ntfyMessage{Topic: topic}
+	texts := make([]string, 0, len(d.Items)+1)
+	for _, n := range d.Items {
+		if p := ntfyPriority(n); p > msg.Priority {
+			msg.Priority = p
+		}
+		for _, tag := range ntfyTags[n.Trigger] {
+			if !containsString(msg.Tags, tag) {
+				msg.Tags = append(msg.Tags, tag)
+			}
+		}
+		texts = append(texts, n.Text)
+	}
+	if len(d.Items) == 1 {
+		msg.Title = notificationHeadline(d.Items[0])
+		msg.Click = d.Items[0].URL
+	} else {
+		msg.Title = fmt.Sprintf("政府採購書籤通知：%d 則", len(d.Items))
+	}
+	if note := d.skippedNote(); note != "" {
+		texts = append(texts, note)
+	}
+	msg.Title = truncateRunes(msg.Title, ntfyMaxTitle)
+	msg.Message = truncateRunes(strings.Join(texts, "\n\n"), ntfyMaxRunes)
+	return msg
+}
+
+func (nt *ntfyNotifier) send(ctx context.Context, d *delivery) (int, error) {
+	payload, err := json.Marshal(ntfyMessageFor(nt.topic, d))
+	if err != nil {
+		return 0, err
+	}
+	resp, err := postNotification(ctx, nt.retryBackoff, func() (*http.Request, error) {
+		// JSON 發布時 POST 到伺服器根路徑，主題放在內容中
+		req, err := http.NewRequest("POST", nt.url+"/", bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if nt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+nt.token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status != http.StatusOK {
+		// 錯誤回應為 {"code": 40101, "http": 401, "error": "unauthorized"}
+		var payload struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(resp.Body))
+		if json.Unmarshal(resp.Body, &payload) == nil && payload.Error != "" {
+			msg = payload.Error
+		}
+		return resp.Status, fmt.Errorf("ntfy 回應 %d: %s", resp.Status, truncateMessage(msg))
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 下載標書的請求節奏
+//
+// 批次下載時兩次請求之間至少間隔 DownloadInterval（由上一個請求發出的時間起算，回應慢時不再額外等待）。
+// 上游回應 429/503 或請求失敗時間隔加倍，連續失敗持續加倍，最長 DownloadMaxInterval；
+// 回應帶 Retry-After 時至少等到指定的時間。請求成功後間隔逐步縮回 DownloadInterval，
+// 不會一次回到原本的速度，以免上游剛解除限流又立刻被打滿。
+// 超出 DownloadInterval 的等待時間累計為 throttled，於下載摘要中回報。
+// 同時下載多筆（DownloadConcurrency）時各請求依序預約發出時間，速率上限不變，只是等待回應的時間可以重疊。
+
+// PCC API 回應非 200
+type upstreamStatusError struct {
+	status     string
+	code       int
+	retryAfter time.Duration // 回應的 Retry-After，未提供時為 0
+}
+
+func (e *upstreamStatusError) Error() string {
+	if e.retryAfter > 0 {
+		return "PCC API 回應 " + e.status + "（Retry-After " + e.retryAfter.String() + "）"
+	}
+	return "PCC API 回應 " + e.status
+}
+
+// 解析 Retry-After：秒數或 HTTP 日期；無法解析或已過期時回傳 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+type downloadPacer struct {
+	mu        sync.Mutex
+	min, max  time.Duration
+	interval  time.Duration // 目前的間隔
+	next      time.Time     // 下一個請求最早可發出的時間
+	last      time.Time     // 最近一次預約的發出時間
+	failures  int           // 連續失敗次數
+	throttled time.Duration // 超出 min 的等待時間合計
+}
+
+func newDownloadPacer(cfg Config) *downloadPacer {
+	return &downloadPacer{
+		min:      cfg.DownloadInterval.Duration,
+		max:      cfg.DownloadMaxInterval.Duration,
+		interval: cfg.DownloadInterval.Duration,
+	}
+}
+
+// 記錄一次請求的結果，start 為發出請求的時間，err 為請求的錯誤（成功時為 nil）
+func (p *downloadPacer) record(start time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.failures = 0
+		// 每次成功縮短一半的超出部分；下一個請求的時間已在 wait 時預約
+		p.interval = p.min + (p.interval-p.min)/2
+		return
+	}
+
+	p.failures++
+	p.interval = min(p.interval*2, p.max)
+	delay := p.interval
+	var se *upstreamStatusError
+	if errors.As(err, &se) && se.retryAfter > delay {
+		delay = min(se.retryAfter, p.max)
+	}
+	if next := time.Now().Add(delay); next.After(p.next) {
+		p.next = next
+	}
+	log.Printf("PCC API 請求失敗（連續 %d 次），%s 後再發出下一個請求", p.failures, delay.Round(time.Millisecond))
+}
+
+// 等到可以發出下一個請求；ctx 取消時提前返回
+// 同時下載時各自預約發出的時間，兩次請求之間仍至少間隔 interval
+func (p *downloadPacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	} else if !p.last.IsZero() {
+		if extra := at.Sub(p.last) - p.min; extra > 0 {
+			p.throttled += extra
+		}
+	}
+	p.last = at
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 預約的請求改由快取回應、沒有發出時歸還間隔，後續的請求不必多等
+func (p *downloadPacer) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next.After(time.Now()) {
+		p.next = p.next.Add(-p.interval)
+	}
+}
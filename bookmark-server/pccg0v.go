@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pcc.g0v.tw API
+//
+// 書籤的 api_url（PCC API）偶爾逾時或以錯誤頁面回應，pcc.g0v.tw 提供相同格式的資料，可作為備用來源。
+// 各功能以 pcc_g0v 設定選擇來源（official 為書籤的 api_url，g0v 為 pcc.g0v.tw）：
+//   - detail：下載標書、比對書籤與 GET /api/bookmarks/{job_number}/detail 使用的標案詳細資料，預設 official
+//   - autofill：新增書籤時缺少標案名稱或機關名稱，以案號查詢補齊，預設 off
+//   - awards：定期檢查書籤的標案是否已有決標公告，記錄於 tender_awards，預設 off
+// 對 pcc.g0v.tw 的請求共用同一個 g0vClient，兩次請求至少間隔 interval，回應 429/503 時依 Retry-After 暫停。
+// 不論來源，標案詳細資料都寫入 tender_cache（以 job_number 為鍵）。
+
+// 資料來源
+const (
+	pccSourceOff      = "off"
+	pccSourceOfficial = "official"
+	pccSourceG0v      = "g0v"
+)
+
+// 事件動作：書籤的標案有了決標公告
+const actionAward = "award"
+
+// 決標檢查的間隔；尚未下載或快取過期的詳細資料才會發出請求
+const awardCheckInterval = 6 * time.Hour
+
+// PCCG0vConfig pcc.g0v.tw API 設定
+type PCCG0vConfig struct {
+	URL      string   `json:"url"`      // API 位址，預設 https://pcc.g0v.ronny.tw/api
+	Interval Duration `json:"interval"` // 兩次請求的最短間隔，預設 1s
+	Detail   string   `json:"detail"`   // 標案詳細資料的來源：official 或 g0v
+	Autofill string   `json:"autofill"` // 新增書籤時補齊欄位的來源：off、official 或 g0v
+	Awards   string   `json:"awards"`   // 追蹤決標公告的來源：off、official 或 g0v
+}
+
+// 補上預設值並檢查設定
+func (c *PCCG0vConfig) validate() error {
+	if c.URL == "" {
+		c.URL = "https://pcc.g0v.ronny.tw/api"
+	}
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("pcc_g0v.url 必須是 http 或 https 網址: %q", c.URL)
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.Interval.Duration == 0 {
+		c.Interval.Duration = time.Second
+	}
+	if c.Interval.Duration < 0 {
+		return fmt.Errorf("pcc_g0v.interval 不可為負數")
+	}
+	for _, f := range []struct {
+		name  string
+		value *string
+		def   string
+		off   bool
+	}{
+		{"detail", &c.Detail, pccSourceOfficial, false},
+		{"autofill", &c.Autofill, pccSourceOff, true},
+		{"awards", &c.Awards, pccSourceOff, true},
+	} {
+		switch *f.value {
+		case "":
+			*f.value = f.def
+		case pccSourceOfficial, pccSourceG0v:
+		case pccSourceOff:
+			if !f.off {
+				return fmt.Errorf("pcc_g0v.%s 必須是 official 或 g0v", f.name)
+			}
+		default:
+			return fmt.Errorf("pcc_g0v.%s 必須是 off、official 或 g0v: %q", f.name, *f.value)
+		}
+	}
+	return nil
+}
+
+// 標案公告（tender、searchbyjob、listbyunit 回應的 records）
+type pccTenderRecord struct {
+	Date         int    `json:"date"`
+	Filename     string `json:"filename"`
+	JobNumber    string `json:"job_number"`
+	UnitID       string `json:"unit_id"`
+	UnitName     string `json:"unit_name"`
+	URL          string `json:"url"`
+	TenderAPIURL string `json:"tender_api_url"`
+	Brief        struct {
+		Type      string        `json:"type"`
+		Title     string        `json:"title"`
+		Category  string        `json:"category"`
+		Companies *pccCompanies `json:"companies"` // 只有決標公告有
+	} `json:"brief"`
+	Detail map[string]interface{} `json:"detail"` // 只有 tender 回應有
+}
+
+// 決標公告的得標廠商
+type pccCompanies struct {
+	IDs   []string `json:"ids"`
+	Names []string `json:"names"`
+}
+
+// GET /tender?unit_id=&job_number=：一個標案的所有公告，依公告先後排列
+type pccTender struct {
+	UnitName string            `json:"unit_name"`
+	Records  []pccTenderRecord `json:"records"`
+}
+
+// GET /searchbyjob?query=：以案號搜尋
+type pccSearchResult struct {
+	Query        string            `json:"query"`
+	Page         int               `json:"page"`
+	TotalPages   int               `json:"total_pages"`
+	TotalRecords int               `json:"total_records"`
+	Records      []pccTenderRecord `json:"records"`
+}
+
+// GET /listbyunit?unit_id=：機關的公告
+type pccUnit struct {
+	UnitName string            `json:"unit_name"`
+	Records  []pccTenderRecord `json:"records"`
+}
+
+// pcc.g0v.tw API client
+type g0vClient struct {
+	baseURL  string
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // 下一個請求最早可發出的時間
+}
+
+func newG0vClient(cfg PCCG0vConfig) *g0vClient {
+	return &g0vClient{baseURL: cfg.URL, interval: cfg.Interval.Duration}
+}
+
+// 等到可以發出下一個請求；同時等待的請求依序排隊
+func (c *g0vClient) wait(ctx context.Context) error {
+	c.mu.Lock()
+	at := c.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.interval)
+	c.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 上游限流時暫停所有請求
+func (c *g0vClient) pause(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t := time.Now().Add(d); t.After(c.next) {
+		c.next = t
+	}
+}
+
+// 標案詳細資料的網址
+func (c *g0vClient) tenderURL(unitID, jobNumber string) string {
+	return c.baseURL + "/tender?" + url.Values{"unit_id": {unitID}, "job_number": {jobNumber}}.Encode()
+}
+
+// 送出 GET 請求並回傳內容；非 200 回傳 upstreamStatusError，以 200 回傳的錯誤訊息回傳 upstreamPayloadError
+func (c *g0vClient) get(ctx context.Context, rawURL string) ([]byte, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "bookmark-server")
+	resp, err := tenderClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			c.pause(max(retryAfter, 10*c.interval))
+		}
+		return nil, &upstreamStatusError{status: resp.Status, code: resp.StatusCode, retryAfter: retryAfter}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimSpace(body), &payload); err != nil || payload == nil {
+		return nil, &upstreamPayloadError{truncateMessage(string(body))}
+	}
+	if raw, ok := payload["error"]; ok {
+		return nil, &upstreamPayloadError{truncateMessage(jsonText(raw))}
+	}
+	return body, nil
+}
+
+// 以案號搜尋，只回傳案號完全相同的公告
+func (c *g0vClient) searchByJob(ctx context.Context, jobNumber string) ([]pccTenderRecord, error) {
+	body, err := c.get(ctx, c.baseURL+"/searchbyjob?"+url.Values{"query": {jobNumber}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var result pccSearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析搜尋結果失敗: %w", err)
+	}
+	var records []pccTenderRecord
+	for _, rec := range result.Records {
+		if rec.JobNumber == jobNumber {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// 機關資料與其公告
+func (c *g0vClient) unit(ctx context.Context, unitID string) (*pccUnit, error) {
+	body, err := c.get(ctx, c.baseURL+"/listbyunit?"+url.Values{"unit_id": {unitID}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var u pccUnit
+	if err := json.Unmarshal(body, &u); err != nil {
+		return nil, fmt.Errorf("解析機關資料失敗: %w", err)
+	}
+	return &u, nil
+}
+
+// 找不到案號對應的標案
+var errG0vNotFound = errors.New("pcc.g0v.tw 查無此案號")
+
+// 取得 pcc.g0v.tw 上標案詳細資料的網址：api_url 帶有 unit_id 時直接使用，否則以案號搜尋
+func (c *g0vClient) resolveTenderURL(ctx context.Context, jobNumber, apiURL string) (string, error) {
+	if u, err := url.Parse(apiURL); err == nil && apiURL != "" {
+		if unitID := u.Query().Get("unit_id"); unitID != "" {
+			return c.tenderURL(unitID, jobNumber), nil
+		}
+	}
+	records, err := c.searchByJob(ctx, jobNumber)
+	if err != nil {
+		return "", err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].UnitID != "" {
+			return c.tenderURL(records[i].UnitID, jobNumber), nil
+		}
+	}
+	return "", errG0vNotFound
+}
+
+// 依來源決定要請求的網址；official 為書籤的 api_url
+func (s *Server) tenderSourceURL(ctx context.Context, source, jobNumber, apiURL string) (string, error) {
+	if source != pccSourceG0v {
+		if apiURL == "" {
+			return "", errors.New("無 API URL")
+		}
+		return apiURL, nil
+	}
+	return s.g0v.resolveTenderURL(ctx, jobNumber, apiURL)
+}
+
+// 書籤沒有 api_url 時能否取得詳細資料（pcc.g0v.tw 可以用案號查詢）
+func (s *Server) canFetchDetail(apiURL string) bool {
+	return apiURL != "" || s.cfg.PCCG0v.Detail == pccSourceG0v
+}
+
+// 新增書籤時補齊標案名稱、機關名稱等空白欄位，查詢失敗不影響新增
+func (s *Server) autofillBookmark(ctx context.Context, jobNumber string, cols *mirroredColumns) {
+	source := s.cfg.PCCG0v.Autofill
+	if source == pccSourceOff || (cols.Title != "" && cols.UnitName != "") {
+		return
+	}
+	var rec *pccTenderRecord
+	unitName := ""
+	switch source {
+	case pccSourceOfficial:
+		if cols.APIURL == "" {
+			return
+		}
+		body, _, err := s.fetchTenderFrom(ctx, pccSourceOfficial, jobNumber, cols.APIURL, true)
+		if err != nil {
+			log.Printf("補齊書籤 %s 的欄位失敗: %v", jobNumber, err)
+			return
+		}
+		var t pccTender
+		if err := json.Unmarshal(body, &t); err != nil || len(t.Records) == 0 {
+			return
+		}
+		rec, unitName = &t.Records[len(t.Records)-1], t.UnitName
+	case pccSourceG0v:
+		records, err := s.g0v.searchByJob(ctx, jobNumber)
+		if err != nil || len(records) == 0 {
+			if err == nil {
+				err = errG0vNotFound
+			}
+			log.Printf("補齊書籤 %s 的欄位失敗: %v", jobNumber, err)
+			return
+		}
+		rec = &records[len(records)-1]
+		if rec.UnitName == "" && rec.UnitID != "" {
+			if u, err := s.g0v.unit(ctx, rec.UnitID); err == nil {
+				unitName = u.UnitName
+			}
+		}
+	}
+
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = strings.TrimSpace(value)
+		}
+	}
+	fill(&cols.Title, rec.Brief.Title)
+	fill(&cols.UnitName, firstNonEmpty(rec.UnitName, unitName))
+	fill(&cols.Type, rec.Brief.Type)
+	if rec.URL != "" {
+		fill(&cols.URL, "https://web.pcc.gov.tw"+rec.URL)
+	}
+	fill(&cols.APIURL, rec.TenderAPIURL)
+	if cols.Date == 0 {
+		cols.Date = TenderDate(rec.Date)
+	}
+}
+
+// TenderAward 書籤標案的決標公告
+type TenderAward struct {
+	JobNumber  string    `json:"job_number"`
+	Title      string    `json:"title"`
+	Date       int       `json:"date"`      // 決標公告日期（YYYYMMDD）
+	Companies  []string  `json:"companies"` // 得標廠商
+	Amount     string    `json:"amount"`    // 總決標金額，原文
+	Source     string    `json:"source"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// 由標案詳細資料找出最後一筆決標公告；無法決標公告不算
+func parseTenderAward(body []byte) (*TenderAward, bool) {
+	var t pccTender
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, false
+	}
+	for i := len(t.Records) - 1; i >= 0; i-- {
+		rec := t.Records[i]
+		if !strings.Contains(rec.Brief.Type, "決標") || strings.Contains(rec.Brief.Type, "無法決標") {
+			continue
+		}
+		a := &TenderAward{JobNumber: rec.JobNumber, Title: rec.Brief.Title, Date: rec.Date, Companies: make([]string, 0)}
+		if rec.Brief.Companies != nil {
+			a.Companies = append(a.Companies, rec.Brief.Companies.Names...)
+		}
+		if v, ok := rec.Detail["決標資料:總決標金額"].(string); ok {
+			a.Amount = strings.TrimSpace(v)
+		}
+		return a, true
+	}
+	return nil, false
+}
+
+// 排程：檢查尚未決標的書籤，發現決標公告時記錄並寫入事件
+func (s *Server) checkAwards(ctx context.Context) error {
+	source := s.cfg.PCCG0v.Awards
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.job_number, COALESCE(b.api_url, '') FROM bookmarks b
+		LEFT JOIN tender_awards a ON a.job_number = b.job_number
+		WHERE a.job_number IS NULL AND b.deleted_at IS NULL
+		ORDER BY b.job_number`)
+	if err != nil {
+		return err
+	}
+	type task struct{ jobNumber, apiURL string }
+	var tasks []task
+	for rows.Next() {
+		var t task
+		if err := rows.Scan(&t.jobNumber, &t.apiURL); err != nil {
+			rows.Close()
+			return err
+		}
+		if t.apiURL != "" || source == pccSourceG0v {
+			tasks = append(tasks, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// 官方來源與下載標書共用請求節奏；pcc.g0v.tw 由 g0vClient 限速
+	pacer := newDownloadPacer(s.cfg)
+	found, failed := 0, 0
+	for _, t := range tasks {
+		if source == pccSourceOfficial {
+			if err := pacer.wait(ctx); err != nil {
+				return err
+			}
+		}
+		start := time.Now()
+		body, fetched, err := s.fetchTenderFrom(ctx, source, t.jobNumber, t.apiURL, false)
+		if fetched != tenderFromCache {
+			pacer.record(start, err)
+		} else if source == pccSourceOfficial {
+			pacer.release()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			log.Printf("檢查書籤 %s 的決標公告失敗: %v", t.jobNumber, err)
+			continue
+		}
+		award, ok := parseTenderAward(body)
+		if !ok {
+			continue
+		}
+		award.JobNumber, award.Source, award.DetectedAt = t.jobNumber, source, localTime(time.Now())
+		companies, _ := json.Marshal(award.Companies)
+		err = s.db.withTx(ctx, func(tx *Tx) error {
+			result, err := tx.Exec(`
+				INSERT INTO tender_awards (job_number, date, companies, amount, source, detected_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+				ON CONFLICT(job_number) DO NOTHING`,
+				t.jobNumber, award.Date, string(companies), award.Amount, source, dbTime(award.DetectedAt))
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return nil
+			}
+			return writeEvent(tx, systemActor, entityTender, t.jobNumber, actionAward, award)
+		})
+		if err != nil {
+			return err
+		}
+		found++
+	}
+	if found > 0 {
+		s.markChanged()
+	}
+	if found > 0 || failed > 0 {
+		log.Printf("決標檢查: 檢查 %d 筆，發現決標 %d 筆，失敗 %d 筆", len(tasks), found, failed)
+	}
+	return nil
+}
+
+// GET /api/awards：已決標的書籤，依公告日期由新到舊
+func (s *Server) getAwards(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT a.job_number, b.title, a.date, a.companies, a.amount, a.source, a.detected_at
+		FROM tender_awards a JOIN bookmarks b ON b.job_number = a.job_number
+		WHERE b.deleted_at IS NULL
+		ORDER BY a.date DESC, a.job_number`)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]TenderAward, 0)
+	for rows.Next() {
+		var a TenderAward
+		var companies string
+		if err := rows.Scan(&a.JobNumber, &a.Title, &a.Date, &companies, &a.Amount, &a.Source, scanTime(&a.DetectedAt)); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if err := json.Unmarshal([]byte(companies), &a.Companies); err != nil || a.Companies == nil {
+			a.Companies = make([]string, 0)
+		}
+		a.DetectedAt = localTime(a.DetectedAt)
+		items = append(items, a)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"source": s.cfg.PCCG0v.Awards,
+		"total":  len(items),
+		"items":  items,
+	})
+}
+
+// GET /api/bookmarks/{job_number}/detail：書籤的標案詳細資料（來源依 pcc_g0v.detail），回應原始 JSON
+// 快取過期時先回傳舊資料並於背景更新；X-Tender-Source 標頭說明資料來源
+func (s *Server) getBookmarkDetail(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.PathValue("job_number"))
+	if !ok {
+		return
+	}
+	var apiURL string
+	err := s.db.QueryRowContext(r.Context(), "SELECT COALESCE(api_url, '') FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL", jobNumber).Scan(&apiURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+			return
+		}
+		writeDBError(w, r, err)
+		return
+	}
+	if !s.canFetchDetail(apiURL) {
+		writeError(w, r, http.StatusUnprocessableEntity, "missing_api_url")
+		return
+	}
+	body, source, err := s.fetchTender(r.Context(), jobNumber, apiURL, true)
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "tender_fetch_failed", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Tender-Source", source)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 以 testdata/pccg0v 中錄下的回應模擬 pcc.g0v.tw，不連線到外部
+type fakeG0v struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int    // 各路徑收到的請求數
+	override map[string]string // 路徑改用的錄製檔
+	status   map[string]int    // 路徑改回應的狀態碼
+}
+
+func newFakeG0v(t *testing.T) *fakeG0v {
+	t.Helper()
+	f := &fakeG0v{requests: map[string]int{}, override: map[string]string{}, status: map[string]int{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests[r.URL.Path]++
+		name, status := f.override[r.URL.Path], f.status[r.URL.Path]
+		f.mu.Unlock()
+		if status != 0 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(status)
+			return
+		}
+		if name == "" {
+			name = filepath.Base(r.URL.Path) + ".json"
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", "pccg0v", name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeG0v) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+// 各功能都使用 pcc.g0v.tw 的測試 Server
+func newG0vTestServer(t *testing.T) (*Server, *fakeG0v) {
+	t.Helper()
+	f := newFakeG0v(t)
+	s := newTestServer(t)
+	s.cfg.PCCG0v = PCCG0vConfig{URL: f.URL, Interval: Duration{time.Millisecond}, Detail: pccSourceG0v, Autofill: pccSourceG0v, Awards: pccSourceG0v}
+	s.g0v = newG0vClient(s.cfg.PCCG0v)
+	return s, f
+}
+
+func TestG0vSearchByJobExactMatch(t *testing.T) {
+	_, f := newG0vTestServer(t)
+	c := newG0vClient(PCCG0vConfig{URL: f.URL, Interval: Duration{time.Millisecond}})
+	records, err := c.searchByJob(context.Background(), "1140101A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].JobNumber != "1140101A" || records[0].Brief.Title != "道路養護工程" {
+		t.Fatalf("records = %+v", records)
+	}
+}
+
+func TestG0vResolveTenderURL(t *testing.T) {
+	_, f := newG0vTestServer(t)
+	c := newG0vClient(PCCG0vConfig{URL: f.URL, Interval: Duration{time.Millisecond}})
+	ctx := context.Background()
+
+	// api_url 帶有 unit_id 時不必搜尋
+	got, err := c.resolveTenderURL(ctx, "1140101A", "https://web.pcc.gov.tw/api?unit_id=3.80.9&job_number=1140101A")
+	if err != nil || got != f.URL+"/tender?job_number=1140101A&unit_id=3.80.9" {
+		t.Fatalf("resolveTenderURL = %q, %v", got, err)
+	}
+	if n := f.count("/searchbyjob"); n != 0 {
+		t.Errorf("有 unit_id 時仍搜尋了 %d 次", n)
+	}
+
+	got, err = c.resolveTenderURL(ctx, "1140101A", "")
+	if err != nil || got != f.URL+"/tender?job_number=1140101A&unit_id=3.80.1" {
+		t.Fatalf("resolveTenderURL = %q, %v", got, err)
+	}
+	if _, err := c.resolveTenderURL(ctx, "9999999", ""); !errors.Is(err, errG0vNotFound) {
+		t.Errorf("查無案號: err = %v, want errG0vNotFound", err)
+	}
+}
+
+func TestG0vUpstreamErrors(t *testing.T) {
+	_, f := newG0vTestServer(t)
+	c := newG0vClient(PCCG0vConfig{URL: f.URL, Interval: Duration{time.Millisecond}})
+	ctx := context.Background()
+
+	f.override["/searchbyjob"] = "error.json"
+	var payloadErr *upstreamPayloadError
+	if _, err := c.searchByJob(ctx, "1140101A"); !errors.As(err, &payloadErr) {
+		t.Errorf("以 200 回傳的錯誤: err = %v, want upstreamPayloadError", err)
+	}
+
+	f.status["/listbyunit"] = http.StatusTooManyRequests
+	var statusErr *upstreamStatusError
+	if _, err := c.unit(ctx, "3.80.1"); !errors.As(err, &statusErr) || statusErr.code != http.StatusTooManyRequests {
+		t.Fatalf("429: err = %v", err)
+	}
+	// 限流時暫停後續請求（Retry-After 1 秒）
+	c.mu.Lock()
+	wait := time.Until(c.next)
+	c.mu.Unlock()
+	if wait < 500*time.Millisecond {
+		t.Errorf("429 之後只暫停 %s", wait)
+	}
+}
+
+func TestG0vAutofillOnAdd(t *testing.T) {
+	s, f := newG0vTestServer(t)
+	h := s.routes()
+
+	_, resp := postBookmark(t, h, `{"job_number":"1140101A"}`)
+	b := resp.Bookmark
+	if b.Title != "道路養護工程" || b.UnitName != "臺北市政府工務局" || b.Type != "公開招標公告" || b.Date != 20250102 {
+		t.Fatalf("補齊的欄位錯誤: %+v", b)
+	}
+	if b.APIURL == "" || b.URL != "https://web.pcc.gov.tw/tps/QueryTender/query/searchTenderDetail?pkPmsMain=AAA" {
+		t.Errorf("url=%q api_url=%q", b.URL, b.APIURL)
+	}
+	// 搜尋結果沒有機關名稱，以 listbyunit 補齊
+	if f.count("/listbyunit") != 1 {
+		t.Errorf("listbyunit 請求 %d 次, want 1", f.count("/listbyunit"))
+	}
+
+	// 已有的欄位不被覆寫，完整時不查詢
+	_, resp = postBookmark(t, h, `{"job_number":"1140101B","title":"自訂名稱","unit_name":"自訂機關"}`)
+	if resp.Bookmark.Title != "自訂名稱" || f.count("/searchbyjob") != 1 {
+		t.Errorf("title=%q searchbyjob=%d", resp.Bookmark.Title, f.count("/searchbyjob"))
+	}
+}
+
+func TestG0vDetailCached(t *testing.T) {
+	s, f := newG0vTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"1140101A","title":"道路養護工程","unit_name":"臺北市政府工務局"}`)
+
+	for i, wantSource := range []string{tenderFetched, tenderFromCache} {
+		w := serve(t, h, "GET", "/api/bookmarks/1140101A/detail", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("第 %d 次 status = %d: %s", i+1, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Tender-Source"); got != wantSource {
+			t.Errorf("第 %d 次 X-Tender-Source = %q, want %q", i+1, got, wantSource)
+		}
+		if resp := decodeBody(t, w); resp["unit_name"] != "臺北市政府工務局" {
+			t.Errorf("詳細資料錯誤: %v", resp)
+		}
+	}
+	if n := f.count("/tender"); n != 1 {
+		t.Errorf("tender 請求 %d 次, want 1（第二次應使用 tender_cache）", n)
+	}
+	var cached int
+	s.db.QueryRow("SELECT COUNT(*) FROM tender_cache WHERE job_number = '1140101A'").Scan(&cached)
+	if cached != 1 {
+		t.Errorf("tender_cache 沒有寫入")
+	}
+}
+
+func TestG0vCheckAwards(t *testing.T) {
+	s, _ := newG0vTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"1140101A","title":"道路養護工程","unit_name":"臺北市政府工務局"}`)
+
+	if err := s.checkAwards(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	resp := decodeBody(t, serve(t, h, "GET", "/api/awards", ""))
+	items, _ := resp["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("awards = %v", resp)
+	}
+	award := items[0].(map[string]interface{})
+	if award["amount"] != "12,500,000元" || award["date"] != float64(20250301) || award["source"] != pccSourceG0v {
+		t.Errorf("award = %v", award)
+	}
+	if companies, _ := award["companies"].([]interface{}); len(companies) != 1 || companies[0] != "大道營造股份有限公司" {
+		t.Errorf("companies = %v", award["companies"])
+	}
+}
+
+func TestParseTenderAward(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+		date int
+	}{
+		{"no award", `{"records":[{"date":20250102,"brief":{"type":"公開招標公告"}}]}`, false, 0},
+		{"award", `{"records":[{"date":20250102,"brief":{"type":"公開招標公告"}},{"date":20250301,"brief":{"type":"決標公告"}}]}`, true, 20250301},
+		{"failed award ignored", `{"records":[{"date":20250301,"brief":{"type":"無法決標公告"}}]}`, false, 0},
+		{"latest award", `{"records":[{"date":20250301,"brief":{"type":"決標公告"}},{"date":20250401,"brief":{"type":"更正決標公告"}}]}`, true, 20250401},
+		{"invalid json", `{`, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := parseTenderAward([]byte(tt.body))
+			if ok != tt.ok || (ok && a.Date != tt.date) {
+				t.Errorf("parseTenderAward = %+v, %v", a, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PruneReport 清理結果
+type PruneReport struct {
+	DryRun      bool             `json:"dry_run"`
+	Files       []string         `json:"files"`
+	FileBytes   int64            `json:"file_bytes"`
+	TenderCache int64            `json:"tender_cache"`
+	Tables      map[string]int64 `json:"tables"`
+
+	// 沒有任何附件參照的附件檔案
+	AttachmentFiles int   `json:"attachment_files"`
+	AttachmentBytes int64 `json:"attachment_bytes"`
+}
+
+// 清除已不屬於任何書籤的資料：下載的標書檔、過期的標案快取，以及 orphanChecks 登記的子表資料
+// 現有與已封存的書籤（含日後的垃圾桶）都算存在，其資料不會被動到；
+// 清理開始後才修改的檔案也略過，避免刪掉剛加入書籤時下載的檔案
+func (s *Server) prune(ctx context.Context, dryRun bool) (*PruneReport, error) {
+	start := time.Now()
+	report := &PruneReport{DryRun: dryRun, Files: []string{}, Tables: map[string]int64{}}
+
+	live := map[string]bool{}
+	for _, table := range []string{"bookmarks", "bookmarks_archive"} {
+		rows, err := s.db.QueryContext(ctx, "SELECT job_number FROM "+table)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var jn string
+			if err := rows.Scan(&jn); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			live[tenderFileName(jn)] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 下載的標書檔
+	entries, err := os.ReadDir(s.tendersDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") || live[name] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(start) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filepath.Join(s.tendersDir(), name)); err != nil {
+				log.Printf("刪除檔案 %s 失敗: %v", name, err)
+				continue
+			}
+		}
+		report.Files = append(report.Files, name)
+		report.FileBytes += info.Size()
+	}
+
+	// 資料表：不屬於書籤且已過期的標案快取（未過期的可能是加入書籤前的查詢結果），以及登記的子表
+	cacheWhere := "job_number NOT IN (SELECT job_number FROM bookmarks) AND job_number NOT IN (SELECT job_number FROM bookmarks_archive) AND fetched_at < ?"
+	cacheCutoff := dbTime(time.Now().Add(-s.cfg.TenderCacheTTL.Duration))
+	if dryRun {
+		if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tender_cache WHERE "+cacheWhere, cacheCutoff).Scan(&report.TenderCache); err != nil {
+			return nil, err
+		}
+		for _, oc := range orphanChecks {
+			var n int64
+			if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+oc.table+" WHERE "+oc.where()).Scan(&n); err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				report.Tables[oc.table] = n
+			}
+		}
+		// 試算時孤兒資料列尚未刪除，只計入目前已沒被參照的檔案
+		files, err := s.scanAttachmentFiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		report.AttachmentFiles, report.AttachmentBytes = len(files.OrphanFiles), files.OrphanBytes
+		return report, nil
+	}
+
+	err = s.db.withTx(ctx, func(tx *Tx) error {
+		result, err := tx.Exec("DELETE FROM tender_cache WHERE "+cacheWhere, cacheCutoff)
+		if err != nil {
+			return err
+		}
+		report.TenderCache, _ = result.RowsAffected()
+		for _, oc := range orphanChecks {
+			result, err := tx.Exec("DELETE FROM " + oc.table + " WHERE " + oc.where())
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				report.Tables[oc.table] = n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 刪除書籤時附件資料列隨之刪除，檔案在此移除（已刪除上面的孤兒資料列後再比對）
+	files, err := s.scanAttachmentFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.AttachmentFiles = s.removeAttachmentFiles(files.OrphanFiles)
+	report.AttachmentBytes = files.OrphanBytes
+
+	if len(report.Files) > 0 || report.TenderCache > 0 || len(report.Tables) > 0 || report.AttachmentFiles > 0 {
+		log.Printf("清理完成: %d 個檔案（%d bytes）、%d 筆標案快取、子表 %v、%d 個附件檔案（%d bytes）",
+			len(report.Files), report.FileBytes, report.TenderCache, report.Tables, report.AttachmentFiles, report.AttachmentBytes)
+	}
+	return report, nil
+}
+
+// 手動清理，?dry_run=true 只回報會刪除的項目
+func (s *Server) pruneHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := s.prune(r.Context(), r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 標案詳細資料與書籤的比對
+//
+// 機關重複使用案號或書籤的 api_url 填錯時，下載到的詳細資料會屬於另一個標案。
+// 下載成功後以 tenderMismatches 比對案號、標案名稱與機關名稱，不一致時在下載結果中附上 mismatch
+// 並寫入 tender_mismatch 事件；完整性檢查也會比對 tender_cache 中的資料。
+// POST /api/bookmarks/{job_number}/reconcile 以詳細資料為準更新書籤的標案名稱與機關名稱；
+// 案號不一致時只回報，不自動改名（改名會牽動附件、下載檔與事件）。
+
+// 事件動作：下載的詳細資料與書籤不一致
+const actionTenderMismatch = "tender_mismatch"
+
+// 詳細資料中用來比對的欄位
+type tenderSummary struct {
+	JobNumber string
+	Title     string
+	UnitName  string
+}
+
+// 由 PCC API 回應取出案號、標案名稱與機關名稱
+// records 依公告先後排列，取最後一筆；欄位不存在時為空字串
+func parseTenderSummary(body []byte) (tenderSummary, error) {
+	var payload struct {
+		UnitName string `json:"unit_name"`
+		Records  []struct {
+			JobNumber string `json:"job_number"`
+			UnitName  string `json:"unit_name"`
+			Brief     struct {
+				Title string `json:"title"`
+			} `json:"brief"`
+			Detail map[string]interface{} `json:"detail"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return tenderSummary{}, err
+	}
+	if len(payload.Records) == 0 {
+		return tenderSummary{}, errors.New("缺少 records")
+	}
+	rec := payload.Records[len(payload.Records)-1]
+	detail := func(key string) string {
+		v, _ := rec.Detail[key].(string)
+		return v
+	}
+	return tenderSummary{
+		JobNumber: rec.JobNumber,
+		Title:     firstNonEmpty(rec.Brief.Title, detail("採購資料:標案名稱")),
+		UnitName:  firstNonEmpty(rec.UnitName, payload.UnitName, detail("機關資料:機關名稱")),
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// FieldDiff 書籤與詳細資料不同的欄位
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Bookmark string `json:"bookmark"`
+	Tender   string `json:"tender"`
+}
+
+// 比對書籤與詳細資料，回傳不同的欄位；詳細資料沒有的欄位不比對
+// includeEmpty 為 false 時書籤的欄位為空也不算不一致（舊版用戶端常沒有填機關名稱），
+// 更新書籤時則設為 true，一併補上空白的欄位
+func tenderDiffs(jobNumber, title, unitName string, t tenderSummary, includeEmpty bool) []FieldDiff {
+	var diffs []FieldDiff
+	compare := func(field, ours, theirs string, equal func(a, b string) bool) {
+		if theirs == "" || (ours == "" && !includeEmpty) || equal(ours, theirs) {
+			return
+		}
+		diffs = append(diffs, FieldDiff{Field: field, Bookmark: ours, Tender: theirs})
+	}
+	sameText := func(a, b string) bool {
+		return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+	}
+	compare("job_number", jobNumber, t.JobNumber, func(a, b string) bool {
+		return strings.EqualFold(canonicalJobNumber(a), canonicalJobNumber(b))
+	})
+	compare("title", title, t.Title, sameText)
+	compare("unit_name", unitName, t.UnitName, sameText)
+	return diffs
+}
+
+// 比對下載的詳細資料，不一致時寫入事件並回傳差異；詳細資料無法解析時不比對
+func (s *Server) tenderMismatches(r *http.Request, jobNumber, title, unitName, apiURL string, body []byte) []FieldDiff {
+	summary, err := parseTenderSummary(body)
+	if err != nil {
+		return nil
+	}
+	diffs := tenderDiffs(jobNumber, title, unitName, summary, false)
+	if len(diffs) == 0 {
+		return nil
+	}
+	err = s.db.withTx(context.Background(), func(tx *Tx) error {
+		return writeEvent(tx, requestActor(r), entityBookmark, jobNumber, actionTenderMismatch, map[string]interface{}{
+			"api_url":     apiURL,
+			"differences": diffs,
+		})
+	})
+	if err != nil {
+		log.Println("寫入不一致事件失敗:", err)
+	} else {
+		s.broker.notify()
+	}
+	return diffs
+}
+
+// POST /api/bookmarks/{job_number}/reconcile：以詳細資料為準更新書籤的標案名稱與機關名稱
+// 詳細資料優先使用 tender_cache，快取過期時重新下載；?dry_run=true 只回傳差異不更新
+func (s *Server) reconcileBookmark(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.PathValue("job_number"))
+	if !ok {
+		return
+	}
+
+	var title, unitName, apiURL string
+	err := s.db.QueryRowContext(r.Context(), "SELECT title, COALESCE(unit_name, ''), COALESCE(api_url, '') FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL", jobNumber).
+		Scan(&title, &unitName, &apiURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+			return
+		}
+		writeDBError(w, r, err)
+		return
+	}
+	if !s.canFetchDetail(apiURL) {
+		writeError(w, r, http.StatusUnprocessableEntity, "missing_api_url")
+		return
+	}
+
+	body, _, err := s.fetchTender(r.Context(), jobNumber, apiURL, false)
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "tender_fetch_failed", err.Error())
+		return
+	}
+	summary, err := parseTenderSummary(body)
+	if err != nil {
+		writeError(w, r, http.StatusBadGateway, "tender_fetch_failed", err.Error())
+		return
+	}
+
+	diffs := tenderDiffs(jobNumber, title, unitName, summary, true)
+	var updates []FieldDiff // 會更新的欄位；案號只回報
+	for _, d := range diffs {
+		if d.Field != "job_number" {
+			updates = append(updates, d)
+		}
+	}
+	resp := map[string]interface{}{
+		"job_number":  jobNumber,
+		"differences": append(make([]FieldDiff, 0), diffs...),
+		"applied":     false,
+	}
+	if len(diffs) > len(updates) {
+		resp["job_number_mismatch"] = true
+	}
+	if len(updates) == 0 || r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	var updated *Bookmark
+	err = s.db.withTx(r.Context(), func(tx *Tx) error {
+		sets := make([]string, 0, len(updates))
+		args := make([]interface{}, 0, len(updates)+2)
+		changed := make([]string, 0, len(updates))
+		for _, d := range updates {
+			sets = append(sets, d.Field+" = ?")
+			args = append(args, d.Tender)
+			changed = append(changed, fmt.Sprintf("%s: %q → %q", d.Field, d.Bookmark, d.Tender))
+		}
+		args = append(args, dbNow(), jobNumber)
+		result, err := tx.Exec("UPDATE bookmarks SET "+strings.Join(sets, ", ")+", version = version + 1, updated_at = ? WHERE job_number = ? AND deleted_at IS NULL", args...)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errBookmarkNotFound
+		}
+		// data 中的標案名稱與機關一併更新，避免與欄位不一致
+		cols := mirroredColumns{Title: title, UnitName: unitName}
+		fields := make([]string, 0, len(updates))
+		for _, d := range updates {
+			cols.set(d.Field, d.Tender)
+			fields = append(fields, d.Field)
+		}
+		if err := patchBookmarkData(tx, jobNumber, &cols, fields...); err != nil {
+			return err
+		}
+		if updated, err = bookmarkSnapshot(tx, jobNumber); err != nil {
+			return err
+		}
+		if err := writeEvent(tx, requestActor(r), entityBookmark, jobNumber, actionUpdate, updated); err != nil {
+			return err
+		}
+		return writeAudit(tx, newAuditEntry(r, "依標案詳細資料更新書籤（"+strings.Join(changed, "、")+"）", jobNumber))
+	})
+	if errors.Is(err, errBookmarkNotFound) {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	s.markChanged()
+	updated.MatchedCategories, updated.MatchedKeywords = bookmarkTerms(updated.Data)
+	resp["applied"] = true
+	resp["bookmark"] = updated
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// 完整性檢查：tender_cache 中的詳細資料與書籤不一致的書籤 id
+func (s *Server) tenderMismatchIDs(ctx context.Context) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.id, b.job_number, b.title, COALESCE(b.unit_name, ''), c.body
+		FROM bookmarks b JOIN tender_cache c ON c.job_number = b.job_number
+		ORDER BY b.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var jobNumber, title, unitName, body string
+		if err := rows.Scan(&id, &jobNumber, &title, &unitName, &body); err != nil {
+			return nil, err
+		}
+		summary, err := parseTenderSummary([]byte(body))
+		if err != nil {
+			continue
+		}
+		if len(tenderDiffs(jobNumber, title, unitName, summary, false)) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 請求內容的 JSON 解析
+//
+// 所有以 JSON 為請求內容的端點都經過 decodeJSONBody：不接受未知欄位、重複的鍵與 JSON 之後多餘的內容，
+// 拼錯的欄位（例如 prioirty）不會被默默忽略。解析失敗時以 writeDecodeError 回傳 422，
+// 並附上出錯的欄位與位置（位元組偏移）。
+
+// 請求內容解析錯誤
+type bodyError struct {
+	Field  string // 出錯的欄位，無法判斷時為空字串
+	Offset int64  // 出錯位置（位元組偏移）
+	Reason string
+}
+
+func (e *bodyError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("欄位 %s: %s（位置 %d）", e.Field, e.Reason, e.Offset)
+	}
+	return fmt.Sprintf("%s（位置 %d）", e.Reason, e.Offset)
+}
+
+// 解析請求內容到 v
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return &bodyError{Reason: "讀取請求內容失敗: " + err.Error()}
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &bodyError{Reason: "請求內容為空"}
+	}
+	if err := checkDuplicateKeys(body); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		offset := dec.InputOffset()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			offset = int64(len(body))
+		}
+		return translateDecodeError(err, offset)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &bodyError{Offset: dec.InputOffset(), Reason: "JSON 之後有多餘的內容"}
+	}
+	return nil
+}
+
+// 將 encoding/json 的錯誤轉為 bodyError
+func translateDecodeError(err error, offset int64) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &bodyError{Offset: syntaxErr.Offset, Reason: "JSON 格式錯誤: " + syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		return &bodyError{Field: typeErr.Field, Offset: typeErr.Offset, Reason: fmt.Sprintf("型別錯誤，應為 %s，收到 %s", typeErr.Type, typeErr.Value)}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &bodyError{Offset: offset, Reason: "JSON 不完整"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &bodyError{Field: field, Offset: offset, Reason: "不支援的欄位"}
+	}
+	return &bodyError{Offset: offset, Reason: err.Error()}
+}
+
+// 檢查物件中是否有重複的鍵（encoding/json 會以最後一個值為準，不會報錯）
+// 格式錯誤不在這裡回報，留給 Decode 產生較完整的訊息
+func checkDuplicateKeys(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var be *bodyError
+	if err := scanJSONValue(dec, ""); errors.As(err, &be) {
+		return be
+	}
+	return nil
+}
+
+func scanJSONValue(dec *json.Decoder, path string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		keys := map[string]bool{}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := t.(string)
+			field := key
+			if path != "" {
+				field = path + "." + key
+			}
+			if keys[key] {
+				return &bodyError{Field: field, Offset: dec.InputOffset(), Reason: "重複的欄位"}
+			}
+			keys[key] = true
+			if err := scanJSONValue(dec, field); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := scanJSONValue(dec, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	_, err = dec.Token() // 結尾的 } 或 ]
+	return err
+}
+
+// 輸出請求內容解析錯誤（422），附上欄位與位置
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	resp := map[string]interface{}{
+		"success": false,
+		"error":   "invalid_json",
+		"message": localize(requestLanguage(r), "invalid_json", err.Error()),
+	}
+	var be *bodyError
+	if errors.As(err, &be) {
+		resp["field"] = be.Field
+		resp["offset"] = be.Offset
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, resp)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 各 JSON 端點以 422 拒絕未知欄位、重複的鍵、多餘的內容、型別錯誤與格式錯誤，並指出欄位與位置
+func TestStrictJSONBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		field  string // 預期的 field，"-" 表示不檢查
+		reason string // message 中應包含的文字
+	}{
+		{"add unknown field", "POST", "/api/bookmarks", `{"job_number":"A001","prioirty":3}`, "prioirty", "不支援的欄位"},
+		{"add duplicate key", "POST", "/api/bookmarks", `{"job_number":"A001","priority":1,"priority":5}`, "priority", "重複的欄位"},
+		{"add trailing garbage", "POST", "/api/bookmarks", `{"job_number":"A001"} {"job_number":"A002"}`, "", "多餘的內容"},
+		{"add wrong type", "POST", "/api/bookmarks", `{"job_number":"A001","priority":"high"}`, "priority", "型別錯誤"},
+		{"add syntax error", "POST", "/api/bookmarks", `{"job_number":"A001",}`, "", "JSON 格式錯誤"},
+		{"add truncated", "POST", "/api/bookmarks", `{"job_number":"A001"`, "", "JSON 不完整"},
+		{"add empty", "POST", "/api/bookmarks", ` `, "", "請求內容為空"},
+		{"update unknown field", "PUT", "/api/bookmarks", `{"job_number":"B001","noet":"x"}`, "noet", "不支援的欄位"},
+		{"update nested duplicate", "PUT", "/api/bookmarks", `{"job_number":"B001","data":{"a":1,"a":2}}`, "data.a", "重複的欄位"},
+		{"bulk trailing garbage", "POST", "/api/bookmarks/bulk", `[{"job_number":"A001"}] x`, "", "多餘的內容"},
+		{"bulk delete duplicate", "DELETE", "/api/bookmarks/bulk", `{"job_numbers":["B001"],"job_numbers":[]}`, "job_numbers", "重複的欄位"},
+		{"bulk delete unknown field", "DELETE", "/api/bookmarks/bulk", `{"job_number":["B001"]}`, "job_number", "不支援的欄位"},
+		{"import duplicate in record", "POST", "/api/bookmarks/import", `{"bookmarks":[{"job_number":"A001","job_number":"A002"}]}`, "bookmarks[0].job_number", "重複的欄位"},
+	}
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"B001"}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, tt.method, tt.target, tt.body)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want 422: %s", w.Code, w.Body.String())
+			}
+			resp := decodeBody(t, w)
+			if resp["error"] != "invalid_json" || resp["field"] != tt.field {
+				t.Errorf("error = %v, field = %v, want field %q", resp["error"], resp["field"], tt.field)
+			}
+			if !strings.Contains(resp["message"].(string), tt.reason) {
+				t.Errorf("message = %v, want %q", resp["message"], tt.reason)
+			}
+			if _, ok := resp["offset"].(float64); !ok {
+				t.Errorf("回應缺少 offset: %v", resp)
+			}
+		})
+	}
+
+	// 被拒絕的請求不會寫入任何書籤
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")); resp["bookmarked"] != false {
+		t.Errorf("422 的請求寫入了書籤: %v", resp)
+	}
+}
+
+// 批次新增與匯入逐筆解析，拼錯欄位的項目回報錯誤且不寫入，其他項目照常處理
+func TestStrictJSONPerItem(t *testing.T) {
+	h := newTestServer(t).routes()
+
+	resp := decodeBody(t, serve(t, h, "POST", "/api/bookmarks/bulk", `[{"job_number":"A001","prioirty":3},{"job_number":"A002","priority":3}]`))
+	results := resp["results"].([]interface{})
+	if resp["created"] != float64(1) || resp["failed"] != float64(1) ||
+		results[0].(map[string]interface{})["error"] != "invalid_json" ||
+		!strings.Contains(results[0].(map[string]interface{})["message"].(string), "prioirty") {
+		t.Fatalf("bulk = %v", resp)
+	}
+
+	resp = decodeBody(t, serve(t, h, "POST", "/api/bookmarks/import", `[{"job_number":"A003","prioirty":3}]`))
+	errs := resp["errors"].([]interface{})
+	if resp["imported"] != float64(0) || len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), "prioirty") {
+		t.Fatalf("import = %v", resp)
+	}
+
+	for jn, want := range map[string]bool{"A001": false, "A002": true, "A003": false} {
+		if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number="+jn, "")); resp["bookmarked"] != want {
+			t.Errorf("%s bookmarked = %v, want %v", jn, resp["bookmarked"], want)
+		}
+	}
+}
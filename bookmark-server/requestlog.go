@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 請求紀錄：每個請求結束後寫一行日誌（方法、路徑、狀態碼、耗時與請求編號）
+// 事件串流與 WebSocket 在連線結束時才記錄，耗時為連線的時間
+
+// 記錄狀態碼的 ResponseWriter，保留 Flusher 與 Hijacker，事件串流與 WebSocket 才能照常運作
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("連線不支援 Hijack")
+	}
+	// WebSocket 升級後不會再呼叫 WriteHeader
+	sw.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// 供 http.ResponseController 取得原本的 ResponseWriter
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// 請求紀錄中介軟體，包在所有路由之外
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			status := sw.status
+			if status == 0 {
+				// 沒有寫出任何內容時 net/http 回應 200
+				status = http.StatusOK
+			}
+			log.Printf("%s %s %d %s [%s]", r.Method, r.URL.Path, status,
+				time.Since(start).Round(time.Microsecond), w.Header().Get("X-Request-ID"))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
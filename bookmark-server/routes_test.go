@@ -0,0 +1,239 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 每個路由允許的方法；新增路由時一併加入，TestRouteTableComplete 會檢查是否遺漏
+var routeMethods = map[string][]string{
+	"/api/bookmarks":                        {"GET", "POST", "PUT", "DELETE"},
+	"/api/events/stream":                    {"GET"},
+	"/api/ws":                               {"GET"},
+	"/api/bookmarks/list":                   {"GET"},
+	"/api/bookmarks/history":                {"GET"},
+	"/api/bookmarks/check":                  {"GET"},
+	"/api/bookmarks/download":               {"GET", "POST", "DELETE"},
+	"/api/bookmarks/download/status":        {"GET"},
+	"/api/bookmarks/download/archive":       {"GET"},
+	"/api/bookmarks/download/file":          {"GET"},
+	"/api/bookmarks/export":                 {"GET"},
+	"/api/tags":                             {"GET", "DELETE"},
+	"/api/bookmarks/bulk":                   {"POST", "DELETE"},
+	"/api/bookmarks/refresh":                {"POST"},
+	"/api/bookmarks/changes":                {"GET", "PUT"},
+	"/api/bookmarks/import":                 {"POST"},
+	"/api/version":                          {"GET"},
+	"/api/health":                           {"GET"},
+	"/api/files":                            {"GET"},
+	"/api/events":                           {"GET"},
+	"/api/admin/audit":                      {"GET"},
+	"/api/admin/metrics":                    {"GET"},
+	"/api/admin/maintenance":                {"POST"},
+	"/api/admin/integrity":                  {"GET", "POST"},
+	"/api/admin/invalid-tenders":            {"GET"},
+	"/api/admin/job-number-collisions":      {"GET"},
+	"/api/admin/invalid-data":               {"GET"},
+	"/api/admin/data-drift":                 {"GET"},
+	"/api/admin/invalid-dates":              {"GET"},
+	"/api/admin/archive-year":               {"POST"},
+	"/api/bookmarks/{job_number}/reconcile": {"POST"},
+	"/api/bookmarks/{job_number}/detail":    {"GET"},
+	"/api/bookmarks/calendar":               {"GET"},
+	"/api/bookmarks/search":                 {"GET"},
+	"/api/bookmarks/feed.json":              {"GET"},
+	"/api/shares":                           {"GET", "POST"},
+	"/api/shares/{name}":                    {"GET", "DELETE"},
+	"/share/{token}":                        {"GET"},
+	"/api/tenders":                          {"GET"},
+	"/api/tenders/stats":                    {"GET"},
+	"/api/lookup":                           {"GET"},
+	"/api/awards":                           {"GET"},
+	"/api/bookmarks/stats":                  {"GET"},
+	"/api/attachments":                      {"GET"},
+	"/api/bookmarks/attachments":            {"GET", "POST", "DELETE"},
+	"/api/bookmarks/attachments/download":   {"GET"},
+	"/api/bookmarks/trash":                  {"GET"},
+	"/api/bookmarks/restore":                {"POST"},
+	"/api/bookmarks/purge":                  {"DELETE"},
+	"/api/admin/dump":                       {"GET"},
+	"/api/admin/prune":                      {"POST"},
+	"/api/admin/years":                      {"POST"},
+	"/api/admin/tender-cache":               {"GET", "DELETE"},
+	"/api/reports/weekly":                   {"GET"},
+	"/api/reports/weekly/run":               {"POST"},
+	"/api/admin/email-digest":               {"GET", "POST"},
+	"/api/admin/backups":                    {"GET", "POST"},
+	"/api/ingest/status":                    {"GET"},
+	"/api/ingest/run":                       {"POST"},
+	"/api/watchlist":                        {"GET"},
+	"/api/watchlist/import":                 {"POST"},
+	"/api/sync":                             {"GET"},
+	"/api/sync/pull":                        {"GET"},
+	"/api/sync/push":                        {"POST"},
+	"/api/sync/run":                         {"POST"},
+	"/api/webhooks":                         {"GET", "POST"},
+	"/api/webhooks/{id}":                    {"GET", "PUT", "DELETE"},
+	"/api/webhooks/{id}/deliveries":         {"GET"},
+	"/api/webhooks/{id}/deliveries/{delivery_id}/redeliver": {"POST"},
+	"/api/notifications":      {"GET"},
+	"/api/notifications/log":  {"GET"},
+	"/api/notifications/test": {"POST"},
+}
+
+// 需要額外設定才會登記的路由
+var optionalRoutes = map[string]bool{
+	"/calendar/{file}": true, // calendar.tokens
+}
+
+func TestRouteTableComplete(t *testing.T) {
+	src, err := os.ReadFile("server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := map[string]bool{}
+	for _, m := range regexp.MustCompile(`mux\.HandleFunc\("([^"]+)"`).FindAllStringSubmatch(string(src), -1) {
+		if path := m[1]; path != "/api/" && !optionalRoutes[path] {
+			registered[path] = true
+		}
+	}
+	for path := range registered {
+		if _, ok := routeMethods[path]; !ok {
+			t.Errorf("routeMethods 缺少 %s", path)
+		}
+	}
+	for path := range routeMethods {
+		if !registered[path] {
+			t.Errorf("routeMethods 中的 %s 沒有登記", path)
+		}
+	}
+}
+
+// 路由樣式中的 {name} 換成範例值
+var routeParam = regexp.MustCompile(`\{[^}]+\}`)
+
+func TestRouteMethods(t *testing.T) {
+	h := newTestServer(t).routes()
+	verbs := []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+	paths := make([]string, 0, len(routeMethods))
+	for path := range routeMethods {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		allowed := map[string]bool{}
+		for _, m := range routeMethods[path] {
+			allowed[m] = true
+		}
+		if allowed["GET"] {
+			allowed["HEAD"] = true
+		}
+		target := routeParam.ReplaceAllString(path, "1")
+
+		for _, verb := range verbs {
+			t.Run(verb+" "+path, func(t *testing.T) {
+				if allowed[verb] {
+					// 允許的方法以預檢請求確認，不實際執行處理函式
+					req := httptest.NewRequest("OPTIONS", target, nil)
+					req.Header.Set("Origin", "http://example.com")
+					req.Header.Set("Access-Control-Request-Method", verb)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, req)
+					if w.Code != http.StatusNoContent {
+						t.Errorf("預檢 %s 回應 %d, want 204", verb, w.Code)
+					}
+					return
+				}
+				w := serve(t, h, verb, target, "")
+				if w.Code != http.StatusMethodNotAllowed {
+					t.Fatalf("status = %d, want 405: %s", w.Code, w.Body.String())
+				}
+				allow := w.Header().Get("Allow")
+				for m := range allowed {
+					if !strings.Contains(allow, m) {
+						t.Errorf("Allow = %q, 缺少 %s", allow, m)
+					}
+				}
+				if verb != "HEAD" {
+					if resp := decodeBody(t, w); resp["error"] != "method_not_allowed" {
+						t.Errorf("error = %v, want method_not_allowed", resp["error"])
+					}
+				}
+			})
+		}
+	}
+}
+
+// 瀏覽器對每個路由的預檢請求：允許的方法列出與路由實際允許的方法相同，不允許的方法與不存在的路由回傳 JSON 錯誤
+func TestPreflightPerRoute(t *testing.T) {
+	h := newTestServer(t).routes()
+	preflight := func(target, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", target, nil)
+		req.Header.Set("Origin", "http://example.com")
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	for path, methods := range routeMethods {
+		target := routeParam.ReplaceAllString(path, "1")
+		want := map[string]bool{"OPTIONS": true}
+		for _, m := range methods {
+			want[m] = true
+		}
+		if want["GET"] {
+			want["HEAD"] = true
+		}
+
+		// /api/ 以外的頁面（例如 /share/{token}）不開放跨來源讀取
+		w := preflight(target, methods[0])
+		if w.Code != http.StatusNoContent || (strings.HasPrefix(path, "/api/") && w.Header().Get("Access-Control-Allow-Origin") != "*") {
+			t.Errorf("預檢 %s %s = %d, Allow-Origin %q", methods[0], path, w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+			continue
+		}
+		got := map[string]bool{}
+		for _, m := range strings.Split(w.Header().Get("Access-Control-Allow-Methods"), ", ") {
+			got[m] = true
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s Access-Control-Allow-Methods = %q", path, w.Header().Get("Access-Control-Allow-Methods"))
+		}
+		for m := range want {
+			if !got[m] {
+				t.Errorf("%s Access-Control-Allow-Methods = %q, 缺少 %s", path, w.Header().Get("Access-Control-Allow-Methods"), m)
+			}
+		}
+		if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Content-Type") {
+			t.Errorf("%s Access-Control-Allow-Headers = %q", path, w.Header().Get("Access-Control-Allow-Headers"))
+		}
+
+		if !want["PATCH"] {
+			w := preflight(target, "PATCH")
+			if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("預檢 PATCH %s = %d, Allow %q, Content-Type %q", path, w.Code, w.Header().Get("Allow"), w.Header().Get("Content-Type"))
+			} else if resp := decodeBody(t, w); resp["error"] != "method_not_allowed" {
+				t.Errorf("預檢 PATCH %s error = %v", path, resp["error"])
+			}
+		}
+	}
+
+	for _, target := range []string{"/api/nope", "/api/bookmarks/1/nope"} {
+		for _, w := range []*httptest.ResponseRecorder{preflight(target, "GET"), serve(t, h, "GET", target, "")} {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s = %d, want 404", target, w.Code)
+				continue
+			}
+			if resp := decodeBody(t, w); resp["error"] != "unknown_route" {
+				t.Errorf("%s error = %v", target, resp["error"])
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// 背景排程器：以固定間隔執行工作，關閉伺服器時一併停止
+type scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newScheduler() *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{ctx: ctx, cancel: cancel}
+}
+
+// 每隔 interval 執行一次 fn；interval <= 0 表示停用
+func (s *scheduler) every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+	log.Printf("排程工作 %s: 每 %s 執行一次", name, interval)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(s.ctx); err != nil {
+					log.Printf("排程工作 %s 失敗: %v", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// 停止所有排程並等待執行中的工作結束
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// 執行中的長時間工作（下載、匯入等），維護作業在有工作時拒絕執行
+var activeJobs struct {
+	sync.Mutex
+	names map[string]int
+}
+
+// 登記工作開始，回傳結束時呼叫的函式
+func beginJob(name string) func() {
+	activeJobs.Lock()
+	defer activeJobs.Unlock()
+	if activeJobs.names == nil {
+		activeJobs.names = make(map[string]int)
+	}
+	activeJobs.names[name]++
+	return func() {
+		activeJobs.Lock()
+		defer activeJobs.Unlock()
+		activeJobs.names[name]--
+		if activeJobs.names[name] == 0 {
+			delete(activeJobs.names, name)
+		}
+	}
+}
+
+// 等待執行中的工作結束，最多等 timeout，回傳仍未結束的工作
+func waitJobs(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		names := runningJobs()
+		if len(names) == 0 || time.Now().After(deadline) {
+			return names
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// 目前執行中的工作名稱
+func runningJobs() []string {
+	activeJobs.Lock()
+	defer activeJobs.Unlock()
+	names := make([]string, 0, len(activeJobs.names))
+	for name := range activeJobs.names {
+		names = append(names, name)
+	}
+	return names
+}
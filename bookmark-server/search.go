@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 全文檢索
+//
+// GET /api/bookmarks/search?q= 在標題、機關、備註與 data（標案 JSON 中的值，例如採購內容、預算金額）中搜尋，
+// 以空白分隔的多個詞必須全部出現（AND），每個詞可以出現在不同欄位，不分大小寫。
+// 每筆結果附上 matched_fields（符合的欄位）與 matched_paths（data 中符合的 JSON 路徑）。
+//
+// SQLite 以 bookmarks_fts（遷移 25，trigram 斷詞）篩選：三個字以上的詞以 MATCH 查詢索引，
+// 一、兩個字的詞（中文常見）trigram 無法索引，改以 LIKE 比對同一張表。
+// 啟用欄位加密時索引中沒有 note 與 data 的內容，PostgreSQL 或唯讀開啟的舊資料庫沒有索引，
+// 這些情況改為讀出所有書籤逐筆比對，結果相同，只是較慢。
+
+const (
+	searchDefaultLimit = 50
+	searchMaxLimit     = 500
+	searchMaxTerms     = 10
+	searchMaxPaths     = 5
+)
+
+// SearchResult 搜尋結果
+type SearchResult struct {
+	Bookmark
+	MatchedFields []string `json:"matched_fields"`
+	MatchedPaths  []string `json:"matched_paths,omitempty"`
+}
+
+// 遷移 25 建立索引時使用的內容；傾印時以此重建索引
+const bookmarksFTSPopulate = `INSERT INTO bookmarks_fts (rowid, title, unit_name, note, data)
+	SELECT id, COALESCE(title, ''), COALESCE(unit_name, ''),
+		CASE WHEN note LIKE 'enc:v1:%' THEN '' ELSE COALESCE(note, '') END,
+		CASE WHEN json_valid(data) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_tree(bookmarks.data) WHERE atom IS NOT NULL) ELSE '' END
+	FROM bookmarks`
+
+// 搜尋詞：以空白分隔、去除重複，不分大小寫
+func searchTerms(q string) []string {
+	var terms []string
+	for _, t := range strings.Fields(strings.ToLower(q)) {
+		if !containsString(terms, t) {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// 能否以 bookmarks_fts 篩選
+func (s *Server) canUseFTS(ctx context.Context) bool {
+	if s.db.dialect != dialectSQLite || s.db.cipher != nil {
+		return false
+	}
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'bookmarks_fts'").Scan(&n)
+	return err == nil && n > 0
+}
+
+// 比對一筆書籤；所有詞都出現在某個欄位時回傳結果，否則回傳 nil
+func matchBookmark(b *Bookmark, terms []string) *SearchResult {
+	fields := []struct{ name, value string }{
+		{"title", strings.ToLower(b.Title)},
+		{"unit_name", strings.ToLower(b.UnitName)},
+		{"note", strings.ToLower(b.Note)},
+	}
+	values := dataValues(b.Data)
+
+	result := &SearchResult{Bookmark: *b, MatchedFields: []string{}}
+	for _, t := range terms {
+		found := false
+		for _, f := range fields {
+			if strings.Contains(f.value, t) {
+				found = true
+				if !containsString(result.MatchedFields, f.name) {
+					result.MatchedFields = append(result.MatchedFields, f.name)
+				}
+			}
+		}
+		for _, v := range values {
+			if !strings.Contains(strings.ToLower(v.value), t) {
+				continue
+			}
+			found = true
+			if !containsString(result.MatchedFields, "data") {
+				result.MatchedFields = append(result.MatchedFields, "data")
+			}
+			if len(result.MatchedPaths) < searchMaxPaths && !containsString(result.MatchedPaths, v.path) {
+				result.MatchedPaths = append(result.MatchedPaths, v.path)
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	// 欄位依固定順序列出
+	order := map[string]int{"title": 0, "unit_name": 1, "note": 2, "data": 3}
+	sort.Slice(result.MatchedFields, func(i, j int) bool { return order[result.MatchedFields[i]] < order[result.MatchedFields[j]] })
+	return result
+}
+
+type dataValue struct {
+	path, value string
+}
+
+// data 中所有的字串與數字，依 JSON 路徑（例如 detail.採購資料:預算金額、records[0].title）列出；無效的 JSON 沒有值
+func dataValues(data string) []dataValue {
+	if data == "" {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	var values []dataValue
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				walk(p, v[k])
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(path+"["+strconv.Itoa(i)+"]", item)
+			}
+		case string:
+			values = append(values, dataValue{path, v})
+		case json.Number:
+			values = append(values, dataValue{path, v.String()})
+		}
+	}
+	walk("", v)
+	return values
+}
+
+// GET /api/bookmarks/search?q=&limit=：全文檢索，依優先順序、更新時間排列
+func (s *Server) searchBookmarks(w http.ResponseWriter, r *http.Request) {
+	terms := searchTerms(r.URL.Query().Get("q"))
+	if len(terms) == 0 || len(terms) > searchMaxTerms {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "q")
+		return
+	}
+	limit, ok := intParam(r, "limit", searchDefaultLimit, 1, searchMaxLimit)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+		return
+	}
+
+	engine := "scan"
+	fts := s.canUseFTS(r.Context())
+	if fts {
+		engine = "fts"
+	}
+
+	// 索引只負責縮小範圍，是否符合與符合的欄位一律逐筆比對
+	results := make([]*SearchResult, 0)
+	var matched []Bookmark
+	total := 0
+	_, err := s.bookmarks.Search(r.Context(), terms, fts, func(b Bookmark) {
+		res := matchBookmark(&b, terms)
+		if res == nil {
+			return
+		}
+		total++
+		if len(results) < limit {
+			results = append(results, res)
+			matched = append(matched, b)
+		}
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if err := s.attachTerms(matched); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	for i := range results {
+		results[i].MatchedCategories, results[i].MatchedKeywords, results[i].Tags = matched[i].MatchedCategories, matched[i].MatchedKeywords, matched[i].Tags
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":  terms,
+		"engine": engine,
+		"total":  total,
+		"items":  results,
+	})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"bookmark-server/store"
+)
+
+// Server 持有設定與資料庫，所有 HTTP 處理函式都是它的方法
+// 測試時可用 newServer(cfg, ":memory:") 建立獨立的伺服器
+type Server struct {
+	cfg    Config
+	db     *DB
+	dbPath string // SQLite 資料庫檔案路徑（使用 PostgreSQL 時不使用）
+	cache  *responseCache
+
+	// 書籤表的存取（見 store 套件）
+	bookmarks *store.BookmarkStore[Bookmark]
+
+	// 資料異動計數器，每次修改成功提交後遞增
+	// 回應快取以它判斷是否失效，ETag 也以它為基礎
+	changes atomic.Uint64
+
+	backup backupState
+
+	// 背景更新統計，唯讀模式為 nil
+	stats *statsRefresher
+
+	// 以 ?year= 開啟的其他年度資料庫，所有年度共用同一份登記
+	years *yearRegistry
+
+	// 通知事件串流有新的事件，所有年度共用
+	broker *eventBroker
+
+	// 通知（LINE 等），未啟用時為 nil，所有年度共用
+	notifications *notificationHub
+
+	// 送出 webhook，唯讀模式為 nil
+	webhooks *webhookDispatcher
+
+	// pcc.g0v.tw API，所有年度共用同一個請求間隔
+	g0v *g0vClient
+}
+
+// 開啟資料庫、建立資料表並準備常用查詢
+func newServer(cfg Config, dbPath string) (*Server, error) {
+	c, err := newFieldCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	d, location, err := openDB(cfg, dbPath)
+	if err != nil {
+		return nil, err
+	}
+	d.cipher = c
+	s := &Server{
+		cfg:    cfg,
+		db:     d,
+		dbPath: dbPath,
+		cache:  newResponseCache(),
+		years:  &yearRegistry{servers: make(map[int]*Server)},
+		broker: newEventBroker(),
+		g0v:    newG0vClient(cfg.PCCG0v),
+	}
+
+	// 唯讀模式：不建立資料表也不執行遷移
+	if cfg.ReadOnly {
+		log.Println("資料庫以唯讀模式開啟:", location)
+	} else {
+		if err := initSchema(d); err != nil {
+			d.Close()
+			return nil, err
+		}
+		log.Println("資料庫初始化完成:", location)
+	}
+
+	if err := verifyEncryptionKey(d, c, cfg.ReadOnly); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := s.backfillEncryptedTerms(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := s.migrateTenderFiles(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := s.normalizeTimestamps(); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	if err := s.openBookmarkStore(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if !cfg.ReadOnly {
+		s.startStatsRefresher()
+	}
+	return s, nil
+}
+
+// 建立路由
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	handler := withRequestID(withDefaultLanguage(s.cfg.Language, mux))
+
+	// API 路由
+	// 書籤相關端點都可用 ?year= 指定年度；每個路由以 allowMethods 列出允許的方法，其他方法回傳 405
+	mux.HandleFunc("/api/bookmarks", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case "GET", "HEAD":
+				ys.conditionalBookmarks(ys.getBookmarks)(w, r)
+			case "POST":
+				ys.addBookmark(w, r)
+			case "PUT":
+				ys.updateBookmark(w, r)
+			case "DELETE":
+				ys.deleteBookmark(w, r)
+			default:
+				writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+			}
+		}
+	}))), "GET", "POST", "PUT", "DELETE")))
+
+	mux.HandleFunc("/api/events/stream", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.streamEventsHandler }), "GET")))
+	// WebSocket 的指令經由 handler 執行，套用與 REST 相同的路由與防護
+	mux.HandleFunc("/api/ws", s.corsMiddleware(allowMethods(s.csrfGuard(true, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.websocketHandler(handler) })), "GET")))
+	mux.HandleFunc("/api/bookmarks/list", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return ys.conditionalBookmarks(ys.cacheMiddleware(ys.getBookmarkList))
+	}), "GET")))
+	mux.HandleFunc("/api/bookmarks/history", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getStatusHistory }), "GET")))
+	mux.HandleFunc("/api/bookmarks/check", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.checkBookmark }), "GET")))
+	mux.HandleFunc("/api/bookmarks/download", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case "POST":
+				ys.startDownloadJob(w, r)
+			case "DELETE":
+				ys.cancelDownloadJob(w, r)
+			default:
+				ys.downloadBookmarkedTenders(w, r)
+			}
+		}
+	}))), "GET", "POST", "DELETE")))
+	mux.HandleFunc("/api/bookmarks/download/status", s.corsMiddleware(allowMethods(s.downloadJobStatus, "GET")))
+	mux.HandleFunc("/api/bookmarks/download/archive", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.downloadTenderArchive }), "GET")))
+	mux.HandleFunc("/api/bookmarks/download/file", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.downloadTenderFile }), "GET")))
+	mux.HandleFunc("/api/bookmarks/export", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.exportBookmarks }), "GET")))
+	mux.HandleFunc("/api/tags", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "DELETE" {
+				ys.deleteTag(w, r)
+				return
+			}
+			ys.listTags(w, r)
+		}
+	}))), "GET", "DELETE")))
+	mux.HandleFunc("/api/bookmarks/bulk", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "DELETE" {
+				ys.bulkDeleteBookmarks(w, r)
+				return
+			}
+			ys.bulkAddBookmarks(w, r)
+		}
+	}))), "POST", "DELETE")))
+	mux.HandleFunc("/api/bookmarks/refresh", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.refreshBookmarks }))), "POST")))
+	mux.HandleFunc("/api/bookmarks/changes", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "PUT" {
+				ys.markTenderChangesSeen(w, r)
+				return
+			}
+			ys.listTenderChanges(w, r)
+		}
+	}))), "GET", "PUT")))
+	mux.HandleFunc("/api/bookmarks/import", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.importBookmarks }))), "POST")))
+	mux.HandleFunc("/api/version", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getVersion }), "GET")))
+	mux.HandleFunc("/api/health", s.corsMiddleware(allowMethods(s.getHealth, "GET")))
+	mux.HandleFunc("/api/files", s.corsMiddleware(allowMethods(s.listDataFiles, "GET")))
+	mux.HandleFunc("/api/events", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getEvents }), "GET")))
+	mux.HandleFunc("/api/admin/audit", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getAuditLog }), "GET")))
+	mux.HandleFunc("/api/admin/metrics", s.corsMiddleware(allowMethods(s.getMetrics, "GET")))
+	mux.HandleFunc("/api/admin/maintenance", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.maintenanceHandler)), "POST")))
+	mux.HandleFunc("/api/admin/integrity", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.checkIntegrity)), "GET", "POST")))
+	mux.HandleFunc("/api/admin/invalid-tenders", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.invalidTenderFilesReport }), "GET")))
+	mux.HandleFunc("/api/admin/job-number-collisions", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.jobNumberCollisionsReport }), "GET")))
+	mux.HandleFunc("/api/admin/invalid-data", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.invalidDataReport }), "GET")))
+	mux.HandleFunc("/api/admin/data-drift", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.dataDriftReport }), "GET")))
+	mux.HandleFunc("/api/admin/invalid-dates", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.invalidDatesReport }), "GET")))
+	mux.HandleFunc("/api/admin/archive-year", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.archiveYear }))), "POST")))
+	mux.HandleFunc("/api/bookmarks/{job_number}/reconcile", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.reconcileBookmark }))), "POST")))
+	mux.HandleFunc("/api/bookmarks/{job_number}/detail", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getBookmarkDetail }), "GET")))
+	mux.HandleFunc("/api/bookmarks/calendar", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.exportCalendar }), "GET")))
+	mux.HandleFunc("/api/bookmarks/search", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.searchBookmarks }), "GET")))
+	mux.HandleFunc("/api/bookmarks/feed.json", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.bookmarkFeed }), "GET")))
+	if len(s.cfg.Calendar.Tokens) > 0 {
+		mux.HandleFunc("/calendar/{file}", allowMethods(s.calendarFeed, "GET"))
+	}
+	mux.HandleFunc("/api/shares", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.sharesHandler)), "GET", "POST")))
+	mux.HandleFunc("/api/shares/{name}", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.shareHandler)), "GET", "DELETE")))
+	mux.HandleFunc("/share/{token}", allowMethods(s.sharePage, "GET"))
+	mux.HandleFunc("/api/tenders", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getTenders }), "GET")))
+	mux.HandleFunc("/api/tenders/stats", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getTenderStats }), "GET")))
+	mux.HandleFunc("/api/lookup", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.lookupPageURL }), "GET")))
+	mux.HandleFunc("/api/awards", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getAwards }), "GET")))
+	mux.HandleFunc("/api/bookmarks/stats", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.cacheMiddleware(ys.getStats) }), "GET")))
+	mux.HandleFunc("/api/attachments", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.attachmentsHandler }), "GET")))
+	mux.HandleFunc("/api/bookmarks/attachments", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.bookmarkAttachmentsHandler }))), "GET", "POST", "DELETE")))
+	mux.HandleFunc("/api/bookmarks/attachments/download", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.downloadAttachment }), "GET")))
+	mux.HandleFunc("/api/bookmarks/trash", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.listTrash }), "GET")))
+	mux.HandleFunc("/api/bookmarks/restore", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.restoreBookmark }))), "POST")))
+	mux.HandleFunc("/api/bookmarks/purge", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.purgeTrashHandler }))), "DELETE")))
+	mux.HandleFunc("/api/admin/dump", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.dumpHandler }), "GET")))
+	mux.HandleFunc("/api/admin/years", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.createYear)), "POST")))
+	mux.HandleFunc("/api/admin/prune", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.pruneHandler }))), "POST")))
+	mux.HandleFunc("/api/admin/tender-cache", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.tenderCacheHandler)), "GET", "DELETE")))
+	mux.HandleFunc("/api/reports/weekly", s.corsMiddleware(allowMethods(s.listWeeklyReports, "GET")))
+	mux.HandleFunc("/api/reports/weekly/run", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.runWeeklyReportHandler)), "POST")))
+	mux.HandleFunc("/api/admin/email-digest", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.emailDigestHandler)), "GET", "POST")))
+	mux.HandleFunc("/api/admin/backups", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.backupsHandler)), "GET", "POST")))
+
+	mux.HandleFunc("/api/ingest/status", s.corsMiddleware(allowMethods(s.getIngestStatus, "GET")))
+	mux.HandleFunc("/api/ingest/run", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.runIngestHandler)), "POST")))
+	mux.HandleFunc("/api/watchlist", s.corsMiddleware(allowMethods(s.getWatchlist, "GET")))
+	mux.HandleFunc("/api/watchlist/import", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.importWatchlist)), "POST")))
+	mux.HandleFunc("/api/sync", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.getSyncState }), "GET")))
+	mux.HandleFunc("/api/sync/pull", s.corsMiddleware(allowMethods(s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.syncPull }), "GET")))
+	mux.HandleFunc("/api/sync/push", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.syncPush }))), "POST")))
+	mux.HandleFunc("/api/sync/run", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.yearScoped(func(ys *Server) http.HandlerFunc { return ys.runSync }))), "POST")))
+	mux.HandleFunc("/api/webhooks", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.webhooksHandler)), "GET", "POST")))
+	mux.HandleFunc("/api/webhooks/{id}", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.webhookHandler)), "GET", "PUT", "DELETE")))
+	mux.HandleFunc("/api/webhooks/{id}/deliveries", s.corsMiddleware(allowMethods(s.webhookDeliveriesHandler, "GET")))
+	mux.HandleFunc("/api/webhooks/{id}/deliveries/{delivery_id}/redeliver", s.corsMiddleware(allowMethods(s.readOnlyGuard(true, s.csrfGuard(true, s.redeliverWebhook)), "POST")))
+	mux.HandleFunc("/api/notifications", s.corsMiddleware(allowMethods(s.getNotificationLog, "GET")))
+	mux.HandleFunc("/api/notifications/log", s.corsMiddleware(allowMethods(s.getNotificationLog, "GET")))
+	mux.HandleFunc("/api/notifications/test", s.corsMiddleware(allowMethods(s.readOnlyGuard(false, s.csrfGuard(false, s.testNotification)), "POST")))
+
+	mux.HandleFunc("/api/", s.corsMiddleware(unknownRoute))
+
+	// 靜態檔案服務
+	if s.cfg.WeeklyReport.Enabled && !s.cfg.ReadOnly {
+		os.MkdirAll(s.cfg.WeeklyReport.Dir, 0755) // 第一次產生報告前目錄就要能掛載
+	}
+	registerStaticMounts(mux, s.cfg.StaticMounts)
+
+	return handler
+}
+
+// 登記背景排程工作
+func (s *Server) schedule(sched *scheduler) {
+	if !s.cfg.ReadOnly && s.cfg.MaintenanceInterval.Duration > 0 {
+		sched.every("maintenance", s.cfg.MaintenanceInterval.Duration, func(ctx context.Context) error {
+			_, err := s.runMaintenance(ctx, false)
+			return err
+		})
+	}
+	if !s.cfg.ReadOnly && s.cfg.EventRetention.Duration > 0 {
+		sched.every("event_retention", time.Hour, s.pruneEvents)
+	}
+	if !s.cfg.ReadOnly && s.cfg.PruneInterval.Duration > 0 {
+		sched.every("prune", s.cfg.PruneInterval.Duration, func(ctx context.Context) error {
+			_, err := s.prune(ctx, false)
+			return err
+		})
+	}
+	if !s.cfg.ReadOnly && s.notifications.enabled(triggerDeadline) {
+		sched.every("deadline_notifications", s.cfg.Notifications.DeadlineInterval.Duration, s.checkDeadlines)
+	}
+	if !s.cfg.ReadOnly && s.cfg.TrashRetention.Duration > 0 {
+		if err := s.purgeExpiredTrash(context.Background()); err != nil {
+			log.Printf("永久刪除垃圾桶中過期的書籤失敗: %v", err)
+		}
+		sched.every("trash_purge", 24*time.Hour, s.purgeExpiredTrash)
+	}
+	if !s.cfg.ReadOnly {
+		sched.every("webhook_retention", 24*time.Hour, s.pruneWebhookDeliveries)
+	}
+	if !s.cfg.ReadOnly && s.cfg.Ingest.Enabled {
+		sched.every("ingest", ingestCheckInterval, s.checkIngest)
+	}
+	if !s.cfg.ReadOnly && s.cfg.PCCG0v.Awards != pccSourceOff {
+		sched.every("awards", awardCheckInterval, s.checkAwards)
+	}
+	if !s.cfg.ReadOnly && s.cfg.TenderChanges.Interval.Duration > 0 {
+		sched.every("tender_changes", s.cfg.TenderChanges.Interval.Duration, s.checkTenderChanges)
+	}
+	if !s.cfg.ReadOnly && s.cfg.WeeklyReport.Enabled {
+		sched.every("weekly_report", weeklyReportCheckInterval, s.checkWeeklyReport)
+	}
+	if !s.cfg.ReadOnly && s.cfg.EmailDigest.enabled() {
+		sched.every("email_digest", digestCheckInterval, s.checkEmailDigest)
+	}
+	if s.db.dialect == dialectSQLite && !s.cfg.ReadOnly && s.dbPath != ":memory:" {
+		s.checkBackupFreshness()
+		sched.every("backup", s.cfg.BackupInterval.Duration, func(ctx context.Context) error {
+			_, err := s.runBackup(ctx)
+			return err
+		})
+	}
+}
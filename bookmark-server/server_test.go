@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 測試用的設定：預設值加上較短的下載間隔，不向外查詢
+func testConfig() Config {
+	return Config{
+		Language:            langZhTW,
+		Timezone:            "Asia/Taipei",
+		Year:                currentYear(),
+		CSRF:                true,
+		TrashRetention:      Duration{30 * 24 * time.Hour},
+		TenderCacheTTL:      Duration{6 * time.Hour},
+		TenderCacheMaxStale: Duration{7 * 24 * time.Hour},
+		DownloadInterval:    Duration{time.Millisecond},
+		DownloadMaxInterval: Duration{time.Second},
+		DownloadConcurrency: 1,
+		UploadMaxBytes:      1 << 20,
+		PCCG0v:              PCCG0vConfig{Autofill: pccSourceOff},
+	}
+}
+
+// 以記憶體資料庫建立測試用的 Server，資料根目錄指向暫存目錄
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServerDB(t, ":memory:")
+}
+
+// 以暫存目錄中的 SQLite 檔案建立測試用的 Server，測試多個連線同時寫入時使用
+func newFileTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServerDB(t, "bookmarks.db")
+}
+
+// dbPath 為 ":memory:" 以外的值時視為資料根目錄下的檔名
+func newTestServerDB(t *testing.T, dbPath string) *Server {
+	t.Helper()
+	oldRoot := dataRoot
+	dataRoot = t.TempDir()
+	t.Cleanup(func() { dataRoot = oldRoot })
+
+	cfg := testConfig()
+	if dbPath != ":memory:" {
+		dbPath = filepath.Join(dataRoot, dbPath)
+	}
+	s, err := newServer(cfg, dbPath)
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	t.Cleanup(s.close)
+	return s
+}
+
+// 送出請求並回傳回應，與 CLI 相同帶上 X-Requested-With，不含 Origin 與 Referer
+func serve(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(csrfHeader, "test")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// 解析 JSON 物件回應
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("回應不是 JSON 物件: %v\n%s", err, w.Body.String())
+	}
+	return v
+}
+
+// 新增一筆書籤，失敗時中止測試
+func addTestBookmark(t *testing.T, h http.Handler, body string) map[string]interface{} {
+	t.Helper()
+	w := serve(t, h, "POST", "/api/bookmarks", body)
+	if w.Code != http.StatusCreated && w.Code != http.StatusOK {
+		t.Fatalf("新增書籤回應 %d: %s", w.Code, w.Body.String())
+	}
+	return decodeBody(t, w)
+}
+
+func TestBaselineEndpoints(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程","unit_name":"臺北市政府","priority":2}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"資訊系統維護","unit_name":"新北市政府"}`)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   string // 錯誤回應的 error 或成功回應的 code，空字串表示不檢查
+	}{
+		// GET /api/bookmarks
+		{"list bookmarks", "GET", "/api/bookmarks", "", http.StatusOK, ""},
+		{"list bookmarks bad filter", "GET", "/api/bookmarks?min_priority=x", "", http.StatusBadRequest, "invalid_parameter"},
+		{"list bookmarks unknown year", "GET", "/api/bookmarks?year=1999", "", http.StatusNotFound, "unknown_year"},
+
+		// POST /api/bookmarks
+		{"add bookmark", "POST", "/api/bookmarks", `{"job_number":"B001","title":"新增"}`, http.StatusCreated, "bookmark_added"},
+		{"re-add bookmark", "POST", "/api/bookmarks", `{"job_number":"B001","title":"新增"}`, http.StatusOK, "bookmark_updated"},
+		{"add bookmark bad json", "POST", "/api/bookmarks", `{"job_number":`, http.StatusUnprocessableEntity, "invalid_json"},
+		{"add bookmark missing job number", "POST", "/api/bookmarks", `{"title":"沒有案號"}`, http.StatusBadRequest, "missing_job_number"},
+		{"add bookmark invalid job number", "POST", "/api/bookmarks", `{"job_number":"A<script>"}`, http.StatusBadRequest, "invalid_job_number"},
+		{"add bookmark unknown year", "POST", "/api/bookmarks?year=1999", `{"job_number":"B002"}`, http.StatusNotFound, "unknown_year"},
+
+		// PUT /api/bookmarks
+		{"update bookmark", "PUT", "/api/bookmarks", `{"job_number":"A001","note":"已聯絡"}`, http.StatusOK, "bookmark_updated"},
+		{"update bookmark nothing", "PUT", "/api/bookmarks", `{"job_number":"A001"}`, http.StatusBadRequest, "nothing_to_update"},
+		{"update bookmark not found", "PUT", "/api/bookmarks", `{"job_number":"Z999","priority":1}`, http.StatusNotFound, "not_found"},
+
+		// DELETE /api/bookmarks
+		{"delete bookmark", "DELETE", "/api/bookmarks?job_number=B001", "", http.StatusOK, "bookmark_deleted"},
+		{"delete bookmark missing job number", "DELETE", "/api/bookmarks", "", http.StatusBadRequest, "missing_job_number"},
+		{"delete bookmark not found", "DELETE", "/api/bookmarks?job_number=Z999", "", http.StatusNotFound, "not_found"},
+
+		// GET /api/bookmarks/list
+		{"job number list", "GET", "/api/bookmarks/list", "", http.StatusOK, ""},
+		{"job number list v2", "GET", "/api/bookmarks/list?format_version=2", "", http.StatusOK, ""},
+		{"job number list bad format", "GET", "/api/bookmarks/list?format_version=3", "", http.StatusBadRequest, "invalid_parameter"},
+		{"job number list bad year", "GET", "/api/bookmarks/list?year=abc", "", http.StatusBadRequest, "invalid_parameter"},
+		{"job number list unknown year", "GET", "/api/bookmarks/list?year=1999", "", http.StatusNotFound, "unknown_year"},
+
+		// GET /api/bookmarks/check
+		{"check bookmark", "GET", "/api/bookmarks/check?job_number=A001", "", http.StatusOK, ""},
+		{"check bookmark missing job number", "GET", "/api/bookmarks/check", "", http.StatusBadRequest, "missing_job_number"},
+		{"check bookmark unknown year", "GET", "/api/bookmarks/check?job_number=A001&year=1999", "", http.StatusNotFound, "unknown_year"},
+
+		// GET /api/bookmarks/download
+		{"download", "GET", "/api/bookmarks/download?stream=false", "", http.StatusOK, ""},
+		{"download bad year", "GET", "/api/bookmarks/download?year=abc", "", http.StatusBadRequest, "invalid_parameter"},
+		{"download unknown year", "GET", "/api/bookmarks/download?year=1999", "", http.StatusNotFound, "unknown_year"},
+
+		// GET /api/bookmarks/export
+		{"export", "GET", "/api/bookmarks/export", "", http.StatusOK, ""},
+		{"export bad format", "GET", "/api/bookmarks/export?format=pdf", "", http.StatusBadRequest, "invalid_parameter"},
+		{"export unknown year", "GET", "/api/bookmarks/export?year=1999", "", http.StatusNotFound, "unknown_year"},
+
+		// 其他
+		{"unknown route", "GET", "/api/nothing-here", "", http.StatusNotFound, "unknown_route"},
+		{"method not allowed", "PATCH", "/api/bookmarks", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, tt.method, tt.target, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.code == "" {
+				return
+			}
+			resp := decodeBody(t, w)
+			got := resp["code"]
+			if resp["success"] != true {
+				got = resp["error"]
+			}
+			if got != tt.code {
+				t.Errorf("code = %v, want %s: %s", got, tt.code, w.Body.String())
+			}
+		})
+	}
+}
+
+// ?year= 指向尚未建立的年度時回應 404，不在磁碟上建立任何檔案；只有管理路徑會建立
+func TestYearNotCreatedByRequests(t *testing.T) {
+	h := newFileTestServer(t).routes()
+	year := currentYear() - 1
+	target := fmt.Sprintf("/api/bookmarks?year=%d", year)
+
+	for _, method := range []string{"GET", "HEAD"} {
+		w := serve(t, h, method, target, "")
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%s status = %d, want 404: %s", method, w.Code, w.Body.String())
+		}
+		if method == "GET" {
+			if resp := decodeBody(t, w); resp["error"] != "year_not_found" {
+				t.Fatalf("error = %v, want year_not_found", resp["error"])
+			}
+		}
+	}
+	if _, err := os.Stat(yearDir(year)); !os.IsNotExist(err) {
+		t.Fatalf("GET ?year=%d 建立了 %s: %v", year, yearDir(year), err)
+	}
+
+	w := serve(t, h, "POST", fmt.Sprintf("/api/admin/years?year=%d", year), "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(yearDBPath(year)); err != nil {
+		t.Fatalf("年度資料庫未建立: %v", err)
+	}
+	if w := serve(t, h, "POST", fmt.Sprintf("/api/admin/years?year=%d", year), ""); w.Code != http.StatusOK {
+		t.Fatalf("second create status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if w := serve(t, h, "GET", target, ""); w.Code != http.StatusOK {
+		t.Fatalf("GET after create status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, h, "POST", fmt.Sprintf("/api/admin/years?year=%d", currentYear()+2), "")
+	if w.Code != http.StatusNotFound || decodeBody(t, w)["error"] != "unknown_year" {
+		t.Fatalf("out-of-range create = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetBookmarksContent(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"低","priority":1}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"高","priority":5}`)
+
+	w := serve(t, h, "GET", "/api/bookmarks", "")
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(w.Body.Bytes(), &bookmarks); err != nil {
+		t.Fatalf("解析書籤失敗: %v", err)
+	}
+	if len(bookmarks) != 2 || bookmarks[0].JobNumber != "A002" || bookmarks[1].JobNumber != "A001" {
+		t.Fatalf("書籤未依優先級排序: %+v", bookmarks)
+	}
+
+	w = serve(t, h, "GET", "/api/bookmarks?min_priority=3", "")
+	bookmarks = nil
+	json.Unmarshal(w.Body.Bytes(), &bookmarks)
+	if len(bookmarks) != 1 || bookmarks[0].JobNumber != "A002" {
+		t.Fatalf("min_priority 篩選結果錯誤: %+v", bookmarks)
+	}
+}
+
+func TestCheckAndListAfterDelete(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"測試"}`)
+
+	resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", ""))
+	if resp["job_number"] != "A001" || resp["bookmarked"] != true {
+		t.Fatalf("check 回應錯誤: %v", resp)
+	}
+	resp = decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if resp["count"] != float64(1) {
+		t.Fatalf("list count = %v, want 1", resp["count"])
+	}
+
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A001", "")
+	resp = decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", ""))
+	if resp["bookmarked"] != false {
+		t.Fatalf("刪除後仍顯示已加入書籤: %v", resp)
+	}
+	resp = decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", ""))
+	if resp["count"] != float64(0) {
+		t.Fatalf("刪除後 list count = %v, want 0", resp["count"])
+	}
+}
+
+func TestDownloadStream(t *testing.T) {
+	h := newTestServer(t).routes()
+	// 沒有 api_url 的書籤不會連線，結果為失敗
+	addTestBookmark(t, h, `{"job_number":"A001","title":"測試"}`)
+
+	w := serve(t, h, "GET", "/api/bookmarks/download", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var v map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			t.Fatalf("NDJSON 行無法解析: %v: %s", err, sc.Text())
+		}
+		lines = append(lines, v)
+	}
+	if len(lines) != 2 || lines[0]["type"] != "result" || lines[1]["type"] != "summary" {
+		t.Fatalf("NDJSON 內容錯誤: %v", lines)
+	}
+	if lines[1]["total"] != float64(1) || lines[1]["success"] != float64(0) {
+		t.Fatalf("摘要錯誤: %v", lines[1])
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"測試","note":"備註"}`)
+
+	w := serve(t, h, "GET", "/api/bookmarks/export", "")
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "bookmarks_") {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(w.Body.Bytes(), &bookmarks); err != nil {
+		t.Fatalf("匯出內容無法解析: %v: %s", err, w.Body.String())
+	}
+	if len(bookmarks) != 1 || bookmarks[0].Note != "備註" {
+		t.Fatalf("匯出內容錯誤: %+v", bookmarks)
+	}
+}
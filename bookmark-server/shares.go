@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 唯讀分享連結
+//
+// POST /api/shares 建立分享：選取的案號（job_numbers）或儲存的篩選條件（search，與 GET /api/bookmarks 的參數相同），
+// 可設定到期時間；回應中的 token 只在建立時出現一次，資料庫只存雜湊。
+// GET /share/{token} 為唯讀的 HTML 頁面，GET /api/shares/{token}.json 為同樣的資料，每次請求依目前的書籤產生；
+// 只包含分享範圍內的書籤，備註只有建立時指定 include_notes 才會出現。
+// DELETE /api/shares/{id} 撤銷分享；撤銷或過期的 token 回應 410，不存在的 token 回應 404。
+
+// 每個分享最多選取的案號數
+const maxShareJobNumbers = 500
+
+// search 可使用的參數
+var shareSearchParams = []string{"category", "keyword", "date_from", "date_to"}
+
+// Share 分享設定
+type Share struct {
+	ID           int64      `json:"id"`
+	Title        string     `json:"title"`
+	JobNumbers   []string   `json:"job_numbers"`
+	Search       string     `json:"search"` // 篩選條件（查詢字串），與 job_numbers 擇一
+	IncludeNotes bool       `json:"include_notes"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Views        int        `json:"views"`
+	Token        string     `json:"token,omitempty"` // 只在建立時回傳
+	Path         string     `json:"path,omitempty"`  // 分享頁面的路徑，只在建立時回傳
+}
+
+// 建立分享的請求
+type shareRequest struct {
+	Title        string            `json:"title"`
+	JobNumbers   []string          `json:"job_numbers"`
+	Search       map[string]string `json:"search"`
+	IncludeNotes bool              `json:"include_notes"`
+	ExpiresAt    *time.Time        `json:"expires_at"` // 與 expires_in 擇一，都未設定時不會過期
+	ExpiresIn    string            `json:"expires_in"` // 例如 "168h"
+}
+
+// SharedBookmark 分享頁面中的一筆書籤
+type SharedBookmark struct {
+	JobNumber         string     `json:"job_number"`
+	Title             string     `json:"title"`
+	UnitName          string     `json:"unit_name"`
+	URL               string     `json:"url"`
+	Type              string     `json:"type"`
+	Date              TenderDate `json:"date"`
+	Priority          int        `json:"priority"`
+	MatchedCategories []string   `json:"matched_categories"`
+	Note              string     `json:"note,omitempty"`
+}
+
+// 分享已撤銷或過期
+var (
+	errShareRevoked = errors.New("share revoked")
+	errShareExpired = errors.New("share expired")
+)
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const shareColumns = "id, title, job_numbers, search, include_notes, expires_at, revoked_at, created_at, views"
+
+func scanShare(row interface{ Scan(...interface{}) error }) (Share, error) {
+	var sh Share
+	var jobNumbers string
+	var includeNotes int
+	var expires, revoked time.Time
+	err := row.Scan(&sh.ID, &sh.Title, &jobNumbers, &sh.Search, &includeNotes,
+		scanTime(&expires), scanTime(&revoked), scanTime(&sh.CreatedAt), &sh.Views)
+	if err != nil {
+		return sh, err
+	}
+	if err := json.Unmarshal([]byte(jobNumbers), &sh.JobNumbers); err != nil || sh.JobNumbers == nil {
+		sh.JobNumbers = make([]string, 0)
+	}
+	sh.IncludeNotes = includeNotes != 0
+	sh.ExpiresAt, sh.RevokedAt = optionalTime(expires), optionalTime(revoked)
+	sh.CreatedAt = localTime(sh.CreatedAt)
+	return sh, nil
+}
+
+// 以 token 取得分享並記錄瀏覽次數；不存在時回傳 sql.ErrNoRows，撤銷或過期時回傳 errShareRevoked/errShareExpired
+func (s *Server) shareByToken(r *http.Request, token string) (*Share, error) {
+	if token == "" {
+		return nil, sql.ErrNoRows
+	}
+	sh, err := scanShare(s.db.QueryRowContext(r.Context(), "SELECT "+shareColumns+" FROM shares WHERE token_hash = ?", hashShareToken(token)))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case sh.RevokedAt != nil:
+		return nil, errShareRevoked
+	case sh.ExpiresAt != nil && !time.Now().Before(*sh.ExpiresAt):
+		return nil, errShareExpired
+	}
+	if !s.cfg.ReadOnly {
+		s.db.execWrite(r.Context(), "UPDATE shares SET views = views + 1 WHERE id = ?", sh.ID)
+	}
+	return &sh, nil
+}
+
+// 分享範圍內目前的書籤
+func (s *Server) sharedBookmarks(ctx context.Context, sh *Share) ([]SharedBookmark, error) {
+	var filter string
+	var args []interface{}
+	if len(sh.JobNumbers) > 0 {
+		filter = "WHERE " + notDeleted + " AND job_number IN (?" + strings.Repeat(", ?", len(sh.JobNumbers)-1) + ")"
+		for _, jn := range sh.JobNumbers {
+			args = append(args, jn)
+		}
+	} else {
+		values, err := url.ParseQuery(sh.Search)
+		if err != nil {
+			return nil, err
+		}
+		var badParam string
+		if filter, args, badParam = bookmarkFilter(values); badParam != "" {
+			return nil, fmt.Errorf("分享的篩選條件錯誤: %s", badParam)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT "+bookmarkColumns+" FROM bookmarks "+filter+" ORDER BY priority DESC, date DESC, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	bookmarks := make([]Bookmark, 0)
+	for rows.Next() {
+		b, err := scanBookmark(rows, s.db.cipher)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.attachTerms(bookmarks); err != nil {
+		return nil, err
+	}
+	items := make([]SharedBookmark, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		item := SharedBookmark{
+			JobNumber: b.JobNumber, Title: b.Title, UnitName: b.UnitName, URL: b.URL, Type: b.Type,
+			Date: b.Date, Priority: b.Priority, MatchedCategories: append(make([]string, 0), b.MatchedCategories...),
+		}
+		if sh.IncludeNotes {
+			item.Note = b.Note
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GET 列出分享、POST 建立分享
+func (s *Server) sharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		s.createShare(w, r)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+shareColumns+" FROM shares ORDER BY id DESC")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]Share, 0)
+	for rows.Next() {
+		sh, err := scanShare(rows)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		items = append(items, sh)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(items), "items": items})
+}
+
+func (s *Server) createShare(w http.ResponseWriter, r *http.Request) {
+	var req shareRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if (len(req.JobNumbers) == 0) == (len(req.Search) == 0) {
+		writeError(w, r, http.StatusBadRequest, "share_invalid", "job_numbers 與 search 必須擇一")
+		return
+	}
+	if len(req.JobNumbers) > maxShareJobNumbers {
+		writeError(w, r, http.StatusBadRequest, "share_invalid", fmt.Sprintf("job_numbers 最多 %d 筆", maxShareJobNumbers))
+		return
+	}
+	sh := Share{Title: strings.TrimSpace(req.Title), JobNumbers: make([]string, 0), IncludeNotes: req.IncludeNotes}
+	for _, raw := range req.JobNumbers {
+		jn, ok := jobNumberParam(w, r, raw)
+		if !ok {
+			return
+		}
+		if !containsString(sh.JobNumbers, jn) {
+			sh.JobNumbers = append(sh.JobNumbers, jn)
+		}
+	}
+	if len(req.Search) > 0 {
+		values := url.Values{}
+		for k, v := range req.Search {
+			if !containsString(shareSearchParams, k) {
+				writeError(w, r, http.StatusBadRequest, "share_invalid", "search 不支援的參數: "+k)
+				return
+			}
+			if v != "" {
+				values.Set(k, v)
+			}
+		}
+		if _, _, badParam := bookmarkFilter(values); badParam != "" {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "search."+badParam)
+			return
+		}
+		sh.Search = values.Encode()
+	}
+	switch {
+	case req.ExpiresAt != nil && req.ExpiresIn != "":
+		writeError(w, r, http.StatusBadRequest, "share_invalid", "expires_at 與 expires_in 只能擇一")
+		return
+	case req.ExpiresAt != nil:
+		sh.ExpiresAt = req.ExpiresAt
+	case req.ExpiresIn != "":
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "expires_in")
+			return
+		}
+		t := time.Now().Add(d)
+		sh.ExpiresAt = &t
+	}
+	if sh.ExpiresAt != nil && !sh.ExpiresAt.After(time.Now()) {
+		writeError(w, r, http.StatusBadRequest, "share_invalid", "到期時間必須晚於現在")
+		return
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	sh.Token = base64.RawURLEncoding.EncodeToString(b)
+	sh.Path = "/share/" + sh.Token
+	jobNumbers, _ := json.Marshal(sh.JobNumbers)
+	var expires interface{}
+	if sh.ExpiresAt != nil {
+		expires = dbTime(*sh.ExpiresAt)
+		*sh.ExpiresAt = localTime(*sh.ExpiresAt)
+	}
+	sh.CreatedAt = localTime(time.Now())
+
+	scope := sh.Search
+	if scope == "" {
+		scope = fmt.Sprintf("%d 筆書籤", len(sh.JobNumbers))
+	}
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		err := tx.QueryRow(`
+			INSERT INTO shares (token_hash, title, job_numbers, search, include_notes, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			hashShareToken(sh.Token), sh.Title, string(jobNumbers), sh.Search, boolFlag(sh.IncludeNotes), expires, dbTime(sh.CreatedAt)).Scan(&sh.ID)
+		if err != nil {
+			return err
+		}
+		summary := fmt.Sprintf("建立分享連結 %d（%s", sh.ID, scope)
+		if sh.IncludeNotes {
+			summary += "，含備註"
+		}
+		return writeAudit(tx, newAuditEntry(r, summary+"）", sh.JobNumbers...))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sh)
+}
+
+// GET /api/shares/{token}.json 分享的資料；DELETE /api/shares/{id} 撤銷分享
+func (s *Server) shareHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if r.Method == "DELETE" {
+		s.revokeShare(w, r)
+		return
+	}
+	token, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "share_not_found")
+		return
+	}
+	sh, ok := s.shareForRequest(w, r, token, false)
+	if !ok {
+		return
+	}
+	items, err := s.sharedBookmarks(r.Context(), sh)
+	if err != nil {
+		writeInternalError(w, r, "database_error", err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"title":         sh.Title,
+		"expires_at":    sh.ExpiresAt,
+		"include_notes": sh.IncludeNotes,
+		"total":         len(items),
+		"items":         items,
+	})
+}
+
+// 依 token 取得分享，不存在、撤銷或過期時回應錯誤（HTML 頁面以純文字回應）
+func (s *Server) shareForRequest(w http.ResponseWriter, r *http.Request, token string, page bool) (*Share, bool) {
+	sh, err := s.shareByToken(r, token)
+	if err == nil {
+		return sh, true
+	}
+	status, code := http.StatusInternalServerError, "database_error"
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		status, code = http.StatusNotFound, "share_not_found"
+	case errors.Is(err, errShareRevoked), errors.Is(err, errShareExpired):
+		status, code = http.StatusGone, "share_gone"
+	}
+	if status == http.StatusInternalServerError {
+		writeInternalError(w, r, code, err)
+	} else if page {
+		http.Error(w, localize(requestLanguage(r), code), status)
+	} else {
+		writeError(w, r, status, code)
+	}
+	return nil, false
+}
+
+func (s *Server) revokeShare(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "name")
+	if !ok {
+		return
+	}
+	var jobNumbers string
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		err := tx.QueryRow("UPDATE shares SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? RETURNING job_numbers", dbNow(), id).Scan(&jobNumbers)
+		if err != nil {
+			return err
+		}
+		var jns []string
+		json.Unmarshal([]byte(jobNumbers), &jns)
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("撤銷分享連結 %d", id), jns...))
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "id": id, "revoked": true})
+}
+
+var sharePageTemplate = htmltemplate.Must(htmltemplate.New("share").Parse(`<!DOCTYPE html>
+<html lang="zh-Hant"><head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}標案分享{{end}}</title>
+<style>
+body { font-family: sans-serif; color: #222; max-width: 960px; margin: 24px auto; padding: 0 16px; }
+.meta { color: #666; }
+.item { border-bottom: 1px solid #eee; padding: 12px 0; }
+.tag { display: inline-block; background: #eef; border-radius: 4px; padding: 0 6px; margin-right: 4px; font-size: 90%; }
+.note { background: #fffbe6; padding: 6px 8px; margin-top: 6px; white-space: pre-wrap; }
+</style>
+</head><body>
+<h2>{{if .Title}}{{.Title}}{{else}}標案分享{{end}}</h2>
+<p class="meta">共 {{len .Items}} 筆{{if .ExpiresAt}}，此連結於 {{.ExpiresAt}} 到期{{end}}</p>
+{{range .Items}}<div class="item">
+<div>{{if .URL}}<a href="{{.URL}}" target="_blank" rel="noopener noreferrer">{{.Title}}</a>{{else}}{{.Title}}{{end}}</div>
+<div class="meta">{{.JobNumber}}{{if .UnitName}}｜{{.UnitName}}{{end}}{{if .Type}}｜{{.Type}}{{end}}{{if .Date}}｜{{.Date}}{{end}}{{if .Priority}}｜優先級 {{.Priority}}{{end}}</div>
+{{if .MatchedCategories}}<div>{{range .MatchedCategories}}<span class="tag">{{.}}</span>{{end}}</div>{{end}}
+{{if .Note}}<div class="note">{{.Note}}</div>{{end}}
+</div>
+{{else}}<p class="meta">（沒有書籤）</p>{{end}}
+</body></html>
+`))
+
+// GET /share/{token}：唯讀的分享頁面
+func (s *Server) sharePage(w http.ResponseWriter, r *http.Request) {
+	sh, ok := s.shareForRequest(w, r, r.PathValue("token"), true)
+	if !ok {
+		return
+	}
+	items, err := s.sharedBookmarks(r.Context(), sh)
+	if err != nil {
+		writeInternalError(w, r, "database_error", err)
+		return
+	}
+	data := struct {
+		Title     string
+		ExpiresAt string
+		Items     []SharedBookmark
+	}{Title: sh.Title, Items: items}
+	if sh.ExpiresAt != nil {
+		data.ExpiresAt = sh.ExpiresAt.Format("2006-01-02 15:04")
+	}
+	var buf bytes.Buffer
+	if err := sharePageTemplate.Execute(&buf, data); err != nil {
+		writeInternalError(w, r, "database_error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Write(buf.Bytes())
+}
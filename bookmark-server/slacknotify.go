@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Slack 通知服務
+// 以 incoming webhook 送出 Block Kit 格式的訊息；url 為 webhook 網址。
+// channels 依觸發條件指定頻道（只有舊式 webhook 接受 channel 欄位，新式 webhook 固定送到建立時選定的頻道）。
+// 摘要模式下同一頻道的通知合併為一則，最多列出 slackMaxItems 則，其餘只顯示則數。
+
+const (
+	slackMaxItems     = 15
+	slackMaxTextRunes = 3000 // section 文字上限
+	slackMaxHeader    = 150  // header 文字上限
+)
+
+type slackNotifier struct {
+	url          string
+	channels     map[string]string
+	retryBackoff time.Duration
+}
+
+func newSlackNotifier(cfg ProviderConfig) (*slackNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("未設定 Slack incoming webhook 網址（url）")
+	}
+	return &slackNotifier{url: cfg.URL, channels: cfg.Channels, retryBackoff: notifyRetryBackoff}, nil
+}
+
+// Block Kit 區塊
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"` // plain_text、mrkdwn
+	Text string `json:"text"`
+}
+
+type slackMessage struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"` // 通知預覽與不支援 Block Kit 時顯示的文字
+	Blocks  []slackBlock `json:"blocks"`
+}
+
+// 依頻道分組後逐一送出；任一則失敗時回傳最後的錯誤
+func (sl *slackNotifier) send(ctx context.Context, d *delivery) (int, error) {
+	var order []string
+	groups := map[string][]*Notification{}
+	for _, n := range d.Items {
+		channel := sl.channels[n.Trigger]
+		if _, ok := groups[channel]; !ok {
+			order = append(order, channel)
+		}
+		groups[channel] = append(groups[channel], n)
+	}
+	var status int
+	var lastErr error
+	note := d.skippedNote() // 略過的則數只附註在第一則
+	for _, channel := range order {
+		msg := slackMessageFor(groups[channel], note)
+		msg.Channel = channel
+		note = ""
+		code, err := sl.post(ctx, msg)
+		status = code
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return status, lastErr
+}
+
+func (sl *slackNotifier) post(ctx context.Context, msg *slackMessage) (int, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := postNotification(ctx, sl.retryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", sl.url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status != http.StatusOK {
+		// 錯誤回應為純文字，例如 invalid_payload、channel_not_found、no_service
+		return resp.Status, fmt.Errorf("Slack 回應 %d: %s", resp.Status, truncateMessage(strings.TrimSpace(string(resp.Body))))
+	}
+	return resp.Status, nil
+}
+
+// 產生 Block Kit 訊息：一則通知時以標題列為 header，多則時為摘要
+func slackMessageFor(items []*Notification, note string) *slackMessage {
+	msg := &slackMessage{}
+	header := notificationHeadline(items[0])
+	if len(items) > 1 {
+		header = fmt.Sprintf("政府採購書籤通知：%d 則", len(items))
+	}
+	msg.Text = header
+	if len(items) == 1 {
+		msg.Text = header + "：" + items[0].Title
+	}
+	msg.Blocks = append(msg.Blocks, slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: truncateRunes(header, slackMaxHeader)}})
+
+	for i, n := range items {
+		if i == slackMaxItems {
+			msg.Blocks = append(msg.Blocks, slackBlock{Type: "context", Elements: []*slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("另有 %d 則通知，詳見通知紀錄", len(items)-slackMaxItems)},
+			}})
+			break
+		}
+		if i > 0 {
+			msg.Blocks = append(msg.Blocks, slackBlock{Type: "divider"})
+		}
+		title := slackEscape(n.Title)
+		if n.URL != "" {
+			title = "<" + slackEscape(n.URL) + "|" + title + ">"
+		}
+		lines := []string{"*" + title + "*"}
+		if n.UnitName != "" {
+			lines = append(lines, slackEscape(n.UnitName))
+		}
+		if len(items) > 1 {
+			lines = append(lines, slackEscape(notificationHeadline(n)))
+		}
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncateRunes(strings.Join(lines, "\n"), slackMaxTextRunes)}})
+
+		meta := []string{}
+		if n.JobNumber != "" {
+			meta = append(meta, "標案案號 "+slackEscape(n.JobNumber))
+		}
+		if n.Priority != 0 {
+			meta = append(meta, fmt.Sprintf("優先順序 %d", n.Priority))
+		}
+		if len(meta) > 0 {
+			msg.Blocks = append(msg.Blocks, slackBlock{Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: strings.Join(meta, "｜")}}})
+		}
+	}
+
+	if note != "" {
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: slackEscape(note)}}})
+	}
+	return msg
+}
+
+// mrkdwn 需要跳脫的字元
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 書籤匯出為試算表（GET /api/bookmarks/export?format=csv|xlsx）
+//
+// 欄位固定為 exportColumns，日期為 YYYY-MM-DD，新增時間為顯示時區的 YYYY-MM-DD HH:MM:SS。
+// CSV 以 UTF-8 BOM 開頭、CRLF 換行，Excel 才會以 UTF-8 顯示中文；
+// 以 = + - @ 開頭的文字前加上 '，避免被試算表當成公式執行。
+// XLSX 不依賴外部套件，直接產生最小的 Office Open XML：文字為 inline string，
+// 日期與新增時間為日期儲存格（可排序、篩選），第一列凍結。
+
+// 試算表的欄位與 XLSX 欄寬
+var exportColumns = []struct {
+	name  string
+	width float64
+}{
+	{"job_number", 18},
+	{"title", 50},
+	{"unit_name", 28},
+	{"type", 14},
+	{"date", 12},
+	{"priority", 9},
+	{"status", 11},
+	{"note", 40},
+	{"url", 40},
+	{"created_at", 20},
+}
+
+// 儲存格的種類
+const (
+	cellText = iota
+	cellNumber
+	cellDate
+	cellDateTime
+)
+
+type sheetCell struct {
+	kind int
+	text string
+	num  int
+	date TenderDate
+	time time.Time
+}
+
+// 一筆書籤對應的儲存格，順序同 exportColumns
+func bookmarkCells(b *Bookmark) []sheetCell {
+	return []sheetCell{
+		{kind: cellText, text: b.JobNumber},
+		{kind: cellText, text: b.Title},
+		{kind: cellText, text: b.UnitName},
+		{kind: cellText, text: b.Type},
+		{kind: cellDate, date: b.Date},
+		{kind: cellNumber, num: b.Priority},
+		{kind: cellText, text: b.Status},
+		{kind: cellText, text: b.Note},
+		{kind: cellText, text: b.URL},
+		{kind: cellDateTime, time: b.CreatedAt},
+	}
+}
+
+// 儲存格的文字（CSV 使用）；沒有日期時為空字串
+func (c sheetCell) String() string {
+	switch c.kind {
+	case cellNumber:
+		return strconv.Itoa(c.num)
+	case cellDate:
+		return c.date.String()
+	case cellDateTime:
+		if c.time.IsZero() {
+			return ""
+		}
+		return localTime(c.time).Format("2006-01-02 15:04:05")
+	}
+	return c.text
+}
+
+// 試算表會把這些字元開頭的內容當成公式
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeBookmarksCSV(w io.Writer, bookmarks []Bookmark) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\ufeff") // BOM
+	cw := csv.NewWriter(bw)
+	cw.UseCRLF = true
+	header := make([]string, len(exportColumns))
+	for i, col := range exportColumns {
+		header[i] = col.name
+	}
+	cw.Write(header)
+	for i := range bookmarks {
+		cells := bookmarkCells(&bookmarks[i])
+		record := make([]string, len(cells))
+		for j, c := range cells {
+			record[j] = c.String()
+			if c.kind == cellText {
+				record[j] = escapeFormula(record[j])
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// XLSX 的樣式編號，對應 xlsxStyles 中 cellXfs 的順序
+const (
+	xlsxStyleHeader   = 1
+	xlsxStyleDate     = 2
+	xlsxStyleDateTime = 3
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="bookmarks" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// Excel 的日期序號：1899-12-30 起的天數，小數為一天中的時間
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// 欄位名稱，例如 0 → A、26 → AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func writeXLSXText(w io.Writer, ref, text string, style int) {
+	fmt.Fprintf(w, `<c r="%s" t="inlineStr"`, ref)
+	if style != 0 {
+		fmt.Fprintf(w, ` s="%d"`, style)
+	}
+	io.WriteString(w, `><is><t xml:space="preserve">`)
+	xml.EscapeText(w, []byte(text))
+	io.WriteString(w, `</t></is></c>`)
+}
+
+func writeXLSXSheet(w io.Writer, bookmarks []Bookmark) {
+	io.WriteString(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	io.WriteString(w, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	io.WriteString(w, `<cols>`)
+	for i, col := range exportColumns {
+		fmt.Fprintf(w, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, col.width)
+	}
+	io.WriteString(w, `</cols><sheetData><row r="1">`)
+	for i, col := range exportColumns {
+		writeXLSXText(w, xlsxColumn(i)+"1", col.name, xlsxStyleHeader)
+	}
+	io.WriteString(w, `</row>`)
+	for n := range bookmarks {
+		row := n + 2
+		fmt.Fprintf(w, `<row r="%d">`, row)
+		for i, c := range bookmarkCells(&bookmarks[n]) {
+			ref := xlsxColumn(i) + strconv.Itoa(row)
+			switch {
+			case c.kind == cellNumber:
+				fmt.Fprintf(w, `<c r="%s"><v>%d</v></c>`, ref, c.num)
+			case c.kind == cellDate && c.date != 0:
+				fmt.Fprintf(w, `<c r="%s" s="%d"><v>%g</v></c>`, ref, xlsxStyleDate, excelSerial(c.date.Time()))
+			case c.kind == cellDateTime && !c.time.IsZero():
+				fmt.Fprintf(w, `<c r="%s" s="%d"><v>%.8f</v></c>`, ref, xlsxStyleDateTime, excelSerial(localTime(c.time)))
+			case c.kind == cellText && c.text != "":
+				writeXLSXText(w, ref, c.text, 0)
+			}
+		}
+		io.WriteString(w, `</row>`)
+	}
+	io.WriteString(w, `</sheetData></worksheet>`)
+}
+
+func writeBookmarksXLSX(w io.Writer, bookmarks []Bookmark) error {
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name  string
+		write func(io.Writer)
+	}{
+		{"[Content_Types].xml", func(w io.Writer) { io.WriteString(w, xlsxContentTypes) }},
+		{"_rels/.rels", func(w io.Writer) { io.WriteString(w, xlsxRootRels) }},
+		{"xl/workbook.xml", func(w io.Writer) { io.WriteString(w, xlsxWorkbook) }},
+		{"xl/_rels/workbook.xml.rels", func(w io.Writer) { io.WriteString(w, xlsxWorkbookRels) }},
+		{"xl/styles.xml", func(w io.Writer) { io.WriteString(w, xlsxStyles) }},
+		{"xl/worksheets/sheet1.xml", func(w io.Writer) { writeXLSXSheet(w, bookmarks) }},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(f)
+		p.write(bw)
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// 以試算表格式匯出；先讀出所有符合的書籤，讀取失敗時仍可回傳錯誤
+func (s *Server) exportSpreadsheet(w http.ResponseWriter, r *http.Request, format, filter string, args []interface{}, orderBy string) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+bookmarkColumns+" FROM bookmarks "+filter+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	bookmarks := make([]Bookmark, 0)
+	for rows.Next() {
+		b, err := scanBookmark(rows, s.db.cipher)
+		if err != nil {
+			writeDBError(w, r, fmt.Errorf("匯出時讀取書籤失敗（id=%d）: %w", b.ID, err))
+			return
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	name := "bookmarks_" + time.Now().In(displayLocation).Format("20060102_150405") + "." + format
+	w.Header().Set("Content-Disposition", contentDisposition(name))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = writeBookmarksCSV(w, bookmarks)
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = writeBookmarksXLSX(w, bookmarks)
+	}
+	if err != nil {
+		log.Println("匯出寫入失敗:", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StaticMount 靜態檔案掛載點
+type StaticMount struct {
+	URLPrefix      string `json:"url_prefix"`      // 以 / 開頭與結尾，例如 /downloads/
+	Directory      string `json:"directory"`       // 對應的本機目錄
+	ListingEnabled bool   `json:"listing_enabled"` // 是否允許列出目錄內容
+}
+
+// 預設掛載：根路徑提供該年度篩選後的標案網頁
+func defaultStaticMounts(year int) []StaticMount {
+	return []StaticMount{
+		{URLPrefix: "/", Directory: filepath.Join(yearDir(year), "filtered_for_company"), ListingEnabled: true},
+	}
+}
+
+// 以 dir 作為根路徑 / 的目錄，沒有根路徑的掛載時新增一個
+func withRootMount(mounts []StaticMount, dir string) []StaticMount {
+	for i := range mounts {
+		if mounts[i].URLPrefix == "/" {
+			mounts[i].Directory = dir
+			return mounts
+		}
+	}
+	return append(mounts, StaticMount{URLPrefix: "/", Directory: dir, ListingEnabled: true})
+}
+
+// 檢查掛載設定
+// 根路徑 / 是其他路徑都找不到時的後備掛載，不算重疊；其餘前綴不得互相包含，也不得佔用 /api/
+func validateStaticMounts(mounts []StaticMount) error {
+	seen := make([]string, 0, len(mounts))
+	for i := range mounts {
+		m := &mounts[i]
+		if m.Directory == "" {
+			return fmt.Errorf("靜態掛載 %q 未設定目錄", m.URLPrefix)
+		}
+		if !strings.HasPrefix(m.URLPrefix, "/") {
+			return fmt.Errorf("靜態掛載前綴必須以 / 開頭: %q", m.URLPrefix)
+		}
+		if !strings.HasSuffix(m.URLPrefix, "/") {
+			m.URLPrefix += "/"
+		}
+		if path.Clean(m.URLPrefix)+"/" != m.URLPrefix && m.URLPrefix != "/" {
+			return fmt.Errorf("靜態掛載前綴格式錯誤: %q", m.URLPrefix)
+		}
+		if strings.HasPrefix(m.URLPrefix, "/api/") {
+			return fmt.Errorf("靜態掛載前綴不可位於 /api/ 之下: %q", m.URLPrefix)
+		}
+		for _, other := range seen {
+			if m.URLPrefix == other ||
+				(other != "/" && strings.HasPrefix(m.URLPrefix, other)) ||
+				(m.URLPrefix != "/" && strings.HasPrefix(other, m.URLPrefix)) {
+				return fmt.Errorf("靜態掛載前綴重疊: %q 與 %q", other, m.URLPrefix)
+			}
+		}
+		seen = append(seen, m.URLPrefix)
+	}
+	return nil
+}
+
+// 註冊所有靜態掛載
+func registerStaticMounts(mux *http.ServeMux, mounts []StaticMount) {
+	// 由長到短註冊，方便閱讀啟動紀錄
+	sorted := append([]StaticMount(nil), mounts...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i].URLPrefix) > len(sorted[j].URLPrefix) })
+
+	for _, m := range sorted {
+		if info, err := os.Stat(m.Directory); err != nil || !info.IsDir() {
+			log.Printf("警告: 靜態目錄不存在: %s（%s）", m.Directory, m.URLPrefix)
+		}
+		var fsys http.FileSystem = safeFS{http.Dir(m.Directory)}
+		if !m.ListingEnabled {
+			fsys = noListingFS{fsys}
+		}
+		// http.Dir 會清理路徑中的 ..，不會存取目錄之外的檔案
+		mux.Handle(m.URLPrefix, allowMethods(http.StripPrefix(strings.TrimSuffix(m.URLPrefix, "/"), http.FileServer(fsys)).ServeHTTP, "GET"))
+		log.Printf("靜態掛載: %s -> %s（目錄列表: %v）", m.URLPrefix, m.Directory, m.ListingEnabled)
+	}
+}
+
+// noListingFS 禁止目錄列表：開啟沒有 index.html 的目錄時回傳不存在
+type noListingFS struct {
+	fs http.FileSystem
+}
+
+func (n noListingFS) Open(name string) (http.File, error) {
+	f, err := n.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := n.fs.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
+
+// 靜態掛載可提供的副檔名；其他檔案（資料庫、備份、設定檔等）一律當作不存在
+var staticExtensions = map[string]bool{
+	".html": true, ".htm": true, ".css": true, ".js": true, ".mjs": true, ".map": true,
+	".json": true, ".csv": true, ".txt": true, ".md": true, ".pdf": true,
+	".xls": true, ".xlsx": true, ".doc": true, ".docx": true, ".odt": true, ".ods": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".woff": true, ".woff2": true,
+}
+
+// safeFS 過濾不應公開的檔案，回傳不存在（404）：
+// 隱藏檔與隱藏目錄、SQLite 資料庫（*.db 與 -wal、-shm）、backups 目錄，以及副檔名不在 staticExtensions 中的檔案。
+// 掛載目錄的配置改變（例如掛載整個年度目錄）時，資料庫與備份也不會因此可被下載
+type safeFS struct {
+	fs http.FileSystem
+}
+
+// 路徑中是否有不可公開的部分；isDir 為 false 時另檢查副檔名
+func staticPathDenied(name string, isDir bool) bool {
+	for _, part := range strings.Split(name, "/") {
+		lower := strings.ToLower(part)
+		if strings.HasPrefix(part, ".") || lower == "backups" ||
+			strings.HasSuffix(lower, ".db") || strings.HasSuffix(lower, ".db-wal") || strings.HasSuffix(lower, ".db-shm") {
+			return true
+		}
+	}
+	return !isDir && !staticExtensions[strings.ToLower(path.Ext(name))]
+}
+
+func (s safeFS) Open(name string) (http.File, error) {
+	// http.FileServer 傳入的路徑已經過 path.Clean，編碼過的 ../ 也已還原並清理
+	if staticPathDenied(name, true) {
+		return nil, os.ErrNotExist
+	}
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.IsDir() && staticPathDenied(name, false) {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return safeFile{f}, nil
+}
+
+// safeFile 目錄列表中不顯示不可公開的項目
+type safeFile struct {
+	http.File
+}
+
+func (f safeFile) Readdir(count int) ([]os.FileInfo, error) {
+	entries, err := f.File.Readdir(count)
+	visible := entries[:0]
+	for _, e := range entries {
+		if !staticPathDenied(e.Name(), e.IsDir()) {
+			visible = append(visible, e)
+		}
+	}
+	return visible, err
+}
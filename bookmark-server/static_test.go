@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 靜態掛載不提供資料庫、備份、隱藏檔與未列入的副檔名，編碼過的路徑也一樣
+func TestStaticMountHidesInternalFiles(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "site")
+	files := map[string]string{
+		"index.html":           "<p>index</p>",
+		"tenders/A001.json":    `{"public":true}`,
+		"bookmarks.db":         "SECRET-db",
+		"bookmarks.db-wal":     "SECRET-wal",
+		"bookmarks.db-shm":     "SECRET-shm",
+		"Archive.DB":           "SECRET-upper",
+		".env":                 "SECRET-env",
+		".git/config":          "SECRET-git",
+		"backups/old.html":     "SECRET-backup",
+		"tenders/notes.key":    "SECRET-key",
+		"tenders/config.yaml":  "SECRET-yaml",
+		"../outside.html":      "SECRET-outside",
+		"../site-bookmarks.db": "SECRET-outside-db",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	registerStaticMounts(mux, []StaticMount{{URLPrefix: "/", Directory: dir, ListingEnabled: true}})
+
+	for target, want := range map[string]string{"/": "<p>index</p>", "/tenders/A001.json": `{"public":true}`} {
+		if w := serve(t, mux, "GET", target, ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("GET %s = %d: %s", target, w.Code, w.Body.String())
+		}
+	}
+
+	for _, target := range []string{
+		"/bookmarks.db", "/bookmarks.db-wal", "/bookmarks.db-shm", "/Archive.DB",
+		"/.env", "/.git/config", "/backups/old.html", "/backups/",
+		"/tenders/notes.key", "/tenders/config.yaml",
+	} {
+		if w := serve(t, mux, "GET", target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404: %s", target, w.Code, w.Body.String())
+		}
+	}
+
+	// 編碼過的路徑與目錄穿越：ServeMux 可能先以 301 導向清理後的路徑，但都不能取得內容
+	denied := []string{
+		"/%62ookmarks.db", "/bookmarks%2Edb", "/%2eenv", "/%2Egit/config", "/backups%2Fold.html",
+		"/tenders/..%2fbookmarks.db", "/tenders/%2e%2e/bookmarks.db",
+		"/%2e%2e/outside.html", "/..%2foutside.html", "/%2e%2e%2fsite-bookmarks.db",
+	}
+	for _, target := range denied {
+		// NewRequest 與伺服器相同地解析請求行，%2e、%2f 在路由前解碼
+		req := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code == http.StatusOK || strings.Contains(w.Body.String(), "SECRET") {
+			t.Errorf("GET %s = %d: %s", target, w.Code, w.Body.String())
+		}
+		if w.Code >= 500 || w.Code < 300 {
+			t.Errorf("GET %s = %d, want 3xx/4xx", target, w.Code)
+		}
+	}
+
+	// 目錄列表不列出不可公開的項目
+	w := serve(t, mux, "GET", "/tenders/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "A001.json") {
+		t.Fatalf("GET /tenders/ = %d:\n%s", w.Code, w.Body.String())
+	}
+	for _, hidden := range []string{"notes.key", "config.yaml"} {
+		if strings.Contains(w.Body.String(), hidden) {
+			t.Errorf("目錄列表含有 %s:\n%s", hidden, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// 書籤統計
+//
+// 統計存於 stats 表（維度、值、筆數），GET /api/bookmarks/stats 直接讀表，不必每次掃描 bookmarks。
+// 資料異動後（markChanged）由背景 goroutine 等待 statsDebounce 沒有新的異動再重新計算，
+// 連續匯入時只會計算一次。?refresh=true 立即重新計算，並記錄與表中舊值的差異。
+// 帶有與 GET /api/bookmarks 相同的篩選參數（?status= ?tag= 等，見 bookmarkFilter）時不使用 stats 表，每次直接計算。
+// 機關前幾名、每月筆數與近期新增不存表（近期新增隨時間變動），每次以 GROUP BY 查詢。
+
+// 異動後等待多久沒有新的異動才重新計算
+const statsDebounce = 2 * time.Second
+
+// 統計維度
+const (
+	statsTotal    = "total"
+	statsArchived = "archived"
+	statsType     = "type"
+	statsPriority = "priority"
+)
+
+// 維度 → 值 → 筆數；total、archived 的值為空字串
+type statsCounts map[string]map[string]int64
+
+// statsRefresher 背景重新計算統計
+type statsRefresher struct {
+	dirty chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+
+	// 最近一次計算時的異動計數，小於目前計數表示統計已過時
+	version atomic.Uint64
+}
+
+// 啟動背景計算；啟動時先計算一次
+func (s *Server) startStatsRefresher() {
+	sr := &statsRefresher{
+		dirty: make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	s.stats = sr
+	sr.dirty <- struct{}{}
+	go func() {
+		defer close(sr.done)
+		for {
+			select {
+			case <-sr.dirty:
+			case <-sr.quit:
+				return
+			}
+			timer := time.NewTimer(statsDebounce)
+		debounce:
+			for {
+				select {
+				case <-sr.dirty:
+					timer.Reset(statsDebounce)
+				case <-timer.C:
+					break debounce
+				case <-sr.quit:
+					timer.Stop()
+					return
+				}
+			}
+			if _, err := s.refreshStats(context.Background(), false); err != nil {
+				log.Println("更新統計失敗:", err)
+			}
+		}
+	}()
+}
+
+// 通知有資料異動
+func (sr *statsRefresher) notify() {
+	if sr == nil {
+		return
+	}
+	select {
+	case sr.dirty <- struct{}{}:
+	default:
+	}
+}
+
+func (sr *statsRefresher) stop() {
+	if sr == nil {
+		return
+	}
+	close(sr.quit)
+	<-sr.done
+}
+
+// 由 bookmarks 計算統計，filter 與 args 為 bookmarkFilter 產生的 WHERE 子句；有篩選參數時不計算 archived
+func (s *Server) computeStats(ctx context.Context, filter string, args []interface{}) (statsCounts, error) {
+	counts := statsCounts{}
+	add := func(dimension, query string) error {
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		counts[dimension] = map[string]int64{}
+		for rows.Next() {
+			var value string
+			var n int64
+			if err := rows.Scan(&value, &n); err != nil {
+				return err
+			}
+			counts[dimension][value] = n
+		}
+		return rows.Err()
+	}
+	queries := []struct{ dimension, query string }{
+		{statsTotal, "SELECT '', COUNT(*) FROM bookmarks " + filter},
+		{statsType, "SELECT COALESCE(type, ''), COUNT(*) FROM bookmarks " + filter + " GROUP BY COALESCE(type, '')"},
+		{statsPriority, "SELECT CAST(COALESCE(priority, 0) AS TEXT), COUNT(*) FROM bookmarks " + filter + " GROUP BY COALESCE(priority, 0)"},
+	}
+	if len(args) == 0 {
+		queries = append(queries, struct{ dimension, query string }{statsArchived, "SELECT '', COUNT(*) FROM bookmarks_archive"})
+	}
+	for _, q := range queries {
+		if err := add(q.dimension, q.query); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// 讀取 stats 表與最近計算時間
+func (s *Server) storedStats(ctx context.Context) (statsCounts, time.Time, error) {
+	counts := statsCounts{}
+	rows, err := s.db.QueryContext(ctx, "SELECT dimension, value, count FROM stats")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dimension, value string
+		var n int64
+		if err := rows.Scan(&dimension, &value, &n); err != nil {
+			return nil, time.Time{}, err
+		}
+		if counts[dimension] == nil {
+			counts[dimension] = map[string]int64{}
+		}
+		counts[dimension][value] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var refreshed time.Time
+	var raw string
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(value), '') FROM app_meta WHERE key = 'stats_refreshed_at'").Scan(&raw); err != nil {
+		return nil, time.Time{}, err
+	}
+	if raw != "" {
+		refreshed, _ = time.Parse(time.RFC3339, raw)
+	}
+	return counts, refreshed, nil
+}
+
+// 重新計算並寫入 stats 表，回傳與表中舊值不同的項目（「維度:值」→ 舊值與新值）
+// reconcile 為 true 時記錄差異；背景計算時差異是正常的異動結果，不記錄
+func (s *Server) refreshStats(ctx context.Context, reconcile bool) (map[string][2]int64, error) {
+	version := s.changes.Load()
+	counts, err := s.computeStats(ctx, "WHERE "+notDeleted, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := map[string][2]int64{}
+	if reconcile {
+		old, _, err := s.storedStats(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for dimension := range mergeKeys(old, counts) {
+			for value := range mergeKeys(old[dimension], counts[dimension]) {
+				if before, after := old[dimension][value], counts[dimension][value]; before != after {
+					drift[dimension+":"+value] = [2]int64{before, after}
+				}
+			}
+		}
+	}
+
+	err = s.db.withTx(ctx, func(tx *Tx) error {
+		if _, err := tx.Exec("DELETE FROM stats"); err != nil {
+			return err
+		}
+		for dimension, values := range counts {
+			for value, n := range values {
+				if _, err := tx.Exec("INSERT INTO stats (dimension, value, count) VALUES (?, ?, ?)", dimension, value, n); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO app_meta (key, value) VALUES ('stats_refreshed_at', ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, dbNow())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.stats != nil {
+		s.stats.version.Store(version)
+	}
+	if len(drift) > 0 {
+		keys := make([]string, 0, len(drift))
+		for k := range drift {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			log.Printf("統計不一致 %s: 表中 %d，實際 %d", k, drift[k][0], drift[k][1])
+		}
+	}
+	return drift, nil
+}
+
+// 兩個 map 的所有鍵
+func mergeKeys[V any](a, b map[string]V) map[string]bool {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+// GET /api/bookmarks/stats：書籤統計，?refresh=true 立即重新計算
+// 篩選參數同 GET /api/bookmarks，?unit_limit= 為 by_unit 列出的機關數（預設 10）
+// 唯讀模式不寫入 stats 表，每次直接計算
+func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
+	filter, args, badParam := bookmarkFilter(r.URL.Query())
+	if badParam != "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	unitLimit, ok := intParam(r, "unit_limit", statsDefaultUnitLimit, 1, statsMaxUnitLimit)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "unit_limit")
+		return
+	}
+	// 每個篩選參數都有對應的查詢參數
+	filtered := len(args) > 0
+
+	var counts statsCounts
+	var refreshed time.Time
+	var err error
+	resp := map[string]interface{}{}
+	switch {
+	case s.cfg.ReadOnly || filtered:
+		counts, err = s.computeStats(r.Context(), filter, args)
+		refreshed = time.Now()
+	case r.URL.Query().Get("refresh") == "true":
+		var drift map[string][2]int64
+		if drift, err = s.refreshStats(r.Context(), true); err == nil {
+			discrepancies := map[string]interface{}{}
+			for k, v := range drift {
+				discrepancies[k] = map[string]int64{"stored": v[0], "actual": v[1]}
+			}
+			resp["discrepancies"] = discrepancies
+			counts, refreshed, err = s.storedStats(r.Context())
+		}
+	default:
+		counts, refreshed, err = s.storedStats(r.Context())
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	live, err := s.liveStats(r.Context(), filter, args, unitLimit)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	for k, v := range live {
+		resp[k] = v
+	}
+
+	resp["total"] = counts[statsTotal][""]
+	// 有篩選條件時不提供：已封存的書籤不在 bookmarks 中，無法套用相同的條件
+	if !filtered {
+		resp["archived"] = counts[statsArchived][""]
+	}
+	resp["filtered"] = filtered
+	resp["by_type"] = nonNilCounts(counts[statsType])
+	resp["by_priority"] = nonNilCounts(counts[statsPriority])
+	resp["refreshed_at"] = nil
+	if !refreshed.IsZero() {
+		resp["refreshed_at"] = localTime(refreshed)
+	}
+	// 統計計算後又有異動，背景更新完成前數字可能略舊；背景更新不會遞增異動計數，略舊的結果不放入回應快取
+	resp["stale"] = !filtered && s.stats != nil && s.stats.version.Load() < s.changes.Load()
+	if resp["stale"] == true {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func nonNilCounts(m map[string]int64) map[string]int64 {
+	if m == nil {
+		return map[string]int64{}
+	}
+	return m
+}
+
+const (
+	statsDefaultUnitLimit = 10
+	statsMaxUnitLimit     = 1000
+)
+
+// UnitCount 機關的書籤數
+type UnitCount struct {
+	UnitName string `json:"unit_name"`
+	Count    int64  `json:"count"`
+}
+
+// MonthCount 標案日期所在月份（YYYY-MM）的書籤數
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int64  `json:"count"`
+}
+
+// 不存於 stats 表的統計：書籤數最多的 unitLimit 個機關（by_unit）與機關總數（unit_count）、
+// 依標案日期的每月筆數（by_month，由舊到新；沒有日期的書籤計入 undated）、最近 7 與 30 天新增的筆數（recent）
+func (s *Server) liveStats(ctx context.Context, filter string, args []interface{}, unitLimit int) (map[string]interface{}, error) {
+	byUnit := make([]UnitCount, 0)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(unit_name, ''), COUNT(*) FROM bookmarks `+filter+`
+		GROUP BY COALESCE(unit_name, '') ORDER BY COUNT(*) DESC, COALESCE(unit_name, '') LIMIT ?`,
+		append(append([]interface{}{}, args...), unitLimit)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u UnitCount
+		if err := rows.Scan(&u.UnitName, &u.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		byUnit = append(byUnit, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// date 為 YYYYMMDD，除以 100 即為 YYYYMM
+	byMonth := make([]MonthCount, 0)
+	rows, err = s.db.QueryContext(ctx, "SELECT date / 100, COUNT(*) FROM bookmarks "+filter+" AND date > 0 GROUP BY date / 100 ORDER BY date / 100", args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var month, n int64
+		if err := rows.Scan(&month, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		byMonth = append(byMonth, MonthCount{Month: fmt.Sprintf("%04d-%02d", month/100, month%100), Count: n})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var units, undated, last7, last30 int64
+	now := time.Now()
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT COALESCE(unit_name, '')),
+			COALESCE(SUM(CASE WHEN date = 0 OR date IS NULL THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at >= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN created_at >= ? THEN 1 ELSE 0 END), 0)
+		FROM bookmarks `+filter,
+		append([]interface{}{dbTime(now.AddDate(0, 0, -7)), dbTime(now.AddDate(0, 0, -30))}, args...)...,
+	).Scan(&units, &undated, &last7, &last30)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"by_unit":    byUnit,
+		"unit_count": units,
+		"by_month":   byMonth,
+		"undated":    undated,
+		"recent":     map[string]int64{"last_7_days": last7, "last_30_days": last30},
+	}, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 書籤狀態：標案從關注到結束的進度
+//
+// 狀態存於 bookmarks.status（遷移 27），新增的書籤為 watching；
+// 每次變更在同一筆交易中寫入 bookmark_status_history（舊狀態、新狀態、說明、操作者與時間）。
+// PUT /api/bookmarks 的 status 變更狀態（status_comment 為說明），只接受 bookmarkStatuses 中的值，
+// 狀態之間可以任意轉換（例如誤按「放棄」後改回準備投標）；相同狀態不記錄歷程。
+// GET /api/bookmarks?status= 篩選（可重複，符合任一即列出），GET /api/bookmarks/history?job_number= 列出歷程。
+// 已刪除書籤的歷程保留到下一次清理（見 prune.go），期間仍可查詢。
+
+const (
+	statusWatching  = "watching"  // 關注中
+	statusPreparing = "preparing" // 準備投標
+	statusSubmitted = "submitted" // 已投標
+	statusWon       = "won"       // 得標
+	statusLost      = "lost"      // 未得標
+	statusAbandoned = "abandoned" // 放棄
+)
+
+// 可用的狀態，依進度排列
+var bookmarkStatuses = []string{statusWatching, statusPreparing, statusSubmitted, statusWon, statusLost, statusAbandoned}
+
+const maxStatusCommentLength = 500
+
+// StatusChange 一筆狀態變更
+type StatusChange struct {
+	ID        int64     `json:"id"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	Comment   string    `json:"comment"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func validStatus(status string) bool {
+	for _, s := range bookmarkStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// 狀態錯誤時回應 400 並列出可用的狀態
+func writeStatusError(w http.ResponseWriter, r *http.Request, status string) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"success": false,
+		"error":   "invalid_status",
+		"message": localize(requestLanguage(r), "invalid_status", status, strings.Join(bookmarkStatuses, ", ")),
+		"allowed": bookmarkStatuses,
+	})
+}
+
+// 檢查狀態說明
+func checkStatusComment(comment string) error {
+	if !utf8.ValidString(comment) {
+		return errors.New("含有無效的字元")
+	}
+	if utf8.RuneCountInString(comment) > maxStatusCommentLength {
+		return fmt.Errorf("超過 %d 字", maxStatusCommentLength)
+	}
+	return nil
+}
+
+// 書籤目前的狀態；書籤不存在時回傳空字串
+func bookmarkStatus(tx *Tx, jobNumber string) (string, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+// 記錄一筆狀態變更
+func recordStatusChange(tx *Tx, actor, jobNumber, oldStatus, newStatus, comment string) error {
+	_, err := tx.Exec(`
+		INSERT INTO bookmark_status_history (job_number, old_status, new_status, comment, actor, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, jobNumber, oldStatus, newStatus, comment, actor, dbNow())
+	return err
+}
+
+// 變更書籤的狀態並記錄歷程，狀態相同時不做任何事；不更新版本，由呼叫端與其他欄位一起更新
+func setBookmarkStatus(tx *Tx, actor, jobNumber, status, comment string) error {
+	old, err := bookmarkStatus(tx, jobNumber)
+	if err != nil || old == "" || old == status {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE bookmarks SET status = ? WHERE job_number = ?", status, jobNumber); err != nil {
+		return err
+	}
+	return recordStatusChange(tx, actor, jobNumber, old, status, comment)
+}
+
+// GET /api/bookmarks/history?job_number=：書籤的狀態變更歷程，由舊到新
+func (s *Server) getStatusHistory(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
+		return
+	}
+
+	var status string
+	err := s.db.QueryRowContext(r.Context(), "SELECT status FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&status)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeDBError(w, r, err)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, old_status, new_status, comment, actor, changed_at FROM bookmark_status_history
+		WHERE job_number = ? ORDER BY id`, jobNumber)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]StatusChange, 0)
+	for rows.Next() {
+		var c StatusChange
+		if err := rows.Scan(&c.ID, &c.OldStatus, &c.NewStatus, &c.Comment, &c.Actor, scanTime(&c.ChangedAt)); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		c.ChangedAt = localTime(c.ChangedAt)
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	// 書籤已刪除但歷程還在時仍回傳歷程，status 為 null
+	if status == "" && len(items) == 0 {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
+
+	var current interface{}
+	if status != "" {
+		current = status
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"job_number": jobNumber,
+		"status":     current,
+		"total":      len(items),
+		"items":      items,
+	})
+}
@@ -0,0 +1,278 @@
+// Package store 書籤表的資料存取
+//
+// BookmarkStore 集中 bookmarks 表的查詢與寫入，HTTP 處理函式只負責解析參數與回應。
+// 讀取與寫入一律帶 context，用戶端中斷連線時查詢隨之取消並歸還連線；常用查詢以預備陳述式重複使用。
+// 寫入在呼叫端的交易中執行，事件、稽核等其他表的異動與書籤在同一筆交易提交。
+//
+// SQL 以 ? 為佔位符，由傳入的 DB、Tx 依資料庫種類轉換（見 bookmark-server 的 db.go）。
+// 書籤的型別與解密由呼叫端提供的 scan 函式處理，本套件不依賴主程式的型別。
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Columns 書籤查詢欄位，scan 函式依此順序讀取
+const Columns = "id, job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at, updated_at, version, status"
+
+// 排除垃圾桶中的書籤
+const notDeleted = "deleted_at IS NULL"
+
+// ErrNotFound 書籤不存在或已在垃圾桶中
+var ErrNotFound = errors.New("書籤不存在")
+
+// LikeEscaper 讓 LIKE 樣式中的萬用字元照字面比對，搭配 ESCAPE '\' 使用
+var LikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// DB 讀取與準備陳述式所需的連線
+type DB interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Tx 寫入所需的交易
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// BookmarkStore 書籤表的存取；B 為書籤型別，由 scan 自查詢結果讀出
+type BookmarkStore[B any] struct {
+	db   DB
+	scan func(*sql.Rows) (B, error)
+
+	// 預備陳述式，sql.Stmt 會自動在連線池中的各條連線上重新準備，與 WAL 多連線讀取相容
+	exists     *sql.Stmt
+	jobNumbers *sql.Stmt
+}
+
+// New 準備常用查詢；scan 讀取一筆 Columns 欄位的資料列，失敗時該筆略過並計入略過數
+func New[B any](ctx context.Context, db DB, scan func(*sql.Rows) (B, error)) (*BookmarkStore[B], error) {
+	s := &BookmarkStore[B]{db: db, scan: scan}
+	var err error
+	if s.exists, err = db.PrepareContext(ctx, "SELECT EXISTS(SELECT 1 FROM bookmarks WHERE job_number = ? AND "+notDeleted+")"); err != nil {
+		return nil, err
+	}
+	if s.jobNumbers, err = db.PrepareContext(ctx, "SELECT job_number FROM bookmarks WHERE "+notDeleted); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close 關閉預備陳述式
+func (s *BookmarkStore[B]) Close() {
+	if s == nil {
+		return
+	}
+	for _, stmt := range []*sql.Stmt{s.exists, s.jobNumbers} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// Query 列表條件；Where 含 WHERE 關鍵字，須包含排除垃圾桶的條件，OrderBy 不含 ORDER BY
+// 兩者都由呼叫端以固定的欄位組合，使用者輸入只能放在 Args
+type Query struct {
+	Where   string
+	Args    []interface{}
+	OrderBy string
+	Limit   int   // 0 表示不限
+	Offset  int64 // Limit 為 0 時不使用
+}
+
+// Exists 書籤是否存在（不含垃圾桶）
+func (s *BookmarkStore[B]) Exists(ctx context.Context, jobNumber string) (bool, error) {
+	var exists bool
+	err := s.exists.QueryRowContext(ctx, jobNumber).Scan(&exists)
+	return exists, err
+}
+
+// JobNumbers 所有書籤的案號（不含垃圾桶），skipped 為讀取失敗而略過的筆數
+func (s *BookmarkStore[B]) JobNumbers(ctx context.Context) (jobNumbers []string, skipped int, err error) {
+	rows, err := s.jobNumbers.QueryContext(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobNumbers = make([]string, 0)
+	for rows.Next() {
+		var jn string
+		if err := rows.Scan(&jn); err != nil {
+			skipped++
+			continue
+		}
+		jobNumbers = append(jobNumbers, jn)
+	}
+	return jobNumbers, skipped, rows.Err()
+}
+
+// Count 符合條件的書籤數，不考慮 Limit 與 Offset
+func (s *BookmarkStore[B]) Count(ctx context.Context, q Query) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookmarks "+q.Where, q.Args...).Scan(&n)
+	return n, err
+}
+
+// List 符合條件的書籤；沒有書籤時回傳空的 slice，不是 nil
+func (s *BookmarkStore[B]) List(ctx context.Context, q Query) (bookmarks []B, skipped int, err error) {
+	query := "SELECT " + Columns + " FROM bookmarks " + q.Where
+	if q.OrderBy != "" {
+		query += " ORDER BY " + q.OrderBy
+	}
+	args := q.Args
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args[:len(args):len(args)], q.Limit, q.Offset)
+	}
+	bookmarks = make([]B, 0)
+	skipped, err = s.each(ctx, query, args, func(b B) { bookmarks = append(bookmarks, b) })
+	return bookmarks, skipped, err
+}
+
+// Get 一筆書籤（不含垃圾桶），不存在時回傳 ErrNotFound
+func (s *BookmarkStore[B]) Get(ctx context.Context, jobNumber string) (B, error) {
+	var zero B
+	rows, err := s.db.QueryContext(ctx, "SELECT "+Columns+" FROM bookmarks WHERE job_number = ? AND "+notDeleted, jobNumber)
+	if err != nil {
+		return zero, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return zero, err
+		}
+		return zero, ErrNotFound
+	}
+	return s.scan(rows)
+}
+
+// Search 全文檢索的候選書籤，依優先順序、更新時間排列，逐筆交給 fn
+// fts 為 true 時以 bookmarks_fts（SQLite，trigram 斷詞）縮小範圍，否則列出所有書籤；
+// 候選不一定包含所有詞，是否符合由呼叫端逐筆比對
+func (s *BookmarkStore[B]) Search(ctx context.Context, terms []string, fts bool, fn func(B)) (skipped int, err error) {
+	where, args := "WHERE "+notDeleted, []interface{}(nil)
+	if fts {
+		var filter string
+		filter, args = ftsFilter(terms)
+		where += " AND " + filter
+	}
+	return s.each(ctx, "SELECT "+Columns+" FROM bookmarks "+where+" ORDER BY priority DESC, updated_at DESC, id DESC", args, fn)
+}
+
+// 以 bookmarks_fts 篩選的條件與參數
+// 三個字以上的詞以 MATCH 查詢索引；一、兩個字的詞 trigram 無法索引，改以 LIKE 比對同一張表
+func ftsFilter(terms []string) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) >= 3 {
+			// 以雙引號包成片語，詞中的 FTS5 語法字元照字面比對
+			where = append(where, "bookmarks_fts MATCH ?")
+			args = append(args, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+			continue
+		}
+		pattern := "%" + LikeEscaper.Replace(t) + "%"
+		where = append(where, `(title LIKE ? ESCAPE '\' OR unit_name LIKE ? ESCAPE '\' OR note LIKE ? ESCAPE '\' OR data LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	return "id IN (SELECT rowid FROM bookmarks_fts WHERE " + strings.Join(where, " AND ") + ")", args
+}
+
+// 執行查詢並逐筆讀取，讀取失敗的資料列略過
+func (s *BookmarkStore[B]) each(ctx context.Context, query string, args []interface{}, fn func(B)) (skipped int, err error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		b, err := s.scan(rows)
+		if err != nil {
+			skipped++
+			continue
+		}
+		fn(b)
+	}
+	return skipped, rows.Err()
+}
+
+// Input 新增或更新的書籤；Note 與 Data 須已由呼叫端加密（未啟用加密時為原文）
+// Note、Priority 為 nil 時更新不覆寫既有的值，新增時為空字串與 0
+type Input struct {
+	JobNumber string
+	Title     string
+	UnitName  string
+	URL       string
+	APIURL    string
+	Type      string
+	Date      int
+	Note      *string
+	Priority  *int
+	Data      string
+	Now       string // 資料庫格式的目前時間，寫入 created_at 與 updated_at
+}
+
+// Upsert 在交易中新增或更新一筆書籤
+// 已存在時只更新標案資料，保留 id、created_at 與未提供的使用者欄位，version 遞增；
+// 新增的書籤 version 為 1，以此判斷 created；書籤在垃圾桶中時移出垃圾桶，restored 為 true
+func (s *BookmarkStore[B]) Upsert(ctx context.Context, tx Tx, in Input) (created, restored bool, err error) {
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM bookmarks WHERE job_number = ? AND deleted_at IS NOT NULL", in.JobNumber).Scan(&restored); err != nil {
+		return false, false, err
+	}
+	var note string
+	var priority int
+	// 只有提供的使用者欄位才列入更新
+	userFields := ""
+	if in.Note != nil {
+		note = *in.Note
+		userFields += "note = excluded.note, "
+	}
+	if in.Priority != nil {
+		priority = *in.Priority
+		userFields += "priority = excluded.priority, "
+	}
+	var version int
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO bookmarks (job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(job_number) DO UPDATE SET
+		title = excluded.title, unit_name = excluded.unit_name, url = excluded.url,
+		api_url = excluded.api_url, type = excluded.type, date = excluded.date, data = excluded.data,
+		`+userFields+`updated_at = excluded.updated_at, version = bookmarks.version + 1, deleted_at = NULL
+	RETURNING version
+	`, in.JobNumber, in.Title, in.UnitName, in.URL, in.APIURL, in.Type, in.Date, note, priority, in.Data, in.Now, in.Now).Scan(&version)
+	if err != nil {
+		return false, false, err
+	}
+	return version == 1, restored, nil
+}
+
+// Delete 在交易中將一筆書籤移到垃圾桶，不存在或已在垃圾桶中時回傳 ErrNotFound
+func (s *BookmarkStore[B]) Delete(ctx context.Context, tx Tx, jobNumber, now string) error {
+	trashed, err := Trash(ctx, tx, jobNumber, now)
+	if err != nil {
+		return err
+	}
+	if !trashed {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Trash 在交易中將一筆書籤移到垃圾桶並遞增 version，回傳是否有書籤被移入；已在垃圾桶中時不變
+func Trash(ctx context.Context, tx Tx, jobNumber, now string) (bool, error) {
+	res, err := tx.ExecContext(ctx, "UPDATE bookmarks SET deleted_at = ?, version = version + 1 WHERE job_number = ? AND "+notDeleted, now, jobNumber)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,381 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// 測試用的書籤，只讀出需要比對的欄位
+type testBookmark struct {
+	ID        int
+	JobNumber string
+	Title     string
+	Note      string
+	Priority  int
+	Data      string
+	Version   int
+}
+
+func scanTestBookmark(rows *sql.Rows) (testBookmark, error) {
+	var b testBookmark
+	var unitName, url, apiURL, typ, note, data, createdAt, updatedAt, status sql.NullString
+	var date, priority sql.NullInt64
+	err := rows.Scan(&b.ID, &b.JobNumber, &b.Title, &unitName, &url, &apiURL, &typ, &date, &note, &priority, &data, &createdAt, &updatedAt, &b.Version, &status)
+	b.Note, b.Priority, b.Data = note.String, int(priority.Int64), data.String
+	return b, err
+}
+
+// 開啟暫存目錄中的 SQLite 檔案，設定與伺服器相同（WAL、busy_timeout、foreign_keys）
+// 伺服器的寫入由單一寫入者依序執行（見 writer.go）；這裡讓多個交易直接並行，
+// 因此以 BEGIN IMMEDIATE 開始交易，先取得寫入鎖再讀取，其他交易依 busy_timeout 等候而不是失敗
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "bookmarks.db") +
+		"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// 結構與伺服器執行所有遷移後相同，由 bookmark-server 的 TestStoreTestSchema 產生並檢查
+	schema, err := os.ReadFile(filepath.Join("testdata", "schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q, %v", mode, err)
+	}
+	return db
+}
+
+func openTestStore(t *testing.T) (*sql.DB, *BookmarkStore[testBookmark]) {
+	t.Helper()
+	db := openTestDB(t)
+	s, err := New(context.Background(), db, scanTestBookmark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return db, s
+}
+
+func testNow() string {
+	return time.Now().UTC().Format("2006-01-02 15:04:05.000")
+}
+
+// 在交易中執行 fn 並提交
+func inTx(t *testing.T, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	t.Helper()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func upsert(t *testing.T, db *sql.DB, s *BookmarkStore[testBookmark], in Input) (created, restored bool) {
+	t.Helper()
+	in.Now = testNow()
+	err := inTx(t, db, func(tx *sql.Tx) error {
+		var err error
+		created, restored, err = s.Upsert(context.Background(), tx, in)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Upsert(%s): %v", in.JobNumber, err)
+	}
+	return created, restored
+}
+
+func strPtr(s string) *string { return &s }
+func intPtr(n int) *int       { return &n }
+
+func TestUpsert(t *testing.T) {
+	db, s := openTestStore(t)
+	ctx := context.Background()
+
+	// 依序套用，每一步比對結果與之後的書籤
+	steps := []struct {
+		name     string
+		in       Input
+		trash    bool // 套用前先移到垃圾桶
+		created  bool
+		restored bool
+		want     testBookmark
+	}{
+		{"create", Input{JobNumber: "A001", Title: "道路養護", Note: strPtr("週五前報價"), Priority: intPtr(3), Data: `{"a":1}`},
+			false, true, false, testBookmark{Title: "道路養護", Note: "週五前報價", Priority: 3, Data: `{"a":1}`, Version: 1}},
+		{"update keeps user fields", Input{JobNumber: "A001", Title: "道路養護（更正）", Data: `{"a":2}`},
+			false, false, false, testBookmark{Title: "道路養護（更正）", Note: "週五前報價", Priority: 3, Data: `{"a":2}`, Version: 2}},
+		{"update clears note", Input{JobNumber: "A001", Title: "道路養護", Note: strPtr(""), Priority: intPtr(0)},
+			false, false, false, testBookmark{Title: "道路養護", Note: "", Priority: 0, Version: 3}},
+		{"restore from trash", Input{JobNumber: "A001", Title: "道路養護", Priority: intPtr(5)},
+			true, false, true, testBookmark{Title: "道路養護", Priority: 5, Version: 5}},
+	}
+	for _, st := range steps {
+		if st.trash {
+			if err := inTx(t, db, func(tx *sql.Tx) error { return s.Delete(ctx, tx, st.in.JobNumber, testNow()) }); err != nil {
+				t.Fatalf("%s: Delete: %v", st.name, err)
+			}
+		}
+		created, restored := upsert(t, db, s, st.in)
+		if created != st.created || restored != st.restored {
+			t.Errorf("%s: created = %v restored = %v, want %v %v", st.name, created, restored, st.created, st.restored)
+		}
+		got, err := s.Get(ctx, st.in.JobNumber)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", st.name, err)
+		}
+		st.want.ID, st.want.JobNumber = got.ID, st.in.JobNumber
+		if got != st.want {
+			t.Errorf("%s: got %+v, want %+v", st.name, got, st.want)
+		}
+	}
+}
+
+func TestListGetDelete(t *testing.T) {
+	db, s := openTestStore(t)
+	ctx := context.Background()
+	for i, jn := range []string{"A001", "A002", "A003", "A004"} {
+		upsert(t, db, s, Input{JobNumber: jn, Title: "標案 " + jn, Priority: intPtr(i)})
+	}
+	if err := inTx(t, db, func(tx *sql.Tx) error { return s.Delete(ctx, tx, "A004", testNow()) }); err != nil {
+		t.Fatal(err)
+	}
+
+	listTests := []struct {
+		name  string
+		q     Query
+		want  string
+		count int
+	}{
+		{"all", Query{Where: "WHERE " + notDeleted, OrderBy: "priority DESC"}, "A003,A002,A001", 3},
+		{"filter", Query{Where: "WHERE " + notDeleted + " AND priority >= ?", Args: []interface{}{1}, OrderBy: "id"}, "A002,A003", 2},
+		{"page", Query{Where: "WHERE " + notDeleted, OrderBy: "id", Limit: 2, Offset: 2}, "A003", 3},
+		{"empty", Query{Where: "WHERE " + notDeleted + " AND priority > ?", Args: []interface{}{9}}, "", 0},
+	}
+	for _, tt := range listTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped, err := s.List(ctx, tt.q)
+			if err != nil || skipped != 0 || got == nil {
+				t.Fatalf("List = %v, %d, %v", got, skipped, err)
+			}
+			var jns []string
+			for _, b := range got {
+				jns = append(jns, b.JobNumber)
+			}
+			if strings.Join(jns, ",") != tt.want {
+				t.Errorf("List = %v, want %s", jns, tt.want)
+			}
+			if n, err := s.Count(ctx, tt.q); err != nil || n != tt.count {
+				t.Errorf("Count = %d, %v; want %d", n, err, tt.count)
+			}
+		})
+	}
+
+	getTests := []struct {
+		jobNumber string
+		exists    bool
+	}{
+		{"A001", true},
+		{"A004", false}, // 垃圾桶中
+		{"Z999", false},
+	}
+	for _, tt := range getTests {
+		_, err := s.Get(ctx, tt.jobNumber)
+		if tt.exists != (err == nil) || (!tt.exists && !errors.Is(err, ErrNotFound)) {
+			t.Errorf("Get(%s) err = %v", tt.jobNumber, err)
+		}
+		if exists, err := s.Exists(ctx, tt.jobNumber); err != nil || exists != tt.exists {
+			t.Errorf("Exists(%s) = %v, %v", tt.jobNumber, exists, err)
+		}
+	}
+	if jns, skipped, err := s.JobNumbers(ctx); err != nil || skipped != 0 || len(jns) != 3 {
+		t.Errorf("JobNumbers = %v, %d, %v", jns, skipped, err)
+	}
+
+	// 已在垃圾桶中或不存在時回傳 ErrNotFound，version 不變
+	for _, jn := range []string{"A004", "Z999"} {
+		if err := inTx(t, db, func(tx *sql.Tx) error { return s.Delete(ctx, tx, jn, testNow()) }); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete(%s) err = %v, want ErrNotFound", jn, err)
+		}
+	}
+	var version int
+	db.QueryRow("SELECT version FROM bookmarks WHERE job_number = 'A004'").Scan(&version)
+	if version != 2 {
+		t.Errorf("A004 version = %d, want 2", version)
+	}
+
+	// 取消的 context 不執行查詢
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := s.List(canceled, listTests[0].q); err == nil {
+		t.Error("List 以取消的 context 沒有回傳錯誤")
+	}
+	// 寫入也一樣，交易中的書籤不變
+	err := inTx(t, db, func(tx *sql.Tx) error {
+		if _, _, err := s.Upsert(canceled, tx, Input{JobNumber: "A001", Title: "取消", Now: testNow()}); err == nil {
+			return errors.New("Upsert 以取消的 context 沒有回傳錯誤")
+		}
+		if err := s.Delete(canceled, tx, "A001", testNow()); err == nil {
+			return errors.New("Delete 以取消的 context 沒有回傳錯誤")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if b, err := s.Get(ctx, "A001"); err != nil || b.Title != "標案 A001" {
+		t.Errorf("Get(A001) = %+v, %v", b, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db, s := openTestStore(t)
+	ctx := context.Background()
+	for _, in := range []Input{
+		{JobNumber: "A001", Title: "道路養護工程", Priority: intPtr(1)},
+		{JobNumber: "A002", Title: "橋梁維修工程", Note: strPtr("預算 100% 執行"), Priority: intPtr(5)},
+		{JobNumber: "A003", Title: "資訊系統維護", Data: `{"budget":"1000"}`, Priority: intPtr(3)},
+	} {
+		upsert(t, db, s, in)
+	}
+
+	tests := []struct {
+		name  string
+		terms []string
+		fts   bool
+		want  string
+	}{
+		{"scan lists all", []string{"工程"}, false, "A002,A003,A001"},
+		{"trigram match", []string{"維修工程"}, true, "A002"},
+		{"short term like", []string{"工程"}, true, "A002,A001"},
+		{"all terms", []string{"工程", "道路"}, true, "A001"},
+		{"literal percent", []string{"0%"}, true, "A002"},
+		{"data", []string{"1000"}, true, "A003"},
+		{"no match", []string{"不存在的詞"}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var jns []string
+			skipped, err := s.Search(ctx, tt.terms, tt.fts, func(b testBookmark) { jns = append(jns, b.JobNumber) })
+			if err != nil || skipped != 0 {
+				t.Fatalf("Search = %d, %v", skipped, err)
+			}
+			if strings.Join(jns, ",") != tt.want {
+				t.Errorf("Search(%q) = %v, want %s", tt.terms, jns, tt.want)
+			}
+		})
+	}
+}
+
+// 多個交易同時新增或更新同一個案號：全部成功、沒有 database is locked，只有一個是新增
+func TestConcurrentUpsert(t *testing.T) {
+	tests := []struct {
+		name       string
+		writers    int
+		jobNumbers int // 案號數，writers 平均分配
+		readers    int // 同時讀取列表的 goroutine 數
+	}{
+		{"same job number", 16, 1, 0},
+		{"same job number with readers", 16, 1, 4},
+		{"few job numbers", 24, 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, s := openTestStore(t)
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			created := map[string]int{}
+			errs := make(chan error, tt.writers+tt.readers)
+			done := make(chan struct{})
+			for i := 0; i < tt.readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						if _, _, err := s.List(ctx, Query{Where: "WHERE " + notDeleted}); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+			}
+			var writers sync.WaitGroup
+			for i := 0; i < tt.writers; i++ {
+				writers.Add(1)
+				go func(i int) {
+					defer writers.Done()
+					jn := fmt.Sprintf("A%03d", i%tt.jobNumbers)
+					in := Input{JobNumber: jn, Title: fmt.Sprintf("第 %d 次", i), Priority: intPtr(i), Now: testNow()}
+					err := inTx(t, db, func(tx *sql.Tx) error {
+						c, _, err := s.Upsert(context.Background(), tx, in)
+						if err == nil && c {
+							mu.Lock()
+							created[jn]++
+							mu.Unlock()
+						}
+						return err
+					})
+					if err != nil {
+						errs <- err
+					}
+				}(i)
+			}
+			writers.Wait()
+			close(done)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if strings.Contains(err.Error(), "locked") || strings.Contains(err.Error(), "busy") {
+					t.Errorf("並行寫入失敗: %v", err)
+				} else {
+					t.Error(err)
+				}
+			}
+
+			perJob := tt.writers / tt.jobNumbers
+			for i := 0; i < tt.jobNumbers; i++ {
+				jn := fmt.Sprintf("A%03d", i)
+				if created[jn] != 1 {
+					t.Errorf("%s 新增 %d 次, want 1", jn, created[jn])
+				}
+				b, err := s.Get(ctx, jn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b.Version != perJob {
+					t.Errorf("%s version = %d, want %d", jn, b.Version, perJob)
+				}
+			}
+			if n, err := s.Count(ctx, Query{Where: "WHERE " + notDeleted}); err != nil || n != tt.jobNumbers {
+				t.Errorf("Count = %d, %v; want %d", n, err, tt.jobNumbers)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+-- 執行 migrations.go 所有遷移後的 SQLite 結構，由 TestStoreTestSchema 產生，請勿手動修改
+CREATE TABLE app_meta (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			);
+CREATE TABLE attachments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL REFERENCES bookmarks(job_number) ON DELETE CASCADE ON UPDATE CASCADE,
+				kind TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				content_type TEXT NOT NULL DEFAULT '',
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				stored_path TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				UNIQUE (job_number, kind, filename)
+			);
+CREATE TABLE attachments_archive (
+				id INTEGER PRIMARY KEY,
+				job_number TEXT NOT NULL,
+				kind TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				content_type TEXT NOT NULL DEFAULT '',
+				size INTEGER NOT NULL,
+				sha256 TEXT NOT NULL,
+				stored_path TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				archived_at DATETIME NOT NULL
+			);
+CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		actor TEXT NOT NULL DEFAULT '',
+		remote_addr TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		job_numbers TEXT NOT NULL DEFAULT '',
+		summary TEXT NOT NULL DEFAULT ''
+	);
+CREATE TABLE bookmark_categories (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				category TEXT NOT NULL,
+				UNIQUE (job_number, category)
+			);
+CREATE TABLE bookmark_changes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				field TEXT NOT NULL,
+				old_value TEXT NOT NULL DEFAULT '',
+				new_value TEXT NOT NULL DEFAULT '',
+				detected_at DATETIME NOT NULL,
+				seen INTEGER NOT NULL DEFAULT 0,
+				seen_at DATETIME
+			);
+CREATE TABLE bookmark_keywords (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				keyword TEXT NOT NULL,
+				UNIQUE (job_number, keyword)
+			);
+CREATE TABLE bookmark_status_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				old_status TEXT NOT NULL,
+				new_status TEXT NOT NULL,
+				comment TEXT NOT NULL DEFAULT '',
+				actor TEXT NOT NULL DEFAULT '',
+				changed_at DATETIME NOT NULL
+			);
+CREATE TABLE bookmark_tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				job_number TEXT NOT NULL,
+				tag_id INTEGER NOT NULL,
+				UNIQUE (job_number, tag_id)
+			);
+CREATE TABLE bookmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_number TEXT UNIQUE NOT NULL,
+		title TEXT NOT NULL,
+		unit_name TEXT,
+		url TEXT,
+		api_url TEXT,
+		type TEXT,
+		date INTEGER,
+		note TEXT DEFAULT '',
+		priority INTEGER DEFAULT 0,
+		data TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	, updated_at DATETIME, version INTEGER NOT NULL DEFAULT 1, status TEXT NOT NULL DEFAULT 'watching', deleted_at DATETIME);
+CREATE TABLE bookmarks_archive (
+				id INTEGER PRIMARY KEY,
+				job_number TEXT UNIQUE NOT NULL,
+				title TEXT NOT NULL,
+				unit_name TEXT,
+				url TEXT,
+				api_url TEXT,
+				type TEXT,
+				date INTEGER,
+				note TEXT DEFAULT '',
+				priority INTEGER DEFAULT 0,
+				data TEXT,
+				created_at DATETIME,
+				updated_at DATETIME,
+				version INTEGER NOT NULL DEFAULT 1,
+				archived_at DATETIME NOT NULL
+			, status TEXT NOT NULL DEFAULT 'watching');
+CREATE VIRTUAL TABLE bookmarks_fts USING fts5(title, unit_name, note, data, tokenize = 'trigram');
+CREATE TABLE calendar_events (
+				job_number TEXT PRIMARY KEY,
+				deadline DATETIME NOT NULL,
+				sequence INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME NOT NULL
+			);
+CREATE TABLE email_digests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				digest_date TEXT NOT NULL,
+				manual INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				recipients TEXT NOT NULL DEFAULT '',
+				new_count INTEGER NOT NULL DEFAULT 0,
+				deadline_count INTEGER NOT NULL DEFAULT 0,
+				changed_count INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				last_event_id INTEGER NOT NULL DEFAULT 0
+			);
+CREATE TABLE events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				actor TEXT NOT NULL DEFAULT '',
+				entity_type TEXT NOT NULL,
+				entity_key TEXT NOT NULL,
+				action TEXT NOT NULL,
+				payload TEXT
+			);
+CREATE TABLE ingest_bookmarks (
+				job_number TEXT PRIMARY KEY,
+				created_at DATETIME NOT NULL
+			);
+CREATE TABLE ingest_runs (
+				date TEXT PRIMARY KEY,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				fetched INTEGER NOT NULL DEFAULT 0,
+				matched INTEGER NOT NULL DEFAULT 0,
+				bookmarked INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				started_at DATETIME,
+				finished_at DATETIME,
+				next_attempt_at DATETIME
+			);
+CREATE TABLE notification_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				provider TEXT NOT NULL,
+				trigger_name TEXT NOT NULL,
+				job_number TEXT NOT NULL DEFAULT '',
+				dedup_key TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				http_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				message TEXT NOT NULL DEFAULT ''
+			);
+CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+CREATE TABLE shares (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				token_hash TEXT NOT NULL UNIQUE,
+				title TEXT NOT NULL DEFAULT '',
+				job_numbers TEXT NOT NULL DEFAULT '[]',
+				search TEXT NOT NULL DEFAULT '',
+				include_notes INTEGER NOT NULL DEFAULT 0,
+				views INTEGER NOT NULL DEFAULT 0,
+				expires_at DATETIME,
+				revoked_at DATETIME,
+				created_at DATETIME NOT NULL
+			);
+CREATE TABLE stats (
+				dimension TEXT NOT NULL,
+				value TEXT NOT NULL,
+				count INTEGER NOT NULL,
+				PRIMARY KEY (dimension, value)
+			);
+CREATE TABLE sync_state (
+				peer TEXT PRIMARY KEY,
+				peer_instance TEXT NOT NULL DEFAULT '',
+				pulled_through INTEGER,
+				pushed_through INTEGER,
+				last_synced_at DATETIME
+			);
+CREATE TABLE tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL,
+				name_key TEXT NOT NULL UNIQUE
+			);
+CREATE TABLE tender_awards (
+				job_number TEXT PRIMARY KEY,
+				date INTEGER NOT NULL DEFAULT 0,
+				companies TEXT NOT NULL DEFAULT '[]',
+				amount TEXT NOT NULL DEFAULT '',
+				source TEXT NOT NULL,
+				detected_at DATETIME NOT NULL
+			);
+CREATE TABLE tender_cache (
+				job_number TEXT PRIMARY KEY,
+				api_url TEXT NOT NULL,
+				body TEXT NOT NULL,
+				etag TEXT,
+				fetched_at DATETIME NOT NULL
+			);
+CREATE TABLE watchlist (
+				name TEXT PRIMARY KEY,
+				keywords TEXT NOT NULL DEFAULT '[]',
+				negative_keywords TEXT NOT NULL DEFAULT '[]',
+				priority INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			);
+CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event_id INTEGER NOT NULL,
+				event_type TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL,
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at DATETIME,
+				response_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT '',
+				redelivery_of INTEGER,
+				created_at DATETIME NOT NULL,
+				delivered_at DATETIME
+			);
+CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				events TEXT NOT NULL DEFAULT '',
+				allow_private INTEGER NOT NULL DEFAULT 0,
+				active INTEGER NOT NULL DEFAULT 1,
+				last_event_id INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			);
+CREATE TABLE weekly_reports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				created_at DATETIME NOT NULL,
+				report_date TEXT NOT NULL,
+				period_start DATETIME NOT NULL,
+				period_end DATETIME NOT NULL,
+				manual INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				items INTEGER NOT NULL DEFAULT 0,
+				agencies INTEGER NOT NULL DEFAULT 0,
+				markdown_file TEXT NOT NULL,
+				html_file TEXT NOT NULL,
+				webhook_status INTEGER NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT ''
+			);
+CREATE INDEX idx_attachments_archive_job ON attachments_archive(job_number);
+CREATE INDEX idx_attachments_sha256 ON attachments(sha256);
+CREATE INDEX idx_audit_created_at ON audit_log(created_at);
+CREATE INDEX idx_bookmark_categories_category ON bookmark_categories(category, job_number);
+CREATE INDEX idx_bookmark_changes_job ON bookmark_changes(job_number, id);
+CREATE INDEX idx_bookmark_changes_seen ON bookmark_changes(seen, id);
+CREATE INDEX idx_bookmark_keywords_keyword ON bookmark_keywords(keyword, job_number);
+CREATE INDEX idx_bookmark_status_history_job ON bookmark_status_history(job_number, id);
+CREATE INDEX idx_bookmark_tags_tag ON bookmark_tags(tag_id, job_number);
+CREATE INDEX idx_bookmarks_date ON bookmarks(date);
+CREATE INDEX idx_bookmarks_priority_created ON bookmarks(priority DESC, created_at DESC, id DESC);
+CREATE INDEX idx_bookmarks_status ON bookmarks(status);
+CREATE INDEX idx_bookmarks_trashed ON bookmarks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_bookmarks_type ON bookmarks(type);
+CREATE INDEX idx_bookmarks_unit_name ON bookmarks(unit_name);
+CREATE INDEX idx_email_digests_date ON email_digests(digest_date);
+CREATE INDEX idx_events_created_at ON events(created_at);
+CREATE INDEX idx_events_entity ON events(entity_type, entity_key);
+CREATE INDEX idx_notification_log_dedup ON notification_log(trigger_name, job_number, dedup_key);
+CREATE INDEX idx_tender_cache_fetched_at ON tender_cache(fetched_at);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+CREATE INDEX idx_weekly_reports_period_end ON weekly_reports(period_end);
+CREATE TRIGGER bookmark_categories_version_delete AFTER DELETE ON bookmark_categories BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_categories_version_insert AFTER INSERT ON bookmark_categories BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_categories_version_update AFTER UPDATE ON bookmark_categories BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_keywords_version_delete AFTER DELETE ON bookmark_keywords BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_keywords_version_insert AFTER INSERT ON bookmark_keywords BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_keywords_version_update AFTER UPDATE ON bookmark_keywords BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_tags_version_delete AFTER DELETE ON bookmark_tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_tags_version_insert AFTER INSERT ON bookmark_tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmark_tags_version_update AFTER UPDATE ON bookmark_tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmarks_data_json_insert BEFORE INSERT ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NEW.data NOT LIKE 'enc:v1:%' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+CREATE TRIGGER bookmarks_data_json_update BEFORE UPDATE OF data ON bookmarks
+			WHEN NEW.data IS NOT NULL AND NEW.data <> '' AND NEW.data NOT LIKE 'enc:v1:%' AND NOT json_valid(NEW.data)
+			BEGIN SELECT RAISE(ABORT, 'CHECK constraint failed: json_valid(data)'); END;
+CREATE TRIGGER bookmarks_fts_delete AFTER DELETE ON bookmarks BEGIN
+				DELETE FROM bookmarks_fts WHERE rowid = OLD.id;
+			END;
+CREATE TRIGGER bookmarks_fts_insert AFTER INSERT ON bookmarks BEGIN
+				INSERT INTO bookmarks_fts (rowid, title, unit_name, note, data) VALUES (
+					NEW.id, COALESCE(NEW.title, ''), COALESCE(NEW.unit_name, ''),
+					CASE WHEN NEW.note LIKE 'enc:v1:%' THEN '' ELSE COALESCE(NEW.note, '') END,
+					CASE WHEN json_valid(NEW.data) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_tree(NEW.data) WHERE atom IS NOT NULL) ELSE '' END);
+			END;
+CREATE TRIGGER bookmarks_fts_update AFTER UPDATE ON bookmarks BEGIN
+				DELETE FROM bookmarks_fts WHERE rowid = OLD.id;
+				INSERT INTO bookmarks_fts (rowid, title, unit_name, note, data) VALUES (
+					NEW.id, COALESCE(NEW.title, ''), COALESCE(NEW.unit_name, ''),
+					CASE WHEN NEW.note LIKE 'enc:v1:%' THEN '' ELSE COALESCE(NEW.note, '') END,
+					CASE WHEN json_valid(NEW.data) THEN (SELECT COALESCE(group_concat(value, ' '), '') FROM json_tree(NEW.data) WHERE atom IS NOT NULL) ELSE '' END);
+			END;
+CREATE TRIGGER bookmarks_version_delete AFTER DELETE ON bookmarks BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmarks_version_insert AFTER INSERT ON bookmarks BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER bookmarks_version_update AFTER UPDATE ON bookmarks BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER tags_version_delete AFTER DELETE ON tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER tags_version_insert AFTER INSERT ON tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
+CREATE TRIGGER tags_version_update AFTER UPDATE ON tags BEGIN
+				UPDATE app_meta SET value = CAST(value AS INTEGER) + 1 WHERE key = 'bookmarks_version';
+				UPDATE app_meta SET value = CAST(CAST(unixepoch('now', 'subsec') * 1000 AS INTEGER) AS TEXT) WHERE key = 'bookmarks_modified_at';
+			END;
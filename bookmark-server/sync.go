@@ -0,0 +1,745 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 兩台伺服器之間的書籤雙向同步
+//
+// 以 events 表為基礎：GET /api/sync/pull?since= 回傳游標之後書籤的新增、更新（upsert）與刪除（delete，即 tombstone），
+// 同一書籤只留最後一筆；POST /api/sync/push 套用對方送來的一批異動。
+// 衝突以 updated_at 判斷，較新的一方勝出（last-writer-wins）；本機較新或本機在對方更新之後刪除時保留本機，
+// 並寫入 sync_conflict 事件。時間相同時視為已同步，略過。
+// 套用遠端異動時事件的 actor 為 sync:<對方的 instance_id>，拉取時排除對方自己造成的事件，異動不會來回傳送，
+// 因此連續同步兩次時第二次不會有任何異動。
+//
+// POST /api/sync/run {"peer": 名稱或網址} 與設定檔 sync.peers 中的對象（或直接指定的網址）進行一輪同步：先拉取再推送，
+// 兩個方向的游標記錄在 sync_state；第一次同步、對方換了資料庫（instance_id 不同）或游標已依 event_retention 刪除時，
+// 改以目前所有書籤的快照（snapshot）同步。快照不含 tombstone，之前的刪除不會傳到對方。
+// 刪除事件是唯一的 tombstone，依 event_retention 刪除後，對方仍有的舊版本可能被重新加入。
+// 封存（archive）不同步，各伺服器各自封存。
+
+// SyncConfig 同步設定
+type SyncConfig struct {
+	Peers []SyncPeer `json:"peers"`
+}
+
+// SyncPeer 同步對象
+type SyncPeer struct {
+	Name   string `json:"name"`    // 在 /api/sync/run 與命令列中使用的名稱
+	URL    string `json:"url"`     // 對方伺服器的網址，例如 http://nas:8080
+	APIKey string `json:"api_key"` // 對方要求 API 金鑰時使用
+}
+
+// 檢查設定
+func (c *SyncConfig) validate() error {
+	names := map[string]bool{}
+	for i := range c.Peers {
+		p := &c.Peers[i]
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("sync.peers 的 url 必須是 http 或 https 網址: %q", p.URL)
+		}
+		p.URL = strings.TrimSuffix(p.URL, "/")
+		if p.Name == "" {
+			p.Name = u.Host
+		}
+		if names[p.Name] {
+			return fmt.Errorf("sync.peers 的名稱重複: %s", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return nil
+}
+
+// 依名稱或網址尋找同步對象
+func (c *SyncConfig) find(peer string) *SyncPeer {
+	peer = strings.TrimSuffix(peer, "/")
+	for i := range c.Peers {
+		if c.Peers[i].Name == peer || strings.EqualFold(c.Peers[i].URL, peer) {
+			return &c.Peers[i]
+		}
+	}
+	return nil
+}
+
+// 同步異動的種類
+const (
+	syncUpsert = "upsert"
+	syncDelete = "delete"
+
+	actionSyncConflict = "sync_conflict"
+)
+
+const (
+	syncDefaultLimit = 200
+	syncMaxLimit     = 1000
+	syncPushMaxBytes = 32 << 20
+	syncPeerTimeout  = 2 * time.Minute
+)
+
+// instance_id 為 32 個十六進位字元
+var instanceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// 同一時間只執行一輪同步
+var syncRunning sync.Mutex
+
+// SyncBookmark 同步的書籤內容；日期保留原始數值，舊資料中無效的日期也能同步
+type SyncBookmark struct {
+	Title     string    `json:"title"`
+	UnitName  string    `json:"unit_name"`
+	URL       string    `json:"url"`
+	APIURL    string    `json:"api_url"`
+	Type      string    `json:"type"`
+	Date      int64     `json:"date"`
+	Note      string    `json:"note"`
+	Priority  int       `json:"priority"`
+	Data      string    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Tags      []string  `json:"tags"`             // nil（較舊的伺服器或事件）時不變更本機的標籤
+	Status    string    `json:"status,omitempty"` // 空字串（較舊的伺服器或事件）時不變更本機的狀態
+}
+
+// SyncChange 一筆書籤異動；刪除時 updated_at 為刪除的時間
+type SyncChange struct {
+	JobNumber string        `json:"job_number"`
+	Op        string        `json:"op"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Bookmark  *SyncBookmark `json:"bookmark,omitempty"`
+}
+
+// 一批異動；next_since 為下一次拉取的游標
+type syncBatch struct {
+	InstanceID string       `json:"instance_id"`
+	Changes    []SyncChange `json:"changes"`
+	NextSince  int64        `json:"next_since"`
+	HasMore    bool         `json:"has_more"`
+	Snapshot   bool         `json:"snapshot,omitempty"`
+}
+
+// SyncConflict 未套用的遠端異動
+type SyncConflict struct {
+	JobNumber       string    `json:"job_number"`
+	Op              string    `json:"op"`
+	Reason          string    `json:"reason"` // local_newer、deleted_locally、invalid_data
+	RemoteUpdatedAt time.Time `json:"remote_updated_at"`
+	LocalUpdatedAt  time.Time `json:"local_updated_at,omitempty"`
+}
+
+// SyncResult 套用一批異動的結果
+type SyncResult struct {
+	Applied   int            `json:"applied"`
+	Skipped   int            `json:"skipped"`
+	Conflicts []SyncConflict `json:"conflicts"`
+}
+
+func (r *SyncResult) add(other SyncResult) {
+	r.Applied += other.Applied
+	r.Skipped += other.Skipped
+	r.Conflicts = append(r.Conflicts, other.Conflicts...)
+}
+
+// 本機資料庫的識別碼，存於 app_meta；第一次使用時產生（唯讀模式不產生，回傳空字串）
+func (s *Server) instanceID(ctx context.Context) (string, error) {
+	var id string
+	if err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(value), '') FROM app_meta WHERE key = 'instance_id'").Scan(&id); err != nil || id != "" || s.cfg.ReadOnly {
+		return id, err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	err := s.db.withTx(ctx, func(tx *Tx) error {
+		_, err := tx.Exec("INSERT INTO app_meta (key, value) VALUES ('instance_id', ?) ON CONFLICT(key) DO NOTHING", hex.EncodeToString(b))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	err = s.db.QueryRowContext(ctx, "SELECT value FROM app_meta WHERE key = 'instance_id'").Scan(&id)
+	return id, err
+}
+
+// 游標 since 之後的書籤異動（最後由 excludeActor 異動的書籤除外），或 snapshot 為 true 時目前所有書籤
+func (s *Server) syncChanges(ctx context.Context, er eventRange, since int64, snapshot bool, excludeActor string, limit int) (*syncBatch, error) {
+	batch := &syncBatch{Changes: []SyncChange{}, NextSince: er.latest, Snapshot: snapshot}
+	if snapshot {
+		// 最新的 id 在讀取書籤之前取得，之後的異動會在下一次拉取時重複送出，依 updated_at 略過
+		rows, err := s.db.QueryContext(ctx, "SELECT "+bookmarkColumns+" FROM bookmarks WHERE "+notDeleted+" ORDER BY id")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			b, err := scanBookmark(rows, s.db.cipher)
+			if err != nil {
+				return nil, err
+			}
+			batch.Changes = append(batch.Changes, SyncChange{JobNumber: b.JobNumber, Op: syncUpsert, UpdatedAt: b.UpdatedAt, Bookmark: syncBookmarkOf(&b)})
+		}
+		return batch, rows.Err()
+	}
+
+	query := `SELECT id, created_at, actor, entity_type, entity_key, action, payload FROM events
+		WHERE id > ? AND id <= ? AND entity_type = ? AND action IN (?, ?, ?, ?)`
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY id LIMIT ?", since, er.latest, entityBookmark, actionCreate, actionUpdate, actionDelete, actionRestore, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.scanEvents(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > limit {
+		events = events[:limit]
+		batch.NextSince = events[len(events)-1].ID
+		batch.HasMore = true
+	}
+
+	// 同一書籤只留最後一筆，依最後一筆的順序排列；最後一筆由對方造成時對方已是最新內容，整筆略過，
+	// 否則之前被對方覆寫的本機版本會送回去成為衝突
+	latest := map[string]int{}
+	for i, e := range events {
+		latest[e.EntityKey] = i
+	}
+	for i, e := range events {
+		if latest[e.EntityKey] != i || (excludeActor != "" && e.Actor == excludeActor) {
+			continue
+		}
+		c := SyncChange{JobNumber: e.EntityKey, Op: syncUpsert}
+		if e.Action == actionDelete {
+			c.Op, c.UpdatedAt = syncDelete, e.CreatedAt
+		} else {
+			c.Bookmark = &SyncBookmark{}
+			if err := json.Unmarshal(e.Payload, c.Bookmark); err != nil {
+				return nil, fmt.Errorf("事件 %d 的內容無法解析: %w", e.ID, err)
+			}
+			c.UpdatedAt = c.Bookmark.UpdatedAt
+		}
+		batch.Changes = append(batch.Changes, c)
+	}
+	return batch, nil
+}
+
+func syncBookmarkOf(b *Bookmark) *SyncBookmark {
+	return &SyncBookmark{
+		Title: b.Title, UnitName: b.UnitName, URL: b.URL, APIURL: b.APIURL, Type: b.Type, Date: int64(b.Date),
+		Note: b.Note, Priority: b.Priority, Data: b.Data, CreatedAt: b.CreatedAt, UpdatedAt: b.UpdatedAt, Tags: b.Tags,
+		Status: b.Status,
+	}
+}
+
+// 本機最後一次刪除書籤的時間（tombstone），沒有時為零值
+func lastDeletion(tx *Tx, jobNumber string) (time.Time, error) {
+	var t time.Time
+	err := tx.QueryRow("SELECT MAX(created_at) FROM events WHERE entity_type = ? AND entity_key = ? AND action = ?",
+		entityBookmark, jobNumber, actionDelete).Scan(scanTime(&t))
+	return t, err
+}
+
+// 在一個交易中套用遠端的異動，actor 為 sync:<對方的 instance_id>
+func (s *Server) applySyncChanges(r *http.Request, actor string, changes []SyncChange) (SyncResult, error) {
+	var result SyncResult
+	if len(changes) == 0 {
+		return result, nil
+	}
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		result = SyncResult{}
+		var applied []string
+		for _, c := range changes {
+			local, err := bookmarkSnapshot(tx, c.JobNumber)
+			if err != nil {
+				return err
+			}
+			remoteAt := c.UpdatedAt.Truncate(time.Millisecond)
+			conflict := SyncConflict{JobNumber: c.JobNumber, Op: c.Op, RemoteUpdatedAt: c.UpdatedAt}
+			if local != nil {
+				conflict.LocalUpdatedAt = local.UpdatedAt
+			}
+
+			switch {
+			case c.Op == syncUpsert && validateData(c.Bookmark.Data) != nil:
+				conflict.Reason = "invalid_data"
+			case c.Op == syncUpsert && local == nil:
+				deletedAt, err := lastDeletion(tx, c.JobNumber)
+				if err != nil {
+					return err
+				}
+				if deletedAt.After(remoteAt) {
+					conflict.Reason, conflict.LocalUpdatedAt = "deleted_locally", localTime(deletedAt)
+				}
+			case local == nil:
+				result.Skipped++ // 本機沒有此書籤，不需要刪除
+				continue
+			case local.UpdatedAt.Truncate(time.Millisecond).Equal(remoteAt):
+				result.Skipped++
+				continue
+			case local.UpdatedAt.After(remoteAt):
+				conflict.Reason = "local_newer"
+			}
+
+			if conflict.Reason != "" {
+				result.Conflicts = append(result.Conflicts, conflict)
+				if err := writeEvent(tx, actor, entityBookmark, c.JobNumber, actionSyncConflict, map[string]interface{}{
+					"conflict": conflict,
+					"remote":   c.Bookmark,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			if c.Op == syncDelete {
+				err = applySyncDelete(r.Context(), tx, actor, c.JobNumber, local)
+			} else {
+				err = applySyncUpsert(tx, actor, c.JobNumber, c.Bookmark, local == nil)
+			}
+			if err != nil {
+				return err
+			}
+			result.Applied++
+			applied = append(applied, c.JobNumber)
+		}
+		if len(applied) == 0 && len(result.Conflicts) == 0 {
+			return nil
+		}
+		entry := newAuditEntry(r, fmt.Sprintf("同步（%s）：套用 %d 筆、略過 %d 筆、衝突 %d 筆", actor, result.Applied, result.Skipped, len(result.Conflicts)), applied...)
+		return writeAudit(tx, entry)
+	})
+	if err == nil && result.Applied > 0 {
+		s.markChanged()
+	}
+	return result, err
+}
+
+// 以遠端的內容覆寫（或新增）書籤，保留遠端的 created_at 與 updated_at，兩邊才能以 updated_at 比較；書籤在垃圾桶中時一併移出
+func applySyncUpsert(tx *Tx, actor, jobNumber string, b *SyncBookmark, created bool) error {
+	note, err := tx.cipher.seal("note", b.Note)
+	if err != nil {
+		return err
+	}
+	data, err := tx.cipher.seal("data", b.Data)
+	if err != nil {
+		return err
+	}
+	createdAt := b.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = b.UpdatedAt
+	}
+	_, err = tx.Exec(`
+		INSERT INTO bookmarks (job_number, title, unit_name, url, api_url, type, date, note, priority, data, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_number) DO UPDATE SET
+			title = excluded.title, unit_name = excluded.unit_name, url = excluded.url,
+			api_url = excluded.api_url, type = excluded.type, date = excluded.date, data = excluded.data,
+			note = excluded.note, priority = excluded.priority,
+			updated_at = excluded.updated_at, version = bookmarks.version + 1, deleted_at = NULL
+	`, jobNumber, b.Title, b.UnitName, b.URL, b.APIURL, b.Type, b.Date, note, b.Priority, data, dbTime(createdAt), dbTime(b.UpdatedAt))
+	if err != nil {
+		return err
+	}
+	if err := syncBookmarkTerms(tx, jobNumber, b.Data); err != nil {
+		return err
+	}
+	if b.Tags != nil {
+		tags, err := normalizeTags(b.Tags)
+		if err != nil {
+			return err
+		}
+		if err := setBookmarkTags(tx, jobNumber, tags); err != nil {
+			return err
+		}
+	}
+	// 對方有本機不認得的狀態（較新的版本）時保留本機的狀態
+	if validStatus(b.Status) {
+		if err := setBookmarkStatus(tx, actor, jobNumber, b.Status, ""); err != nil {
+			return err
+		}
+	}
+	saved, err := bookmarkSnapshot(tx, jobNumber)
+	if err != nil {
+		return err
+	}
+	action := actionUpdate
+	if created {
+		action = actionCreate
+	}
+	return writeEvent(tx, actor, entityBookmark, jobNumber, action, saved)
+}
+
+// 對方刪除的書籤同樣移到垃圾桶，本機仍可還原
+func applySyncDelete(ctx context.Context, tx *Tx, actor, jobNumber string, before *Bookmark) error {
+	if err := trashBookmark(ctx, tx, jobNumber); err != nil {
+		return err
+	}
+	return writeEvent(tx, actor, entityBookmark, jobNumber, actionDelete, before)
+}
+
+// GET /api/sync/pull?since=&peer=&limit=：游標之後的書籤異動；?snapshot=true 回傳所有書籤
+// peer 為呼叫端的 instance_id，它自己推送過來的異動不會再傳回去
+func (s *Server) syncPull(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	peer := q.Get("peer")
+	if peer != "" && !instanceIDPattern.MatchString(peer) {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "peer")
+		return
+	}
+	limit, ok := intParam(r, "limit", syncDefaultLimit, 1, syncMaxLimit)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+		return
+	}
+	snapshot := q.Get("snapshot") == "true"
+	var since int64
+	if v := q.Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "since")
+			return
+		}
+		since = n
+	} else if !snapshot {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "since")
+		return
+	}
+
+	er, err := s.currentEventRange(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if !snapshot && !er.check(w, r, since, true) {
+		return
+	}
+	id, err := s.instanceID(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	excludeActor := ""
+	if peer != "" {
+		excludeActor = "sync:" + peer
+	}
+	batch, err := s.syncChanges(r.Context(), er, since, snapshot, excludeActor, limit)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	batch.InstanceID = id
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// POST /api/sync/push：套用對方的異動，內容為 {"peer": 對方的 instance_id, "changes": [...]}
+func (s *Server) syncPush(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, syncPushMaxBytes)
+	var input struct {
+		Peer    string       `json:"peer"`
+		Changes []SyncChange `json:"changes"`
+	}
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if !instanceIDPattern.MatchString(input.Peer) {
+		writeError(w, r, http.StatusBadRequest, "sync_invalid", "peer 必須是對方的 instance_id")
+		return
+	}
+	self, err := s.instanceID(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if input.Peer == self {
+		writeError(w, r, http.StatusBadRequest, "sync_invalid", "不能與自己同步")
+		return
+	}
+	for i := range input.Changes {
+		c := &input.Changes[i]
+		jn, code := checkJobNumber(c.JobNumber)
+		switch {
+		case code != "":
+			writeError(w, r, http.StatusBadRequest, "sync_invalid", fmt.Sprintf("第 %d 筆的案號格式錯誤: %q", i+1, c.JobNumber))
+			return
+		case c.Op != syncUpsert && c.Op != syncDelete:
+			writeError(w, r, http.StatusBadRequest, "sync_invalid", fmt.Sprintf("第 %d 筆的 op 必須是 upsert 或 delete", i+1))
+			return
+		case c.Op == syncUpsert && c.Bookmark == nil:
+			writeError(w, r, http.StatusBadRequest, "sync_invalid", fmt.Sprintf("第 %d 筆缺少 bookmark", i+1))
+			return
+		case c.UpdatedAt.IsZero():
+			writeError(w, r, http.StatusBadRequest, "sync_invalid", fmt.Sprintf("第 %d 筆缺少 updated_at", i+1))
+			return
+		}
+		c.JobNumber = jn
+		if c.Bookmark != nil {
+			c.Bookmark.UpdatedAt = c.UpdatedAt
+		}
+	}
+
+	result, err := s.applySyncChanges(r, "sync:"+input.Peer, input.Changes)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if result.Conflicts == nil {
+		result.Conflicts = []SyncConflict{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"applied":   result.Applied,
+		"skipped":   result.Skipped,
+		"conflicts": result.Conflicts,
+	})
+}
+
+// SyncState 與一個同步對象的游標
+type SyncState struct {
+	Peer          string     `json:"peer"` // 設定中的網址
+	PeerInstance  string     `json:"peer_instance"`
+	PulledThrough *int64     `json:"pulled_through"` // 對方的事件 id；null 表示下一次以快照同步
+	PushedThrough *int64     `json:"pushed_through"` // 本機的事件 id
+	LastSyncedAt  *time.Time `json:"last_synced_at"`
+}
+
+func (s *Server) loadSyncState(ctx context.Context, peerURL string) (*SyncState, error) {
+	st := &SyncState{Peer: peerURL}
+	var pulled, pushed sql.NullInt64
+	var synced time.Time
+	err := s.db.QueryRowContext(ctx, "SELECT peer_instance, pulled_through, pushed_through, last_synced_at FROM sync_state WHERE peer = ?", peerURL).
+		Scan(&st.PeerInstance, &pulled, &pushed, scanTime(&synced))
+	if errors.Is(err, sql.ErrNoRows) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pulled.Valid {
+		st.PulledThrough = &pulled.Int64
+	}
+	if pushed.Valid {
+		st.PushedThrough = &pushed.Int64
+	}
+	if !synced.IsZero() {
+		synced = localTime(synced)
+		st.LastSyncedAt = &synced
+	}
+	return st, nil
+}
+
+func (s *Server) saveSyncState(ctx context.Context, st *SyncState) error {
+	now := time.Now()
+	return s.db.withTx(ctx, func(tx *Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO sync_state (peer, peer_instance, pulled_through, pushed_through, last_synced_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(peer) DO UPDATE SET
+				peer_instance = excluded.peer_instance, pulled_through = excluded.pulled_through,
+				pushed_through = excluded.pushed_through, last_synced_at = excluded.last_synced_at`,
+			st.Peer, st.PeerInstance, st.PulledThrough, st.PushedThrough, dbTime(now))
+		if err == nil {
+			st.LastSyncedAt = &now
+		}
+		return err
+	})
+}
+
+// SyncSummary 一輪同步的結果
+type SyncSummary struct {
+	Peer         string     `json:"peer"`
+	PeerInstance string     `json:"peer_instance"`
+	Pulled       SyncResult `json:"pulled"` // 在本機套用的對方異動
+	Pushed       SyncResult `json:"pushed"` // 在對方套用的本機異動
+	Snapshot     bool       `json:"snapshot"`
+	State        *SyncState `json:"state"`
+}
+
+// 與對象進行一輪同步：先拉取並套用對方的異動，再推送本機的異動；每一批完成後更新游標，中途失敗時下次從中斷處繼續
+func (s *Server) syncRound(r *http.Request, peer *SyncPeer) (*SyncSummary, error) {
+	ctx := r.Context()
+	self, err := s.instanceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(peer.URL)
+	c := &apiClient{base: base, apiKey: peer.APIKey, year: strconv.Itoa(s.cfg.Year), http: &http.Client{Timeout: syncPeerTimeout}}
+	st, err := s.loadSyncState(ctx, peer.URL)
+	if err != nil {
+		return nil, err
+	}
+	summary := &SyncSummary{Peer: peer.Name, State: st}
+
+	// 拉取
+	snapshot := st.PulledThrough == nil
+	for {
+		q := url.Values{"peer": {self}, "limit": {strconv.Itoa(syncMaxLimit)}}
+		if snapshot {
+			q.Set("snapshot", "true")
+		} else {
+			q.Set("since", strconv.FormatInt(*st.PulledThrough, 10))
+		}
+		var batch syncBatch
+		err := c.getJSON(ctx, "GET", "/api/sync/pull", q, nil, &batch)
+		var ce *clientError
+		if !snapshot && errors.As(err, &ce) && (ce.code == "events_cursor_expired" || ce.code == "events_cursor_ahead") {
+			log.Printf("同步 %s: 游標已失效（%s），改以快照同步", peer.Name, ce.code)
+			snapshot = true
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+		if !instanceIDPattern.MatchString(batch.InstanceID) {
+			return summary, &clientError{exit: exitServer, message: fmt.Sprintf("%s 沒有回傳 instance_id", peer.URL)}
+		}
+		if batch.InstanceID == self {
+			return summary, &clientError{exit: exitRejected, message: fmt.Sprintf("%s 是本機，不能與自己同步", peer.URL)}
+		}
+		if st.PeerInstance != "" && batch.InstanceID != st.PeerInstance {
+			// 對方換了資料庫，兩個方向的游標都失效
+			log.Printf("同步 %s: 對方的 instance_id 已改變，改以快照同步", peer.Name)
+			st.PeerInstance, st.PulledThrough, st.PushedThrough = batch.InstanceID, nil, nil
+			if !snapshot {
+				snapshot = true
+				continue
+			}
+		}
+		result, err := s.applySyncChanges(r, "sync:"+batch.InstanceID, batch.Changes)
+		if err != nil {
+			return summary, err
+		}
+		summary.Pulled.add(result)
+		summary.Snapshot = summary.Snapshot || snapshot
+		next := batch.NextSince
+		st.PeerInstance, st.PulledThrough = batch.InstanceID, &next
+		if err := s.saveSyncState(ctx, st); err != nil {
+			return summary, err
+		}
+		snapshot = false
+		if !batch.HasMore {
+			break
+		}
+	}
+	summary.PeerInstance = st.PeerInstance
+
+	// 推送
+	for {
+		er, err := s.currentEventRange(ctx)
+		if err != nil {
+			return summary, err
+		}
+		snapshot := st.PushedThrough == nil || *st.PushedThrough < er.prunedThrough || *st.PushedThrough > er.latest
+		since := int64(0)
+		if !snapshot {
+			since = *st.PushedThrough
+		}
+		batch, err := s.syncChanges(ctx, er, since, snapshot, "sync:"+st.PeerInstance, syncMaxLimit)
+		if err != nil {
+			return summary, err
+		}
+		if len(batch.Changes) > 0 {
+			// 快照可能很大，分批送出
+			for start := 0; start < len(batch.Changes); start += syncMaxLimit {
+				end := min(start+syncMaxLimit, len(batch.Changes))
+				var result SyncResult
+				body := map[string]interface{}{"peer": self, "changes": batch.Changes[start:end]}
+				if err := c.getJSON(ctx, "POST", "/api/sync/push", nil, body, &result); err != nil {
+					return summary, err
+				}
+				summary.Pushed.add(result)
+			}
+		}
+		summary.Snapshot = summary.Snapshot || snapshot
+		next := batch.NextSince
+		st.PushedThrough = &next
+		if err := s.saveSyncState(ctx, st); err != nil {
+			return summary, err
+		}
+		if !batch.HasMore {
+			break
+		}
+	}
+	return summary, nil
+}
+
+// POST /api/sync/run：與對象進行一輪雙向同步，內容為 {"peer": 名稱或網址}；網址可以不在 sync.peers 中
+func (s *Server) runSync(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Peer string `json:"peer"`
+	}
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	peer := s.cfg.Sync.find(input.Peer)
+	if peer == nil {
+		// 未設定的網址直接使用，不帶 API 金鑰
+		adhoc := SyncConfig{Peers: []SyncPeer{{URL: input.Peer}}}
+		if !strings.Contains(input.Peer, "://") || adhoc.validate() != nil {
+			writeError(w, r, http.StatusNotFound, "sync_peer_not_found", input.Peer)
+			return
+		}
+		peer = &adhoc.Peers[0]
+	}
+	if !syncRunning.TryLock() {
+		writeError(w, r, http.StatusConflict, "sync_in_progress")
+		return
+	}
+	defer syncRunning.Unlock()
+
+	summary, err := s.syncRound(r, peer)
+	var ce *clientError
+	if errors.As(err, &ce) {
+		writeError(w, r, http.StatusBadGateway, "sync_peer_failed", peer.Name, ce.Error())
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	for _, res := range []*SyncResult{&summary.Pulled, &summary.Pushed} {
+		if res.Conflicts == nil {
+			res.Conflicts = []SyncConflict{}
+		}
+	}
+	log.Printf("同步 %s: 拉取套用 %d 筆、衝突 %d 筆；推送套用 %d 筆、衝突 %d 筆", peer.Name,
+		summary.Pulled.Applied, len(summary.Pulled.Conflicts), summary.Pushed.Applied, len(summary.Pushed.Conflicts))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "summary": summary})
+}
+
+// GET /api/sync：本機的 instance_id 與各同步對象的游標
+func (s *Server) getSyncState(w http.ResponseWriter, r *http.Request) {
+	id, err := s.instanceID(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	type peerState struct {
+		Name string `json:"name"`
+		*SyncState
+	}
+	peers := make([]peerState, 0, len(s.cfg.Sync.Peers))
+	for _, p := range s.cfg.Sync.Peers {
+		st, err := s.loadSyncState(r.Context(), p.URL)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		peers = append(peers, peerState{Name: p.Name, SyncState: st})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"instance_id": id, "peers": peers})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 書籤標籤
+//
+// 標籤由使用者指定，與由 data 產生的類別、關鍵字不同，存於 tags 與 bookmark_tags（遷移 26）。
+// 名稱去除前後空白，不分大小寫視為同一個標籤（"AI" 與 "ai"），沿用第一次建立時的寫法；
+// 沒有書籤使用的標籤自動刪除。
+//
+// POST/PUT /api/bookmarks 的 tags 陣列取代該書籤的所有標籤，未提供時不變；
+// GET /api/bookmarks?tag=a&tag=b 只列出同時有這些標籤的書籤；
+// GET /api/tags 列出標籤與使用的書籤數，DELETE /api/tags?name= 從所有書籤移除標籤。
+// 封存的書籤不保留標籤。
+
+const (
+	maxTagLength       = 50
+	maxTagsPerBookmark = 20
+)
+
+// TagCount 標籤與使用的書籤數
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// 標籤不存在，交易中回傳此錯誤以回滾並回應 404
+var errTagNotFound = errors.New("標籤不存在")
+
+// 比對用的名稱：去除前後空白並轉為小寫
+func tagKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// 標準化標籤：去除前後空白、略過空字串，不分大小寫去除重複（保留第一個的寫法）
+func normalizeTags(raw []string) ([]string, error) {
+	tags := make([]string, 0, len(raw))
+	seen := map[string]bool{}
+	for _, t := range raw {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !utf8.ValidString(t) || strings.IndexFunc(t, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("標籤 %q 含有無效的字元", t)
+		}
+		if utf8.RuneCountInString(t) > maxTagLength {
+			return nil, fmt.Errorf("標籤 %q 超過 %d 字", t, maxTagLength)
+		}
+		if seen[tagKey(t)] {
+			continue
+		}
+		seen[tagKey(t)] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxTagsPerBookmark {
+		return nil, fmt.Errorf("每筆書籤最多 %d 個標籤", maxTagsPerBookmark)
+	}
+	return tags, nil
+}
+
+// 以 tags 取代書籤的所有標籤（tags 須已標準化）
+func setBookmarkTags(tx *Tx, jobNumber string, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM bookmark_tags WHERE job_number = ?", jobNumber); err != nil {
+		return err
+	}
+	for _, name := range tags {
+		// 已有同名（不分大小寫）的標籤時沿用
+		if _, err := tx.Exec("INSERT INTO tags (name, name_key) VALUES (?, ?) ON CONFLICT(name_key) DO NOTHING", name, tagKey(name)); err != nil {
+			return err
+		}
+		var id int64
+		if err := tx.QueryRow("SELECT id FROM tags WHERE name_key = ?", tagKey(name)).Scan(&id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO bookmark_tags (job_number, tag_id) VALUES (?, ?)", jobNumber, id); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM bookmark_tags)")
+	return err
+}
+
+// 書籤的標籤，依名稱排列
+func bookmarkTags(tx *Tx, jobNumber string) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT t.name FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id
+		WHERE bt.job_number = ? ORDER BY t.name_key`, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// 書籤是否有此標籤（不分大小寫）
+func hasTag(tags []string, name string) bool {
+	for _, t := range tags {
+		if tagKey(t) == tagKey(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /api/tags：所有標籤與使用的書籤數，依使用次數、名稱排列；只計入垃圾桶以外的書籤
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT t.name, COUNT(*) FROM tags t JOIN bookmark_tags bt ON bt.tag_id = t.id
+		JOIN bookmarks b ON b.job_number = bt.job_number AND b.deleted_at IS NULL
+		GROUP BY t.id, t.name, t.name_key
+		ORDER BY COUNT(*) DESC, t.name_key`)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total": len(tags),
+		"items": tags,
+	})
+}
+
+// DELETE /api/tags?name=：從所有書籤移除標籤，每筆受影響的書籤記錄一筆更新事件
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if tagKey(name) == "" {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "name")
+		return
+	}
+
+	var jobNumbers []string
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		var id int64
+		err := tx.QueryRow("SELECT id, name FROM tags WHERE name_key = ?", tagKey(name)).Scan(&id, &name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errTagNotFound
+		}
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query("SELECT job_number FROM bookmark_tags WHERE tag_id = ? ORDER BY job_number", id)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var jn string
+			if err := rows.Scan(&jn); err != nil {
+				rows.Close()
+				return err
+			}
+			jobNumbers = append(jobNumbers, jn)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("DELETE FROM bookmark_tags WHERE tag_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", id); err != nil {
+			return err
+		}
+		// 標籤屬於書籤的內容：遞增版本並更新時間，同步與快取才會看到變更
+		now := dbNow()
+		for _, jn := range jobNumbers {
+			if _, err := tx.Exec("UPDATE bookmarks SET version = version + 1, updated_at = ? WHERE job_number = ?", now, jn); err != nil {
+				return err
+			}
+			saved, err := bookmarkSnapshot(tx, jn)
+			if err != nil {
+				return err
+			}
+			if saved == nil {
+				continue // 孤兒資料，書籤已不存在
+			}
+			if err := writeEvent(tx, requestActor(r), entityBookmark, jn, actionUpdate, saved); err != nil {
+				return err
+			}
+		}
+		return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("刪除標籤「%s」（%d 筆書籤）", name, len(jobNumbers)), jobNumbers...))
+	})
+	if errors.Is(err, errTagNotFound) {
+		writeError(w, r, http.StatusNotFound, "tag_not_found", name)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	s.markChanged()
+	writeSuccess(w, r, http.StatusOK, "tag_deleted", map[string]interface{}{
+		"name":      name,
+		"bookmarks": len(jobNumbers),
+	})
+}
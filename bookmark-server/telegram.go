@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Telegram bot
+//
+// 設定 telegram.allowed_chats 時啟用，以 long polling（getUpdates）接收訊息，隨伺服器啟動與關閉。
+// 只回應 allowed_chats 中的聊天室，其他聊天室回覆拒絕並記錄。支援的指令：
+//   - /list：優先順序最高的書籤
+//   - /find <關鍵字>：標案名稱、機關、備註或符合的關鍵字包含該字的書籤
+//   - /add <標案案號>：由篩選結果（filtered_for_company/all_matched.jsonl）找到標案並加入書籤
+//   - /note <標案案號> <備註>：更新書籤備註
+//   - /deadline [天數]：天數內（預設 7 天）截止投標的書籤
+// 書籤的讀寫與 WebSocket 指令相同，轉為內部 REST 請求（見 internalapi.go），唯讀模式、驗證、事件與稽核紀錄都與 REST 一致。
+// Telegram API 錯誤或逾時只記錄並在稍後重試，不影響伺服器。
+
+// TelegramConfig Telegram bot 設定
+type TelegramConfig struct {
+	Token        string   `json:"token"`         // bot 權杖；未設定時使用環境變數 TELEGRAM_BOT_TOKEN
+	AllowedChats []int64  `json:"allowed_chats"` // 允許的聊天室 ID，未設定時停用 bot
+	APIURL       string   `json:"api_url"`       // API 位址，預設 https://api.telegram.org
+	PollTimeout  Duration `json:"poll_timeout"`  // long polling 等待時間，預設 "50s"
+}
+
+const (
+	telegramAPIURL       = "https://api.telegram.org"
+	telegramMaxRunes     = 4096 // 訊息上限
+	telegramListLimit    = 10
+	telegramMaxBackoff   = time.Minute
+	telegramDeadlineDays = 7
+
+	telegramCommandTimeout = 30 * time.Second
+)
+
+// 補上預設值並檢查設定
+func (c *TelegramConfig) validate() error {
+	if len(c.AllowedChats) == 0 {
+		if c.Token != "" {
+			return fmt.Errorf("telegram: 設定了 token 但沒有 allowed_chats")
+		}
+		return nil
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("TELEGRAM_BOT_TOKEN")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("telegram: 未設定權杖（token 或環境變數 TELEGRAM_BOT_TOKEN）")
+	}
+	if c.APIURL == "" {
+		c.APIURL = telegramAPIURL
+	}
+	if c.PollTimeout.Duration == 0 {
+		c.PollTimeout = Duration{50 * time.Second}
+	}
+	if c.PollTimeout.Duration < 0 {
+		return fmt.Errorf("telegram: poll_timeout 不可為負數")
+	}
+	return nil
+}
+
+// telegramBot 一個執行中的 bot
+type telegramBot struct {
+	s      *Server
+	cfg    TelegramConfig
+	api    http.Handler // 所有路由，指令經由它執行
+	client *http.Client
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	offset int64 // 下一個要取得的 update_id
+}
+
+// 啟動 bot；未設定時回傳 nil
+func (s *Server) startTelegram(api http.Handler) *telegramBot {
+	cfg := s.cfg.Telegram
+	if len(cfg.AllowedChats) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &telegramBot{
+		s:      s,
+		cfg:    cfg,
+		api:    api,
+		client: &http.Client{Timeout: cfg.PollTimeout.Duration + 15*time.Second},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go b.run(ctx)
+	log.Printf("Telegram bot 已啟用: %d 個聊天室", len(cfg.AllowedChats))
+	return b
+}
+
+// 停止 bot，等待處理中的指令完成（最多 timeout）
+func (b *telegramBot) stop(timeout time.Duration) {
+	if b == nil {
+		return
+	}
+	b.cancel()
+	select {
+	case <-b.done:
+	case <-time.After(timeout):
+		log.Println("等待 Telegram bot 結束逾時")
+		return
+	}
+	// 確認已處理的訊息，重新啟動時不再收到
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b.getUpdates(ctx, 0)
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	Text string `json:"text"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	From *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+}
+
+// Telegram API 的錯誤回應
+type telegramError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration
+}
+
+func (e *telegramError) Error() string {
+	return fmt.Sprintf("Telegram API 回應 %d: %s", e.Code, e.Description)
+}
+
+// 呼叫 Telegram API
+func (b *telegramBot) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", b.cfg.APIURL+"/bot"+b.cfg.Token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// 錯誤訊息含網址，去除權杖再記錄
+		return errors.New(strings.ReplaceAll(err.Error(), b.cfg.Token, "***"))
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return fmt.Errorf("Telegram API 回應 %s: %w", resp.Status, err)
+	}
+	if !payload.OK {
+		code := payload.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return &telegramError{Code: code, Description: payload.Description, RetryAfter: time.Duration(payload.Parameters.RetryAfter) * time.Second}
+	}
+	if result != nil {
+		return json.Unmarshal(payload.Result, result)
+	}
+	return nil
+}
+
+// 取得新訊息，同時確認 offset 之前的訊息已處理
+func (b *telegramBot) getUpdates(ctx context.Context, timeout time.Duration) ([]telegramUpdate, error) {
+	b.mu.Lock()
+	offset := b.offset
+	b.mu.Unlock()
+	var updates []telegramUpdate
+	err := b.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// long polling 迴圈；錯誤時逐步拉長間隔後重試
+func (b *telegramBot) run(ctx context.Context) {
+	defer close(b.done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		updates, err := b.getUpdates(ctx, b.cfg.PollTimeout.Duration)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			wait := backoff
+			var te *telegramError
+			if errors.As(err, &te) && te.RetryAfter > 0 {
+				wait = te.RetryAfter
+			}
+			log.Printf("Telegram bot 取得訊息失敗，%s 後重試: %v", wait, err)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+			if backoff *= 2; backoff > telegramMaxBackoff {
+				backoff = telegramMaxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+		for _, u := range updates {
+			b.mu.Lock()
+			b.offset = u.UpdateID + 1
+			b.mu.Unlock()
+			if u.Message != nil && u.Message.Text != "" {
+				b.handle(u.Message)
+			}
+		}
+	}
+}
+
+// 處理一則訊息；指令出錯（包含 panic）只記錄，不影響 bot
+// 指令不隨 bot 停止而取消，stop 會等它完成
+func (b *telegramBot) handle(m *telegramMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), telegramCommandTimeout)
+	defer cancel()
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("Telegram bot 處理訊息時發生錯誤（聊天室 %d）: %v", m.Chat.ID, v)
+		}
+	}()
+	chatID := m.Chat.ID
+	if !containsChat(b.cfg.AllowedChats, chatID) {
+		user := ""
+		if m.From != nil {
+			user = fmt.Sprintf("（使用者 %d @%s）", m.From.ID, m.From.Username)
+		}
+		log.Printf("拒絕未授權的 Telegram 聊天室 %d%s: %s", chatID, user, truncateMessage(m.Text))
+		b.reply(ctx, chatID, "此聊天室未授權使用本 bot。")
+		return
+	}
+	b.reply(ctx, chatID, b.command(ctx, chatID, m.Text))
+}
+
+func containsChat(list []int64, id int64) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// 送出回覆；失敗只記錄
+func (b *telegramBot) reply(ctx context.Context, chatID int64, text string) {
+	err := b.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     truncateRunes(text, telegramMaxRunes),
+		"disable_web_page_preview": true,
+	}, nil)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Telegram bot 回覆聊天室 %d 失敗: %v", chatID, err)
+	}
+}
+
+const telegramHelp = `可用的指令：
+/list － 優先順序最高的書籤
+/find <關鍵字> － 搜尋書籤
+/add <標案案號> － 由篩選結果加入書籤
+/note <標案案號> <備註> － 更新備註
+/deadline [天數] － 即將截止投標的書籤（預設 7 天）`
+
+// 執行指令，回傳回覆的文字
+func (b *telegramBot) command(ctx context.Context, chatID int64, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return telegramHelp
+	}
+	// 群組中的指令可能帶有 bot 名稱，例如 /list@my_bot
+	name := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	args := fields[1:]
+	switch name {
+	case "/list":
+		return b.list(ctx, chatID)
+	case "/find":
+		if len(args) == 0 {
+			return "用法：/find <關鍵字>"
+		}
+		return b.find(ctx, chatID, strings.Join(args, " "))
+	case "/add":
+		if len(args) != 1 {
+			return "用法：/add <標案案號>"
+		}
+		return b.add(ctx, chatID, args[0])
+	case "/note":
+		if len(args) < 2 {
+			return "用法：/note <標案案號> <備註>"
+		}
+		// 備註保留原本的空白與換行：去掉指令與案號後的其餘文字
+		note := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), fields[0]))
+		note = strings.TrimSpace(strings.TrimPrefix(note, args[0]))
+		return b.note(ctx, chatID, args[0], note)
+	case "/deadline":
+		days := telegramDeadlineDays
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 || n > 365 {
+				return "用法：/deadline [天數]"
+			}
+			days = n
+		}
+		return b.deadlines(days)
+	}
+	return telegramHelp
+}
+
+// 以內部請求呼叫 REST 端點；RemoteAddr 記錄聊天室，稽核紀錄可辨識來源
+func (b *telegramBot) callAPI(ctx context.Context, chatID int64, method, path string, query url.Values, body interface{}) (int, []byte, error) {
+	var raw []byte
+	if body != nil {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			return 0, nil, err
+		}
+	}
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(raw))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookmark-server-telegram")
+	req.RemoteAddr = fmt.Sprintf("telegram:%d", chatID)
+	status, resp := serveInternal(b.api, req)
+	return status, resp, nil
+}
+
+// REST 錯誤回應的訊息
+func apiErrorMessage(status int, body []byte) string {
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		return payload.Message
+	}
+	return fmt.Sprintf("伺服器回應 %d", status)
+}
+
+// bot 使用的書籤欄位；只解析需要的欄位，個別書籤的日期等格式有誤時不影響列表
+type telegramBookmark struct {
+	JobNumber       string   `json:"job_number"`
+	Title           string   `json:"title"`
+	UnitName        string   `json:"unit_name"`
+	URL             string   `json:"url"`
+	Note            string   `json:"note"`
+	Priority        int      `json:"priority"`
+	MatchedKeywords []string `json:"matched_keywords"`
+}
+
+// 所有書籤（依優先順序排列）
+func (b *telegramBot) bookmarks(ctx context.Context, chatID int64) ([]telegramBookmark, string) {
+	status, body, err := b.callAPI(ctx, chatID, "GET", "/api/bookmarks", nil, nil)
+	if err != nil {
+		return nil, "讀取書籤失敗：" + err.Error()
+	}
+	if status != http.StatusOK {
+		return nil, "讀取書籤失敗：" + apiErrorMessage(status, body)
+	}
+	var list []telegramBookmark
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, "讀取書籤失敗：" + err.Error()
+	}
+	return list, ""
+}
+
+// 書籤列表的文字
+func formatBookmarks(header string, list []telegramBookmark) string {
+	var sb strings.Builder
+	sb.WriteString(header)
+	for i, bm := range list {
+		if i == telegramListLimit {
+			fmt.Fprintf(&sb, "\n\n…另有 %d 筆", len(list)-telegramListLimit)
+			break
+		}
+		fmt.Fprintf(&sb, "\n\n%d. [%d] %s", i+1, bm.Priority, bm.Title)
+		fmt.Fprintf(&sb, "\n%s｜%s", firstNonEmpty(bm.UnitName, "（未知機關）"), bm.JobNumber)
+		if bm.Note != "" {
+			fmt.Fprintf(&sb, "\n備註：%s", truncateRunes(bm.Note, 100))
+		}
+		if bm.URL != "" {
+			sb.WriteString("\n" + bm.URL)
+		}
+	}
+	return sb.String()
+}
+
+// /list
+func (b *telegramBot) list(ctx context.Context, chatID int64) string {
+	list, errText := b.bookmarks(ctx, chatID)
+	if errText != "" {
+		return errText
+	}
+	if len(list) == 0 {
+		return "目前沒有書籤。"
+	}
+	return formatBookmarks(fmt.Sprintf("優先順序最高的書籤（共 %d 筆）", len(list)), list)
+}
+
+// /find
+func (b *telegramBot) find(ctx context.Context, chatID int64, keyword string) string {
+	list, errText := b.bookmarks(ctx, chatID)
+	if errText != "" {
+		return errText
+	}
+	kw := strings.ToLower(keyword)
+	var found []telegramBookmark
+	for _, bm := range list {
+		fields := append([]string{bm.Title, bm.UnitName, bm.Note, bm.JobNumber}, bm.MatchedKeywords...)
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f), kw) {
+				found = append(found, bm)
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("找不到符合「%s」的書籤。", keyword)
+	}
+	return formatBookmarks(fmt.Sprintf("符合「%s」的書籤（%d 筆）", keyword, len(found)), found)
+}
+
+// 在篩選結果中找到標案，回傳該行原始資料（作為書籤的 data）
+func findFilteredTender(year int, jobNumber string) (string, error) {
+	f, err := os.Open(filepath.Join(yearDir(year), "filtered_for_company", "all_matched.jsonl"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		var t struct {
+			JobNumber string `json:"job_number"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &t) == nil && t.JobNumber == jobNumber {
+			return scanner.Text(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// /add
+func (b *telegramBot) add(ctx context.Context, chatID int64, jobNumber string) string {
+	data, err := findFilteredTender(b.s.cfg.Year, jobNumber)
+	if errors.Is(err, os.ErrNotExist) {
+		return "尚未產生篩選結果（filtered_for_company/all_matched.jsonl）。"
+	}
+	if err != nil {
+		log.Printf("Telegram bot 讀取篩選結果失敗: %v", err)
+		return "讀取篩選結果失敗，詳見伺服器紀錄。"
+	}
+	if data == "" {
+		return fmt.Sprintf("篩選結果中找不到標案 %s。", jobNumber)
+	}
+	status, body, err := b.callAPI(ctx, chatID, "POST", "/api/bookmarks", nil, map[string]string{"job_number": jobNumber, "data": data})
+	if err != nil {
+		return "加入書籤失敗：" + err.Error()
+	}
+	if status != http.StatusOK && status != http.StatusCreated {
+		return "加入書籤失敗：" + apiErrorMessage(status, body)
+	}
+	var result struct {
+		Message  string           `json:"message"`
+		Bookmark telegramBookmark `json:"bookmark"`
+	}
+	json.Unmarshal(body, &result)
+	return fmt.Sprintf("%s\n%s\n%s", result.Message, result.Bookmark.Title, result.Bookmark.URL)
+}
+
+// /note
+func (b *telegramBot) note(ctx context.Context, chatID int64, jobNumber, note string) string {
+	status, body, err := b.callAPI(ctx, chatID, "PUT", "/api/bookmarks", nil, map[string]string{"job_number": jobNumber, "note": note})
+	if err != nil {
+		return "更新備註失敗：" + err.Error()
+	}
+	if status != http.StatusOK {
+		return "更新備註失敗：" + apiErrorMessage(status, body)
+	}
+	var result struct {
+		Message  string           `json:"message"`
+		Bookmark telegramBookmark `json:"bookmark"`
+	}
+	json.Unmarshal(body, &result)
+	return fmt.Sprintf("%s\n%s", result.Message, result.Bookmark.Title)
+}
+
+// /deadline：依截止時間排列
+func (b *telegramBot) deadlines(days int) string {
+	due, err := b.s.upcomingDeadlines(context.Background(), time.Now(), days)
+	if err != nil {
+		log.Printf("Telegram bot 讀取截止投標失敗: %v", err)
+		return "讀取截止投標失敗，詳見伺服器紀錄。"
+	}
+	if len(due) == 0 {
+		return fmt.Sprintf("%d 天內沒有截止投標的書籤（只列出已下載標案詳細資料的書籤）。", days)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Deadline.Before(due[j].Deadline) })
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d 天內截止投標的書籤（%d 筆）", days, len(due))
+	for i, n := range due {
+		if i == telegramListLimit {
+			fmt.Fprintf(&sb, "\n\n…另有 %d 筆", len(due)-telegramListLimit)
+			break
+		}
+		fmt.Fprintf(&sb, "\n\n%s（剩 %d 天）\n%s\n%s｜%s", localTime(n.Deadline).Format("01/02 15:04"), n.DaysLeft, n.Title, firstNonEmpty(n.UnitName, "（未知機關）"), n.JobNumber)
+	}
+	return sb.String()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 下載的標書取回
+//
+// 下載的標案詳細資料存為 tender_detail 種類的附件（見 downloads.go），伺服器上的 bookmarked_tenders/ 只是副本，
+// 從其他機器呼叫 API 時以這裡的端點取回：
+//   - GET /api/bookmarks/download/archive：將書籤已下載的標書打包成 ZIP 串流輸出，邊讀邊寫，不在記憶體中組合整個檔案。
+//     篩選參數與書籤列表相同（?min_priority= 也可寫成 ?priority_min=、?tag= 可重複），尚未下載的書籤不列入。
+//   - GET /api/bookmarks/download/file?job_number=：單一書籤的標書，尚未下載時回傳 404。
+// 檔名為「案號_標案名稱.json」，去除路徑分隔與 Windows 不允許的字元；ZIP 項目的修改時間為最近一次下載的時間，
+// 重新下載後可據此分辨。
+
+// 檔名中標案名稱最多的字數
+const tenderArchiveTitleRunes = 80
+
+// 取回時的檔名：案號_標案名稱.json
+func tenderArchiveName(jobNumber, title string) string {
+	clean := func(s string) string {
+		s = strings.Map(func(c rune) rune {
+			switch {
+			case unicode.IsControl(c), strings.ContainsRune(`/\:*?"<>|`, c):
+				return '_'
+			case unicode.IsSpace(c):
+				return ' '
+			}
+			return c
+		}, s)
+		return strings.Trim(strings.TrimSpace(s), ".")
+	}
+	name := clean(jobNumber)
+	if title = truncateRunes(clean(title), tenderArchiveTitleRunes); title != "" {
+		name += "_" + title
+	}
+	return name + ".json"
+}
+
+// 已下載標書的書籤
+type downloadedTender struct {
+	Title string
+	Attachment
+}
+
+// 符合篩選條件且已下載標書的書籤，依優先順序排列
+func (s *Server) downloadedTenders(r *http.Request, filter string, args []interface{}) ([]downloadedTender, error) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT job_number, title FROM bookmarks "+filter+" ORDER BY priority DESC, created_at DESC, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	var tenders []downloadedTender
+	for rows.Next() {
+		var t downloadedTender
+		if err := rows.Scan(&t.JobNumber, &t.Title); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tenders = append(tenders, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(r.Context(), "SELECT "+attachmentColumns+" FROM attachments WHERE kind = ? AND job_number IN (SELECT job_number FROM bookmarks "+filter+")",
+		append([]interface{}{attachmentTenderDetail}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := map[string]Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if a.Filename == tenderFileName(a.JobNumber) {
+			files[a.JobNumber] = a
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	downloaded := tenders[:0]
+	for _, t := range tenders {
+		if a, ok := files[t.JobNumber]; ok {
+			t.Attachment = a
+			downloaded = append(downloaded, t)
+		}
+	}
+	return downloaded, nil
+}
+
+// GET /api/bookmarks/download/archive：已下載標書的 ZIP
+func (s *Server) downloadTenderArchive(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if v := query.Get("priority_min"); v != "" && query.Get("min_priority") == "" {
+		query.Set("min_priority", v)
+	}
+	filter, args, badParam := bookmarkFilter(query)
+	if badParam != "" {
+		if badParam == "min_priority" && query.Get("priority_min") != "" {
+			badParam = "priority_min"
+		}
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", badParam)
+		return
+	}
+	tenders, err := s.downloadedTenders(r, filter, args)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	name := fmt.Sprintf("bookmarked_tenders_%d_%s.zip", s.cfg.Year, time.Now().In(displayLocation).Format("20060102_150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition(name))
+	w.Header().Set("X-Archive-Files", fmt.Sprint(len(tenders)))
+
+	// 開始輸出後無法再改回應碼：個別檔案讀取失敗時略過並記錄，寫入失敗（用戶端中斷）時停止
+	zw := zip.NewWriter(w)
+	used := map[string]bool{}
+	for _, t := range tenders {
+		if r.Context().Err() != nil {
+			return
+		}
+		entry := tenderArchiveName(t.JobNumber, t.Title)
+		for i := 2; used[strings.ToLower(entry)]; i++ {
+			entry = strings.TrimSuffix(tenderArchiveName(t.JobNumber, t.Title), ".json") + fmt.Sprintf(" (%d).json", i)
+		}
+		used[strings.ToLower(entry)] = true
+
+		f, err := s.openAttachment(t.Attachment)
+		if err != nil {
+			log.Printf("[%s] 打包標書 %s 失敗: %v", requestID(r), t.JobNumber, err)
+			continue
+		}
+		zf, err := zw.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Deflate, Modified: t.CreatedAt})
+		if err == nil {
+			_, err = io.Copy(zf, f)
+		}
+		f.Close()
+		if err != nil {
+			log.Printf("[%s] 輸出標書 ZIP 中斷: %v", requestID(r), err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("[%s] 輸出標書 ZIP 中斷: %v", requestID(r), err)
+	}
+}
+
+// GET /api/bookmarks/download/file?job_number=：單一書籤已下載的標書；書籤不存在或尚未下載時回傳 404
+func (s *Server) downloadTenderFile(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
+		return
+	}
+	var title string
+	if err := s.db.QueryRowContext(r.Context(), "SELECT title FROM bookmarks WHERE job_number = ? AND "+notDeleted, jobNumber).Scan(&title); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+			return
+		}
+		writeDBError(w, r, err)
+		return
+	}
+	a, err := scanAttachment(s.db.QueryRowContext(r.Context(), "SELECT "+attachmentColumns+" FROM attachments WHERE job_number = ? AND kind = ? AND filename = ?",
+		jobNumber, attachmentTenderDetail, tenderFileName(jobNumber)))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, r, http.StatusNotFound, "tender_not_downloaded", jobNumber)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	f, err := s.openAttachment(a)
+	if err != nil {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", contentDisposition(tenderArchiveName(jobNumber, title)))
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, "", a.CreatedAt, f)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 模擬下載完成：將標案詳細資料存為 tender_detail 附件
+func saveTestTender(t *testing.T, s *Server, jobNumber, body string) Attachment {
+	t.Helper()
+	a, err := s.saveAttachment(context.Background(), jobNumber, attachmentTenderDetail, tenderFileName(jobNumber), "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestTenderArchiveName(t *testing.T) {
+	tests := []struct {
+		jobNumber string
+		title     string
+		want      string
+	}{
+		{"A001", "道路養護工程", "A001_道路養護工程.json"},
+		{"A001", "", "A001.json"},
+		{"A001", `路燈/號誌\維護:第1期*?"<>|`, "A001_路燈_號誌_維護_第1期______.json"},
+		{"A001", "第一行\n第二行\t結尾", "A001_第一行_第二行_結尾.json"},
+		{"A001", "  ...隱藏檔名...  ", "A001_隱藏檔名.json"},
+		{"A001", strings.Repeat("長", 100), "A001_" + strings.Repeat("長", tenderArchiveTitleRunes-1) + "….json"},
+	}
+	for _, tt := range tests {
+		if got := tenderArchiveName(tt.jobNumber, tt.title); got != tt.want {
+			t.Errorf("tenderArchiveName(%q, %q) = %q, want %q", tt.jobNumber, tt.title, got, tt.want)
+		}
+	}
+}
+
+// 讀取 ZIP 回應，回傳項目（依順序）與內容
+func readTestArchive(t *testing.T, w *httptest.ResponseRecorder) ([]*zip.File, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("回應不是 ZIP: %v", err)
+	}
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(b)
+	}
+	return zr.File, contents
+}
+
+func TestDownloadTenderArchive(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程","priority":5}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"橋梁/維修","priority":1}`)
+	addTestBookmark(t, h, `{"job_number":"A003","title":"尚未下載","priority":3}`)
+	first := saveTestTender(t, s, "A001", `{"records":["A001"]}`)
+	saveTestTender(t, s, "A002", `{"records":["A002"]}`)
+
+	w := serve(t, h, "GET", "/api/bookmarks/download/archive", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if n := w.Header().Get("X-Archive-Files"); n != "2" {
+		t.Errorf("X-Archive-Files = %q, want 2", n)
+	}
+	disposition := regexp.MustCompile(`^attachment; filename="bookmarked_tenders_\d{4}_\d{8}_\d{6}\.zip"; filename\*=UTF-8''bookmarked_tenders_\d{4}_\d{8}_\d{6}\.zip$`)
+	if cd := w.Header().Get("Content-Disposition"); !disposition.MatchString(cd) || !strings.Contains(cd, "_"+strconv.Itoa(s.cfg.Year)+"_") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	files, contents := readTestArchive(t, w)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	// 依優先順序排列，尚未下載的書籤不列入
+	if want := "A001_道路養護工程.json,A002_橋梁_維修.json"; strings.Join(names, ",") != want {
+		t.Fatalf("ZIP 項目 = %v, want %s", names, want)
+	}
+	if contents["A001_道路養護工程.json"] != `{"records":["A001"]}` || contents["A002_橋梁_維修.json"] != `{"records":["A002"]}` {
+		t.Errorf("ZIP 內容錯誤: %v", contents)
+	}
+	if d := files[0].Modified.Sub(first.CreatedAt); d < -2*time.Second || d > 2*time.Second {
+		t.Errorf("修改時間 = %s, want %s", files[0].Modified, first.CreatedAt)
+	}
+}
+
+func TestDownloadTenderArchiveFilters(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"高","priority":5}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"低","priority":1}`)
+	addTestBookmark(t, h, `{"job_number":"A003","title":"刪除","priority":5}`)
+	// 案號只差大小寫、名稱相同時，ZIP 項目名稱加上序號
+	addTestBookmark(t, h, `{"job_number":"a001","title":"高","priority":4}`)
+	for _, jn := range []string{"A001", "A002", "A003", "a001"} {
+		saveTestTender(t, s, jn, `{"job_number":"`+jn+`"}`)
+	}
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A003", "")
+
+	tests := []struct {
+		target string
+		status int
+		names  string
+	}{
+		{"/api/bookmarks/download/archive", http.StatusOK, "A001_高.json,a001_高 (2).json,A002_低.json"},
+		{"/api/bookmarks/download/archive?min_priority=3", http.StatusOK, "A001_高.json,a001_高 (2).json"},
+		{"/api/bookmarks/download/archive?priority_min=5", http.StatusOK, "A001_高.json"},
+		{"/api/bookmarks/download/archive?min_priority=9", http.StatusOK, ""},
+		{"/api/bookmarks/download/archive?priority_min=x", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if resp := decodeBody(t, w); resp["error"] != "invalid_parameter" || !strings.Contains(resp["message"].(string), "priority_min") {
+					t.Errorf("回應 = %v", resp)
+				}
+				return
+			}
+			files, _ := readTestArchive(t, w)
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+			if strings.Join(names, ",") != tt.names {
+				t.Errorf("ZIP 項目 = %v, want %s", names, tt.names)
+			}
+			if w.Header().Get("X-Archive-Files") != strconv.Itoa(len(files)) {
+				t.Errorf("X-Archive-Files = %q, 項目 %d 個", w.Header().Get("X-Archive-Files"), len(files))
+			}
+		})
+	}
+}
+
+func TestDownloadTenderFile(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"Road works"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"道路/養護"}`)
+	addTestBookmark(t, h, `{"job_number":"A003","title":"尚未下載"}`)
+	addTestBookmark(t, h, `{"job_number":"A004","title":"垃圾桶"}`)
+	a := saveTestTender(t, s, "A001", `{"records":["A001"]}`)
+	saveTestTender(t, s, "A002", `{"records":["A002"]}`)
+	saveTestTender(t, s, "A004", `{"records":["A004"]}`)
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A004", "")
+
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		code        string
+		body        string
+		disposition string
+	}{
+		{"ascii title", "/api/bookmarks/download/file?job_number=A001", http.StatusOK, "", `{"records":["A001"]}`,
+			`attachment; filename="A001_Road works.json"; filename*=UTF-8''A001_Road%20works.json`},
+		{"unicode title", "/api/bookmarks/download/file?job_number=A002", http.StatusOK, "", `{"records":["A002"]}`,
+			`attachment; filename="A002______.json"; filename*=UTF-8''A002_%E9%81%93%E8%B7%AF_%E9%A4%8A%E8%AD%B7.json`},
+		{"not downloaded", "/api/bookmarks/download/file?job_number=A003", http.StatusNotFound, "tender_not_downloaded", "", ""},
+		{"trashed", "/api/bookmarks/download/file?job_number=A004", http.StatusNotFound, "not_found", "", ""},
+		{"missing bookmark", "/api/bookmarks/download/file?job_number=Z999", http.StatusNotFound, "not_found", "", ""},
+		{"no job number", "/api/bookmarks/download/file", http.StatusBadRequest, "missing_job_number", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if resp := decodeBody(t, w); resp["error"] != tt.code {
+					t.Errorf("error = %v, want %s", resp["error"], tt.code)
+				}
+				return
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if cd := w.Header().Get("Content-Disposition"); cd != tt.disposition {
+				t.Errorf("Content-Disposition = %q, want %q", cd, tt.disposition)
+			}
+		})
+	}
+
+	// 單一檔案不是 ZIP，以 ETag 支援條件式請求
+	req := httptest.NewRequest("GET", "/api/bookmarks/download/file?job_number=A001", nil)
+	req.Header.Set("If-None-Match", `"`+a.SHA256+`"`)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 標案詳細資料快取的來源
+const (
+	tenderFromCache   = "cache"   // 快取仍新鮮
+	tenderStale       = "stale"   // 快取過期但仍可用，背景更新中
+	tenderFetched     = "fetched" // 向 PCC API 重新取得
+	tenderRevalidated = "revalidated"
+)
+
+// 對外請求共用的 HTTP client
+var tenderClient = &http.Client{Timeout: 30 * time.Second}
+
+// 快取統計
+var tenderCacheStats struct {
+	hits, stale, fetched, revalidated, errors atomic.Uint64
+}
+
+// 背景更新中的 job_number，避免同一筆標案重複更新
+var tenderRefreshing struct {
+	sync.Mutex
+	jobs map[string]bool
+}
+
+type tenderCacheEntry struct {
+	body      []byte
+	etag      string
+	fetchedAt time.Time
+}
+
+func (s *Server) loadTenderCache(ctx context.Context, jobNumber string) (*tenderCacheEntry, error) {
+	var e tenderCacheEntry
+	var body string
+	var etag sql.NullString
+	err := s.db.QueryRowContext(ctx, "SELECT body, etag, fetched_at FROM tender_cache WHERE job_number = ?", jobNumber).
+		Scan(&body, &etag, scanTime(&e.fetchedAt))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.body = []byte(body)
+	e.etag = etag.String
+	return &e, nil
+}
+
+func (s *Server) storeTenderCache(jobNumber, apiURL string, body []byte, etag string) error {
+	_, err := s.db.execWrite(context.Background(), `
+		INSERT INTO tender_cache (job_number, api_url, body, etag, fetched_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(job_number) DO UPDATE SET
+			api_url = excluded.api_url,
+			body = excluded.body,
+			etag = excluded.etag,
+			fetched_at = excluded.fetched_at
+	`, jobNumber, apiURL, string(body), etag, dbNow())
+	return err
+}
+
+// 向 PCC API（upstream 為 pccSourceG0v 時向 pcc.g0v.tw）取得標案詳細資料；有快取時帶 If-None-Match，304 則沿用快取內容
+func (s *Server) fetchTenderRemote(ctx context.Context, upstream, jobNumber, apiURL string, cached *tenderCacheEntry) ([]byte, string, error) {
+	reqURL, err := s.tenderSourceURL(ctx, upstream, jobNumber, apiURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if upstream == pccSourceG0v {
+		if err := s.g0v.wait(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if cached != nil && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := tenderClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		if _, err := s.db.execWrite(ctx, "UPDATE tender_cache SET fetched_at = ? WHERE job_number = ?", dbNow(), jobNumber); err != nil {
+			log.Println("更新標案快取時間失敗:", err)
+		}
+		tenderCacheStats.revalidated.Add(1)
+		return cached.body, tenderRevalidated, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if upstream == pccSourceG0v && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+			s.g0v.pause(max(retryAfter, 10*s.g0v.interval))
+		}
+		return nil, "", &upstreamStatusError{status: resp.Status, code: resp.StatusCode, retryAfter: retryAfter}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	// 錯誤訊息不寫入快取，也不回傳給呼叫端存成檔案，先前下載的檔案維持不變
+	if err := validateTenderPayload(body); err != nil {
+		return nil, "", err
+	}
+	if err := s.storeTenderCache(jobNumber, reqURL, body, resp.Header.Get("ETag")); err != nil {
+		log.Println("寫入標案快取失敗:", err)
+	}
+	if cached != nil && !bytes.Equal(cached.body, body) {
+		// 每日摘要以此事件列出資料有更新的標案
+		err := s.db.withTx(ctx, func(tx *Tx) error {
+			return writeEvent(tx, systemActor, entityTender, jobNumber, actionUpdate, map[string]string{"api_url": reqURL})
+		})
+		if err != nil {
+			log.Println("寫入標案更新事件失敗:", err)
+		}
+		s.notifyBookmark(triggerTenderChanged, jobNumber)
+	}
+	tenderCacheStats.fetched.Add(1)
+	return body, tenderFetched, nil
+}
+
+// 取得標案詳細資料，優先使用 tender_cache；快取過期時向 pcc_g0v.detail 設定的來源重新取得
+// 快取未超過 TenderCacheTTL 直接回傳；allowStale 時在 TenderCacheMaxStale 內回傳過期內容並於背景更新
+// 回傳的 source 說明資料來源，只有 tenderFetched/tenderRevalidated 代表實際發出了請求
+func (s *Server) fetchTender(ctx context.Context, jobNumber, apiURL string, allowStale bool) ([]byte, string, error) {
+	return s.fetchTenderFrom(ctx, s.cfg.PCCG0v.Detail, jobNumber, apiURL, allowStale)
+}
+
+// 同 fetchTender，快取過期時向指定的來源（pccSourceOfficial 或 pccSourceG0v）重新取得
+func (s *Server) fetchTenderFrom(ctx context.Context, upstream, jobNumber, apiURL string, allowStale bool) ([]byte, string, error) {
+	cached, err := s.loadTenderCache(ctx, jobNumber)
+	if err != nil {
+		log.Println("讀取標案快取失敗:", err)
+	}
+	if cached != nil {
+		age := time.Since(cached.fetchedAt)
+		if age < s.cfg.TenderCacheTTL.Duration {
+			tenderCacheStats.hits.Add(1)
+			return cached.body, tenderFromCache, nil
+		}
+		if allowStale && age < s.cfg.TenderCacheTTL.Duration+s.cfg.TenderCacheMaxStale.Duration {
+			tenderCacheStats.stale.Add(1)
+			s.refreshTenderInBackground(upstream, jobNumber, apiURL, cached)
+			return cached.body, tenderStale, nil
+		}
+	}
+
+	body, source, err := s.fetchTenderRemote(ctx, upstream, jobNumber, apiURL, cached)
+	if err != nil {
+		tenderCacheStats.errors.Add(1)
+	}
+	return body, source, err
+}
+
+// 背景更新過期的快取
+func (s *Server) refreshTenderInBackground(upstream, jobNumber, apiURL string, cached *tenderCacheEntry) {
+	tenderRefreshing.Lock()
+	if tenderRefreshing.jobs == nil {
+		tenderRefreshing.jobs = make(map[string]bool)
+	}
+	if tenderRefreshing.jobs[jobNumber] {
+		tenderRefreshing.Unlock()
+		return
+	}
+	tenderRefreshing.jobs[jobNumber] = true
+	tenderRefreshing.Unlock()
+
+	go func() {
+		defer func() {
+			tenderRefreshing.Lock()
+			delete(tenderRefreshing.jobs, jobNumber)
+			tenderRefreshing.Unlock()
+		}()
+		if _, _, err := s.fetchTenderRemote(context.Background(), upstream, jobNumber, apiURL, cached); err != nil {
+			tenderCacheStats.errors.Add(1)
+			log.Printf("背景更新標案 %s 失敗: %v", jobNumber, err)
+		}
+	}()
+}
+
+// 標案快取管理
+// GET 回傳統計；DELETE ?job_number= 清除單筆，?older_than=24h 清除超過指定時間的資料，兩者皆未指定時清除全部
+func (s *Server) tenderCacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		var entries int
+		var size sql.NullInt64
+		var oldest, newest sql.NullString
+		err := s.db.QueryRowContext(r.Context(), "SELECT COUNT(*), SUM(LENGTH(body)), MIN(fetched_at), MAX(fetched_at) FROM tender_cache").
+			Scan(&entries, &size, &oldest, &newest)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		var fresh int
+		err = s.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM tender_cache WHERE fetched_at >= ?", dbTime(time.Now().Add(-s.cfg.TenderCacheTTL.Duration))).Scan(&fresh)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"entries":     entries,
+			"fresh":       fresh,
+			"bytes":       size.Int64,
+			"oldest":      oldest.String,
+			"newest":      newest.String,
+			"ttl":         s.cfg.TenderCacheTTL,
+			"max_stale":   s.cfg.TenderCacheMaxStale,
+			"hits":        tenderCacheStats.hits.Load(),
+			"stale":       tenderCacheStats.stale.Load(),
+			"fetched":     tenderCacheStats.fetched.Load(),
+			"revalidated": tenderCacheStats.revalidated.Load(),
+			"errors":      tenderCacheStats.errors.Load(),
+		})
+	case "DELETE":
+		query := r.URL.Query()
+		var result sql.Result
+		var err error
+		switch {
+		case query.Get("job_number") != "":
+			jobNumber, ok := jobNumberParam(w, r, query.Get("job_number"))
+			if !ok {
+				return
+			}
+			result, err = s.db.execWrite(r.Context(), "DELETE FROM tender_cache WHERE job_number = ?", jobNumber)
+		case query.Get("older_than") != "":
+			age, perr := time.ParseDuration(query.Get("older_than"))
+			if perr != nil || age < 0 {
+				writeError(w, r, http.StatusBadRequest, "invalid_parameter", "older_than")
+				return
+			}
+			result, err = s.db.execWrite(r.Context(), "DELETE FROM tender_cache WHERE fetched_at < ?", dbTime(time.Now().Add(-age)))
+		default:
+			result, err = s.db.execWrite(r.Context(), "DELETE FROM tender_cache")
+		}
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		n, _ := result.RowsAffected()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": n})
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed")
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 標案異動偵測
+//
+// 機關更正公告後，截止投標時間、預算等欄位會改變，但書籤的 data 仍是加入當時的內容。
+// POST /api/bookmarks/refresh 重新向 api_url 取得每筆書籤的詳細資料（不使用快取），
+// 以 tender_changes.fields 設定的欄位與 data 中同名的鍵比對：
+//   - data 還沒有這個鍵時寫入目前的值作為比對基準，不算異動
+//   - 值不同時在 bookmark_changes（遷移 28）記錄舊值與新值，並以新值更新 data；
+//     鍵與書籤欄位重複時（例如 title）一併更新欄位（見 datasync.go）
+//
+// 請求的節奏與同時進行的筆數與下載標書相同（見 runPaced、downloadPacer），api_url 為空的書籤略過。
+// GET /api/bookmarks/changes 列出未讀的異動，PUT /api/bookmarks/changes 標示為已讀。
+// 設定 tender_changes.interval 時也由排程定期執行。
+
+// TenderChangeField 比對的欄位：data 中的鍵，與詳細資料 detail 中鍵名的結尾
+// 例如 {"key": "deadline", "detail": "截止投標"} 對應 "領投開標:截止投標"
+type TenderChangeField struct {
+	Key    string `json:"key"`
+	Detail string `json:"detail"`
+}
+
+// TenderChangesConfig 標案異動偵測的設定
+type TenderChangesConfig struct {
+	Fields   []TenderChangeField `json:"fields"`   // 未設定時為 defaultTenderChangeFields
+	Interval Duration            `json:"interval"` // 定期檢查的間隔，例如 "24h"；未設定則只在呼叫 API 時檢查
+}
+
+var defaultTenderChangeFields = []TenderChangeField{
+	{Key: "title", Detail: "標案名稱"},
+	{Key: "deadline", Detail: "截止投標"},
+	{Key: "opening", Detail: "開標時間"},
+	{Key: "budget", Detail: "預算金額"},
+}
+
+func (c *TenderChangesConfig) validate() error {
+	if c.Fields == nil {
+		c.Fields = defaultTenderChangeFields
+	}
+	seen := map[string]bool{}
+	for _, f := range c.Fields {
+		if f.Key == "" || f.Detail == "" {
+			return fmt.Errorf("tender_changes.fields 的 key 與 detail 不可為空")
+		}
+		// 這些鍵決定書籤的識別與詳細資料的來源，不能由詳細資料改寫
+		if f.Key == "job_number" || f.Key == "url" || f.Key == "api_url" {
+			return fmt.Errorf("tender_changes.fields 不可使用 %s", f.Key)
+		}
+		if seen[f.Key] {
+			return fmt.Errorf("tender_changes.fields 的 key 重複: %s", f.Key)
+		}
+		seen[f.Key] = true
+	}
+	if c.Interval.Duration < 0 {
+		return fmt.Errorf("tender_changes.interval 不可為負數")
+	}
+	return nil
+}
+
+// TenderChange 一筆偵測到的異動
+type TenderChange struct {
+	ID         int64      `json:"id"`
+	JobNumber  string     `json:"job_number"`
+	Title      string     `json:"title"` // 書籤目前的標案名稱，書籤已刪除時為空字串
+	Field      string     `json:"field"`
+	OldValue   string     `json:"old_value"`
+	NewValue   string     `json:"new_value"`
+	DetectedAt time.Time  `json:"detected_at"`
+	Seen       bool       `json:"seen"`
+	SeenAt     *time.Time `json:"seen_at"`
+}
+
+// 一筆書籤的更新結果
+type refreshResult struct {
+	JobNumber string      `json:"job_number"`
+	Title     string      `json:"title"`
+	Status    string      `json:"status"` // success、error
+	Error     string      `json:"error,omitempty"`
+	Changes   []FieldDiff `json:"changes,omitempty"` // Bookmark 為舊值，Tender 為新值
+}
+
+// 同一時間每個年度只執行一次更新
+var refreshRunning = struct {
+	sync.Mutex
+	years map[int]bool
+}{years: map[int]bool{}}
+
+var errRefreshInProgress = errors.New("標案資料更新中")
+
+// 詳細資料中鍵名以 suffix 結尾的欄位值（最新的公告），空白正規化；有多個時取鍵名排序最前的
+func tenderDetailValue(detail map[string]interface{}, suffix string) string {
+	keys := make([]string, 0, 1)
+	for key := range detail {
+		if strings.HasSuffix(key, suffix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var v string
+		switch value := detail[key].(type) {
+		case string:
+			v = value
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 由詳細資料取出要比對的欄位；沒有值的欄位不列入
+func tenderChangeValues(body []byte, fields []TenderChangeField) (map[string]string, error) {
+	var payload struct {
+		Records []struct {
+			Detail map[string]interface{} `json:"detail"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Records) == 0 {
+		return nil, errors.New("缺少 records")
+	}
+	detail := payload.Records[len(payload.Records)-1].Detail
+	values := map[string]string{}
+	for _, f := range fields {
+		if v := tenderDetailValue(detail, f.Detail); v != "" {
+			values[f.Key] = v
+		}
+	}
+	return values, nil
+}
+
+// 比對並寫入一筆書籤的異動；audit 為稽核紀錄的來源（摘要與案號由此填入）
+// 書籤在下載期間被刪除時不做任何事
+func (s *Server) applyTenderValues(ctx context.Context, actor string, audit AuditEntry, jobNumber string, values map[string]string) ([]FieldDiff, error) {
+	var diffs []FieldDiff
+	err := s.db.withTx(ctx, func(tx *Tx) error {
+		diffs = nil
+		var stored string
+		var cols mirroredColumns
+		err := tx.QueryRow(`
+			SELECT COALESCE(data, ''), title, unit_name, type, date, url, COALESCE(api_url, '')
+			FROM bookmarks WHERE job_number = ? AND deleted_at IS NULL`, jobNumber).Scan(
+			&stored, &cols.Title, &cols.UnitName, &cols.Type, &cols.Date, &cols.URL, &cols.APIURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		obj := map[string]json.RawMessage{}
+		if stored != "" {
+			plain, err := tx.cipher.open("data", stored)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal([]byte(plain), &obj); err != nil || obj == nil {
+				// 損毀的 data 由 invalid-data 報表處理，不在這裡覆寫
+				return nil
+			}
+		}
+
+		var mirrored []string
+		now := dbNow()
+		patched := false
+		for _, f := range s.cfg.TenderChanges.Fields {
+			value, ok := values[f.Key]
+			if !ok {
+				continue
+			}
+			isMirrored := containsTerm(dataMirroredFields, f.Key)
+			old, _ := dataScalar(obj[f.Key])
+			if old == "" && isMirrored {
+				// data 沒有這個鍵時以欄位的值比對
+				old = cols.get(f.Key)
+			}
+			if strings.Join(strings.Fields(old), " ") == value {
+				continue
+			}
+			if isMirrored {
+				// 與書籤欄位重複的鍵一併更新欄位，維持 data 為準的規則；日期無法解析時不更新
+				if cols.set(f.Key, value) != nil {
+					continue
+				}
+				obj[f.Key] = cols.raw(f.Key)
+				mirrored = append(mirrored, f.Key)
+			} else {
+				obj[f.Key], _ = json.Marshal(value)
+			}
+			patched = true
+			if old == "" {
+				continue // 第一次取得，作為之後比對的基準
+			}
+			diffs = append(diffs, FieldDiff{Field: f.Key, Bookmark: old, Tender: value})
+			if _, err := tx.Exec(`
+				INSERT INTO bookmark_changes (job_number, field, old_value, new_value, detected_at)
+				VALUES (?, ?, ?, ?, ?)`, jobNumber, f.Key, old, value, now); err != nil {
+				return err
+			}
+		}
+		if len(mirrored) > 0 {
+			if _, err := tx.Exec("UPDATE bookmarks SET title = ?, unit_name = ?, type = ?, date = ?, url = ?, api_url = ? WHERE job_number = ?",
+				cols.Title, cols.UnitName, cols.Type, cols.Date, cols.URL, cols.APIURL, jobNumber); err != nil {
+				return err
+			}
+		}
+		if !patched {
+			return nil
+		}
+
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		sealed, err := tx.cipher.seal("data", string(b))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE bookmarks SET data = ?, version = version + 1, updated_at = ? WHERE job_number = ?", sealed, now, jobNumber); err != nil {
+			return err
+		}
+		if err := syncBookmarkTerms(tx, jobNumber, string(b)); err != nil {
+			return err
+		}
+		saved, err := bookmarkSnapshot(tx, jobNumber)
+		if err != nil {
+			return err
+		}
+		if err := writeEvent(tx, actor, entityBookmark, jobNumber, actionUpdate, saved); err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			return nil
+		}
+		changed := make([]string, 0, len(diffs))
+		for _, d := range diffs {
+			changed = append(changed, fmt.Sprintf("%s: %q → %q", d.Field, d.Bookmark, d.Tender))
+		}
+		audit.Summary, audit.JobNumbers = "偵測到標案異動（"+strings.Join(changed, "、")+"）", []string{jobNumber}
+		return writeAudit(tx, audit)
+	})
+	return diffs, err
+}
+
+// 重新取得書籤的詳細資料並比對；jobNumber 不為空時只更新該書籤
+// ctx 取消時不再開始新的項目，回傳的結果只含已處理的項目
+func (s *Server) refreshTenders(ctx context.Context, actor string, audit AuditEntry, jobNumber string) ([]refreshResult, int, error) {
+	refreshRunning.Lock()
+	if refreshRunning.years[s.cfg.Year] {
+		refreshRunning.Unlock()
+		return nil, 0, errRefreshInProgress
+	}
+	refreshRunning.years[s.cfg.Year] = true
+	refreshRunning.Unlock()
+	defer func() {
+		refreshRunning.Lock()
+		delete(refreshRunning.years, s.cfg.Year)
+		refreshRunning.Unlock()
+	}()
+	defer beginJob("refresh")()
+
+	query := "SELECT job_number, title, COALESCE(api_url, '') FROM bookmarks WHERE deleted_at IS NULL"
+	var args []interface{}
+	if jobNumber != "" {
+		query += " AND job_number = ?"
+		args = append(args, jobNumber)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY priority DESC, created_at DESC", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var tasks []downloadTask
+	skipped := 0
+	for rows.Next() {
+		var t downloadTask
+		if err := rows.Scan(&t.JobNumber, &t.Title, &t.APIURL); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		if t.APIURL == "" {
+			skipped++
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var mu sync.Mutex
+	results := make([]refreshResult, 0, len(tasks))
+	changed := false
+	pacer := newDownloadPacer(s.cfg)
+	runPaced(ctx, s.cfg.DownloadConcurrency, tasks, func(t downloadTask) {
+		if pacer.wait(ctx) != nil {
+			return
+		}
+		result := refreshResult{JobNumber: t.JobNumber, Title: t.Title, Status: downloadSuccess}
+		// 一律向上游重新取得，未過期的快取可能是更正公告之前的內容
+		cached, err := s.loadTenderCache(ctx, t.JobNumber)
+		if err != nil {
+			log.Println("讀取標案快取失敗:", err)
+		}
+		start := time.Now()
+		body, _, err := s.fetchTenderRemote(ctx, pccSourceOfficial, t.JobNumber, t.APIURL, cached)
+		if ctx.Err() != nil {
+			return
+		}
+		pacer.record(start, err)
+		var values map[string]string
+		if err == nil {
+			values, err = tenderChangeValues(body, s.cfg.TenderChanges.Fields)
+		}
+		if err == nil {
+			result.Changes, err = s.applyTenderValues(context.WithoutCancel(ctx), actor, audit, t.JobNumber, values)
+		}
+		if err != nil {
+			result.Status, result.Error = downloadError, err.Error()
+		}
+		mu.Lock()
+		results = append(results, result)
+		changed = changed || len(result.Changes) > 0
+		mu.Unlock()
+	})
+	if changed {
+		s.markChanged()
+		s.broker.notify()
+	}
+	return results, skipped, nil
+}
+
+// 排程：定期更新所有書籤的標案資料
+func (s *Server) checkTenderChanges(ctx context.Context) error {
+	results, _, err := s.refreshTenders(ctx, systemActor, AuditEntry{Actor: systemActor, Method: "SCHEDULE", Path: "tender_changes"}, "")
+	if errors.Is(err, errRefreshInProgress) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	changes, failed := 0, 0
+	for _, res := range results {
+		changes += len(res.Changes)
+		if res.Status != downloadSuccess {
+			failed++
+		}
+	}
+	if changes > 0 || failed > 0 {
+		log.Printf("標案異動檢查: 檢查 %d 筆，異動 %d 項，失敗 %d 筆", len(results), changes, failed)
+	}
+	return nil
+}
+
+// POST /api/bookmarks/refresh[?job_number=]：重新取得詳細資料並記錄異動，完成後回傳逐筆結果
+func (s *Server) refreshBookmarks(w http.ResponseWriter, r *http.Request) {
+	jobNumber := ""
+	if raw := r.URL.Query().Get("job_number"); raw != "" {
+		var ok bool
+		if jobNumber, ok = jobNumberParam(w, r, raw); !ok {
+			return
+		}
+	}
+
+	results, skipped, err := s.refreshTenders(r.Context(), requestActor(r), newAuditEntry(r, ""), jobNumber)
+	if errors.Is(err, errRefreshInProgress) {
+		writeError(w, r, http.StatusConflict, "refresh_in_progress")
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if jobNumber != "" && len(results) == 0 && skipped == 0 {
+		writeError(w, r, http.StatusNotFound, "not_found", jobNumber)
+		return
+	}
+
+	refreshed, failed, changed, changes := 0, 0, 0, 0
+	for i := range results {
+		if results[i].Status != downloadSuccess {
+			failed++
+			continue
+		}
+		refreshed++
+		if len(results[i].Changes) > 0 {
+			changed++
+			changes += len(results[i].Changes)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].JobNumber < results[j].JobNumber })
+	writeSuccess(w, r, http.StatusOK, "bookmarks_refreshed", map[string]interface{}{
+		"refreshed": refreshed,
+		"skipped":   skipped, // 沒有 api_url
+		"failed":    failed,
+		"changed":   changed,
+		"changes":   changes,
+		"canceled":  r.Context().Err() != nil,
+		"results":   results,
+	})
+}
+
+// GET /api/bookmarks/changes：未讀的異動，由新到舊；?all=true 包含已讀，?job_number= 只列出該書籤
+func (s *Server) listTenderChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var where []string
+	var args []interface{}
+	if query.Get("all") != "true" {
+		where = append(where, "c.seen = 0")
+	}
+	if raw := query.Get("job_number"); raw != "" {
+		jn, ok := jobNumberParam(w, r, raw)
+		if !ok {
+			return
+		}
+		where = append(where, "c.job_number = ?")
+		args = append(args, jn)
+	}
+	limit, ok := intParam(r, "limit", 500, 1, 5000)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+		return
+	}
+	sqlWhere := ""
+	if len(where) > 0 {
+		sqlWhere = "WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT c.id, c.job_number, COALESCE(b.title, ''), c.field, c.old_value, c.new_value, c.detected_at, c.seen, c.seen_at
+		FROM bookmark_changes c LEFT JOIN bookmarks b ON b.job_number = c.job_number
+		`+sqlWhere+` ORDER BY c.id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]TenderChange, 0)
+	for rows.Next() {
+		var c TenderChange
+		var seenAt time.Time
+		if err := rows.Scan(&c.ID, &c.JobNumber, &c.Title, &c.Field, &c.OldValue, &c.NewValue, scanTime(&c.DetectedAt), &c.Seen, scanTime(&seenAt)); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		c.DetectedAt = localTime(c.DetectedAt)
+		if !seenAt.IsZero() {
+			t := localTime(seenAt)
+			c.SeenAt = &t
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	var unseen int
+	if err := s.db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM bookmark_changes WHERE seen = 0").Scan(&unseen); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":  len(items),
+		"unseen": unseen,
+		"items":  items,
+	})
+}
+
+// PUT /api/bookmarks/changes：標示為已讀
+// 內容為 {"ids": [...]}、{"job_number": "..."} 或 {"all": true} 其中之一
+func (s *Server) markTenderChangesSeen(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs       []int64 `json:"ids"`
+		JobNumber string  `json:"job_number"`
+		All       bool    `json:"all"`
+	}
+	if err := decodeJSONBody(r, &input); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	given := 0
+	for _, set := range []bool{input.IDs != nil, input.JobNumber != "", input.All} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "ids、job_number、all 須擇一提供")
+		return
+	}
+	if len(input.IDs) > maxBulkItems {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "bulk_too_large", len(input.IDs), maxBulkItems)
+		return
+	}
+
+	where, args := "", []interface{}{dbNow()}
+	switch {
+	case input.IDs != nil:
+		if len(input.IDs) == 0 {
+			writeSuccess(w, r, http.StatusOK, "changes_marked_seen", map[string]interface{}{"marked": 0})
+			return
+		}
+		where = "id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(input.IDs)), ",") + ")"
+		for _, id := range input.IDs {
+			args = append(args, id)
+		}
+	case input.JobNumber != "":
+		jn, ok := jobNumberParam(w, r, input.JobNumber)
+		if !ok {
+			return
+		}
+		where = "job_number = ?"
+		args = append(args, jn)
+	default:
+		where = "1 = 1"
+	}
+
+	var marked int64
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		result, err := tx.Exec("UPDATE bookmark_changes SET seen = 1, seen_at = ? WHERE seen = 0 AND "+where, args...)
+		if err != nil {
+			return err
+		}
+		marked, _ = result.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeSuccess(w, r, http.StatusOK, "changes_marked_seen", map[string]interface{}{"marked": marked})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 下載的標書檔名
+//
+// 檔名為 job_number 的百分比編碼加上 .json：英數字與 - _ . 保留，其他位元組（/、空白、全形字等）
+// 編碼為 %XX，開頭的 . 也編碼以免成為隱藏檔。編碼可還原，不同的 job_number 不會對應到同一個檔名；
+// 舊版只把 / 換成 _，A/01 與 A_01 會互相覆蓋，啟動時由 migrateTenderFiles 改名。
+// 大小寫不分的檔案系統（NAS、macOS）上 a1 與 A1 仍會衝突，下載前以 tenderFileOwner 檢查。
+
+// 下載的標書檔名
+func tenderFileName(jobNumber string) string {
+	return escapeFileName(jobNumber) + ".json"
+}
+
+// 百分比編碼為可用於檔名的字串，附件目錄的案號子目錄也使用
+func escapeFileName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isTenderFileChar(c) && !(c == '.' && i == 0) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isTenderFileChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+// 舊版檔名，僅供遷移使用
+func legacyTenderFileName(jobNumber string) string {
+	return strings.ReplaceAll(jobNumber, "/", "_") + ".json"
+}
+
+// 已使用同一檔名（不分大小寫）的其他書籤；沒有時回傳空字串
+func (s *Server) tenderFileOwner(ctx context.Context, jobNumber string) (string, error) {
+	var owner string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT job_number FROM attachments
+		WHERE kind = ? AND LOWER(filename) = LOWER(?) AND job_number <> ?
+		LIMIT 1`, attachmentTenderDetail, tenderFileName(jobNumber), jobNumber).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return owner, err
+}
+
+// 由標書內容取出 job_number（PCC API 回應的頂層或 records 中的 job_number），取不到時回傳空字串
+func tenderFileJobNumber(content []byte) string {
+	var detail struct {
+		JobNumber string `json:"job_number"`
+		Records   []struct {
+			JobNumber string `json:"job_number"`
+		} `json:"records"`
+	}
+	if json.Unmarshal(content, &detail) != nil {
+		return ""
+	}
+	if detail.JobNumber != "" {
+		return detail.JobNumber
+	}
+	for _, r := range detail.Records {
+		if r.JobNumber != "" {
+			return r.JobNumber
+		}
+	}
+	return ""
+}
+
+// 將舊版檔名的標書檔改為新的檔名，並更新附件記錄的檔名
+// 多個書籤對應到同一個舊檔名時，由檔案內容判斷屬於哪一個；判斷不出來的保留原檔並記錄
+// 完成後於 app_meta 記錄，之後啟動不再重複
+func (s *Server) migrateTenderFiles() error {
+	if s.cfg.ReadOnly {
+		return nil
+	}
+	var done string
+	if err := s.db.QueryRowContext(context.Background(), "SELECT COALESCE(MAX(value), '') FROM app_meta WHERE key = 'tender_file_names'").Scan(&done); err != nil {
+		return err
+	}
+	if done != "" {
+		return nil
+	}
+
+	// 舊檔名 → 可能的 job_number
+	candidates := map[string][]string{}
+	for _, table := range []string{"bookmarks", "bookmarks_archive"} {
+		rows, err := s.db.QueryContext(context.Background(), "SELECT job_number FROM "+table)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var jn string
+			if err := rows.Scan(&jn); err != nil {
+				rows.Close()
+				return err
+			}
+			legacy := legacyTenderFileName(jn)
+			if !containsTerm(candidates[legacy], jn) {
+				candidates[legacy] = append(candidates[legacy], jn)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	dir := s.tendersDir()
+	renamed := 0
+	for legacy, jns := range candidates {
+		path := filepath.Join(dir, legacy)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		jn := jns[0]
+		if len(jns) > 1 {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if jn = tenderFileJobNumber(content); !containsTerm(jns, jn) {
+				log.Printf("無法判斷 %s 屬於哪個書籤（%s），保留原檔", legacy, strings.Join(jns, "、"))
+				continue
+			}
+		}
+		name := tenderFileName(jn)
+		if name == legacy {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			log.Printf("%s 已存在，保留舊檔 %s", name, legacy)
+			continue
+		}
+		if err := os.Rename(path, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+		renamed++
+	}
+
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		// 附件記錄的檔名不論檔案是否改名都一併更新
+		for legacy, jns := range candidates {
+			for _, jn := range jns {
+				if name := tenderFileName(jn); name != legacy {
+					if _, err := tx.Exec("UPDATE attachments SET filename = ? WHERE job_number = ? AND kind = ? AND filename = ?",
+						name, jn, attachmentTenderDetail, legacy); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		_, err := tx.Exec("INSERT INTO app_meta (key, value) VALUES ('tender_file_names', 'percent')")
+		return err
+	})
+	if err == nil && renamed > 0 {
+		log.Printf("已將 %d 個標書檔改為新的檔名", renamed)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTenderFileName(t *testing.T) {
+	tests := []struct {
+		jobNumber string
+		want      string
+	}{
+		{"A001", "A001.json"},
+		{"A/01", "A%2F01.json"},
+		{"A_01", "A_01.json"},
+		{"B 01", "B%2001.json"},
+		{"Ａ01", "%EF%BC%A101.json"},
+		{".hidden", "%2Ehidden.json"},
+		{"A.1(2)", "A.1%282%29.json"},
+	}
+	seen := map[string]string{}
+	for _, tt := range tests {
+		got := tenderFileName(tt.jobNumber)
+		if got != tt.want {
+			t.Errorf("tenderFileName(%q) = %q, want %q", tt.jobNumber, got, tt.want)
+		}
+		if strings.ContainsAny(got, "/ \\") {
+			t.Errorf("tenderFileName(%q) = %q 含有不安全的字元", tt.jobNumber, got)
+		}
+		// 可還原，不同的案號不會共用檔名
+		if back, err := url.PathUnescape(strings.TrimSuffix(got, ".json")); err != nil || back != tt.jobNumber {
+			t.Errorf("%q 還原為 %q (%v)", got, back, err)
+		}
+		if other, ok := seen[got]; ok {
+			t.Errorf("%q 與 %q 共用檔名 %s", tt.jobNumber, other, got)
+		}
+		seen[got] = tt.jobNumber
+	}
+}
+
+// 舊版檔名改為新檔名；多個書籤共用舊檔名時依檔案內容判斷
+func TestMigrateTenderFiles(t *testing.T) {
+	s := newFileTestServer(t)
+	h := s.routes()
+	for _, jn := range []string{"A/01", "A_01", "B 01", "C/01", "C_01"} {
+		addTestBookmark(t, h, `{"job_number":"`+jn+`"}`)
+	}
+	dir := s.tendersDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := map[string]string{
+		"A_01.json": `{"job_number":"A/01"}`, // A/01 與 A_01 共用舊檔名，內容屬於 A/01
+		"B 01.json": `{"job_number":"B 01"}`,
+		"C_01.json": `{"title":"無法判斷"}`, // C/01 與 C_01 共用，內容無法判斷
+	}
+	for name, content := range legacy {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// newServer 已在空資料庫上完成遷移，清除紀錄後重新執行
+	if _, err := s.db.Exec("DELETE FROM app_meta WHERE key = 'tender_file_names'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.migrateTenderFiles(); err != nil {
+		t.Fatalf("migrateTenderFiles: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"A%2F01.json": true, "A_01.json": false,
+		"B%2001.json": true, "B 01.json": false,
+		"C_01.json": true, "C%2F01.json": false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", name, got, want)
+		}
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "A%2F01.json")); string(content) != legacy["A_01.json"] {
+		t.Errorf("A%%2F01.json = %s", content)
+	}
+}
+
+// 大小寫不分的檔案系統上會衝突的案號，下載前可找出已使用該檔名的書籤
+func TestTenderFileOwner(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"ab-1"}`)
+	addTestBookmark(t, h, `{"job_number":"AB-1"}`)
+	saveTestTender(t, s, "ab-1", `{"job_number":"ab-1"}`)
+
+	ctx := context.Background()
+	if owner, err := s.tenderFileOwner(ctx, "AB-1"); err != nil || owner != "ab-1" {
+		t.Errorf("owner of AB-1 = %q, %v; want ab-1", owner, err)
+	}
+	if owner, err := s.tenderFileOwner(ctx, "ab-1"); err != nil || owner != "" {
+		t.Errorf("owner of ab-1 = %q, %v; want none", owner, err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 檢查 PCC API 的回應內容
+//
+// PCC API 有時以 200 回傳錯誤或限流訊息（{"error": ...}、{"message": ...}、HTML 頁面），
+// 不能當成標案資料存下來。標案詳細資料必須是含 records 陣列的 JSON 物件，
+// 且第一筆紀錄有 detail 物件；其餘一律視為下載失敗，訊息取自回應內容。
+
+// 錯誤訊息最多保留的長度
+const upstreamMessageLimit = 200
+
+// 回應內容不是標案資料
+type upstreamPayloadError struct {
+	message string
+}
+
+func (e *upstreamPayloadError) Error() string {
+	return "PCC API 回傳的不是標案資料: " + e.message
+}
+
+// 檢查回應是否為標案詳細資料
+func validateTenderPayload(body []byte) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return &upstreamPayloadError{"回應為空"}
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &payload); err != nil || payload == nil {
+		return &upstreamPayloadError{truncateMessage(string(trimmed))}
+	}
+	if raw, ok := payload["error"]; ok {
+		return &upstreamPayloadError{truncateMessage(jsonText(raw))}
+	}
+	if _, ok := payload["records"]; !ok {
+		for _, key := range []string{"message", "msg"} {
+			if raw, ok := payload[key]; ok {
+				return &upstreamPayloadError{truncateMessage(jsonText(raw))}
+			}
+		}
+	}
+	var records []map[string]json.RawMessage
+	if err := json.Unmarshal(payload["records"], &records); err != nil || len(records) == 0 {
+		return &upstreamPayloadError{"缺少 records"}
+	}
+	var detail map[string]json.RawMessage
+	if err := json.Unmarshal(records[0]["detail"], &detail); err != nil || detail == nil {
+		return &upstreamPayloadError{"records 缺少 detail"}
+	}
+	return nil
+}
+
+// JSON 值轉為文字：字串取內容，其他維持原樣
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func truncateMessage(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > upstreamMessageLimit {
+		return string(r[:upstreamMessageLimit]) + "…"
+	}
+	return s
+}
+
+// InvalidTenderFile 內容不是標案資料的下載檔
+type InvalidTenderFile struct {
+	File      string `json:"file"`
+	JobNumber string `json:"job_number"`
+	Size      int64  `json:"size"`
+	Error     string `json:"error"`
+}
+
+// GET /api/admin/invalid-tenders：檢查已下載的標書檔，列出內容為錯誤訊息或格式不符的檔案
+func (s *Server) invalidTenderFilesReport(w http.ResponseWriter, r *http.Request) {
+	dir := s.tendersDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		writeInternalError(w, r, "file_error", err)
+		return
+	}
+
+	scanned := 0
+	items := make([]InvalidTenderFile, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		body, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			writeInternalError(w, r, "file_error", err)
+			return
+		}
+		scanned++
+		if err := validateTenderPayload(body); err != nil {
+			item := InvalidTenderFile{File: name, Size: int64(len(body)), Error: err.Error()}
+			var perr *upstreamPayloadError
+			if errors.As(err, &perr) {
+				item.Error = perr.message
+			}
+			if jn, err := url.PathUnescape(strings.TrimSuffix(name, ".json")); err == nil {
+				item.JobNumber = jn
+			}
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].File < items[j].File })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dir":     dir,
+		"scanned": scanned,
+		"total":   len(items),
+		"items":   items,
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// 書籤的符合類別與關鍵字
+//
+// 篩選程式（filter_tenders.py）在每筆標案上標記 matched_categories 與 matched_keywords，
+// 前端加入書籤時整筆標案存於 data。為了能以 SQL 查詢「被某個關鍵字命中的書籤」，
+// 另存於 bookmark_categories 與 bookmark_keywords，新增書籤時由 data 重新產生。
+// 兩張表以 job_number 關聯，刪除書籤時一併刪除，並登記於 orphanChecks。
+
+// 從 data 取出類別與關鍵字，去除空白與重複；data 不是 JSON 或沒有這兩個欄位時回傳空陣列
+func bookmarkTerms(data string) (categories, keywords []string) {
+	var tender struct {
+		MatchedCategories []interface{} `json:"matched_categories"`
+		MatchedKeywords   []interface{} `json:"matched_keywords"`
+	}
+	if data != "" {
+		json.Unmarshal([]byte(data), &tender)
+	}
+	return uniqueTerms(tender.MatchedCategories), uniqueTerms(tender.MatchedKeywords)
+}
+
+func uniqueTerms(values []interface{}) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		terms = append(terms, s)
+	}
+	return terms
+}
+
+// 以 data（明文）重新產生書籤的類別與關鍵字
+func syncBookmarkTerms(tx *Tx, jobNumber, data string) error {
+	if err := deleteBookmarkTerms(tx, jobNumber); err != nil {
+		return err
+	}
+	categories, keywords := bookmarkTerms(data)
+	for _, c := range categories {
+		if _, err := tx.Exec("INSERT INTO bookmark_categories (job_number, category) VALUES (?, ?)", jobNumber, c); err != nil {
+			return err
+		}
+	}
+	for _, k := range keywords {
+		if _, err := tx.Exec("INSERT INTO bookmark_keywords (job_number, keyword) VALUES (?, ?)", jobNumber, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteBookmarkTerms(tx *Tx, jobNumber string) error {
+	if _, err := tx.Exec("DELETE FROM bookmark_categories WHERE job_number = ?", jobNumber); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM bookmark_keywords WHERE job_number = ?", jobNumber)
+	return err
+}
+
+// 讀取所有書籤的類別、關鍵字與標籤，填入 MatchedCategories、MatchedKeywords 與 Tags
+func (s *Server) attachTerms(bookmarks []Bookmark) error {
+	fill, err := s.termFiller()
+	if err != nil {
+		return err
+	}
+	for i := range bookmarks {
+		fill(&bookmarks[i])
+	}
+	return nil
+}
+
+// 讀取所有書籤的類別、關鍵字與標籤，回傳逐筆填入 MatchedCategories、MatchedKeywords 與 Tags 的函式
+// 唯讀開啟尚未遷移的舊資料庫時沒有這些表，類別與關鍵字改由 data 解析，標籤為空
+func (s *Server) termFiller() (func(b *Bookmark), error) {
+	categories, err := s.loadTerms(context.Background(), "SELECT job_number, category FROM bookmark_categories ORDER BY job_number, category")
+	var keywords, tags map[string][]string
+	if err == nil {
+		keywords, err = s.loadTerms(context.Background(), "SELECT job_number, keyword FROM bookmark_keywords ORDER BY job_number, keyword")
+	}
+	if err == nil {
+		tags, err = s.loadTerms(context.Background(), "SELECT bt.job_number, t.name FROM bookmark_tags bt JOIN tags t ON t.id = bt.tag_id ORDER BY bt.job_number, t.name_key")
+	}
+	if err != nil {
+		if !s.cfg.ReadOnly {
+			return nil, err
+		}
+		return func(b *Bookmark) {
+			b.MatchedCategories, b.MatchedKeywords = bookmarkTerms(b.Data)
+			b.Tags = []string{}
+		}, nil
+	}
+	return func(b *Bookmark) {
+		b.MatchedCategories = append([]string{}, categories[b.JobNumber]...)
+		b.MatchedKeywords = append([]string{}, keywords[b.JobNumber]...)
+		b.Tags = append([]string{}, tags[b.JobNumber]...)
+	}, nil
+}
+
+func (s *Server) loadTerms(ctx context.Context, query string) (map[string][]string, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	terms := map[string][]string{}
+	for rows.Next() {
+		var jn, term string
+		if err := rows.Scan(&jn, &term); err != nil {
+			return nil, err
+		}
+		terms[jn] = append(terms[jn], term)
+	}
+	return terms, rows.Err()
+}
+
+// 是否含有指定的值
+func containsTerm(terms []string, value string) bool {
+	for _, t := range terms {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 補齊加密書籤的類別與關鍵字：遷移只能以 SQL 解析明文的 data，加密的 data 需在取得金鑰後處理
+// 完成後於 app_meta 記錄，之後啟動不再重複
+func (s *Server) backfillEncryptedTerms() error {
+	if s.db.cipher == nil || s.cfg.ReadOnly {
+		return nil
+	}
+	var done string
+	if err := s.db.QueryRowContext(context.Background(), "SELECT COALESCE(MAX(value), '') FROM app_meta WHERE key = 'terms_backfill'").Scan(&done); err != nil {
+		return err
+	}
+	if done != "" {
+		return nil
+	}
+
+	type row struct{ jobNumber, data string }
+	var pending []row
+	rows, err := s.db.QueryContext(context.Background(), "SELECT job_number, data FROM bookmarks WHERE data LIKE 'enc:v1:%'")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.jobNumber, &r.data); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	err = s.db.withTx(context.Background(), func(tx *Tx) error {
+		for _, r := range pending {
+			data, err := tx.cipher.open("data", r.data)
+			if err != nil {
+				return err
+			}
+			if err := syncBookmarkTerms(tx, r.jobNumber, data); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec("INSERT INTO app_meta (key, value) VALUES ('terms_backfill', 'done')")
+		return err
+	})
+	if err == nil && len(pending) > 0 {
+		log.Printf("已補齊 %d 筆加密書籤的類別與關鍵字", len(pending))
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // NAS 上可能沒有系統時區資料
+)
+
+// 時間戳記
+//
+// 資料庫中的時間一律為 UTC。SQLite 以固定寬度的 RFC 3339 文字儲存（2026-01-15T08:30:00.000Z），
+// 字串比較即為時間先後，寫入與比較時的參數都必須經過 dbTime；PostgreSQL 為 TIMESTAMPTZ，
+// 同樣的字串參數可直接使用。
+// JSON 輸出以顯示時區（預設 Asia/Taipei）表示並帶時差，例如 2026-01-15T16:30:00+08:00；
+// 「今天」、依日期篩選等計算也一律使用顯示時區。
+//
+// 其他工具或舊版寫入的資料可能是 CURRENT_TIMESTAMP、RFC 3339 或驅動的預設格式，
+// 驅動解析不了時 Scan 到 time.Time 會失敗，整筆資料被略過。時間欄位一律以 scanTime 讀取，
+// 依 timestampLayouts 解析；啟動時 normalizeTimestamps 將非標準格式改寫為 timestampLayout。
+
+// 資料庫時間格式，與 SQLite strftime('%Y-%m-%dT%H:%M:%fZ') 相同
+const timestampLayout = "2006-01-02T15:04:05.000Z"
+
+// 可接受的時間格式，沒有時區的視為 UTC
+var timestampLayouts = []string{
+	timestampLayout,
+	time.RFC3339Nano,                          // 匯入的資料
+	"2006-01-02 15:04:05.999999999",           // CURRENT_TIMESTAMP
+	"2006-01-02 15:04:05.999999999Z07:00",     // mattn/go-sqlite3 與 _time_format=sqlite
+	"2006-01-02 15:04:05.999999999 -0700 MST", // modernc.org/sqlite 預設格式（time.Time.String）
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// 解析資料庫中的時間文字，結果為 UTC
+func parseDBTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	// time.Time.String 附帶的單調時鐘讀數，例如 " m=+0.000123"
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("無法解析的時間格式: %q", s)
+}
+
+// 讀取時間欄位：驅動回傳的 time.Time、文字或 Unix 秒數都接受，NULL 為零值
+func scanTime(t *time.Time) sql.Scanner {
+	return timeScanner{t}
+}
+
+type timeScanner struct {
+	t *time.Time
+}
+
+func (s timeScanner) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*s.t = time.Time{}
+	case time.Time:
+		*s.t = v.UTC()
+	case int64:
+		*s.t = time.Unix(v, 0).UTC()
+	case string:
+		t, err := parseDBTime(v)
+		if err != nil {
+			return err
+		}
+		*s.t = t
+	case []byte:
+		t, err := parseDBTime(string(v))
+		if err != nil {
+			return err
+		}
+		*s.t = t
+	default:
+		return fmt.Errorf("無法將 %T 轉為時間", v)
+	}
+	return nil
+}
+
+// 需要統一格式的時間欄位
+var timestampColumns = []struct{ table, column string }{
+	{"bookmarks", "created_at"},
+	{"bookmarks", "updated_at"},
+	{"bookmarks", "deleted_at"},
+	{"bookmarks_archive", "created_at"},
+	{"bookmarks_archive", "updated_at"},
+	{"bookmarks_archive", "archived_at"},
+	{"bookmark_status_history", "changed_at"},
+	{"bookmark_changes", "detected_at"},
+	{"bookmark_changes", "seen_at"},
+	{"audit_log", "created_at"},
+	{"events", "created_at"},
+	{"tender_cache", "fetched_at"},
+	{"attachments", "created_at"},
+	{"attachments_archive", "created_at"},
+	{"attachments_archive", "archived_at"},
+	{"notification_log", "created_at"},
+	{"email_digests", "created_at"},
+	{"webhooks", "created_at"},
+	{"webhooks", "updated_at"},
+	{"webhook_deliveries", "created_at"},
+	{"webhook_deliveries", "next_attempt_at"},
+	{"webhook_deliveries", "delivered_at"},
+	{"ingest_runs", "started_at"},
+	{"ingest_runs", "finished_at"},
+	{"ingest_runs", "next_attempt_at"},
+	{"ingest_bookmarks", "created_at"},
+	{"tender_awards", "detected_at"},
+	{"calendar_events", "deadline"},
+	{"calendar_events", "updated_at"},
+	{"shares", "expires_at"},
+	{"shares", "revoked_at"},
+	{"shares", "created_at"},
+	{"weekly_reports", "created_at"},
+	{"weekly_reports", "period_start"},
+	{"weekly_reports", "period_end"},
+	{"watchlist", "created_at"},
+	{"watchlist", "updated_at"},
+	{"sync_state", "last_synced_at"},
+}
+
+// 將 SQLite 中非 timestampLayout 格式的時間改寫為標準格式
+// 遷移 11 已改寫過一次，但其他工具仍可能以 CURRENT_TIMESTAMP 等格式寫入，因此每次啟動都檢查；
+// 無法解析的值保留原樣並記錄。PostgreSQL 的 TIMESTAMPTZ 不需要改寫
+func (s *Server) normalizeTimestamps() error {
+	if s.cfg.ReadOnly || s.db.dialect != dialectSQLite {
+		return nil
+	}
+	type fix struct {
+		table, column string
+		rowid         int64
+		value         string
+	}
+	var fixes []fix
+	for _, c := range timestampColumns {
+		rows, err := s.db.QueryContext(context.Background(), fmt.Sprintf(`
+			SELECT rowid, %[1]s FROM %[2]s
+			WHERE %[1]s IS NOT NULL
+			  AND (typeof(%[1]s) <> 'text' OR %[1]s NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]T[0-9][0-9]:[0-9][0-9]:[0-9][0-9].[0-9][0-9][0-9]Z')`,
+			c.column, c.table))
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var rowid int64
+			var raw interface{}
+			if err := rows.Scan(&rowid, &raw); err != nil {
+				rows.Close()
+				return err
+			}
+			var t time.Time
+			if err := scanTime(&t).Scan(raw); err != nil {
+				log.Printf("%s.%s（rowid=%d）: %v，保留原值", c.table, c.column, rowid, err)
+				continue
+			}
+			fixes = append(fixes, fix{c.table, c.column, rowid, dbTime(t)})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	if len(fixes) == 0 {
+		return nil
+	}
+
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		for _, f := range fixes {
+			if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", f.table, f.column), f.value, f.rowid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		log.Printf("已將 %d 個時間欄位改寫為標準格式", len(fixes))
+	}
+	return err
+}
+
+// 顯示時區，由設定檔的 timezone 指定
+var displayLocation = taipei
+
+// 轉成資料庫格式的 UTC 字串
+func dbTime(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// 目前時間的資料庫格式
+func dbNow() string {
+	return dbTime(time.Now())
+}
+
+// 轉成顯示時區，零值維持零值
+func localTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(displayLocation)
+}
+
+// 顯示時區中某一天 00:00 的時間
+func startOfDay(date string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", date, displayLocation)
+}
+
+// 載入顯示時區；Asia/Taipei 使用固定時差，不需要時區資料
+func loadDisplayLocation(name string) (*time.Location, error) {
+	if name == "" || name == taipei.String() {
+		return taipei, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("不支援的時區: %s", name)
+	}
+	return loc, nil
+}
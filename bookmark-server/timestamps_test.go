@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseDBTime(t *testing.T) {
+	want := time.Date(2026, 1, 15, 15, 30, 0, 0, time.UTC)
+	for _, value := range []string{
+		"2026-01-15T15:30:00.000Z",
+		"2026-01-15T23:30:00+08:00",
+		"2026-01-15 15:30:00",
+		"2026-01-15 23:30:00+08:00",
+		"2026-01-15 15:30:00 +0000 UTC",
+		"2026-01-15 15:30:00 +0000 UTC m=+0.000123",
+	} {
+		got, err := parseDBTime(value)
+		if err != nil || !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("parseDBTime(%q) = %v, %v", value, got, err)
+		}
+	}
+	if _, err := parseDBTime("15/01/2026"); err == nil {
+		t.Error("parseDBTime 接受了無法解析的格式")
+	}
+}
+
+// 台灣時間 23:30 建立的資料：JSON 帶 +08:00，依日期篩選時屬於當天而不是前一天或隔天
+func TestTaipeiLateNightRecord(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001"}`)
+	const utc = "2026-01-15T15:30:00.000Z" // 2026-01-15 23:30 台灣時間
+	for _, table := range []string{"bookmarks", "audit_log"} {
+		if _, err := s.db.Exec("UPDATE "+table+" SET created_at = ?", utc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if b := getTestBookmark(t, h, "A001"); b.CreatedAt.Format(time.RFC3339) != "2026-01-15T23:30:00+08:00" {
+		t.Errorf("created_at = %s", b.CreatedAt.Format(time.RFC3339))
+	}
+	w := serve(t, h, "GET", "/api/bookmarks", "")
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil || len(raw) != 1 {
+		t.Fatalf("GET /api/bookmarks: %v %s", err, w.Body.String())
+	}
+	if got := string(raw[0]["created_at"]); got != `"2026-01-15T23:30:00+08:00"` {
+		t.Errorf("JSON created_at = %s", got)
+	}
+
+	for query, want := range map[string]float64{
+		"from=2026-01-15&to=2026-01-16": 1,
+		"from=2026-01-16":               0,
+		"to=2026-01-15":                 0,
+	} {
+		resp := decodeBody(t, serve(t, h, "GET", "/api/admin/audit?"+query, ""))
+		if resp["total"] != want {
+			t.Errorf("audit %s: total = %v, want %v", query, resp["total"], want)
+		}
+	}
+
+	// 顯示時區可設定
+	old := displayLocation
+	displayLocation = time.UTC
+	defer func() { displayLocation = old }()
+	if b := getTestBookmark(t, h, "A001"); b.CreatedAt.Format(time.RFC3339) != "2026-01-15T15:30:00Z" {
+		t.Errorf("UTC created_at = %s", b.CreatedAt.Format(time.RFC3339))
+	}
+}
+
+// 其他工具寫入的 CURRENT_TIMESTAMP 等格式在啟動時改寫為標準格式
+func TestNormalizeTimestamps(t *testing.T) {
+	s := newTestServer(t)
+	addTestBookmark(t, s.routes(), `{"job_number":"A001"}`)
+	if _, err := s.db.Exec("UPDATE bookmarks SET created_at = '2026-01-15 15:30:00', updated_at = '2026-01-15 23:30:00+08:00'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.normalizeTimestamps(); err != nil {
+		t.Fatalf("normalizeTimestamps: %v", err)
+	}
+	var created, updated string
+	if err := s.db.QueryRow("SELECT CAST(created_at AS TEXT), CAST(updated_at AS TEXT) FROM bookmarks").Scan(&created, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if created != "2026-01-15T15:30:00.000Z" || updated != "2026-01-15T15:30:00.000Z" {
+		t.Errorf("created_at = %s, updated_at = %s", created, updated)
+	}
+}
+
+// 其他工具以各種格式寫入的 created_at：清單不會略過任何一筆，時間正確，啟動時的正規化後格式一致
+func TestLegacyTimestampRowsListed(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	formats := []string{
+		"2026-01-15T15:30:00.000Z",
+		"2026-01-15T15:30:00Z",
+		"2026-01-15T23:30:00+08:00",
+		"2026-01-15 15:30:00",
+		"2026-01-15 23:30:00+08:00",
+		"2026-01-15 15:30:00 +0000 UTC",
+		"2026-01-15 15:30:00 +0000 UTC m=+0.000123",
+	}
+	for i, value := range formats {
+		if _, err := s.db.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, '', ?, ?)", fmt.Sprintf("L%02d", i), value, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		w := serve(t, h, "GET", "/api/bookmarks", "")
+		var list []Bookmark
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("%s: %v %s", stage, err, w.Body.String())
+		}
+		if len(list) != len(formats) {
+			t.Errorf("%s: 清單有 %d 筆，want %d", stage, len(list), len(formats))
+		}
+		want := time.Date(2026, 1, 15, 15, 30, 0, 0, time.UTC)
+		for _, b := range list {
+			if !b.CreatedAt.Equal(want) || !b.UpdatedAt.Equal(want) {
+				t.Errorf("%s: %s created_at = %v, updated_at = %v", stage, b.JobNumber, b.CreatedAt, b.UpdatedAt)
+			}
+		}
+	}
+	check("正規化前")
+
+	if err := s.normalizeTimestamps(); err != nil {
+		t.Fatalf("normalizeTimestamps: %v", err)
+	}
+	check("正規化後")
+	rows, err := s.db.Query("SELECT DISTINCT CAST(created_at AS TEXT) FROM bookmarks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var distinct []string
+	for rows.Next() {
+		var v string
+		rows.Scan(&v)
+		distinct = append(distinct, v)
+	}
+	if len(distinct) != 1 || distinct[0] != "2026-01-15T15:30:00.000Z" {
+		t.Errorf("正規化後的 created_at = %v", distinct)
+	}
+}
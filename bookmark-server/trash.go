@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"bookmark-server/store"
+)
+
+// 垃圾桶
+//
+// 刪除書籤（DELETE /api/bookmarks、批次刪除、同步對方的刪除）只在 deleted_at 記錄刪除時間（遷移 30），
+// 資料列與分類、標籤、狀態歷程、附件等子表資料都保留，還原時原封不動回來。
+// 垃圾桶中的書籤不出現在任何列表、檢查、匯出、下載與統計中：以 bookmarkFilter 篩選的查詢自動排除，
+// 其他查詢各自加上 deleted_at IS NULL（新增查詢 bookmarks 的程式時別忘了）；bookmarkSnapshot 視為不存在。
+// 完整性檢查、清理（prune）與加密金鑰輪替等維護作業仍涵蓋垃圾桶中的書籤，其子表資料不是孤兒。
+// 重新加入垃圾桶中的案號（POST /api/bookmarks）時移出垃圾桶並套用新的資料，不會違反 UNIQUE(job_number)。
+// 超過 trash_retention 的書籤在啟動時與之後每天永久刪除，也可以用 DELETE /api/bookmarks/purge 立即刪除。
+
+// 垃圾桶以外的書籤，用於組合 WHERE 子句
+const notDeleted = "deleted_at IS NULL"
+
+// 書籤不在垃圾桶中（不存在或未刪除）
+var errNotInTrash = errors.New("書籤不在垃圾桶中")
+
+// 在交易中將書籤移到垃圾桶；遞增版本，讓快取與樂觀鎖看到變更
+func trashBookmark(ctx context.Context, tx *Tx, jobNumber string) error {
+	_, err := store.Trash(ctx, tx, jobNumber, dbNow())
+	return err
+}
+
+// GET /api/bookmarks/trash：垃圾桶中的書籤，最近刪除的在前；purge_at 為自動永久刪除的時間（停用時為 null）
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+bookmarkColumns+", deleted_at FROM bookmarks WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	bookmarks := make([]Bookmark, 0)
+	for rows.Next() {
+		var deletedAt time.Time
+		b, err := scanBookmark(rows, s.db.cipher, scanTime(&deletedAt))
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		deletedAt = localTime(deletedAt)
+		b.DeletedAt = &deletedAt
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if err := s.attachTerms(bookmarks); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	type trashItem struct {
+		Bookmark
+		PurgeAt *time.Time `json:"purge_at"`
+	}
+	items := make([]trashItem, len(bookmarks))
+	for i, b := range bookmarks {
+		items[i].Bookmark = b
+		if s.cfg.TrashRetention.Duration > 0 {
+			purgeAt := b.DeletedAt.Add(s.cfg.TrashRetention.Duration)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+// POST /api/bookmarks/restore?job_number=：將書籤移出垃圾桶，回傳還原後的書籤
+func (s *Server) restoreBookmark(w http.ResponseWriter, r *http.Request) {
+	jobNumber, ok := jobNumberParam(w, r, r.URL.Query().Get("job_number"))
+	if !ok {
+		return
+	}
+
+	var restored *Bookmark
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		// 更新時間讓同步的對方把還原視為比刪除新的異動
+		result, err := tx.Exec("UPDATE bookmarks SET deleted_at = NULL, version = version + 1, updated_at = ? WHERE job_number = ? AND deleted_at IS NOT NULL",
+			dbNow(), jobNumber)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errNotInTrash
+		}
+		if restored, err = bookmarkSnapshot(tx, jobNumber); err != nil {
+			return err
+		}
+		if err := writeEvent(tx, requestActor(r), entityBookmark, jobNumber, actionRestore, restored); err != nil {
+			return err
+		}
+		return writeAudit(tx, newAuditEntry(r, "從垃圾桶還原書籤", jobNumber))
+	})
+	if errors.Is(err, errNotInTrash) {
+		writeError(w, r, http.StatusNotFound, "not_in_trash", jobNumber)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	restored.MatchedCategories, restored.MatchedKeywords = bookmarkTerms(restored.Data)
+
+	s.markChanged()
+	writeSuccess(w, r, http.StatusOK, "bookmark_restored", map[string]interface{}{"bookmark": restored})
+}
+
+// 永久刪除在 cutoff 之前移到垃圾桶的書籤與其分類、標籤，回傳刪除的案號
+// 其他子表資料與附件檔案由清理（prune）移除
+func (s *Server) purgeTrash(ctx context.Context, cutoff time.Time, audit AuditEntry) ([]string, error) {
+	purged := make([]string, 0)
+	err := s.db.withTx(ctx, func(tx *Tx) error {
+		purged = purged[:0]
+		rows, err := tx.Query("SELECT job_number FROM bookmarks WHERE deleted_at IS NOT NULL AND deleted_at <= ? ORDER BY id", dbTime(cutoff))
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var jn string
+			if err := rows.Scan(&jn); err != nil {
+				rows.Close()
+				return err
+			}
+			purged = append(purged, jn)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(purged) == 0 {
+			return err
+		}
+		for _, jn := range purged {
+			if _, err := tx.Exec("DELETE FROM bookmarks WHERE job_number = ? AND deleted_at IS NOT NULL", jn); err != nil {
+				return err
+			}
+			if err := deleteBookmarkTerms(tx, jn); err != nil {
+				return err
+			}
+			if err := setBookmarkTags(tx, jn, nil); err != nil {
+				return err
+			}
+		}
+		audit.Summary = fmt.Sprintf("永久刪除垃圾桶中的書籤 %d 筆（刪除時間不晚於 %s）", len(purged), cutoff.Format(time.RFC3339))
+		audit.JobNumbers = purged
+		return writeAudit(tx, audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(purged) > 0 {
+		s.markChanged()
+	}
+	return purged, nil
+}
+
+// 排程：永久刪除超過 trash_retention 的書籤
+func (s *Server) purgeExpiredTrash(ctx context.Context) error {
+	purged, err := s.purgeTrash(ctx, time.Now().Add(-s.cfg.TrashRetention.Duration),
+		AuditEntry{Actor: systemActor, Method: "SCHEDULE", Path: "trash_purge"})
+	if err == nil && len(purged) > 0 {
+		log.Printf("已永久刪除垃圾桶中超過 %s 的書籤 %d 筆", s.cfg.TrashRetention.Duration, len(purged))
+	}
+	return err
+}
+
+// DELETE /api/bookmarks/purge?days=：永久刪除移到垃圾桶超過 days 天的書籤；
+// 未指定時依 trash_retention（停用時為 0），0 表示清空垃圾桶
+func (s *Server) purgeTrashHandler(w http.ResponseWriter, r *http.Request) {
+	defaultDays := int(s.cfg.TrashRetention.Duration / (24 * time.Hour))
+	days, ok := intParam(r, "days", defaultDays, 0, 36500)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "days")
+		return
+	}
+	purged, err := s.purgeTrash(r.Context(), time.Now().AddDate(0, 0, -days), newAuditEntry(r, ""))
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeSuccess(w, r, http.StatusOK, "trash_purged", map[string]interface{}{
+		"days":        days,
+		"purged":      len(purged),
+		"job_numbers": purged,
+	})
+}
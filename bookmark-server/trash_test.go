@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 將書籤的刪除時間改為 days 天前
+func backdateTrash(t *testing.T, s *Server, jobNumber string, days int) {
+	t.Helper()
+	err := s.db.withTx(context.Background(), func(tx *Tx) error {
+		_, err := tx.Exec("UPDATE bookmarks SET deleted_at = ? WHERE job_number = ?", dbTime(time.Now().AddDate(0, 0, -days)), jobNumber)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 垃圾桶中的案號，依回應順序
+func trashJobNumbers(t *testing.T, h http.Handler) []string {
+	t.Helper()
+	w := serve(t, h, "GET", "/api/bookmarks/trash", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("trash status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Items []struct {
+			JobNumber string     `json:"job_number"`
+			DeletedAt *time.Time `json:"deleted_at"`
+			PurgeAt   *time.Time `json:"purge_at"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	jns := make([]string, len(resp.Items))
+	for i, item := range resp.Items {
+		jns[i] = item.JobNumber
+		if item.DeletedAt == nil || item.PurgeAt == nil || item.PurgeAt.Sub(*item.DeletedAt) != 30*24*time.Hour {
+			t.Errorf("%s: deleted_at = %v purge_at = %v", item.JobNumber, item.DeletedAt, item.PurgeAt)
+		}
+	}
+	return jns
+}
+
+func TestTrashExcludedFromReads(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"保留"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"刪除","note":"備註"}`)
+	if w := serve(t, h, "DELETE", "/api/bookmarks?job_number=A002", ""); w.Code != http.StatusOK {
+		t.Fatalf("刪除 status = %d: %s", w.Code, w.Body.String())
+	}
+
+	if jns := trashJobNumbers(t, h); len(jns) != 1 || jns[0] != "A002" {
+		t.Fatalf("垃圾桶 = %v, want [A002]", jns)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		check  func(body []byte) bool
+	}{
+		{"bookmarks", "/api/bookmarks", func(body []byte) bool {
+			var bookmarks []Bookmark
+			json.Unmarshal(body, &bookmarks)
+			return len(bookmarks) == 1 && bookmarks[0].JobNumber == "A001"
+		}},
+		{"list", "/api/bookmarks/list?format_version=2", func(body []byte) bool {
+			var resp struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal(body, &resp)
+			return resp.Count == 1
+		}},
+		{"check", "/api/bookmarks/check?job_number=A002", func(body []byte) bool {
+			var resp map[string]interface{}
+			json.Unmarshal(body, &resp)
+			return resp["bookmarked"] == false
+		}},
+		{"export", "/api/bookmarks/export", func(body []byte) bool {
+			var bookmarks []Bookmark
+			json.Unmarshal(body, &bookmarks)
+			return len(bookmarks) == 1 && bookmarks[0].JobNumber == "A001"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, "GET", tt.target, "")
+			if w.Code != http.StatusOK || !tt.check(w.Body.Bytes()) {
+				t.Errorf("status = %d, 垃圾桶中的書籤未排除: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRestoreBookmark(t *testing.T) {
+	h := newTestServer(t).routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"道路養護工程","note":"週五前報價","priority":4}`)
+	before := getTestBookmark(t, h, "A001")
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A001", "")
+
+	w := serve(t, h, "POST", "/api/bookmarks/restore?job_number=A001", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("還原 status = %d: %s", w.Code, w.Body.String())
+	}
+	if resp := decodeBody(t, w); resp["code"] != "bookmark_restored" {
+		t.Errorf("code = %v", resp["code"])
+	}
+	after := getTestBookmark(t, h, "A001")
+	if after.Note != before.Note || after.Priority != before.Priority || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("還原後資料不同: before=%+v after=%+v", before, after)
+	}
+	if after.Version <= before.Version {
+		t.Errorf("還原後 version = %d, want > %d", after.Version, before.Version)
+	}
+	if jns := trashJobNumbers(t, h); len(jns) != 0 {
+		t.Errorf("還原後垃圾桶 = %v", jns)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"not trashed", "/api/bookmarks/restore?job_number=A001", http.StatusNotFound, "not_in_trash"},
+		{"missing", "/api/bookmarks/restore?job_number=Z999", http.StatusNotFound, "not_in_trash"},
+		{"no job number", "/api/bookmarks/restore", http.StatusBadRequest, "missing_job_number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, "POST", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if resp := decodeBody(t, w); resp["error"] != tt.code {
+				t.Errorf("error = %v, want %s", resp["error"], tt.code)
+			}
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	for _, body := range []string{
+		`{"job_number":"A001","title":"保留"}`,
+		`{"job_number":"A002","title":"剛刪除"}`,
+		`{"job_number":"A003","title":"很久以前刪除","tags":["投標"]}`,
+	} {
+		addTestBookmark(t, h, body)
+	}
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A002", "")
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A003", "")
+	backdateTrash(t, s, "A003", 40)
+	var tags int
+	s.db.QueryRow("SELECT COUNT(*) FROM bookmark_tags WHERE job_number = 'A003'").Scan(&tags)
+	if tags != 1 {
+		t.Fatalf("垃圾桶中的書籤應保留標籤, got %d", tags)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		purged []string
+		trash  []string
+	}{
+		{"invalid days", "/api/bookmarks/purge?days=-1", http.StatusBadRequest, nil, []string{"A002", "A003"}},
+		{"default retention", "/api/bookmarks/purge", http.StatusOK, []string{"A003"}, []string{"A002"}},
+		{"nothing older", "/api/bookmarks/purge?days=7", http.StatusOK, []string{}, []string{"A002"}},
+		{"empty trash", "/api/bookmarks/purge?days=0", http.StatusOK, []string{"A002"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, "DELETE", tt.target, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.purged != nil {
+				var resp struct {
+					Code       string   `json:"code"`
+					Purged     int      `json:"purged"`
+					JobNumbers []string `json:"job_numbers"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Code != "trash_purged" || resp.Purged != len(tt.purged) || !equalStrings(resp.JobNumbers, tt.purged) {
+					t.Errorf("回應 = %+v, want %v", resp, tt.purged)
+				}
+			}
+			if jns := trashJobNumbers(t, h); !equalStrings(jns, tt.trash) {
+				t.Errorf("垃圾桶 = %v, want %v", jns, tt.trash)
+			}
+		})
+	}
+
+	// 永久刪除後無法還原，未刪除的書籤不受影響，重新加入時建立新的書籤
+	if w := serve(t, h, "POST", "/api/bookmarks/restore?job_number=A003", ""); w.Code != http.StatusNotFound {
+		t.Errorf("還原已永久刪除的書籤 status = %d, want 404", w.Code)
+	}
+	s.db.QueryRow("SELECT COUNT(*) FROM bookmark_tags WHERE job_number = 'A003'").Scan(&tags)
+	if tags != 0 {
+		t.Errorf("永久刪除後仍有 %d 個標籤", tags)
+	}
+	if b := getTestBookmark(t, h, "A001"); b.Title != "保留" {
+		t.Errorf("A001 = %+v", b)
+	}
+	if code, resp := postBookmark(t, h, `{"job_number":"A003","title":"重新加入"}`); code != http.StatusCreated || !resp.Created || resp.Restored {
+		t.Errorf("重新加入 status = %d resp = %+v", code, resp)
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	s := newTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"過期"}`)
+	addTestBookmark(t, h, `{"job_number":"A002","title":"未過期"}`)
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A001", "")
+	serve(t, h, "DELETE", "/api/bookmarks?job_number=A002", "")
+	backdateTrash(t, s, "A001", 31)
+	backdateTrash(t, s, "A002", 29)
+
+	if err := s.purgeExpiredTrash(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if jns := trashJobNumbers(t, h); !equalStrings(jns, []string{"A002"}) {
+		t.Errorf("垃圾桶 = %v, want [A002]", jns)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 匯入公司能力清單
+//
+// POST /api/watchlist/import 匯入業務的能力清單（JSON 或 CSV）：每項能力有名稱、關鍵字、優先順序與排除關鍵字，
+// 匯入後存於 watchlist 表，每日下載（ingest.go）篩選時作為一個類別，且一律在關注清單中：
+// 符合的標案自動加入書籤，書籤的優先順序為符合的能力中最高的；標題同時含有關鍵字與該能力的排除關鍵字時不算符合。
+// 與設定檔 ingest.categories 同名的能力取代設定檔中的類別。
+// 已有的能力依名稱更新，清單中沒有的能力保留；?mode=replace 時刪除清單中沒有的能力。
+// ?dry_run=true 只回傳差異，不寫入。GET /api/watchlist 列出匯入的能力與實際使用的類別與關注清單。
+//
+// JSON 格式：{"capabilities": [{"name": "網站設計", "keywords": ["網站", "RWD"], "priority": 2, "negative_keywords": ["維護"]}]}
+// CSV 格式（Content-Type: text/csv）：第一列為欄位名稱 name、keywords、priority、negative_keywords
+// （也接受 能力、關鍵字、優先順序、排除關鍵字），關鍵字以分號、頓號或換行分隔。
+
+// 請求內容上限
+const watchlistImportMaxBytes = 1 << 20
+
+// 匯入方式
+const (
+	watchlistMerge   = "merge"   // 新增或更新清單中的能力，其他保留
+	watchlistReplace = "replace" // 另刪除清單中沒有的能力
+)
+
+// 差異的動作
+const (
+	watchlistCreate    = "create"
+	watchlistUpdate    = "update"
+	watchlistUnchanged = "unchanged"
+	watchlistRemove    = "remove"
+)
+
+// CSV 欄位名稱（小寫）對應的欄位
+var watchlistCSVColumns = map[string]string{
+	"name":              "name",
+	"capability":        "name",
+	"能力":                "name",
+	"名稱":                "name",
+	"keywords":          "keywords",
+	"關鍵字":               "keywords",
+	"priority":          "priority",
+	"priority_weight":   "priority",
+	"優先順序":              "priority",
+	"權重":                "priority",
+	"negative_keywords": "negative_keywords",
+	"排除關鍵字":             "negative_keywords",
+}
+
+// WatchlistEntry 一項能力
+type WatchlistEntry struct {
+	Name             string    `json:"name"`
+	Keywords         []string  `json:"keywords"`
+	NegativeKeywords []string  `json:"negative_keywords"`
+	Priority         int       `json:"priority"`
+	CreatedAt        time.Time `json:"created_at,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+}
+
+// 一項能力的差異
+type watchlistChange struct {
+	Name                    string   `json:"name"`
+	Action                  string   `json:"action"`
+	KeywordsAdded           []string `json:"keywords_added,omitempty"`
+	KeywordsRemoved         []string `json:"keywords_removed,omitempty"`
+	NegativeKeywordsAdded   []string `json:"negative_keywords_added,omitempty"`
+	NegativeKeywordsRemoved []string `json:"negative_keywords_removed,omitempty"`
+	PriorityFrom            *int     `json:"priority_from,omitempty"`
+	PriorityTo              *int     `json:"priority_to,omitempty"`
+}
+
+// 讀取匯入的能力，依名稱排列
+func (s *Server) watchlistEntries(ctx context.Context) ([]WatchlistEntry, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, keywords, negative_keywords, priority, created_at, updated_at FROM watchlist ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]WatchlistEntry, 0)
+	for rows.Next() {
+		var e WatchlistEntry
+		var keywords, negative string
+		if err := rows.Scan(&e.Name, &keywords, &negative, &e.Priority, scanTime(&e.CreatedAt), scanTime(&e.UpdatedAt)); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(keywords), &e.Keywords)
+		json.Unmarshal([]byte(negative), &e.NegativeKeywords)
+		e.CreatedAt, e.UpdatedAt = localTime(e.CreatedAt), localTime(e.UpdatedAt)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// 篩選使用的類別（設定檔的類別加上匯入的能力，同名時以能力取代）與關注清單
+func (s *Server) watchCategories() ([]IngestCategory, []string, error) {
+	entries, err := s.watchlistEntries(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	imported := map[string]WatchlistEntry{}
+	for _, e := range entries {
+		imported[e.Name] = e
+	}
+	categories := make([]IngestCategory, 0, len(s.cfg.Ingest.Categories)+len(entries))
+	for _, cat := range s.cfg.Ingest.Categories {
+		if _, ok := imported[cat.Name]; !ok {
+			categories = append(categories, cat)
+		}
+	}
+	watchlist := append([]string(nil), s.cfg.Ingest.Watchlist...)
+	for _, e := range entries {
+		categories = append(categories, IngestCategory{Name: e.Name, Keywords: e.Keywords, NegativeKeywords: e.NegativeKeywords, Priority: e.Priority})
+		if !containsString(watchlist, e.Name) {
+			watchlist = append(watchlist, e.Name)
+		}
+	}
+	return categories, watchlist, nil
+}
+
+// 去除空白與重複（不分大小寫）的關鍵字，保留原本的順序
+func normalizeKeywords(keywords []string) []string {
+	out := make([]string, 0, len(keywords))
+	seen := map[string]bool{}
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" || seen[strings.ToLower(kw)] {
+			continue
+		}
+		seen[strings.ToLower(kw)] = true
+		out = append(out, kw)
+	}
+	return out
+}
+
+// CSV 儲存格中的關鍵字清單
+func splitKeywords(cell string) []string {
+	return strings.FieldsFunc(cell, func(r rune) bool {
+		return r == ';' || r == '；' || r == '、' || r == '\n' || r == '\r'
+	})
+}
+
+// 解析 CSV 能力清單；第一列為欄位名稱
+func parseWatchlistCSV(r io.Reader) ([]WatchlistEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV 沒有內容")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV 格式錯誤: %w", err)
+	}
+	columns := make([]string, len(header))
+	hasName, hasKeywords := false, false
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) // Excel 匯出的 BOM
+		columns[i] = watchlistCSVColumns[h]
+		hasName = hasName || columns[i] == "name"
+		hasKeywords = hasKeywords || columns[i] == "keywords"
+	}
+	if !hasName || !hasKeywords {
+		return nil, errors.New("CSV 第一列必須有 name 與 keywords 欄位")
+	}
+	var entries []WatchlistEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV 格式錯誤: %w", err)
+		}
+		var e WatchlistEntry
+		blank := true
+		for i, cell := range record {
+			if i >= len(columns) {
+				break
+			}
+			cell = strings.TrimSpace(cell)
+			blank = blank && cell == ""
+			switch columns[i] {
+			case "name":
+				e.Name = cell
+			case "keywords":
+				e.Keywords = splitKeywords(cell)
+			case "negative_keywords":
+				e.NegativeKeywords = splitKeywords(cell)
+			case "priority":
+				if cell == "" {
+					continue
+				}
+				n, err := strconv.Atoi(cell)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 列的優先順序不是整數: %q", line, cell)
+				}
+				e.Priority = n
+			}
+		}
+		if !blank {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// 檢查並整理匯入的能力
+func checkWatchlistEntries(entries []WatchlistEntry) error {
+	if len(entries) == 0 {
+		return errors.New("能力清單是空的")
+	}
+	seen := map[string]bool{}
+	for i := range entries {
+		e := &entries[i]
+		e.Name = strings.TrimSpace(e.Name)
+		if e.Name == "" || strings.ContainsAny(e.Name, `/\`) || strings.IndexFunc(e.Name, unicode.IsControl) >= 0 {
+			return fmt.Errorf("第 %d 項能力的名稱不可為空或含有斜線: %q", i+1, e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("能力名稱重複: %s", e.Name)
+		}
+		seen[e.Name] = true
+		e.Keywords = normalizeKeywords(e.Keywords)
+		e.NegativeKeywords = normalizeKeywords(e.NegativeKeywords)
+		if len(e.Keywords) == 0 {
+			return fmt.Errorf("能力 %s 至少要有一個關鍵字", e.Name)
+		}
+	}
+	return nil
+}
+
+// 在 a 中但不在 b 中的字（不分大小寫）
+func keywordsMissing(a, b []string) []string {
+	var out []string
+	for _, kw := range a {
+		found := false
+		for _, other := range b {
+			if strings.EqualFold(kw, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+// 比較匯入的能力與現有的能力
+func diffWatchlist(current, incoming []WatchlistEntry, mode string) []watchlistChange {
+	existing := map[string]WatchlistEntry{}
+	for _, e := range current {
+		existing[e.Name] = e
+	}
+	changes := make([]watchlistChange, 0, len(incoming))
+	for _, e := range incoming {
+		old, ok := existing[e.Name]
+		if !ok {
+			changes = append(changes, watchlistChange{Name: e.Name, Action: watchlistCreate, KeywordsAdded: e.Keywords, NegativeKeywordsAdded: e.NegativeKeywords})
+			continue
+		}
+		delete(existing, e.Name)
+		c := watchlistChange{
+			Name:                    e.Name,
+			Action:                  watchlistUnchanged,
+			KeywordsAdded:           keywordsMissing(e.Keywords, old.Keywords),
+			KeywordsRemoved:         keywordsMissing(old.Keywords, e.Keywords),
+			NegativeKeywordsAdded:   keywordsMissing(e.NegativeKeywords, old.NegativeKeywords),
+			NegativeKeywordsRemoved: keywordsMissing(old.NegativeKeywords, e.NegativeKeywords),
+		}
+		if old.Priority != e.Priority {
+			from, to := old.Priority, e.Priority
+			c.PriorityFrom, c.PriorityTo = &from, &to
+		}
+		if len(c.KeywordsAdded)+len(c.KeywordsRemoved)+len(c.NegativeKeywordsAdded)+len(c.NegativeKeywordsRemoved) > 0 || c.PriorityTo != nil {
+			c.Action = watchlistUpdate
+		}
+		changes = append(changes, c)
+	}
+	if mode == watchlistReplace {
+		var removed []string
+		for name := range existing {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		for _, name := range removed {
+			changes = append(changes, watchlistChange{Name: name, Action: watchlistRemove})
+		}
+	}
+	return changes
+}
+
+// GET /api/watchlist：匯入的能力，以及每日下載實際使用的類別與關注清單
+func (s *Server) getWatchlist(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.watchlistEntries(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	categories, watchlist, err := s.watchCategories()
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":      len(entries),
+		"items":      entries,
+		"categories": categories,
+		"watchlist":  watchlist,
+	})
+}
+
+// POST /api/watchlist/import?mode=merge|replace&dry_run=true
+func (s *Server) importWatchlist(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mode := firstNonEmpty(q.Get("mode"), watchlistMerge)
+	if mode != watchlistMerge && mode != watchlistReplace {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "mode")
+		return
+	}
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "dry_run")
+			return
+		}
+		dryRun = b
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, watchlistImportMaxBytes)
+	var entries []WatchlistEntry
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		var err error
+		if entries, err = parseWatchlistCSV(r.Body); err != nil {
+			writeError(w, r, http.StatusBadRequest, "watchlist_import_invalid", err.Error())
+			return
+		}
+	} else {
+		var req struct {
+			Capabilities []WatchlistEntry `json:"capabilities"`
+		}
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		entries = req.Capabilities
+	}
+	if err := checkWatchlistEntries(entries); err != nil {
+		writeError(w, r, http.StatusBadRequest, "watchlist_import_invalid", err.Error())
+		return
+	}
+
+	current, err := s.watchlistEntries(r.Context())
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	changes := diffWatchlist(current, entries, mode)
+	counts := map[string]int{watchlistCreate: 0, watchlistUpdate: 0, watchlistUnchanged: 0, watchlistRemove: 0}
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+
+	if !dryRun && counts[watchlistCreate]+counts[watchlistUpdate]+counts[watchlistRemove] > 0 {
+		byName := map[string]WatchlistEntry{}
+		for _, e := range entries {
+			byName[e.Name] = e
+		}
+		err := s.db.withTx(r.Context(), func(tx *Tx) error {
+			now := dbNow()
+			for _, c := range changes {
+				var err error
+				switch c.Action {
+				case watchlistCreate, watchlistUpdate:
+					e := byName[c.Name]
+					keywords, _ := json.Marshal(e.Keywords)
+					negative, _ := json.Marshal(e.NegativeKeywords)
+					_, err = tx.Exec(`
+						INSERT INTO watchlist (name, keywords, negative_keywords, priority, created_at, updated_at)
+						VALUES (?, ?, ?, ?, ?, ?)
+						ON CONFLICT(name) DO UPDATE SET
+							keywords = excluded.keywords, negative_keywords = excluded.negative_keywords,
+							priority = excluded.priority, updated_at = excluded.updated_at`,
+						e.Name, string(keywords), string(negative), e.Priority, now, now)
+				case watchlistRemove:
+					_, err = tx.Exec("DELETE FROM watchlist WHERE name = ?", c.Name)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return writeAudit(tx, newAuditEntry(r, fmt.Sprintf("匯入能力清單：新增 %d、更新 %d、刪除 %d",
+				counts[watchlistCreate], counts[watchlistUpdate], counts[watchlistRemove])))
+		})
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"dry_run":   dryRun,
+		"mode":      mode,
+		"created":   counts[watchlistCreate],
+		"updated":   counts[watchlistUpdate],
+		"unchanged": counts[watchlistUnchanged],
+		"removed":   counts[watchlistRemove],
+		"changes":   changes,
+	})
+}
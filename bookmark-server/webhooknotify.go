@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 通用 webhook 通知服務
+// 以 JSON POST 到 url，內容為 webhookNotificationPayload（摘要模式下 items 有多則），給自行撰寫的接收端或自動化服務使用；
+// 要送到 Slack 請改用 type "slack"。設定 token 時以其為密鑰簽署請求內容，
+// 標頭 X-Signature 為 sha256=<HMAC-SHA256，十六進位>，與 /api/webhooks 的訂閱相同。
+// 回應 2xx 視為成功。
+
+type webhookNotifier struct {
+	url          string
+	secret       string
+	retryBackoff time.Duration
+}
+
+func newWebhookNotifier(cfg ProviderConfig) (*webhookNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("未設定 webhook 網址（url）")
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook 網址必須是 http 或 https 網址: %q", cfg.URL)
+	}
+	return &webhookNotifier{url: cfg.URL, secret: cfg.Token, retryBackoff: notifyRetryBackoff}, nil
+}
+
+// 送出的內容
+type webhookNotificationPayload struct {
+	Type    string                    `json:"type"` // 固定為 notification
+	SentAt  time.Time                 `json:"sent_at"`
+	Skipped int                       `json:"skipped"` // 先前因頻率限制未送出的則數
+	Items   []webhookNotificationItem `json:"items"`
+}
+
+type webhookNotificationItem struct {
+	Trigger   string     `json:"trigger"`
+	JobNumber string     `json:"job_number,omitempty"`
+	Title     string     `json:"title"`
+	UnitName  string     `json:"unit_name,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	Priority  int        `json:"priority"`
+	Note      string     `json:"note,omitempty"`
+	Keywords  []string   `json:"keywords,omitempty"`
+	Deadline  *time.Time `json:"deadline,omitempty"`
+	DaysLeft  *int       `json:"days_left,omitempty"` // 只有 deadline 有值
+	Headline  string     `json:"headline"`
+	Text      string     `json:"text"` // 由範本產生的訊息
+}
+
+func (wn *webhookNotifier) send(ctx context.Context, d *delivery) (int, error) {
+	p := webhookNotificationPayload{Type: "notification", SentAt: localTime(time.Now()), Skipped: d.Skipped}
+	for _, n := range d.Items {
+		item := webhookNotificationItem{
+			Trigger:   n.Trigger,
+			JobNumber: n.JobNumber,
+			Title:     n.Title,
+			UnitName:  n.UnitName,
+			URL:       n.URL,
+			Priority:  n.Priority,
+			Note:      n.Note,
+			Keywords:  n.Keywords,
+			Headline:  notificationHeadline(n),
+			Text:      n.Text,
+		}
+		if !n.Deadline.IsZero() {
+			deadline := localTime(n.Deadline)
+			item.Deadline = &deadline
+		}
+		if n.Trigger == triggerDeadline {
+			daysLeft := n.DaysLeft
+			item.DaysLeft = &daysLeft
+		}
+		p.Items = append(p.Items, item)
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := postNotification(ctx, wn.retryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", wn.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "bookmark-server")
+		if wn.secret != "" {
+			mac := hmac.New(sha256.New, []byte(wn.secret))
+			mac.Write(body)
+			req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		return req, nil
+	})
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status < 200 || resp.Status > 299 {
+		return resp.Status, fmt.Errorf("webhook 回應 %d: %s", resp.Status, truncateMessage(strings.TrimSpace(string(resp.Body))))
+	}
+	return resp.Status, nil
+}
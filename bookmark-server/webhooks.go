@@ -0,0 +1,845 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Webhook
+//
+// 以 /api/webhooks 管理訂閱（網址、密鑰、事件篩選），書籤與下載事件（與 /api/events/stream 相同）
+// 以 JSON POST 到訂閱的網址，內容為事件加上 type（種類.動作，例如 bookmark.update）。
+// 請求帶有以下標頭：
+//   - X-Signature：sha256=<以密鑰對請求內容計算的 HMAC-SHA256，十六進位>
+//   - X-Webhook-Event：事件名稱
+//   - X-Webhook-Delivery：送出紀錄的 id，重新送出時為新的 id
+// 事件篩選為「種類.動作」或「種類.*」，未設定時送出所有事件。
+// 事件寫入後由 webhookDispatcher 為每個訂閱建立送出紀錄（webhook_deliveries），每個訂閱記錄處理到的事件 id，
+// 重新啟動後從該處繼續；新的訂閱只送出建立之後的事件。
+// 回應非 2xx 或連線失敗時依 webhookBackoff 重試（429 的 Retry-After 較長時以其為準），全部失敗後標記為 failed；
+// 以 POST /api/webhooks/{id}/deliveries/{delivery_id}/redeliver 重新送出任一筆紀錄。
+// 不保證送達順序，接收端應以事件 id 判斷先後。
+// 為避免伺服器被用來存取內部網路（SSRF），網址解析為私有、本機或鏈路本地位址時須設定 allow_private；
+// 送出時也在連線前檢查實際連線的位址，DNS 之後改指向內部位址也會被拒絕，且不跟隨轉址。
+// 訂閱與送出紀錄存於設定年度的資料庫，只送出該年度資料庫的事件。
+
+// 送出紀錄的狀態
+const (
+	webhookPending = "pending"
+	webhookSuccess = "success"
+	webhookFailed  = "failed"
+)
+
+// 第 n 次失敗後等待 webhookBackoff[n-1] 再重試，用完時標記為失敗
+var webhookBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute, time.Hour}
+
+const (
+	webhookPollInterval = 30 * time.Second // 沒有新事件時檢查待重試紀錄的間隔
+	webhookBatchSize    = 20
+	webhookTimeout      = 15 * time.Second
+
+	// 完成（成功或失敗）的送出紀錄保留期限
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+)
+
+var errWebhookPrivateAddress = errors.New("私有網路位址")
+
+// Webhook 訂閱；密鑰只在建立時回傳
+type Webhook struct {
+	ID           int64     `json:"id"`
+	URL          string    `json:"url"`
+	Secret       string    `json:"secret,omitempty"`
+	Events       []string  `json:"events"`
+	AllowPrivate bool      `json:"allow_private"`
+	Active       bool      `json:"active"`
+	LastEventID  int64     `json:"last_event_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// 建立與更新訂閱的請求內容；更新時未提供的欄位維持不變
+type webhookRequest struct {
+	URL          *string   `json:"url"`
+	Secret       *string   `json:"secret"` // 建立時未提供則隨機產生
+	Events       *[]string `json:"events"`
+	AllowPrivate *bool     `json:"allow_private"`
+	Active       *bool     `json:"active"`
+}
+
+// WebhookDelivery 送出紀錄
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status"`
+	Error          string          `json:"error"`
+	RedeliveryOf   int64           `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"` // 只有 pending 有值
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// 送出的內容
+type webhookPayload struct {
+	Type string `json:"type"` // 種類.動作
+	Event
+}
+
+// 檢查事件篩選：「種類.動作」或「種類.*」，種類須為推送的事件種類
+func checkWebhookEvents(events []string) error {
+	for _, e := range events {
+		entity, action, ok := strings.Cut(e, ".")
+		if !ok || action == "" || !containsString(streamEntityTypes, entity) {
+			return fmt.Errorf("%s（可用的種類: %s）", e, strings.Join(streamEntityTypes, "、"))
+		}
+	}
+	return nil
+}
+
+// 事件名稱是否符合篩選，沒有篩選時全部符合
+func webhookMatches(filter []string, eventType string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	entity, _, _ := strings.Cut(eventType, ".")
+	for _, f := range filter {
+		if f == eventType || f == entity+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// 100.64.0.0/10（電信業者 NAT），net.IP.IsPrivate 不包含
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// 私有、本機、鏈路本地等不應由伺服器主動連線的位址
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip)
+}
+
+// 檢查訂閱網址；未允許私有網路時解析主機，任一位址為私有網路即拒絕（回傳 errWebhookPrivateAddress）
+func checkWebhookURL(ctx context.Context, raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("必須是 http 或 https 網址")
+	}
+	if allowPrivate {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("無法解析主機 %s", u.Hostname())
+	}
+	for _, a := range addrs {
+		if isPrivateIP(a.IP) {
+			return fmt.Errorf("%w: %s", errWebhookPrivateAddress, a.IP)
+		}
+	}
+	return nil
+}
+
+// 送出用的 HTTP client；未允許私有網路時在連線前檢查位址，不跟隨轉址
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("%w: %s", errWebhookPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+var (
+	webhookClient        = newWebhookClient(false)
+	webhookPrivateClient = newWebhookClient(true)
+)
+
+// 簽章：sha256=<HMAC-SHA256 十六進位>
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDispatcher 建立並送出 webhook；唯讀模式為 nil
+type webhookDispatcher struct {
+	s      *Server
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 啟動背景送出
+func (s *Server) startWebhooks() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &webhookDispatcher{
+		s:      s,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	s.webhooks = d
+	go d.run()
+}
+
+// 停止送出並等待進行中的請求結束（最多 timeout）；未完成的紀錄下次啟動時繼續
+func (d *webhookDispatcher) stop(timeout time.Duration) {
+	if d == nil {
+		return
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+		log.Println("等待 webhook 送出逾時")
+	}
+}
+
+// 立即檢查待送出的紀錄（例如重新送出時）
+func (d *webhookDispatcher) wakeUp() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *webhookDispatcher) run() {
+	defer close(d.done)
+	events, unsubscribe := d.s.broker.subscribe()
+	defer unsubscribe()
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.enqueueEvents(); err != nil && d.ctx.Err() == nil {
+			log.Println("建立 webhook 送出紀錄失敗:", err)
+		}
+		if err := d.deliverDue(); err != nil && d.ctx.Err() == nil {
+			log.Println("送出 webhook 失敗:", err)
+		}
+		select {
+		case <-events:
+		case <-d.wake:
+		case <-ticker.C:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// 為每個啟用的訂閱建立新事件的送出紀錄，並更新訂閱處理到的事件 id
+func (d *webhookDispatcher) enqueueEvents() error {
+	s := d.s
+	rows, err := s.db.QueryContext(d.ctx, "SELECT id, events, last_event_id FROM webhooks WHERE active = 1")
+	if err != nil {
+		return err
+	}
+	type cursor struct {
+		id     int64
+		filter []string
+		lastID int64
+	}
+	var hooks []cursor
+	for rows.Next() {
+		var c cursor
+		var events string
+		if err := rows.Scan(&c.id, &events, &c.lastID); err != nil {
+			rows.Close()
+			return err
+		}
+		c.filter = splitWebhookEvents(events)
+		hooks = append(hooks, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, h := range hooks {
+		for {
+			events, err := s.streamEvents(d.ctx, h.lastID)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				break
+			}
+			lastID := events[len(events)-1].ID
+			err = s.db.withTx(d.ctx, func(tx *Tx) error {
+				for _, e := range events {
+					eventType := e.EntityType + "." + e.Action
+					if !webhookMatches(h.filter, eventType) {
+						continue
+					}
+					body, err := json.Marshal(webhookPayload{Type: eventType, Event: e})
+					if err != nil {
+						return err
+					}
+					sealed, err := tx.cipher.seal("payload", string(body))
+					if err != nil {
+						return err
+					}
+					if _, err := tx.Exec(`
+						INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+						VALUES (?, ?, ?, ?, ?, ?, ?)
+					`, h.id, e.ID, eventType, sealed, webhookPending, dbNow(), dbNow()); err != nil {
+						return err
+					}
+				}
+				_, err := tx.Exec("UPDATE webhooks SET last_event_id = ? WHERE id = ?", lastID, h.id)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			h.lastID = lastID
+			if len(events) < streamBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// 待送出的一筆紀錄
+type webhookJob struct {
+	id           int64
+	eventType    string
+	payload      string
+	attempts     int
+	url          string
+	secret       string
+	allowPrivate bool
+}
+
+// 送出所有到期的紀錄
+func (d *webhookDispatcher) deliverDue() error {
+	for d.ctx.Err() == nil {
+		jobs, err := d.dueJobs()
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if d.ctx.Err() != nil {
+				return nil
+			}
+			d.attempt(job)
+		}
+		if len(jobs) < webhookBatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (d *webhookDispatcher) dueJobs() ([]*webhookJob, error) {
+	s := d.s
+	rows, err := s.db.QueryContext(d.ctx, `
+		SELECT d.id, d.event_type, d.payload, d.attempts, w.url, w.secret, w.allow_private
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND w.active = 1 AND d.next_attempt_at <= ?
+		ORDER BY d.id LIMIT ?`, webhookPending, dbNow(), webhookBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []*webhookJob
+	for rows.Next() {
+		job := &webhookJob{}
+		var allowPrivate int
+		if err := rows.Scan(&job.id, &job.eventType, &job.payload, &job.attempts, &job.url, &job.secret, &allowPrivate); err != nil {
+			return nil, err
+		}
+		job.allowPrivate = allowPrivate != 0
+		if job.payload, err = s.db.cipher.open("payload", job.payload); err != nil {
+			return nil, err
+		}
+		if job.secret, err = s.db.cipher.open("webhook_secret", job.secret); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// 送出一筆並記錄結果；伺服器關閉而中斷時不計入次數
+func (d *webhookDispatcher) attempt(job *webhookJob) {
+	status, retryAfter, sendErr := d.send(job)
+	if sendErr != nil && d.ctx.Err() != nil {
+		return
+	}
+	attempts := job.attempts + 1
+	result, errText := webhookSuccess, ""
+	var next, delivered interface{}
+	switch {
+	case sendErr == nil:
+		delivered = dbNow()
+	case attempts > len(webhookBackoff):
+		result, errText = webhookFailed, truncateMessage(sendErr.Error())
+		log.Printf("webhook 送出失敗（紀錄 %d，已嘗試 %d 次）: %v", job.id, attempts, sendErr)
+	default:
+		result, errText = webhookPending, truncateMessage(sendErr.Error())
+		wait := webhookBackoff[attempts-1]
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		next = dbTime(time.Now().Add(wait))
+	}
+	_, err := d.s.db.execWrite(context.Background(), `
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, error = ?, delivered_at = ?
+		WHERE id = ?
+	`, result, attempts, next, status, errText, delivered, job.id)
+	if err != nil {
+		log.Println("寫入 webhook 送出紀錄失敗:", err)
+	}
+}
+
+// 送出請求，回傳 HTTP 狀態碼（沒有收到回應時為 0）與 429 的 Retry-After
+func (d *webhookDispatcher) send(job *webhookJob) (int, time.Duration, error) {
+	body := []byte(job.payload)
+	req, err := http.NewRequestWithContext(d.ctx, "POST", job.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookmark-server-webhook")
+	req.Header.Set("X-Webhook-Event", job.eventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(job.id, 10))
+	req.Header.Set("X-Signature", webhookSignature(job.secret, body))
+
+	client := webhookClient
+	if job.allowPrivate {
+		client = webhookPrivateClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp.StatusCode, 0, nil
+	}
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp.StatusCode, retryAfter, fmt.Errorf("回應 %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+}
+
+// 刪除超過保留期限且已完成的送出紀錄
+func (s *Server) pruneWebhookDeliveries(ctx context.Context) error {
+	result, err := s.db.execWrite(ctx, "DELETE FROM webhook_deliveries WHERE status <> ? AND created_at < ?",
+		webhookPending, dbTime(time.Now().Add(-webhookDeliveryRetention)))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("已刪除 %d 筆過期的 webhook 送出紀錄", n)
+	}
+	return nil
+}
+
+// 資料庫中以逗號分隔的事件篩選
+func splitWebhookEvents(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+const webhookColumns = "id, url, events, allow_private, active, last_event_id, created_at, updated_at"
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (Webhook, error) {
+	var wh Webhook
+	var events string
+	var allowPrivate, active int
+	err := row.Scan(&wh.ID, &wh.URL, &events, &allowPrivate, &active, &wh.LastEventID, scanTime(&wh.CreatedAt), scanTime(&wh.UpdatedAt))
+	wh.Events = splitWebhookEvents(events)
+	wh.AllowPrivate = allowPrivate != 0
+	wh.Active = active != 0
+	wh.CreatedAt = localTime(wh.CreatedAt)
+	wh.UpdatedAt = localTime(wh.UpdatedAt)
+	return wh, err
+}
+
+func (s *Server) getWebhook(ctx context.Context, id int64) (Webhook, error) {
+	return scanWebhook(s.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+// 路徑中的 id
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", name)
+		return 0, false
+	}
+	return id, true
+}
+
+// 檢查網址並回應錯誤
+func checkWebhookURLRequest(w http.ResponseWriter, r *http.Request, raw string, allowPrivate bool) bool {
+	err := checkWebhookURL(r.Context(), raw, allowPrivate)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errWebhookPrivateAddress):
+		writeError(w, r, http.StatusBadRequest, "webhook_private_address", err.Error())
+	default:
+		writeError(w, r, http.StatusBadRequest, "webhook_invalid_url", err.Error())
+	}
+	return false
+}
+
+func boolFlag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// GET /api/webhooks：列出訂閱
+// POST /api/webhooks：建立訂閱，回應含密鑰（之後不再回傳）
+func (s *Server) webhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		s.createWebhook(w, r)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]Webhook, 0)
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		items = append(items, wh)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(items), "items": items})
+}
+
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.URL == nil || *req.URL == "" {
+		writeError(w, r, http.StatusBadRequest, "webhook_invalid_url", "未設定 url")
+		return
+	}
+	wh := Webhook{URL: *req.URL, Events: []string{}, Active: true}
+	if req.Events != nil {
+		wh.Events = *req.Events
+	}
+	if req.AllowPrivate != nil {
+		wh.AllowPrivate = *req.AllowPrivate
+	}
+	if req.Active != nil {
+		wh.Active = *req.Active
+	}
+	if err := checkWebhookEvents(wh.Events); err != nil {
+		writeError(w, r, http.StatusBadRequest, "webhook_invalid_event", err.Error())
+		return
+	}
+	if !checkWebhookURLRequest(w, r, wh.URL, wh.AllowPrivate) {
+		return
+	}
+	if req.Secret != nil && *req.Secret != "" {
+		wh.Secret = *req.Secret
+	} else {
+		b := make([]byte, 32)
+		rand.Read(b)
+		wh.Secret = hex.EncodeToString(b)
+	}
+
+	err := s.db.withTx(r.Context(), func(tx *Tx) error {
+		sealed, err := tx.cipher.seal("webhook_secret", wh.Secret)
+		if err != nil {
+			return err
+		}
+		// 只送出建立之後的事件
+		if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM events").Scan(&wh.LastEventID); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO webhooks (url, secret, events, allow_private, active, last_event_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			wh.URL, sealed, strings.Join(wh.Events, ","), boolFlag(wh.AllowPrivate), boolFlag(wh.Active), wh.LastEventID, dbNow(), dbNow()).Scan(&wh.ID)
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	created, err := s.getWebhook(r.Context(), wh.ID)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	created.Secret = wh.Secret
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// GET /api/webhooks/{id}：讀取訂閱
+// PUT /api/webhooks/{id}：更新訂閱，只修改提供的欄位
+// DELETE /api/webhooks/{id}：刪除訂閱與其送出紀錄
+func (s *Server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		s.updateWebhook(w, r, id)
+	case "DELETE":
+		result, err := s.db.execWrite(r.Context(), "DELETE FROM webhooks WHERE id = ?", id)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			writeDBError(w, r, sql.ErrNoRows)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "id": id})
+	default:
+		wh, err := s.getWebhook(r.Context(), id)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, wh)
+	}
+}
+
+func (s *Server) updateWebhook(w http.ResponseWriter, r *http.Request, id int64) {
+	var req webhookRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	wh, err := s.getWebhook(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if req.URL != nil {
+		wh.URL = *req.URL
+	}
+	if req.Events != nil {
+		wh.Events = *req.Events
+	}
+	if req.AllowPrivate != nil {
+		wh.AllowPrivate = *req.AllowPrivate
+	}
+	if req.Active != nil {
+		wh.Active = *req.Active
+	}
+	if err := checkWebhookEvents(wh.Events); err != nil {
+		writeError(w, r, http.StatusBadRequest, "webhook_invalid_event", err.Error())
+		return
+	}
+	if !checkWebhookURLRequest(w, r, wh.URL, wh.AllowPrivate) {
+		return
+	}
+
+	err = s.db.withTx(r.Context(), func(tx *Tx) error {
+		if req.Secret != nil && *req.Secret != "" {
+			sealed, err := tx.cipher.seal("webhook_secret", *req.Secret)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE webhooks SET secret = ? WHERE id = ?", sealed, id); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+			UPDATE webhooks SET url = ?, events = ?, allow_private = ?, active = ?, updated_at = ? WHERE id = ?
+		`, wh.URL, strings.Join(wh.Events, ","), boolFlag(wh.AllowPrivate), boolFlag(wh.Active), dbNow(), id)
+		return err
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	// 重新啟用時送出暫停期間累積的紀錄
+	s.webhooks.wakeUp()
+	if wh, err = s.getWebhook(r.Context(), id); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wh)
+}
+
+const webhookDeliveryColumns = "id, webhook_id, event_id, event_type, status, attempts, response_status, error, redelivery_of, created_at, next_attempt_at, delivered_at, payload"
+
+func (s *Server) scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var redeliveryOf sql.NullInt64
+	var next, delivered time.Time
+	var payload string
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error,
+		&redeliveryOf, scanTime(&d.CreatedAt), scanTime(&next), scanTime(&delivered), &payload); err != nil {
+		return d, err
+	}
+	d.RedeliveryOf = redeliveryOf.Int64
+	d.CreatedAt = localTime(d.CreatedAt)
+	if !next.IsZero() && d.Status == webhookPending {
+		t := localTime(next)
+		d.NextAttemptAt = &t
+	}
+	if !delivered.IsZero() {
+		t := localTime(delivered)
+		d.DeliveredAt = &t
+	}
+	plain, err := s.db.cipher.open("payload", payload)
+	if err != nil {
+		return d, err
+	}
+	d.Payload = json.RawMessage(plain)
+	return d, nil
+}
+
+// GET /api/webhooks/{id}/deliveries：送出紀錄，最新的在前，可依 status 篩選，?limit= 預設 100
+func (s *Server) webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if _, err := s.getWebhook(r.Context(), id); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, r, http.StatusBadRequest, "invalid_parameter", "limit")
+			return
+		}
+		limit = n
+	}
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE webhook_id = ?"
+	args := []interface{}{id}
+	if v := q.Get("status"); v != "" {
+		query += " AND status = ?"
+		args = append(args, v)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		d, err := s.scanWebhookDelivery(rows)
+		if err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		items = append(items, d)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(items), "items": items})
+}
+
+// POST /api/webhooks/{id}/deliveries/{delivery_id}/redeliver：以相同內容建立新的送出紀錄並立即送出
+func (s *Server) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathID(w, r, "delivery_id")
+	if !ok {
+		return
+	}
+	wh, err := s.getWebhook(r.Context(), id)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if !wh.Active {
+		writeError(w, r, http.StatusConflict, "webhook_inactive", id)
+		return
+	}
+	var newID int64
+	err = s.db.withTx(r.Context(), func(tx *Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, redelivery_of, created_at)
+			SELECT webhook_id, event_id, event_type, payload, ?, ?, id, ? FROM webhook_deliveries WHERE id = ? AND webhook_id = ?
+			RETURNING id`, webhookPending, dbNow(), dbNow(), deliveryID, id).Scan(&newID)
+	})
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	s.webhooks.wakeUp()
+	d, err := s.scanWebhookDelivery(s.db.QueryRowContext(r.Context(), "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?", newID))
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, d)
+}
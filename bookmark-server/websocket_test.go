@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// 啟動測試伺服器並連上 /api/ws
+func dialTestWebSocket(t *testing.T, h http.Handler, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	if header == nil {
+		header = http.Header{"X-Requested-With": {"test"}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, resp, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", &websocket.DialOptions{HTTPHeader: header})
+	if conn != nil {
+		t.Cleanup(func() { conn.CloseNow() })
+	}
+	return conn, resp, err
+}
+
+// 讀取訊息直到符合 match，略過其他訊息（例如穿插的事件）
+func readWebSocket(t *testing.T, conn *websocket.Conn, match func(map[string]interface{}) bool) map[string]interface{} {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		_, msg, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("讀取 WebSocket 訊息失敗: %v", err)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(msg, &v); err != nil {
+			t.Fatalf("訊息不是 JSON 物件: %v\n%s", err, msg)
+		}
+		if match(v) {
+			return v
+		}
+	}
+}
+
+func writeWebSocket(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := conn.Write(ctx, websocket.MessageText, []byte(msg)); err != nil {
+		t.Fatalf("送出 WebSocket 訊息失敗: %v", err)
+	}
+}
+
+// 指令的回應以 id 對應，內容與 REST 端點相同；書籤異動的事件推送到同一條連線
+func TestWebSocketCommands(t *testing.T) {
+	h := newTestServer(t).routes()
+	conn, _, err := dialTestWebSocket(t, h, nil)
+	if err != nil {
+		t.Fatalf("連線失敗: %v", err)
+	}
+	response := func(id string) func(map[string]interface{}) bool {
+		return func(v map[string]interface{}) bool { return v["type"] == "response" && v["id"] == id }
+	}
+
+	writeWebSocket(t, conn, `{"id":"1","type":"check","job_number":"A001"}`)
+	resp := readWebSocket(t, conn, response("1"))
+	if resp["status"] != float64(http.StatusOK) || resp["body"].(map[string]interface{})["bookmarked"] != false {
+		t.Fatalf("check = %v", resp)
+	}
+
+	writeWebSocket(t, conn, `{"id":"2","type":"toggle","job_number":"A001","bookmark":{"title":"道路工程"}}`)
+	if resp := readWebSocket(t, conn, response("2")); resp["status"] != float64(http.StatusCreated) {
+		t.Fatalf("toggle add = %v", resp)
+	}
+	event := readWebSocket(t, conn, func(v map[string]interface{}) bool { return v["type"] == "event" })
+	if e := event["event"].(map[string]interface{}); e["entity_key"] != "A001" {
+		t.Fatalf("event = %v", event)
+	}
+
+	writeWebSocket(t, conn, `{"id":"3","type":"update","job_number":"A001","bookmark":{"note":"已聯絡"}}`)
+	if resp := readWebSocket(t, conn, response("3")); resp["status"] != float64(http.StatusOK) {
+		t.Fatalf("update = %v", resp)
+	}
+	if b := getTestBookmark(t, h, "A001"); b.Note != "已聯絡" {
+		t.Fatalf("update 未寫入: %v", b)
+	}
+
+	writeWebSocket(t, conn, `{"id":"4","type":"update","job_number":"A001"}`)
+	if resp := readWebSocket(t, conn, response("4")); resp["status"] != float64(http.StatusBadRequest) {
+		t.Fatalf("update without fields = %v", resp)
+	}
+
+	writeWebSocket(t, conn, `{"id":"5","type":"toggle","job_number":"A001"}`)
+	if resp := readWebSocket(t, conn, response("5")); resp["status"] != float64(http.StatusOK) {
+		t.Fatalf("toggle remove = %v", resp)
+	}
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")); resp["bookmarked"] != false {
+		t.Fatalf("toggle 未移除書籤: %v", resp)
+	}
+
+	for _, msg := range []string{`{"id":"6","type":"explode"}`, `{"id":"7","type":"check","extra":1}`, `not json`} {
+		writeWebSocket(t, conn, msg)
+		if resp := readWebSocket(t, conn, func(v map[string]interface{}) bool { return v["type"] == "error" }); resp["error"] != "invalid_message" {
+			t.Fatalf("%s: %v", msg, resp)
+		}
+	}
+}
+
+// 超過上限的訊息以 1009 關閉連線
+func TestWebSocketMessageLimit(t *testing.T) {
+	conn, _, err := dialTestWebSocket(t, newTestServer(t).routes(), nil)
+	if err != nil {
+		t.Fatalf("連線失敗: %v", err)
+	}
+	big := `{"id":"1","type":"update","job_number":"A001","bookmark":{"note":"` + strings.Repeat("x", wsMaxMessageBytes) + `"}}`
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn.Write(ctx, websocket.MessageText, []byte(big))
+	_, _, err = conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusMessageTooBig {
+		t.Fatalf("close status = %v (%v), want 1009", status, err)
+	}
+}
+
+// 指令執行中用戶端斷線不影響伺服器，之後的請求照常處理
+func TestWebSocketAbruptDisconnect(t *testing.T) {
+	h := newTestServer(t).routes()
+	for i := 0; i < 5; i++ {
+		conn, _, err := dialTestWebSocket(t, h, nil)
+		if err != nil {
+			t.Fatalf("連線失敗: %v", err)
+		}
+		writeWebSocket(t, conn, `{"id":"1","type":"toggle","job_number":"A001"}`)
+		conn.CloseNow()
+	}
+
+	conn, _, err := dialTestWebSocket(t, h, nil)
+	if err != nil {
+		t.Fatalf("斷線後無法重新連線: %v", err)
+	}
+	writeWebSocket(t, conn, `{"id":"x","type":"check","job_number":"A001"}`)
+	if resp := readWebSocket(t, conn, func(v map[string]interface{}) bool { return v["id"] == "x" }); resp["status"] != float64(http.StatusOK) {
+		t.Fatalf("check after disconnects = %v", resp)
+	}
+	if w := serve(t, h, "GET", "/api/bookmarks", ""); w.Code != http.StatusOK {
+		t.Fatalf("REST after disconnects = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// 升級請求與 REST 相同做跨站與 API 金鑰檢查
+func TestWebSocketAuth(t *testing.T) {
+	s := newTestServer(t)
+	_, resp, err := dialTestWebSocket(t, s.routes(), http.Header{"Origin": {"https://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-origin upgrade: resp=%v err=%v, want 403", resp, err)
+	}
+
+	s.cfg.APIKeys = []string{testAPIKey}
+	h := s.routes()
+	_, resp, err = dialTestWebSocket(t, h, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("upgrade without key: resp=%v err=%v, want 401", resp, err)
+	}
+	conn, _, err := dialTestWebSocket(t, h, http.Header{"X-Api-Key": {testAPIKey}})
+	if err != nil {
+		t.Fatalf("upgrade with key: %v", err)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 每週報告
+//
+// 每週 weekly_report.weekday 的 weekly_report.time（顯示時區）產生前 7 天的書籤摘要，依機關分組，
+// 各機關再依狀態（新增、更新、決標、截止投標、移除）列出書籤：
+//   - 新增、更新、移除取自書籤事件（同一書籤以最後的狀態為準，新增後又更新只列為新增）
+//   - 決標取自 tender_awards（見 pccg0v.go 的 checkAwards）
+//   - 截止投標為截止時間（見 tenderDeadline）落在期間內的書籤
+// 報告寫成 Markdown 與 HTML 兩個檔案（weekly_<期間結束時間>.md/.html）放在 weekly_report.dir，
+// 並自動掛載為靜態目錄 weekly_report.url_prefix；設定 webhook_url 時另以 {"text": Markdown} POST 給
+// Slack 或 Teams 的 incoming webhook。沒有異動的一週仍會產生一份簡短的報告。
+// 每次產生記錄於 weekly_reports，排程只補最近一次應產生的報告，重新啟動不會重複產生；
+// POST /api/reports/weekly/run 立即產生到現在為止 7 天的報告，GET /api/reports/weekly 列出紀錄。
+
+// WeeklyReportConfig 每週報告設定，enabled 為 true 時啟用
+type WeeklyReportConfig struct {
+	Enabled    bool   `json:"enabled"`
+	Weekday    string `json:"weekday"`     // 星期幾產生（英文名稱，例如 friday），預設 friday
+	Time       string `json:"time"`        // 產生的時間（HH:MM），預設 "17:00"
+	Dir        string `json:"dir"`         // 報告目錄，預設為資料根目錄下的 reports/
+	URLPrefix  string `json:"url_prefix"`  // 報告目錄的靜態掛載路徑，預設 /reports/
+	WebhookURL string `json:"webhook_url"` // Slack/Teams incoming webhook，未設定時只寫入檔案
+
+	weekday time.Weekday
+}
+
+// 報告涵蓋的期間
+const weeklyReportPeriod = 7 * 24 * time.Hour
+
+// 排程檢查是否該產生的間隔
+const weeklyReportCheckInterval = 10 * time.Minute
+
+// 報告紀錄的狀態
+const (
+	weeklyReportSaved         = "saved"          // 已寫入檔案（未設定 webhook）
+	weeklyReportSent          = "sent"           // 已寫入檔案並送出 webhook
+	weeklyReportWebhookFailed = "webhook_failed" // 已寫入檔案，送出 webhook 失敗
+)
+
+// 報告中書籤的狀態，依此順序列出
+const (
+	reportAdded   = "added"
+	reportUpdated = "updated"
+	reportAwarded = "awarded"
+	reportClosed  = "closed"
+	reportRemoved = "removed"
+)
+
+var reportStatuses = []string{reportAdded, reportUpdated, reportAwarded, reportClosed, reportRemoved}
+
+var reportStatusLabels = map[string]string{
+	reportAdded:   "新增",
+	reportUpdated: "更新",
+	reportAwarded: "決標",
+	reportClosed:  "截止投標",
+	reportRemoved: "移除",
+}
+
+// 補上預設值並檢查設定
+func (c *WeeklyReportConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Weekday == "" {
+		c.Weekday = "friday"
+	}
+	found := false
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(c.Weekday, d.String()) {
+			c.weekday, found = d, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("weekly_report.weekday 必須是星期的英文名稱（例如 friday）: %q", c.Weekday)
+	}
+	if c.Time == "" {
+		c.Time = "17:00"
+	}
+	if _, err := time.Parse("15:04", c.Time); err != nil {
+		return fmt.Errorf("weekly_report.time 格式錯誤（HH:MM）: %q", c.Time)
+	}
+	if c.Dir == "" {
+		c.Dir = filepath.Join(dataRoot, "reports")
+	}
+	if c.URLPrefix == "" {
+		c.URLPrefix = "/reports/"
+	}
+	if !strings.HasSuffix(c.URLPrefix, "/") {
+		c.URLPrefix += "/"
+	}
+	if c.WebhookURL != "" {
+		if u, err := url.Parse(c.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("weekly_report.webhook_url 必須是 http 或 https 網址: %q", c.WebhookURL)
+		}
+	}
+	return nil
+}
+
+// 加上報告目錄的靜態掛載；已有掛載指向同一個目錄時沿用
+func (c *WeeklyReportConfig) mount(mounts []StaticMount) []StaticMount {
+	if !c.Enabled {
+		return mounts
+	}
+	for _, m := range mounts {
+		if filepath.Clean(m.Directory) == filepath.Clean(c.Dir) {
+			c.URLPrefix = m.URLPrefix
+			if !strings.HasSuffix(c.URLPrefix, "/") {
+				c.URLPrefix += "/"
+			}
+			return mounts
+		}
+	}
+	return append(mounts, StaticMount{URLPrefix: c.URLPrefix, Directory: c.Dir, ListingEnabled: true})
+}
+
+// 最近一次應產生報告的時間（不晚於 now）
+func (c *WeeklyReportConfig) lastScheduled(now time.Time) time.Time {
+	at, _ := time.Parse("15:04", c.Time)
+	local := localTime(now)
+	t := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, local.Location())
+	t = t.AddDate(0, 0, -((int(t.Weekday()) - int(c.weekday) + 7) % 7))
+	if t.After(now) {
+		t = t.AddDate(0, 0, -7)
+	}
+	return t
+}
+
+// 報告中的一筆書籤
+type reportItem struct {
+	JobNumber string
+	Title     string
+	UnitName  string
+	URL       string
+	Priority  int
+	Detail    string // 決標廠商、截止時間等補充說明
+}
+
+// 一個機關在某個狀態下的書籤
+type reportGroup struct {
+	Label string
+	Items []reportItem
+}
+
+// 一個機關的摘要
+type reportAgency struct {
+	Name   string
+	Counts []int // 依 reportStatuses 的順序
+	Total  int
+	Groups []reportGroup
+}
+
+// 報告內容，範本的資料
+type weeklyReport struct {
+	Start    time.Time
+	End      time.Time
+	Labels   []string
+	Agencies []*reportAgency
+	Totals   []int
+	Total    int
+}
+
+func (rep *weeklyReport) Period() string {
+	return localTime(rep.Start).Format("2006-01-02 15:04") + " ~ " + localTime(rep.End).Format("2006-01-02 15:04")
+}
+
+func (rep *weeklyReport) Title() string {
+	return fmt.Sprintf("政府採購書籤週報 %s ~ %s", localTime(rep.Start).Format("2006-01-02"), localTime(rep.End).Format("2006-01-02"))
+}
+
+// 產生 [start, end) 期間的報告內容
+func (s *Server) buildWeeklyReport(ctx context.Context, start, end time.Time) (*weeklyReport, error) {
+	byStatus := map[string]map[string]reportItem{} // 狀態 → 案號 → 書籤
+	for _, status := range reportStatuses {
+		byStatus[status] = map[string]reportItem{}
+	}
+
+	// 書籤事件：同一書籤以最後一個事件的狀態為準，期間內新增的不再列為更新
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, created_at, actor, entity_type, entity_key, action, payload FROM events
+		WHERE entity_type = ? AND action IN (?, ?, ?) AND created_at >= ? AND created_at < ?
+		ORDER BY id`, entityBookmark, actionCreate, actionUpdate, actionDelete, dbTime(start), dbTime(end))
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.scanEvents(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	created := map[string]bool{}
+	for _, e := range events {
+		var b Bookmark
+		json.Unmarshal(e.Payload, &b)
+		item := reportItem{JobNumber: e.EntityKey, Title: b.Title, UnitName: b.UnitName, URL: b.URL, Priority: b.Priority}
+		if item.Title == "" {
+			item.Title = e.EntityKey
+		}
+		for _, status := range reportStatuses {
+			delete(byStatus[status], e.EntityKey)
+		}
+		switch {
+		case e.Action == actionDelete:
+			byStatus[reportRemoved][e.EntityKey] = item
+		case e.Action == actionCreate || created[e.EntityKey]:
+			created[e.EntityKey] = true
+			byStatus[reportAdded][e.EntityKey] = item
+		default:
+			byStatus[reportUpdated][e.EntityKey] = item
+		}
+	}
+
+	// 期間內偵測到的決標
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT a.job_number, b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0), a.companies, a.amount
+		FROM tender_awards a JOIN bookmarks b ON b.job_number = a.job_number
+		WHERE a.detected_at >= ? AND a.detected_at < ? AND b.deleted_at IS NULL`, dbTime(start), dbTime(end))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var it reportItem
+		var companies, amount string
+		if err := rows.Scan(&it.JobNumber, &it.Title, &it.UnitName, &it.URL, &it.Priority, &companies, &amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var names []string
+		json.Unmarshal([]byte(companies), &names)
+		var detail []string
+		if len(names) > 0 {
+			detail = append(detail, "得標廠商："+strings.Join(names, "、"))
+		}
+		if amount != "" {
+			detail = append(detail, "決標金額："+amount)
+		}
+		it.Detail = strings.Join(detail, "；")
+		byStatus[reportAwarded][it.JobNumber] = it
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// 截止時間落在期間內的書籤
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT b.job_number, b.title, COALESCE(b.unit_name, ''), COALESCE(b.url, ''), COALESCE(b.priority, 0), c.body
+		FROM bookmarks b JOIN tender_cache c ON c.job_number = b.job_number
+		WHERE b.deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var it reportItem
+		var body string
+		if err := rows.Scan(&it.JobNumber, &it.Title, &it.UnitName, &it.URL, &it.Priority, &body); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deadline := tenderDeadline([]byte(body))
+		if deadline.IsZero() || deadline.Before(start) || !deadline.Before(end) {
+			continue
+		}
+		it.Detail = "截止時間：" + localTime(deadline).Format("2006-01-02 15:04")
+		byStatus[reportClosed][it.JobNumber] = it
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &weeklyReport{Start: start, End: end, Totals: make([]int, len(reportStatuses))}
+	agencies := map[string]*reportAgency{}
+	for i, status := range reportStatuses {
+		rep.Labels = append(rep.Labels, reportStatusLabels[status])
+		items := make([]reportItem, 0, len(byStatus[status]))
+		for _, it := range byStatus[status] {
+			items = append(items, it)
+		}
+		sort.Slice(items, func(a, b int) bool {
+			if items[a].Priority != items[b].Priority {
+				return items[a].Priority > items[b].Priority
+			}
+			return items[a].JobNumber < items[b].JobNumber
+		})
+		for _, it := range items {
+			name := strings.TrimSpace(it.UnitName)
+			if name == "" {
+				name = "（未填機關）"
+			}
+			ag := agencies[name]
+			if ag == nil {
+				ag = &reportAgency{Name: name, Counts: make([]int, len(reportStatuses))}
+				agencies[name] = ag
+				rep.Agencies = append(rep.Agencies, ag)
+			}
+			if len(ag.Groups) == 0 || ag.Groups[len(ag.Groups)-1].Label != reportStatusLabels[status] {
+				ag.Groups = append(ag.Groups, reportGroup{Label: reportStatusLabels[status]})
+			}
+			ag.Groups[len(ag.Groups)-1].Items = append(ag.Groups[len(ag.Groups)-1].Items, it)
+			ag.Counts[i]++
+			ag.Total++
+			rep.Totals[i]++
+			rep.Total++
+		}
+	}
+	sort.SliceStable(rep.Agencies, func(a, b int) bool {
+		if rep.Agencies[a].Total != rep.Agencies[b].Total {
+			return rep.Agencies[a].Total > rep.Agencies[b].Total
+		}
+		return rep.Agencies[a].Name < rep.Agencies[b].Name
+	})
+	return rep, nil
+}
+
+// Markdown 表格與連結文字中需要跳脫的字元
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`", "\n", " ")
+
+var weeklyReportFuncs = template.FuncMap{"md": markdownEscaper.Replace}
+
+var weeklyMarkdownTemplate = template.Must(template.New("weekly").Funcs(weeklyReportFuncs).Parse(`# {{.Title}}
+
+期間：{{.Period}}
+
+{{if .Total -}}
+本週共 {{.Total}} 筆書籤異動，涉及 {{len .Agencies}} 個機關。
+
+| 機關 |{{range .Labels}} {{.}} |{{end}} 合計 |
+|---|{{range .Labels}}---:|{{end}}---:|
+{{range .Agencies}}| {{md .Name}} |{{range .Counts}} {{.}} |{{end}} {{.Total}} |
+{{end}}| **合計** |{{range .Totals}} **{{.}}** |{{end}} **{{.Total}}** |
+{{range .Agencies}}
+## {{md .Name}}
+{{range .Groups}}
+**{{.Label}}（{{len .Items}}）**
+{{range .Items}}
+- {{if .URL}}[{{md .Title}}]({{.URL}}){{else}}{{md .Title}}{{end}}（{{md .JobNumber}}{{if .Priority}}，優先順序 {{.Priority}}{{end}}）{{if .Detail}}
+  {{md .Detail}}{{end}}
+{{- end}}
+{{end}}
+{{- end}}
+{{- else -}}
+本週沒有書籤異動。
+{{end}}`))
+
+var weeklyHTMLTemplate = htmltemplate.Must(htmltemplate.New("weekly").Parse(`<!DOCTYPE html>
+<html lang="zh-Hant"><head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; color: #222; max-width: 960px; margin: 24px auto; padding: 0 16px; }
+.meta { color: #666; }
+table { border-collapse: collapse; margin: 12px 0; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; }
+td.n { text-align: right; }
+li { margin-bottom: 6px; }
+</style>
+</head><body>
+<h1>{{.Title}}</h1>
+<p class="meta">期間：{{.Period}}</p>
+{{if .Total}}
+<p>本週共 {{.Total}} 筆書籤異動，涉及 {{len .Agencies}} 個機關。</p>
+<table>
+<tr><th>機關</th>{{range .Labels}}<th>{{.}}</th>{{end}}<th>合計</th></tr>
+{{range .Agencies}}<tr><td>{{.Name}}</td>{{range .Counts}}<td class="n">{{.}}</td>{{end}}<td class="n">{{.Total}}</td></tr>
+{{end}}<tr><th>合計</th>{{range .Totals}}<th class="n">{{.}}</th>{{end}}<th class="n">{{.Total}}</th></tr>
+</table>
+{{range .Agencies}}
+<h2>{{.Name}}</h2>
+{{range .Groups}}<h3>{{.Label}}（{{len .Items}}）</h3>
+<ul>
+{{range .Items}}<li>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}
+<span class="meta">（{{.JobNumber}}{{if .Priority}}，優先順序 {{.Priority}}{{end}}）</span>{{if .Detail}}<br><span class="meta">{{.Detail}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+{{else}}
+<p>本週沒有書籤異動。</p>
+{{end}}
+</body></html>
+`))
+
+// WeeklyReportRecord 報告紀錄
+type WeeklyReportRecord struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	ReportDate    string    `json:"report_date"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Manual        bool      `json:"manual"`
+	Status        string    `json:"status"`
+	Items         int       `json:"items"`
+	Agencies      int       `json:"agencies"`
+	MarkdownFile  string    `json:"markdown_file"`
+	HTMLFile      string    `json:"html_file"`
+	MarkdownURL   string    `json:"markdown_url"`
+	HTMLURL       string    `json:"html_url"`
+	WebhookStatus int       `json:"webhook_status,omitempty"`
+	Error         string    `json:"error"`
+}
+
+// 檔案的網址
+func (s *Server) weeklyReportURLs(rec *WeeklyReportRecord) {
+	prefix := s.cfg.WeeklyReport.URLPrefix
+	rec.MarkdownURL = path.Join(prefix, rec.MarkdownFile)
+	rec.HTMLURL = path.Join(prefix, rec.HTMLFile)
+}
+
+// 送出 Markdown 給 webhook（Slack 與 Teams 的 incoming webhook 都接受 text 欄位）
+func postWeeklyReport(ctx context.Context, webhookURL, markdown string) (int, error) {
+	payload, err := json.Marshal(map[string]string{"text": markdown})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := postNotification(ctx, notifyRetryBackoff, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return resp.Status, err
+	}
+	if resp.Status < 200 || resp.Status > 299 {
+		return resp.Status, fmt.Errorf("webhook 回應 %d: %s", resp.Status, truncateMessage(strings.TrimSpace(string(resp.Body))))
+	}
+	return resp.Status, nil
+}
+
+// 產生 [end-7 天, end) 的報告、寫入檔案並送出 webhook，寫入紀錄
+// 檔案寫入失敗時不留紀錄（排程下次再試）；webhook 失敗時紀錄的狀態為 webhook_failed，並回傳錯誤
+func (s *Server) runWeeklyReport(ctx context.Context, end time.Time, manual bool) (*WeeklyReportRecord, error) {
+	cfg := s.cfg.WeeklyReport
+	start := end.Add(-weeklyReportPeriod)
+	rep, err := s.buildWeeklyReport(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var markdown, html bytes.Buffer
+	if err := weeklyMarkdownTemplate.Execute(&markdown, rep); err != nil {
+		return nil, err
+	}
+	if err := weeklyHTMLTemplate.Execute(&html, rep); err != nil {
+		return nil, err
+	}
+	base := "weekly_" + localTime(end).Format("20060102-1504")
+	rec := &WeeklyReportRecord{
+		ReportDate:   localTime(end).Format("2006-01-02"),
+		PeriodStart:  localTime(start),
+		PeriodEnd:    localTime(end),
+		Manual:       manual,
+		Status:       weeklyReportSaved,
+		Items:        rep.Total,
+		Agencies:     len(rep.Agencies),
+		MarkdownFile: base + ".md",
+		HTMLFile:     base + ".html",
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.Dir, rec.MarkdownFile), markdown.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(cfg.Dir, rec.HTMLFile), html.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var postErr error
+	if cfg.WebhookURL != "" {
+		rec.Status = weeklyReportSent
+		if rec.WebhookStatus, postErr = postWeeklyReport(ctx, cfg.WebhookURL, markdown.String()); postErr != nil {
+			rec.Status = weeklyReportWebhookFailed
+			rec.Error = truncateMessage(postErr.Error())
+		}
+	}
+
+	now := time.Now()
+	err = s.db.withTx(context.Background(), func(tx *Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO weekly_reports (created_at, report_date, period_start, period_end, manual, status, items, agencies,
+				markdown_file, html_file, webhook_status, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			dbTime(now), rec.ReportDate, dbTime(start), dbTime(end), boolFlag(manual), rec.Status, rec.Items, rec.Agencies,
+			rec.MarkdownFile, rec.HTMLFile, rec.WebhookStatus, rec.Error).Scan(&rec.ID)
+	})
+	if err != nil {
+		// 已送出卻沒有紀錄時排程會重複產生，記錄下來以便追查
+		log.Printf("寫入週報紀錄失敗（狀態 %s）: %v", rec.Status, err)
+		return rec, err
+	}
+	rec.CreatedAt = localTime(now)
+	s.weeklyReportURLs(rec)
+	return rec, postErr
+}
+
+// 排程：最近一次應產生的報告還沒有紀錄時產生
+func (s *Server) checkWeeklyReport(ctx context.Context) error {
+	end := s.cfg.WeeklyReport.lastScheduled(time.Now())
+	var done bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM weekly_reports WHERE manual = 0 AND period_end = ?)`, dbTime(end)).Scan(&done)
+	if err != nil || done {
+		return err
+	}
+	rec, err := s.runWeeklyReport(ctx, end, false)
+	if rec == nil {
+		return err
+	}
+	log.Printf("每週報告 %s: %s（%d 筆書籤、%d 個機關）", rec.ReportDate, rec.Status, rec.Items, rec.Agencies)
+	if err != nil {
+		log.Printf("每週報告送出 webhook 失敗: %v", err)
+	}
+	return nil
+}
+
+// POST /api/reports/weekly/run：立即產生到現在為止 7 天的報告，webhook 失敗時回傳 502
+func (s *Server) runWeeklyReportHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.WeeklyReport.Enabled {
+		writeError(w, r, http.StatusConflict, "weekly_report_disabled")
+		return
+	}
+	rec, err := s.runWeeklyReport(r.Context(), time.Now(), true)
+	if rec == nil || (err != nil && rec.ID == 0) {
+		writeInternalError(w, r, "weekly_report_failed", err)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"success": false,
+			"error":   "weekly_report_webhook_failed",
+			"message": localize(requestLanguage(r), "weekly_report_webhook_failed", truncateMessage(err.Error())),
+			"report":  rec,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "report": rec})
+}
+
+// GET /api/reports/weekly：最近 100 筆報告紀錄
+func (s *Server) listWeeklyReports(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.WeeklyReport.Enabled {
+		writeError(w, r, http.StatusConflict, "weekly_report_disabled")
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, created_at, report_date, period_start, period_end, manual, status, items, agencies,
+			markdown_file, html_file, webhook_status, error
+		FROM weekly_reports ORDER BY id DESC LIMIT 100`)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+	items := make([]WeeklyReportRecord, 0)
+	for rows.Next() {
+		var rec WeeklyReportRecord
+		var manual int
+		if err := rows.Scan(&rec.ID, scanTime(&rec.CreatedAt), &rec.ReportDate, scanTime(&rec.PeriodStart), scanTime(&rec.PeriodEnd),
+			&manual, &rec.Status, &rec.Items, &rec.Agencies, &rec.MarkdownFile, &rec.HTMLFile, &rec.WebhookStatus, &rec.Error); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		rec.Manual = manual != 0
+		rec.CreatedAt = localTime(rec.CreatedAt)
+		rec.PeriodStart = localTime(rec.PeriodStart)
+		rec.PeriodEnd = localTime(rec.PeriodEnd)
+		s.weeklyReportURLs(&rec)
+		items = append(items, rec)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(items), "items": items})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// 單一寫入者
+//
+// SQLite 同一時間只允許一個寫入交易。多條連線同時寫入時，後到的交易在檔案鎖上以
+// busy_timeout 輪詢等待，匯入與介面編輯同時進行時延遲會突然拉高；在 WAL 下，
+// 讀取交易升級為寫入時若快照已過期，更會直接回傳 SQLITE_BUSY 而不等待。
+// 因此 SQLite 的所有寫入交易都送到單一 goroutine 依序執行，讀取仍使用連線池中的
+// 其他連線，在 WAL 下不受寫入阻擋。
+//
+// 排隊中的寫入合併在同一個交易中提交，減少 fsync 次數；每個寫入各自包在
+// SAVEPOINT 中，其中一個失敗只回滾它自己的變更，不影響同批的其他寫入。
+// 呼叫端要等整批提交後才會取得結果，因此 withTx 回傳 nil 時資料一定已經寫入。
+//
+// PostgreSQL 有列鎖，寫入可並行，不使用寫入者。
+
+// 每批最多合併的寫入數
+const maxWriteBatch = 64
+
+var (
+	errDBClosed     = errors.New("資料庫已關閉")
+	errWriteAborted = errors.New("寫入交易已中止")
+)
+
+type writeRequest struct {
+	ctx  context.Context
+	fn   func(tx *Tx) error
+	done chan writeResult
+}
+
+type writeResult struct {
+	err      error
+	panicked interface{}
+}
+
+type writer struct {
+	// 不使用緩衝：送出成功就表示寫入者已收到，關閉時不會有遺留在佇列中的寫入
+	queue   chan *writeRequest
+	quit    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	batches  atomic.Uint64
+	writes   atomic.Uint64
+	maxBatch atomic.Uint64
+}
+
+func (d *DB) startWriter() {
+	d.writer = &writer{
+		queue:   make(chan *writeRequest),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go d.runWriter()
+}
+
+// 停止寫入者，等待進行中的批次完成
+func (w *writer) stop() {
+	w.once.Do(func() { close(w.quit) })
+	<-w.stopped
+}
+
+// 送出寫入並等待結果；fn 中的 panic 會在呼叫端重新拋出
+func (w *writer) submit(ctx context.Context, fn func(tx *Tx) error) error {
+	req := &writeRequest{ctx: ctx, fn: fn, done: make(chan writeResult, 1)}
+	select {
+	case w.queue <- req:
+	case <-w.quit:
+		return errDBClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	res := <-req.done
+	if res.panicked != nil {
+		panic(res.panicked)
+	}
+	return res.err
+}
+
+func (d *DB) runWriter() {
+	w := d.writer
+	defer close(w.stopped)
+	for {
+		var first *writeRequest
+		select {
+		case first = <-w.queue:
+		case <-w.quit:
+			return
+		}
+		batch := []*writeRequest{first}
+	drain:
+		for len(batch) < maxWriteBatch {
+			select {
+			case req := <-w.queue:
+				batch = append(batch, req)
+			default:
+				break drain
+			}
+		}
+		d.runBatch(batch)
+	}
+}
+
+// 在同一個交易中依序執行一批寫入
+// 持有寫入閘門的讀鎖，維護作業可藉此暫停所有寫入
+func (d *DB) runBatch(batch []*writeRequest) {
+	d.gate.RLock()
+	defer d.gate.RUnlock()
+
+	w := d.writer
+	w.batches.Add(1)
+	w.writes.Add(uint64(len(batch)))
+	for n := uint64(len(batch)); ; {
+		max := w.maxBatch.Load()
+		if n <= max || w.maxBatch.CompareAndSwap(max, n) {
+			break
+		}
+	}
+
+	results := make([]writeResult, len(batch))
+	deliver := func() {
+		for i, req := range batch {
+			req.done <- results[i]
+		}
+	}
+
+	tx, err := d.BeginTx(context.Background(), nil)
+	if err != nil {
+		for i := range results {
+			results[i].err = err
+		}
+		deliver()
+		return
+	}
+
+	for i, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			results[i].err = err
+			continue
+		}
+		var intact bool
+		results[i], intact = runSavepoint(tx, req.fn)
+		if !intact {
+			// 交易已被資料庫中止（例如磁碟已滿），整批回滾，已執行的寫入也回報失敗
+			tx.Rollback()
+			abortErr := errWriteAborted
+			if results[i].err != nil {
+				abortErr = fmt.Errorf("%w: %w", errWriteAborted, results[i].err)
+			}
+			for j := range results {
+				if j != i && results[j].err == nil && results[j].panicked == nil {
+					results[j].err = abortErr
+				}
+			}
+			deliver()
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		for i := range results {
+			if results[i].err == nil && results[i].panicked == nil {
+				results[i].err = err
+			}
+		}
+	}
+	deliver()
+}
+
+// 以 SAVEPOINT 執行單一寫入，失敗或 panic 時只回滾這個寫入
+// intact 為 false 表示無法回滾到 SAVEPOINT，交易已無法繼續使用
+func runSavepoint(tx *Tx, fn func(tx *Tx) error) (res writeResult, intact bool) {
+	if _, err := tx.Exec("SAVEPOINT write_request"); err != nil {
+		return writeResult{err: err}, false
+	}
+	rollback := func() bool {
+		if _, err := tx.Exec("ROLLBACK TO write_request"); err != nil {
+			return false
+		}
+		_, err := tx.Exec("RELEASE write_request")
+		return err == nil
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			res, intact = writeResult{panicked: p}, rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return writeResult{err: err}, rollback()
+	}
+	if _, err := tx.Exec("RELEASE write_request"); err != nil {
+		return writeResult{err: err}, rollback()
+	}
+	return writeResult{}, true
+}
+
+// 透過 withTx 執行單一寫入陳述式
+func (d *DB) execWrite(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := d.withTx(ctx, func(tx *Tx) error {
+		var err error
+		result, err = tx.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// 寫入者統計，供 /api/admin/metrics 使用
+func (w *writer) stats() map[string]interface{} {
+	if w == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"batches":   w.batches.Load(),
+		"writes":    w.writes.Load(),
+		"max_batch": w.maxBatch.Load(),
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 開啟暫存目錄中的 SQLite 檔案並建立資料表
+func openTestSQLite(t *testing.T) *DB {
+	t.Helper()
+	d, _, err := openSQLite(filepath.Join(t.TempDir(), "bookmarks.db"), false)
+	if err != nil {
+		t.Fatalf("openSQLite: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := initSchema(d); err != nil {
+		t.Fatalf("initSchema: %v", err)
+	}
+	return d
+}
+
+func insertTestRow(jobNumber string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		_, err := tx.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, ?, ?, ?)", jobNumber, "測試", dbNow(), dbNow())
+		return err
+	}
+}
+
+func countTestRows(t *testing.T, d *DB, jobNumber string) int {
+	t.Helper()
+	var n int
+	if err := d.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE job_number = ?", jobNumber).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWriterBatchIsolation(t *testing.T) {
+	d := openTestSQLite(t)
+
+	// 第一個寫入停住，讓其他寫入在佇列中等待，之後合併成同一批
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blockerDone := make(chan error, 1)
+	go func() {
+		blockerDone <- d.withTx(context.Background(), func(tx *Tx) error {
+			close(started)
+			<-release
+			return insertTestRow("BLOCKER")(tx)
+		})
+	}()
+	<-started
+
+	errFail := errors.New("失敗的寫入")
+	type outcome struct {
+		err      error
+		panicked interface{}
+	}
+	fns := map[string]func(tx *Tx) error{
+		"OK1": insertTestRow("OK1"),
+		"OK2": insertTestRow("OK2"),
+		"FAIL": func(tx *Tx) error {
+			insertTestRow("FAIL")(tx)
+			return errFail
+		},
+		"PANIC": func(tx *Tx) error {
+			insertTestRow("PANIC")(tx)
+			panic("寫入 panic")
+		},
+	}
+	var mu sync.Mutex
+	outcomes := map[string]outcome{}
+	var wg sync.WaitGroup
+	for name, fn := range fns {
+		wg.Add(1)
+		go func(name string, fn func(tx *Tx) error) {
+			defer wg.Done()
+			var o outcome
+			func() {
+				defer func() { o.panicked = recover() }()
+				o.err = d.withTx(context.Background(), fn)
+			}()
+			mu.Lock()
+			outcomes[name] = o
+			mu.Unlock()
+		}(name, fn)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if err := <-blockerDone; err != nil {
+		t.Fatalf("第一個寫入失敗: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		wantErr error
+		panics  bool
+		rows    int
+	}{
+		{"OK1", nil, false, 1},
+		{"OK2", nil, false, 1},
+		{"FAIL", errFail, false, 0},
+		{"PANIC", nil, true, 0},
+	}
+	for _, tt := range tests {
+		o := outcomes[tt.name]
+		if !errors.Is(o.err, tt.wantErr) || (o.err == nil) != (tt.wantErr == nil) {
+			t.Errorf("%s: err = %v, want %v", tt.name, o.err, tt.wantErr)
+		}
+		if (o.panicked != nil) != tt.panics {
+			t.Errorf("%s: panicked = %v, want %v", tt.name, o.panicked, tt.panics)
+		}
+		if n := countTestRows(t, d, tt.name); n != tt.rows {
+			t.Errorf("%s: %d 列, want %d", tt.name, n, tt.rows)
+		}
+	}
+	if max := d.writer.maxBatch.Load(); max < 2 {
+		t.Errorf("排隊中的寫入沒有合併: max_batch = %d", max)
+	}
+}
+
+func TestWriterCanceledAndClosed(t *testing.T) {
+	d := openTestSQLite(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.withTx(ctx, insertTestRow("A001")); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的寫入: err = %v, want context.Canceled", err)
+	}
+	if n := countTestRows(t, d, "A001"); n != 0 {
+		t.Errorf("已取消的寫入仍寫入 %d 列", n)
+	}
+
+	d.writer.stop()
+	if err := d.withTx(context.Background(), insertTestRow("A002")); !errors.Is(err, errDBClosed) {
+		t.Errorf("停止後寫入: err = %v, want errDBClosed", err)
+	}
+}
+
+// 匯入 1000 筆時檢查端點的延遲不應隨寫入排隊而暴增
+func TestCheckLatencyDuringImport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("負載測試")
+	}
+	s := newFileTestServer(t)
+	h := s.routes()
+	addTestBookmark(t, h, `{"job_number":"A001","title":"既有"}`)
+
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"job_number":"IMP%04d","title":"匯入 %d","note":"備註"}`, i, i)
+	}
+	b.WriteString("]")
+
+	importDone := make(chan int, 1)
+	go func() {
+		importDone <- serve(t, h, "POST", "/api/bookmarks/import", b.String()).Code
+	}()
+
+	var latencies []time.Duration
+	timeout := time.After(30 * time.Second)
+loop:
+	for {
+		select {
+		case status := <-importDone:
+			if status != http.StatusOK {
+				t.Fatalf("匯入回應 %d", status)
+			}
+			break loop
+		case <-timeout:
+			t.Fatal("匯入逾時")
+		default:
+		}
+		start := time.Now()
+		w := serve(t, h, "GET", "/api/bookmarks/check?job_number=A001", "")
+		latencies = append(latencies, time.Since(start))
+		if w.Code != http.StatusOK {
+			t.Fatalf("匯入期間檢查回應 %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	if resp := decodeBody(t, serve(t, h, "GET", "/api/bookmarks/list?format_version=2", "")); resp["count"] != float64(1001) {
+		t.Fatalf("匯入後 count = %v, want 1001", resp["count"])
+	}
+	if len(latencies) == 0 {
+		t.Skip("匯入在第一次檢查前就完成")
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	t.Logf("匯入期間檢查 %d 次，p50=%s p99=%s", len(latencies), latencies[len(latencies)/2], p99)
+	if p99 > 200*time.Millisecond {
+		t.Errorf("匯入期間檢查的 p99 延遲 %s 超過 200ms", p99)
+	}
+}
+
+func BenchmarkWriterConcurrentWrites(b *testing.B) {
+	d, _, err := openSQLite(filepath.Join(b.TempDir(), "bookmarks.db"), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.Close()
+	if err := initSchema(d); err != nil {
+		b.Fatal(err)
+	}
+	var mu sync.Mutex
+	i := 0
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			i++
+			jn := fmt.Sprintf("B%08d", i)
+			mu.Unlock()
+			err := d.withTx(context.Background(), func(tx *Tx) error {
+				_, err := tx.Exec("INSERT INTO bookmarks (job_number, title, created_at, updated_at) VALUES (?, ?, ?, ?)", jn, "測試", dbNow(), dbNow())
+				return err
+			})
+			if err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
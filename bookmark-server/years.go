@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 資料根目錄，各年度的資料放在 <dataRoot>/<西元年>/ 之下
+var dataRoot = filepath.Join("..", "pcc_data")
+
+// 台灣時區（無日光節約時間），以固定時差表示，不依賴系統的時區資料
+var taipei = time.FixedZone("Asia/Taipei", 8*60*60)
+
+// 民國紀年與西元紀年的差
+const rocYearOffset = 1911
+
+// 預設年度：顯示時區（預設台灣時間）的西元年，跨年當天 00:00 起即使用新年度
+func currentYear() int {
+	return time.Now().In(displayLocation).Year()
+}
+
+// 解析年度參數，接受西元年（2026）或民國年（115）
+func parseYear(value string) (int, error) {
+	year, err := strconv.Atoi(value)
+	if err != nil || year <= 0 {
+		return 0, fmt.Errorf("年度格式錯誤: %q", value)
+	}
+	if year < rocYearOffset {
+		year += rocYearOffset
+	}
+	return year, nil
+}
+
+func yearDir(year int) string {
+	return filepath.Join(dataRoot, strconv.Itoa(year))
+}
+
+// 年度資料庫路徑
+func yearDBPath(year int) string {
+	return filepath.Join(yearDir(year), "bookmarks.db")
+}
+
+// 主要年度的資料庫路徑，設定 db_path 時使用該檔案
+func (cfg Config) primaryDBPath() string {
+	if cfg.DBPath != "" {
+		return cfg.DBPath
+	}
+	return yearDBPath(cfg.Year)
+}
+
+// 書籤標書的下載目錄
+func (s *Server) tendersDir() string {
+	return filepath.Join(yearDir(s.cfg.Year), "bookmarked_tenders")
+}
+
+var errUnknownYear = errors.New("年度資料庫不存在")
+
+// 年度在可建立的範圍內，但資料庫檔案尚未建立
+var errYearNotFound = errors.New("年度資料庫尚未建立")
+
+// 已開啟的年度資料庫，由主要年度的 Server 持有，關閉伺服器時一併關閉
+type yearRegistry struct {
+	mu      sync.Mutex
+	servers map[int]*Server
+}
+
+// 取得指定年度的 Server，尚未開啟時才開啟
+// 資料庫檔案不存在時，create 為 false 回傳 errYearNotFound；為 true 時建立並執行所有遷移，
+// 只有不晚於明年的年度可以建立。一般請求不可建立，只有管理路徑（POST /api/admin/years）會傳 true
+func (s *Server) forYear(year int, create bool) (*Server, error) {
+	if year == s.cfg.Year {
+		return s, nil
+	}
+
+	s.years.mu.Lock()
+	defer s.years.mu.Unlock()
+	if ys, ok := s.years.servers[year]; ok {
+		return ys, nil
+	}
+
+	if s.db.dialect != dialectSQLite || s.dbPath == ":memory:" {
+		return nil, errUnknownYear
+	}
+	path := yearDBPath(year)
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if s.cfg.ReadOnly || year > currentYear()+1 || year < 2000 {
+			return nil, errUnknownYear
+		}
+		if !create {
+			return nil, errYearNotFound
+		}
+	}
+
+	cfg := s.cfg
+	cfg.Year = year
+	ys, err := newServer(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	ys.years = s.years
+	ys.broker = s.broker
+	ys.notifications = s.notifications
+	ys.g0v = s.g0v
+	s.years.servers[year] = ys
+	log.Printf("已開啟 %d 年度資料庫: %s", year, path)
+	return ys, nil
+}
+
+// 依 ?year= 選擇年度資料庫，未指定時使用設定的年度
+func (s *Server) yearScoped(handler func(ys *Server) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ys := s
+		if value := r.URL.Query().Get("year"); value != "" {
+			year, err := parseYear(value)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "invalid_parameter", "year")
+				return
+			}
+			ys, err = s.forYear(year, false)
+			if errors.Is(err, errUnknownYear) {
+				writeError(w, r, http.StatusNotFound, "unknown_year", year)
+				return
+			}
+			if errors.Is(err, errYearNotFound) {
+				writeError(w, r, http.StatusNotFound, "year_not_found", year)
+				return
+			}
+			if err != nil {
+				writeDBError(w, r, err)
+				return
+			}
+		}
+		handler(ys)(w, r)
+	}
+}
+
+// 建立年度資料庫並執行所有遷移，已存在時只開啟
+// POST /api/admin/years?year=2027，回應 created 表示是否新建立
+func (s *Server) createYear(w http.ResponseWriter, r *http.Request) {
+	year, err := parseYear(r.URL.Query().Get("year"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "invalid_parameter", "year")
+		return
+	}
+	ys, err := s.forYear(year, false)
+	created := false
+	if errors.Is(err, errYearNotFound) {
+		ys, err = s.forYear(year, true)
+		created = err == nil
+	}
+	if errors.Is(err, errUnknownYear) {
+		writeError(w, r, http.StatusNotFound, "unknown_year", year)
+		return
+	}
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	status, code := http.StatusOK, "year_opened"
+	if created {
+		status, code = http.StatusCreated, "year_created"
+		log.Printf("已建立 %d 年度資料庫: %s", year, ys.dbPath)
+	}
+	writeSuccess(w, r, status, code, map[string]interface{}{"year": year, "created": created})
+}
@@ -583,11 +583,12 @@ def generate_html(tenders):
         // 檢查伺服器連線
         async function checkConnection() {{
             try {{
-                const response = await fetch(API_BASE + '/bookmarks/list');
+                const response = await fetch(API_BASE + '/bookmarks/list?format_version=2');
                 if (response.ok) {{
                     isConnected = true;
-                    const jobs = await response.json();
-                    bookmarkedJobs = new Set(jobs || []);
+                    // 較舊的伺服器不認得 format_version，仍回傳案號陣列
+                    const list = await response.json();
+                    bookmarkedJobs = new Set(Array.isArray(list) ? list : (list.job_numbers || []));
                     updateConnectionStatus(true);
                     updateBookmarkCount();
                     document.getElementById('downloadBtn').style.display = bookmarkedJobs.size > 0 ? 'inline-flex' : 'none';
@@ -655,11 +656,15 @@ def generate_html(tenders):
                 try {{
                     if (isBookmarked) {{
                         // 刪除書籤
-                        await fetch(API_BASE + '/bookmarks?job_number=' + encodeURIComponent(jobNumber), {{
+                        const response = await fetch(API_BASE + '/bookmarks?job_number=' + encodeURIComponent(jobNumber), {{
                             method: 'DELETE'
                         }});
                         bookmarkedJobs.delete(jobNumber);
-                        showToast('已移除書籤');
+                        if (response.status === 404) {{
+                            showToast('書籤不存在（可能已在其他地方移除）', 'error');
+                        }} else {{
+                            showToast('已移除書籤');
+                        }}
                     }} else {{
                         // 新增書籤
                         await fetch(API_BASE + '/bookmarks', {{
@@ -730,11 +735,39 @@ def generate_html(tenders):
             showToast('開始下載標書...');
             
             try {{
-                const response = await fetch(API_BASE + '/bookmarks/download');
-                const result = await response.json();
+                // 伺服器逐筆回傳 NDJSON，最後一行為摘要
+                // 下載即使是 GET 也會寫入，伺服器要求沒有 Origin 的請求帶 X-Requested-With
+                const response = await fetch(API_BASE + '/bookmarks/download', {{
+                    headers: {{ 'X-Requested-With': 'fetch' }}
+                }});
+                const reader = response.body.getReader();
+                const decoder = new TextDecoder();
+                let buffer = '';
+                let done = 0;
+                let summary = null;
+                while (true) {{
+                    const {{ value, done: finished }} = await reader.read();
+                    if (finished) break;
+                    buffer += decoder.decode(value, {{ stream: true }});
+                    const lines = buffer.split('\n');
+                    buffer = lines.pop();
+                    for (const line of lines) {{
+                        if (!line.trim()) continue;
+                        const item = JSON.parse(line);
+                        if (item.type === 'summary') {{
+                            summary = item;
+                        }} else {{
+                            done++;
+                            showToast(`下載中... ${{done}} 筆`);
+                        }}
+                    }}
+                }}
                 
-                const success = result.results.filter(r => r.status === 'success').length;
-                showToast(`下載完成！成功 ${{success}}/${{result.total}} 筆`);
+                if (summary) {{
+                    showToast(`下載完成！成功 ${{summary.success}}/${{summary.total}} 筆`);
+                }} else {{
+                    showToast('下載中斷', 'error');
+                }}
             }} catch (e) {{
                 showToast('下載失敗: ' + e.message, 'error');
             }}